@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +32,11 @@ import (
 const (
 	GithubAccessManagementURL = "https://github.com/settings/apps/authorizations"
 	UpdateMaxRequestSize      = 64 * 1024
+	HealthCheckTimeout        = 5 * time.Second
+	OmahaDebugHeader          = "X-Nebraska-Debug"
+	OmahaUpdateDecisionHeader = "X-Nebraska-Update-Decision"
+	OmahaSignatureHeader      = "X-Nebraska-Signature"
+	EventStreamHeartbeat      = 30 * time.Second
 )
 
 // ClientConfig represents Nebraska's configuration of interest for the client.
@@ -42,11 +51,12 @@ type ClientConfig struct {
 }
 
 type controller struct {
-	api          *api.API
-	omahaHandler *omaha.Handler
-	syncer       *syncer.Syncer
-	clientConfig *ClientConfig
-	auth         auth.Authenticator
+	api           *api.API
+	omahaHandler  *omaha.Handler
+	omahaRecorder *omaha.RequestRecorder
+	syncer        *syncer.Syncer
+	clientConfig  *ClientConfig
+	auth          auth.Authenticator
 }
 
 type controllerConfig struct {
@@ -60,6 +70,13 @@ type controllerConfig struct {
 	oidcAuthConfig      *auth.OIDCAuthConfig
 	flatcarUpdatesURL   string
 	checkFrequency      time.Duration
+	// omahaRecorderSize is the number of raw Omaha requests kept to be
+	// replayed, 0 disables recording them.
+	omahaRecorderSize int
+	// rolloutPollInterval and idlePollInterval are the intervals the
+	// instances are told to check for updates with, 0 doesn't hint them.
+	rolloutPollInterval time.Duration
+	idlePollInterval    time.Duration
 }
 
 func loggerWithUsername(l zerolog.Logger, c *gin.Context) zerolog.Logger {
@@ -73,6 +90,17 @@ func loggerWithUsername(l zerolog.Logger, c *gin.Context) zerolog.Logger {
 	return logger.With().Str("username", username.(string)).Logger()
 }
 
+// requestActor returns the username of the user making the request provided,
+// if it's known.
+func requestActor(c *gin.Context) string {
+	session := ginsessions.GetSession(c)
+	if session == nil {
+		return ""
+	}
+	username, _ := session.Get("username").(string)
+	return username
+}
+
 func newController(conf *controllerConfig) (*controller, error) {
 	authenticator, err := getAuthenticator(conf)
 	if err != nil {
@@ -101,6 +129,12 @@ func newController(conf *controllerConfig) (*controller, error) {
 		go syncer.Start()
 	}
 
+	if conf.omahaRecorderSize > 0 {
+		c.omahaRecorder = omaha.NewRequestRecorder(conf.omahaRecorderSize)
+		c.omahaHandler.RecordRequests(c.omahaRecorder)
+	}
+	c.omahaHandler.SetPollIntervals(conf.rolloutPollInterval, conf.idlePollInterval)
+
 	c.clientConfig = NewClientConfig(conf)
 
 	return c, nil
@@ -126,10 +160,49 @@ func getAuthenticator(config *controllerConfig) (auth.Authenticator, error) {
 	return nil, fmt.Errorf("authentication method not configured")
 }
 
+// apiForRequest returns the api instance to use to serve the request
+// provided, which records the user making it as the author of the changes in
+// the audit log and only gives access to the applications of the user's team.
+func (ctl *controller) apiForRequest(c *gin.Context) *api.API {
+	return ctl.api.WithActor(requestActor(c)).WithTeam(c.GetString("team_id"))
+}
+
+// activeWithinParam returns the window in which instances must have checked
+// for updates to be counted as active in the stats, taken from the
+// active_within query parameter of the request as a duration like 168h. It
+// returns zero when the parameter is not set, so the default window is used.
+func activeWithinParam(c *gin.Context) (time.Duration, error) {
+	if c.Query("active_within") == "" {
+		return 0, nil
+	}
+	activeWithin, err := time.ParseDuration(c.Query("active_within"))
+	if err != nil {
+		return 0, err
+	}
+	if activeWithin <= 0 {
+		return 0, api.ErrInvalidActiveWithin
+	}
+	return activeWithin, nil
+}
+
 func httpError(c *gin.Context, status int) {
 	c.AbortWithStatus(status)
 }
 
+// httpStatusForError returns the http status matching the kind of an error
+// returned by the api package. Validation errors and the ones without a kind
+// are bad requests.
+func httpStatusForError(err error) int {
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, api.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 func NewClientConfig(conf *controllerConfig) *ClientConfig {
 	config := &ClientConfig{}
 
@@ -200,6 +273,11 @@ func (ctl *controller) updateUserPassword(c *gin.Context) {
 func (ctl *controller) addApp(c *gin.Context) {
 	logger := loggerWithUsername(logger, c)
 
+	if c.Request.URL.Query().Get("import") == "true" {
+		ctl.importApp(c)
+		return
+	}
+
 	sourceAppID := c.Request.URL.Query().Get("clone_from")
 
 	app := &api.Application{}
@@ -210,14 +288,14 @@ func (ctl *controller) addApp(c *gin.Context) {
 	}
 	app.TeamID = c.GetString("team_id")
 
-	_, err := ctl.api.AddAppCloning(app, sourceAppID)
+	_, err := ctl.apiForRequest(c).AddAppCloning(app, sourceAppID)
 	if err != nil {
 		logger.Error().Err(err).Str("sourceAppID", sourceAppID).Msgf("addApp - cloning app %v", app)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	app, err = ctl.api.GetApp(app.ID)
+	app, err = ctl.apiForRequest(c).GetApp(app.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("appID", app.ID).Msg("addApp - getting added app")
 		httpError(c, http.StatusInternalServerError)
@@ -230,13 +308,56 @@ func (ctl *controller) addApp(c *gin.Context) {
 	logger.Info().Msgf("addApp - successfully added app %+v", app)
 }
 
+func (ctl *controller) importApp(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	data, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Error().Err(err).Msg("importApp - reading payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	app, err := ctl.apiForRequest(c).ImportAppToTeam(data, c.GetString("team_id"))
+	if err != nil {
+		logger.Error().Err(err).Msg("importApp - importing app")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(app); err != nil {
+		logger.Error().Err(err).Msgf("importApp - encoding app %v", app)
+	}
+
+	logger.Info().Msgf("importApp - successfully imported app %+v", app)
+}
+
+func (ctl *controller) exportApp(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	data, err := ctl.apiForRequest(c).ExportApp(appID)
+	switch err {
+	case nil:
+		c.Data(http.StatusOK, "application/json", data)
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("exportApp - exporting app")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 func (ctl *controller) updateApp(c *gin.Context) {
 	logger := loggerWithUsername(logger, c)
 
 	appID := c.Params.ByName("app_id")
 
-	oldApp, err := ctl.api.GetApp(appID)
-	if err != nil {
+	oldApp, err := ctl.apiForRequest(c).GetApp(appID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("appID", appID).Msg("updateApp - getting old app to update")
 		httpError(c, http.StatusInternalServerError)
 		return
@@ -251,14 +372,14 @@ func (ctl *controller) updateApp(c *gin.Context) {
 	app.ID = appID
 	app.TeamID = c.GetString("team_id")
 
-	err = ctl.api.UpdateApp(app)
+	err = ctl.apiForRequest(c).UpdateApp(app)
 	if err != nil {
 		logger.Error().Err(err).Msgf("updatedApp - updating app %+v", app)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	app, err = ctl.api.GetApp(app.ID)
+	app, err = ctl.apiForRequest(c).GetApp(app.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("appID", app.ID).Msg("updateApp - getting updated app")
 		httpError(c, http.StatusInternalServerError)
@@ -276,14 +397,19 @@ func (ctl *controller) deleteApp(c *gin.Context) {
 
 	appID := c.Params.ByName("app_id")
 
-	app, err := ctl.api.GetApp(appID)
-	if err != nil {
-		logger.Error().Err(err).Str("appID", app.ID).Msg("deleteApp - getting app to delete")
+	app, err := ctl.apiForRequest(c).GetApp(appID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("deleteApp - getting app to delete")
 		httpError(c, http.StatusInternalServerError)
 		return
 	}
 
-	err = ctl.api.DeleteApp(appID)
+	err = ctl.apiForRequest(c).DeleteApp(appID)
 	switch err {
 	case nil:
 		c.Status(http.StatusNoContent)
@@ -295,10 +421,66 @@ func (ctl *controller) deleteApp(c *gin.Context) {
 	logger.Info().Msgf("deleteApp - successfully deleted app %+v", app)
 }
 
+func (ctl *controller) restoreApp(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+
+	err := ctl.apiForRequest(c).RestoreApp(appID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows, api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("restoreApp")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	app, err := ctl.apiForRequest(c).GetApp(appID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("restoreApp - getting restored app")
+		httpError(c, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(app); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msg("restoreApp - encoding app")
+	}
+
+	logger.Info().Msgf("restoreApp - successfully restored app %+v", app)
+}
+
+func (ctl *controller) purgeApp(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+
+	err := ctl.apiForRequest(c).PurgeApp(appID)
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case sql.ErrNoRows, api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("purgeApp")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info().Str("appID", appID).Msg("purgeApp - successfully purged app")
+}
+
 func (ctl *controller) getApp(c *gin.Context) {
 	appID := c.Params.ByName("app_id")
 
-	app, err := ctl.api.GetApp(appID)
+	app, err := ctl.apiForRequest(c).GetApp(appID)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(app); err != nil {
@@ -317,7 +499,7 @@ func (ctl *controller) getApps(c *gin.Context) {
 	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
 	perPage, _ := strconv.ParseUint(c.Query("perpage"), 10, 64)
 
-	apps, err := ctl.api.GetApps(teamID, page, perPage)
+	apps, err := ctl.apiForRequest(c).GetApps(teamID, page, perPage)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(apps); err != nil {
@@ -331,6 +513,121 @@ func (ctl *controller) getApps(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getDashboardSummary(c *gin.Context) {
+	summary, err := ctl.apiForRequest(c).GetDashboardSummary()
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(summary); err != nil {
+			logger.Error().Err(err).Msg("getDashboardSummary - encoding summary")
+		}
+	default:
+		logger.Error().Err(err).Msg("getDashboardSummary - getting summary")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getAppRegistrationPolicy(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	policy, err := ctl.apiForRequest(c).GetInstanceRegistrationPolicy(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(policy); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getAppRegistrationPolicy - encoding policy")
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getAppRegistrationPolicy - getting policy")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) setAppRegistrationPolicy(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+
+	policy := &api.InstanceRegistrationPolicy{}
+	if err := json.NewDecoder(c.Request.Body).Decode(policy); err != nil {
+		logger.Error().Err(err).Msg("setAppRegistrationPolicy - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	if err := ctl.apiForRequest(c).SetInstanceRegistrationPolicy(appID, policy); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msgf("setAppRegistrationPolicy - setting policy %+v", policy)
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	policy, err := ctl.apiForRequest(c).GetInstanceRegistrationPolicy(appID)
+	if err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msg("setAppRegistrationPolicy - getting updated policy")
+		httpError(c, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(policy); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msg("setAppRegistrationPolicy - encoding policy")
+	}
+
+	logger.Info().Msgf("setAppRegistrationPolicy - successfully updated registration policy of app %s to %+v", appID, policy)
+}
+
+func (ctl *controller) getTrackAliases(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	aliases, err := ctl.apiForRequest(c).GetTrackAliases(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(aliases); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getTrackAliases - encoding aliases")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getTrackAliases - getting aliases")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) setTrackAlias(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	alias := c.Params.ByName("alias")
+
+	params := struct {
+		Track string `json:"track"`
+	}{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&params); err != nil {
+		logger.Error().Err(err).Msg("setTrackAlias - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	if err := ctl.apiForRequest(c).SetTrackAlias(appID, alias, params.Track); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Str("alias", alias).Str("track", params.Track).Msg("setTrackAlias - setting alias")
+		httpError(c, httpStatusForError(err))
+		return
+	}
+	logger.Info().Str("appID", appID).Str("alias", alias).Str("track", params.Track).Msg("setTrackAlias - successfully set alias")
+	c.Status(http.StatusNoContent)
+}
+
+func (ctl *controller) deleteTrackAlias(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	alias := c.Params.ByName("alias")
+
+	if err := ctl.apiForRequest(c).DeleteTrackAlias(appID, alias); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Str("alias", alias).Msg("deleteTrackAlias - deleting alias")
+		httpError(c, httpStatusForError(err))
+		return
+	}
+	logger.Info().Str("appID", appID).Str("alias", alias).Msg("deleteTrackAlias - successfully deleted alias")
+	c.Status(http.StatusNoContent)
+}
+
 // ----------------------------------------------------------------------------
 // API: groups CRUD
 //
@@ -346,14 +643,19 @@ func (ctl *controller) addGroup(c *gin.Context) {
 	}
 	group.ApplicationID = c.Params.ByName("app_id")
 
-	_, err := ctl.api.AddGroup(group)
-	if err != nil {
+	_, err := ctl.apiForRequest(c).AddGroup(group)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Msgf("addGroup - adding group %v", group)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	group, err = ctl.api.GetGroup(group.ID)
+	group, err = ctl.apiForRequest(c).GetGroup(group.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("groupID", group.ID).Msg("addGroup - getting added group")
 		httpError(c, http.StatusInternalServerError)
@@ -371,8 +673,13 @@ func (ctl *controller) updateGroup(c *gin.Context) {
 
 	groupID := c.Params.ByName("group_id")
 
-	oldGroup, err := ctl.api.GetGroup(groupID)
-	if err != nil {
+	oldGroup, err := ctl.apiForRequest(c).GetGroup(groupID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("groupID", groupID).Msg("updateGroup - getting old group to update")
 		httpError(c, http.StatusInternalServerError)
 		return
@@ -387,14 +694,14 @@ func (ctl *controller) updateGroup(c *gin.Context) {
 	group.ID = groupID
 	group.ApplicationID = c.Params.ByName("app_id")
 
-	err = ctl.api.UpdateGroup(group)
+	err = ctl.apiForRequest(c).UpdateGroup(group)
 	if err != nil {
 		logger.Error().Err(err).Msgf("updateGroup - updating group %+v", group)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	group, err = ctl.api.GetGroup(group.ID)
+	group, err = ctl.apiForRequest(c).GetGroup(group.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("groupID", group.ID).Msg("updateGroup - fetching updated group")
 		httpError(c, http.StatusInternalServerError)
@@ -407,19 +714,50 @@ func (ctl *controller) updateGroup(c *gin.Context) {
 	logger.Info().Msgf("updateGroup - successfully updated group %+v -> %+v", oldGroup, group)
 }
 
+func (ctl *controller) patchGroup(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	groupID := c.Params.ByName("group_id")
+
+	changes := make(map[string]interface{})
+	if err := json.NewDecoder(c.Request.Body).Decode(&changes); err != nil {
+		logger.Error().Err(err).Msg("patchGroup - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	group, err := ctl.apiForRequest(c).PatchGroup(groupID, changes)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(group); err != nil {
+			logger.Error().Err(err).Msgf("patchGroup - encoding group %v", group)
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("groupID", groupID).Msgf("patchGroup - patching group with %v", changes)
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 func (ctl *controller) deleteGroup(c *gin.Context) {
 	logger := loggerWithUsername(logger, c)
 
 	groupID := c.Params.ByName("group_id")
 
-	group, err := ctl.api.GetGroup(groupID)
-	if err != nil {
+	group, err := ctl.apiForRequest(c).GetGroup(groupID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("groupID", group.ID).Msg("deleteGroup - fetching group to delete")
 		httpError(c, http.StatusInternalServerError)
 		return
 	}
 
-	err = ctl.api.DeleteGroup(groupID)
+	err = ctl.apiForRequest(c).DeleteGroup(groupID)
 	switch err {
 	case nil:
 		c.Status(http.StatusNoContent)
@@ -431,46 +769,147 @@ func (ctl *controller) deleteGroup(c *gin.Context) {
 	logger.Info().Msgf("deleteGroup - successfully deleted group %+v", group)
 }
 
-func (ctl *controller) getGroup(c *gin.Context) {
+func (ctl *controller) cloneGroup(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
 	groupID := c.Params.ByName("group_id")
 
-	group, err := ctl.api.GetGroup(groupID)
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&params); err != nil {
+		logger.Error().Err(err).Msg("cloneGroup - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	group, err := ctl.apiForRequest(c).CloneGroup(groupID, params.Name)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(group); err != nil {
-			logger.Error().Err(err).Msgf("getGroup - encoding group %v", group)
+			logger.Error().Err(err).Msgf("cloneGroup - encoding group %v", group)
 		}
+		logger.Info().Msgf("cloneGroup - successfully cloned group %s into %+v", groupID, group)
 	case sql.ErrNoRows:
 		httpError(c, http.StatusNotFound)
+	case api.ErrGroupNameExists:
+		httpError(c, http.StatusConflict)
 	default:
-		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroup - getting group")
+		logger.Error().Err(err).Str("groupID", groupID).Msg("cloneGroup")
 		httpError(c, http.StatusBadRequest)
 	}
 }
 
-func (ctl *controller) getGroups(c *gin.Context) {
-	appID := c.Params.ByName("app_id")
-	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
-	perPage, _ := strconv.ParseUint(c.Query("perpage"), 10, 64)
+func (ctl *controller) pauseGroup(c *gin.Context) {
+	ctl.setGroupPaused(c, true)
+}
+
+func (ctl *controller) resumeGroup(c *gin.Context) {
+	ctl.setGroupPaused(c, false)
+}
+
+func (ctl *controller) setGroupPaused(c *gin.Context, paused bool) {
+	logger := loggerWithUsername(logger, c)
+
+	groupID := c.Params.ByName("group_id")
 
-	groups, err := ctl.api.GetGroups(appID, page, perPage)
+	var err error
+	if paused {
+		err = ctl.apiForRequest(c).PauseGroup(groupID)
+	} else {
+		err = ctl.apiForRequest(c).ResumeGroup(groupID)
+	}
 	switch err {
 	case nil:
-		if err := json.NewEncoder(c.Writer).Encode(groups); err != nil {
-			logger.Error().Err(err).Str("appID", appID).Msg("getGroups - encoding groups")
-		}
-	case sql.ErrNoRows:
+	case sql.ErrNoRows, api.ErrNoRowsAffected:
 		httpError(c, http.StatusNotFound)
+		return
 	default:
-		logger.Error().Err(err).Str("appID", appID).Msg("getGroups - getting groups")
+		logger.Error().Err(err).Str("groupID", groupID).Bool("paused", paused).Msg("setGroupPaused - updating group")
 		httpError(c, http.StatusBadRequest)
+		return
 	}
-}
 
-func (ctl *controller) getGroupVersionCountTimeline(c *gin.Context) {
-	groupID := c.Params.ByName("group_id")
-	duration := c.Query("duration")
-	versionCountTimeline, isCache, err := ctl.api.GetGroupVersionCountTimeline(groupID, duration)
+	group, err := ctl.apiForRequest(c).GetGroup(groupID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("groupID", groupID).Msg("setGroupPaused - fetching updated group")
+		httpError(c, http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(group); err != nil {
+		logger.Error().Err(err).Msgf("setGroupPaused - encoding group %v", group)
+	}
+
+	logger.Info().Str("groupID", groupID).Bool("paused", paused).Msg("setGroupPaused - successfully updated group")
+}
+
+func (ctl *controller) advanceGroupCohort(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	groupID := c.Params.ByName("group_id")
+
+	group, err := ctl.apiForRequest(c).AdvanceGroupCohort(groupID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(group); err != nil {
+			logger.Error().Err(err).Msgf("advanceGroupCohort - encoding group %v", group)
+		}
+		logger.Info().Str("groupID", groupID).Int("activeCohort", group.PolicyActiveCohort).Msg("advanceGroupCohort - successfully advanced cohort")
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	case api.ErrRolloutCohortsDisabled, api.ErrLastRolloutCohort:
+		httpError(c, http.StatusConflict)
+	default:
+		logger.Error().Err(err).Str("groupID", groupID).Msg("advanceGroupCohort - advancing cohort")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getGroup(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	group, err := ctl.apiForRequest(c).GetGroup(groupID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(group); err != nil {
+			logger.Error().Err(err).Msgf("getGroup - encoding group %v", group)
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroup - getting group")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getGroups(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
+	perPage, _ := strconv.ParseUint(c.Query("perpage"), 10, 64)
+
+	groups, err := ctl.apiForRequest(c).GetGroups(appID, page, perPage)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(groups); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getGroups - encoding groups")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getGroups - getting groups")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getGroupVersionCountTimeline(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+	duration := c.Query("duration")
+	versionCountTimeline, isCache, err := ctl.apiForRequest(c).GetGroupVersionCountTimeline(groupID, duration)
 	switch err {
 	case nil:
 		if isCache {
@@ -492,7 +931,7 @@ func (ctl *controller) getGroupVersionCountTimeline(c *gin.Context) {
 func (ctl *controller) getGroupStatusCountTimeline(c *gin.Context) {
 	groupID := c.Params.ByName("group_id")
 	duration := c.Query("duration")
-	statusCountTimeline, err := ctl.api.GetGroupStatusCountTimeline(groupID, duration)
+	statusCountTimeline, err := ctl.apiForRequest(c).GetGroupStatusCountTimeline(groupID, duration)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(statusCountTimeline); err != nil {
@@ -509,7 +948,7 @@ func (ctl *controller) getGroupStatusCountTimeline(c *gin.Context) {
 func (ctl *controller) getGroupInstancesStats(c *gin.Context) {
 	groupID := c.Params.ByName("group_id")
 	duration := c.Query("duration")
-	instancesStats, err := ctl.api.GetGroupInstancesStats(groupID, duration)
+	instancesStats, err := ctl.apiForRequest(c).GetGroupInstancesStats(groupID, duration)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(instancesStats); err != nil {
@@ -523,10 +962,145 @@ func (ctl *controller) getGroupInstancesStats(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getGroupRolloutProgress(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	activeWithin, err := activeWithinParam(c)
+	if err != nil {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	progress, err := ctl.apiForRequest(c).GetGroupRolloutProgress(groupID, activeWithin)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(progress); err != nil {
+			logger.Error().Err(err).Msgf("getGroupRolloutProgress - encoding group rollout progress %v", progress)
+		}
+	case sql.ErrNoRows, api.ErrNoPackageFound:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroupRolloutProgress - getting rollout progress")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getGroupUpdateDurations(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	stats, err := ctl.apiForRequest(c).GetUpdateDurationStats(groupID)
+	if err != nil {
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroupUpdateDurations - getting update duration stats")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(stats); err != nil {
+		logger.Error().Err(err).Msgf("getGroupUpdateDurations - encoding update duration stats %v", stats)
+	}
+}
+
+func (ctl *controller) getGroupEventAggregates(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if c.Query("from") != "" {
+		if from, err = time.Parse(time.RFC3339, c.Query("from")); err != nil {
+			httpError(c, http.StatusBadRequest)
+			return
+		}
+	}
+	if c.Query("to") != "" {
+		if to, err = time.Parse(time.RFC3339, c.Query("to")); err != nil {
+			httpError(c, http.StatusBadRequest)
+			return
+		}
+	}
+
+	aggregates, err := ctl.apiForRequest(c).GetDailyEventAggregates(groupID, from, to)
+	if err != nil {
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroupEventAggregates - getting event aggregates")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(aggregates); err != nil {
+		logger.Error().Err(err).Msgf("getGroupEventAggregates - encoding event aggregates %v", aggregates)
+	}
+}
+
+func (ctl *controller) getGroupErrorBreakdown(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	since := time.Now().Add(-24 * time.Hour)
+	if c.Query("since") != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, c.Query("since")); err != nil {
+			httpError(c, http.StatusBadRequest)
+			return
+		}
+	}
+
+	breakdown, err := ctl.apiForRequest(c).GetEventErrorBreakdown(groupID, since)
+	if err != nil {
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroupErrorBreakdown - getting error breakdown")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	type errorCodeCount struct {
+		ErrorCode   string `json:"error_code"`
+		Description string `json:"description"`
+		Count       int    `json:"count"`
+	}
+	errorCodes := make([]errorCodeCount, 0, len(breakdown))
+	for errorCode, count := range breakdown {
+		errorCodes = append(errorCodes, errorCodeCount{ErrorCode: errorCode, Description: api.DescribeErrorCode(errorCode), Count: count})
+	}
+	sort.Slice(errorCodes, func(i, j int) bool {
+		if errorCodes[i].Count != errorCodes[j].Count {
+			return errorCodes[i].Count > errorCodes[j].Count
+		}
+		return errorCodes[i].ErrorCode < errorCodes[j].ErrorCode
+	})
+	if err := json.NewEncoder(c.Writer).Encode(errorCodes); err != nil {
+		logger.Error().Err(err).Msgf("getGroupErrorBreakdown - encoding group error breakdown %v", errorCodes)
+	}
+}
+
+func (ctl *controller) getGroupFailingInstances(c *gin.Context) {
+	groupID := c.Params.ByName("group_id")
+
+	since := time.Now().Add(-24 * time.Hour)
+	if c.Query("since") != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, c.Query("since")); err != nil {
+			httpError(c, http.StatusBadRequest)
+			return
+		}
+	}
+
+	instances, err := ctl.apiForRequest(c).GetFailingInstances(groupID, since)
+	if err != nil {
+		logger.Error().Err(err).Str("groupID", groupID).Msg("getGroupFailingInstances - getting failing instances")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(instances); err != nil {
+		logger.Error().Err(err).Msgf("getGroupFailingInstances - encoding failing instances %v", instances)
+	}
+}
+
 func (ctl *controller) getGroupVersionBreakdown(c *gin.Context) {
 	groupID := c.Params.ByName("group_id")
 
-	versionBreakdown, err := ctl.api.GetGroupVersionBreakdown(groupID)
+	activeWithin, err := activeWithinParam(c)
+	if err != nil {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	versionBreakdown, err := ctl.apiForRequest(c).GetGroupVersionBreakdown(groupID, activeWithin)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(versionBreakdown); err != nil {
@@ -555,14 +1129,19 @@ func (ctl *controller) addChannel(c *gin.Context) {
 	}
 	channel.ApplicationID = c.Params.ByName("app_id")
 
-	_, err := ctl.api.AddChannel(channel)
-	if err != nil {
+	_, err := ctl.apiForRequest(c).AddChannel(channel)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Msgf("addChannel channel %v", channel)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	channel, err = ctl.api.GetChannel(channel.ID)
+	channel, err = ctl.apiForRequest(c).GetChannel(channel.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("channelID", channel.ID).Msg("addChannel")
 		httpError(c, http.StatusInternalServerError)
@@ -579,8 +1158,13 @@ func (ctl *controller) updateChannel(c *gin.Context) {
 	logger := loggerWithUsername(logger, c)
 
 	channelID := c.Params.ByName("channel_id")
-	oldChannel, err := ctl.api.GetChannel(channelID)
-	if err != nil {
+	oldChannel, err := ctl.apiForRequest(c).GetChannel(channelID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("channelID", channelID).Msg("updateChannel - getting old channel to update")
 		httpError(c, http.StatusInternalServerError)
 		return
@@ -595,14 +1179,16 @@ func (ctl *controller) updateChannel(c *gin.Context) {
 	channel.ID = channelID
 	channel.ApplicationID = c.Params.ByName("app_id")
 
-	err = ctl.api.UpdateChannel(channel)
-	if err != nil {
+	err = ctl.apiForRequest(c).UpdateChannel(channel)
+	switch err {
+	case nil:
+	default:
 		logger.Error().Err(err).Msgf("updateChannel - updating channel %+v", channel)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	channel, err = ctl.api.GetChannel(channel.ID)
+	channel, err = ctl.apiForRequest(c).GetChannel(channel.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("channelID", channel.ID).Msg("updateChannel - getting channel updated")
 		httpError(c, http.StatusInternalServerError)
@@ -620,14 +1206,19 @@ func (ctl *controller) deleteChannel(c *gin.Context) {
 
 	channelID := c.Params.ByName("channel_id")
 
-	channel, err := ctl.api.GetChannel(channelID)
-	if err != nil {
+	channel, err := ctl.apiForRequest(c).GetChannel(channelID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("channelID", channel.ID).Msg("updateChannel - getting channel to be deleted")
 		httpError(c, http.StatusInternalServerError)
 		return
 	}
 
-	err = ctl.api.DeleteChannel(channelID)
+	err = ctl.apiForRequest(c).DeleteChannel(channelID)
 	switch err {
 	case nil:
 		c.Status(http.StatusNoContent)
@@ -639,10 +1230,67 @@ func (ctl *controller) deleteChannel(c *gin.Context) {
 	logger.Info().Msgf("deleteChannel - successfully deleted channel %+v (PACKAGE: %+v)", channel, channel.Package)
 }
 
+func (ctl *controller) promoteChannelPackage(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	channelID := c.Params.ByName("channel_id")
+
+	var params struct {
+		ToChannelID string `json:"to_channel_id"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&params); err != nil {
+		logger.Error().Err(err).Msg("promoteChannelPackage - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	pkg, err := ctl.apiForRequest(c).PromotePackage(channelID, params.ToChannelID)
+	switch err {
+	case nil:
+		channel, err := ctl.apiForRequest(c).GetChannel(params.ToChannelID)
+		if err != nil {
+			logger.Error().Err(err).Str("channelID", params.ToChannelID).Msg("promoteChannelPackage - getting promoted channel")
+			httpError(c, http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(c.Writer).Encode(channel); err != nil {
+			logger.Error().Err(err).Str("channelID", channel.ID).Msg("promoteChannelPackage - encoding channel")
+		}
+		logger.Info().Msgf("promoteChannelPackage - successfully promoted package %+v from channel %s to channel %s", pkg, channelID, params.ToChannelID)
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	case api.ErrPromotionNotNewer:
+		httpError(c, http.StatusConflict)
+	default:
+		logger.Error().Err(err).Str("channelID", channelID).Str("toChannelID", params.ToChannelID).Msg("promoteChannelPackage")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) previewChannelPromotion(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	channelID := c.Params.ByName("channel_id")
+	packageID := c.Query("package_id")
+
+	impact, err := ctl.apiForRequest(c).PreviewPromotion(channelID, packageID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(impact); err != nil {
+			logger.Error().Err(err).Str("channelID", channelID).Msg("previewChannelPromotion - encoding promotion impact")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("channelID", channelID).Str("packageID", packageID).Msg("previewChannelPromotion")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 func (ctl *controller) getChannel(c *gin.Context) {
 	channelID := c.Params.ByName("channel_id")
 
-	channel, err := ctl.api.GetChannel(channelID)
+	channel, err := ctl.apiForRequest(c).GetChannel(channelID)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(channel); err != nil {
@@ -656,12 +1304,29 @@ func (ctl *controller) getChannel(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getChannelHistory(c *gin.Context) {
+	channelID := c.Params.ByName("channel_id")
+
+	history, err := ctl.apiForRequest(c).GetChannelHistory(channelID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(history); err != nil {
+			logger.Error().Err(err).Str("channelID", channelID).Msg("getChannelHistory - encoding channel history")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("channelID", channelID).Msg("getChannelHistory - getting channel history")
+		httpError(c, httpStatusForError(err))
+	}
+}
+
 func (ctl *controller) getChannels(c *gin.Context) {
 	appID := c.Params.ByName("app_id")
 	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
 	perPage, _ := strconv.ParseUint(c.Query("perpage"), 10, 64)
 
-	channels, err := ctl.api.GetChannels(appID, page, perPage)
+	channels, err := ctl.apiForRequest(c).GetChannels(appID, page, perPage)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(channels); err != nil {
@@ -675,6 +1340,23 @@ func (ctl *controller) getChannels(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getSuggestedChannelColor(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	color, err := ctl.apiForRequest(c).SuggestChannelColor(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(map[string]string{"color": color}); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getSuggestedChannelColor - encoding color")
+		}
+	case api.ErrNoChannelColorAvailable:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getSuggestedChannelColor - suggesting color")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 // ----------------------------------------------------------------------------
 // API: packages CRUD
 //
@@ -690,14 +1372,19 @@ func (ctl *controller) addPackage(c *gin.Context) {
 	}
 	pkg.ApplicationID = c.Params.ByName("app_id")
 
-	_, err := ctl.api.AddPackage(pkg)
-	if err != nil {
+	_, err := ctl.apiForRequest(c).AddPackage(pkg)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Msgf("addPackage - adding package %v", pkg)
-		httpError(c, http.StatusBadRequest)
+		httpError(c, httpStatusForError(err))
 		return
 	}
 
-	pkg, err = ctl.api.GetPackage(pkg.ID)
+	pkg, err = ctl.apiForRequest(c).GetPackage(pkg.ID)
 	if err != nil {
 		logger.Error().Err(err).Str("packageID", pkg.ID).Msg("addPackage - getting added package")
 		httpError(c, http.StatusInternalServerError)
@@ -710,36 +1397,84 @@ func (ctl *controller) addPackage(c *gin.Context) {
 	logger.Info().Msgf("addPackage - successfully added package %+v", pkg)
 }
 
-func (ctl *controller) updatePackage(c *gin.Context) {
+func (ctl *controller) addPackages(c *gin.Context) {
 	logger := loggerWithUsername(logger, c)
 
-	packageID := c.Params.ByName("package_id")
-
-	oldPkg, err := ctl.api.GetPackage(packageID)
-	if err != nil {
-		logger.Error().Err(err).Str("packageID", packageID).Msg("updatePackage - getting old package to update")
-		httpError(c, http.StatusInternalServerError)
+	var pkgs []*api.Package
+	if err := json.NewDecoder(c.Request.Body).Decode(&pkgs); err != nil {
+		logger.Error().Err(err).Msg("addPackages - decoding payload")
+		httpError(c, http.StatusBadRequest)
 		return
 	}
+	for _, pkg := range pkgs {
+		pkg.ApplicationID = c.Params.ByName("app_id")
+	}
 
-	pkg := &api.Package{}
-	if err := json.NewDecoder(c.Request.Body).Decode(pkg); err != nil {
-		logger.Error().Err(err).Msg("updatePackage - decoding payload")
-		httpError(c, http.StatusBadRequest)
+	pkgs, err := ctl.apiForRequest(c).AddPackages(pkgs)
+	if batchErr, ok := err.(*api.PackagesBatchError); ok {
+		logger.Error().Err(err).Msg("addPackages - adding packages")
+		errs := make([]*string, len(batchErr.Errors))
+		for i, err := range batchErr.Errors {
+			if err != nil {
+				msg := err.Error()
+				errs[i] = &msg
+			}
+		}
+		c.Status(http.StatusBadRequest)
+		if err := json.NewEncoder(c.Writer).Encode(map[string][]*string{"errors": errs}); err != nil {
+			logger.Error().Err(err).Msg("addPackages - encoding packages errors")
+		}
 		return
 	}
-	pkg.ID = packageID
-	pkg.ApplicationID = c.Params.ByName("app_id")
-
-	err = ctl.api.UpdatePackage(pkg)
 	if err != nil {
-		logger.Error().Err(err).Msgf("updatePackage - updating package %+v", pkg)
+		logger.Error().Err(err).Msg("addPackages - adding packages")
 		httpError(c, http.StatusBadRequest)
 		return
 	}
+	if err := json.NewEncoder(c.Writer).Encode(pkgs); err != nil {
+		logger.Error().Err(err).Msg("addPackages - encoding packages")
+	}
 
-	pkg, err = ctl.api.GetPackage(pkg.ID)
-	if err != nil {
+	logger.Info().Msgf("addPackages - successfully added %d packages", len(pkgs))
+}
+
+func (ctl *controller) updatePackage(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	packageID := c.Params.ByName("package_id")
+
+	oldPkg, err := ctl.apiForRequest(c).GetPackage(packageID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("packageID", packageID).Msg("updatePackage - getting old package to update")
+		httpError(c, http.StatusInternalServerError)
+		return
+	}
+
+	pkg := &api.Package{}
+	if err := json.NewDecoder(c.Request.Body).Decode(pkg); err != nil {
+		logger.Error().Err(err).Msg("updatePackage - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	pkg.ID = packageID
+	pkg.ApplicationID = c.Params.ByName("app_id")
+
+	err = ctl.apiForRequest(c).UpdatePackage(pkg)
+	switch err {
+	case nil:
+	default:
+		logger.Error().Err(err).Msgf("updatePackage - updating package %+v", pkg)
+		httpError(c, httpStatusForError(err))
+		return
+	}
+
+	pkg, err = ctl.apiForRequest(c).GetPackage(pkg.ID)
+	if err != nil {
 		logger.Error().Err(err).Str("packageID", pkg.ID).Msg("updatePackage - getting updated package")
 		httpError(c, http.StatusInternalServerError)
 		return
@@ -756,20 +1491,35 @@ func (ctl *controller) deletePackage(c *gin.Context) {
 
 	packageID := c.Params.ByName("package_id")
 
-	pkg, err := ctl.api.GetPackage(packageID)
-	if err != nil {
+	pkg, err := ctl.apiForRequest(c).GetPackage(packageID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
 		logger.Error().Err(err).Str("packageID", pkg.ID).Msg("addPackage - getting package to delete")
 		httpError(c, http.StatusInternalServerError)
 		return
 	}
 
-	err = ctl.api.DeletePackage(packageID)
+	// Packages served by some group are only deleted when forced.
+	deletePackage := ctl.apiForRequest(c).DeletePackage
+	if c.Request.URL.Query().Get("force") == "true" {
+		deletePackage = ctl.apiForRequest(c).ForceDeletePackage
+	}
+	err = deletePackage(packageID)
 	switch err {
 	case nil:
 		c.Status(http.StatusNoContent)
+	case api.ErrPackageInUse:
+		logger.Info().Str("packageID", packageID).Msg("deletePackage - package in use by some groups")
+		httpError(c, http.StatusConflict)
+		return
 	default:
 		logger.Error().Err(err).Str("packageID", packageID).Msg("deletePackage")
 		httpError(c, http.StatusBadRequest)
+		return
 	}
 
 	logger.Info().Msgf("deletePackage - successfully deleted package %+v", pkg)
@@ -778,7 +1528,7 @@ func (ctl *controller) deletePackage(c *gin.Context) {
 func (ctl *controller) getPackage(c *gin.Context) {
 	packageID := c.Params.ByName("package_id")
 
-	pkg, err := ctl.api.GetPackage(packageID)
+	pkg, err := ctl.apiForRequest(c).GetPackage(packageID)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(pkg); err != nil {
@@ -797,7 +1547,7 @@ func (ctl *controller) getPackages(c *gin.Context) {
 	page, _ := strconv.ParseUint(c.Query("page"), 10, 64)
 	perPage, _ := strconv.ParseUint(c.Query("perpage"), 10, 64)
 
-	pkgs, err := ctl.api.GetPackages(appID, page, perPage)
+	pkgs, err := ctl.apiForRequest(c).GetPackages(appID, page, perPage)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(pkgs); err != nil {
@@ -811,6 +1561,162 @@ func (ctl *controller) getPackages(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) pruneOldPackages(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	keep, err := strconv.Atoi(c.Query("keep"))
+	if err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msg("pruneOldPackages - parsing keep")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	pkgs, err := ctl.apiForRequest(c).PruneOldPackages(appID, keep)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(pkgs); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("pruneOldPackages - encoding packages")
+		}
+		logger.Info().Str("appID", appID).Msgf("pruneOldPackages - successfully removed %d packages", len(pkgs))
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("pruneOldPackages - pruning packages")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getGroupsUsingPackage(c *gin.Context) {
+	packageID := c.Params.ByName("package_id")
+
+	groups, err := ctl.apiForRequest(c).GetGroupsUsingPackage(packageID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(groups); err != nil {
+			logger.Error().Err(err).Str("packageID", packageID).Msg("getGroupsUsingPackage - encoding groups")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("packageID", packageID).Msg("getGroupsUsingPackage - getting groups")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) addPackageDelta(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	delta := &api.PackageDelta{}
+	if err := json.NewDecoder(c.Request.Body).Decode(delta); err != nil {
+		logger.Error().Err(err).Msg("addPackageDelta - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	delta.PackageID = c.Params.ByName("package_id")
+
+	_, err := ctl.apiForRequest(c).AddPackageDelta(delta)
+	if err != nil {
+		logger.Error().Err(err).Msgf("addPackageDelta - adding package delta %+v", delta)
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(delta); err != nil {
+		logger.Error().Err(err).Msgf("addPackageDelta - encoding package delta %+v", delta)
+	}
+
+	logger.Info().Msgf("addPackageDelta - successfully added package delta %+v", delta)
+}
+
+func (ctl *controller) deletePackageDelta(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	deltaID := c.Params.ByName("delta_id")
+
+	err := ctl.apiForRequest(c).DeletePackageDelta(deltaID)
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+		logger.Info().Msgf("deletePackageDelta - successfully deleted package delta %s", deltaID)
+	case api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("deltaID", deltaID).Msg("deletePackageDelta")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getPackageDeltas(c *gin.Context) {
+	packageID := c.Params.ByName("package_id")
+
+	deltas, err := ctl.apiForRequest(c).GetPackageDeltas(packageID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(deltas); err != nil {
+			logger.Error().Err(err).Str("packageID", packageID).Msg("getPackageDeltas - encoding package deltas")
+		}
+	default:
+		logger.Error().Err(err).Str("packageID", packageID).Msg("getPackageDeltas - getting package deltas")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) addPackageArtifact(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	artifact := &api.PackageArtifact{}
+	if err := json.NewDecoder(c.Request.Body).Decode(artifact); err != nil {
+		logger.Error().Err(err).Msg("addPackageArtifact - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	artifact.PackageID = c.Params.ByName("package_id")
+
+	_, err := ctl.apiForRequest(c).AddPackageArtifact(artifact)
+	if err != nil {
+		logger.Error().Err(err).Msgf("addPackageArtifact - adding package artifact %+v", artifact)
+		httpError(c, httpStatusForError(err))
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(artifact); err != nil {
+		logger.Error().Err(err).Msgf("addPackageArtifact - encoding package artifact %+v", artifact)
+	}
+
+	logger.Info().Msgf("addPackageArtifact - successfully added package artifact %+v", artifact)
+}
+
+func (ctl *controller) deletePackageArtifact(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	packageID := c.Params.ByName("package_id")
+	artifactID := c.Params.ByName("artifact_id")
+
+	err := ctl.apiForRequest(c).DeletePackageArtifact(packageID, artifactID)
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+		logger.Info().Msgf("deletePackageArtifact - successfully deleted package artifact %s", artifactID)
+	case api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("artifactID", artifactID).Msg("deletePackageArtifact")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getPackageArtifacts(c *gin.Context) {
+	packageID := c.Params.ByName("package_id")
+
+	artifacts, err := ctl.apiForRequest(c).GetPackageArtifacts(packageID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(artifacts); err != nil {
+			logger.Error().Err(err).Str("packageID", packageID).Msg("getPackageArtifacts - encoding package artifacts")
+		}
+	default:
+		logger.Error().Err(err).Str("packageID", packageID).Msg("getPackageArtifacts - getting package artifacts")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 // ----------------------------------------------------------------------------
 // API: instances
 //
@@ -821,7 +1727,7 @@ func (ctl *controller) getInstanceStatusHistory(c *gin.Context) {
 	instanceID := c.Params.ByName("instance_id")
 	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
 
-	instanceStatusHistory, err := ctl.api.GetInstanceStatusHistory(instanceID, appID, groupID, limit)
+	instanceStatusHistory, err := ctl.apiForRequest(c).GetInstanceStatusHistory(instanceID, appID, groupID, limit)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(instanceStatusHistory); err != nil {
@@ -835,20 +1741,85 @@ func (ctl *controller) getInstanceStatusHistory(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getInstanceStatsByArch(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	activeWithin, err := activeWithinParam(c)
+	if err != nil {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	stats, err := ctl.apiForRequest(c).GetInstanceStatsByArch(appID, activeWithin)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(stats); err != nil {
+			logger.Error().Err(err).Msgf("getInstanceStatsByArch - encoding stats %v", stats)
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getInstanceStatsByArch - getting instances stats")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getInstanceStatsByRegion(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	stats, err := ctl.apiForRequest(c).GetInstanceStatsByRegion(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(stats); err != nil {
+			logger.Error().Err(err).Msgf("getInstanceStatsByRegion - encoding stats %v", stats)
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getInstanceStatsByRegion - getting instances stats")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getInstanceTimeline(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	events, err := ctl.apiForRequest(c).GetInstanceTimeline(instanceID, appID)
+	switch err {
+	case nil:
+		for _, event := range events {
+			event.ResultDescription = omaha.DescribeEventResult(strconv.Itoa(event.Type), strconv.Itoa(event.Result), event.ErrorCode.String)
+		}
+		if err := json.NewEncoder(c.Writer).Encode(events); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("getInstanceTimeline - encoding timeline")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("getInstanceTimeline - getting timeline")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 func (ctl *controller) getInstances(c *gin.Context) {
 	appID := c.Params.ByName("app_id")
 	groupID := c.Params.ByName("group_id")
 
+	if c.Query("search") != "" || c.Query("ip_range") != "" || c.Query("min_version") != "" || c.Query("max_version") != "" || c.Query("last_seen") != "" {
+		ctl.searchInstances(c)
+		return
+	}
+
 	p := api.InstancesQueryParams{
 		ApplicationID: appID,
 		GroupID:       groupID,
 		Version:       c.Query("version"),
+		SortBy:        c.Query("sort_by"),
 	}
 	p.Status, _ = strconv.Atoi(c.Query("status"))
 	p.Page, _ = strconv.ParseUint(c.Query("page"), 10, 64)
 	p.PerPage, _ = strconv.ParseUint(c.Query("perpage"), 10, 64)
+	p.Limit, _ = strconv.ParseUint(c.Query("limit"), 10, 64)
+	p.Offset, _ = strconv.ParseUint(c.Query("offset"), 10, 64)
 	duration := c.Query("duration")
-	result, err := ctl.api.GetInstances(p, duration)
+	result, err := ctl.apiForRequest(c).GetInstances(p, duration)
 	if err == nil {
 		if err := json.NewEncoder(c.Writer).Encode(result); err != nil {
 			logger.Error().Err(err).Msgf("getInstances - encoding instances params %v", p)
@@ -859,6 +1830,264 @@ func (ctl *controller) getInstances(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getInstancesReport(c *gin.Context) {
+	p := api.InstancesQueryParams{
+		ApplicationID: c.Params.ByName("app_id"),
+		GroupID:       c.Params.ByName("group_id"),
+		Version:       c.Query("version"),
+		SortBy:        c.Query("sort_by"),
+	}
+	p.Status, _ = strconv.Atoi(c.Query("status"))
+	duration := c.DefaultQuery("duration", "1d")
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="instances.csv"`)
+	err := ctl.apiForRequest(c).WriteInstancesReport(c.Writer, p, duration)
+	switch {
+	case err == nil:
+	case c.Writer.Written():
+		// The report is partially sent already, it can only be cut short.
+		logger.Error().Err(err).Msgf("getInstancesReport - writing instances report params %v", p)
+	case err == sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Msgf("getInstancesReport - getting instances report params %v", p)
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) searchInstances(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	filter := api.InstanceSearchFilter{
+		GroupID:    c.Params.ByName("group_id"),
+		Text:       c.Query("search"),
+		IPRange:    c.Query("ip_range"),
+		MinVersion: c.Query("min_version"),
+		MaxVersion: c.Query("max_version"),
+	}
+	if lastSeen := c.Query("last_seen"); lastSeen != "" {
+		var err error
+		if filter.LastSeen, err = time.ParseDuration(lastSeen); err != nil {
+			logger.Error().Err(err).Str("lastSeen", lastSeen).Msg("searchInstances - parsing last seen duration")
+			httpError(c, http.StatusBadRequest)
+			return
+		}
+	}
+	filter.Page, _ = strconv.ParseUint(c.Query("page"), 10, 64)
+	filter.PerPage, _ = strconv.ParseUint(c.Query("perpage"), 10, 64)
+
+	instances, total, err := ctl.apiForRequest(c).SearchInstances(appID, filter)
+	if err != nil {
+		logger.Error().Err(err).Msgf("searchInstances - searching instances filter %v", filter)
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	result := api.InstancesWithTotal{
+		TotalInstances: uint64(total),
+		Instances:      instances,
+	}
+	if err := json.NewEncoder(c.Writer).Encode(result); err != nil {
+		logger.Error().Err(err).Msgf("searchInstances - encoding instances filter %v", filter)
+	}
+}
+
+func (ctl *controller) getInstancesByLabel(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+	key, value := c.Query("key"), c.Query("value")
+	if key == "" || value == "" {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	instances, err := ctl.apiForRequest(c).GetInstancesByLabel(appID, key, value)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(instances); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getInstancesByLabel - encoding instances")
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Str("key", key).Str("value", value).Msg("getInstancesByLabel - getting instances")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) getQuarantinedInstances(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	instances, err := ctl.apiForRequest(c).ListQuarantinedInstances(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(instances); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getQuarantinedInstances - encoding instances")
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getQuarantinedInstances - getting instances")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) acknowledgeQuarantinedInstance(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	err := ctl.apiForRequest(c).AcknowledgeQuarantinedInstance(instanceID, appID)
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("acknowledgeQuarantinedInstance - acknowledging instance")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+// getStuckInstances returns the instances that have been running the same
+// version for longer than the threshold query parameter, a duration like
+// 168h, despite being granted an update.
+func (ctl *controller) getStuckInstances(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	threshold, err := time.ParseDuration(c.Query("threshold"))
+	if err != nil {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	instances, err := ctl.apiForRequest(c).GetStuckInstances(appID, threshold)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(instances); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getStuckInstances - encoding instances")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getStuckInstances - getting instances")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+// streamEvents streams the events registered for an application, optionally
+// restricted to one of its groups, as Server-Sent Events until the client
+// disconnects. Clients not keeping up with the events are disconnected.
+func (ctl *controller) streamEvents(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+	groupID := c.Query("group")
+
+	if _, err := ctl.apiForRequest(c).GetApp(appID); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Msg("streamEvents - getting app")
+		httpError(c, http.StatusNotFound)
+		return
+	}
+
+	sub := ctl.apiForRequest(c).SubscribeEvents(appID, groupID)
+	defer sub.Unsubscribe()
+
+	heartbeat := time.NewTicker(EventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				logger.Warn().Str("appID", appID).Str("groupID", groupID).Msg("streamEvents - subscriber too slow, disconnecting")
+				return false
+			}
+			// The event is shared with the other subscribers, describe
+			// a copy of it.
+			streamedEvent := *event
+			streamedEvent.ResultDescription = omaha.DescribeEventResult(strconv.Itoa(event.Type), strconv.Itoa(event.Result), event.ErrorCode)
+			c.SSEvent("event", streamedEvent)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", "")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (ctl *controller) getInstancePackageOverride(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	pkg, err := ctl.apiForRequest(c).GetInstancePackageOverride(instanceID, appID)
+	switch {
+	case err != nil:
+		logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("getInstancePackageOverride - getting override")
+		httpError(c, http.StatusBadRequest)
+	case pkg == nil:
+		httpError(c, http.StatusNotFound)
+	default:
+		if err := json.NewEncoder(c.Writer).Encode(pkg); err != nil {
+			logger.Error().Err(err).Str("instanceID", instanceID).Msg("getInstancePackageOverride - encoding package")
+		}
+	}
+}
+
+func (ctl *controller) setInstancePackageOverride(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	var override struct {
+		PackageID string `json:"package_id"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&override); err != nil {
+		logger.Error().Err(err).Msg("setInstancePackageOverride - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	pkg, err := ctl.apiForRequest(c).GetPackage(override.PackageID)
+	if err != nil || pkg.ApplicationID != appID {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	if err := ctl.apiForRequest(c).SetInstancePackageOverride(instanceID, pkg.ID); err != nil {
+		logger.Error().Err(err).Str("instanceID", instanceID).Str("packageID", pkg.ID).Msg("setInstancePackageOverride - setting override")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	logger.Info().Str("instanceID", instanceID).Str("packageID", pkg.ID).Msg("setInstancePackageOverride - successfully set override")
+	c.Status(http.StatusNoContent)
+}
+
+func (ctl *controller) clearInstancePackageOverride(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	if err := ctl.apiForRequest(c).ClearInstancePackageOverride(instanceID, appID); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("clearInstancePackageOverride - clearing override")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ctl *controller) deregisterInstance(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	appID := c.Params.ByName("app_id")
+	instanceID := c.Params.ByName("instance_id")
+
+	if err := ctl.apiForRequest(c).DeregisterInstance(instanceID, appID); err != nil {
+		logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("deregisterInstance - deregistering instance")
+		httpError(c, httpStatusForError(err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (ctl *controller) getInstancesCount(c *gin.Context) {
 	appID := c.Params.ByName("app_id")
 	groupID := c.Params.ByName("group_id")
@@ -868,7 +2097,7 @@ func (ctl *controller) getInstancesCount(c *gin.Context) {
 		GroupID:       groupID,
 	}
 	duration := c.Query("duration")
-	result, err := ctl.api.GetInstancesCount(p, duration)
+	result, err := ctl.apiForRequest(c).GetInstancesCount(p, duration)
 	if err == nil {
 		if err := json.NewEncoder(c.Writer).Encode(result); err != nil {
 			logger.Error().Err(err).Msgf("getInstances - encoding instances params %v", p)
@@ -882,7 +2111,7 @@ func (ctl *controller) getInstancesCount(c *gin.Context) {
 func (ctl *controller) getInstance(c *gin.Context) {
 	appID := c.Params.ByName("app_id")
 	instanceID := c.Params.ByName("instance_id")
-	result, err := ctl.api.GetInstance(instanceID, appID)
+	result, err := ctl.apiForRequest(c).GetInstance(instanceID, appID)
 	if err == nil {
 		if err := json.NewEncoder(c.Writer).Encode(result); err != nil {
 			logger.Error().Err(err).Str("appID", appID).Str("instanceID", instanceID).Msg("getInstance - encoding instance")
@@ -905,7 +2134,7 @@ func (ctl *controller) updateInstance(c *gin.Context) {
 		return
 	}
 
-	instance, err := ctl.api.UpdateInstance(instanceID, params.Alias)
+	instance, err := ctl.apiForRequest(c).UpdateInstance(instanceID, params.Alias)
 	if err != nil {
 		logger.Error().Err(err).Str("instance", instanceID).Msgf("updateInstance - updating params %s", params)
 		httpError(c, http.StatusBadRequest)
@@ -924,6 +2153,139 @@ func (ctl *controller) updateInstance(c *gin.Context) {
 	logger.Info().Msgf("updateInstance - successfully updated instance %q alias to %q", instanceID, instance.Alias)
 }
 
+func (ctl *controller) getInstanceNote(c *gin.Context) {
+	instanceID := c.Params.ByName("instance_id")
+
+	note, err := ctl.apiForRequest(c).GetInstanceNote(instanceID)
+	switch err {
+	case nil:
+		params := struct {
+			Note *string `json:"note"`
+		}{Note: note.Ptr()}
+		if err := json.NewEncoder(c.Writer).Encode(params); err != nil {
+			logger.Error().Err(err).Str("instanceID", instanceID).Msg("getInstanceNote - encoding note")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("instanceID", instanceID).Msg("getInstanceNote - getting note")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
+func (ctl *controller) setInstanceNote(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	instanceID := c.Params.ByName("instance_id")
+	params := struct {
+		Note string `json:"note"`
+	}{}
+
+	if err := json.NewDecoder(c.Request.Body).Decode(&params); err != nil {
+		logger.Error().Err(err).Msg("setInstanceNote - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	switch err := ctl.apiForRequest(c).SetInstanceNote(instanceID, params.Note); err {
+	case nil:
+		logger.Info().Str("instanceID", instanceID).Msg("setInstanceNote - successfully set note")
+		c.Status(http.StatusNoContent)
+	default:
+		logger.Error().Err(err).Str("instanceID", instanceID).Msg("setInstanceNote - setting note")
+		httpError(c, httpStatusForError(err))
+	}
+}
+
+func (ctl *controller) getInstanceStatus(c *gin.Context) {
+	instanceID := c.Params.ByName("instance_id")
+
+	status, err := ctl.apiForRequest(c).GetInstanceStatus(instanceID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(map[string]api.InstanceUpdateStatus{"status": status}); err != nil {
+			logger.Error().Err(err).Str("instanceID", instanceID).Msg("getInstanceStatus - encoding status")
+		}
+	case sql.ErrNoRows:
+		httpError(c, http.StatusNotFound)
+	default:
+		logger.Error().Err(err).Str("instanceID", instanceID).Msg("getInstanceStatus - getting status")
+		httpError(c, httpStatusForError(err))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// API: webhooks
+//
+
+// webhookWithSecret is the representation of a webhook in the requests adding
+// it and their responses, the only place its secret is exposed.
+type webhookWithSecret struct {
+	*api.Webhook
+	Secret string `json:"secret"`
+}
+
+func (ctl *controller) addWebhook(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	req := webhookWithSecret{Webhook: &api.Webhook{}}
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		logger.Error().Err(err).Msg("addWebhook - decoding payload")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	req.Webhook.ApplicationID = c.Params.ByName("app_id")
+	req.Webhook.Secret = req.Secret
+
+	webhook, err := ctl.apiForRequest(c).AddWebhook(req.Webhook)
+	if err != nil {
+		logger.Error().Err(err).Str("appID", c.Params.ByName("app_id")).Msg("addWebhook")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(webhookWithSecret{Webhook: webhook, Secret: webhook.Secret}); err != nil {
+		logger.Error().Err(err).Str("webhookID", webhook.ID).Msg("addWebhook - encoding webhook")
+	}
+
+	logger.Info().Str("webhookID", webhook.ID).Str("url", webhook.URL).Msg("addWebhook - successfully added webhook")
+}
+
+func (ctl *controller) deleteWebhook(c *gin.Context) {
+	logger := loggerWithUsername(logger, c)
+
+	webhookID := c.Params.ByName("webhook_id")
+
+	err := ctl.apiForRequest(c).DeleteWebhook(webhookID)
+	switch err {
+	case nil:
+		c.Status(http.StatusNoContent)
+	case api.ErrNoRowsAffected:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Str("webhookID", webhookID).Msg("deleteWebhook")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	logger.Info().Str("webhookID", webhookID).Msg("deleteWebhook - successfully deleted webhook")
+}
+
+func (ctl *controller) getWebhooks(c *gin.Context) {
+	appID := c.Params.ByName("app_id")
+
+	webhooks, err := ctl.apiForRequest(c).GetWebhooks(appID)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(webhooks); err != nil {
+			logger.Error().Err(err).Str("appID", appID).Msg("getWebhooks - encoding webhooks")
+		}
+	default:
+		logger.Error().Err(err).Str("appID", appID).Msg("getWebhooks - getting webhooks")
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 // ----------------------------------------------------------------------------
 // API: activity
 //
@@ -944,7 +2306,7 @@ func (ctl *controller) getActivity(c *gin.Context) {
 	p.Page, _ = strconv.ParseUint(c.Query("page"), 10, 64)
 	p.PerPage, _ = strconv.ParseUint(c.Query("perpage"), 10, 64)
 
-	activityEntries, err := ctl.api.GetActivity(teamID, p)
+	activityEntries, err := ctl.apiForRequest(c).GetActivity(teamID, p)
 	switch err {
 	case nil:
 		if err := json.NewEncoder(c.Writer).Encode(activityEntries); err != nil {
@@ -958,16 +2320,162 @@ func (ctl *controller) getActivity(c *gin.Context) {
 	}
 }
 
+func (ctl *controller) getAuditLog(c *gin.Context) {
+	p := api.AuditLogQueryParams{
+		TeamID:     c.GetString("team_id"),
+		Actor:      c.Query("actor"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+	}
+	p.Start, _ = time.Parse(time.RFC3339, c.Query("start"))
+	p.End, _ = time.Parse(time.RFC3339, c.Query("end"))
+	p.Page, _ = strconv.ParseUint(c.Query("page"), 10, 64)
+	p.PerPage, _ = strconv.ParseUint(c.Query("perpage"), 10, 64)
+
+	auditEntries, err := ctl.apiForRequest(c).GetAuditLog(p)
+	switch err {
+	case nil:
+		if err := json.NewEncoder(c.Writer).Encode(auditEntries); err != nil {
+			logger.Error().Err(err).Msgf("getAuditLog - encoding audit log entries params %v", p)
+		}
+	default:
+		logger.Error().Err(err).Msgf("getAuditLog params %v", p)
+		httpError(c, http.StatusBadRequest)
+	}
+}
+
 // ----------------------------------------------------------------------------
 // OMAHA server
 //
 
 func (ctl *controller) processOmahaRequest(c *gin.Context) {
-	c.Writer.Header().Set("Content-Type", "text/xml")
+	ctl.serveOmahaRequest(c, ctl.omahaHandler.HandleEncoding, ctl.omahaHandler.HandleWithReasons, "process omaha request")
+}
+
+func (ctl *controller) processOmahaDryRunRequest(c *gin.Context) {
+	ctl.serveOmahaRequest(c, ctl.omahaHandler.HandleDryRunEncoding, ctl.omahaHandler.HandleDryRunWithReasons, "process omaha dry-run request")
+}
+
+// getRecordedOmahaRequests returns the raw Omaha requests recorded for the
+// applications of the user's team, oldest first. It fails with a not found
+// status when recording is disabled.
+func (ctl *controller) getRecordedOmahaRequests(c *gin.Context) {
+	if ctl.omahaRecorder == nil {
+		httpError(c, http.StatusNotFound)
+		return
+	}
+	if err := json.NewEncoder(c.Writer).Encode(ctl.omahaRecorder.RequestsFor(ctl.apiForRequest(c))); err != nil {
+		logger.Error().Err(err).Msg("getRecordedOmahaRequests - encoding requests")
+	}
+}
+
+// replayOmahaRequest replays a recorded Omaha request of the applications of
+// the user's team in dry-run mode, responding with the Omaha response and the
+// reasons of the update decisions in the OmahaUpdateDecisionHeader, like a
+// dry-run request with the OmahaDebugHeader set.
+func (ctl *controller) replayOmahaRequest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Params.ByName("request_id"), 10, 64)
+	if err != nil {
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	if ctl.omahaRecorder == nil {
+		httpError(c, http.StatusNotFound)
+		return
+	}
+	req, err := ctl.omahaRecorder.GetFor(ctl.apiForRequest(c), id)
+	if err != nil {
+		httpError(c, http.StatusNotFound)
+		return
+	}
+
+	var resp bytes.Buffer
+	reasons, err := ctl.omahaHandler.ReplayRequest(id, &resp)
+	switch err {
+	case nil:
+	case omaha.ErrRecordedRequestNotFound:
+		httpError(c, http.StatusNotFound)
+		return
+	default:
+		logger.Error().Err(err).Uint64("requestID", id).Msg("replayOmahaRequest - replaying request")
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", req.Encoding.ContentType())
+	c.Writer.Header().Set(OmahaUpdateDecisionHeader, formatUpdateDecisionReasons(reasons))
+	if _, err := resp.WriteTo(c.Writer); err != nil {
+		logger.Error().Err(err).Uint64("requestID", id).Msg("replayOmahaRequest - writing response")
+	}
+}
+
+type omahaHandleFunc func(rawReq io.Reader, respWriter io.Writer, ip string, encoding omaha.Encoding) error
+
+type omahaHandleWithReasonsFunc func(rawReq io.Reader, respWriter io.Writer, ip string, encoding omaha.Encoding) (map[omaha.UpdateDecisionKey]api.UpdateDecisionReason, error)
+
+// serveOmahaRequest serves an Omaha request with the handler functions
+// provided. Requests setting the OmahaDebugHeader get the reasons of the
+// update decisions made for their applications in the
+// OmahaUpdateDecisionHeader of the response, as machineID/appID=reason pairs.
+// Request bodies compressed with gzip or deflate are decompressed, and
+// responses are compressed when the request accepts it.
+func (ctl *controller) serveOmahaRequest(c *gin.Context, handle omahaHandleFunc, handleWithReasons omahaHandleWithReasonsFunc, errMsg string) {
+	encoding := omaha.EncodingFromContentType(c.ContentType())
+	c.Writer.Header().Set("Content-Type", encoding.ContentType())
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, UpdateMaxRequestSize)
-	if err := ctl.omahaHandler.Handle(c.Request.Body, c.Writer, getRequestIP(c.Request)); err != nil {
-		logger.Error().Err(err).Msg("process omaha request")
-		if uerr := errors.Unwrap(err); uerr != nil && uerr.Error() == "http: request body too large" {
+
+	reqCompression, err := omaha.CompressionFromContentEncoding(c.GetHeader("Content-Encoding"))
+	if err != nil {
+		logger.Warn().Err(err).Str("contentEncoding", c.GetHeader("Content-Encoding")).Msg(errMsg)
+		httpError(c, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := omaha.DecompressRequest(c.Request.Body, reqCompression)
+	if err != nil {
+		logger.Warn().Err(err).Msg(errMsg)
+		httpError(c, http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+	// The limit applies to the decompressed body too, so small compressed
+	// bodies can't expand into huge requests.
+	rawReq := http.MaxBytesReader(c.Writer, body, UpdateMaxRequestSize)
+
+	respCompression := omaha.CompressionFromAcceptEncoding(c.GetHeader("Accept-Encoding"))
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	if respCompression != omaha.CompressionNone {
+		c.Writer.Header().Set("Content-Encoding", respCompression.ContentEncoding())
+	}
+	respWriter := omaha.CompressResponse(c.Writer, respCompression)
+
+	if c.GetHeader(OmahaDebugHeader) == "" && !ctl.api.SignsResponses() {
+		err = handle(rawReq, respWriter, getRequestIP(c.Request), encoding)
+	} else {
+		// The headers have to be set before the response is written.
+		var resp bytes.Buffer
+		if c.GetHeader(OmahaDebugHeader) == "" {
+			err = handle(rawReq, &resp, getRequestIP(c.Request), encoding)
+		} else {
+			var reasons map[omaha.UpdateDecisionKey]api.UpdateDecisionReason
+			reasons, err = handleWithReasons(rawReq, &resp, getRequestIP(c.Request), encoding)
+			c.Writer.Header().Set(OmahaUpdateDecisionHeader, formatUpdateDecisionReasons(reasons))
+		}
+		if err == nil {
+			// The signature is made over the uncompressed response.
+			if signature, ok := ctl.api.SignResponse(resp.Bytes()); ok {
+				c.Writer.Header().Set(OmahaSignatureHeader, signature)
+			}
+			_, err = resp.WriteTo(respWriter)
+		}
+	}
+	if err == nil {
+		err = respWriter.Close()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg(errMsg)
+		c.Writer.Header().Del("Content-Encoding")
+		uerr := errors.Unwrap(err)
+		if (uerr != nil && uerr.Error() == "http: request body too large") || errors.Is(err, omaha.ErrMalformedCompressedRequest) {
 			httpError(c, http.StatusBadRequest)
 		}
 	}
@@ -977,6 +2485,18 @@ func (ctl *controller) processOmahaRequest(c *gin.Context) {
 // Helpers
 //
 
+// formatUpdateDecisionReasons formats the reasons of the update decisions
+// provided as the value of the OmahaUpdateDecisionHeader, sorted
+// machineID/appID=reason pairs separated by commas.
+func formatUpdateDecisionReasons(reasons map[omaha.UpdateDecisionKey]api.UpdateDecisionReason) string {
+	pairs := make([]string, 0, len(reasons))
+	for key, reason := range reasons {
+		pairs = append(pairs, key.MachineID+"/"+key.AppID+"="+string(reason))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
 func getRequestIP(r *http.Request) string {
 	ips := strings.Split(r.Header.Get("X-FORWARDED-FOR"), ",")
 	if ips[0] != "" && net.ParseIP(strings.TrimSpace(ips[0])) != nil {
@@ -990,6 +2510,29 @@ func getRequestIP(r *http.Request) string {
 // Config
 //
 
+// healthz replies with 200 when the database is reachable and all the
+// migrations have been applied, and with 503 otherwise, so it can be used as
+// a readiness probe.
+func (ctl *controller) healthz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), HealthCheckTimeout)
+	defer cancel()
+
+	status := struct {
+		Status           string `json:"status"`
+		MigrationVersion string `json:"migration_version"`
+	}{Status: "ok"}
+	var err error
+	status.MigrationVersion, err = ctl.api.Healthz(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("healthz - checking database")
+		status.Status = "unavailable"
+		c.Status(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(c.Writer).Encode(status); err != nil {
+		logger.Error().Err(err).Msg("healthz - encoding status")
+	}
+}
+
 func (ctl *controller) getConfig(c *gin.Context) {
 	if err := json.NewEncoder(c.Writer).Encode(ctl.clientConfig); err != nil {
 		logger.Error().Err(err).Msg("getConfig - encoding config")
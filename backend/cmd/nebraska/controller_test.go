@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -139,3 +142,123 @@ func TestOmahaRequestSizeLimitation(t *testing.T) {
 		assert.Equal(t, tc.status, w.Code)
 	}
 }
+
+func TestOmahaRequestCompression(t *testing.T) {
+	a, err := api.NewForTest(api.OptionInitDB, api.OptionDisableUpdatesOnFailedRollout)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	defer a.Close()
+
+	ctl, err := newController(&controllerConfig{noopAuthConfig: &auth.NoopAuthConfig{}, api: a})
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+
+	xmlRequest := `<?xml version="1.0" encoding="UTF-8"?>
+	<request protocol="3.0" version="update_engine-0.4.10" updaterversion="update_engine-0.4.10" installsource="scheduler" ismachine="1">
+		<os version="Chateau" platform="CoreOS" sp="2512.2.0_x86_64"></os>
+		<app appid="e96281a6-d1af-4bde-9a0a-97b76e56dc57" version="1.2.3" track="stable" machineid="compressed-request-machine" board="amd64-usr">
+			<ping active="1"></ping>
+			<updatecheck></updatecheck>
+		</app>
+	</request>`
+	var compressedRequest bytes.Buffer
+	gw := gzip.NewWriter(&compressedRequest)
+	_, err = gw.Write([]byte(xmlRequest))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/update/", bytes.NewReader(compressedRequest.Bytes()))
+	c.Request.Header.Set("Content-Encoding", "gzip")
+	c.Request.Header.Set("Accept-Encoding", "gzip")
+	ctl.processOmahaRequest(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	resp, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `<app appid="e96281a6-d1af-4bde-9a0a-97b76e56dc57" status="ok">`)
+
+	// Responses aren't compressed unless requested.
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/update/", bytes.NewBufferString(xmlRequest))
+	ctl.processOmahaRequest(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), `<app appid="e96281a6-d1af-4bde-9a0a-97b76e56dc57" status="ok">`)
+
+	// Malformed compressed bodies are rejected.
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/update/", bytes.NewBufferString(xmlRequest))
+	c.Request.Header.Set("Content-Encoding", "gzip")
+	ctl.processOmahaRequest(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOmahaResponseSignature(t *testing.T) {
+	a, err := api.NewForTest(api.OptionInitDB, api.OptionResponseSigningKey("signing-key"))
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	defer a.Close()
+
+	ctl, err := newController(&controllerConfig{noopAuthConfig: &auth.NoopAuthConfig{}, api: a})
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+
+	xmlRequest := `<?xml version="1.0" encoding="UTF-8"?>
+	<request protocol="3.0" version="update_engine-0.4.10" updaterversion="update_engine-0.4.10" installsource="scheduler" ismachine="1">
+		<os version="Chateau" platform="CoreOS" sp="2512.2.0_x86_64"></os>
+		<app appid="e96281a6-d1af-4bde-9a0a-97b76e56dc57" version="1.2.3" track="stable" machineid="signed-response-machine" board="amd64-usr">
+			<ping active="1"></ping>
+			<updatecheck></updatecheck>
+		</app>
+	</request>`
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/update/", bytes.NewBufferString(xmlRequest))
+	ctl.processOmahaRequest(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	signature := w.Header().Get(OmahaSignatureHeader)
+	require.NotEmpty(t, signature)
+	assert.True(t, api.VerifyResponseSignature("signing-key", w.Body.Bytes(), signature))
+	assert.False(t, api.VerifyResponseSignature("another-key", w.Body.Bytes(), signature))
+
+	// The signature is made over the uncompressed response.
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/update/", bytes.NewBufferString(xmlRequest))
+	c.Request.Header.Set("Accept-Encoding", "gzip")
+	c.Request.Header.Set(OmahaDebugHeader, "1")
+	ctl.processOmahaRequest(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(OmahaUpdateDecisionHeader))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	resp, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.True(t, api.VerifyResponseSignature("signing-key", resp, w.Header().Get(OmahaSignatureHeader)))
+}
+
+func TestHTTPStatusForError(t *testing.T) {
+	testCases := []struct {
+		err    error
+		status int
+	}{
+		{api.ErrNotFound, http.StatusNotFound},
+		{api.ErrNoRowsAffected, http.StatusNotFound},
+		{api.ErrStaleRevision, http.StatusConflict},
+		{api.ErrGroupNameExists, http.StatusConflict},
+		{fmt.Errorf("group %q: %w", "default", api.ErrConflict), http.StatusConflict},
+		{api.ErrInvalidSemver, http.StatusBadRequest},
+		{errors.New("unknown"), http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.status, httpStatusForError(tc.err), tc.err.Error())
+	}
+}
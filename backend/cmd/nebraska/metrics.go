@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kinvolk/nebraska/backend/pkg/omaha"
 )
 
 const (
@@ -48,6 +50,10 @@ func registerNebraskaMetrics() error {
 	if err != nil {
 		return err
 	}
+	err = omaha.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
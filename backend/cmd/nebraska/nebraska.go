@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
 	"path"
@@ -20,6 +21,7 @@ import (
 
 	"github.com/kinvolk/nebraska/backend/cmd/nebraska/auth"
 	"github.com/kinvolk/nebraska/backend/pkg/api"
+	"github.com/kinvolk/nebraska/backend/pkg/grpcapi"
 	"github.com/kinvolk/nebraska/backend/pkg/random"
 	"github.com/kinvolk/nebraska/backend/pkg/util"
 )
@@ -35,6 +37,8 @@ const (
 	oidcClientSecretEnvName    = "NEBRASKA_OIDC_CLIENT_SECRET"
 	oidcSessionAuthKeyEnvName  = "NEBRASKA_OIDC_SESSION_SECRET"
 	oidcSessionCryptKeyEnvName = "NEBRASKA_OIDC_SESSION_CRYPT_KEY"
+	dbReplicaURLEnvName        = "NEBRASKA_DB_REPLICA_URL"
+	responseSigningKeyEnvName  = "NEBRASKA_OMAHA_RESPONSE_SIGNING_KEY"
 )
 
 var (
@@ -70,6 +74,24 @@ var (
 	appHeaderStyle        = flag.String("client-header-style", "light", "Client app header style, should be either dark or light")
 	apiEndpointSuffix     = flag.String("api-endpoint-suffix", "", "Additional suffix for the API endpoint to serve Omaha clients on; use a secret to only serve your clients, e.g., mysecret results in /v1/update/mysecret")
 	debug                 = flag.Bool("debug", false, "sets log level to debug")
+	rollbackCheckInterval = flag.String("rollback-check-interval", "1m", "Interval in which the groups with automatic rollbacks enabled are checked for failed updates")
+	inferStatusFromPing   = flag.Bool("infer-status-from-ping", false, "Mark instances as complete when they ping reporting their group's channel package version")
+	updateCheckInterval   = flag.Duration("update-check-min-interval", 0, "Minimum interval between the accepted update checks of each instance, more frequent update checks get the last decision made; 0 disables the limit")
+	updateTimeoutInterval = flag.Duration("update-timeout-check-interval", time.Minute, "Interval in which the groups' update timeout actions are applied to the instances whose update timed out")
+	staleInstancesCheck   = flag.Duration("stale-instances-check-interval", time.Hour, "Interval in which the instances that stopped checking for updates are deleted")
+	activeWithin          = flag.Duration("active-instances-window", 24*time.Hour, "Window in which instances must have checked for updates to be counted as active in the stats, unless a request asks for another one")
+	validatePackageURLs   = flag.Bool("validate-package-urls", false, "Check that the URLs and mirrors of the packages added are reachable, taking the size of the packages without one from them")
+	eventRetentionCheck   = flag.Duration("event-retention-check-interval", time.Hour, "Interval in which the events older than the event retention period are rolled up into daily aggregates and deleted")
+	eventRetention        = flag.Duration("event-retention", 0, "Period after which the events are rolled up into per group daily aggregates and deleted; 0 keeps them")
+	staleInstancesAge     = flag.Duration("stale-instances-retention", 0, "Period after which the instances that stopped checking for updates are deleted, unless their application sets its own; 0 keeps them")
+	dbReplicaURL          = flag.String("db-replica-url", "", fmt.Sprintf("URL of a read replica of the database the dashboard stats and instances listings are read from; can be taken from %s env var too", dbReplicaURLEnvName))
+	geoIPDB               = flag.String("geoip-db", "", "Path to a file mapping ip ranges to regions, one \"cidr,region\" entry per line, used to tag the instances with the region they are in; empty disables it")
+	omahaRecorderSize     = flag.Int("omaha-request-recorder-size", 0, "Number of raw Omaha requests kept in memory to be replayed in dry-run mode through the API, to reproduce the behavior of the clients; 0 disables recording them")
+	responseSigningKey    = flag.String("omaha-response-signing-key", "", fmt.Sprintf("Key the Omaha responses are signed with, the HMAC-SHA256 signature of each response body is sent in the %s header so clients sharing the key can verify it; can be taken from %s env var too; empty disables signing", OmahaSignatureHeader, responseSigningKeyEnvName))
+	rolloutPollInterval   = flag.Duration("omaha-rollout-poll-interval", 0, "Interval the instances of groups with a rollout in progress are told to check for updates with, in the poll_interval attribute of the Omaha responses; 0 doesn't hint it")
+	idlePollInterval      = flag.Duration("omaha-idle-poll-interval", 0, "Interval the instances of groups without a rollout in progress are told to check for updates with, in the poll_interval attribute of the Omaha responses; 0 doesn't hint it")
+	grpcListenAddress     = flag.String("grpc-listen-address", "", "Address to serve the gRPC API on, e.g. 127.0.0.1:9000; requires grpc-tokens-file; empty disables it")
+	grpcTokensFile        = flag.String("grpc-tokens-file", "", "Path to a file with the tokens the gRPC API clients authenticate with, one \"team_id token\" entry per line; each token gives access to the applications of its team, sent as a bearer token in the authorization metadata of the requests")
 	logger                = util.NewLogger("nebraska")
 )
 
@@ -93,10 +115,38 @@ func mainWithError() error {
 		return err
 	}
 
-	api, err := api.New()
+	var apiOptions []func(*api.API) error
+	if *inferStatusFromPing {
+		apiOptions = append(apiOptions, api.OptionInferStatusFromPing)
+	}
+	if *updateCheckInterval > 0 {
+		apiOptions = append(apiOptions, api.OptionUpdateCheckMinInterval(*updateCheckInterval))
+	}
+	apiOptions = append(apiOptions, api.OptionActiveWithin(*activeWithin))
+	if *validatePackageURLs {
+		apiOptions = append(apiOptions, api.OptionValidatePackageURLs)
+	}
+	if url := getPotentialOrEnv(*dbReplicaURL, dbReplicaURLEnvName); url != "" {
+		apiOptions = append(apiOptions, api.OptionReadReplica(url))
+	}
+	if key := getPotentialOrEnv(*responseSigningKey, responseSigningKeyEnvName); key != "" {
+		apiOptions = append(apiOptions, api.OptionResponseSigningKey(key))
+	}
+	if *geoIPDB != "" {
+		resolver, err := api.NewCIDRRegionResolver(*geoIPDB)
+		if err != nil {
+			return err
+		}
+		apiOptions = append(apiOptions, api.OptionRegionResolver(resolver))
+	}
+
+	api, err := api.New(apiOptions...)
 	if err != nil {
 		return err
 	}
+	if err := api.RecalculateAllRolloutStats(); err != nil {
+		logger.Error().Err(err).Msg("could not recalculate the rollout stats of the groups")
+	}
 
 	var (
 		noopAuthConfig *auth.NoopAuthConfig
@@ -188,6 +238,10 @@ func mainWithError() error {
 	if err != nil {
 		return err
 	}
+	rollbackInterval, err := time.ParseDuration(*rollbackCheckInterval)
+	if err != nil {
+		return err
+	}
 	conf := &controllerConfig{
 		api:                 api,
 		enableSyncer:        *enableSyncer,
@@ -199,6 +253,9 @@ func mainWithError() error {
 		oidcAuthConfig:      oidcAuthConfig,
 		flatcarUpdatesURL:   *flatcarUpdatesURL,
 		checkFrequency:      checkFrequency,
+		omahaRecorderSize:   *omahaRecorderSize,
+		rolloutPollInterval: *rolloutPollInterval,
+		idlePollInterval:    *idlePollInterval,
 	}
 	ctl, err := newController(conf)
 	if err != nil {
@@ -214,6 +271,19 @@ func mainWithError() error {
 		return err
 	}
 
+	startRollbackEvaluator(ctl, rollbackInterval)
+	startStaleInstancesPruner(ctl, *staleInstancesCheck, *staleInstancesAge)
+	if *eventRetention > 0 {
+		startEventRollup(ctl, *eventRetentionCheck, *eventRetention)
+	}
+	startUpdateTimeoutSweeper(ctl, *updateTimeoutInterval)
+
+	if *grpcListenAddress != "" {
+		if err := startGRPCServer(ctl, *grpcListenAddress, *grpcTokensFile); err != nil {
+			return err
+		}
+	}
+
 	var params []string
 	if os.Getenv("PORT") == "" {
 		params = append(params, ":8000")
@@ -221,6 +291,101 @@ func mainWithError() error {
 	return engine.Run(params...)
 }
 
+// startGRPCServer serves the gRPC API on the given address, to the clients
+// authenticating with one of the tokens of the file provided.
+func startGRPCServer(ctl *controller, address, tokensFile string) error {
+	if tokensFile == "" {
+		return errors.New("grpc-tokens-file is required to serve the gRPC API")
+	}
+	authenticator, err := grpcapi.NewTokenAuthenticatorFromFile(tokensFile)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	server := grpcapi.NewServer(ctl.api, authenticator)
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Error().Err(err).Msg("startGRPCServer - serving gRPC API")
+		}
+	}()
+
+	return nil
+}
+
+// startRollbackEvaluator periodically rolls back the channels of the groups
+// in which too many updates failed.
+func startRollbackEvaluator(ctl *controller, interval time.Duration) {
+	ticker := time.Tick(interval)
+
+	go func() {
+		for {
+			<-ticker
+			if err := ctl.api.EvaluateRollbacks(); err != nil {
+				logger.Error().Err(err).Msg("startRollbackEvaluator - evaluating rollbacks")
+			}
+		}
+	}()
+}
+
+// startStaleInstancesPruner periodically deletes the instances that haven't
+// checked for updates in longer than their application's retention period.
+func startStaleInstancesPruner(ctl *controller, interval, retention time.Duration) {
+	ticker := time.Tick(interval)
+
+	go func() {
+		for {
+			<-ticker
+			deleted, err := ctl.api.DeleteStaleInstances(retention)
+			if err != nil {
+				logger.Error().Err(err).Msg("startStaleInstancesPruner - deleting stale instances")
+				continue
+			}
+			if deleted > 0 {
+				logger.Info().Int("deleted", deleted).Msg("startStaleInstancesPruner - deleted stale instances")
+			}
+		}
+	}()
+}
+
+// startEventRollup periodically rolls up the events older than the retention
+// period into daily aggregates, deleting them.
+func startEventRollup(ctl *controller, interval, retention time.Duration) {
+	ticker := time.Tick(interval)
+
+	go func() {
+		for {
+			<-ticker
+			deleted, err := ctl.api.RollupEvents(retention)
+			if err != nil {
+				logger.Error().Err(err).Msg("startEventRollup - rolling up events")
+				continue
+			}
+			if deleted > 0 {
+				logger.Info().Int("deleted", deleted).Msg("startEventRollup - rolled up events")
+			}
+		}
+	}()
+}
+
+// startUpdateTimeoutSweeper periodically applies the groups' update timeout
+// actions to the instances whose update timed out.
+func startUpdateTimeoutSweeper(ctl *controller, interval time.Duration) {
+	ticker := time.Tick(interval)
+
+	go func() {
+		for {
+			<-ticker
+			if err := ctl.api.SweepUpdateTimeouts(); err != nil {
+				logger.Error().Err(err).Msg("startUpdateTimeoutSweeper - sweeping update timeouts")
+			}
+		}
+	}()
+}
+
 func obtainSessionAuthKey(potentialSecret string) []byte {
 	if secret := getPotentialOrEnv(potentialSecret, ghSessionAuthKeyEnvName); secret != "" {
 		return []byte(secret)
@@ -363,49 +528,114 @@ func setupRoutes(ctl *controller, httpLog bool) *gin.Engine {
 	apiRouter.POST("/apps", ctl.addApp)
 	apiRouter.PUT("/apps/:app_id", ctl.updateApp)
 	apiRouter.DELETE("/apps/:app_id", ctl.deleteApp)
+	apiRouter.POST("/apps/:app_id/restore", ctl.restoreApp)
+	apiRouter.DELETE("/apps/:app_id/purge", ctl.purgeApp)
 	apiRouter.GET("/apps/:app_id", ctl.getApp)
+	apiRouter.GET("/apps/:app_id/export", ctl.exportApp)
 	apiRouter.GET("/apps", ctl.getApps)
+	apiRouter.GET("/apps/:app_id/registration_policy", ctl.getAppRegistrationPolicy)
+	apiRouter.PUT("/apps/:app_id/registration_policy", ctl.setAppRegistrationPolicy)
+	apiRouter.GET("/apps/:app_id/track_aliases", ctl.getTrackAliases)
+	apiRouter.PUT("/apps/:app_id/track_aliases/:alias", ctl.setTrackAlias)
+	apiRouter.DELETE("/apps/:app_id/track_aliases/:alias", ctl.deleteTrackAlias)
 
 	// Groups
 	apiRouter.POST("/apps/:app_id/groups", ctl.addGroup)
 	apiRouter.PUT("/apps/:app_id/groups/:group_id", ctl.updateGroup)
+	apiRouter.PATCH("/apps/:app_id/groups/:group_id", ctl.patchGroup)
 	apiRouter.DELETE("/apps/:app_id/groups/:group_id", ctl.deleteGroup)
+	apiRouter.POST("/apps/:app_id/groups/:group_id/clone", ctl.cloneGroup)
+	apiRouter.POST("/apps/:app_id/groups/:group_id/pause", ctl.pauseGroup)
+	apiRouter.POST("/apps/:app_id/groups/:group_id/resume", ctl.resumeGroup)
+	apiRouter.POST("/apps/:app_id/groups/:group_id/advance_cohort", ctl.advanceGroupCohort)
 	apiRouter.GET("/apps/:app_id/groups/:group_id", ctl.getGroup)
 	apiRouter.GET("/apps/:app_id/groups", ctl.getGroups)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/version_timeline", ctl.getGroupVersionCountTimeline)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/status_timeline", ctl.getGroupStatusCountTimeline)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/instances_stats", ctl.getGroupInstancesStats)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/version_breakdown", ctl.getGroupVersionBreakdown)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/error_breakdown", ctl.getGroupErrorBreakdown)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/failing_instances", ctl.getGroupFailingInstances)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/rollout_progress", ctl.getGroupRolloutProgress)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/update_durations", ctl.getGroupUpdateDurations)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/event_aggregates", ctl.getGroupEventAggregates)
 
 	// Channels
 	apiRouter.POST("/apps/:app_id/channels", ctl.addChannel)
 	apiRouter.PUT("/apps/:app_id/channels/:channel_id", ctl.updateChannel)
 	apiRouter.DELETE("/apps/:app_id/channels/:channel_id", ctl.deleteChannel)
+	apiRouter.POST("/apps/:app_id/channels/:channel_id/promote", ctl.promoteChannelPackage)
+	apiRouter.GET("/apps/:app_id/channels/:channel_id/promotion_preview", ctl.previewChannelPromotion)
+	apiRouter.GET("/apps/:app_id/channels/:channel_id/history", ctl.getChannelHistory)
 	apiRouter.GET("/apps/:app_id/channels/:channel_id", ctl.getChannel)
 	apiRouter.GET("/apps/:app_id/channels", ctl.getChannels)
+	apiRouter.GET("/apps/:app_id/channels/suggested_color", ctl.getSuggestedChannelColor)
 
 	// Packages
 	apiRouter.POST("/apps/:app_id/packages", ctl.addPackage)
+	apiRouter.POST("/apps/:app_id/packages/batch", ctl.addPackages)
 	apiRouter.PUT("/apps/:app_id/packages/:package_id", ctl.updatePackage)
 	apiRouter.DELETE("/apps/:app_id/packages/:package_id", ctl.deletePackage)
 	apiRouter.GET("/apps/:app_id/packages/:package_id", ctl.getPackage)
+	apiRouter.GET("/apps/:app_id/packages/:package_id/groups", ctl.getGroupsUsingPackage)
 	apiRouter.GET("/apps/:app_id/packages", ctl.getPackages)
+	apiRouter.POST("/apps/:app_id/packages/prune", ctl.pruneOldPackages)
+	apiRouter.POST("/apps/:app_id/packages/:package_id/deltas", ctl.addPackageDelta)
+	apiRouter.DELETE("/apps/:app_id/packages/:package_id/deltas/:delta_id", ctl.deletePackageDelta)
+	apiRouter.GET("/apps/:app_id/packages/:package_id/deltas", ctl.getPackageDeltas)
+	apiRouter.POST("/apps/:app_id/packages/:package_id/artifacts", ctl.addPackageArtifact)
+	apiRouter.DELETE("/apps/:app_id/packages/:package_id/artifacts/:artifact_id", ctl.deletePackageArtifact)
+	apiRouter.GET("/apps/:app_id/packages/:package_id/artifacts", ctl.getPackageArtifacts)
 
 	// Instances
 	apiRouter.GET("/apps/:app_id/groups/:group_id/instances/:instance_id/status_history", ctl.getInstanceStatusHistory)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/instances/:instance_id/timeline", ctl.getInstanceTimeline)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/instances", ctl.getInstances)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/instancescount", ctl.getInstancesCount)
+	apiRouter.GET("/apps/:app_id/groups/:group_id/instances_report", ctl.getInstancesReport)
 	apiRouter.GET("/apps/:app_id/groups/:group_id/instances/:instance_id", ctl.getInstance)
+	apiRouter.GET("/apps/:app_id/instances_by_label", ctl.getInstancesByLabel)
+	apiRouter.GET("/apps/:app_id/quarantined_instances", ctl.getQuarantinedInstances)
+	apiRouter.POST("/apps/:app_id/quarantined_instances/:instance_id/acknowledge", ctl.acknowledgeQuarantinedInstance)
+	apiRouter.GET("/apps/:app_id/stuck_instances", ctl.getStuckInstances)
+	apiRouter.DELETE("/apps/:app_id/instances/:instance_id", ctl.deregisterInstance)
+	apiRouter.GET("/apps/:app_id/instances/:instance_id/package_override", ctl.getInstancePackageOverride)
+	apiRouter.PUT("/apps/:app_id/instances/:instance_id/package_override", ctl.setInstancePackageOverride)
+	apiRouter.DELETE("/apps/:app_id/instances/:instance_id/package_override", ctl.clearInstancePackageOverride)
 	apiRouter.PUT("/instances/:instance_id", ctl.updateInstance)
+	apiRouter.GET("/instances/:instance_id/note", ctl.getInstanceNote)
+	apiRouter.PUT("/instances/:instance_id/note", ctl.setInstanceNote)
+	apiRouter.GET("/instances/:instance_id/status", ctl.getInstanceStatus)
+	apiRouter.GET("/apps/:app_id/instances_stats_by_arch", ctl.getInstanceStatsByArch)
+	apiRouter.GET("/apps/:app_id/instances_stats_by_region", ctl.getInstanceStatsByRegion)
+	apiRouter.GET("/apps/:app_id/events/stream", ctl.streamEvents)
+
+	// Webhooks
+	apiRouter.POST("/apps/:app_id/webhooks", ctl.addWebhook)
+	apiRouter.DELETE("/apps/:app_id/webhooks/:webhook_id", ctl.deleteWebhook)
+	apiRouter.GET("/apps/:app_id/webhooks", ctl.getWebhooks)
+
+	// Dashboard
+	apiRouter.GET("/dashboard", ctl.getDashboardSummary)
 
 	// Activity
 	apiRouter.GET("/activity", ctl.getActivity)
+	apiRouter.GET("/activity/audit", ctl.getAuditLog)
+
+	// Omaha dry-run
+	apiRouter.POST("/omaha/dry-run", ctl.processOmahaDryRunRequest)
+	apiRouter.GET("/omaha/recorded-requests", ctl.getRecordedOmahaRequests)
+	apiRouter.POST("/omaha/recorded-requests/:request_id/replay", ctl.replayOmahaRequest)
 
 	// Omaha server router setup
 	omahaRouter := wrappedEngine.Group("/", "omaha")
 	omahaRouter.POST("/omaha", ctl.processOmahaRequest)
 	omahaRouter.POST(path.Join("/v1/update", *apiEndpointSuffix), ctl.processOmahaRequest)
 
+	// Health router setup, not authenticated so it can be used as a probe
+	healthRouter := wrappedEngine.Group("/", "health")
+	healthRouter.GET("/healthz", ctl.healthz)
+
 	// Config router setup
 	configRouter := wrappedEngine.Group("/config", "config")
 	if *authMode != "oidc" {
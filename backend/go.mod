@@ -9,6 +9,7 @@ require (
 	github.com/doug-martin/goqu/v9 v9.12.0
 	github.com/gin-contrib/requestid v0.0.1
 	github.com/gin-gonic/gin v1.7.1
+	github.com/golang/protobuf v1.4.3
 	github.com/golangci/golangci-lint v1.39.0
 	github.com/google/go-github/v28 v28.1.1
 	github.com/google/uuid v1.2.0
@@ -26,5 +27,7 @@ require (
 	github.com/tidwall/gjson v1.8.0
 	github.com/ziutek/mymysql v1.5.4 // indirect
 	golang.org/x/oauth2 v0.0.0-20210427180440-81ed05c6b58c
+	google.golang.org/grpc v1.31.0
+	google.golang.org/protobuf v1.25.0
 	gopkg.in/guregu/null.v4 v4.0.0
 )
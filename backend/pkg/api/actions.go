@@ -18,6 +18,8 @@ type FlatcarAction struct {
 	MetadataSignatureRsa  string    `db:"metadata_signature_rsa" json:"metadata_signature_rsa"`
 	MetadataSize          string    `db:"metadata_size" json:"metadata_size"`
 	Deadline              string    `db:"deadline" json:"deadline"`
+	PayloadSignature      string    `db:"payload_signature" json:"payload_signature"`
+	SigningKeyID          string    `db:"signing_key_id" json:"signing_key_id"`
 	CreatedTs             time.Time `db:"created_ts" json:"created_ts"`
 	PackageID             string    `db:"package_id" json:"-"`
 }
@@ -25,7 +27,7 @@ type FlatcarAction struct {
 // AddFlatcarAction registers the provided Omaha Flatcar action.
 func (api *API) AddFlatcarAction(action *FlatcarAction) (*FlatcarAction, error) {
 	query, _, err := goqu.Insert("flatcar_action").
-		Cols("event", "chromeos_version", "sha256", "needs_admin", "is_delta", "disable_payload_backoff", "metadata_signature_rsa", "metadata_size", "deadline", "package_id").
+		Cols("event", "chromeos_version", "sha256", "needs_admin", "is_delta", "disable_payload_backoff", "metadata_signature_rsa", "metadata_size", "deadline", "payload_signature", "signing_key_id", "package_id").
 		Vals(goqu.Vals{
 			action.Event,
 			action.ChromeOSVersion,
@@ -36,6 +38,8 @@ func (api *API) AddFlatcarAction(action *FlatcarAction) (*FlatcarAction, error)
 			action.MetadataSignatureRsa,
 			action.MetadataSize,
 			action.Deadline,
+			action.PayloadSignature,
+			action.SigningKeyID,
 			action.PackageID,
 		}).
 		Returning(goqu.T("flatcar_action").All()).
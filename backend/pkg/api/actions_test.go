@@ -14,7 +14,7 @@ func TestAddFlatcarAction(t *testing.T) {
 	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
 	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
 
-	flatcarAction, err := a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PackageID: tPkg.ID})
+	flatcarAction, err := a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PayloadSignature: "c2lnbmF0dXJl", SigningKeyID: "flatcar-2021", PackageID: tPkg.ID})
 	assert.NoError(t, err)
 
 	flatcarActionX, err := a.GetFlatcarAction(tPkg.ID)
@@ -22,4 +22,6 @@ func TestAddFlatcarAction(t *testing.T) {
 
 	assert.Equal(t, flatcarAction.Event, flatcarActionX.Event)
 	assert.Equal(t, flatcarAction.Sha256, flatcarActionX.Sha256)
+	assert.Equal(t, "c2lnbmF0dXJl", flatcarActionX.PayloadSignature)
+	assert.Equal(t, "flatcar-2021", flatcarActionX.SigningKeyID)
 }
@@ -19,6 +19,14 @@ const (
 	activityRolloutFailed
 	activityInstanceUpdateFailed
 	activityChannelPackageUpdated
+	activityInstanceVersionMalformed
+	activityVersionSpreadExceeded
+	activityRolloutRolledBack
+	activityChannelPackagePromoted
+	activityInstanceUpdateTimedOut
+	activityEntityCreated
+	activityEntityUpdated
+	activityEntityDeleted
 )
 
 const (
@@ -73,7 +81,7 @@ func (api *API) GetActivity(teamID string, p ActivityQueryParams) ([]*Activity,
 	if err != nil {
 		return nil, err
 	}
-	rows, err := api.db.Queryx(query)
+	rows, err := api.readDB().Queryx(query)
 	if err != nil {
 		return nil, err
 	}
@@ -101,12 +109,12 @@ func (api *API) activityQuery(teamID string, p ActivityQueryParams) *goqu.Select
 	if !p.Start.IsZero() {
 		start = p.Start.UTC()
 	} else {
-		start = time.Now().UTC().AddDate(0, 0, -3)
+		start = api.nowUTC().AddDate(0, 0, -3)
 	}
 	if !p.End.IsZero() {
 		end = p.End.UTC()
 	} else {
-		end = time.Now().UTC()
+		end = api.nowUTC()
 	}
 	query := goqu.From(goqu.L(`
 	activity AS a 
@@ -116,7 +124,7 @@ func (api *API) activityQuery(teamID string, p ActivityQueryParams) *goqu.Select
 `)).Select("a.application_id", "a.group_id", "a.created_ts", "a.class", "a.severity", "a.version", "a.instance_id",
 		goqu.I("app.name").As("application_name"), goqu.I("g.name").
 			As("group_name"), goqu.I("c.name").As("channel_name")).
-		Where(goqu.I("app.team_id").Eq(teamID), goqu.And(goqu.I("a.created_ts").Gte(start),
+		Where(goqu.I("app.team_id").Eq(teamID), goqu.I("app.deleted_at").IsNull(), goqu.And(goqu.I("a.created_ts").Gte(start),
 			goqu.I("a.created_ts").Lt(end)))
 
 	if p.AppID != "" {
@@ -137,6 +145,9 @@ func (api *API) activityQuery(teamID string, p ActivityQueryParams) *goqu.Select
 		query = query.Where(goqu.L(ignoreFakeInstanceCondition("a.instance_id")))
 	}
 
+	// Audit log entries are returned by GetAuditLog.
+	query = query.Where(goqu.I("a.entity_type").IsNull())
+
 	if p.Version != "" {
 		query = query.Where(goqu.I("a.version").Eq(p.Version))
 	}
@@ -152,7 +163,7 @@ func (api *API) activityQuery(teamID string, p ActivityQueryParams) *goqu.Select
 }
 
 func (api *API) hasRecentActivity(class int, p ActivityQueryParams) bool {
-	recent := time.Now().UTC().Add(-24 * time.Hour)
+	recent := api.nowUTC().Add(-24 * time.Hour)
 
 	query := goqu.From("activity").
 		Select("*").
@@ -318,6 +329,24 @@ func (api *API) postHipchat(class, severity int, version string, ctx *activityCo
 		channel, _ := api.GetChannel(ctx.channelID)
 		fmt.Fprintf(&msg, "Channel <i>%s</i> is now pointing to version <i>%s</i>", channel.Name, version)
 		color = "purple"
+	case activityInstanceVersionMalformed:
+		instance, _ := api.GetInstance(ctx.instanceID, ctx.appID)
+		fmt.Fprintf(&msg, "Instance <i>%s</i> reported the malformed version <i>%s</i>", instance.IP, version)
+		color = "yellow"
+	case activityVersionSpreadExceeded:
+		fmt.Fprintf(&msg, "Instances are running too many different versions, the newest being <i>%s</i>", version)
+		color = "yellow"
+	case activityRolloutRolledBack:
+		fmt.Fprintf(&msg, "Too many updates failed, the group's channel has been rolled back to version <i>%s</i>", version)
+		color = "red"
+	case activityChannelPackagePromoted:
+		channel, _ := api.GetChannel(ctx.channelID)
+		fmt.Fprintf(&msg, "Version <i>%s</i> has been promoted to channel <i>%s</i>", version, channel.Name)
+		color = "purple"
+	case activityInstanceUpdateTimedOut:
+		instance, _ := api.GetInstance(ctx.instanceID, ctx.appID)
+		fmt.Fprintf(&msg, "Instance <i>%s</i> timed out while updating to version <i>%s</i>", instance.IP, version)
+		color = "yellow"
 	}
 
 	body := map[string]interface{}{
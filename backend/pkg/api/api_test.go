@@ -1,10 +1,14 @@
 package api
 
 import (
+	"context"
 	"log"
 	"os"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,3 +45,65 @@ func TestMain(m *testing.M) {
 
 	os.Exit(m.Run())
 }
+
+func TestHealthz(t *testing.T) {
+	a := newForTest(t)
+
+	migrations, err := AssetDir(migrationsDir)
+	require.NoError(t, err)
+	sort.Strings(migrations)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	version, err := a.Healthz(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1], version)
+
+	_, err = a.db.Exec("DELETE FROM database_migrations WHERE id = $1", version)
+	require.NoError(t, err)
+	version, err = a.Healthz(ctx)
+	assert.Equal(t, ErrPendingMigrations, err)
+	assert.Equal(t, migrations[len(migrations)-2], version)
+
+	a.Close()
+	_, err = a.Healthz(ctx)
+	assert.Error(t, err)
+}
+
+func TestReadReplica(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionReadReplica(os.Getenv("NEBRASKA_DB_URL")))
+	require.NoError(t, err)
+	defer a.Close()
+
+	require.NotNil(t, a.replicaDB)
+	assert.True(t, a.readDB() == a.replicaDB)
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	_, err = a.RegisterInstance("instance1", "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	instances, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID}, testDuration)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), instances.TotalInstances)
+
+	// Once the replica is unreachable the reads routed to it fail, while
+	// writes and the reads of the updates flow keep using the primary.
+	require.NoError(t, a.replicaDB.Close())
+
+	_, err = a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID}, testDuration)
+	assert.Error(t, err)
+	_, err = a.GetInstanceTimeline("instance1", tApp.ID)
+	assert.Error(t, err)
+	_, err = a.GetGroupInstancesStats(tGroup.ID, testDuration)
+	assert.Error(t, err)
+
+	_, err = a.RegisterInstance("instance2", "", "10.0.0.2", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.GetInstance("instance2", tApp.ID)
+	assert.NoError(t, err)
+
+	a.replicaDB = nil
+	assert.True(t, a.readDB() == a.db)
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// appExportVersion is the version of the documents produced by ExportApp. It
+// must be increased whenever the format changes in a way older versions of
+// ImportApp can't handle.
+const appExportVersion = 1
+
+var (
+	// ErrUnsupportedAppExportVersion error indicates that the application
+	// export document provided was produced by an unsupported version.
+	ErrUnsupportedAppExportVersion = newValidationError("nebraska: unsupported application export version")
+
+	// ErrInvalidAppExport error indicates that the application export document
+	// provided references packages or channels that are not part of it.
+	ErrInvalidAppExport = newValidationError("nebraska: invalid application export")
+)
+
+// AppExport represents the full configuration of an application, as exported
+// by ExportApp. Groups, channels and packages keep their original ids, which
+// are only used to describe the relationships between them.
+type AppExport struct {
+	Version                int                         `json:"version"`
+	TeamID                 string                      `json:"team_id"`
+	Name                   string                      `json:"name"`
+	Description            string                      `json:"description"`
+	InstanceRetentionDays  null.Int                    `json:"instance_retention_days"`
+	DisablePingPersistence bool                        `json:"disable_ping_persistence"`
+	AllowedEventTypes      []int                       `json:"allowed_event_types"`
+	RegistrationPolicy     *InstanceRegistrationPolicy `json:"registration_policy"`
+	Packages               []*Package                  `json:"packages"`
+	Channels               []*Channel                  `json:"channels"`
+	Groups                 []*Group                    `json:"groups"`
+}
+
+// ExportApp serializes the configuration of the application provided, that
+// is its groups, channels, packages and Flatcar actions, to a versioned JSON
+// document that can be imported later using ImportApp.
+func (api *API) ExportApp(appID string) ([]byte, error) {
+	app, err := api.GetApp(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AppExport{
+		Version:                appExportVersion,
+		TeamID:                 app.TeamID,
+		Name:                   app.Name,
+		Description:            app.Description,
+		InstanceRetentionDays:  app.InstanceRetentionDays,
+		DisablePingPersistence: app.DisablePingPersistence,
+		Channels:               app.Channels,
+		Groups:                 app.Groups,
+	}
+	if export.AllowedEventTypes, err = api.GetAppAllowedEventTypes(appID); err != nil {
+		return nil, err
+	}
+	if export.RegistrationPolicy, err = api.GetInstanceRegistrationPolicy(appID); err != nil {
+		return nil, err
+	}
+
+	query, _, err := api.packagesQuery().
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if export.Packages, err = api.getPackagesFromQuery(query); err != nil {
+		return nil, err
+	}
+
+	// The relationships are described by the ids, so there is no need to
+	// embed the channel's package and the group's channel again.
+	for _, channel := range export.Channels {
+		channel.Package = nil
+	}
+	for _, group := range export.Groups {
+		group.Channel = nil
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// ImportApp recreates an application from a document produced by ExportApp
+// in the team the application was exported from. Groups, channels, packages
+// and Flatcar actions get new ids, keeping the relationships between them.
+func (api *API) ImportApp(data []byte) (*Application, error) {
+	return api.ImportAppToTeam(data, "")
+}
+
+// ImportAppToTeam works like ImportApp, but creates the application in the
+// team provided. If the team is empty, the one in the document is used.
+//
+// NOTE: the import is not transactional, if something goes wrong the partially
+// imported application is removed.
+func (api *API) ImportAppToTeam(data []byte, teamID string) (*Application, error) {
+	export := &AppExport{}
+	if err := json.Unmarshal(data, export); err != nil {
+		return nil, err
+	}
+	if export.Version != appExportVersion {
+		return nil, ErrUnsupportedAppExportVersion
+	}
+	if teamID == "" {
+		teamID = export.TeamID
+	}
+
+	app, err := api.AddApp(&Application{
+		Name:                   export.Name,
+		Description:            export.Description,
+		InstanceRetentionDays:  export.InstanceRetentionDays,
+		DisablePingPersistence: export.DisablePingPersistence,
+		TeamID:                 teamID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.importAppConfig(app.ID, export); err != nil {
+		if err := api.PurgeApp(app.ID); err != nil {
+			logger.Error().Err(err).Str("appID", app.ID).Msg("ImportAppToTeam - could not remove partially imported app")
+		}
+		return nil, err
+	}
+
+	return api.GetApp(app.ID)
+}
+
+// importAppConfig creates the packages, channels and groups of the export
+// document provided in the given application, remapping the ids used in the
+// document to the ones of the new entries.
+func (api *API) importAppConfig(appID string, export *AppExport) error {
+	if len(export.AllowedEventTypes) > 0 {
+		if err := api.SetAppAllowedEventTypes(appID, export.AllowedEventTypes); err != nil {
+			return err
+		}
+	}
+	if export.RegistrationPolicy != nil && len(export.RegistrationPolicy.Rules) > 0 {
+		if err := api.SetInstanceRegistrationPolicy(appID, export.RegistrationPolicy); err != nil {
+			return err
+		}
+	}
+
+	// Packages are created first without their channels blacklist, as the
+	// channels don't exist yet.
+	packageIDs := make(map[string]string, len(export.Packages))
+	for _, exported := range export.Packages {
+		pkg := *exported
+		pkg.ID = ""
+		pkg.ApplicationID = appID
+		pkg.ChannelsBlacklist = nil
+		pkg.FlatcarAction = nil
+		if _, err := api.AddPackage(&pkg); err != nil {
+			return err
+		}
+		packageIDs[exported.ID] = pkg.ID
+
+		if exported.FlatcarAction != nil && exported.Type == PkgTypeFlatcar {
+			action := *exported.FlatcarAction
+			action.PackageID = pkg.ID
+			if _, err := api.AddFlatcarAction(&action); err != nil {
+				return err
+			}
+		}
+	}
+	remapPackageID := func(packageID null.String) (null.String, error) {
+		if packageID.String == "" {
+			return null.String{}, nil
+		}
+		newPackageID, ok := packageIDs[packageID.String]
+		if !ok {
+			return null.String{}, ErrInvalidAppExport
+		}
+		return null.StringFrom(newPackageID), nil
+	}
+
+	channelIDs := make(map[string]string, len(export.Channels))
+	for _, exported := range export.Channels {
+		channel := *exported
+		channel.ID = ""
+		channel.ApplicationID = appID
+		channel.Package = nil
+		packageID, err := remapPackageID(exported.PackageID)
+		if err != nil {
+			return err
+		}
+		channel.PackageID = packageID
+		if _, err := api.AddChannel(&channel); err != nil {
+			return err
+		}
+		channelIDs[exported.ID] = channel.ID
+	}
+	remapChannelID := func(channelID string) (string, error) {
+		newChannelID, ok := channelIDs[channelID]
+		if !ok {
+			return "", ErrInvalidAppExport
+		}
+		return newChannelID, nil
+	}
+
+	for _, exported := range export.Packages {
+		for _, channelID := range exported.ChannelsBlacklist {
+			newChannelID, err := remapChannelID(channelID)
+			if err != nil {
+				return err
+			}
+			query, _, err := goqu.Insert("package_channel_blacklist").
+				Cols("package_id", "channel_id").
+				Vals(goqu.Vals{packageIDs[exported.ID], newChannelID}).
+				ToSQL()
+			if err != nil {
+				return err
+			}
+			if _, err := api.db.Exec(query); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, exported := range export.Groups {
+		group := *exported
+		group.ID = ""
+		group.ApplicationID = appID
+		group.Channel = nil
+		// Groups without an explicit track name use their id as track, so
+		// the new group gets its own.
+		if group.Track == exported.ID {
+			group.Track = ""
+		}
+		if exported.ChannelID.String != "" {
+			channelID, err := remapChannelID(exported.ChannelID.String)
+			if err != nil {
+				return err
+			}
+			group.ChannelID = null.StringFrom(channelID)
+		}
+		group.ChannelWeights = make([]ChannelWeight, 0, len(exported.ChannelWeights))
+		for _, weight := range exported.ChannelWeights {
+			channelID, err := remapChannelID(weight.ChannelID)
+			if err != nil {
+				return err
+			}
+			group.ChannelWeights = append(group.ChannelWeights, ChannelWeight{ChannelID: channelID, Weight: weight.Weight})
+		}
+		lastKnownGoodPackageID, err := remapPackageID(exported.LastKnownGoodPackageID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := api.AddGroup(&group); err != nil {
+			return err
+		}
+		if lastKnownGoodPackageID.Valid {
+			if err := api.setGroupLastKnownGoodPackage(group.ID, lastKnownGoodPackageID.String); err != nil {
+				return err
+			}
+		}
+		if exported.PolicyPaused {
+			if err := api.setGroupPaused(group.ID, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestExportImportApp(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID, InstanceRetentionDays: null.IntFrom(30)})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64, Mirrors: StringArray{"http://mirror.url/pkg"}})
+	_, err := a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", IsDelta: true, PayloadSignature: "signature", SigningKeyID: "key-1", PackageID: tPkg1.ID})
+	require.NoError(t, err)
+	tChannel1, _ := a.AddChannel(&Channel{Name: "channel1", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg1.ID), Arch: ArchAMD64})
+	tChannel2, _ := a.AddChannel(&Channel{Name: "channel2", Color: "red", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID, Arch: ArchAMD64, ChannelsBlacklist: []string{tChannel1.ID}})
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel1.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes",
+		ChannelWeights: []ChannelWeight{{ChannelID: tChannel1.ID, Weight: 3}, {ChannelID: tChannel2.ID, Weight: 1}}})
+	require.NoError(t, err)
+	_, err = a.AddGroup(&Group{Name: "group_stable", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel2.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", Track: "stable"})
+	require.NoError(t, err)
+	require.NoError(t, a.setGroupLastKnownGoodPackage(tGroup.ID, tPkg1.ID))
+	require.NoError(t, a.PauseGroup(tGroup.ID))
+
+	data, err := a.ExportApp(tApp.ID)
+	require.NoError(t, err)
+
+	_, err = a.ImportApp(data)
+	assert.Error(t, err, "The application name is already in use in the team.")
+
+	app, err := a.ImportAppToTeam(data, tTeam2.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, tApp.ID, app.ID)
+	assert.Equal(t, tTeam2.ID, app.TeamID)
+	assert.Equal(t, tApp.Name, app.Name)
+	assert.Equal(t, tApp.Description, app.Description)
+	assert.Equal(t, null.IntFrom(30), app.InstanceRetentionDays)
+
+	pkgs, err := a.GetPackages(app.ID, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	pkgsByVersion := make(map[string]*Package)
+	for _, pkg := range pkgs {
+		assert.NotContains(t, []string{tPkg1.ID, tPkg2.ID}, pkg.ID)
+		pkgsByVersion[pkg.Version] = pkg
+	}
+	pkg1, pkg2 := pkgsByVersion[tPkg1.Version], pkgsByVersion[tPkg2.Version]
+	require.NotNil(t, pkg1)
+	require.NotNil(t, pkg2)
+	assert.Equal(t, StringArray{"http://mirror.url/pkg"}, pkg1.Mirrors)
+	require.NotNil(t, pkg1.FlatcarAction)
+	assert.Equal(t, "postinstall", pkg1.FlatcarAction.Event)
+	assert.Equal(t, "fsdkjjfghsdakjfgaksdjfasd", pkg1.FlatcarAction.Sha256)
+	assert.True(t, pkg1.FlatcarAction.IsDelta)
+	assert.Equal(t, "signature", pkg1.FlatcarAction.PayloadSignature)
+	assert.Equal(t, "key-1", pkg1.FlatcarAction.SigningKeyID)
+
+	channelsByName := make(map[string]*Channel)
+	for _, channel := range app.Channels {
+		assert.NotContains(t, []string{tChannel1.ID, tChannel2.ID}, channel.ID)
+		channelsByName[channel.Name] = channel
+	}
+	require.Len(t, channelsByName, 2)
+	channel1, channel2 := channelsByName[tChannel1.Name], channelsByName[tChannel2.Name]
+	assert.Equal(t, null.StringFrom(pkg1.ID), channel1.PackageID)
+	assert.False(t, channel2.PackageID.Valid)
+	assert.Equal(t, StringArray{channel1.ID}, pkg2.ChannelsBlacklist)
+
+	groupsByName := make(map[string]*Group)
+	for _, group := range app.Groups {
+		groupsByName[group.Name] = group
+	}
+	require.Len(t, groupsByName, 2)
+	group := groupsByName[tGroup.Name]
+	assert.NotEqual(t, tGroup.ID, group.ID)
+	assert.Equal(t, group.ID, group.Track)
+	assert.Equal(t, null.StringFrom(channel1.ID), group.ChannelID)
+	assert.Equal(t, null.StringFrom(pkg1.ID), group.LastKnownGoodPackageID)
+	assert.True(t, group.PolicyPaused)
+	assert.True(t, group.PolicyUpdatesEnabled)
+	assert.Equal(t, 2, group.PolicyMaxUpdatesPerPeriod)
+	assert.ElementsMatch(t, []ChannelWeight{{ChannelID: channel1.ID, Weight: 3}, {ChannelID: channel2.ID, Weight: 1}}, group.ChannelWeights)
+	groupStable := groupsByName["group_stable"]
+	assert.Equal(t, "stable", groupStable.Track)
+	assert.Equal(t, null.StringFrom(channel2.ID), groupStable.ChannelID)
+
+	// Exporting the imported application gives back the same configuration.
+	data2, err := a.ExportApp(app.ID)
+	require.NoError(t, err)
+	export, export2 := &AppExport{}, &AppExport{}
+	require.NoError(t, json.Unmarshal(data, export))
+	require.NoError(t, json.Unmarshal(data2, export2))
+	assert.Equal(t, export.Name, export2.Name)
+	assert.Len(t, export2.Packages, len(export.Packages))
+	assert.Len(t, export2.Channels, len(export.Channels))
+	assert.Len(t, export2.Groups, len(export.Groups))
+}
+
+func TestImportApp_Invalid(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+
+	_, err := a.ImportAppToTeam([]byte(`{"version": 2, "name": "test_app"}`), tTeam.ID)
+	assert.Equal(t, ErrUnsupportedAppExportVersion, err)
+
+	_, err = a.ImportAppToTeam([]byte(`{"version": 1, "name": "test_app", "channels": [{"id": "c", "name": "channel", "color": "blue", "package_id": "unknown"}]}`), tTeam.ID)
+	assert.Equal(t, ErrInvalidAppExport, err)
+
+	// The partially imported application is removed.
+	apps, err := a.GetApps(tTeam.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, apps)
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -12,6 +15,15 @@ const (
 	flatcarAppID = "e96281a6-d1af-4bde-9a0a-97b76e56dc57"
 )
 
+var (
+	// ErrAppDeleted error indicates that the application has been deleted.
+	ErrAppDeleted = errors.New("nebraska: application deleted")
+
+	// ErrInvalidInstanceRetention error indicates that the instance retention
+	// period provided is not a positive number of days.
+	ErrInvalidInstanceRetention = newValidationError("nebraska: invalid instance retention period")
+)
+
 // Application represents a Nebraska application instance.
 type Application struct {
 	ID          string     `db:"id" json:"id"`
@@ -21,6 +33,16 @@ type Application struct {
 	TeamID      string     `db:"team_id" json:"-"`
 	Groups      []*Group   `db:"groups" json:"groups"`
 	Channels    []*Channel `db:"channels" json:"channels"`
+	DeletedAt   null.Time  `db:"deleted_at" json:"-"`
+
+	// InstanceRetentionDays overrides the default period after which the
+	// instances that stopped checking for updates are deleted.
+	InstanceRetentionDays null.Int `db:"instance_retention_days" json:"instance_retention_days"`
+
+	// DisablePingPersistence makes the pings of the application's instances
+	// be acknowledged without being stored, to reduce the write load. Their
+	// events and update checks are still processed.
+	DisablePingPersistence bool `db:"disable_ping_persistence" json:"disable_ping_persistence"`
 
 	Instances struct {
 		Count int `db:"count" json:"count"`
@@ -29,18 +51,44 @@ type Application struct {
 
 // AddApp registers the provided application.
 func (api *API) AddApp(app *Application) (*Application, error) {
+	if err := api.checkTeam(app.TeamID); err != nil {
+		return nil, err
+	}
+	if app.InstanceRetentionDays.Valid && app.InstanceRetentionDays.Int64 <= 0 {
+		return nil, ErrInvalidInstanceRetention
+	}
+
 	query, _, err := goqu.Insert("application").
-		Cols("name", "description", "team_id").
-		Vals(goqu.Vals{app.Name, app.Description, app.TeamID}).
+		Cols("name", "description", "team_id", "instance_retention_days", "disable_ping_persistence").
+		Vals(goqu.Vals{app.Name, app.Description, app.TeamID, app.InstanceRetentionDays, app.DisablePingPersistence}).
 		Returning(goqu.T("application").All()).
 		ToSQL()
 	if err != nil {
 		return nil, err
 	}
-	err = api.db.QueryRowx(query).StructScan(app)
+	tx, err := api.db.Beginx()
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AddApp - could not roll back")
+		}
+	}()
+	err = tx.QueryRowx(query).StructScan(app)
+	if err != nil {
+		return nil, wrapUniqueViolation(err, "application %q", app.Name)
+	}
+	appAfterAdd, err := getAppAuditSnapshot(tx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityCreated, auditEntityApplication, app.ID, app.ID, nil, appAfterAdd); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return app, nil
 }
@@ -49,6 +97,11 @@ func (api *API) AddApp(app *Application) (*Application, error) {
 // channels from an existing application. Channels' packages will be set to null
 // as packages won't be cloned.
 func (api *API) AddAppCloning(app *Application, sourceAppID string) (*Application, error) {
+	if sourceAppID != "" {
+		if err := api.checkAppTeam(sourceAppID); err != nil {
+			return nil, err
+		}
+	}
 	app, err := api.AddApp(app)
 	if err != nil {
 		return nil, err
@@ -97,11 +150,20 @@ func (api *API) AddAppCloning(app *Application, sourceAppID string) (*Applicatio
 // UpdateApp updates an existing application using the content of the
 // application provided.
 func (api *API) UpdateApp(app *Application) error {
+	if err := api.checkAppTeam(app.ID); err != nil {
+		return err
+	}
+	if app.InstanceRetentionDays.Valid && app.InstanceRetentionDays.Int64 <= 0 {
+		return ErrInvalidInstanceRetention
+	}
+
 	query, _, err := goqu.Update("application").
 		Set(
 			goqu.Record{
-				"name":        app.Name,
-				"description": app.Description,
+				"name":                     app.Name,
+				"description":              app.Description,
+				"instance_retention_days":  app.InstanceRetentionDays,
+				"disable_ping_persistence": app.DisablePingPersistence,
 			},
 		).
 		Where(goqu.C("id").Eq(app.ID)).
@@ -109,7 +171,91 @@ func (api *API) UpdateApp(app *Application) error {
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("UpdateApp - could not roll back")
+		}
+	}()
+	appBeforeUpdate, err := getAppAuditSnapshot(tx, app.ID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return wrapUniqueViolation(err, "application %q", app.Name)
+	}
+	appAfterUpdate, err := getAppAuditSnapshot(tx, app.ID)
+	if err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityUpdated, auditEntityApplication, app.ID, app.ID, appBeforeUpdate, appAfterUpdate); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteApp marks the application identified by the id provided as deleted.
+// Deleted applications are hidden from listings and lookups, and their
+// instances can't get updates anymore, but they can be restored with
+// RestoreApp until they are purged.
+func (api *API) DeleteApp(appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("DeleteApp - could not roll back")
+		}
+	}()
+	appBeforeDelete, err := getAppAuditSnapshot(tx, appID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
+	if err := api.setAppDeletedAt(tx, appID, null.TimeFrom(api.nowUTC())); err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityDeleted, auditEntityApplication, appID, appID, appBeforeDelete, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreApp restores the deleted application identified by the id provided.
+func (api *API) RestoreApp(appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	return api.setAppDeletedAt(api.db, appID, null.Time{})
+}
+
+// setAppDeletedAt sets the deletion time of the application identified by the
+// id provided, marking it as deleted or restoring it when the time is null.
+func (api *API) setAppDeletedAt(e sqlx.Execer, appID string, deletedAt null.Time) error {
+	// Only deleting applications that aren't deleted and restoring
+	// those that are counts as a change.
+	deletedCond := goqu.C("deleted_at").IsNotNull()
+	if deletedAt.Valid {
+		deletedCond = goqu.C("deleted_at").IsNull()
+	}
+	query, _, err := goqu.Update("application").
+		Set(goqu.Record{"deleted_at": deletedAt}).
+		Where(goqu.C("id").Eq(appID), deletedCond).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := e.Exec(query)
 	if err != nil {
 		return err
 	}
@@ -120,16 +266,40 @@ func (api *API) UpdateApp(app *Application) error {
 	if rowsAffected == 0 {
 		return ErrNoRowsAffected
 	}
+
 	return nil
 }
 
-// DeleteApp removes the application identified by the id provided.
-func (api *API) DeleteApp(appID string) error {
-	query, _, err := goqu.Delete("application").Where(goqu.C("id").Eq(appID)).ToSQL()
+// PurgeApp removes the application identified by the id provided, together
+// with its groups, channels, packages and activity, whether it was deleted
+// or not. Its audit log entries are kept.
+func (api *API) PurgeApp(appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("PurgeApp - could not roll back")
+		}
+	}()
+	query, _, err := goqu.Delete("activity").
+		Where(goqu.C("application_id").Eq(appID), goqu.C("entity_type").IsNull()).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	query, _, err = goqu.Delete("application").Where(goqu.C("id").Eq(appID)).ToSQL()
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
+	result, err := tx.Exec(query)
 	if err != nil {
 		return err
 	}
@@ -141,14 +311,62 @@ func (api *API) DeleteApp(appID string) error {
 		return ErrNoRowsAffected
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	api.updateCachedGroups()
+
 	return nil
 }
 
+// isAppDeleted checks if the application identified by the id provided has
+// been deleted.
+func (api *API) isAppDeleted(appID string) (bool, error) {
+	query, _, err := goqu.From("application").
+		Select(goqu.C("deleted_at").IsNotNull()).
+		Where(goqu.C("id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+	var deleted bool
+	if err := api.db.QueryRow(query).Scan(&deleted); err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// isPingPersistenceDisabled checks if the pings of the instances of the
+// application provided must not be stored. Unknown applications are left for
+// the registration of the instance to reject.
+func (api *API) isPingPersistenceDisabled(appID string) (bool, error) {
+	if _, err := uuid.Parse(appID); err != nil {
+		return false, nil
+	}
+	query, _, err := goqu.From("application").
+		Select("disable_ping_persistence").
+		Where(goqu.C("id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+	var disabled bool
+	switch err := api.db.QueryRow(query).Scan(&disabled); err {
+	case nil, sql.ErrNoRows:
+		return disabled, nil
+	default:
+		return false, err
+	}
+}
+
 // GetApp returns the application identified by the id provided.
 func (api *API) GetApp(appID string) (*Application, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	var app Application
 	query, _, err := goqu.From("application").
-		Where(goqu.C("id").Eq(appID)).ToSQL()
+		Where(goqu.C("id").Eq(appID), goqu.C("deleted_at").IsNull()).ToSQL()
 	if err != nil {
 		return nil, err
 	}
@@ -167,7 +385,7 @@ func (api *API) GetApp(appID string) (*Application, error) {
 	} else {
 		return nil, err
 	}
-	app.Instances.Count, err = api.getInstanceCount(app.ID, "", validityInterval)
+	app.Instances.Count, err = api.getInstanceCount(app.ID, "", api.activeInterval(0))
 	if err != nil {
 		return nil, err
 	}
@@ -176,8 +394,10 @@ func (api *API) GetApp(appID string) (*Application, error) {
 
 // GetApps returns all applications that belong to the team id provided.
 func (api *API) GetApps(teamID string, page, perPage uint64) ([]*Application, error) {
+	if err := api.checkTeam(teamID); err != nil {
+		return nil, err
+	}
 	page, perPage = validatePaginationParams(page, perPage)
-	var apps []*Application
 	limit, offset := sqlPaginate(page, perPage)
 	query, _, err := api.appsQuery().
 		Where(goqu.C("team_id").Eq(teamID)).
@@ -187,6 +407,53 @@ func (api *API) GetApps(teamID string, page, perPage uint64) ([]*Application, er
 	if err != nil {
 		return nil, err
 	}
+	return api.getAppsFromQuery(query)
+}
+
+// GetAppsByTeam returns a page of the applications that belong to the team id
+// provided, ordered by name, together with the total number of applications
+// the team has.
+func (api *API) GetAppsByTeam(teamID string, limit, offset int) ([]*Application, int, error) {
+	if err := api.checkTeam(teamID); err != nil {
+		return nil, 0, err
+	}
+	if limit < 1 {
+		limit = int(defaultPerPage)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	countQuery, _, err := goqu.From("application").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("team_id").Eq(teamID), goqu.C("deleted_at").IsNull()).
+		ToSQL()
+	if err != nil {
+		return nil, 0, err
+	}
+	total := 0
+	if err := api.db.QueryRow(countQuery).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query, _, err := api.appsQuery().
+		Where(goqu.C("team_id").Eq(teamID)).
+		Order(goqu.I("name").Asc(), goqu.I("id").Asc()).
+		Limit(uint(limit)).
+		Offset(uint(offset)).
+		ToSQL()
+	if err != nil {
+		return nil, 0, err
+	}
+	apps, err := api.getAppsFromQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return apps, total, nil
+}
+
+func (api *API) getAppsFromQuery(query string) ([]*Application, error) {
+	var apps []*Application
 	rows, err := api.db.Queryx(query)
 	if err != nil {
 		return nil, err
@@ -210,7 +477,7 @@ func (api *API) GetApps(teamID string, page, perPage uint64) ([]*Application, er
 		} else {
 			return nil, err
 		}
-		app.Instances.Count, err = api.getInstanceCount(app.ID, "", validityInterval)
+		app.Instances.Count, err = api.getInstanceCount(app.ID, "", api.activeInterval(0))
 		if err != nil {
 			return nil, err
 		}
@@ -222,13 +489,99 @@ func (api *API) GetApps(teamID string, page, perPage uint64) ([]*Application, er
 	return apps, nil
 }
 
-// appsQuery returns a SelectDataset prepared to return all applications.
+// SetAppAllowedEventTypes replaces the set of event types the instances of
+// the application provided are allowed to post. An empty set allows all event
+// types.
+func (api *API) SetAppAllowedEventTypes(appID string, eventTypes []int) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("SetAppAllowedEventTypes - could not roll back")
+		}
+	}()
+
+	query, _, err := goqu.Delete("application_allowed_event_type").
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	for _, eventType := range eventTypes {
+		query, _, err := goqu.Insert("application_allowed_event_type").
+			Cols("application_id", "event_type").
+			Vals(goqu.Vals{appID, eventType}).
+			OnConflict(goqu.DoNothing()).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAppAllowedEventTypes returns the event types the instances of the
+// application provided are allowed to post. An empty result means that all
+// event types are allowed.
+func (api *API) GetAppAllowedEventTypes(appID string) ([]int, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("application_allowed_event_type").
+		Select("event_type").
+		Where(goqu.C("application_id").Eq(appID)).
+		Order(goqu.C("event_type").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	eventTypes := []int{}
+	if err := api.db.Select(&eventTypes, query); err != nil {
+		return nil, err
+	}
+	return eventTypes, nil
+}
+
+// isEventTypeAllowed checks if the instances of the application provided are
+// allowed to post events of the given type.
+func (api *API) isEventTypeAllowed(appID string, eventType int) (bool, error) {
+	eventTypes, err := api.GetAppAllowedEventTypes(appID)
+	if err != nil {
+		return false, err
+	}
+	if len(eventTypes) == 0 {
+		return true, nil
+	}
+	for _, allowed := range eventTypes {
+		if allowed == eventType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// appsQuery returns a SelectDataset prepared to return all applications
+// that haven't been deleted.
 // This query is meant to be extended later in the methods using it to filter
 // by a specific application id, all applications that belong to a given team,
 // specify how to query the rows or their destination.
 func (api *API) appsQuery() *goqu.SelectDataset {
 	query := goqu.From("application").
-		Select("id", "name", "description", "created_ts").
+		Select("id", "name", "description", "created_ts", "instance_retention_days", "disable_ping_persistence").
+		Where(goqu.C("deleted_at").IsNull()).
 		Order(goqu.I("created_ts").Desc())
 	return query
 }
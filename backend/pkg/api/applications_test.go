@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"testing"
 
 	"github.com/google/uuid"
@@ -104,6 +105,55 @@ func TestDeleteApp(t *testing.T) {
 	assert.Error(t, err, "Trying to get deleted app.")
 }
 
+func TestDeleteApp_Lifecycle(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	assert.NoError(t, a.DeleteApp(tApp.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.DeleteApp(tApp.ID))
+
+	_, err := a.GetApp(tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	apps, err := a.GetApps(tTeam.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, apps, 0)
+	_, total, err := a.GetAppsByTeam(tTeam.ID, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	// The groups and channels of deleted apps are kept, but their instances
+	// can't register anymore.
+	_, err = a.GetGroup(tGroup.ID)
+	assert.NoError(t, err)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrAppDeleted, err)
+
+	assert.NoError(t, a.RestoreApp(tApp.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.RestoreApp(tApp.ID))
+
+	app, err := a.GetApp(tApp.ID)
+	assert.NoError(t, err)
+	assert.Len(t, app.Groups, 1)
+	assert.Len(t, app.Channels, 1)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	// The name of a deleted app can be reused.
+	assert.NoError(t, a.DeleteApp(tApp.ID))
+	_, err = a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.PurgeApp(tApp.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.RestoreApp(tApp.ID))
+	_, err = a.GetGroup(tGroup.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
 func TestGetApp(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -144,6 +194,48 @@ func TestGetApps(t *testing.T) {
 	assert.NoError(t, err, "should not have any error for non existing appID")
 }
 
+func TestGetAppsByTeam(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	_, _ = a.AddApp(&Application{Name: "test_app_c", TeamID: tTeam.ID})
+	_, _ = a.AddApp(&Application{Name: "test_app_a", TeamID: tTeam.ID})
+	_, _ = a.AddApp(&Application{Name: "test_app_e", TeamID: tTeam.ID})
+	_, _ = a.AddApp(&Application{Name: "test_app_b", TeamID: tTeam.ID})
+	_, _ = a.AddApp(&Application{Name: "test_app_d", TeamID: tTeam.ID})
+	_, _ = a.AddApp(&Application{Name: "test_app_other", TeamID: tTeam2.ID})
+
+	apps, total, err := a.GetAppsByTeam(tTeam.ID, 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	if assert.Len(t, apps, 2) {
+		assert.Equal(t, "test_app_a", apps[0].Name)
+		assert.Equal(t, "test_app_b", apps[1].Name)
+	}
+
+	apps, total, err = a.GetAppsByTeam(tTeam.ID, 2, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	if assert.Len(t, apps, 2) {
+		assert.Equal(t, "test_app_c", apps[0].Name)
+		assert.Equal(t, "test_app_d", apps[1].Name)
+	}
+
+	apps, total, err = a.GetAppsByTeam(tTeam.ID, 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	if assert.Len(t, apps, 1) {
+		assert.Equal(t, "test_app_e", apps[0].Name)
+	}
+
+	apps, total, err = a.GetAppsByTeam(tTeam.ID, 2, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Len(t, apps, 0)
+}
+
 func TestGetAppsFiltered(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -67,6 +67,12 @@ func (a Arch) IsValid() bool {
 	return ok
 }
 
+// archMatches checks if a package built for pkgArch can be installed in an
+// instance of the given arch, treating ArchAll on either side as a wildcard.
+func archMatches(pkgArch, arch Arch) bool {
+	return pkgArch == ArchAll || arch == ArchAll || pkgArch == arch
+}
+
 func pkgArchFromIdxString(s string, idx int) (Arch, error) {
 	if s == "" {
 		return ArchAll, ErrInvalidArch
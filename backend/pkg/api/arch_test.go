@@ -77,3 +77,10 @@ func TestArch(t *testing.T) {
 		}
 	}
 }
+
+func TestArchMatches(t *testing.T) {
+	assert.True(t, archMatches(ArchAll, ArchAArch64))
+	assert.True(t, archMatches(ArchAMD64, ArchAll))
+	assert.True(t, archMatches(ArchAMD64, ArchAMD64))
+	assert.False(t, archMatches(ArchAMD64, ArchAArch64))
+}
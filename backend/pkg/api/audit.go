@@ -0,0 +1,275 @@
+package api
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	auditEntityApplication = "application"
+	auditEntityGroup       = "group"
+	auditEntityChannel     = "channel"
+	auditEntityPackage     = "package"
+)
+
+// AuditFieldChange represents the values a field of an entity had before and
+// after a change. Before is nil for created entities and After is nil for
+// deleted ones.
+type AuditFieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditDiff represents the fields of an entity that were modified by a
+// change, indexed by their name.
+type AuditDiff map[string]AuditFieldChange
+
+// Scan implements the sql.Scanner interface.
+func (d *AuditDiff) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, d)
+	case string:
+		return json.Unmarshal([]byte(src), d)
+	case nil:
+		*d = nil
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to AuditDiff", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (d AuditDiff) Value() (driver.Value, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// AuditEntry represents an audit log entry, recording a change made to an
+// application, group, channel or package.
+type AuditEntry struct {
+	ID         int         `db:"id" json:"id"`
+	CreatedTs  time.Time   `db:"created_ts" json:"created_ts"`
+	Class      int         `db:"class" json:"class"`
+	Actor      null.String `db:"actor" json:"actor"`
+	AppID      null.String `db:"application_id" json:"app_id"`
+	EntityType string      `db:"entity_type" json:"entity_type"`
+	EntityID   string      `db:"entity_id" json:"entity_id"`
+	Diff       AuditDiff   `db:"diff" json:"diff"`
+}
+
+// AuditLogQueryParams represents a helper structure used to pass a set of
+// parameters when querying audit log entries. EntityType can be one of
+// application, group, channel or package.
+type AuditLogQueryParams struct {
+	TeamID     string    `db:"team_id"`
+	Actor      string    `db:"actor"`
+	EntityType string    `db:"entity_type"`
+	EntityID   string    `db:"entity_id"`
+	Start      time.Time `db:"start"`
+	End        time.Time `db:"end"`
+	Page       uint64    `json:"page"`
+	PerPage    uint64    `json:"perpage"`
+}
+
+// GetAuditLog returns the audit log entries that match the specified criteria
+// in the query parameters, most recent first.
+func (api *API) GetAuditLog(p AuditLogQueryParams) ([]*AuditEntry, error) {
+	p.Page, p.PerPage = validatePaginationParams(p.Page, p.PerPage)
+
+	query := goqu.From(goqu.T("activity").As("a")).
+		Select("a.id", "a.created_ts", "a.class", "a.actor", "a.application_id", "a.entity_type", "a.entity_id", "a.diff").
+		Where(goqu.I("a.entity_type").IsNotNull())
+
+	if p.TeamID != "" {
+		query = query.Where(goqu.I("a.team_id").Eq(p.TeamID))
+	}
+	if p.Actor != "" {
+		query = query.Where(goqu.I("a.actor").Eq(p.Actor))
+	}
+	if p.EntityType != "" {
+		query = query.Where(goqu.I("a.entity_type").Eq(p.EntityType))
+	}
+	if p.EntityID != "" {
+		query = query.Where(goqu.I("a.entity_id").Eq(p.EntityID))
+	}
+	if !p.Start.IsZero() {
+		query = query.Where(goqu.I("a.created_ts").Gte(p.Start.UTC()))
+	}
+	if !p.End.IsZero() {
+		query = query.Where(goqu.I("a.created_ts").Lt(p.End.UTC()))
+	}
+	limit, offset := sqlPaginate(p.Page, p.PerPage)
+	sqlQuery, _, err := query.
+		Order(goqu.I("a.created_ts").Desc(), goqu.I("a.id").Desc()).
+		Limit(limit).
+		Offset(offset).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	entries := []*AuditEntry{}
+	if err := api.readDB().Select(&entries, sqlQuery); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// newAuditDiff returns the fields whose values differ between the JSON
+// representations of the entity before and after the change. Either of them
+// can be nil, when the entity was created or deleted.
+func newAuditDiff(before, after interface{}) (AuditDiff, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := AuditDiff{}
+	for name, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[name]
+		if !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[name] = AuditFieldChange{Before: beforeValue, After: afterValue}
+		}
+	}
+	for name, afterValue := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = AuditFieldChange{After: afterValue}
+		}
+	}
+	return diff, nil
+}
+
+// auditFields returns the fields of the JSON representation of the entity
+// provided.
+func auditFields(entity interface{}) (map[string]interface{}, error) {
+	if v := reflect.ValueOf(entity); !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return nil, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// recordAuditEntry records in the audit log, within the transaction making
+// the change, the change made by the api's actor to the entity provided,
+// given its state before and after the change. The entry is attributed to
+// the team owning the application, so it's kept when the application is
+// purged.
+func (api *API) recordAuditEntry(tx *sqlx.Tx, class int, entityType, entityID, appID string, before, after interface{}) error {
+	diff, err := newAuditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	diffValue, err := diff.Value()
+	if err != nil {
+		return err
+	}
+	query, _, err := goqu.Insert("activity").
+		Cols("class", "severity", "application_id", "team_id", "actor", "entity_type", "entity_id", "diff").
+		FromQuery(goqu.From("application").
+			Select(goqu.V(class), goqu.V(activityInfo), goqu.C("id"), goqu.C("team_id"), goqu.V(null.NewString(api.actor, api.actor != "")), goqu.V(entityType), goqu.V(entityID), goqu.L("?::jsonb", diffValue)).
+			Where(goqu.C("id").Eq(appID))).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(query)
+	return err
+}
+
+// getAppAuditSnapshot returns the application identified by the id provided,
+// as compared by its audit entries. Like the other audit snapshots, it's read
+// within the transaction making the change and it doesn't include the
+// entities it refers to.
+func getAppAuditSnapshot(tx *sqlx.Tx, appID string) (*Application, error) {
+	query, _, err := goqu.From("application").
+		Where(goqu.C("id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var app Application
+	if err := tx.QueryRowx(query).StructScan(&app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// getChannelAuditSnapshot returns the channel identified by the id provided,
+// as compared by its audit entries.
+func getChannelAuditSnapshot(tx *sqlx.Tx, channelID string) (*Channel, error) {
+	query, _, err := goqu.From("channel").
+		Where(goqu.C("id").Eq(channelID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var channel Channel
+	if err := tx.QueryRowx(query).StructScan(&channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// getGroupAuditSnapshot returns the group identified by the id provided,
+// with its update windows and channel weights, as compared by its audit
+// entries.
+func (api *API) getGroupAuditSnapshot(tx *sqlx.Tx, groupID string) (*Group, error) {
+	query, _, err := goqu.From("groups").
+		Where(goqu.C("id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var group Group
+	if err := tx.QueryRowx(query).StructScan(&group); err != nil {
+		return nil, err
+	}
+	if group.PolicyUpdateWindows, err = api.getGroupUpdateWindows(tx, group.ID); err != nil {
+		return nil, err
+	}
+	if group.ChannelWeights, err = api.getGroupChannelWeights(tx, group.ID); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// getPackageAuditSnapshot returns the package identified by the id provided,
+// with its Flatcar action, as compared by its audit entries.
+func (api *API) getPackageAuditSnapshot(tx *sqlx.Tx, pkgID string) (*Package, error) {
+	query, _, err := api.packagesQuery().
+		Where(goqu.C("id").Eq(pkgID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var pkg Package
+	if err := tx.QueryRowx(query).StructScan(&pkg); err != nil {
+		return nil, err
+	}
+	pkg.FlatcarAction, err = api.getFlatcarAction(tx, pkg.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &pkg, nil
+}
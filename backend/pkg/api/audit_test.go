@@ -0,0 +1,100 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetAuditLog(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	alice := a.WithActor("alice")
+	bob := a.WithActor("bob")
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, err := alice.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	require.NoError(t, err)
+	tPkg, err := alice.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	require.NoError(t, err)
+	tChannel, err := alice.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	require.NoError(t, err)
+	tGroup, err := alice.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+
+	tChannel.Name = "renamed_channel"
+	require.NoError(t, bob.UpdateChannel(tChannel))
+	require.NoError(t, bob.DeleteGroup(tGroup.ID))
+
+	entries, err := a.GetAuditLog(AuditLogQueryParams{TeamID: tTeam.ID})
+	require.NoError(t, err)
+	assert.Len(t, entries, 6)
+
+	entries, err = a.GetAuditLog(AuditLogQueryParams{TeamID: tTeam.ID, Actor: "alice"})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, activityEntityCreated, entries[0].Class)
+	assert.Equal(t, auditEntityGroup, entries[0].EntityType)
+	assert.Equal(t, null.StringFrom(tApp.ID), entries[3].AppID)
+	assert.Equal(t, auditEntityApplication, entries[3].EntityType)
+	assert.Equal(t, tApp.ID, entries[3].EntityID)
+	assert.Equal(t, AuditFieldChange{Before: nil, After: "test_app"}, entries[3].Diff["name"])
+
+	entries, err = a.GetAuditLog(AuditLogQueryParams{EntityType: auditEntityPackage, EntityID: tPkg.ID})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditFieldChange{Before: nil, After: "12.1.0"}, entries[0].Diff["version"])
+
+	// Updating a single field records that field only, besides the revision.
+	entries, err = a.GetAuditLog(AuditLogQueryParams{EntityType: auditEntityChannel, EntityID: tChannel.ID, Actor: "bob"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, activityEntityUpdated, entries[0].Class)
+	assert.Equal(t, null.StringFrom("bob"), entries[0].Actor)
+	assert.Equal(t, AuditFieldChange{Before: "test_channel", After: "renamed_channel"}, entries[0].Diff["name"])
+	assert.NotContains(t, entries[0].Diff, "color")
+
+	entries, err = a.GetAuditLog(AuditLogQueryParams{EntityType: auditEntityGroup, EntityID: tGroup.ID, Actor: "bob"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, activityEntityDeleted, entries[0].Class)
+	assert.Equal(t, AuditFieldChange{Before: "test_group", After: nil}, entries[0].Diff["name"])
+
+	entries, err = a.GetAuditLog(AuditLogQueryParams{TeamID: tTeam.ID, End: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Audit log entries aren't listed as regular activity.
+	activityEntries, err := a.GetActivity(tTeam.ID, ActivityQueryParams{})
+	require.NoError(t, err)
+	assert.Empty(t, activityEntries)
+
+	// Purging the application keeps its audit log entries.
+	require.NoError(t, a.PurgeApp(tApp.ID))
+	entries, err = a.GetAuditLog(AuditLogQueryParams{TeamID: tTeam.ID})
+	require.NoError(t, err)
+	require.Len(t, entries, 6)
+	assert.False(t, entries[0].AppID.Valid)
+	assert.Equal(t, tGroup.ID, entries[0].EntityID)
+}
+
+func TestNewAuditDiff(t *testing.T) {
+	before := &Channel{ID: "1", Name: "channel", Color: "blue"}
+	after := &Channel{ID: "1", Name: "channel", Color: "red"}
+
+	diff, err := newAuditDiff(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, AuditDiff{"color": {Before: "blue", After: "red"}}, diff)
+
+	diff, err = newAuditDiff(before, nil)
+	require.NoError(t, err)
+	assert.Equal(t, AuditFieldChange{Before: "channel"}, diff["name"])
+
+	diff, err = newAuditDiff(before, before)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
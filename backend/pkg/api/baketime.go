@@ -0,0 +1,41 @@
+package api
+
+import (
+	"time"
+)
+
+// normalizeMinBakeTime checks that the minimum bake time of the group
+// provided, if any, is a positive interval, clearing it when it's empty.
+func (api *API) normalizeMinBakeTime(group *Group) error {
+	if !group.PolicyMinBakeTime.Valid {
+		return nil
+	}
+	if group.PolicyMinBakeTime.String == "" {
+		group.PolicyMinBakeTime.Valid = false
+		return nil
+	}
+	return api.validatePolicyInterval(group.PolicyMinBakeTime.String)
+}
+
+// isPackageBaking checks if the package provided was added more recently than
+// the minimum bake time of the group provided, in which case it must not be
+// offered to the group's instances yet.
+func (api *API) isPackageBaking(group *Group, pkg *Package) (bool, error) {
+	if !group.PolicyMinBakeTime.Valid {
+		return false, nil
+	}
+	bakeTime, err := api.intervalDuration(group.PolicyMinBakeTime.String)
+	if err != nil {
+		return false, err
+	}
+	return api.nowUTC().Before(pkg.CreatedTs.Add(bakeTime)), nil
+}
+
+// intervalDuration returns the duration of the postgres interval provided.
+func (api *API) intervalDuration(interval string) (time.Duration, error) {
+	var seconds float64
+	if err := api.db.QueryRow("SELECT extract(epoch from $1::interval)", interval).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
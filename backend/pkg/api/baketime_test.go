@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestAddGroup_MinBakeTime(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	group, err := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("6 hours")})
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("6 hours"), group.PolicyMinBakeTime)
+
+	group, err = a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("")})
+	require.NoError(t, err)
+	assert.False(t, group.PolicyMinBakeTime.Valid, "Empty bake times are cleared.")
+
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("not an interval")})
+	assert.Equal(t, ErrInvalidPolicyInterval, err)
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("-1 hour")})
+	assert.Equal(t, ErrInvalidPolicyInterval, err)
+}
+
+func TestGetUpdatePackage_MinBakeTime(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("6 hours")})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, reason, err := a.GetUpdatePackageWithReason(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	assert.Equal(t, UpdateReasonBakeTime, reason)
+
+	// Groups without a bake time get the package right away.
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup2.ID)
+	assert.NoError(t, err)
+
+	clock.Advance(5 * time.Hour)
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err, "The package is still baking.")
+
+	clock.Advance(time.Hour + time.Minute)
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+}
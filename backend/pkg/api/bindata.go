@@ -1,6 +1,6 @@
 // Code generated by go-bindata. DO NOT EDIT.
 // sources:
-// db/drop_all_tables.sql (781B)
+// db/drop_all_tables.sql (1.491kB)
 // db/sample_data.sql (16.109kB)
 // db/migrations/0001_initial.sql (7.125kB)
 // db/migrations/0002_event_data.sql (729B)
@@ -15,6 +15,56 @@
 // db/migrations/0011_add_composite_indexes.sql (760B)
 // db/migrations/0012_drop_unused_indexes.sql (696B)
 // db/migrations/0013_add_stats_indexes.sql (426B)
+// db/migrations/0014_add_group_min_healthy_instances.sql (225B)
+// db/migrations/0015_add_application_allowed_event_types.sql (286B)
+// db/migrations/0016_add_group_max_version_spread.sql (216B)
+// db/migrations/0017_add_group_rollout_percentage.sql (210B)
+// db/migrations/0018_add_group_max_concurrent_downloads.sql (234B)
+// db/migrations/0019_add_group_policy_paused.sql (157B)
+// db/migrations/0020_add_group_auto_rollback.sql (779B)
+// db/migrations/0021_add_package_mirrors.sql (278B)
+// db/migrations/0022_add_instance_ip_index.sql (154B)
+// db/migrations/0023_add_webhooks.sql (380B)
+// db/migrations/0024_add_package_min_previous_version.sql (155B)
+// db/migrations/0025_add_application_deleted_at.sql (564B)
+// db/migrations/0026_add_group_pinned_version.sql (155B)
+// db/migrations/0027_add_instance_system_info.sql (365B)
+// db/migrations/0028_add_group_update_windows.sql (411B)
+// db/migrations/0029_application_instance_retention.sql (196B)
+// db/migrations/0030_flatcar_action_payload_signature.sql (317B)
+// db/migrations/0031_group_update_timeout_action.sql (426B)
+// db/migrations/0032_channel_package_revision.sql (247B)
+// db/migrations/0033_add_group_channel_weights.sql (417B)
+// db/migrations/0034_add_instance_registration_rules.sql (357B)
+// db/migrations/0035_add_package_deltas.sql (609B)
+// db/migrations/0036_add_instance_labels.sql (276B)
+// db/migrations/0037_add_activity_audit.sql (664B)
+// db/migrations/0038_add_package_oci_image.sql (347B)
+// db/migrations/0039_add_group_force_update_after.sql (162B)
+// db/migrations/0040_add_instances_sort_indexes.sql (381B)
+// db/migrations/0041_add_channel_version_constraint.sql (151B)
+// db/migrations/0042_add_instance_update_duration.sql (600B)
+// db/migrations/0043_add_instance_event_fingerprint.sql (410B)
+// db/migrations/0044_add_group_max_concurrent_updates.sql (228B)
+// db/migrations/0045_add_instance_application_quarantined.sql (254B)
+// db/migrations/0046_add_package_severity.sql (432B)
+// db/migrations/0047_add_instance_region.sql (129B)
+// db/migrations/0048_add_event_daily_aggregate.sql (400B)
+// db/migrations/0049_add_instance_package_override.sql (454B)
+// db/migrations/0050_add_group_rollout_cohorts.sql (373B)
+// db/migrations/0051_add_group_min_bake_time.sql (187B)
+// db/migrations/0052_add_application_disable_ping_persistence.sql (189B)
+// db/migrations/0053_add_instance_first_seen_and_version_changed_at.sql (516B)
+// db/migrations/0054_add_group_rollout_schedule.sql (253B)
+// db/migrations/0055_add_instance_note.sql (117B)
+// db/migrations/0056_add_track_aliases.sql (373B)
+// db/migrations/0057_add_group_policy_observe_only.sql (462B)
+// db/migrations/0058_add_package_artifacts.sql (473B)
+// db/migrations/0059_add_group_safe_mode_failure_threshold.sql (428B)
+// db/migrations/0060_add_group_rollout_order.sql (254B)
+// db/migrations/0061_add_channel_package_history_details.sql (590B)
+// db/migrations/0062_keep_audit_entries_of_purged_apps.sql (924B)
+// db/migrations/0063_allow_channel_package_history_without_package.sql (246B)
 
 package api
 
@@ -83,7 +133,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _dbDrop_all_tablesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x92\x3d\x6e\xc3\x30\x0c\x85\xf7\x9c\x42\x5b\x27\x9f\x20\x5b\xd1\xb1\x77\x10\x9e\x69\xc6\x21\xa2\x50\x82\x48\xa7\xf5\xed\x8b\xfc\xb4\x43\xd0\x80\x9e\xf5\xf1\x23\xc5\xc7\xa9\xd7\x96\x1c\x63\xe1\x24\x87\xc4\xdf\x62\x6e\xc9\x19\xe7\x44\x30\xc2\xc4\xfb\xdd\xbf\xc8\x62\xdc\x2d\x60\xd0\x5a\x11\x82\x4b\xd5\x80\x6c\xa0\x13\x66\x0e\xa8\x43\x81\x13\x7a\x06\x6d\x50\xd2\x11\xaa\x5c\x02\x6a\xee\x75\x69\xd1\x3f\x44\xcd\xa1\x14\x8d\xf7\x8b\x65\x73\xf8\xb2\x55\x9a\xb7\x6f\xe9\xa9\x41\x3e\x8a\x79\xed\x6b\x50\xc5\x17\x56\xcf\xbe\xb6\x68\xfe\x1b\x18\x45\x4a\x2e\x17\xf1\xa8\xe7\x23\xcf\xfc\x08\x21\x8f\x05\x74\x2a\x62\x91\x7e\x82\x63\x84\x71\x3e\xcb\xdc\x6f\x2b\xb1\xfd\x6e\x18\xd2\x27\xcf\xa0\xf5\x8e\xdb\x95\xff\xe2\xb7\xce\xe9\xea\x68\xa2\xf3\xdf\x83\x26\x24\xad\x3a\xdc\xcb\x79\x4a\x1f\xef\x2f\xae\xa3\x76\xae\xf6\x7c\x49\x3f\x01\x00\x00\xff\xff\xe6\x1e\x65\x85\x0d\x03\x00\x00")
+var _dbDrop_all_tablesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x94\x31\x8e\xe3\x30\x0c\x45\xfb\x9c\x42\xdd\x56\x39\x41\xba\xc5\x96\x7b\x07\xe1\x5b\xa2\x65\xc2\x8a\x24\x50\x74\x3c\xbe\xfd\xc0\x71\x3c\x45\x10\x40\x9a\xda\x8f\x22\x3f\xf9\x60\x2f\xb9\x18\xc5\x10\xc9\xf0\x68\xe8\x8b\xab\x56\xa3\x84\xbb\x71\xa8\x0e\x9e\x6e\x97\x8f\xc8\x52\x49\x6a\x83\x41\x29\x91\x1d\x94\x73\x6a\x90\x05\x6e\x46\xa0\x06\x35\x46\xa8\x83\x58\xb8\x8e\x27\xdd\x84\x94\x28\x36\xa8\x20\x79\x29\xad\x1c\x9c\xaa\x22\x39\xea\xc4\x6c\x55\xe8\xd2\xfb\xa8\xed\xdf\xd2\x5b\x03\x3b\x71\xd5\x2c\x5b\xa3\x8a\x1e\x94\xd4\xea\x56\x5a\xf3\x3f\xc1\x06\xb3\xaf\xfe\xc1\xba\xf5\xdd\xd3\xbe\x8e\x60\x87\x08\x37\x47\xae\xda\x6f\x8c\x45\x8c\x79\x25\x6f\xbb\xe7\x3f\x9b\x9d\xcd\xfb\xd6\x73\xd2\x77\x16\xc9\x4d\xa5\x57\x1a\xa6\x9c\xe7\x06\xf5\xb4\xca\x2e\xc5\x43\xc9\xae\x9c\x7c\x5e\xbb\x2a\xce\x04\x2b\x71\x98\xb4\xd7\x06\xa1\xc0\x55\xe5\x69\x90\x95\x25\x52\x67\x62\x4f\x51\xd1\xc9\x42\x94\x47\xb8\xee\x99\x5e\xd9\xfd\x22\xbf\x12\xfb\xb8\xf5\xc8\x29\x90\x14\xe1\xa6\x8e\x07\xef\xc1\x71\xb3\x08\x41\x28\x40\xa9\xb7\xd9\x99\x2d\x3f\x48\x84\x7d\xab\x4e\x05\x6e\xb6\x88\x8c\x96\x24\x1e\x8a\x01\x75\x77\x2a\x1c\xf9\xeb\xed\x72\xbd\x9a\xff\x14\xe0\xb6\x23\x44\xdd\x53\xac\xf4\x47\xc8\xec\xc9\x0a\xa7\xf0\xf3\x21\x19\x98\x94\xd3\xf5\x28\x27\x6f\xfe\xfd\xfd\xdc\xc8\x65\xa1\x5c\xdf\xff\x87\xdf\x03\x00\x11\x03\xe3\x59\xd3\x05\x00\x00")
 
 func dbDrop_all_tablesSqlBytes() ([]byte, error) {
 	return bindataRead(
@@ -98,8 +148,8 @@ func dbDrop_all_tablesSql() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "db/drop_all_tables.sql", size: 781, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xde, 0xcf, 0xbe, 0xfb, 0xd5, 0xe3, 0xea, 0xab, 0xec, 0x78, 0x43, 0x41, 0x2, 0x31, 0xfc, 0x52, 0x9a, 0x8b, 0x30, 0x84, 0xc8, 0xab, 0x83, 0x9c, 0x5b, 0x64, 0xf8, 0xbe, 0xbc, 0xfd, 0x0, 0x97}}
+	info := bindataFileInfo{name: "db/drop_all_tables.sql", size: 1491, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb8, 0x2, 0x85, 0xd3, 0xe0, 0xde, 0x7a, 0x45, 0xc5, 0x66, 0xf9, 0xed, 0xca, 0x95, 0xcc, 0x3b, 0xba, 0x8c, 0x2a, 0xa8, 0xc7, 0xd3, 0x70, 0xf, 0xf9, 0xf9, 0xc1, 0xd5, 0x7a, 0x2d, 0x5a, 0xd5}}
 	return a, nil
 }
 
@@ -383,6 +433,1006 @@ func dbMigrations0013_add_stats_indexesSql() (*asset, error) {
 	return a, nil
 }
 
+var _dbMigrations0014_add_group_min_healthy_instancesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\xb1\x0a\xc2\x30\x10\x06\xe0\x3d\x4f\xf1\x8f\x8a\x14\xba\x17\x9d\x7c\x05\xe7\x72\x26\x67\x1b\xbc\xde\x85\xe4\x82\xf4\xed\x5d\x1d\x44\x7c\x80\x0f\xbe\x61\xc0\x69\xcb\x4b\x25\x67\xdc\x4a\x08\x24\xce\x15\x4e\x77\x61\x2c\xd5\x7a\x69\xa0\x94\x10\x4d\xfa\xa6\x28\x26\x39\xee\xf3\x96\x75\x5e\x99\xc4\xd7\x7d\xce\xda\x9c\x34\x72\x43\x56\xe7\x85\x2b\xd4\x1c\xda\x45\x90\xf8\x41\x5d\x1c\x23\xe2\xca\xf1\x89\xc3\x4f\x7e\x39\x63\x3c\x4e\x21\x7c\x8e\xae\xf6\xd2\xaf\xa7\x54\xad\xfc\x93\x9a\xc2\x7b\x00\xc7\x09\x24\x2d\xe1\x00\x00\x00")
+
+func dbMigrations0014_add_group_min_healthy_instancesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0014_add_group_min_healthy_instancesSql,
+		"db/migrations/0014_add_group_min_healthy_instances.sql",
+	)
+}
+
+func dbMigrations0014_add_group_min_healthy_instancesSql() (*asset, error) {
+	bytes, err := dbMigrations0014_add_group_min_healthy_instancesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0014_add_group_min_healthy_instances.sql", size: 225, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xd7, 0x22, 0xf2, 0x61, 0xfc, 0x17, 0xbb, 0x26, 0xae, 0x8c, 0x43, 0x7e, 0x7b, 0x9d, 0x4b, 0x21, 0xc9, 0x92, 0x1e, 0x65, 0xc9, 0xdc, 0x54, 0xcb, 0x7a, 0x55, 0x27, 0x8c, 0x7, 0xc, 0x5a, 0xc2}}
+	return a, nil
+}
+
+var _dbMigrations0015_add_application_allowed_event_typesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x8f\xb1\x4e\xc4\x30\x10\x44\xeb\xec\x57\x4c\x99\x88\xdc\x17\x5c\xcb\x2f\x50\x47\xc6\x9e\x3b\xad\xf0\xad\xad\xcd\x86\x90\xbf\x47\x48\x08\x42\x45\x37\xc5\xcc\x1b\xbd\xcb\x05\x4f\x0f\xbd\x7b\x0a\xe2\xa5\x8b\x64\xe7\x57\x8c\xf4\x5a\x89\xd4\x7b\xd5\x9c\x42\x9b\x2d\xa9\xd6\xb6\xb3\x2c\x7c\xa7\xc5\x12\x47\x27\x46\x19\xce\x0d\x2d\xd8\x36\x2d\xb0\x16\xb0\xad\x56\x38\x6f\x74\x5a\xe6\x7a\x26\x61\xd4\x32\xa1\x19\x0a\x2b\x83\xc8\x69\xcd\xa9\x70\x96\xe1\x84\x56\x0b\xde\xe9\x3f\xac\x59\x86\xee\xfa\x48\x7e\xe0\x8d\x07\xc6\xbf\xbf\x33\x7e\xa7\x93\x4c\x57\x91\xb3\xd6\x73\xdb\x4d\xa4\x78\xeb\xdf\x5a\x7a\x03\x3f\x74\x8d\xf5\x1f\xc1\xab\x7c\x0e\x00\xde\x2c\x4f\x2e\x1e\x01\x00\x00")
+
+func dbMigrations0015_add_application_allowed_event_typesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0015_add_application_allowed_event_typesSql,
+		"db/migrations/0015_add_application_allowed_event_types.sql",
+	)
+}
+
+func dbMigrations0015_add_application_allowed_event_typesSql() (*asset, error) {
+	bytes, err := dbMigrations0015_add_application_allowed_event_typesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0015_add_application_allowed_event_types.sql", size: 286, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa3, 0x56, 0xe4, 0xe1, 0x2f, 0x5b, 0x83, 0x0, 0x76, 0x25, 0x5b, 0xe5, 0xb2, 0xf6, 0xc4, 0xe8, 0xe3, 0x6f, 0x69, 0x82, 0xa3, 0x75, 0xa9, 0xa2, 0x1e, 0xb7, 0x5c, 0x3f, 0xa0, 0xe1, 0xb7, 0xe4}}
+	return a, nil
+}
+
+var _dbMigrations0016_add_group_max_version_spreadSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xce\x3d\x0e\xc2\x30\x0c\x06\xd0\x3d\xa7\xf8\x46\x10\xaa\xd4\xbd\x82\x89\x2b\x30\x57\x26\x31\x25\xc2\x8d\x23\xc7\xe1\xe7\xf6\xac\x0c\x20\x0e\xf0\xa4\x37\x0c\xd8\xad\x79\x31\x72\xc6\xa9\x86\x40\xe2\x6c\x70\x3a\x0b\x63\x31\xed\xb5\x81\x52\x42\x54\xe9\x6b\x41\x55\xc9\xf1\x35\xaf\xf4\x9c\xef\x6c\x2d\x6b\x99\x5b\x35\xa6\x84\x5c\x9c\x17\x36\x14\x75\x94\x2e\x82\xc4\x17\xea\xe2\x18\x11\xaf\x1c\x6f\xd8\xfc\xb6\x87\x3d\xc6\xed\x14\xc2\xe7\xe5\xa8\x8f\xf2\x75\x93\x4c\xeb\xdf\xce\x14\xde\x03\x00\x7c\x6f\x36\xfd\xd8\x00\x00\x00")
+
+func dbMigrations0016_add_group_max_version_spreadSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0016_add_group_max_version_spreadSql,
+		"db/migrations/0016_add_group_max_version_spread.sql",
+	)
+}
+
+func dbMigrations0016_add_group_max_version_spreadSql() (*asset, error) {
+	bytes, err := dbMigrations0016_add_group_max_version_spreadSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0016_add_group_max_version_spread.sql", size: 216, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x97, 0xfc, 0x11, 0x7e, 0xf6, 0x70, 0xac, 0x4a, 0x89, 0xc, 0xf7, 0xe0, 0xbf, 0xd6, 0x52, 0x55, 0x55, 0xc8, 0x64, 0x60, 0x8a, 0x78, 0x46, 0xe0, 0x94, 0x99, 0xf, 0x57, 0x63, 0xbd, 0x3d, 0x13}}
+	return a, nil
+}
+
+var _dbMigrations0017_add_group_rollout_percentageSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xce\xb1\xaa\xc2\x40\x10\x05\xd0\x7e\xbf\xe2\x96\xef\x21\x81\x58\xa7\xf5\x17\xac\xc3\x66\xf7\xb2\x06\x27\x33\xcb\x38\x21\xf8\xf7\xb6\x16\x8a\xfd\x29\xce\x30\xe0\xb4\xad\xcd\x73\x10\xd7\x9e\x52\x96\xa0\x23\xf2\x22\x44\x73\xdb\xfb\x03\xb9\x56\x14\x93\x7d\x53\x74\x93\xb5\x3c\x67\x37\x11\xdb\x63\xee\xf4\x42\x8d\xdc\x88\x55\x83\x8d\x8e\x72\x63\xb9\xe3\xef\x3b\x5c\x18\x07\xa9\x18\x91\xb5\xe2\x3c\x8e\xff\x53\x4a\xef\x8b\x8b\x1d\xfa\xf1\x51\xdd\xfa\xcf\xc8\x94\x5e\x03\x00\x69\x33\x7b\x1b\xd2\x00\x00\x00")
+
+func dbMigrations0017_add_group_rollout_percentageSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0017_add_group_rollout_percentageSql,
+		"db/migrations/0017_add_group_rollout_percentage.sql",
+	)
+}
+
+func dbMigrations0017_add_group_rollout_percentageSql() (*asset, error) {
+	bytes, err := dbMigrations0017_add_group_rollout_percentageSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0017_add_group_rollout_percentage.sql", size: 210, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x36, 0x2a, 0x32, 0xb0, 0x29, 0xcf, 0x82, 0x8d, 0xe5, 0x1c, 0x34, 0x1c, 0x73, 0x8, 0xb1, 0x84, 0x10, 0x44, 0x81, 0x29, 0x6, 0x1d, 0x98, 0x3d, 0x91, 0x85, 0xe1, 0x9c, 0x24, 0x2e, 0xde, 0xc8}}
+	return a, nil
+}
+
+var _dbMigrations0018_add_group_max_concurrent_downloadsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\xb1\x0a\xc2\x40\x0c\x06\xe0\xfd\x9e\xe2\x1f\x15\x29\x74\x2f\x3a\xf9\x0a\xce\x25\xde\xc5\x5a\x4c\x93\x23\xcd\x51\x7d\x7b\x57\x07\x41\x5f\xe0\xe3\xeb\x3a\x1c\x96\x79\x72\x0a\xc6\xa5\xa6\x44\x12\xec\x08\xba\x0a\x63\x72\x6b\x75\x05\x95\x82\x6c\xd2\x16\x45\x35\x99\xf3\x6b\x5c\xe8\x39\x66\xd3\xdc\xdc\x59\x63\x2c\xb6\xa9\x18\x95\x15\xb3\x06\x4f\xec\x50\x0b\x68\x13\x41\xe1\x1b\x35\x09\xf4\xc8\x77\xce\x0f\xec\x7e\x09\xa7\x23\xfa\xfd\x90\xd2\xe7\xeb\x6c\x9b\x7e\x9d\x15\xb7\xfa\x67\x6d\x48\xef\x01\x00\xce\x63\xab\x5c\xea\x00\x00\x00")
+
+func dbMigrations0018_add_group_max_concurrent_downloadsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0018_add_group_max_concurrent_downloadsSql,
+		"db/migrations/0018_add_group_max_concurrent_downloads.sql",
+	)
+}
+
+func dbMigrations0018_add_group_max_concurrent_downloadsSql() (*asset, error) {
+	bytes, err := dbMigrations0018_add_group_max_concurrent_downloadsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0018_add_group_max_concurrent_downloads.sql", size: 234, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x85, 0x7c, 0x27, 0x62, 0x50, 0x83, 0x2f, 0xd6, 0x33, 0xe3, 0xf0, 0xb8, 0x59, 0xc6, 0x80, 0x30, 0x8d, 0x32, 0xfc, 0x70, 0x53, 0x18, 0xdd, 0xb7, 0x15, 0x4, 0xf1, 0xf2, 0xe8, 0x91, 0xe9, 0xe5}}
+	return a, nil
+}
+
+var _dbMigrations0019_add_group_policy_pausedSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\xcc\x31\x0e\x83\x50\x08\x06\xe0\x9d\x53\xfc\x7b\xe3\x09\x5c\x7b\x85\xce\x0d\x0a\x1a\x13\x04\xf2\x84\x34\xbd\x7d\xd7\x0e\x5e\xe0\x9b\x26\x3c\xce\x63\x1f\x5c\x8a\x57\x12\xb1\x95\x0e\x14\x2f\xa6\xd8\x47\x74\x5e\x60\x11\xac\x61\x7d\x3a\x32\xec\x58\xbf\xef\xe4\xbe\x54\xb0\x44\x98\xb2\xc3\xa3\xe0\x6d\x06\xd1\x8d\xdb\x0a\x1b\xdb\xa5\x33\xd1\x3f\xfe\x8c\x8f\xdf\xf2\x32\x22\x6f\xfd\x99\x7e\x03\x00\xa5\x38\x89\x52\x9d\x00\x00\x00")
+
+func dbMigrations0019_add_group_policy_pausedSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0019_add_group_policy_pausedSql,
+		"db/migrations/0019_add_group_policy_paused.sql",
+	)
+}
+
+func dbMigrations0019_add_group_policy_pausedSql() (*asset, error) {
+	bytes, err := dbMigrations0019_add_group_policy_pausedSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0019_add_group_policy_paused.sql", size: 157, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x62, 0x2b, 0xa, 0x6e, 0xe1, 0x42, 0xb, 0x1e, 0xf3, 0x1a, 0x5b, 0xe2, 0xc8, 0x79, 0xda, 0xea, 0xb1, 0xaa, 0x92, 0xf3, 0xa9, 0x3f, 0xab, 0x42, 0x4a, 0x5d, 0x9c, 0x1e, 0x13, 0x4, 0xa2, 0xc5}}
+	return a, nil
+}
+
+var _dbMigrations0020_add_group_auto_rollbackSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x92\xcd\x8e\x5a\x31\x0c\x85\xd7\xe4\x29\xbc\x04\x95\x91\xe8\x9a\x6d\x5f\xa1\xeb\xc8\x24\x87\x8b\x75\x73\x9d\x2b\xc7\x11\x43\x9f\xbe\xa2\xfc\x8d\xa6\x83\x98\xb5\xe3\xcf\xfe\x4e\xfc\xf6\x46\x3f\x26\x19\x8c\x1d\xf4\x7b\x0e\x81\x8b\xc3\xc8\x79\x57\x40\x83\xd5\x3e\x37\xe2\x9c\x29\xd5\xd2\x27\xa5\xb9\x16\x49\xa7\x68\xb5\x94\x1d\xa7\x31\xee\x59\x4a\x37\xc4\x19\x96\xa0\xce\x03\x48\xd4\x31\xc0\x28\x1d\x90\x46\x5a\x7e\xa3\x63\x07\x3f\x02\x4a\x1b\x62\xcd\xf4\x73\xb3\x59\x6d\x5f\xac\x51\xb8\x79\x1c\xb5\x1e\x35\x0e\xb5\xe6\x38\x73\x1a\x79\x40\x94\x4c\xbd\x4b\x26\xc3\x1e\x06\x4d\x68\x74\x2d\xd1\x52\xf2\x8a\xaa\x52\x46\x81\x83\x1a\x9c\xb4\x97\xb2\x0d\x21\x19\xce\xf2\x17\xe5\x74\x60\x55\x94\x3b\xf1\x20\xcd\xab\x9d\x68\x19\x16\x92\xa9\xc1\x84\x0b\xcd\x26\x13\xdb\x89\x46\x9c\xd6\x61\x71\x6b\xb9\x0d\xd7\x7a\x41\x7f\xdc\xe2\xfa\xe6\xf3\x16\x89\x5b\xe2\x8c\x75\x58\x7c\x36\xf8\x0a\xf2\x44\xe5\x01\xb9\x98\xe4\xe8\x8d\x5c\x26\x34\xe7\x69\xf6\x3f\x94\xb1\xe7\x5e\x9c\x52\x37\x83\x7a\xbc\xd7\xee\x53\xc2\xea\x91\x83\x68\xc6\xfb\x19\x7f\xf3\xfa\x2f\x8a\x5b\x41\xf2\x9a\x1e\x23\xcf\x88\x8f\xc7\xf4\xab\x1e\x35\x84\x6c\x75\xbe\x46\x2b\x7b\xc2\xbb\x34\x6f\xcf\xc8\x5f\xfe\xfa\x3f\xc0\xcb\x6f\x7f\xd9\xfa\xfa\x0c\xb7\xe1\xef\x00\x0c\x66\x1f\xf2\x0b\x03\x00\x00")
+
+func dbMigrations0020_add_group_auto_rollbackSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0020_add_group_auto_rollbackSql,
+		"db/migrations/0020_add_group_auto_rollback.sql",
+	)
+}
+
+func dbMigrations0020_add_group_auto_rollbackSql() (*asset, error) {
+	bytes, err := dbMigrations0020_add_group_auto_rollbackSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0020_add_group_auto_rollback.sql", size: 779, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x82, 0xe6, 0xb2, 0xfa, 0x67, 0x53, 0x74, 0xe2, 0xc, 0xe8, 0xf5, 0x32, 0xd4, 0x28, 0xb1, 0x3e, 0x25, 0x3, 0x48, 0x6b, 0x37, 0x1b, 0x5d, 0x23, 0xd9, 0xc1, 0x25, 0x97, 0x97, 0xb0, 0x52, 0x52}}
+	return a, nil
+}
+
+var _dbMigrations0021_add_package_mirrorsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x4c\xcf\x41\x4e\xc3\x40\x0c\x05\xd0\x75\x7d\x8a\xbf\x6b\x22\xd2\x13\x14\xb1\xe2\x0a\xac\xab\x61\xe6\x37\x58\x99\xcc\x44\x1e\x47\x34\xb7\x47\x15\xa4\x65\x67\xd9\xf2\xd3\xff\xa7\x13\x5e\x66\x1d\x2d\x38\xf1\xb1\x88\x44\xe3\x7d\xf4\xf0\x99\x89\x25\xc4\x29\x8c\xbc\xcc\x6a\x56\xad\xa1\x93\xc3\xbe\xd2\x84\x75\xd5\x84\x52\x1d\x65\xcd\x19\xc6\x2b\x8d\x25\xb2\xed\x6f\xe8\x34\xf5\xa8\x05\x89\x99\x4e\xc4\xd0\x62\x48\x1c\xe4\xb0\x98\x56\x53\xdf\xa0\xc5\x39\xd2\x1e\xca\x20\x87\xd5\x32\x9c\x37\x7f\xca\xf1\x8b\x71\x42\x77\x3f\xbc\xbe\xe1\x78\xec\x7f\x85\x39\xd8\x86\x89\x1b\xba\x67\xa6\x01\x3b\xdd\x4b\x7f\x16\xf9\xdf\xee\xbd\x7e\x17\x91\x64\x75\xf9\x6b\xa7\x57\xf0\xa6\xcd\x1f\x81\x2f\xb3\x9a\x55\x6b\x67\xf9\x19\x00\xe8\xaf\xc8\xf0\x16\x01\x00\x00")
+
+func dbMigrations0021_add_package_mirrorsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0021_add_package_mirrorsSql,
+		"db/migrations/0021_add_package_mirrors.sql",
+	)
+}
+
+func dbMigrations0021_add_package_mirrorsSql() (*asset, error) {
+	bytes, err := dbMigrations0021_add_package_mirrorsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0021_add_package_mirrors.sql", size: 278, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x7a, 0xae, 0x19, 0x8c, 0xbf, 0x58, 0xa9, 0x2, 0x7d, 0x30, 0x25, 0x19, 0x7d, 0x26, 0x40, 0x1e, 0x86, 0xe8, 0xca, 0x26, 0x1, 0x7b, 0x59, 0x76, 0xdb, 0x3, 0x45, 0x13, 0x30, 0x16, 0x76, 0x2e}}
+	return a, nil
+}
+
+var _dbMigrations0022_add_instance_ip_indexSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\xcd\x31\x0e\x02\x31\x0c\x44\xd1\xde\xa7\x98\x12\x84\xf6\x04\xdb\x72\x05\xea\x68\xb5\x31\xd1\x14\x38\x56\x6c\x44\x8e\x8f\x68\x80\x62\xbb\xd1\x14\xff\x2d\x0b\x2e\x0f\xb6\xb1\xa5\xe2\xe6\x22\xfb\xd0\xcf\xa4\x55\x9d\xe0\x1d\xd6\x13\x3a\x19\x19\xa0\x45\x6e\xb6\x6b\xa1\x17\xd6\x89\x6e\xdf\x0b\xcf\xa0\x35\x34\x46\xe2\x44\x07\x4d\xb3\x74\x8f\xf3\x2a\xf2\x2f\x5c\xfb\xcb\x44\xea\xe8\xfe\x13\x8e\xeb\xab\xbc\x07\x00\x1c\x5e\xea\x6e\x9a\x00\x00\x00")
+
+func dbMigrations0022_add_instance_ip_indexSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0022_add_instance_ip_indexSql,
+		"db/migrations/0022_add_instance_ip_index.sql",
+	)
+}
+
+func dbMigrations0022_add_instance_ip_indexSql() (*asset, error) {
+	bytes, err := dbMigrations0022_add_instance_ip_indexSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0022_add_instance_ip_index.sql", size: 154, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xd9, 0xe8, 0x92, 0x77, 0xec, 0x14, 0x20, 0x30, 0xe5, 0xaf, 0x91, 0xe7, 0x40, 0x76, 0x80, 0xa0, 0xa5, 0x83, 0xd9, 0x9c, 0xf9, 0x11, 0xf5, 0xa1, 0x50, 0x1f, 0xd, 0xa6, 0x85, 0xb3, 0xb6, 0x38}}
+	return a, nil
+}
+
+var _dbMigrations0023_add_webhooksSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x54\x90\xbd\x6e\xc4\x20\x10\x84\x6b\xf3\x14\xdb\x9d\xad\xf8\xba\x74\x17\xa5\xca\x2b\xa4\xb6\x30\x8c\xcf\xc8\x18\xd0\xb2\xc4\xbe\x3c\x7d\x44\x7e\x9c\xbb\x0e\x69\x66\x87\x99\xef\x7c\xa6\xa7\xd5\x5d\x59\x0b\xe8\x3d\x29\x65\x18\xf5\x29\x7a\xf4\xa0\x0d\xe3\x1c\xe3\x42\xad\x6a\x9c\xa5\x52\x9c\xa5\xc4\x6e\xd5\x7c\xa3\x05\x37\xb2\x98\x74\xf1\xf2\x2d\x0c\x57\x04\xd4\x94\xe1\xe3\xb9\xed\x7a\xd5\xe8\x94\xbc\x33\x5a\x5c\x0c\xc3\xdf\x71\x88\x42\xa1\x78\x4f\x8c\x09\x8c\x60\x90\xe9\xce\x47\xad\xb3\x1d\xc5\x40\x16\x1e\x02\x32\x3a\x1b\x6d\xd1\xab\xa6\xb0\x27\xc1\x2e\xff\x09\x66\x86\x59\xa8\xad\xc2\xcb\x2b\x9d\x4e\xf5\xcb\x0c\xc3\x90\x47\x63\xaf\x9a\x9f\x49\x76\x90\x4c\xe2\x56\x64\xd1\x6b\x92\xcf\xa3\xbd\x29\xcc\x08\x32\x1c\xda\x71\xab\xba\xcb\x01\xc4\x05\x8b\xbd\x76\x3b\x98\x3c\x0e\xac\xd6\x7b\x96\x6f\x71\x0b\x4a\x59\x8e\xe9\x97\xa5\x9b\x08\xbb\xcb\x92\x69\xc3\x38\xc7\xb8\x5c\xd4\xd7\x00\xbf\x54\x13\xa5\x7c\x01\x00\x00")
+
+func dbMigrations0023_add_webhooksSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0023_add_webhooksSql,
+		"db/migrations/0023_add_webhooks.sql",
+	)
+}
+
+func dbMigrations0023_add_webhooksSql() (*asset, error) {
+	bytes, err := dbMigrations0023_add_webhooksSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0023_add_webhooks.sql", size: 380, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x38, 0xb0, 0xf6, 0x3f, 0x16, 0x68, 0xb3, 0x64, 0x71, 0xb8, 0xb4, 0x45, 0xa9, 0xd0, 0xba, 0x82, 0xa, 0x83, 0xe0, 0xc8, 0xc2, 0x33, 0xad, 0x8, 0x6b, 0x28, 0x2d, 0xdb, 0xa, 0xd8, 0xfd, 0xa2}}
+	return a, nil
+}
+
+var _dbMigrations0024_add_package_min_previous_versionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\xcc\xb1\x0d\xc2\x30\x10\x05\xd0\xfe\xa6\xf8\x25\x08\xa5\x41\x4a\x95\x96\x15\xa8\xa3\x8f\x7d\x0a\x16\xb6\xcf\xba\x38\x66\x7d\x5a\x0a\xc4\x02\x6f\x9a\x70\x29\x69\x73\x76\xc5\xbd\x89\x30\x77\x75\x74\x3e\xb2\xa2\x31\xbc\xb8\x29\x18\x23\x82\xe5\xa3\x54\x94\x54\xd7\xe6\x3a\x92\x1d\xfb\x3a\xd4\xf7\x64\x15\x83\x1e\x9e\xf4\xd3\x75\x9e\xcf\x8b\xc8\x37\x79\xb3\x77\xfd\x8d\x46\xb7\xf6\x4f\x5d\xe4\x33\x00\x7e\xd2\xaa\x8e\x9b\x00\x00\x00")
+
+func dbMigrations0024_add_package_min_previous_versionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0024_add_package_min_previous_versionSql,
+		"db/migrations/0024_add_package_min_previous_version.sql",
+	)
+}
+
+func dbMigrations0024_add_package_min_previous_versionSql() (*asset, error) {
+	bytes, err := dbMigrations0024_add_package_min_previous_versionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0024_add_package_min_previous_version.sql", size: 155, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x26, 0x87, 0x32, 0xc9, 0xcf, 0xc, 0x15, 0x9f, 0xdb, 0x7b, 0x20, 0x61, 0xbc, 0x97, 0x95, 0xca, 0x38, 0x38, 0xfa, 0xe1, 0x54, 0xb8, 0x71, 0x8, 0x46, 0x1c, 0xa, 0x23, 0x7, 0xe3, 0x94, 0xec}}
+	return a, nil
+}
+
+var _dbMigrations0025_add_application_deleted_atSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x91\x3d\x4e\x03\x31\x10\x85\x7b\x9f\xe2\x95\x20\x58\x2e\xb0\x2d\x35\x1d\xf5\x6a\x62\xcf\xc2\x08\x7b\x6c\xec\xb1\x12\x38\x3d\x0a\x9b\xa0\x25\x82\x24\xf5\x3c\x7f\x7e\x3f\xc3\x80\xbb\x24\x2f\x95\x8c\xf1\x5c\x9c\xa3\x68\x5c\x61\xb4\x89\x0c\x2a\x25\x8a\x27\x93\xac\xa0\x10\xe0\x73\xec\x49\x11\x38\xb2\x71\x98\xc8\x60\x92\xb8\x19\xa5\x62\x9f\xa3\x73\xc3\x80\x27\x4a\xdc\x90\xe7\xa3\x68\xcd\x68\xf0\xa4\xd8\x30\x2a\xf7\xc6\xe1\xe1\xdf\xbf\x42\xcd\x05\x3e\x6b\xb3\x4a\xa2\xb6\xbe\x4d\xc6\x94\x26\x09\x93\x52\xe2\xe9\x8d\x3f\x46\xe7\x2b\xef\xcd\x77\x95\xf7\xce\x10\x0d\xbc\x3b\xfb\x02\x59\xd7\x77\xdc\x1c\x04\xf7\xd8\x33\x6f\xb1\x7d\xe5\xca\xeb\x90\xd2\xa0\x3d\xc6\x25\xe0\x4f\x59\x8f\x79\xab\xce\x2d\x32\xcc\x35\xa7\x5f\xd0\x3f\x21\xd9\x0e\xa0\xef\x80\x8b\x53\x99\xc1\x3b\x69\xd6\xce\x7a\x1e\x2f\xec\x72\x55\x55\xc7\x8a\x4e\x02\x8f\x97\x76\x38\x19\x7d\x74\x5f\x03\x00\xef\x7a\x90\x24\x34\x02\x00\x00")
+
+func dbMigrations0025_add_application_deleted_atSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0025_add_application_deleted_atSql,
+		"db/migrations/0025_add_application_deleted_at.sql",
+	)
+}
+
+func dbMigrations0025_add_application_deleted_atSql() (*asset, error) {
+	bytes, err := dbMigrations0025_add_application_deleted_atSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0025_add_application_deleted_at.sql", size: 564, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x49, 0xb1, 0x52, 0xa5, 0xc7, 0x99, 0x1e, 0x59, 0x1f, 0xd7, 0x9c, 0x52, 0x92, 0x70, 0x4e, 0xc, 0xe5, 0x98, 0x78, 0xb7, 0xce, 0xd3, 0x31, 0xd8, 0x1f, 0x2, 0x81, 0xe5, 0x75, 0xa5, 0x29, 0xb4}}
+	return a, nil
+}
+
+var _dbMigrations0026_add_group_pinned_versionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\xcc\x31\x0a\xc2\x50\x0c\x06\xe0\x3d\xa7\xf8\x47\x45\xba\x08\x9d\xba\x7a\x05\xe7\x12\xdf\x0b\xf5\x41\x9a\x84\xf4\xb5\xe2\xed\x5d\x1d\xc4\x0b\x7c\xc3\x80\xcb\xda\x96\xe4\x2e\xb8\x07\x11\x6b\x97\x44\xe7\x87\x0a\x96\xf4\x3d\x36\x70\xad\x28\xae\xfb\x6a\x08\xd7\x56\xde\x73\x34\x33\xa9\xf3\x21\xb9\x35\x37\x1c\x9c\xe5\xc9\x79\xba\x8e\xe3\x79\x22\xfa\x26\x6f\xfe\xb2\x9f\x68\x4d\x8f\xbf\xea\x44\x9f\x01\x00\xe8\xc1\xc3\x3a\x9b\x00\x00\x00")
+
+func dbMigrations0026_add_group_pinned_versionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0026_add_group_pinned_versionSql,
+		"db/migrations/0026_add_group_pinned_version.sql",
+	)
+}
+
+func dbMigrations0026_add_group_pinned_versionSql() (*asset, error) {
+	bytes, err := dbMigrations0026_add_group_pinned_versionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0026_add_group_pinned_version.sql", size: 155, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x7d, 0xfb, 0xe4, 0xd5, 0x25, 0xf9, 0x86, 0xe2, 0x4f, 0x36, 0x25, 0x33, 0x7b, 0x56, 0x5f, 0x25, 0x2f, 0xae, 0xe8, 0xe4, 0x1b, 0x4c, 0xc1, 0x20, 0xeb, 0x4, 0xf7, 0x2a, 0xc2, 0xb9, 0x35, 0x12}}
+	return a, nil
+}
+
+var _dbMigrations0027_add_instance_system_infoSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xcf\x41\xca\xc2\x30\x10\xc5\xf1\x7d\x4e\xf1\x76\xfd\x3e\xa4\x50\xd7\xdd\x7a\x05\x0f\x30\x6d\x52\x2d\x4c\x66\xc2\x38\xd1\xeb\x4b\x05\x41\x10\x49\xf7\xef\xfd\xe0\xdf\xf7\x38\xe4\xf5\x62\xe4\x09\xe7\x12\x02\xb1\x27\x83\xd3\xc4\x09\xab\xdc\x9c\x64\x4e\xa0\x18\x31\x2b\xd7\x2c\x20\x9b\xaf\x58\xc5\x21\xea\x90\xca\x8c\x98\x16\xaa\xec\x18\xc6\xe6\x79\x52\xb2\x88\xfb\x66\x90\xfd\x1d\x87\xe1\xff\x9b\xe9\xba\xb6\x53\x98\x7c\x51\xcb\x3b\xa8\xf0\x19\x78\xd2\x87\xfc\x48\x8c\xa6\xe5\xcd\x6f\xe8\xd8\x9e\xbd\x6a\x76\xec\x0a\x93\x2f\x6a\x79\x0c\xcf\x01\x00\xf5\x1b\xe8\xc8\x6d\x01\x00\x00")
+
+func dbMigrations0027_add_instance_system_infoSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0027_add_instance_system_infoSql,
+		"db/migrations/0027_add_instance_system_info.sql",
+	)
+}
+
+func dbMigrations0027_add_instance_system_infoSql() (*asset, error) {
+	bytes, err := dbMigrations0027_add_instance_system_infoSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0027_add_instance_system_info.sql", size: 365, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb6, 0x65, 0x58, 0x5b, 0xb7, 0xf9, 0xf0, 0xb8, 0x8b, 0x93, 0x83, 0x8a, 0x28, 0x5c, 0xd7, 0x6a, 0xde, 0xa, 0xe, 0x7c, 0xc3, 0xde, 0x25, 0x2a, 0xea, 0xcb, 0x54, 0xc0, 0x79, 0x84, 0x25, 0x2f}}
+	return a, nil
+}
+
+var _dbMigrations0028_add_group_update_windowsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x74\x90\xb1\x6e\x83\x40\x10\x44\x6b\xee\x2b\xa6\x04\x05\x4b\x69\x92\xc6\x71\xaa\xfc\x42\x6a\xb4\xb9\x5d\xdb\x2b\xe0\x0e\xed\x1d\x01\xfe\x3e\x42\x0e\x4e\x0a\xdc\x9d\x6e\x66\x9f\xf4\xe6\x70\xc0\x53\xaf\x17\xa3\x2c\xf8\x1c\x9c\xf3\x26\xeb\x33\xd3\x57\x27\xb8\x58\x1c\x87\x66\x1c\x98\xb2\x34\x93\x06\x8e\x13\x4a\x57\x28\x23\x89\x29\x75\x18\x4c\x7b\xb2\x05\xad\x2c\xb5\x2b\x6e\x75\x65\x8c\xa3\x32\x42\xcc\x08\x63\xd7\xc1\xe4\x2c\x26\xc1\x4b\xba\x01\x13\x4a\xe5\x0a\x31\x80\xa5\x93\x2c\xf0\x94\x3c\xb1\xd4\xae\x98\x44\x5a\xa6\x05\x1a\xf2\xdf\xbd\xbf\x8a\x6f\x51\x6e\xd9\xfb\x09\xcf\xa0\xc0\xd8\x3e\xde\x4e\x78\xad\x6a\x57\xa4\x4c\x96\x9b\xac\xbd\xe0\x9b\xcc\x5f\xc9\xca\x97\xea\x8e\xa9\x5d\x21\x81\x1f\xc6\xae\x3a\xde\xe5\x35\xb0\xcc\x7b\xf2\xcd\x66\xd8\x28\xcf\xab\xc0\xee\x40\x5b\x69\x25\xfe\x9f\xf7\x23\x4e\xc1\x39\xb6\x38\xfc\xce\xab\x67\xc8\xac\x29\xa7\x3d\xce\xd1\xfd\x0c\x00\x5c\xf5\x9a\xbd\x9b\x01\x00\x00")
+
+func dbMigrations0028_add_group_update_windowsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0028_add_group_update_windowsSql,
+		"db/migrations/0028_add_group_update_windows.sql",
+	)
+}
+
+func dbMigrations0028_add_group_update_windowsSql() (*asset, error) {
+	bytes, err := dbMigrations0028_add_group_update_windowsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0028_add_group_update_windows.sql", size: 411, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x43, 0xf6, 0x7b, 0xe7, 0x46, 0xfa, 0x9c, 0xae, 0x6c, 0x53, 0x26, 0xfd, 0x5e, 0x57, 0xbd, 0x6d, 0x14, 0x4a, 0x25, 0x96, 0x18, 0x7, 0x4a, 0x66, 0xc4, 0xe0, 0xfd, 0xd6, 0xa9, 0x2e, 0x78, 0x5f}}
+	return a, nil
+}
+
+var _dbMigrations0029_application_instance_retentionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xcd\x31\x0a\x02\x41\x0c\x46\xe1\x3e\xa7\xf8\x4b\x45\x16\xec\x17\xac\xbc\x82\xf5\x12\x33\x41\x83\xb3\x99\x21\x1b\x11\x6f\x2f\x76\x36\x83\xf5\x7b\xf0\x4d\x13\x0e\xab\xdd\x82\x53\x71\xe9\x44\x5c\x53\x03\xc9\xd7\xaa\xe0\xde\xab\x09\xa7\x35\x07\x97\x02\x69\xf5\xb9\x3a\xcc\xb7\x64\x17\x5d\x42\x53\xfd\x5b\x97\xc2\xef\x0d\xe6\x09\xb9\xab\x3c\xb0\x1b\x2d\x27\x1c\xf7\x33\xd1\x2f\x7a\x6e\x2f\x1f\xb3\x25\x5a\xff\xe3\xce\xf4\x19\x00\x6a\xee\xc8\x36\xc4\x00\x00\x00")
+
+func dbMigrations0029_application_instance_retentionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0029_application_instance_retentionSql,
+		"db/migrations/0029_application_instance_retention.sql",
+	)
+}
+
+func dbMigrations0029_application_instance_retentionSql() (*asset, error) {
+	bytes, err := dbMigrations0029_application_instance_retentionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0029_application_instance_retention.sql", size: 196, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb0, 0x93, 0x61, 0xc1, 0x73, 0x3b, 0x23, 0x26, 0x37, 0x12, 0x9c, 0xe2, 0x91, 0x68, 0x56, 0x51, 0x87, 0xf8, 0xab, 0xbf, 0xf6, 0x39, 0x16, 0xe, 0xf7, 0xf2, 0x93, 0x6d, 0x68, 0xe4, 0xfc, 0xd3}}
+	return a, nil
+}
+
+var _dbMigrations0030_flatcar_action_payload_signatureSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\x41\x0a\xc2\x30\x10\x46\xe1\x7d\x4e\x31\xbb\x2a\xd2\x8d\xd0\x55\xb7\x5e\xc1\x75\xf8\x4d\xd2\x1a\x9c\x4e\xca\x38\x51\x7b\x7b\x71\xa7\xa0\xd2\x03\xbc\xc7\xd7\xb6\xb4\x9b\xf2\xa8\xb0\x44\xc7\xd9\x39\xb0\x25\x25\xc3\x89\x13\x0d\x0c\x0b\x50\x8f\x60\xb9\x08\x21\x46\x0a\x85\xeb\x24\x34\x63\xe1\x82\xe8\xaf\x79\x14\x58\xd5\x44\x96\x1e\x46\x52\x8c\xa4\x32\x53\x4c\x03\x2a\x1b\x35\x4d\xbf\xf2\xf8\x3a\x65\x19\xfd\x25\x2d\x3e\x47\xba\x41\xc3\x19\xba\xd9\x77\xdd\xf6\xfb\xd6\xbd\xcb\x0f\xe5\x2e\x7f\xed\x51\xcb\xfc\x13\xdf\xaf\x2d\x3f\x91\xbd\x7b\x0e\x00\x12\x92\xe1\x36\x3d\x01\x00\x00")
+
+func dbMigrations0030_flatcar_action_payload_signatureSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0030_flatcar_action_payload_signatureSql,
+		"db/migrations/0030_flatcar_action_payload_signature.sql",
+	)
+}
+
+func dbMigrations0030_flatcar_action_payload_signatureSql() (*asset, error) {
+	bytes, err := dbMigrations0030_flatcar_action_payload_signatureSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0030_flatcar_action_payload_signature.sql", size: 317, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf6, 0xb8, 0x38, 0xb4, 0x74, 0xb7, 0x48, 0x3e, 0x80, 0xf6, 0x6f, 0x15, 0xef, 0xa3, 0x62, 0x40, 0xcf, 0xe5, 0xc7, 0x89, 0xf, 0x7a, 0x9b, 0xc, 0xee, 0xd6, 0x71, 0x86, 0x3b, 0x20, 0x22, 0xb}}
+	return a, nil
+}
+
+var _dbMigrations0031_group_update_timeout_actionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\x3d\x4e\x04\x31\x0c\x46\x6b\x72\x0a\x77\x99\x11\xbb\x12\xa2\x9d\x96\x2b\x50\x47\xde\xc4\xd9\x8d\xf0\xd8\x91\xc7\x01\x71\x7b\x04\x34\xc3\x8f\x56\xdb\xb9\xb0\xde\xf7\xf4\x8e\x47\xb8\x5f\xdb\xd9\xd0\x09\x9e\x7b\x08\xc8\x4e\x06\x8e\x27\x26\x38\x9b\x8e\xbe\x01\x96\x02\x59\x79\xac\x02\x5d\xb9\xe5\xf7\x34\x7a\x41\xa7\xe4\x6d\x25\x1d\x9e\x30\x7b\x53\x81\x57\xb4\x7c\x41\x9b\x1e\x1f\x66\x10\x75\x90\xc1\x0c\x85\x2a\x0e\x76\x88\xa2\x42\x31\xdc\xe5\x0b\xe5\x17\x98\xae\x82\x9a\xc0\xf4\xfd\x7f\x80\x58\xb1\x71\x3c\x40\x34\xd2\x5a\xc9\x3e\x4f\x64\x32\x8f\xf3\xbc\xfc\xb0\x6d\xb2\x39\x4a\xa6\x84\xbd\x73\xcb\xf8\x85\xda\xb9\xef\xb6\x4a\xd2\xe1\x70\x52\x65\x42\xf9\xeb\x5a\x91\x37\x5a\x42\xd8\xb7\x79\xd2\x37\xf9\xb7\x4e\x31\xed\xb7\xe4\xb9\x41\x76\x8f\xfa\x6d\xbb\x84\x8f\x01\x00\x11\x49\x32\x33\xaa\x01\x00\x00")
+
+func dbMigrations0031_group_update_timeout_actionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0031_group_update_timeout_actionSql,
+		"db/migrations/0031_group_update_timeout_action.sql",
+	)
+}
+
+func dbMigrations0031_group_update_timeout_actionSql() (*asset, error) {
+	bytes, err := dbMigrations0031_group_update_timeout_actionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0031_group_update_timeout_action.sql", size: 426, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x3d, 0x1d, 0x3f, 0x8d, 0x79, 0xd0, 0xfb, 0x15, 0x8, 0x57, 0xa1, 0x60, 0xb, 0x6c, 0xec, 0xaf, 0x87, 0x1f, 0xa, 0x76, 0xef, 0xaa, 0xa3, 0xb5, 0x66, 0xbf, 0x9e, 0xf9, 0x5e, 0x96, 0xcb, 0xdd}}
+	return a, nil
+}
+
+var _dbMigrations0032_channel_package_revisionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x8e\xb1\x11\x02\x31\x0c\x04\x73\x57\x71\x39\xf3\x01\x31\x29\x2d\x50\x80\xb0\xc5\xe3\x41\x7f\xf2\x18\x19\xda\x27\x05\xc6\xc9\xe7\xb7\xb7\xbb\x2c\x38\x6c\x75\xed\x12\x8a\x4b\x4b\x49\x2c\xb4\x23\xe4\x6a\x8a\x7c\x17\x52\x0d\x52\x0a\xb2\xdb\xd8\x88\xae\xaf\xfa\xac\x4e\x54\x06\xe8\x01\x0e\x33\x14\xbd\xc9\xb0\xc0\xf1\xf4\xc3\x37\xc9\x0f\x59\x75\x17\x9f\xbe\x83\xce\xfe\xe6\x3c\xa9\x74\x6f\xff\x9f\x73\xf9\x7c\xf9\x19\x00\x2b\x5d\xef\xd6\xf7\x00\x00\x00")
+
+func dbMigrations0032_channel_package_revisionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0032_channel_package_revisionSql,
+		"db/migrations/0032_channel_package_revision.sql",
+	)
+}
+
+func dbMigrations0032_channel_package_revisionSql() (*asset, error) {
+	bytes, err := dbMigrations0032_channel_package_revisionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0032_channel_package_revision.sql", size: 247, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x32, 0x58, 0x62, 0x8f, 0x9, 0x33, 0x3, 0x6d, 0x74, 0x6a, 0xfc, 0x90, 0x47, 0x12, 0x8c, 0x28, 0x64, 0x9a, 0x4f, 0x31, 0x28, 0x75, 0xf8, 0x2d, 0x8b, 0x5a, 0xa2, 0xbe, 0xc4, 0x7f, 0xb2, 0xb7}}
+	return a, nil
+}
+
+var _dbMigrations0033_add_group_channel_weightsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x90\xcd\x6e\x83\x30\x10\x84\xcf\xec\x53\xec\x11\x54\x22\xf5\x1e\xa9\xa7\xbe\x42\xcf\xc8\xf5\x4e\x60\x15\x67\x8d\x6c\xa3\x90\xb7\xaf\x50\x20\xf4\x40\x7b\xf3\xcf\xce\xb7\x33\x73\x3a\xf1\xdb\x4d\xfb\xe4\x0a\xf8\x6b\x24\xf2\x09\xcb\xb1\xb8\xef\x00\xee\x53\x9c\xc6\xce\x0f\xce\x0c\xa1\xbb\x43\xfb\xa1\x70\x4d\xd5\xf3\x5d\x85\xa7\x49\x85\x2d\x16\xb6\x29\x04\x4e\xb8\x20\xc1\x3c\xf2\x53\x99\xb9\x56\x69\x38\x1a\x0b\x02\x0a\xd8\xbb\xec\x9d\xa0\xa5\x6a\x63\xfe\xc7\x58\x67\xfe\x86\xac\x86\xd4\xca\xae\xf7\x03\xfc\x95\xeb\xf5\xeb\x83\xdf\x9b\x96\xaa\x31\xe9\xcd\xa5\x07\x5f\xf1\xe0\x7a\x33\xdf\x6e\x0b\x3a\x95\x86\x9a\xf3\x2b\xbb\x9a\x60\x3e\xcc\xfe\xba\xaa\x74\x2a\xf3\x92\xec\xb8\xa2\x7d\x6e\xe1\xfe\xee\xf8\x33\xde\x8d\x48\x52\x1c\xd7\x8e\xf5\xc2\x98\x35\x97\x7c\x88\x3a\xd3\xcf\x00\x7e\x6e\xff\x98\xa1\x01\x00\x00")
+
+func dbMigrations0033_add_group_channel_weightsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0033_add_group_channel_weightsSql,
+		"db/migrations/0033_add_group_channel_weights.sql",
+	)
+}
+
+func dbMigrations0033_add_group_channel_weightsSql() (*asset, error) {
+	bytes, err := dbMigrations0033_add_group_channel_weightsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0033_add_group_channel_weights.sql", size: 417, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xbd, 0xc9, 0x25, 0xe6, 0xc1, 0x15, 0xc0, 0x8d, 0x4e, 0xd0, 0x0, 0x1f, 0xfc, 0xe5, 0xe, 0xa6, 0x26, 0x5b, 0xed, 0xfb, 0xbc, 0x96, 0x5, 0x2, 0xb5, 0xbe, 0x8e, 0x79, 0xc2, 0xfa, 0x36, 0x5}}
+	return a, nil
+}
+
+var _dbMigrations0034_add_instance_registration_rulesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x90\x41\x4e\x03\x31\x0c\x45\xd7\xe3\x53\xfc\xdd\x24\x62\x2a\xc1\xba\x5b\xae\xc0\xba\x32\x89\xdb\x5a\x4d\x93\xc8\xc9\x50\xe6\xf6\xa8\x85\xc2\xb0\xe9\x26\x8a\xbe\xbe\x9e\xed\xb7\xd9\xe0\xe9\xac\x07\xe3\x2e\x78\xab\x44\xc1\xe4\xfa\xed\xfc\x9e\x04\x9a\x5b\xe7\x1c\x64\x67\x72\xd0\xd6\x8d\xbb\x96\xbc\xb3\x39\x09\x1c\x0d\x5c\x6b\xd2\xf0\x9d\x69\xc4\x3c\x6b\x44\x2e\x1d\x79\x4e\x09\x26\x7b\x31\xc9\x41\x1a\x56\x3d\x38\x8d\x1e\x25\x23\x4a\x92\x2e\x08\xdc\x02\x47\x99\x68\xa8\xa5\xe9\x95\x04\xcd\xfd\x97\x32\xd1\xc0\xe1\x96\x7e\xb0\x85\x23\x9b\x7b\x79\xf6\x7f\x33\xc2\x51\xc2\x09\xee\xa7\xa2\x19\x6e\xe4\x94\xca\x65\x9c\x30\x46\xc9\xcb\xe8\xfd\x44\x43\xd0\x68\xb8\x3d\x2b\x6c\x35\x3d\xb3\x2d\x38\xc9\x02\xf7\xff\x90\x09\xf7\x5d\x3c\xf9\x2d\xd1\xda\xd0\x6b\xb9\x64\xa2\x68\xa5\xde\x0d\xed\x21\x9f\xda\x7a\x7b\xe0\x6a\x4b\x5f\x03\x00\x6b\xa8\xc0\x0f\x65\x01\x00\x00")
+
+func dbMigrations0034_add_instance_registration_rulesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0034_add_instance_registration_rulesSql,
+		"db/migrations/0034_add_instance_registration_rules.sql",
+	)
+}
+
+func dbMigrations0034_add_instance_registration_rulesSql() (*asset, error) {
+	bytes, err := dbMigrations0034_add_instance_registration_rulesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0034_add_instance_registration_rules.sql", size: 357, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x60, 0x84, 0xf1, 0x3d, 0xe5, 0x20, 0x9f, 0x6f, 0xf8, 0x9d, 0xd1, 0x8b, 0x4e, 0x66, 0x42, 0xf2, 0x96, 0xa3, 0x74, 0x4d, 0xc1, 0x82, 0x73, 0xc6, 0x85, 0x25, 0x9, 0xa5, 0xee, 0x1b, 0xa4, 0x73}}
+	return a, nil
+}
+
+var _dbMigrations0035_add_package_deltasSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x74\x52\x41\x8e\xdb\x30\x0c\x3c\x5b\xaf\xe0\xcd\x36\xea\x05\xb6\xc6\xda\x97\x2d\x7a\xea\x17\x7a\x16\x58\x89\xb6\x85\xc8\xb2\x4b\x51\x69\x93\xd7\x17\x4a\x1b\xc5\x41\xb1\x37\x61\x34\x33\x24\x66\xf8\xf2\x02\x9f\x56\x37\x33\x0a\xc1\xf7\x5d\x29\xc3\x94\x9f\x82\x3f\x3c\xc1\x8e\xe6\x84\x33\x69\x4b\x5e\x10\x1a\x55\x39\x0b\x29\x39\x0b\x3b\xbb\x15\xf9\x02\x27\xba\x80\xa5\x09\x93\x97\xdb\x87\x9e\x29\x50\xf6\xd2\xe7\xb7\xa6\xed\x54\x75\x77\xb8\x0b\xc3\x26\x10\x92\xf7\xc0\x34\x11\x53\x30\x14\xef\x53\xa0\x71\xb6\x85\x2d\x80\x25\x4f\x42\x60\x30\x1a\xb4\xd4\xa9\x6a\xe2\x6d\xd5\x67\xe2\xe8\xb6\x00\x67\x64\xb3\x20\x37\xfd\x30\xb4\x0f\x3b\xb3\x90\x39\x41\xf3\xc4\xfc\xf2\x15\xea\x3a\x2f\x91\xd8\x1f\x64\xe3\xff\xb2\x4c\x28\xec\xc9\x79\x0a\xb8\x52\x91\x7c\x7e\x7d\xcd\x2e\xd1\x5d\x1f\x58\x7f\x83\x16\x8c\x4b\x81\xc6\xb7\x0c\xc5\x05\xfb\x61\x3c\x82\x25\xa0\xba\xee\x54\xb5\x92\xa0\x45\x41\x1d\xdd\x1c\x50\x12\x93\xe6\x88\x85\xdf\x0f\xe3\xc7\x82\xc3\xfc\xbc\xd3\x33\xef\x6f\x71\x56\x4b\x04\x71\x2b\x45\xc1\x75\x97\x6b\xe1\x98\xc4\x4c\x41\x74\xf9\x2b\x21\xe4\x80\x82\xfb\x99\x08\x9a\x47\x5b\x1d\x1c\xa3\x6c\x55\xfb\xae\xd4\xf1\x54\xbe\x6d\xbf\x82\x52\x96\xb7\xfd\xdf\xa9\xb8\x09\xe8\xb7\x8b\x52\xea\xd4\x96\xbc\xe0\xbb\xfa\x33\x00\xc1\x0c\x96\x44\x61\x02\x00\x00")
+
+func dbMigrations0035_add_package_deltasSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0035_add_package_deltasSql,
+		"db/migrations/0035_add_package_deltas.sql",
+	)
+}
+
+func dbMigrations0035_add_package_deltasSql() (*asset, error) {
+	bytes, err := dbMigrations0035_add_package_deltasSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0035_add_package_deltas.sql", size: 609, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x33, 0xc9, 0x3e, 0x8c, 0x94, 0x6a, 0x73, 0x4b, 0xbd, 0x8b, 0x56, 0x8d, 0x9b, 0x51, 0x79, 0xf4, 0x3a, 0x14, 0x85, 0xac, 0x8b, 0xd6, 0xcd, 0xc, 0x59, 0xf0, 0xab, 0x11, 0x9e, 0xf, 0x34, 0x55}}
+	return a, nil
+}
+
+var _dbMigrations0036_add_instance_labelsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x6c\x8f\x4d\x4e\x03\x31\x0c\x85\xf7\x3e\xc5\xdb\x15\x84\x7a\x82\xd9\x72\x05\xd6\x91\x33\x71\x87\x20\xd7\x89\x62\x47\x8c\x84\xb8\x3b\xe2\xaf\xed\xa2\x07\xf8\xbe\xf7\xbe\xe3\x11\x4f\xe7\xba\x0d\x0e\xc1\x4b\x27\x62\x0d\x19\x08\xce\x2a\xa8\xe6\xc1\xb6\x0a\xb8\x14\xac\x4d\xe7\xd9\xa0\x9c\x45\x1d\x6f\xde\x2c\xc3\x5a\xc0\xa6\x2a\x8a\x9c\x78\x6a\xe0\xf0\xf1\x79\x58\x68\x1d\xf2\xad\xab\x56\x64\xbf\x48\xd2\x2f\x99\x6a\xd9\xd1\xec\xea\x9e\x5e\x6d\xc3\x56\x0d\x0f\xb7\xee\xd4\x39\x5e\x53\xeb\xfe\xb8\x10\xdd\x9e\x7c\x6e\xef\x46\x54\x46\xeb\xff\x03\x27\xc8\x5e\x3d\xfc\xde\xd4\x72\x3f\xe8\x07\xff\x2b\xba\xf2\xca\x59\xd4\x17\xfa\x1a\x00\x7f\x21\xab\x44\x14\x01\x00\x00")
+
+func dbMigrations0036_add_instance_labelsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0036_add_instance_labelsSql,
+		"db/migrations/0036_add_instance_labels.sql",
+	)
+}
+
+func dbMigrations0036_add_instance_labelsSql() (*asset, error) {
+	bytes, err := dbMigrations0036_add_instance_labelsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0036_add_instance_labels.sql", size: 276, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x3, 0x78, 0xc9, 0x21, 0xc9, 0xac, 0x34, 0xbe, 0x13, 0x17, 0x17, 0xad, 0xe0, 0x45, 0xda, 0x82, 0x25, 0x99, 0x9b, 0x97, 0x77, 0x9a, 0x4d, 0x39, 0x35, 0x7a, 0x3a, 0x45, 0x76, 0x66, 0xab, 0xdb}}
+	return a, nil
+}
+
+var _dbMigrations0037_add_activity_auditSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x92\xcf\x4e\xc3\x30\x0c\xc6\xef\x7e\x0a\x1f\x57\xc1\x24\x84\xd4\x53\xae\xbc\x02\x67\x94\x25\x2e\x33\x4a\xed\xca\x75\x3b\xfa\xf6\x68\x45\x5b\x7b\x81\xb5\xd7\xe8\xfb\xe5\xfb\x93\x1c\x8f\xf8\xd4\xf2\xa7\x45\x27\x7c\xef\x00\x62\x71\x32\xf4\x78\x2a\x84\x31\x39\x8f\xec\x13\xfe\x1e\x26\x2d\x43\x2b\x38\x92\xf5\xac\x82\xd9\xb4\x43\x51\x47\x19\x4a\x09\x7f\x80\x39\xdf\xb0\x98\x5c\x0d\xc7\x68\xe9\x1c\xed\xf0\x5a\xd7\xd5\x63\x86\xc4\xd9\xa7\x0f\x9f\x3a\xba\x93\xf5\xcb\x76\x90\xf3\x4e\xc3\xcc\x4d\x83\x5f\xbd\xca\x29\x00\x24\xa3\xeb\x2a\x2c\x99\xbe\x51\x65\x01\x0e\xab\x5c\xcf\x8b\x57\x15\xfe\x41\xe6\xfa\x55\x00\x58\x0f\xfe\xa6\x17\x01\xc8\x54\xc8\x09\x1b\xd3\x76\x01\x2e\x67\x32\xba\xdd\x3d\x0f\xc0\xfd\xa3\xb5\xe7\x17\x59\x35\xd9\x20\xbb\x87\xdf\xae\xbd\x86\xd9\xa0\x9e\x0b\x87\x1d\xff\xa9\x27\x47\x51\x47\x19\x4a\x09\xf0\x33\x00\x73\x6a\xd6\x73\x98\x02\x00\x00")
+
+func dbMigrations0037_add_activity_auditSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0037_add_activity_auditSql,
+		"db/migrations/0037_add_activity_audit.sql",
+	)
+}
+
+func dbMigrations0037_add_activity_auditSql() (*asset, error) {
+	bytes, err := dbMigrations0037_add_activity_auditSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0037_add_activity_audit.sql", size: 664, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x11, 0xed, 0x65, 0xe9, 0x17, 0xb, 0x5d, 0x5, 0x92, 0x49, 0xdc, 0x3, 0x43, 0xfb, 0x7e, 0xab, 0x3c, 0xbd, 0x9, 0xc1, 0xab, 0x80, 0x87, 0x9f, 0x4e, 0xa8, 0xad, 0xb2, 0x0, 0xf4, 0x9d, 0xbf}}
+	return a, nil
+}
+
+var _dbMigrations0038_add_package_oci_imageSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\x8f\x31\x0a\x02\x31\x10\x45\xfb\x9c\x62\x4a\x17\x59\x50\x21\x55\x5a\xaf\x60\x2d\x63\x32\xc4\xc1\xec\x4e\x98\x8c\xca\xde\xde\x4e\x05\x2d\xc2\xf6\xef\x7d\xde\x1f\x47\xd8\x4e\x9c\x15\x8d\xe0\x54\x9d\xc3\x62\xa4\x60\x78\x29\x04\x15\xe3\x0d\x33\x01\xa6\x04\x51\xca\x7d\x9a\x41\x22\x9f\x95\x32\x37\xd3\x05\x1e\xa8\xf1\x8a\xba\x39\x78\x3f\x84\x3e\xb3\x4a\x63\x93\x35\x6e\xe2\x4c\xcd\xde\xde\xde\xef\x86\xe0\xdc\x77\xfe\x51\x9e\xf3\xff\x03\x49\xa5\xfe\x6e\x85\x2e\xf6\xd3\xdc\xcb\x67\x6e\xa6\x4b\x70\xaf\x01\x00\x8f\x90\xd2\x6d\x5b\x01\x00\x00")
+
+func dbMigrations0038_add_package_oci_imageSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0038_add_package_oci_imageSql,
+		"db/migrations/0038_add_package_oci_image.sql",
+	)
+}
+
+func dbMigrations0038_add_package_oci_imageSql() (*asset, error) {
+	bytes, err := dbMigrations0038_add_package_oci_imageSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0038_add_package_oci_image.sql", size: 347, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x48, 0x85, 0x71, 0x6c, 0xf, 0xd, 0x91, 0x94, 0x7, 0x7c, 0x97, 0x57, 0xaa, 0xcb, 0x28, 0x55, 0x2d, 0x15, 0x2d, 0xcb, 0xb4, 0x5b, 0xe2, 0x7c, 0x6c, 0x23, 0xaf, 0x15, 0xe8, 0xa6, 0x5f, 0x16}}
+	return a, nil
+}
+
+var _dbMigrations0039_add_group_force_update_afterSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xcc\x31\x0a\xc2\x50\x0c\x00\xd0\x3d\xa7\xc8\x2e\x3d\x41\x57\xaf\xe0\xfc\x89\x3f\x69\xf9\x90\x34\x21\xcd\x47\xf4\xf4\x82\x93\x83\xe0\x01\xde\x5b\x16\xbc\xd8\xd8\x93\x4a\xf0\x16\x00\xa4\x25\x89\x45\x77\x15\xdc\xd3\x67\x9c\x48\xcc\xd8\x5d\xa7\x1d\x18\xae\xa3\x3f\xdb\xe6\xd9\xa5\xcd\x60\x2a\x69\xb4\x7d\xc4\x30\x39\x8b\x2c\xea\xb5\x02\x7c\xaf\x57\x7f\x1c\x3f\x5f\x4e\x8f\xbf\xf1\x0a\xef\x01\x00\x23\xde\xac\xf3\xa2\x00\x00\x00")
+
+func dbMigrations0039_add_group_force_update_afterSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0039_add_group_force_update_afterSql,
+		"db/migrations/0039_add_group_force_update_after.sql",
+	)
+}
+
+func dbMigrations0039_add_group_force_update_afterSql() (*asset, error) {
+	bytes, err := dbMigrations0039_add_group_force_update_afterSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0039_add_group_force_update_after.sql", size: 162, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc3, 0xa, 0x1c, 0xc, 0x4, 0x0, 0x4, 0xaa, 0x30, 0x9f, 0xb1, 0xf7, 0xb9, 0x86, 0xcd, 0xbb, 0x55, 0x8d, 0x93, 0x32, 0x84, 0x9e, 0xb0, 0x5, 0x6, 0x84, 0x8e, 0xf7, 0x6e, 0xee, 0x79, 0xce}}
+	return a, nil
+}
+
+var _dbMigrations0040_add_instances_sort_indexesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\x41\x0a\xc2\x30\x10\x45\xf7\x39\xc5\x5f\x56\x4c\x4f\xd0\xad\x57\x70\x1d\x42\x13\xcb\x07\x9d\x0c\x49\xd4\x1e\x5f\x2a\x4a\x5b\x51\xec\xee\x43\xf2\xde\xcc\x9f\xb6\xc5\xfe\xc2\x21\xfb\x1a\x71\x54\x63\xfa\x1c\xa7\x48\x09\x71\x04\x4f\x90\x54\x11\x47\x96\x5a\x40\x29\xd5\x4b\x1f\x9d\x57\x3d\xb3\xf7\x95\x49\x56\x99\xc1\x0d\x39\x5d\xd5\x31\xb8\x5b\xcc\x65\x7a\x67\x18\x91\xe4\x2b\x8b\x66\x0d\x5b\xbc\x69\x8b\x17\x6e\x67\x90\x61\xd7\x6d\xda\x8e\x93\xe1\x73\x2e\x1a\xaa\xc5\xd3\x61\x96\x95\x0f\xe9\x2e\xc6\x84\x9c\x74\x96\xfe\x14\x76\x7f\x3e\x2e\xeb\x6c\xb8\x4b\x67\x1e\x03\x00\x22\xa5\x2b\xd2\x7d\x01\x00\x00")
+
+func dbMigrations0040_add_instances_sort_indexesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0040_add_instances_sort_indexesSql,
+		"db/migrations/0040_add_instances_sort_indexes.sql",
+	)
+}
+
+func dbMigrations0040_add_instances_sort_indexesSql() (*asset, error) {
+	bytes, err := dbMigrations0040_add_instances_sort_indexesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0040_add_instances_sort_indexes.sql", size: 381, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xad, 0x1e, 0xd2, 0x52, 0x3, 0xa7, 0x76, 0x9d, 0x7f, 0x78, 0xed, 0x7, 0x2b, 0x91, 0xae, 0xee, 0xd8, 0x1d, 0x78, 0xd1, 0x23, 0x99, 0xe5, 0xcb, 0xab, 0x65, 0x43, 0xe9, 0x6b, 0xb1, 0x26, 0x11}}
+	return a, nil
+}
+
+var _dbMigrations0041_add_channel_version_constraintSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x74\xcc\xb1\x0d\xc2\x40\x0c\x05\xd0\xde\x53\xfc\x12\x84\xd2\x20\xa5\x4a\xcb\x0a\xd4\xe8\x73\x67\x91\x93\x2e\x76\xe4\x98\xb0\x3e\x2d\x05\x59\xe0\x0d\x03\x2e\x4b\x7b\x05\x53\x71\x5f\x45\xd8\x53\x03\xc9\x67\x57\x94\x99\x66\xda\xc1\x5a\x51\xbc\xbf\x17\xc3\xae\xb1\x35\xb7\x47\x71\xdb\x32\xd8\x2c\xb1\x33\xca\xcc\x38\x5d\xc7\xf1\x3c\x89\xfc\x82\x37\xff\xd8\x7f\xb2\x86\xaf\xc7\xe6\x24\xdf\x01\x00\x5f\x85\x4c\x31\x97\x00\x00\x00")
+
+func dbMigrations0041_add_channel_version_constraintSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0041_add_channel_version_constraintSql,
+		"db/migrations/0041_add_channel_version_constraint.sql",
+	)
+}
+
+func dbMigrations0041_add_channel_version_constraintSql() (*asset, error) {
+	bytes, err := dbMigrations0041_add_channel_version_constraintSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0041_add_channel_version_constraint.sql", size: 151, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xce, 0x5d, 0x3c, 0xab, 0xce, 0x4c, 0x35, 0xa, 0xae, 0xdb, 0xbf, 0x17, 0xa3, 0xd4, 0xee, 0x7b, 0x29, 0x50, 0x85, 0xa6, 0xb0, 0xe1, 0x9e, 0x97, 0x6, 0xc, 0x98, 0xd3, 0xbf, 0x14, 0x39, 0x90}}
+	return a, nil
+}
+
+var _dbMigrations0042_add_instance_update_durationSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x92\x31\x6e\xeb\x30\x0c\x86\x67\xeb\x14\x1c\x6d\x3c\x07\x78\x28\x90\x29\x6b\xaf\xd0\x59\x60\x45\x26\x25\x2a\x4b\x02\x45\xa5\x49\x4f\x5f\x38\x40\x1c\x0f\x75\xdb\xcd\x80\xf9\x7d\xfc\x29\x72\xb7\x83\x7f\x93\x9c\x14\x8d\xe1\xa5\x38\x17\x94\xe7\x4f\xc3\xd7\xc8\x20\x47\x48\xd9\x80\x2f\x52\xad\x82\xa4\x6a\x98\x02\xfb\x56\x08\x8d\x3d\x35\x45\x93\x9c\xa0\x77\x9d\x10\x54\x56\xc1\x08\x45\x65\x42\xbd\xc2\x3b\x5f\x47\xd7\x2d\x8c\x10\x9c\x51\xc3\x1b\x6a\xbf\xff\x3f\xdc\xb4\xa9\xc5\x08\xca\x47\x56\x4e\x81\x1f\x7e\xe8\x85\x06\xc8\x09\x88\x23\x1b\x43\xc0\x1a\x90\x78\x74\x1d\x96\x12\x25\xdc\x9a\x7a\x21\x68\x4d\xe8\x5b\xd3\xaa\x6e\x5b\x76\xd2\xdc\xca\xa2\x59\xd1\xb7\x1f\x75\x1b\x3c\xb3\xd6\xd9\x7c\x9f\xe7\x69\xbf\x1f\x46\xd7\x55\x43\x35\x26\x6f\x15\x4c\x26\xae\x86\x53\xb1\xcf\x25\xdf\xe8\xba\x90\xa7\x12\xf9\x87\x1a\x37\x1c\x96\x0d\x48\x22\xbe\xfc\x71\x03\xfe\x3e\x8b\x5f\xb7\xf0\x42\x97\xf9\x19\xb7\x28\xe8\xef\xd8\x08\x6b\x6e\x0e\xb1\x3e\x8b\xe7\xfc\x91\x9c\x23\xcd\xe5\x71\x16\xbf\x04\x3a\xb8\xaf\x01\x00\x10\x92\xb5\xb1\x58\x02\x00\x00")
+
+func dbMigrations0042_add_instance_update_durationSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0042_add_instance_update_durationSql,
+		"db/migrations/0042_add_instance_update_duration.sql",
+	)
+}
+
+func dbMigrations0042_add_instance_update_durationSql() (*asset, error) {
+	bytes, err := dbMigrations0042_add_instance_update_durationSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0042_add_instance_update_duration.sql", size: 600, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x70, 0x80, 0x8a, 0x2b, 0x25, 0xba, 0x38, 0xfa, 0x42, 0xf4, 0x43, 0x12, 0x12, 0xd5, 0x37, 0x5b, 0x6, 0xcc, 0xfe, 0xc7, 0xc3, 0x24, 0x8e, 0x27, 0x7b, 0xd6, 0xa3, 0xc9, 0xca, 0x4c, 0x9a, 0xf9}}
+	return a, nil
+}
+
+var _dbMigrations0043_add_instance_event_fingerprintSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x90\xc1\x4a\xc4\x40\x0c\x86\xcf\xcd\x53\xe4\xd8\x62\x17\x3c\xa8\x97\xbd\xfa\x0a\x9e\x4b\x9c\x49\xd7\xe0\x34\x33\x64\xd2\xd5\xfa\xf4\xa2\xa2\x1d\x41\xd9\x5b\x20\x3f\x1f\xdf\xff\x1f\x0e\x78\xb5\xc8\xc9\xc8\x19\x1f\x0a\x40\x30\xfe\x38\x9d\x1e\x13\xa3\xcc\xa8\xd9\x91\x5f\xa5\x7a\x45\xd1\xea\xa4\x81\x27\x3e\xb3\xfa\x34\x8b\x9e\xd8\x8a\x89\x3a\xf6\xd0\xfd\x7c\x25\xe2\x99\x2c\x3c\x91\xf5\xb7\xd7\xc3\x27\x40\xd7\x94\xd0\x78\x66\x63\x0d\xbc\x93\xb0\x97\x38\x60\x56\x8c\x9c\xd8\x19\x03\xd5\x40\x91\x47\xe8\xa8\x94\x24\x81\x5c\xb2\x4e\x12\x71\x5d\x25\xfe\x49\x6a\x72\xff\xc3\x5a\xd3\x6f\xb5\xbb\x9b\x5d\x6d\x84\xee\xab\x76\x9c\xbc\xa2\xcb\xc2\xd5\x69\x29\xfe\xd6\x26\x8a\xc9\x42\xb6\xe1\x33\x6f\xd8\x37\x65\x47\xfc\xed\x3a\xc0\x70\x04\x68\x67\xbd\xcf\x2f\x0a\x10\x2d\x97\x7d\xd6\x8b\x93\x1e\xe1\x7d\x00\x5f\xfb\xc1\xa1\x9a\x01\x00\x00")
+
+func dbMigrations0043_add_instance_event_fingerprintSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0043_add_instance_event_fingerprintSql,
+		"db/migrations/0043_add_instance_event_fingerprint.sql",
+	)
+}
+
+func dbMigrations0043_add_instance_event_fingerprintSql() (*asset, error) {
+	bytes, err := dbMigrations0043_add_instance_event_fingerprintSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0043_add_instance_event_fingerprint.sql", size: 410, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe9, 0x9a, 0x6b, 0x2, 0x11, 0x52, 0x5f, 0xfa, 0x32, 0xe9, 0x9, 0x99, 0xcb, 0xd4, 0x3d, 0x18, 0x33, 0x4a, 0x4, 0x6e, 0x91, 0x18, 0x7c, 0x16, 0xf9, 0xf6, 0x1f, 0x47, 0x9f, 0x33, 0x6f, 0xf3}}
+	return a, nil
+}
+
+var _dbMigrations0044_add_group_max_concurrent_updatesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\x31\xaa\xc2\x40\x10\x06\xe0\x7e\x4f\xf1\x97\xef\x21\x81\xf4\x41\x2b\xaf\x60\x1d\xc6\xdd\x31\x06\x67\x67\x96\xc9\x2c\xea\xed\x6d\x2d\x44\x3c\xc0\x07\xdf\x30\x60\x57\xd7\xc5\x29\x18\xa7\x96\x12\x49\xb0\x23\xe8\x2c\x8c\xc5\xad\xb7\x0d\x54\x0a\xb2\x49\xaf\x8a\x66\xb2\xe6\xe7\x5c\xe9\x31\x67\xd3\xdc\xdd\x59\x63\xee\xad\x50\xf0\x86\x55\x83\x17\x76\xa8\x05\xb4\x8b\xa0\xf0\x85\xba\x04\x46\xe4\x2b\xe7\x1b\xfe\xbe\xfb\xc3\x1e\xe3\xff\x94\xd2\xfb\xe9\x68\x77\xfd\xb8\x2a\x6e\xed\xa7\xd6\x94\x5e\x03\x00\x51\x44\x71\xb6\xe4\x00\x00\x00")
+
+func dbMigrations0044_add_group_max_concurrent_updatesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0044_add_group_max_concurrent_updatesSql,
+		"db/migrations/0044_add_group_max_concurrent_updates.sql",
+	)
+}
+
+func dbMigrations0044_add_group_max_concurrent_updatesSql() (*asset, error) {
+	bytes, err := dbMigrations0044_add_group_max_concurrent_updatesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0044_add_group_max_concurrent_updates.sql", size: 228, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa1, 0xcc, 0x52, 0x5f, 0x8a, 0x1f, 0xb8, 0xc, 0x18, 0xa2, 0xd8, 0xf1, 0x49, 0xa0, 0x81, 0xba, 0xa1, 0xb5, 0x17, 0xaa, 0xc5, 0x74, 0x18, 0x7b, 0xa0, 0x3d, 0x12, 0x1b, 0x14, 0x43, 0x8c, 0xc9}}
+	return a, nil
+}
+
+var _dbMigrations0045_add_instance_application_quarantinedSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\x31\x0e\xc2\x30\x0c\x85\xe1\x3d\xa7\x78\x23\x08\xf5\x04\x5d\xb9\x02\x73\xe5\x26\x2e\x58\x72\xed\x90\x3a\x2a\xc7\x47\x6c\x1d\x3a\x30\xbe\xe5\xfd\xdf\x30\xe0\xb6\xca\xb3\x51\x30\x1e\x35\x25\xd2\xe0\x86\xa0\x59\x19\x62\x5b\x90\x65\x9e\xa8\x56\x95\x4c\x21\x6e\xa0\x52\x90\x5d\xfb\x6a\x78\x77\x6a\x64\x21\xc6\x05\xb3\xbb\x32\x19\xcc\x03\xd6\x55\x51\x78\xa1\xae\x81\x85\x74\xe3\x31\xe5\xc6\xbf\x84\x58\xe1\x0f\xdc\xce\xbf\x2f\x87\x31\x49\xb9\x62\x7f\x71\xe3\x63\x67\x4c\xe9\x28\xbe\xfb\x6e\x7f\x98\x4b\xf3\x7a\x82\x1e\xd3\x77\x00\xef\xd8\x14\xe9\xfe\x00\x00\x00")
+
+func dbMigrations0045_add_instance_application_quarantinedSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0045_add_instance_application_quarantinedSql,
+		"db/migrations/0045_add_instance_application_quarantined.sql",
+	)
+}
+
+func dbMigrations0045_add_instance_application_quarantinedSql() (*asset, error) {
+	bytes, err := dbMigrations0045_add_instance_application_quarantinedSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0045_add_instance_application_quarantined.sql", size: 254, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe7, 0xb9, 0x7f, 0xc9, 0xb, 0x80, 0x4a, 0xb, 0xa5, 0x80, 0x4d, 0xfd, 0x2e, 0x47, 0x4d, 0xac, 0x7c, 0x99, 0xea, 0x1, 0x13, 0xc6, 0xb0, 0x26, 0xdb, 0x78, 0x3c, 0x40, 0x9a, 0xb6, 0x7c, 0xa}}
+	return a, nil
+}
+
+var _dbMigrations0046_add_package_severitySql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xd0\xb1\x6a\xc4\x30\x0c\xc6\xf1\xb9\x7e\x0a\x6d\x4e\xe8\x1d\x94\xae\xb7\xf6\x15\x3a\x07\x9d\xad\xe6\x44\x1c\xcb\xc8\x72\x42\xde\xbe\x5b\x48\x4b\x68\x6f\xb6\x3f\xf8\xe9\x7f\xbd\xc2\xeb\xcc\xa3\xa2\x11\x7c\x16\xe7\x30\x19\x29\x18\xde\x13\x41\xc1\x30\xe1\x48\x80\x31\x42\x90\xd4\xe6\x0c\x95\x16\x52\xb6\x0d\x16\xd4\xf0\x40\xed\xde\xdf\x7a\xc8\x62\x90\x5b\x4a\x10\xe9\x0b\x5b\x32\xf0\x2a\xcd\x38\x93\x77\x2f\xe1\x41\x61\x82\x6e\xdf\x71\x86\x6e\x7f\xbe\x80\xe7\xb9\x88\x1a\x66\xf3\x17\xf0\x41\xd9\x38\x60\xf2\x7d\x7f\xfb\x21\x19\x55\x5a\xa9\x47\x48\x91\xc4\x61\x1b\x56\xce\x51\xd6\x3a\xdc\xb7\x82\xb5\x0e\x67\xbc\xdd\xf0\xcf\xe4\x79\x99\x3b\x46\xfb\x90\x35\x9f\x67\x8b\x2a\xe5\x77\xb7\xd3\xb3\x8e\x1f\xff\x46\xde\xdc\xf7\x00\xe5\xa3\xd4\xfc\xb0\x01\x00\x00")
+
+func dbMigrations0046_add_package_severitySqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0046_add_package_severitySql,
+		"db/migrations/0046_add_package_severity.sql",
+	)
+}
+
+func dbMigrations0046_add_package_severitySql() (*asset, error) {
+	bytes, err := dbMigrations0046_add_package_severitySqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0046_add_package_severity.sql", size: 432, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc4, 0xc8, 0x9f, 0x63, 0xd7, 0x19, 0x8e, 0x67, 0x80, 0x8f, 0xdf, 0xc4, 0x50, 0x1e, 0x45, 0xaa, 0xff, 0x96, 0x7a, 0xa9, 0xf8, 0xdb, 0x7b, 0x22, 0x47, 0x69, 0x3d, 0x8, 0xe5, 0x6e, 0xc, 0x58}}
+	return a, nil
+}
+
+var _dbMigrations0047_add_instance_regionSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x74\xcc\x31\x0e\x02\x20\x0c\x05\xd0\xbd\xa7\xf8\xa3\xc6\x90\xe0\xcc\xea\x15\x3c\x40\x85\x06\x49\xa0\x25\xb5\xea\xf5\x5d\x75\xf0\x02\x2f\x25\x9c\xd6\xe8\xce\x21\xb8\x6e\x22\x9e\x21\x8e\xe0\xdb\x14\x0c\x7d\x04\x6b\x15\x70\x6b\xa8\x36\x9f\x4b\xe1\xd2\x87\x29\x5e\xec\xf5\xce\x7e\x38\xe7\x7c\x2c\x44\xdf\xca\xc5\xde\xfa\xc7\x69\x6e\xfb\x17\x2a\xf4\x19\x00\xbc\xd0\xd1\x9d\x81\x00\x00\x00")
+
+func dbMigrations0047_add_instance_regionSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0047_add_instance_regionSql,
+		"db/migrations/0047_add_instance_region.sql",
+	)
+}
+
+func dbMigrations0047_add_instance_regionSql() (*asset, error) {
+	bytes, err := dbMigrations0047_add_instance_regionSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0047_add_instance_region.sql", size: 129, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xdf, 0x21, 0xe9, 0x19, 0xcf, 0xa6, 0x46, 0x28, 0xac, 0x1a, 0x22, 0x38, 0xa, 0x8e, 0x92, 0x1d, 0xa8, 0x1e, 0x49, 0x80, 0xb6, 0x2e, 0x0, 0x26, 0xf4, 0x16, 0xd1, 0x5a, 0x2f, 0xaf, 0x81, 0xeb}}
+	return a, nil
+}
+
+var _dbMigrations0048_add_event_daily_aggregateSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x90\x41\x4e\xc4\x30\x0c\x45\xd7\xf5\x29\xbc\x6c\x45\xe7\x04\xb3\xe5\x0a\xac\x2b\x93\xfc\x89\x2c\x32\x49\xe4\x24\x40\x6e\x8f\x0a\x8c\xd4\x45\x61\x17\xe5\x7f\x3d\xdb\xef\x72\xe1\xa7\xbb\x06\x93\x06\x7e\x29\x44\xce\xb0\x3f\x9b\xbc\x46\x30\xde\x91\xda\xe6\x45\xe3\xd8\x24\x04\x43\xd8\xb3\x99\xa6\x60\xb9\x97\x4d\x3d\xf7\xae\x9e\x53\x6e\x9c\x7a\x8c\x6c\xb8\xc1\x90\x1c\x2a\x7f\x37\x2a\xcf\xea\x17\xce\x89\x3d\x22\x1a\xd8\x49\x75\xe2\xb1\xd2\x24\xa5\x44\x75\xd2\x34\xa7\x7f\x39\x87\xde\xdf\x30\x2f\x83\xfd\xbe\xda\x83\xb0\xd2\xd4\x46\x01\x6b\x6a\x08\xb0\xe3\xbf\xa1\xf6\xd8\xce\x12\x97\x7b\x3a\x0d\x8a\xe9\x5d\x6c\xf0\x1b\x06\xcf\x8f\xd3\x57\xf6\x32\x56\xde\xc7\xac\xfc\x03\x5d\x68\xb9\x12\x1d\x8d\x3e\xe7\x8f\x44\xe4\x2d\x97\x5f\xa3\x7a\x63\x7c\x6a\x6d\xf5\xdc\xed\x95\xbe\x06\x00\x4f\x01\xea\xbe\x90\x01\x00\x00")
+
+func dbMigrations0048_add_event_daily_aggregateSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0048_add_event_daily_aggregateSql,
+		"db/migrations/0048_add_event_daily_aggregate.sql",
+	)
+}
+
+func dbMigrations0048_add_event_daily_aggregateSql() (*asset, error) {
+	bytes, err := dbMigrations0048_add_event_daily_aggregateSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0048_add_event_daily_aggregate.sql", size: 400, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x29, 0xa9, 0xcc, 0x43, 0xc9, 0x11, 0x20, 0x8e, 0x50, 0x4, 0x6, 0xac, 0x3e, 0xc4, 0x0, 0x6d, 0xc7, 0x82, 0xed, 0xe2, 0xdd, 0xa, 0x6e, 0xbe, 0x9, 0xdb, 0x20, 0xd8, 0x7a, 0x85, 0xea, 0x15}}
+	return a, nil
+}
+
+var _dbMigrations0049_add_instance_package_overrideSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x91\xc1\x4e\xc3\x40\x0c\x44\xcf\xf1\x57\xf8\x98\x88\x54\xe2\xc2\xa9\x57\x7e\x81\x73\x64\xd6\x4e\xb1\xba\xd9\x5d\x79\xbd\x85\xf2\xf5\x08\x41\xa3\x20\x91\xde\x2c\xcd\xe8\xd9\x9e\x39\x1c\xf0\x61\xd1\x93\x91\x0b\xbe\x14\x80\x60\xf2\x3d\x3a\xbd\x46\x41\x4d\xd5\x29\x05\x99\x0a\x85\x33\x9d\x64\xca\x17\x31\x53\x16\xec\xa1\x5b\x45\x65\xbc\x90\x85\x37\xb2\xfe\xe9\x71\xc0\x94\x1d\x53\x8b\x11\x4d\x66\x31\x49\x41\xea\x0a\xc2\x5e\x79\xc0\x9c\x90\x25\x8a\x0b\x06\xaa\x81\x58\x46\xe8\xa8\x94\xa8\x81\x5c\x73\x9a\x94\xb1\x35\xe5\x7f\x49\x1b\xdf\x3e\xec\x76\xee\x3d\xd0\xaf\x67\x1f\xf2\x93\x04\x4f\x5e\xd1\x75\x91\xea\xb4\x14\xff\x44\x96\x99\x5a\x74\x0c\xcd\x4c\x92\x4f\xab\xb6\x6e\x19\xa1\x2b\xa6\x0b\xd9\x15\xcf\x72\xc5\x7e\x13\xd4\x88\x7f\xff\x1c\x60\x38\x02\x6c\x3b\x78\xce\xef\x09\x80\x2d\x97\x5b\x07\x33\xca\x87\x56\xaf\xfb\x6d\x1c\xe1\x6b\x00\x26\xe8\x64\x29\xc6\x01\x00\x00")
+
+func dbMigrations0049_add_instance_package_overrideSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0049_add_instance_package_overrideSql,
+		"db/migrations/0049_add_instance_package_override.sql",
+	)
+}
+
+func dbMigrations0049_add_instance_package_overrideSql() (*asset, error) {
+	bytes, err := dbMigrations0049_add_instance_package_overrideSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0049_add_instance_package_override.sql", size: 454, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x46, 0xc7, 0xf6, 0x5a, 0xe0, 0xf, 0x67, 0x5f, 0x95, 0x8c, 0xc0, 0x37, 0x4e, 0xf6, 0xd0, 0xa0, 0xd4, 0xc6, 0xa5, 0x4, 0x97, 0x6c, 0x8e, 0x59, 0x65, 0x20, 0x8e, 0x79, 0x13, 0x7, 0x40, 0xc7}}
+	return a, nil
+}
+
+var _dbMigrations0050_add_group_rollout_cohortsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xcf\x41\x0a\xc2\x30\x10\x85\xe1\x7d\x4e\xf1\x96\x8a\x14\xba\x2f\xba\xf2\x0a\xae\x4b\x4c\xc6\x36\x38\xcd\x84\xe9\x44\xf1\xf6\x6e\xba\x50\x51\xe9\x01\x3e\xde\xfb\x9b\x06\xbb\x29\x0d\xea\x8d\x70\x2a\xce\x79\x36\x52\x98\x3f\x33\x61\x50\xa9\x65\x86\x8f\x11\x41\xb8\x4e\x19\x45\x38\x85\x47\xaf\xc2\x2c\xd5\xfa\x20\xa3\xa8\xcd\x48\xd9\x68\x20\x45\x16\x43\xae\xcc\x88\x74\xf1\x95\x0d\x2d\xc2\x48\xe1\x8a\xcd\x0f\x78\xd8\xa3\xdd\x76\xeb\x46\x7d\xb0\x74\xa3\x85\xae\x9f\x7c\x67\xcb\xa0\x7b\xcd\x3e\xca\x3d\x7f\x0d\x8f\x2a\xe5\xdf\x89\x6e\x25\xfa\xa8\xee\xdc\x73\x00\x7b\x40\x98\xd0\x75\x01\x00\x00")
+
+func dbMigrations0050_add_group_rollout_cohortsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0050_add_group_rollout_cohortsSql,
+		"db/migrations/0050_add_group_rollout_cohorts.sql",
+	)
+}
+
+func dbMigrations0050_add_group_rollout_cohortsSql() (*asset, error) {
+	bytes, err := dbMigrations0050_add_group_rollout_cohortsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0050_add_group_rollout_cohorts.sql", size: 373, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb8, 0xb8, 0x9, 0x92, 0xc0, 0x9, 0x28, 0x4a, 0xd5, 0xdc, 0xaa, 0x30, 0x79, 0x55, 0x61, 0x28, 0x79, 0xf, 0x8b, 0xfb, 0x84, 0xf7, 0x87, 0x70, 0x29, 0xe7, 0x1f, 0xa2, 0x95, 0xa6, 0xb0, 0xe3}}
+	return a, nil
+}
+
+var _dbMigrations0051_add_group_min_bake_timeSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\xcd\xb1\x0a\xc2\x30\x10\x06\xe0\x3d\x4f\xf1\x6f\x6d\x91\x82\xb8\x56\x9c\x7c\x05\xe7\x70\xbd\x1c\x6d\x68\x92\x0b\x67\xaa\xf8\xf6\xae\x0e\xc5\xfd\x83\x6f\x1c\x71\xca\x71\x31\x6a\x82\x47\x75\x8e\x52\x13\x43\xa3\x39\x09\x16\xd3\xbd\x3e\x41\x21\x80\x35\xed\xb9\xa0\x6a\x8a\xfc\xf1\x39\x16\x3f\xd3\x26\xbe\xc5\x2c\x78\x91\xf1\x4a\xd6\x5f\xce\x03\x78\x15\xde\xd0\x1f\xba\xeb\x0d\x5d\x37\x4c\xce\xfd\x9e\x77\x7d\x97\xc3\x35\x98\xd6\x7f\xed\xe4\xbe\x03\x00\x95\xdd\xa1\x8b\xbb\x00\x00\x00")
+
+func dbMigrations0051_add_group_min_bake_timeSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0051_add_group_min_bake_timeSql,
+		"db/migrations/0051_add_group_min_bake_time.sql",
+	)
+}
+
+func dbMigrations0051_add_group_min_bake_timeSql() (*asset, error) {
+	bytes, err := dbMigrations0051_add_group_min_bake_timeSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0051_add_group_min_bake_time.sql", size: 187, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xb5, 0x65, 0xfe, 0xe4, 0xd4, 0x55, 0x67, 0x59, 0xe5, 0xb4, 0xd3, 0xba, 0x41, 0x2c, 0xa5, 0xc6, 0x86, 0x5e, 0xc2, 0xb0, 0x16, 0x20, 0x4f, 0xb6, 0x9c, 0x20, 0xa0, 0x99, 0xa3, 0xd5, 0xd7, 0x8b}}
+	return a, nil
+}
+
+var _dbMigrations0052_add_application_disable_ping_persistenceSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\xcd\x31\x0a\xc3\x30\x0c\x05\xd0\xdd\xa7\xf8\x7b\xc9\x09\xb2\xf6\x0a\x9d\x83\x62\x2b\x41\xa0\x48\xc2\x96\xe9\xf5\x4b\xb7\x2e\x25\xf3\x1b\xde\xb2\xe0\x71\xc9\xd9\x29\x19\xaf\x28\x85\x34\xb9\x23\x69\x57\x06\x45\xa8\x54\x4a\x71\x03\xb5\x86\xea\x3a\x2f\x43\x93\xf1\xe5\x2d\xc4\xce\x2d\xb8\x0f\x19\xc9\x56\x19\xbb\xbb\x32\x19\xcc\x13\x36\x55\xd1\xf8\xa0\xa9\x89\x83\x74\xf0\x5a\xca\x6f\xf6\xf4\xb7\xfd\xef\x5a\xf7\xb8\xfb\xd6\xf2\x19\x00\x90\xda\xf5\xca\xbd\x00\x00\x00")
+
+func dbMigrations0052_add_application_disable_ping_persistenceSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0052_add_application_disable_ping_persistenceSql,
+		"db/migrations/0052_add_application_disable_ping_persistence.sql",
+	)
+}
+
+func dbMigrations0052_add_application_disable_ping_persistenceSql() (*asset, error) {
+	bytes, err := dbMigrations0052_add_application_disable_ping_persistenceSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0052_add_application_disable_ping_persistence.sql", size: 189, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xcb, 0x10, 0xa3, 0x84, 0xec, 0xbe, 0x6e, 0x1d, 0x42, 0x47, 0xc0, 0x12, 0x81, 0x12, 0x12, 0x39, 0xb8, 0x2c, 0x8, 0x56, 0x93, 0xfa, 0x32, 0x9f, 0xd6, 0x4c, 0x3b, 0xdc, 0x70, 0xd3, 0xf0, 0xff}}
+	return a, nil
+}
+
+var _dbMigrations0053_add_instance_first_seen_and_version_changed_atSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x91\x41\x4e\xc5\x30\x0c\x44\xf7\x39\xc5\xec\xa1\x5c\xa0\x62\xc7\x11\x60\x1d\x99\xc4\x6d\x23\x52\x27\x8a\x1d\x8a\x38\x3d\xfa\x85\xff\xa9\xf4\x41\x88\xf5\x78\x9e\x47\x33\xc3\x80\x9b\x35\xcd\x8d\x8c\xf1\x54\x9d\xa3\x6c\xdc\x60\xf4\x9c\x19\x49\xd4\x48\x02\x83\x62\x44\x28\xb9\xaf\x82\x29\x35\x35\xaf\xcc\x02\x4b\x2b\xab\xd1\x5a\xed\x1d\x91\x27\xea\xd9\x10\x7a\x6b\x2c\xe6\x2f\x1a\xa4\x18\xa4\xe7\x3c\xba\x5e\x23\xd9\x81\xaa\x6c\x47\xdc\x3d\x42\x63\x32\x8e\xde\x74\x74\x6e\x18\xf0\xb8\xf0\xfe\x04\xb6\x30\xf8\x2d\xa9\x25\x99\x2f\x7e\xc5\x5c\xec\x24\xa5\x76\x7e\x8b\x57\x6e\x9a\x8a\x20\x29\xba\xbc\x48\xd9\xe4\x16\x5a\x4e\xac\xcf\xbb\xb3\x4e\x33\x43\x8d\x9a\x29\x42\xe9\xb2\x73\xa7\x56\x56\x48\xd9\xee\x7e\xec\xc0\x53\xad\x39\x05\xb2\xdd\xfe\xdd\xc7\x17\xd1\x87\x85\x64\xe6\xe8\xc9\xfe\xdd\x8b\x3b\x6e\xf0\x50\x36\xf9\x65\x85\xd8\x4a\xbd\x9e\x61\xfc\x3b\xee\xd1\x78\x9d\x77\x74\x1f\x03\x00\x01\x79\xc0\xa6\x04\x02\x00\x00")
+
+func dbMigrations0053_add_instance_first_seen_and_version_changed_atSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0053_add_instance_first_seen_and_version_changed_atSql,
+		"db/migrations/0053_add_instance_first_seen_and_version_changed_at.sql",
+	)
+}
+
+func dbMigrations0053_add_instance_first_seen_and_version_changed_atSql() (*asset, error) {
+	bytes, err := dbMigrations0053_add_instance_first_seen_and_version_changed_atSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0053_add_instance_first_seen_and_version_changed_at.sql", size: 516, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc, 0xd4, 0x74, 0x30, 0x35, 0x2d, 0x2e, 0x3f, 0xb, 0x4c, 0xbc, 0xa6, 0xfa, 0x33, 0x65, 0x2b, 0xeb, 0xe1, 0xf2, 0xa2, 0xc8, 0x42, 0x35, 0x6c, 0xb7, 0xdc, 0x27, 0x1, 0x6e, 0x67, 0xe2, 0xe2}}
+	return a, nil
+}
+
+var _dbMigrations0054_add_group_rollout_scheduleSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\xce\x41\x0a\x02\x31\x0c\x05\xd0\x7d\x4f\x91\xbd\xcc\x09\xba\xf5\x0a\xae\x4b\x34\x61\x18\x48\x9b\x92\xfc\x22\x78\x7a\xb7\x82\x0a\x73\x80\x07\x6f\xdb\xe8\xd2\x8f\x3d\x18\x4a\xb7\x59\x0a\x1b\x34\x08\x7c\x37\xa5\x3d\x7c\xcd\x24\x16\xa1\x87\xdb\xea\x83\xc2\xcd\x7c\xa1\x25\x38\x90\x8d\x41\x38\xba\x26\xb8\x4f\xbc\xea\x49\xac\x43\xbe\x69\xf9\x8c\x5c\xfd\x39\x7e\x56\x24\x7c\xfe\xbd\xd4\xb3\x42\x87\x64\x63\xd4\xf2\x1e\x00\xd2\x0d\x01\xb5\xfd\x00\x00\x00")
+
+func dbMigrations0054_add_group_rollout_scheduleSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0054_add_group_rollout_scheduleSql,
+		"db/migrations/0054_add_group_rollout_schedule.sql",
+	)
+}
+
+func dbMigrations0054_add_group_rollout_scheduleSql() (*asset, error) {
+	bytes, err := dbMigrations0054_add_group_rollout_scheduleSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0054_add_group_rollout_schedule.sql", size: 253, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x20, 0x10, 0x72, 0xc1, 0xeb, 0x37, 0xdf, 0x9, 0xcd, 0xb9, 0x58, 0xdf, 0x55, 0xf0, 0x8c, 0xff, 0xde, 0x58, 0x33, 0x40, 0xed, 0x7a, 0x8e, 0xd1, 0xbd, 0xa8, 0xac, 0x12, 0x84, 0x62, 0xd4, 0x67}}
+	return a, nil
+}
+
+var _dbMigrations0055_add_instance_noteSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x75\x00\x8a\xff\x2d\x2d\x20\x2b\x6d\x69\x67\x72\x61\x74\x65\x20\x55\x70\x0a\x0a\x61\x6c\x74\x65\x72\x20\x74\x61\x62\x6c\x65\x20\x69\x6e\x73\x74\x61\x6e\x63\x65\x20\x61\x64\x64\x20\x63\x6f\x6c\x75\x6d\x6e\x20\x6e\x6f\x74\x65\x20\x74\x65\x78\x74\x3b\x0a\x0a\x2d\x2d\x20\x2b\x6d\x69\x67\x72\x61\x74\x65\x20\x44\x6f\x77\x6e\x0a\x0a\x61\x6c\x74\x65\x72\x20\x74\x61\x62\x6c\x65\x20\x69\x6e\x73\x74\x61\x6e\x63\x65\x20\x64\x72\x6f\x70\x20\x63\x6f\x6c\x75\x6d\x6e\x20\x6e\x6f\x74\x65\x3b\x0a\x03\x00\x82\xa3\x0b\x04\x75\x00\x00\x00")
+
+func dbMigrations0055_add_instance_noteSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0055_add_instance_noteSql,
+		"db/migrations/0055_add_instance_note.sql",
+	)
+}
+
+func dbMigrations0055_add_instance_noteSql() (*asset, error) {
+	bytes, err := dbMigrations0055_add_instance_noteSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0055_add_instance_note.sql", size: 117, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x88, 0x48, 0xb0, 0x1d, 0xb7, 0xe6, 0x64, 0x57, 0xb9, 0x8d, 0xb8, 0x50, 0x8b, 0x3b, 0x9f, 0xdf, 0x9b, 0x53, 0xad, 0x61, 0xd7, 0x2a, 0x1d, 0xd5, 0xcd, 0x13, 0x9a, 0x6c, 0x57, 0x4a, 0x88, 0xaf}}
+	return a, nil
+}
+
+var _dbMigrations0056_add_track_aliasesSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\x90\xb1\x4e\xc3\x40\x10\x44\x6b\xef\x57\x4c\x17\x5b\x38\x0d\x12\x34\x41\x54\xfc\x02\xb5\xb5\xdc\x6d\xc8\x2a\xe7\xf3\x69\x6f\x0d\x84\xaf\x47\xc4\xc8\x98\x86\x6e\xa5\x9d\x19\xcd\xbc\xfd\x1e\x37\xa3\xbe\x1a\xbb\xe0\xb9\x10\x05\x93\xef\xd3\xf9\x25\x09\xdc\x38\x9c\x07\x4e\xca\x15\x2d\x35\x5c\x4a\xd2\xc0\xae\x53\x1e\x34\x62\x9e\x35\x22\x4f\x8e\x3c\xa7\x04\x93\xa3\x98\xe4\x20\x15\x1b\x1d\x5a\x8d\x1d\xa6\x8c\x28\x49\x5c\x10\xb8\x06\x8e\xd2\x53\xb3\xa4\xbe\xb1\x85\x13\x5b\x7b\x7b\x77\xdf\xfd\x66\x85\x93\x84\x33\xda\x45\xf2\xf0\x88\xdd\xae\xeb\xa9\xb9\xb6\xf9\xdf\xb1\x48\x56\xc7\x32\x26\x0e\x5e\xe1\x3a\x4a\x75\x1e\x8b\x7f\x22\xca\x91\xe7\xe4\x08\xb3\x99\x64\x1f\xd6\xdf\x9a\xd7\x53\x53\x4c\x47\xb6\x0b\xce\x72\x41\xfb\x77\x79\x8f\x6b\xb3\x8e\xba\x03\xd1\x16\xe0\xd3\xf4\x9e\x89\xa2\x4d\xe5\x07\xa0\x1e\x21\x1f\x5a\xbd\x6e\x51\x1e\xe8\x6b\x00\x8c\x7f\xf9\xcf\x75\x01\x00\x00")
+
+func dbMigrations0056_add_track_aliasesSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0056_add_track_aliasesSql,
+		"db/migrations/0056_add_track_aliases.sql",
+	)
+}
+
+func dbMigrations0056_add_track_aliasesSql() (*asset, error) {
+	bytes, err := dbMigrations0056_add_track_aliasesSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0056_add_track_aliases.sql", size: 373, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xeb, 0x62, 0xe6, 0x20, 0xc0, 0xa5, 0x11, 0xc6, 0x7, 0xa9, 0x17, 0x1b, 0x51, 0x2f, 0xfd, 0xa, 0xd, 0xbf, 0x4, 0x3e, 0x48, 0xf4, 0x15, 0xf7, 0x93, 0xef, 0xf7, 0x1c, 0xde, 0xe2, 0x1c, 0x86}}
+	return a, nil
+}
+
+var _dbMigrations0057_add_group_policy_observe_onlySql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\xd0\xb1\x4a\x04\x41\x0c\xc6\xf1\x7e\x9e\x22\xa5\x22\xd7\x08\x57\x6d\xeb\x2b\x58\x0f\xd9\x99\xdc\x39\x90\x49\x86\x24\xb3\x72\x3e\xbd\x08\x16\x2b\xa8\x1c\x6c\x1f\x7e\xe4\xfb\x9f\x4e\xf0\xd4\xdb\xd5\x30\x08\x5e\x47\x4a\xc8\x41\x06\x81\x2b\x13\x5c\x4d\xe7\x70\xc0\x5a\xa1\x28\xcf\x2e\x30\x94\x5b\xb9\x65\x5d\x9d\x6c\xa3\xac\xc2\x37\x58\x55\x99\x50\xa0\xd2\x05\x27\x07\x5c\x90\x9d\x40\x34\x40\x26\xf3\xf2\x43\x6c\xe2\x81\x52\x28\xe3\x18\xdc\x0a\x46\x53\xd9\xfb\xdf\x70\xcd\x73\x54\x0c\xca\x1b\x99\x7f\x9d\x6c\x68\xe5\x0d\xed\xe1\xf9\x7c\x7e\x3c\x26\x86\x43\xb4\x4e\x1e\xd8\x47\x7c\x2c\x29\xed\x03\xbc\xe8\xbb\xfc\x9a\xa0\x9a\x8e\x7f\x1a\xdc\xf1\xd2\x5e\xf8\x63\xe5\x41\x25\x7c\x49\x9f\x03\x00\x2f\x5c\x65\x64\xce\x01\x00\x00")
+
+func dbMigrations0057_add_group_policy_observe_onlySqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0057_add_group_policy_observe_onlySql,
+		"db/migrations/0057_add_group_policy_observe_only.sql",
+	)
+}
+
+func dbMigrations0057_add_group_policy_observe_onlySql() (*asset, error) {
+	bytes, err := dbMigrations0057_add_group_policy_observe_onlySqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0057_add_group_policy_observe_only.sql", size: 462, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xda, 0xcf, 0x5d, 0xf7, 0x5, 0x56, 0x21, 0x8, 0xe3, 0x47, 0x63, 0x7, 0xff, 0x8f, 0x42, 0x17, 0xb6, 0x4b, 0xef, 0xea, 0x69, 0x15, 0x61, 0x55, 0x38, 0xa4, 0xbf, 0xf8, 0xe2, 0x4d, 0x8f, 0x43}}
+	return a, nil
+}
+
+var _dbMigrations0058_add_package_artifactsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x4c\x51\x41\x8e\xe2\x30\x10\x3c\xc7\xaf\xe8\x5b\x12\x6d\x90\x56\x08\xb8\xb0\xda\xd3\x7e\x61\xcf\x51\x63\x57\x88\x45\xe2\x84\x76\x9b\x19\x78\xfd\x28\xcc\x60\x72\xb3\xaa\xaa\xab\xdd\x55\x9b\x0d\xfd\x1a\xfd\x59\x58\x41\xff\x67\x63\xac\x60\x79\x2a\x9f\x06\xd0\xcc\xf6\xc2\x67\xb4\x2c\xea\x3b\xb6\x4a\x95\x29\xbc\xa3\x94\xbc\xa3\x59\xfc\xc8\x72\xa7\x0b\xee\xe4\xd0\x71\x1a\xf4\x49\xb4\x67\x04\x2c\x76\xed\x6d\x57\xd5\x8d\x29\x5e\x26\xaf\xc1\x30\x29\x85\x34\x0c\x24\xe8\x20\x08\x16\xf1\xb5\x88\x2a\xef\x6a\x9a\x02\x39\x0c\x50\x90\xe5\x68\xd9\xa1\x31\x45\xe0\x11\x74\x63\xb1\x3d\x4b\xb5\xdd\x1f\xea\xb7\x8d\xed\x61\x2f\x54\x3d\x15\x7f\xfe\x52\x59\x2e\x4b\xa3\x7f\xac\xf4\xbf\x17\xa8\xe7\xd8\x67\xe8\xb0\x5b\xa0\xd8\xf3\x76\x7f\x58\x83\xf9\x94\xb2\x6c\x4c\x21\xb8\x26\x2f\x70\x74\x9a\xa6\x01\x1c\x32\xab\x92\x90\x7f\xd0\x98\xe2\x3b\x35\xd7\x6a\x24\xf5\x23\xa2\xf2\x38\xeb\x23\xcb\x6d\x12\x41\xd0\x36\x73\xeb\xd9\x14\xfc\x35\x81\xaa\x77\x4e\x0d\x2d\xc7\xd4\xa6\x3e\x1a\xb3\xee\xe7\xdf\xf4\x11\x8c\x71\x32\xcd\x3f\xfd\xf8\x8e\xf0\xe9\xa3\xe6\x00\x5b\x16\xf5\x1d\x5b\x3d\x9a\xaf\x01\x00\x1b\xd8\x2f\x73\xd9\x01\x00\x00")
+
+func dbMigrations0058_add_package_artifactsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0058_add_package_artifactsSql,
+		"db/migrations/0058_add_package_artifacts.sql",
+	)
+}
+
+func dbMigrations0058_add_package_artifactsSql() (*asset, error) {
+	bytes, err := dbMigrations0058_add_package_artifactsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0058_add_package_artifacts.sql", size: 473, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc6, 0xf7, 0xfa, 0xde, 0x34, 0xea, 0xf6, 0x50, 0xbd, 0xba, 0xa1, 0x76, 0x3a, 0x77, 0x3b, 0xb8, 0x5f, 0xfc, 0xe4, 0x81, 0x68, 0x74, 0xc8, 0x17, 0x4b, 0xee, 0x19, 0x84, 0x1a, 0xa1, 0x23, 0x6c}}
+	return a, nil
+}
+
+var _dbMigrations0059_add_group_safe_mode_failure_thresholdSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9c\xd0\x31\x6e\xc3\x30\x0c\x85\xe1\x5d\xa7\x78\x5b\x62\x14\x01\x8c\xae\x6e\x33\xf5\x0a\x9d\x0d\x56\xa4\x6d\xa1\xb4\x28\xd0\x52\x85\xde\xbe\x4b\x87\x0e\x05\x12\xe4\x00\xfc\xf9\xf0\x5d\x2e\x78\xda\xd3\xea\x54\x05\xef\x25\x04\xd2\x2a\x8e\x4a\x1f\x2a\x58\xdd\x5a\x39\x40\xcc\x88\xa6\x6d\xcf\x28\xa6\x29\x7e\xcf\x07\x2d\x32\xef\xc6\x32\x2f\x94\xb4\xb9\xcc\x75\x73\x39\x36\x53\x46\xca\x55\x56\x71\xb0\x2c\xd4\xb4\x62\x44\xb6\x8a\xdc\x54\x11\x37\x89\x9f\x38\xdf\x11\xb9\xbe\x62\x1c\xa6\x47\xc7\xf4\x94\xd9\x3a\xbe\xc8\xe3\x46\x7e\x7e\x1e\x87\x9b\xaf\x7f\x4f\x5e\xae\x38\x9d\x86\x29\x84\xbf\x2c\x6f\xd6\xf3\xbf\x30\xec\x56\xee\x97\x99\x1e\x4e\xf4\x94\xd9\xfa\x14\x7e\x06\x00\x0a\xf8\x3b\x5e\xac\x01\x00\x00")
+
+func dbMigrations0059_add_group_safe_mode_failure_thresholdSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0059_add_group_safe_mode_failure_thresholdSql,
+		"db/migrations/0059_add_group_safe_mode_failure_threshold.sql",
+	)
+}
+
+func dbMigrations0059_add_group_safe_mode_failure_thresholdSql() (*asset, error) {
+	bytes, err := dbMigrations0059_add_group_safe_mode_failure_thresholdSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0059_add_group_safe_mode_failure_threshold.sql", size: 428, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xaf, 0xa1, 0x78, 0xf, 0xe4, 0xe8, 0xa9, 0xa5, 0xaa, 0xe4, 0x86, 0x11, 0x42, 0x6b, 0x34, 0xf9, 0x1d, 0x83, 0x94, 0x9b, 0x53, 0xdb, 0x24, 0x4, 0x39, 0x95, 0x3, 0x4c, 0x2d, 0xc5, 0xdd, 0x6}}
+	return a, nil
+}
+
+var _dbMigrations0060_add_group_rollout_orderSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x7c\xce\xb1\x4a\xc5\x40\x10\x85\xe1\xda\x7d\x8a\xd3\x6d\x82\xf7\x82\xd8\xa6\xf5\x15\xac\xc3\xb8\x3b\x49\x16\x27\x3b\xcb\x64\xd6\xe0\xdb\x5b\x89\x16\xe1\x96\x07\x0e\x3f\xdf\xfd\x8e\xe7\xbd\xac\x46\xce\x78\x6f\x21\x90\x38\x1b\x9c\x3e\x84\xb1\x9a\xf6\x76\x80\x72\x46\x52\xe9\x7b\x45\x53\x29\xe9\x7b\x36\x15\xd1\xee\xb3\x5a\x66\xc3\x17\x59\xda\xc8\x86\xd7\x97\x11\x55\x1d\xb5\x8b\x20\xf3\x42\x5d\x1c\xd1\xa8\x66\xdd\x63\x78\x4a\x1b\xa7\x4f\x0c\x97\x89\x52\x31\xfc\x3e\x6f\x88\x2a\x99\x0f\x9f\x97\x62\x87\xc7\x1b\x62\xe5\xf3\x6f\x8f\xe3\x14\xc2\x7f\xf6\x9b\x9e\xf5\x12\x9e\x4d\xdb\x23\xf9\x14\x7e\x06\x00\x65\x7e\xe7\x1d\xfe\x00\x00\x00")
+
+func dbMigrations0060_add_group_rollout_orderSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0060_add_group_rollout_orderSql,
+		"db/migrations/0060_add_group_rollout_order.sql",
+	)
+}
+
+func dbMigrations0060_add_group_rollout_orderSql() (*asset, error) {
+	bytes, err := dbMigrations0060_add_group_rollout_orderSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0060_add_group_rollout_order.sql", size: 254, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xee, 0xe9, 0x8f, 0xcb, 0x94, 0xfd, 0x67, 0x81, 0xa, 0xd4, 0x6c, 0x92, 0xc0, 0x93, 0x90, 0xe, 0x21, 0x60, 0xd1, 0xc4, 0xc6, 0x30, 0xb8, 0xe4, 0x37, 0xc6, 0xd5, 0xc9, 0x30, 0x66, 0xcc, 0xc2}}
+	return a, nil
+}
+
+var _dbMigrations0061_add_channel_package_history_detailsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x91\xb1\x6e\x32\x31\x10\x84\x6b\xfc\x14\x53\xde\xe9\x07\x8a\x5f\xa2\x3a\xa5\xcb\x2b\xa4\x46\x8b\x77\xe1\xac\x18\xaf\xb5\xde\x03\xf1\xf6\x51\x4e\x11\x49\x71\x44\xa1\x1d\xcf\xf8\x1b\x7b\x36\x1b\xfc\x3b\xa7\x93\x91\x0b\xde\x6a\x08\x94\x5d\x0c\x4e\x87\x2c\x88\x23\x95\x22\x79\x5f\x29\xbe\xd3\x49\xf6\x63\x6a\xae\x76\x03\x31\x23\x6a\x9e\xce\x05\xd5\xe4\x92\x74\x6a\x77\x4f\x62\x4c\x53\x62\x98\x1c\xc5\xa4\x44\x69\xf8\x3a\x42\x97\xb8\x87\x16\xb0\x64\x71\x41\x13\x47\x99\x72\x1e\x9e\x65\x52\x74\x35\x5c\xc8\xe2\x48\xd6\xfd\xdf\xed\xfa\x21\x84\xa9\x32\xf9\xe3\xf8\x38\xe3\x96\xda\xbe\xa0\x6e\x17\xf4\x70\x34\x3d\xa3\x0b\xab\x26\x59\xa2\x23\xf1\x1a\x99\x4e\xdd\xb7\xa3\x87\x5e\xc4\xd0\x55\x32\x4f\x9e\xb4\xe0\x70\xbb\xf3\x13\x43\x8d\xc5\x66\xcd\x84\x5c\x78\xef\x6d\x8d\xcf\x18\xb5\xa5\x6f\x0b\xab\x99\xf8\xe0\x01\xa1\x47\x0d\xd7\x51\x4c\x50\xb7\x73\xeb\x71\x9b\x78\x08\xe1\xe7\x7e\xaf\x7a\x2d\x7f\x5b\x90\x4d\xeb\x2f\x13\x0e\x4f\x5f\x42\xd1\xd5\x86\xf0\x31\x00\x25\x51\x2f\x99\x4e\x02\x00\x00")
+
+func dbMigrations0061_add_channel_package_history_detailsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0061_add_channel_package_history_detailsSql,
+		"db/migrations/0061_add_channel_package_history_details.sql",
+	)
+}
+
+func dbMigrations0061_add_channel_package_history_detailsSql() (*asset, error) {
+	bytes, err := dbMigrations0061_add_channel_package_history_detailsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0061_add_channel_package_history_details.sql", size: 590, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf1, 0x7f, 0xf9, 0x8, 0xbf, 0x9a, 0x36, 0x2c, 0xfc, 0x4d, 0xae, 0x59, 0x6b, 0x7a, 0xc3, 0xc3, 0xc6, 0x57, 0x4e, 0x2b, 0x21, 0x12, 0x7a, 0x51, 0x5d, 0x8f, 0x92, 0x2e, 0x2d, 0xa0, 0x6c, 0x77}}
+	return a, nil
+}
+
+var _dbMigrations0062_keep_audit_entries_of_purged_appsSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xdc\x53\x31\x6e\xc3\x30\x0c\xdc\xf5\x0a\x8e\x09\x8a\xf8\x03\x46\xb7\x7e\xa1\xb3\xc1\x4a\x74\x4a\x54\xa6\x04\x89\x6e\xea\xdf\x17\xb2\x5d\x41\x09\x1a\x20\x5d\xbb\xd1\xd2\xf9\x78\xbc\xa3\x4e\x27\x78\x9a\xf8\x9c\x50\x09\x5e\xa3\x31\xe8\x95\x12\x28\xbe\x79\x02\xb4\xca\x9f\xac\x0b\xa0\x73\x60\x83\x9f\x27\x01\x25\x9c\x06\x76\x30\xcf\xec\x20\xd1\x48\x89\xc4\x52\x5e\xcf\xe1\xc0\xee\x08\x41\xc0\x91\x27\x25\xb0\x98\x2d\x3a\xea\x8d\x99\xa3\x43\x6d\x09\x21\x93\x56\xae\x67\xc0\x18\xbb\xfd\xcb\x8c\x29\x4c\xe5\xc0\xb3\x45\xe5\x20\xa5\x36\x97\x77\x4a\x54\xaa\x6e\xc3\x77\x0d\xa0\xc8\x41\x71\x80\x1d\x89\xb2\x2e\x83\x2e\x91\x80\x33\x48\x50\x90\xd9\xfb\xde\x18\x9b\xa8\x08\x60\x71\xf4\x55\x14\x56\x25\x87\xbd\xed\xb1\xbf\x37\xfb\x6a\xc8\x3e\xfd\x4d\x57\x97\x42\x6c\xba\xfc\xfa\xff\x8a\xb1\x41\xb2\x26\x64\xd1\x7a\x31\x5c\x73\x0d\xe3\x07\x2d\x77\x28\x36\xfb\x1f\x62\x80\x31\x24\xe2\xb3\x40\xa9\x0f\xd7\x80\x63\x9b\x57\x73\x75\x1b\x5b\xa6\x6a\x5b\xbb\x1d\x2f\xe1\x22\xc6\xec\x98\x2d\xa4\x1f\x81\x35\x9d\xa6\xdb\x1a\xc0\x7f\xb2\xa5\x6e\xf3\x9f\xd7\x64\x35\xf4\xa1\x2d\x69\x9f\x58\x6f\xbe\x07\x00\xb7\xca\x2a\x0b\x9c\x03\x00\x00")
+
+func dbMigrations0062_keep_audit_entries_of_purged_appsSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0062_keep_audit_entries_of_purged_appsSql,
+		"db/migrations/0062_keep_audit_entries_of_purged_apps.sql",
+	)
+}
+
+func dbMigrations0062_keep_audit_entries_of_purged_appsSql() (*asset, error) {
+	bytes, err := dbMigrations0062_keep_audit_entries_of_purged_appsSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0062_keep_audit_entries_of_purged_apps.sql", size: 924, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x29, 0x3c, 0xb8, 0x69, 0xe7, 0x39, 0x8e, 0x88, 0x7b, 0xe6, 0x9c, 0x7a, 0x2e, 0x2a, 0xe7, 0x21, 0x39, 0x2f, 0x57, 0xb6, 0xd4, 0x42, 0x87, 0x34, 0x4f, 0x4e, 0xad, 0x91, 0x63, 0x0, 0xb1, 0x67}}
+	return a, nil
+}
+
+var _dbMigrations0063_allow_channel_package_history_without_packageSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\xce\x31\xae\xc2\x40\x0c\x84\xe1\xde\xa7\x98\xfe\x29\x27\x48\xfb\xae\x40\x1d\x99\xc4\x24\x2b\x1c\x7b\xe5\xf5\x2a\xe2\xf6\x14\x80\x94\x86\x8a\x7a\x46\x9f\xfe\x61\xc0\xdf\x5e\xd6\xe0\x14\x5c\x2a\x11\x6b\x4a\x20\xf9\xaa\x82\x79\x63\x33\xd1\xa9\xf2\x7c\xe7\x55\xa6\xad\xb4\xf4\x78\xe0\xf5\x99\x5d\xfb\x6e\xf8\x8c\x65\xc1\x12\x5e\x61\x9e\xb0\xae\x3a\x12\x9d\xed\x7f\x3f\x8c\x68\x11\x95\x14\xdc\xc2\xf7\xaf\xfa\xb1\x49\xc8\x99\x2d\xed\x0d\xfe\xd2\xd6\x24\x61\x9e\xb0\xae\x3a\xd2\x73\x00\x07\xc2\x58\x2c\xf6\x00\x00\x00")
+
+func dbMigrations0063_allow_channel_package_history_without_packageSqlBytes() ([]byte, error) {
+	return bindataRead(
+		_dbMigrations0063_allow_channel_package_history_without_packageSql,
+		"db/migrations/0063_allow_channel_package_history_without_package.sql",
+	)
+}
+
+func dbMigrations0063_allow_channel_package_history_without_packageSql() (*asset, error) {
+	bytes, err := dbMigrations0063_allow_channel_package_history_without_packageSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "db/migrations/0063_allow_channel_package_history_without_package.sql", size: 246, mode: os.FileMode(0644), modTime: time.Unix(1, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x11, 0x29, 0x76, 0xc2, 0x1d, 0x48, 0xcc, 0xab, 0x8d, 0xd3, 0x9e, 0x8e, 0x45, 0xe2, 0xd, 0x5e, 0x9c, 0x19, 0x11, 0xd5, 0x6f, 0x95, 0x2f, 0xad, 0xec, 0xd6, 0x20, 0xe1, 0x20, 0x70, 0xc9, 0xcc}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -474,21 +1524,71 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"db/drop_all_tables.sql":                       dbDrop_all_tablesSql,
-	"db/sample_data.sql":                           dbSample_dataSql,
-	"db/migrations/0001_initial.sql":               dbMigrations0001_initialSql,
-	"db/migrations/0002_event_data.sql":            dbMigrations0002_event_dataSql,
-	"db/migrations/0003_longer_team_names.sql":     dbMigrations0003_longer_team_namesSql,
-	"db/migrations/0004_rename_coreos_action.sql":  dbMigrations0004_rename_coreos_actionSql,
-	"db/migrations/0005_default_team_id.sql":       dbMigrations0005_default_team_idSql,
-	"db/migrations/0006_initial_application.sql":   dbMigrations0006_initial_applicationSql,
-	"db/migrations/0007_add_package_arch.sql":      dbMigrations0007_add_package_archSql,
-	"db/migrations/0008-arm-channels-groups.sql":   dbMigrations0008ArmChannelsGroupsSql,
-	"db/migrations/0009_group_track_names.sql":     dbMigrations0009_group_track_namesSql,
-	"db/migrations/0010_add_instance_alias.sql":    dbMigrations0010_add_instance_aliasSql,
-	"db/migrations/0011_add_composite_indexes.sql": dbMigrations0011_add_composite_indexesSql,
-	"db/migrations/0012_drop_unused_indexes.sql":   dbMigrations0012_drop_unused_indexesSql,
-	"db/migrations/0013_add_stats_indexes.sql":     dbMigrations0013_add_stats_indexesSql,
+	"db/drop_all_tables.sql":                                                dbDrop_all_tablesSql,
+	"db/sample_data.sql":                                                    dbSample_dataSql,
+	"db/migrations/0001_initial.sql":                                        dbMigrations0001_initialSql,
+	"db/migrations/0002_event_data.sql":                                     dbMigrations0002_event_dataSql,
+	"db/migrations/0003_longer_team_names.sql":                              dbMigrations0003_longer_team_namesSql,
+	"db/migrations/0004_rename_coreos_action.sql":                           dbMigrations0004_rename_coreos_actionSql,
+	"db/migrations/0005_default_team_id.sql":                                dbMigrations0005_default_team_idSql,
+	"db/migrations/0006_initial_application.sql":                            dbMigrations0006_initial_applicationSql,
+	"db/migrations/0007_add_package_arch.sql":                               dbMigrations0007_add_package_archSql,
+	"db/migrations/0008-arm-channels-groups.sql":                            dbMigrations0008ArmChannelsGroupsSql,
+	"db/migrations/0009_group_track_names.sql":                              dbMigrations0009_group_track_namesSql,
+	"db/migrations/0010_add_instance_alias.sql":                             dbMigrations0010_add_instance_aliasSql,
+	"db/migrations/0011_add_composite_indexes.sql":                          dbMigrations0011_add_composite_indexesSql,
+	"db/migrations/0012_drop_unused_indexes.sql":                            dbMigrations0012_drop_unused_indexesSql,
+	"db/migrations/0013_add_stats_indexes.sql":                              dbMigrations0013_add_stats_indexesSql,
+	"db/migrations/0014_add_group_min_healthy_instances.sql":                dbMigrations0014_add_group_min_healthy_instancesSql,
+	"db/migrations/0015_add_application_allowed_event_types.sql":            dbMigrations0015_add_application_allowed_event_typesSql,
+	"db/migrations/0016_add_group_max_version_spread.sql":                   dbMigrations0016_add_group_max_version_spreadSql,
+	"db/migrations/0017_add_group_rollout_percentage.sql":                   dbMigrations0017_add_group_rollout_percentageSql,
+	"db/migrations/0018_add_group_max_concurrent_downloads.sql":             dbMigrations0018_add_group_max_concurrent_downloadsSql,
+	"db/migrations/0019_add_group_policy_paused.sql":                        dbMigrations0019_add_group_policy_pausedSql,
+	"db/migrations/0020_add_group_auto_rollback.sql":                        dbMigrations0020_add_group_auto_rollbackSql,
+	"db/migrations/0021_add_package_mirrors.sql":                            dbMigrations0021_add_package_mirrorsSql,
+	"db/migrations/0022_add_instance_ip_index.sql":                          dbMigrations0022_add_instance_ip_indexSql,
+	"db/migrations/0023_add_webhooks.sql":                                   dbMigrations0023_add_webhooksSql,
+	"db/migrations/0024_add_package_min_previous_version.sql":               dbMigrations0024_add_package_min_previous_versionSql,
+	"db/migrations/0025_add_application_deleted_at.sql":                     dbMigrations0025_add_application_deleted_atSql,
+	"db/migrations/0026_add_group_pinned_version.sql":                       dbMigrations0026_add_group_pinned_versionSql,
+	"db/migrations/0027_add_instance_system_info.sql":                       dbMigrations0027_add_instance_system_infoSql,
+	"db/migrations/0028_add_group_update_windows.sql":                       dbMigrations0028_add_group_update_windowsSql,
+	"db/migrations/0029_application_instance_retention.sql":                 dbMigrations0029_application_instance_retentionSql,
+	"db/migrations/0030_flatcar_action_payload_signature.sql":               dbMigrations0030_flatcar_action_payload_signatureSql,
+	"db/migrations/0031_group_update_timeout_action.sql":                    dbMigrations0031_group_update_timeout_actionSql,
+	"db/migrations/0032_channel_package_revision.sql":                       dbMigrations0032_channel_package_revisionSql,
+	"db/migrations/0033_add_group_channel_weights.sql":                      dbMigrations0033_add_group_channel_weightsSql,
+	"db/migrations/0034_add_instance_registration_rules.sql":                dbMigrations0034_add_instance_registration_rulesSql,
+	"db/migrations/0035_add_package_deltas.sql":                             dbMigrations0035_add_package_deltasSql,
+	"db/migrations/0036_add_instance_labels.sql":                            dbMigrations0036_add_instance_labelsSql,
+	"db/migrations/0037_add_activity_audit.sql":                             dbMigrations0037_add_activity_auditSql,
+	"db/migrations/0038_add_package_oci_image.sql":                          dbMigrations0038_add_package_oci_imageSql,
+	"db/migrations/0039_add_group_force_update_after.sql":                   dbMigrations0039_add_group_force_update_afterSql,
+	"db/migrations/0040_add_instances_sort_indexes.sql":                     dbMigrations0040_add_instances_sort_indexesSql,
+	"db/migrations/0041_add_channel_version_constraint.sql":                 dbMigrations0041_add_channel_version_constraintSql,
+	"db/migrations/0042_add_instance_update_duration.sql":                   dbMigrations0042_add_instance_update_durationSql,
+	"db/migrations/0043_add_instance_event_fingerprint.sql":                 dbMigrations0043_add_instance_event_fingerprintSql,
+	"db/migrations/0044_add_group_max_concurrent_updates.sql":               dbMigrations0044_add_group_max_concurrent_updatesSql,
+	"db/migrations/0045_add_instance_application_quarantined.sql":           dbMigrations0045_add_instance_application_quarantinedSql,
+	"db/migrations/0046_add_package_severity.sql":                           dbMigrations0046_add_package_severitySql,
+	"db/migrations/0047_add_instance_region.sql":                            dbMigrations0047_add_instance_regionSql,
+	"db/migrations/0048_add_event_daily_aggregate.sql":                      dbMigrations0048_add_event_daily_aggregateSql,
+	"db/migrations/0049_add_instance_package_override.sql":                  dbMigrations0049_add_instance_package_overrideSql,
+	"db/migrations/0050_add_group_rollout_cohorts.sql":                      dbMigrations0050_add_group_rollout_cohortsSql,
+	"db/migrations/0051_add_group_min_bake_time.sql":                        dbMigrations0051_add_group_min_bake_timeSql,
+	"db/migrations/0052_add_application_disable_ping_persistence.sql":       dbMigrations0052_add_application_disable_ping_persistenceSql,
+	"db/migrations/0053_add_instance_first_seen_and_version_changed_at.sql": dbMigrations0053_add_instance_first_seen_and_version_changed_atSql,
+	"db/migrations/0054_add_group_rollout_schedule.sql":                     dbMigrations0054_add_group_rollout_scheduleSql,
+	"db/migrations/0055_add_instance_note.sql":                              dbMigrations0055_add_instance_noteSql,
+	"db/migrations/0056_add_track_aliases.sql":                              dbMigrations0056_add_track_aliasesSql,
+	"db/migrations/0057_add_group_policy_observe_only.sql":                  dbMigrations0057_add_group_policy_observe_onlySql,
+	"db/migrations/0058_add_package_artifacts.sql":                          dbMigrations0058_add_package_artifactsSql,
+	"db/migrations/0059_add_group_safe_mode_failure_threshold.sql":          dbMigrations0059_add_group_safe_mode_failure_thresholdSql,
+	"db/migrations/0060_add_group_rollout_order.sql":                        dbMigrations0060_add_group_rollout_orderSql,
+	"db/migrations/0061_add_channel_package_history_details.sql":            dbMigrations0061_add_channel_package_history_detailsSql,
+	"db/migrations/0062_keep_audit_entries_of_purged_apps.sql":              dbMigrations0062_keep_audit_entries_of_purged_appsSql,
+	"db/migrations/0063_allow_channel_package_history_without_package.sql":  dbMigrations0063_allow_channel_package_history_without_packageSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -535,19 +1635,69 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"db": &bintree{nil, map[string]*bintree{
 		"drop_all_tables.sql": &bintree{dbDrop_all_tablesSql, map[string]*bintree{}},
 		"migrations": &bintree{nil, map[string]*bintree{
-			"0001_initial.sql":               &bintree{dbMigrations0001_initialSql, map[string]*bintree{}},
-			"0002_event_data.sql":            &bintree{dbMigrations0002_event_dataSql, map[string]*bintree{}},
-			"0003_longer_team_names.sql":     &bintree{dbMigrations0003_longer_team_namesSql, map[string]*bintree{}},
-			"0004_rename_coreos_action.sql":  &bintree{dbMigrations0004_rename_coreos_actionSql, map[string]*bintree{}},
-			"0005_default_team_id.sql":       &bintree{dbMigrations0005_default_team_idSql, map[string]*bintree{}},
-			"0006_initial_application.sql":   &bintree{dbMigrations0006_initial_applicationSql, map[string]*bintree{}},
-			"0007_add_package_arch.sql":      &bintree{dbMigrations0007_add_package_archSql, map[string]*bintree{}},
-			"0008-arm-channels-groups.sql":   &bintree{dbMigrations0008ArmChannelsGroupsSql, map[string]*bintree{}},
-			"0009_group_track_names.sql":     &bintree{dbMigrations0009_group_track_namesSql, map[string]*bintree{}},
-			"0010_add_instance_alias.sql":    &bintree{dbMigrations0010_add_instance_aliasSql, map[string]*bintree{}},
-			"0011_add_composite_indexes.sql": &bintree{dbMigrations0011_add_composite_indexesSql, map[string]*bintree{}},
-			"0012_drop_unused_indexes.sql":   &bintree{dbMigrations0012_drop_unused_indexesSql, map[string]*bintree{}},
-			"0013_add_stats_indexes.sql":     &bintree{dbMigrations0013_add_stats_indexesSql, map[string]*bintree{}},
+			"0001_initial.sql":                                        &bintree{dbMigrations0001_initialSql, map[string]*bintree{}},
+			"0002_event_data.sql":                                     &bintree{dbMigrations0002_event_dataSql, map[string]*bintree{}},
+			"0003_longer_team_names.sql":                              &bintree{dbMigrations0003_longer_team_namesSql, map[string]*bintree{}},
+			"0004_rename_coreos_action.sql":                           &bintree{dbMigrations0004_rename_coreos_actionSql, map[string]*bintree{}},
+			"0005_default_team_id.sql":                                &bintree{dbMigrations0005_default_team_idSql, map[string]*bintree{}},
+			"0006_initial_application.sql":                            &bintree{dbMigrations0006_initial_applicationSql, map[string]*bintree{}},
+			"0007_add_package_arch.sql":                               &bintree{dbMigrations0007_add_package_archSql, map[string]*bintree{}},
+			"0008-arm-channels-groups.sql":                            &bintree{dbMigrations0008ArmChannelsGroupsSql, map[string]*bintree{}},
+			"0009_group_track_names.sql":                              &bintree{dbMigrations0009_group_track_namesSql, map[string]*bintree{}},
+			"0010_add_instance_alias.sql":                             &bintree{dbMigrations0010_add_instance_aliasSql, map[string]*bintree{}},
+			"0011_add_composite_indexes.sql":                          &bintree{dbMigrations0011_add_composite_indexesSql, map[string]*bintree{}},
+			"0012_drop_unused_indexes.sql":                            &bintree{dbMigrations0012_drop_unused_indexesSql, map[string]*bintree{}},
+			"0013_add_stats_indexes.sql":                              &bintree{dbMigrations0013_add_stats_indexesSql, map[string]*bintree{}},
+			"0014_add_group_min_healthy_instances.sql":                &bintree{dbMigrations0014_add_group_min_healthy_instancesSql, map[string]*bintree{}},
+			"0015_add_application_allowed_event_types.sql":            &bintree{dbMigrations0015_add_application_allowed_event_typesSql, map[string]*bintree{}},
+			"0016_add_group_max_version_spread.sql":                   &bintree{dbMigrations0016_add_group_max_version_spreadSql, map[string]*bintree{}},
+			"0017_add_group_rollout_percentage.sql":                   &bintree{dbMigrations0017_add_group_rollout_percentageSql, map[string]*bintree{}},
+			"0018_add_group_max_concurrent_downloads.sql":             &bintree{dbMigrations0018_add_group_max_concurrent_downloadsSql, map[string]*bintree{}},
+			"0019_add_group_policy_paused.sql":                        &bintree{dbMigrations0019_add_group_policy_pausedSql, map[string]*bintree{}},
+			"0020_add_group_auto_rollback.sql":                        &bintree{dbMigrations0020_add_group_auto_rollbackSql, map[string]*bintree{}},
+			"0021_add_package_mirrors.sql":                            &bintree{dbMigrations0021_add_package_mirrorsSql, map[string]*bintree{}},
+			"0022_add_instance_ip_index.sql":                          &bintree{dbMigrations0022_add_instance_ip_indexSql, map[string]*bintree{}},
+			"0023_add_webhooks.sql":                                   &bintree{dbMigrations0023_add_webhooksSql, map[string]*bintree{}},
+			"0024_add_package_min_previous_version.sql":               &bintree{dbMigrations0024_add_package_min_previous_versionSql, map[string]*bintree{}},
+			"0025_add_application_deleted_at.sql":                     &bintree{dbMigrations0025_add_application_deleted_atSql, map[string]*bintree{}},
+			"0026_add_group_pinned_version.sql":                       &bintree{dbMigrations0026_add_group_pinned_versionSql, map[string]*bintree{}},
+			"0027_add_instance_system_info.sql":                       &bintree{dbMigrations0027_add_instance_system_infoSql, map[string]*bintree{}},
+			"0028_add_group_update_windows.sql":                       &bintree{dbMigrations0028_add_group_update_windowsSql, map[string]*bintree{}},
+			"0029_application_instance_retention.sql":                 &bintree{dbMigrations0029_application_instance_retentionSql, map[string]*bintree{}},
+			"0030_flatcar_action_payload_signature.sql":               &bintree{dbMigrations0030_flatcar_action_payload_signatureSql, map[string]*bintree{}},
+			"0031_group_update_timeout_action.sql":                    &bintree{dbMigrations0031_group_update_timeout_actionSql, map[string]*bintree{}},
+			"0032_channel_package_revision.sql":                       &bintree{dbMigrations0032_channel_package_revisionSql, map[string]*bintree{}},
+			"0033_add_group_channel_weights.sql":                      &bintree{dbMigrations0033_add_group_channel_weightsSql, map[string]*bintree{}},
+			"0034_add_instance_registration_rules.sql":                &bintree{dbMigrations0034_add_instance_registration_rulesSql, map[string]*bintree{}},
+			"0035_add_package_deltas.sql":                             &bintree{dbMigrations0035_add_package_deltasSql, map[string]*bintree{}},
+			"0036_add_instance_labels.sql":                            &bintree{dbMigrations0036_add_instance_labelsSql, map[string]*bintree{}},
+			"0037_add_activity_audit.sql":                             &bintree{dbMigrations0037_add_activity_auditSql, map[string]*bintree{}},
+			"0038_add_package_oci_image.sql":                          &bintree{dbMigrations0038_add_package_oci_imageSql, map[string]*bintree{}},
+			"0039_add_group_force_update_after.sql":                   &bintree{dbMigrations0039_add_group_force_update_afterSql, map[string]*bintree{}},
+			"0040_add_instances_sort_indexes.sql":                     &bintree{dbMigrations0040_add_instances_sort_indexesSql, map[string]*bintree{}},
+			"0041_add_channel_version_constraint.sql":                 &bintree{dbMigrations0041_add_channel_version_constraintSql, map[string]*bintree{}},
+			"0042_add_instance_update_duration.sql":                   &bintree{dbMigrations0042_add_instance_update_durationSql, map[string]*bintree{}},
+			"0043_add_instance_event_fingerprint.sql":                 &bintree{dbMigrations0043_add_instance_event_fingerprintSql, map[string]*bintree{}},
+			"0044_add_group_max_concurrent_updates.sql":               &bintree{dbMigrations0044_add_group_max_concurrent_updatesSql, map[string]*bintree{}},
+			"0045_add_instance_application_quarantined.sql":           &bintree{dbMigrations0045_add_instance_application_quarantinedSql, map[string]*bintree{}},
+			"0046_add_package_severity.sql":                           &bintree{dbMigrations0046_add_package_severitySql, map[string]*bintree{}},
+			"0047_add_instance_region.sql":                            &bintree{dbMigrations0047_add_instance_regionSql, map[string]*bintree{}},
+			"0048_add_event_daily_aggregate.sql":                      &bintree{dbMigrations0048_add_event_daily_aggregateSql, map[string]*bintree{}},
+			"0049_add_instance_package_override.sql":                  &bintree{dbMigrations0049_add_instance_package_overrideSql, map[string]*bintree{}},
+			"0050_add_group_rollout_cohorts.sql":                      &bintree{dbMigrations0050_add_group_rollout_cohortsSql, map[string]*bintree{}},
+			"0051_add_group_min_bake_time.sql":                        &bintree{dbMigrations0051_add_group_min_bake_timeSql, map[string]*bintree{}},
+			"0052_add_application_disable_ping_persistence.sql":       &bintree{dbMigrations0052_add_application_disable_ping_persistenceSql, map[string]*bintree{}},
+			"0053_add_instance_first_seen_and_version_changed_at.sql": &bintree{dbMigrations0053_add_instance_first_seen_and_version_changed_atSql, map[string]*bintree{}},
+			"0054_add_group_rollout_schedule.sql":                     &bintree{dbMigrations0054_add_group_rollout_scheduleSql, map[string]*bintree{}},
+			"0055_add_instance_note.sql":                              &bintree{dbMigrations0055_add_instance_noteSql, map[string]*bintree{}},
+			"0056_add_track_aliases.sql":                              &bintree{dbMigrations0056_add_track_aliasesSql, map[string]*bintree{}},
+			"0057_add_group_policy_observe_only.sql":                  &bintree{dbMigrations0057_add_group_policy_observe_onlySql, map[string]*bintree{}},
+			"0058_add_package_artifacts.sql":                          &bintree{dbMigrations0058_add_package_artifactsSql, map[string]*bintree{}},
+			"0059_add_group_safe_mode_failure_threshold.sql":          &bintree{dbMigrations0059_add_group_safe_mode_failure_thresholdSql, map[string]*bintree{}},
+			"0060_add_group_rollout_order.sql":                        &bintree{dbMigrations0060_add_group_rollout_orderSql, map[string]*bintree{}},
+			"0061_add_channel_package_history_details.sql":            &bintree{dbMigrations0061_add_channel_package_history_detailsSql, map[string]*bintree{}},
+			"0062_keep_audit_entries_of_purged_apps.sql":              &bintree{dbMigrations0062_keep_audit_entries_of_purged_appsSql, map[string]*bintree{}},
+			"0063_allow_channel_package_history_without_package.sql":  &bintree{dbMigrations0063_allow_channel_package_history_without_packageSql, map[string]*bintree{}},
 		}},
 		"sample_data.sql": &bintree{dbSample_dataSql, map[string]*bintree{}},
 	}},
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+var (
+	// ErrDuplicateChannelColor error indicates an attempt of creating or
+	// updating a channel using a color already used by another channel of the
+	// same application.
+	ErrDuplicateChannelColor = newConflictError("nebraska: channel color already used in the application")
+
+	// ErrNoChannelColorAvailable error indicates that all the colors of the
+	// palette are already used by the channels of an application.
+	ErrNoChannelColorAvailable = errors.New("nebraska: no channel color available")
+)
+
+// channelColorPalette is the list of colors suggested for new channels, in
+// order of preference. They match the ones offered by the dashboard's color
+// picker.
+var channelColorPalette = []string{
+	"#0693E3",
+	"#00D084",
+	"#FF6900",
+	"#9900EF",
+	"#EB144C",
+	"#FCB900",
+	"#8ED1FC",
+	"#7BDCB5",
+	"#F78DA7",
+	"#ABB8C3",
+}
+
+// validateChannelColor checks that no channel of the application provided,
+// other than the one identified by channelID, is using the color provided.
+// Colors are compared case insensitively, and channels without color are
+// not checked.
+func (api *API) validateChannelColor(channelID, appID, color string) error {
+	if color == "" {
+		return nil
+	}
+	conditions := []goqu.Expression{
+		goqu.C("application_id").Eq(appID),
+		goqu.L("lower(color)").Eq(strings.ToLower(color)),
+	}
+	if channelID != "" {
+		conditions = append(conditions, goqu.C("id").Neq(channelID))
+	}
+	query, _, err := goqu.From("channel").
+		Select(goqu.COUNT("*")).
+		Where(conditions...).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	var count int
+	if err := api.db.QueryRow(query).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateChannelColor
+	}
+	return nil
+}
+
+// SuggestChannelColor returns the first color of the palette not used yet by
+// any channel of the application provided.
+func (api *API) SuggestChannelColor(appID string) (string, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return "", err
+	}
+	query, _, err := goqu.From("channel").
+		Select(goqu.L("lower(color)")).
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return "", err
+	}
+	var usedColors []string
+	if err := api.readDB().Select(&usedColors, query); err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(usedColors))
+	for _, color := range usedColors {
+		used[color] = true
+	}
+	for _, color := range channelColorPalette {
+		if !used[strings.ToLower(color)] {
+			return color, nil
+		}
+	}
+	return "", ErrNoChannelColorAvailable
+}
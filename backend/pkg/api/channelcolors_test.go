@@ -0,0 +1,76 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddChannel_DuplicateColor(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+
+	_, err := a.AddChannel(&Channel{Name: "channel1", Color: "#0693E3", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+
+	_, err = a.AddChannel(&Channel{Name: "channel2", Color: "#0693e3", ApplicationID: tApp.ID})
+	assert.Equal(t, ErrDuplicateChannelColor, err, "Colors must be unique within an application.")
+
+	_, err = a.AddChannel(&Channel{Name: "channel2", Color: "#0693E3", ApplicationID: tApp2.ID})
+	assert.NoError(t, err, "Colors may be reused across applications.")
+
+	_, err = a.AddChannel(&Channel{Name: "channel3", ApplicationID: tApp.ID})
+	assert.NoError(t, err)
+	_, err = a.AddChannel(&Channel{Name: "channel4", ApplicationID: tApp.ID})
+	assert.NoError(t, err, "Channels without color aren't checked.")
+}
+
+func TestUpdateChannel_DuplicateColor(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tChannel1, _ := a.AddChannel(&Channel{Name: "channel1", Color: "blue", ApplicationID: tApp.ID})
+	tChannel2, _ := a.AddChannel(&Channel{Name: "channel2", Color: "red", ApplicationID: tApp.ID})
+
+	err := a.UpdateChannel(&Channel{ID: tChannel2.ID, Name: "channel2", Color: "Blue"})
+	assert.Equal(t, ErrDuplicateChannelColor, err)
+
+	err = a.UpdateChannel(&Channel{ID: tChannel1.ID, Name: "channel1_updated", Color: "blue"})
+	assert.NoError(t, err, "A channel keeping its color isn't a duplicate of itself.")
+
+	err = a.UpdateChannel(&Channel{ID: tChannel2.ID, Name: "channel2", Color: "green"})
+	assert.NoError(t, err)
+}
+
+func TestSuggestChannelColor(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	color, err := a.SuggestChannelColor(tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, channelColorPalette[0], color)
+
+	_, _ = a.AddChannel(&Channel{Name: "channel1", Color: channelColorPalette[0], ApplicationID: tApp.ID})
+	_, _ = a.AddChannel(&Channel{Name: "channel2", Color: "#00d084", ApplicationID: tApp.ID})
+
+	color, err = a.SuggestChannelColor(tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, channelColorPalette[2], color, "The suggestion must skip the colors taken, whatever their case.")
+
+	for i, c := range channelColorPalette[2:] {
+		_, err := a.AddChannel(&Channel{Name: "channel_" + string(rune('a'+i)), Color: c, ApplicationID: tApp.ID})
+		require.NoError(t, err)
+	}
+	_, err = a.SuggestChannelColor(tApp.ID)
+	assert.Equal(t, ErrNoChannelColorAvailable, err)
+}
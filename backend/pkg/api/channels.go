@@ -3,20 +3,31 @@ package api
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"gopkg.in/guregu/null.v4"
 )
 
 var (
 	// ErrInvalidPackage error indicates that a package doesn't belong to the
 	// application it was supposed to belong to.
-	ErrInvalidPackage = errors.New("nebraska: invalid package")
+	ErrInvalidPackage = newValidationError("nebraska: invalid package")
 
 	// ErrBlacklistedChannel error indicates an attempt of creating/updating a
 	// channel using a package that has blacklisted the channel.
-	ErrBlacklistedChannel = errors.New("nebraska: blacklisted channel")
+	ErrBlacklistedChannel = newValidationError("nebraska: blacklisted channel")
+
+	// ErrChannelNoPackage error indicates an attempt of promoting the
+	// package of a channel that doesn't point to any package.
+	ErrChannelNoPackage = errors.New("nebraska: channel has no package")
+
+	// ErrPromotionNotNewer error indicates an attempt of promoting a package
+	// to a channel already pointing to the same or a newer version.
+	ErrPromotionNotNewer = newValidationError("nebraska: promoted package is not newer than the channel's package")
 )
 
 // Channel represents a Nebraska application's channel.
@@ -29,45 +40,124 @@ type Channel struct {
 	PackageID     null.String `db:"package_id" json:"package_id"`
 	Package       *Package    `db:"package" json:"package"`
 	Arch          Arch        `db:"arch" json:"arch"`
+	Revision      int         `db:"revision" json:"revision"`
+	// VersionConstraint, when set, makes instances be offered the newest
+	// package matching it, like ^3.0.0, instead of the channel's package.
+	VersionConstraint null.String `db:"version_constraint" json:"version_constraint"`
+}
+
+// ChannelGap represents the distance between the packages two channels of the
+// same application and architecture are pointing to.
+type ChannelGap struct {
+	BehindChannelID   string `json:"behind_channel_id"`
+	BehindChannelName string `json:"behind_channel_name"`
+	BehindVersion     string `json:"behind_version"`
+	AheadChannelID    string `json:"ahead_channel_id"`
+	AheadChannelName  string `json:"ahead_channel_name"`
+	AheadVersion      string `json:"ahead_version"`
+	Arch              Arch   `json:"arch"`
+	// Distance is the number of package versions of the application newer
+	// than the version of the channel behind, up to the one of the channel
+	// ahead.
+	Distance int `json:"distance"`
+}
+
+// ChannelHistoryEntry represents a change of the package a channel points
+// to. The previous package is not set for the first package of the channel,
+// nor when it was deleted, the package is not set when the channel was left
+// without package, and the actor is not set when the change wasn't made on
+// behalf of a user, like in automatic rollbacks.
+type ChannelHistoryEntry struct {
+	ID                int         `db:"id" json:"id"`
+	ChannelID         string      `db:"channel_id" json:"channel_id"`
+	PreviousPackageID null.String `db:"previous_package_id" json:"previous_package_id"`
+	PreviousVersion   null.String `db:"previous_version" json:"previous_version"`
+	PackageID         null.String `db:"package_id" json:"package_id"`
+	Version           null.String `db:"version" json:"version"`
+	Actor             null.String `db:"actor" json:"actor"`
+	CreatedTs         time.Time   `db:"created_ts" json:"created_ts"`
 }
 
 // AddChannel registers the provided channel.
 func (api *API) AddChannel(channel *Channel) (*Channel, error) {
+	if err := api.checkAppTeam(channel.ApplicationID); err != nil {
+		return nil, err
+	}
 	if !channel.Arch.IsValid() {
 		return nil, ErrInvalidArch
 	}
+	if err := validateVersionConstraint(channel); err != nil {
+		return nil, err
+	}
+	if err := api.validateChannelColor("", channel.ApplicationID, channel.Color); err != nil {
+		return nil, err
+	}
 	if channel.PackageID.String != "" {
 		if _, err := api.validatePackage(channel.PackageID.String, channel.ID, channel.ApplicationID, channel.Arch); err != nil {
 			return nil, err
 		}
 	}
 	query, _, err := goqu.Insert("channel").
-		Cols("name", "color", "application_id", "package_id", "arch").
+		Cols("name", "color", "application_id", "package_id", "arch", "version_constraint").
 		Vals(goqu.Vals{
 			channel.Name,
 			channel.Color,
 			channel.ApplicationID,
 			channel.PackageID,
-			channel.Arch}).
+			channel.Arch,
+			channel.VersionConstraint}).
 		Returning(goqu.T("channel").All()).
 		ToSQL()
 	if err != nil {
 		return nil, err
 	}
-	err = api.db.QueryRowx(query).StructScan(channel)
+	tx, err := api.db.Beginx()
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AddChannel - could not roll back")
+		}
+	}()
+	err = tx.QueryRowx(query).StructScan(channel)
+	if err != nil {
+		return nil, wrapUniqueViolation(err, "channel %q", channel.Name)
+	}
+	channelAfterAdd, err := getChannelAuditSnapshot(tx, channel.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityCreated, auditEntityChannel, channel.ID, channel.ApplicationID, nil, channelAfterAdd); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if channel.PackageID.String != "" {
+		if err := api.recordChannelPackage(channel.ID, null.String{}, channel.PackageID); err != nil {
+			logger.Error().Err(err).Msg("AddChannel - could not record channel package history")
+		}
+	}
 	return channel, nil
 }
 
 // UpdateChannel updates an existing channel using the content of the channel
-// provided.
+// provided. If the channel's revision is set, the update fails with
+// ErrStaleRevision when the channel was modified since that revision was read.
 func (api *API) UpdateChannel(channel *Channel) error {
+	if err := validateVersionConstraint(channel); err != nil {
+		return err
+	}
 	channelBeforeUpdate, err := api.GetChannel(channel.ID)
 	if err != nil {
 		return err
 	}
+	if !strings.EqualFold(channel.Color, channelBeforeUpdate.Color) {
+		if err := api.validateChannelColor(channel.ID, channelBeforeUpdate.ApplicationID, channel.Color); err != nil {
+			return err
+		}
+	}
 
 	var pkg *Package
 	if channel.PackageID.String != "" {
@@ -75,29 +165,66 @@ func (api *API) UpdateChannel(channel *Channel) error {
 			return err
 		}
 	}
+	where := []exp.Expression{goqu.C("id").Eq(channel.ID)}
+	if channel.Revision > 0 {
+		where = append(where, goqu.C("revision").Eq(channel.Revision))
+	}
 	query, _, err := goqu.Update("channel").
 		Set(goqu.Record{
-			"name":       channel.Name,
-			"color":      channel.Color,
-			"package_id": channel.PackageID,
+			"name":               channel.Name,
+			"color":              channel.Color,
+			"package_id":         channel.PackageID,
+			"version_constraint": channel.VersionConstraint,
+			"revision":           goqu.L("revision + 1"),
 		}).
-		Where(goqu.C("id").Eq(channel.ID)).
+		Where(where...).
 		ToSQL()
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("UpdateChannel - could not roll back")
+		}
+	}()
+	auditBefore, err := getChannelAuditSnapshot(tx, channel.ID)
 	if err != nil {
 		return err
 	}
+	result, err := tx.Exec(query)
+	if err != nil {
+		return wrapUniqueViolation(err, "channel %q", channel.Name)
+	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if rowsAffected == 0 {
+		if channel.Revision > 0 {
+			return ErrStaleRevision
+		}
 		return ErrNoRowsAffected
 	}
+	auditAfter, err := getChannelAuditSnapshot(tx, channel.ID)
+	if err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityUpdated, auditEntityChannel, channel.ID, channelBeforeUpdate.ApplicationID, auditBefore, auditAfter); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
+	if channelBeforeUpdate.PackageID.String != channel.PackageID.String {
+		if err := api.recordChannelPackage(channel.ID, channelBeforeUpdate.PackageID, channel.PackageID); err != nil {
+			logger.Error().Err(err).Msg("UpdateChannel - could not record channel package history")
+		}
+	}
 	if channelBeforeUpdate.PackageID.String != channel.PackageID.String && pkg != nil {
 		if err := api.newChannelActivityEntry(activityChannelPackageUpdated, activityInfo, pkg.Version, pkg.ApplicationID, channel.ID); err != nil {
 			logger.Error().Err(err).Msg("UpdateChannel - could not add channel activity")
@@ -107,31 +234,151 @@ func (api *API) UpdateChannel(channel *Channel) error {
 	return nil
 }
 
+// PromotePackage points the channel identified by toChannelID to the package
+// the channel identified by fromChannelID points to, as in promoting a
+// package from an edge channel to a stable one. The package must be newer
+// than the one the target channel currently points to, if any.
+func (api *API) PromotePackage(fromChannelID, toChannelID string) (*Package, error) {
+	for _, channelID := range []string{fromChannelID, toChannelID} {
+		if err := api.checkChannelTeam(channelID); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("PromotePackage - could not roll back")
+		}
+	}()
+
+	// Lock both channels so that they can't be changed concurrently.
+	query, _, err := goqu.From("channel").
+		Where(goqu.C("id").In(fromChannelID, toChannelID)).
+		Order(goqu.C("id").Asc()).
+		ForUpdate(exp.Wait).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	var fromChannel, toChannel *Channel
+	for rows.Next() {
+		channel := &Channel{}
+		if err := rows.StructScan(channel); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if channel.ID == fromChannelID {
+			fromChannel = channel
+		}
+		if channel.ID == toChannelID {
+			toChannel = channel
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if fromChannel == nil || toChannel == nil {
+		return nil, sql.ErrNoRows
+	}
+	if !fromChannel.PackageID.Valid {
+		return nil, ErrChannelNoPackage
+	}
+
+	pkg, err := api.validatePackage(fromChannel.PackageID.String, toChannel.ID, toChannel.ApplicationID, toChannel.Arch)
+	if err != nil {
+		return nil, err
+	}
+	if toChannel.PackageID.Valid {
+		currentPkg, err := api.GetPackage(toChannel.PackageID.String)
+		if err != nil {
+			return nil, err
+		}
+		newSemver, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return nil, err
+		}
+		currentSemver, err := semver.Parse(currentPkg.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !currentSemver.LT(newSemver) {
+			return nil, ErrPromotionNotNewer
+		}
+	}
+
+	query, _, err = goqu.Update("channel").
+		Set(goqu.Record{"package_id": pkg.ID, "revision": goqu.L("revision + 1")}).
+		Where(goqu.C("id").Eq(toChannel.ID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := api.recordChannelPackage(toChannel.ID, toChannel.PackageID, null.StringFrom(pkg.ID)); err != nil {
+		logger.Error().Err(err).Msg("PromotePackage - could not record channel package history")
+	}
+	if err := api.newChannelActivityEntry(activityChannelPackagePromoted, activityInfo, pkg.Version, pkg.ApplicationID, toChannel.ID); err != nil {
+		logger.Error().Err(err).Msg("PromotePackage - could not add channel activity")
+	}
+
+	return pkg, nil
+}
+
 // DeleteChannel removes the channel identified by the id provided.
 func (api *API) DeleteChannel(channelID string) error {
+	if err := api.checkChannelTeam(channelID); err != nil {
+		return err
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("DeleteChannel - could not roll back")
+		}
+	}()
+	channelBeforeDelete, err := getChannelAuditSnapshot(tx, channelID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
 	query, _, err := goqu.Delete("channel").
 		Where(goqu.C("id").Eq(channelID)).
 		ToSQL()
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
-	if err != nil {
+	if _, err := tx.Exec(query); err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := api.recordAuditEntry(tx, activityEntityDeleted, auditEntityChannel, channelID, channelBeforeDelete.ApplicationID, channelBeforeDelete, nil); err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNoRowsAffected
-	}
 
-	return nil
+	return tx.Commit()
 }
 
 // GetChannel returns the channel identified by the id provided.
 func (api *API) GetChannel(channelID string) (*Channel, error) {
+	if err := api.checkChannelTeam(channelID); err != nil {
+		return nil, err
+	}
 	var channel Channel
 
 	query, _, err := goqu.From("channel").
@@ -197,6 +444,9 @@ func (api *API) getSpecificChannels(channelID ...string) ([]*Channel, error) {
 
 // GetChannels returns all channels associated to the application provided.
 func (api *API) GetChannels(appID string, page, perPage uint64) ([]*Channel, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	page, perPage = validatePaginationParams(page, perPage)
 	limit, offset := sqlPaginate(page, perPage)
 	query, _, err := api.channelsQuery().
@@ -250,6 +500,164 @@ func (api *API) getChannelsFromQuery(query string) ([]*Channel, error) {
 	return channels, nil
 }
 
+// GetChannelDivergence returns the gap between the packages of every pair of
+// channels of the application provided that share the same architecture and
+// point to a package.
+func (api *API) GetChannelDivergence(appID string) ([]ChannelGap, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	channels, err := api.getChannels(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, _, err := goqu.From("package").
+		Select("version", "arch").
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versionsByArch := make(map[Arch][]semver.Version)
+	for rows.Next() {
+		var (
+			version string
+			arch    Arch
+		)
+		if err := rows.Scan(&version, &arch); err != nil {
+			return nil, err
+		}
+		v, err := semver.Make(version)
+		if err != nil {
+			continue
+		}
+		versionsByArch[arch] = append(versionsByArch[arch], v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	gaps := []ChannelGap{}
+	for i, channel := range channels {
+		if channel.Package == nil {
+			continue
+		}
+		for _, other := range channels[i+1:] {
+			if other.Package == nil || other.Arch != channel.Arch {
+				continue
+			}
+			behind, ahead := channel, other
+			behindVersion, _ := semver.Make(behind.Package.Version)
+			aheadVersion, _ := semver.Make(ahead.Package.Version)
+			if behindVersion.GT(aheadVersion) {
+				behind, ahead = ahead, behind
+				behindVersion, aheadVersion = aheadVersion, behindVersion
+			}
+			distance := 0
+			for _, v := range versionsByArch[channel.Arch] {
+				if v.GT(behindVersion) && v.LTE(aheadVersion) {
+					distance++
+				}
+			}
+			gaps = append(gaps, ChannelGap{
+				BehindChannelID:   behind.ID,
+				BehindChannelName: behind.Name,
+				BehindVersion:     behind.Package.Version,
+				AheadChannelID:    ahead.ID,
+				AheadChannelName:  ahead.Name,
+				AheadVersion:      ahead.Package.Version,
+				Arch:              channel.Arch,
+				Distance:          distance,
+			})
+		}
+	}
+
+	return gaps, nil
+}
+
+// recordChannelPackage adds an entry to the package history of the channel
+// provided, recording that it now points to the given package instead of the
+// previous one, and the actor of the api instance that changed it. The
+// package is null when the channel was left without package.
+func (api *API) recordChannelPackage(channelID string, previousPackageID, packageID null.String) error {
+	query, _, err := goqu.Insert("channel_package_history").
+		Cols("channel_id", "previous_package_id", "package_id", "actor").
+		Vals(goqu.Vals{channelID, previousPackageID, packageID, null.NewString(api.actor, api.actor != "")}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+
+	return err
+}
+
+// GetChannelHistory returns the changes of the package the channel provided
+// points to, latest first.
+func (api *API) GetChannelHistory(channelID string) ([]*ChannelHistoryEntry, error) {
+	if err := api.checkChannelTeam(channelID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From(goqu.T("channel_package_history").As("h")).
+		LeftJoin(goqu.T("package").As("p"), goqu.On(goqu.I("p.id").Eq(goqu.I("h.package_id")))).
+		LeftJoin(goqu.T("package").As("pp"), goqu.On(goqu.I("pp.id").Eq(goqu.I("h.previous_package_id")))).
+		Select("h.id", "h.channel_id", "h.previous_package_id", goqu.I("pp.version").As("previous_version"), "h.package_id", "p.version", "h.actor", "h.created_ts").
+		Where(goqu.I("h.channel_id").Eq(channelID)).
+		Order(goqu.I("h.created_ts").Desc(), goqu.I("h.id").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	history := []*ChannelHistoryEntry{}
+	if err := api.readDB().Select(&history, query); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// getPreviousChannelPackageID returns the id of the package the channel
+// provided pointed to most recently before switching to the current one.
+func (api *API) getPreviousChannelPackageID(channelID, currentPackageID string) (string, error) {
+	query, _, err := goqu.From("channel_package_history").
+		Select("package_id").
+		Where(goqu.C("channel_id").Eq(channelID), goqu.C("package_id").Neq(currentPackageID)).
+		Order(goqu.C("created_ts").Desc(), goqu.C("id").Desc()).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return "", err
+	}
+	var packageID string
+	if err := api.db.QueryRow(query).Scan(&packageID); err != nil {
+		return "", err
+	}
+	return packageID, nil
+}
+
+// getChannelHistoryPackages returns the packages the channel provided has
+// pointed to at some point, newest version first.
+func (api *API) getChannelHistoryPackages(channelID string) ([]*Package, error) {
+	query, _, err := api.packagesQuery().
+		Where(goqu.L("package.id IN (SELECT package_id FROM channel_package_history WHERE channel_id = ?)", channelID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := api.getPackagesFromQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	// The query only sorts by major, minor and patch numbers.
+	sortPackagesByVersion(pkgs)
+	return pkgs, nil
+}
+
 // validatePackage checks if a package belongs to the application provided and
 // that the channel is not in the package's channels blacklist. It returns the
 // package if everything is ok.
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -89,6 +90,162 @@ func TestUpdateChannel(t *testing.T) {
 	assert.Equal(t, ErrBlacklistedChannel, err, "Package used must not have blacklisted this channel.")
 }
 
+func TestUpdateChannel_StaleRevision(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID})
+	assert.Equal(t, 1, tChannel.Revision)
+
+	// Two operators read the same revision of the channel.
+	channel1, err := a.GetChannel(tChannel.ID)
+	require.NoError(t, err)
+	channel2, err := a.GetChannel(tChannel.ID)
+	require.NoError(t, err)
+
+	channel1.PackageID = null.StringFrom(tPkg.ID)
+	assert.NoError(t, a.UpdateChannel(channel1))
+
+	channel2.Color = "red"
+	assert.Equal(t, ErrStaleRevision, a.UpdateChannel(channel2))
+
+	channel, err := a.GetChannel(tChannel.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, channel.Revision)
+	assert.Equal(t, "blue", channel.Color, "The stale write didn't clobber the channel.")
+	assert.Equal(t, tPkg.ID, channel.PackageID.String)
+
+	channel.Color = "red"
+	assert.NoError(t, a.UpdateChannel(channel))
+
+	// Updates without a revision are always applied.
+	assert.NoError(t, a.UpdateChannel(&Channel{ID: tChannel.ID, Name: "test_channel", Color: "green"}))
+	channel, err = a.GetChannel(tChannel.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, channel.Revision)
+	assert.Equal(t, "green", channel.Color)
+}
+
+func TestPromotePackage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tEdge, _ := a.AddChannel(&Channel{Name: "edge", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg2.ID)})
+	tBeta, _ := a.AddChannel(&Channel{Name: "beta", Color: "yellow", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tStable, _ := a.AddChannel(&Channel{Name: "stable", Color: "green", ApplicationID: tApp.ID})
+
+	pkg, err := a.PromotePackage(tEdge.ID, tBeta.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg2.ID, pkg.ID)
+
+	channel, _ := a.GetChannel(tBeta.ID)
+	assert.Equal(t, tPkg2.ID, channel.PackageID.String)
+
+	pkg, err = a.PromotePackage(tBeta.ID, tStable.ID)
+	assert.NoError(t, err, "Packages can be promoted to channels without a package.")
+	assert.Equal(t, tPkg2.ID, pkg.ID)
+
+	channel, _ = a.GetChannel(tStable.ID)
+	assert.Equal(t, tPkg2.ID, channel.PackageID.String)
+
+	_, err = a.PromotePackage(tBeta.ID, tStable.ID)
+	assert.Equal(t, ErrPromotionNotNewer, err, "Channel already points to the promoted package.")
+
+	err = a.UpdateChannel(&Channel{ID: tEdge.ID, Name: tEdge.Name, Color: tEdge.Color, PackageID: null.StringFrom(tPkg.ID)})
+	assert.NoError(t, err)
+
+	_, err = a.PromotePackage(tEdge.ID, tStable.ID)
+	assert.Equal(t, ErrPromotionNotNewer, err, "A lower version must not be promoted over a higher one.")
+
+	channel, _ = a.GetChannel(tStable.ID)
+	assert.Equal(t, tPkg2.ID, channel.PackageID.String)
+
+	tEmpty, _ := a.AddChannel(&Channel{Name: "empty", Color: "blue", ApplicationID: tApp.ID})
+	_, err = a.PromotePackage(tEmpty.ID, tStable.ID)
+	assert.Equal(t, ErrChannelNoPackage, err)
+
+	_, err = a.PromotePackage(tEdge.ID, uuid.New().String())
+	assert.Error(t, err, "Target channel must exist.")
+}
+
+func TestGetChannelHistory(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	alice := a.WithActor("alice")
+	bob := a.WithActor("bob")
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "638.0.0", ApplicationID: tApp.ID})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID})
+	tPkg3, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "641.0.0", ApplicationID: tApp.ID})
+	tEdge, _ := a.AddChannel(&Channel{Name: "edge", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg3.ID)})
+
+	tChannel, err := alice.AddChannel(&Channel{Name: "stable", Color: "green", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg1.ID)})
+	require.NoError(t, err)
+
+	err = bob.UpdateChannel(&Channel{ID: tChannel.ID, Name: tChannel.Name, Color: tChannel.Color, PackageID: null.StringFrom(tPkg2.ID)})
+	require.NoError(t, err)
+
+	// Changes not involving the package aren't part of the history.
+	err = bob.UpdateChannel(&Channel{ID: tChannel.ID, Name: "renamed", Color: tChannel.Color, PackageID: null.StringFrom(tPkg2.ID)})
+	require.NoError(t, err)
+
+	_, err = alice.PromotePackage(tEdge.ID, tChannel.ID)
+	require.NoError(t, err)
+
+	history, err := a.GetChannelHistory(tChannel.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	assert.Equal(t, null.StringFrom(tPkg2.ID), history[0].PreviousPackageID)
+	assert.Equal(t, null.StringFrom("640.0.0"), history[0].PreviousVersion)
+	assert.Equal(t, null.StringFrom(tPkg3.ID), history[0].PackageID)
+	assert.Equal(t, null.StringFrom("641.0.0"), history[0].Version)
+	assert.Equal(t, null.StringFrom("alice"), history[0].Actor)
+
+	assert.Equal(t, null.StringFrom(tPkg1.ID), history[1].PreviousPackageID)
+	assert.Equal(t, null.StringFrom("638.0.0"), history[1].PreviousVersion)
+	assert.Equal(t, null.StringFrom(tPkg2.ID), history[1].PackageID)
+	assert.Equal(t, null.StringFrom("640.0.0"), history[1].Version)
+	assert.Equal(t, null.StringFrom("bob"), history[1].Actor)
+
+	assert.False(t, history[2].PreviousPackageID.Valid)
+	assert.False(t, history[2].PreviousVersion.Valid)
+	assert.Equal(t, null.StringFrom(tPkg1.ID), history[2].PackageID)
+	assert.Equal(t, null.StringFrom("alice"), history[2].Actor)
+
+	for i := 1; i < len(history); i++ {
+		assert.False(t, history[i].CreatedTs.After(history[i-1].CreatedTs), "History is sorted latest first.")
+		assert.Equal(t, tChannel.ID, history[i].ChannelID)
+	}
+
+	history, err = a.GetChannelHistory(tEdge.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.False(t, history[0].Actor.Valid)
+
+	// Leaving the channel without package is part of the history too.
+	err = bob.UpdateChannel(&Channel{ID: tChannel.ID, Name: "renamed", Color: tChannel.Color})
+	require.NoError(t, err)
+
+	history, err = a.GetChannelHistory(tChannel.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	assert.Equal(t, null.StringFrom(tPkg3.ID), history[0].PreviousPackageID)
+	assert.False(t, history[0].PackageID.Valid)
+	assert.False(t, history[0].Version.Valid)
+	assert.Equal(t, null.StringFrom("bob"), history[0].Actor)
+}
+
 func TestDeleteChannel(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -156,3 +313,33 @@ func TestGetChannels(t *testing.T) {
 	_, err = a.GetChannels(uuid.New().String(), 0, 0)
 	assert.NoError(t, err, "no error for a non existing appID")
 }
+
+func TestGetChannelDivergence(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "1.0.0", ApplicationID: tApp.ID})
+	_, _ = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "1.1.0", ApplicationID: tApp.ID})
+	tPkg3, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "1.2.0", ApplicationID: tApp.ID})
+	tPkg4, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "2.0.0", ApplicationID: tApp.ID})
+	tStable, _ := a.AddChannel(&Channel{Name: "stable", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg1.ID)})
+	tBeta, _ := a.AddChannel(&Channel{Name: "beta", Color: "green", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg3.ID)})
+	tAlpha, _ := a.AddChannel(&Channel{Name: "alpha", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg4.ID)})
+	_, _ = a.AddChannel(&Channel{Name: "empty", Color: "white", ApplicationID: tApp.ID})
+
+	gaps, err := a.GetChannelDivergence(tApp.ID)
+	assert.NoError(t, err)
+	assert.Len(t, gaps, 3)
+
+	distances := make(map[string]int)
+	for _, gap := range gaps {
+		distances[gap.BehindChannelID+":"+gap.AheadChannelID] = gap.Distance
+	}
+	assert.Equal(t, map[string]int{
+		tStable.ID + ":" + tBeta.ID:  2,
+		tStable.ID + ":" + tAlpha.ID: 3,
+		tBeta.ID + ":" + tAlpha.ID:   1,
+	}, distances)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"hash/fnv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrInvalidChannelWeights error indicates that the channel weights of a
+	// group have a non positive weight or reference a channel more than once.
+	ErrInvalidChannelWeights = newValidationError("nebraska: invalid channel weights")
+)
+
+// ChannelWeight represents the share of the instances of a group that get the
+// package of a given channel, used to split a group between several channels
+// for A/B testing. Each instance gets the channel with a probability of its
+// weight divided by the sum of the weights of the group's channels.
+type ChannelWeight struct {
+	ChannelID string `db:"channel_id" json:"channel_id"`
+	Weight    int    `db:"weight" json:"weight"`
+}
+
+// validateChannelWeights checks that the channel weights provided have
+// positive weights, don't reference a channel twice and that the channels
+// belong to the given application and are for the architecture provided.
+func (api *API) validateChannelWeights(weights []ChannelWeight, appID string, arch Arch) error {
+	seen := make(map[string]bool)
+	for _, weight := range weights {
+		if weight.Weight <= 0 || seen[weight.ChannelID] {
+			return ErrInvalidChannelWeights
+		}
+		seen[weight.ChannelID] = true
+
+		channel, err := api.GetChannel(weight.ChannelID)
+		if err != nil {
+			return err
+		}
+		if channel.ApplicationID != appID {
+			return ErrInvalidChannel
+		}
+		if channel.Arch != arch {
+			return ErrArchMismatch
+		}
+	}
+	return nil
+}
+
+// channelWeightsArch returns the architecture the channels weighted in the
+// group provided must be for, which is the one of the group's channel or, if
+// it has none, the one of the first weighted channel.
+func (api *API) channelWeightsArch(group *Group) (Arch, error) {
+	channelID := group.ChannelID.String
+	if channelID == "" {
+		if len(group.ChannelWeights) == 0 {
+			return ArchAll, nil
+		}
+		channelID = group.ChannelWeights[0].ChannelID
+	}
+	channel, err := api.GetChannel(channelID)
+	if err != nil {
+		return ArchAll, err
+	}
+	return channel.Arch, nil
+}
+
+// pickWeightedChannel returns the id of the channel an instance of a group
+// gets among the weighted channels provided. The pick is deterministic, so an
+// instance stays on the same channel as long as the weights don't change, and
+// independent of the bucket used for percentage based rollouts.
+func pickWeightedChannel(groupID, instanceID string, weights []ChannelWeight) string {
+	total := 0
+	for _, weight := range weights {
+		total += weight.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(groupID))
+	_, _ = h.Write([]byte(instanceID))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for _, weight := range weights {
+		if bucket < weight.Weight {
+			return weight.ChannelID
+		}
+		bucket -= weight.Weight
+	}
+	return ""
+}
+
+// getGroupChannelWeights returns the channel weights of the group provided,
+// sorted by channel id.
+func (api *API) getGroupChannelWeights(q sqlx.Queryer, groupID string) ([]ChannelWeight, error) {
+	query, _, err := goqu.From("group_channel_weight").
+		Select("channel_id", "weight").
+		Where(goqu.C("group_id").Eq(groupID)).
+		Order(goqu.C("channel_id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	weights := []ChannelWeight{}
+	if err := sqlx.Select(q, &weights, query); err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// setGroupChannelWeights replaces the channel weights of the group provided,
+// within the transaction given.
+func (api *API) setGroupChannelWeights(tx *sqlx.Tx, groupID string, weights []ChannelWeight) error {
+	query, _, err := goqu.Delete("group_channel_weight").
+		Where(goqu.C("group_id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	for _, weight := range weights {
+		query, _, err := goqu.Insert("group_channel_weight").
+			Cols("group_id", "channel_id", "weight").
+			Vals(goqu.Vals{groupID, weight.ChannelID, weight.Weight}).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
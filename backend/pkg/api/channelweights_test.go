@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestPickWeightedChannel(t *testing.T) {
+	weights := []ChannelWeight{{ChannelID: "a", Weight: 90}, {ChannelID: "b", Weight: 10}}
+	groupID := uuid.New().String()
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		instanceID := fmt.Sprintf("instance-%d", i)
+		channelID := pickWeightedChannel(groupID, instanceID, weights)
+		assert.Equal(t, channelID, pickWeightedChannel(groupID, instanceID, weights), "Instances are always assigned to the same channel.")
+		counts[channelID]++
+	}
+	assert.Len(t, counts, 2)
+	assert.InDelta(t, 9000, counts["a"], 300)
+	assert.InDelta(t, 1000, counts["b"], 300)
+
+	assert.Equal(t, "", pickWeightedChannel(groupID, "instance", nil))
+	assert.Equal(t, "a", pickWeightedChannel(groupID, "instance", []ChannelWeight{{ChannelID: "a", Weight: 1}}))
+}
+
+func TestGetUpdatePackage_ChannelWeights(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+	tPkgA, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg-a", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgB, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg-b", Version: "12.2.0", ApplicationID: tApp.ID})
+	tChannelA, _ := a.AddChannel(&Channel{Name: "channel_a", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgA.ID)})
+	tChannelB, _ := a.AddChannel(&Channel{Name: "channel_b", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgB.ID)})
+	tChannelARM, _ := a.AddChannel(&Channel{Name: "channel_arm", Color: "green", ApplicationID: tApp.ID, Arch: ArchAArch64})
+	tChannelApp2, _ := a.AddChannel(&Channel{Name: "channel_app2", Color: "green", ApplicationID: tApp2.ID})
+
+	newGroup := func(weights []ChannelWeight) *Group {
+		return &Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannelA.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10000, PolicyUpdateTimeout: "60 minutes", ChannelWeights: weights}
+	}
+
+	_, err := a.AddGroup(newGroup([]ChannelWeight{{ChannelID: tChannelA.ID, Weight: 0}}))
+	assert.Equal(t, ErrInvalidChannelWeights, err)
+	_, err = a.AddGroup(newGroup([]ChannelWeight{{ChannelID: tChannelA.ID, Weight: 1}, {ChannelID: tChannelA.ID, Weight: 1}}))
+	assert.Equal(t, ErrInvalidChannelWeights, err)
+	_, err = a.AddGroup(newGroup([]ChannelWeight{{ChannelID: tChannelApp2.ID, Weight: 1}}))
+	assert.Equal(t, ErrInvalidChannel, err)
+	_, err = a.AddGroup(newGroup([]ChannelWeight{{ChannelID: tChannelARM.ID, Weight: 1}}))
+	assert.Equal(t, ErrArchMismatch, err)
+
+	weights := []ChannelWeight{{ChannelID: tChannelA.ID, Weight: 90}, {ChannelID: tChannelB.ID, Weight: 10}}
+	tGroup, err := a.AddGroup(newGroup(weights))
+	require.NoError(t, err)
+
+	group, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, weights, group.ChannelWeights)
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		counts[pkg.ID]++
+	}
+	assert.InDelta(t, 900, counts[tPkgA.ID], 60)
+	assert.InDelta(t, 100, counts[tPkgB.ID], 60)
+
+	// Removing the weights sends every instance to the group's channel.
+	group.ChannelWeights = nil
+	require.NoError(t, a.UpdateGroup(group))
+	group, err = a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.Empty(t, group.ChannelWeights)
+	for i := 0; i < 20; i++ {
+		pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tPkgA.ID, pkg.ID)
+	}
+}
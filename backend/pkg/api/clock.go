@@ -0,0 +1,54 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the time based logic of the API, like
+// the office hours policy or the recent activity checks.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock, backed by the system's wall clock.
+type realClock struct{}
+
+// Now returns the current time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// MockClock is a Clock that only changes its time when told to, which makes
+// time based policies testable. Note that times computed by the database
+// (like now() in queries) are not affected by it.
+type MockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewMockClock creates a new MockClock set to the time provided.
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{now: now}
+}
+
+// Now returns the time the clock is currently set to.
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock to the time provided.
+func (c *MockClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by the duration provided.
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
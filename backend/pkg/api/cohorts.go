@@ -0,0 +1,129 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"hash/fnv"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+var (
+	// ErrInvalidRolloutCohorts error indicates that the number of rollout
+	// cohorts of a group is negative, or that its active cohort is not one of
+	// them.
+	ErrInvalidRolloutCohorts = newValidationError("nebraska: invalid rollout cohorts")
+
+	// ErrRolloutCohortsDisabled error indicates an attempt of advancing the
+	// active cohort of a group that doesn't roll out by cohorts.
+	ErrRolloutCohortsDisabled = errors.New("nebraska: group doesn't roll out by cohorts")
+
+	// ErrLastRolloutCohort error indicates an attempt of advancing the active
+	// cohort of a group whose last cohort is already active.
+	ErrLastRolloutCohort = errors.New("nebraska: last rollout cohort already active")
+
+	// ErrRolloutCohortNotActive indicates that the instance is in a rollout
+	// cohort of its group that hasn't been reached yet.
+	ErrRolloutCohortNotActive = errors.New("nebraska: rollout cohort not active yet")
+)
+
+// normalizeRolloutCohorts checks the rollout cohorts of the group provided,
+// making the first cohort the active one when the group rolls out by cohorts
+// and none is set, and clearing the active cohort when it doesn't.
+func normalizeRolloutCohorts(group *Group) error {
+	if group.PolicyRolloutCohorts < 0 || group.PolicyActiveCohort < 0 {
+		return ErrInvalidRolloutCohorts
+	}
+	if group.PolicyRolloutCohorts == 0 {
+		group.PolicyActiveCohort = 0
+		return nil
+	}
+	if group.PolicyActiveCohort == 0 {
+		group.PolicyActiveCohort = 1
+	}
+	if group.PolicyActiveCohort > group.PolicyRolloutCohorts {
+		return ErrInvalidRolloutCohorts
+	}
+	return nil
+}
+
+// rolloutCohort returns the cohort, between 1 and the number of cohorts
+// provided, the instance provided belongs to. It is derived from the instance
+// id so instances always fall in the same cohort.
+func rolloutCohort(instanceID string, cohorts int) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(instanceID))
+	return int(h.Sum64()%uint64(cohorts)) + 1
+}
+
+// inActiveCohort checks if the instance provided belongs to one of the
+// rollout cohorts of the group already reached, that is, the active cohort or
+// any of the previous ones. All instances are in groups not rolling out by
+// cohorts.
+func inActiveCohort(instanceID string, group *Group) bool {
+	if group.PolicyRolloutCohorts == 0 {
+		return true
+	}
+	return rolloutCohort(instanceID, group.PolicyRolloutCohorts) <= group.PolicyActiveCohort
+}
+
+// AdvanceGroupCohort makes the next rollout cohort of the group provided the
+// active one, so that its instances are offered the group's updates too.
+func (api *API) AdvanceGroupCohort(groupID string) (*Group, error) {
+	groupBeforeUpdate, err := api.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if groupBeforeUpdate.PolicyRolloutCohorts == 0 {
+		return nil, ErrRolloutCohortsDisabled
+	}
+
+	// The condition on the number of cohorts guards against concurrent
+	// advances going beyond the last one.
+	query, _, err := goqu.Update("groups").
+		Set(goqu.Record{"policy_active_cohort": goqu.L("policy_active_cohort + 1")}).
+		Where(
+			goqu.C("id").Eq(groupID),
+			goqu.C("policy_active_cohort").Lt(goqu.I("policy_rollout_cohorts")),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AdvanceGroupCohort - could not roll back")
+		}
+	}()
+	auditBefore, err := api.getGroupAuditSnapshot(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(query)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrLastRolloutCohort
+	}
+	auditAfter, err := api.getGroupAuditSnapshot(tx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityUpdated, auditEntityGroup, groupID, groupBeforeUpdate.ApplicationID, auditBefore, auditAfter); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return api.GetGroup(groupID)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRolloutCohort(t *testing.T) {
+	seen := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		instanceID := fmt.Sprintf("instance-%d", i)
+		cohort := rolloutCohort(instanceID, 4)
+		require.True(t, cohort >= 1 && cohort <= 4, "Cohorts go from 1 to the number of cohorts.")
+		assert.Equal(t, cohort, rolloutCohort(instanceID, 4), "Instances must always fall in the same cohort.")
+		seen[cohort]++
+	}
+	assert.Len(t, seen, 4, "Instances must be spread over all the cohorts.")
+	for cohort, instances := range seen {
+		assert.InDelta(t, 250, instances, 75, "Cohort %d has too many or too few instances.", cohort)
+	}
+}
+
+func TestAddGroup_RolloutCohorts(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	group, err := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyRolloutCohorts: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 1, group.PolicyActiveCohort, "Rollouts start with the first cohort.")
+
+	group, err = a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyActiveCohort: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 0, group.PolicyActiveCohort, "Groups not rolling out by cohorts have no active cohort.")
+
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyRolloutCohorts: 3, PolicyActiveCohort: 4})
+	assert.Equal(t, ErrInvalidRolloutCohorts, err)
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyRolloutCohorts: -1})
+	assert.Equal(t, ErrInvalidRolloutCohorts, err)
+}
+
+func TestAdvanceGroupCohort(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyRolloutCohorts: 3})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	group, err := a.AdvanceGroupCohort(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, group.PolicyActiveCohort)
+	group, err = a.AdvanceGroupCohort(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, group.PolicyActiveCohort)
+
+	_, err = a.AdvanceGroupCohort(tGroup.ID)
+	assert.Equal(t, ErrLastRolloutCohort, err)
+	group, _ = a.GetGroup(tGroup.ID)
+	assert.Equal(t, 3, group.PolicyActiveCohort)
+
+	_, err = a.AdvanceGroupCohort(tGroup2.ID)
+	assert.Equal(t, ErrRolloutCohortsDisabled, err)
+}
+
+func TestGetUpdatePackage_RolloutCohorts(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyRolloutCohorts: 3})
+
+	// Pick an instance of each cohort.
+	cohortInstances := make(map[int]string)
+	for len(cohortInstances) < 3 {
+		instanceID := uuid.New().String()
+		cohortInstances[rolloutCohort(instanceID, 3)] = instanceID
+	}
+
+	_, err := a.GetUpdatePackage(cohortInstances[1], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	for _, cohort := range []int{2, 3} {
+		_, err := a.GetUpdatePackage(cohortInstances[cohort], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		assert.Equal(t, ErrRolloutCohortNotActive, err, "Cohort %d hasn't been reached yet.", cohort)
+	}
+	instance, _ := a.GetInstance(cohortInstances[2], tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusOnHold)), instance.Application.Status)
+
+	_, err = a.AdvanceGroupCohort(tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.GetUpdatePackage(cohortInstances[2], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	_, err = a.GetUpdatePackage(cohortInstances[3], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrRolloutCohortNotActive, err)
+
+	_, err = a.AdvanceGroupCohort(tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.GetUpdatePackage(cohortInstances[3], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	// Instances of the cohorts already reached keep being served.
+	instanceID := uuid.New().String()
+	for rolloutCohort(instanceID, 3) != 1 {
+		instanceID = uuid.New().String()
+	}
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+)
+
+// DashboardSummary represents an overview of the fleet managed by Nebraska,
+// so it can be displayed without querying every application and group.
+type DashboardSummary struct {
+	Applications       int `json:"applications"`
+	Groups             int `json:"groups"`
+	Instances          int `json:"instances"`
+	InstancesOnLatest  int `json:"instances_on_latest"`
+	RolloutsInProgress int `json:"rollouts_in_progress"`
+}
+
+// GetDashboardSummary returns an overview of the applications, groups and
+// active instances, restricted to the ones of the api instance's team when
+// it's scoped to one. Instances are on the latest version when they run the
+// version of the package of their group's channel.
+func (api *API) GetDashboardSummary() (*DashboardSummary, error) {
+	apps := goqu.From(goqu.T("application").As("a")).
+		Where(goqu.I("a.deleted_at").IsNull())
+	if api.teamID != "" {
+		apps = apps.Where(goqu.I("a.team_id").Eq(api.teamID))
+	}
+
+	summary := &DashboardSummary{}
+
+	query, _, err := apps.Select(goqu.COUNT("*")).ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.readDB().QueryRow(query).Scan(&summary.Applications); err != nil {
+		return nil, err
+	}
+
+	query, _, err = apps.
+		Join(goqu.T("groups").As("g"), goqu.On(goqu.I("g.application_id").Eq(goqu.I("a.id")))).
+		Select(
+			goqu.COUNT("*"),
+			goqu.COALESCE(goqu.SUM(goqu.L("case when g.rollout_in_progress then 1 else 0 end")), 0),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.readDB().QueryRow(query).Scan(&summary.Groups, &summary.RolloutsInProgress); err != nil {
+		return nil, err
+	}
+
+	query, _, err = apps.
+		Join(goqu.T("instance_application").As("ia"), goqu.On(goqu.I("ia.application_id").Eq(goqu.I("a.id")))).
+		LeftJoin(goqu.T("groups").As("g"), goqu.On(goqu.I("g.id").Eq(goqu.I("ia.group_id")))).
+		LeftJoin(goqu.T("channel").As("c"), goqu.On(goqu.I("c.id").Eq(goqu.I("g.channel_id")))).
+		LeftJoin(goqu.T("package").As("p"), goqu.On(goqu.I("p.id").Eq(goqu.I("c.package_id")))).
+		Select(
+			goqu.COUNT("*"),
+			goqu.COALESCE(goqu.SUM(goqu.L("case when ia.version = p.version then 1 else 0 end")), 0),
+		).
+		Where(
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.readDB().QueryRow(query).Scan(&summary.Instances, &summary.InstancesOnLatest); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetDashboardSummary(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+	tDeletedApp, _ := a.AddApp(&Application{Name: "deleted_app", TeamID: tTeam.ID})
+	tOtherApp, _ := a.AddApp(&Application{Name: "other_app", TeamID: tTeam2.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup3, _ := a.AddGroup(&Group{Name: "group3", ApplicationID: tApp2.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	_, _ = a.AddGroup(&Group{Name: "group4", ApplicationID: tOtherApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, a.DeleteApp(tDeletedApp.ID))
+
+	// Granting an update starts the rollout of the first group.
+	_, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup1.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.1.0", tApp.ID, tGroup1.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup2.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp2.ID, tGroup3.ID)
+	require.NoError(t, err)
+
+	summary, err := a.WithTeam(tTeam.ID).GetDashboardSummary()
+	require.NoError(t, err)
+	assert.Equal(t, &DashboardSummary{
+		Applications:       2,
+		Groups:             3,
+		Instances:          4,
+		InstancesOnLatest:  1,
+		RolloutsInProgress: 1,
+	}, summary)
+
+	summary, err = a.WithTeam(tTeam2.ID).GetDashboardSummary()
+	require.NoError(t, err)
+	assert.Equal(t, &DashboardSummary{Applications: 1, Groups: 1}, summary)
+
+	// Without a team, the whole fleet is summarized.
+	summary, err = a.GetDashboardSummary()
+	require.NoError(t, err)
+	assert.True(t, summary.Applications >= 3)
+	assert.True(t, summary.Groups >= 4)
+	assert.True(t, summary.Instances >= 4)
+}
@@ -13,6 +13,20 @@ drop table if exists event_type cascade;
 drop table if exists event cascade;
 drop table if exists activity cascade;
 drop table if exists package_channel_blacklist cascade;
+drop table if exists application_allowed_event_type cascade;
+drop table if exists channel_package_history cascade;
+drop table if exists package_mirrors cascade;
+drop table if exists webhook cascade;
+drop table if exists group_update_window cascade;
+drop table if exists group_channel_weight cascade;
+drop table if exists instance_registration_rule cascade;
+drop table if exists package_delta cascade;
+drop table if exists package_artifact cascade;
+drop table if exists instance_update_duration cascade;
+drop table if exists instance_event_fingerprint cascade;
+drop table if exists event_daily_aggregate cascade;
+drop table if exists instance_package_override cascade;
+drop table if exists track_alias cascade;
 drop table if exists database_migrations;
 -- Legacy tables if we're dropping tables in a non-migrated DB
 drop table if exists coreos_action cascade;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_min_healthy_instances integer not null default 0 check (policy_min_healthy_instances >= 0);
+
+-- +migrate Down
+
+alter table groups drop column policy_min_healthy_instances;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+create table application_allowed_event_type (
+	application_id uuid not null references application (id) on delete cascade,
+	event_type integer not null,
+	primary key (application_id, event_type)
+);
+
+-- +migrate Down
+
+drop table if exists application_allowed_event_type;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_max_version_spread integer not null default 0 check (policy_max_version_spread >= 0);
+
+-- +migrate Down
+
+alter table groups drop column policy_max_version_spread;
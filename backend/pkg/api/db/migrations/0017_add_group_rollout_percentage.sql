@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_rollout_percentage integer check (policy_rollout_percentage between 0 and 100);
+
+-- +migrate Down
+
+alter table groups drop column policy_rollout_percentage;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_paused boolean not null default false;
+
+-- +migrate Down
+
+alter table groups drop column policy_paused;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+alter table groups add column policy_rollback_failure_percentage integer check (policy_rollback_failure_percentage between 0 and 100);
+alter table groups add column last_known_good_package_id uuid references package (id) on delete set null;
+
+create table channel_package_history (
+	id serial primary key,
+	channel_id uuid not null references channel (id) on delete cascade,
+	package_id uuid not null references package (id) on delete cascade,
+	created_ts timestamptz default current_timestamp not null
+);
+
+create index on channel_package_history (channel_id, created_ts);
+
+-- +migrate Down
+
+drop table if exists channel_package_history;
+alter table groups drop column last_known_good_package_id;
+alter table groups drop column policy_rollback_failure_percentage;
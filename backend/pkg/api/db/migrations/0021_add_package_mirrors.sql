@@ -0,0 +1,12 @@
+-- +migrate Up
+
+create table package_mirrors (
+	package_id uuid not null references package (id) on delete cascade,
+	priority integer not null,
+	url text not null check (url <> ''),
+	primary key (package_id, priority)
+);
+
+-- +migrate Down
+
+drop table if exists package_mirrors;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+create index if not exists instance_ip_idx on instance using gist (ip inet_ops);
+
+-- +migrate Down
+
+drop index if exists instance_ip_idx;
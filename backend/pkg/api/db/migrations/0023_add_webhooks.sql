@@ -0,0 +1,15 @@
+-- +migrate Up
+
+create table webhook (
+	id uuid primary key default uuid_generate_v4(),
+	application_id uuid not null references application (id) on delete cascade,
+	url text not null check (url <> ''),
+	secret text not null,
+	created_ts timestamptz default current_timestamp not null
+);
+
+create index on webhook (application_id);
+
+-- +migrate Down
+
+drop table if exists webhook;
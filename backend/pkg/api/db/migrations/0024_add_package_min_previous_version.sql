@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table package add column min_previous_version varchar(255);
+
+-- +migrate Down
+
+alter table package drop column min_previous_version;
@@ -0,0 +1,14 @@
+-- +migrate Up
+
+alter table application add column deleted_at timestamptz;
+
+-- Names of deleted applications can be reused.
+alter table application drop constraint application_team_id_name_key;
+create unique index application_team_id_name_key on application (team_id, name) where deleted_at is null;
+
+-- +migrate Down
+
+delete from application where deleted_at is not null;
+drop index if exists application_team_id_name_key;
+alter table application add constraint application_team_id_name_key unique (team_id, name);
+alter table application drop column deleted_at;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_pinned_version varchar(255);
+
+-- +migrate Down
+
+alter table groups drop column policy_pinned_version;
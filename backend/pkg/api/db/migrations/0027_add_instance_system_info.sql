@@ -0,0 +1,11 @@
+-- +migrate Up
+
+alter table instance add column arch int not null default 0;
+alter table instance add column board varchar(100) not null default '';
+alter table instance add column platform varchar(100) not null default '';
+
+-- +migrate Down
+
+alter table instance drop column arch;
+alter table instance drop column board;
+alter table instance drop column platform;
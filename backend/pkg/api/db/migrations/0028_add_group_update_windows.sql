@@ -0,0 +1,15 @@
+-- +migrate Up
+
+create table group_update_window (
+	id serial primary key,
+	group_id uuid not null references groups (id) on delete cascade,
+	weekday int not null check (weekday >= 0 and weekday <= 6),
+	start_time varchar(5) not null,
+	end_time varchar(5) not null
+);
+
+create index group_update_window_group_id_idx on group_update_window (group_id);
+
+-- +migrate Down
+
+drop table if exists group_update_window;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table application add column instance_retention_days int check (instance_retention_days > 0);
+
+-- +migrate Down
+
+alter table application drop column instance_retention_days;
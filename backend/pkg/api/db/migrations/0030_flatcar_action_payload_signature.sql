@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table flatcar_action add column payload_signature text not null default '';
+alter table flatcar_action add column signing_key_id varchar(255) not null default '';
+
+-- +migrate Down
+
+alter table flatcar_action drop column payload_signature;
+alter table flatcar_action drop column signing_key_id;
@@ -0,0 +1,10 @@
+-- +migrate Up
+
+alter table groups add column policy_update_timeout_action varchar(20) not null default 'none'
+	check (policy_update_timeout_action in ('none', 'fail', 'reoffer', 'alert'));
+alter table instance_application add column update_timed_out boolean not null default false;
+
+-- +migrate Down
+
+alter table groups drop column policy_update_timeout_action;
+alter table instance_application drop column update_timed_out;
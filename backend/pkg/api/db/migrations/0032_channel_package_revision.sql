@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table channel add column revision int not null default 1;
+alter table package add column revision int not null default 1;
+
+-- +migrate Down
+
+alter table channel drop column revision;
+alter table package drop column revision;
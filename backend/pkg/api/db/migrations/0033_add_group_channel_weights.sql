@@ -0,0 +1,14 @@
+-- +migrate Up
+
+create table group_channel_weight (
+	group_id uuid not null references groups (id) on delete cascade,
+	channel_id uuid not null references channel (id) on delete cascade,
+	weight int not null check (weight > 0),
+	primary key (group_id, channel_id)
+);
+
+create index group_channel_weight_channel_id_idx on group_channel_weight (channel_id);
+
+-- +migrate Down
+
+drop table if exists group_channel_weight;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+create table instance_registration_rule (
+	application_id uuid not null references application (id) on delete cascade,
+	position int not null,
+	action varchar(10) not null check (action in ('allow', 'deny')),
+	cidr cidr not null,
+	primary key (application_id, position)
+);
+
+-- +migrate Down
+
+drop table if exists instance_registration_rule;
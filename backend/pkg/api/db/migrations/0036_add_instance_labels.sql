@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table instance add column labels jsonb not null default '{}';
+create index instance_labels_idx on instance using gin (labels jsonb_path_ops);
+
+-- +migrate Down
+
+drop index if exists instance_labels_idx;
+alter table instance drop column if exists labels;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+alter table activity alter column version drop not null;
+alter table activity add column actor varchar(255);
+alter table activity add column entity_type varchar(50);
+alter table activity add column entity_id varchar(255);
+alter table activity add column diff jsonb;
+
+create index on activity (entity_type, entity_id);
+create index on activity (actor);
+
+-- +migrate Down
+
+delete from activity where entity_type is not null;
+alter table activity drop column diff;
+alter table activity drop column entity_id;
+alter table activity drop column entity_type;
+alter table activity drop column actor;
+alter table activity alter column version set not null;
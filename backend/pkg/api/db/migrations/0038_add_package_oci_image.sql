@@ -0,0 +1,11 @@
+-- +migrate Up
+
+alter table package add column oci_registry varchar(255);
+alter table package add column oci_repository varchar(255);
+alter table package add column oci_digest varchar(150);
+
+-- +migrate Down
+
+alter table package drop column oci_digest;
+alter table package drop column oci_repository;
+alter table package drop column oci_registry;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_force_update_after timestamptz;
+
+-- +migrate Down
+
+alter table groups drop column policy_force_update_after;
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+create index if not exists instance_application_application_id_group_id_version_idx on instance_application (application_id, group_id, version, instance_id);
+create index if not exists instance_ip_id_idx on instance (ip, id);
+
+-- +migrate Down
+
+drop index if exists instance_ip_id_idx;
+drop index if exists instance_application_application_id_group_id_version_idx;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table channel add column version_constraint varchar(255);
+
+-- +migrate Down
+
+alter table channel drop column version_constraint;
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+create table if not exists instance_update_duration (
+	id serial primary key,
+	instance_id varchar(50) not null references instance (id) on delete cascade,
+	application_id uuid not null references application (id) on delete cascade,
+	group_id uuid references groups (id) on delete cascade,
+	version varchar(255),
+	started_ts timestamptz not null,
+	completed_ts timestamptz not null
+);
+
+create index if not exists instance_update_duration_group_id_completed_ts_idx on instance_update_duration (group_id, completed_ts);
+
+-- +migrate Down
+
+drop table if exists instance_update_duration;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+create table if not exists instance_event_fingerprint (
+	instance_id varchar(50) not null references instance (id) on delete cascade,
+	application_id uuid not null references application (id) on delete cascade,
+	fingerprint varchar(64) not null,
+	created_ts timestamptz not null,
+	primary key (instance_id, application_id)
+);
+
+-- +migrate Down
+
+drop table if exists instance_event_fingerprint;
@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_max_concurrent_updates integer not null default 0 check (policy_max_concurrent_updates >= 0);
+
+-- +migrate Down
+
+alter table groups drop column policy_max_concurrent_updates;
@@ -0,0 +1,8 @@
+-- +migrate Up
+
+alter table instance_application add column quarantined boolean not null default false;
+create index on instance_application (application_id) where quarantined;
+
+-- +migrate Down
+
+alter table instance_application drop column quarantined;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+alter table package add column severity varchar(20) not null default 'routine'
+	check (severity in ('routine', 'important', 'critical'));
+alter table groups add column policy_windows_bypass_severity varchar(20)
+	check (policy_windows_bypass_severity in ('routine', 'important', 'critical'));
+
+-- +migrate Down
+
+alter table package drop column severity;
+alter table groups drop column policy_windows_bypass_severity;
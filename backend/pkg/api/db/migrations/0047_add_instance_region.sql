@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table instance add column region varchar(100);
+
+-- +migrate Down
+
+alter table instance drop column region;
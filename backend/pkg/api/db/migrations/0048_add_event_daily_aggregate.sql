@@ -0,0 +1,15 @@
+-- +migrate Up
+
+create table event_daily_aggregate (
+	group_id uuid not null references groups (id) on delete cascade,
+	application_id uuid not null references application (id) on delete cascade,
+	day date not null,
+	type integer not null,
+	result integer not null,
+	count integer not null,
+	primary key (group_id, day, type, result)
+);
+
+-- +migrate Down
+
+drop table if exists event_daily_aggregate;
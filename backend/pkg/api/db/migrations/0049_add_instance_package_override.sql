@@ -0,0 +1,13 @@
+-- +migrate Up
+
+create table instance_package_override (
+	instance_id varchar(50) not null references instance (id) on delete cascade,
+	application_id uuid not null references application (id) on delete cascade,
+	package_id uuid not null references package (id) on delete cascade,
+	created_ts timestamptz default current_timestamp not null,
+	primary key (instance_id, application_id)
+);
+
+-- +migrate Down
+
+drop table if exists instance_package_override;
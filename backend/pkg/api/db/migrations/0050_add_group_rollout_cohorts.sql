@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table groups add column policy_rollout_cohorts integer not null default 0 check (policy_rollout_cohorts >= 0);
+alter table groups add column policy_active_cohort integer not null default 0 check (policy_active_cohort >= 0);
+
+-- +migrate Down
+
+alter table groups drop column policy_active_cohort;
+alter table groups drop column policy_rollout_cohorts;
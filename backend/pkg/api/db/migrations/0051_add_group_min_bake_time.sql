@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table groups add column policy_min_bake_time varchar(20) check (policy_min_bake_time <> '');
+
+-- +migrate Down
+
+alter table groups drop column policy_min_bake_time;
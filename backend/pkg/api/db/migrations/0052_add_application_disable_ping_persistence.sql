@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table application add column disable_ping_persistence boolean not null default false;
+
+-- +migrate Down
+
+alter table application drop column disable_ping_persistence;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+alter table instance add column first_seen timestamptz default current_timestamp not null;
+update instance set first_seen = created_ts;
+
+-- The time the existing instances got their current version is unknown, so
+-- their version age starts counting from now.
+alter table instance_application add column version_changed_at timestamptz default current_timestamp not null;
+
+-- +migrate Down
+
+alter table instance drop column first_seen;
+alter table instance_application drop column version_changed_at;
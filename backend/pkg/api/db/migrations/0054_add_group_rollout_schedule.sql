@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table groups add column rollout_starts_at timestamptz;
+alter table groups add column rollout_ends_at timestamptz;
+
+-- +migrate Down
+
+alter table groups drop column rollout_starts_at;
+alter table groups drop column rollout_ends_at;
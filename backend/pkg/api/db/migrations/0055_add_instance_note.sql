@@ -0,0 +1,7 @@
+-- +migrate Up
+
+alter table instance add column note text;
+
+-- +migrate Down
+
+alter table instance drop column note;
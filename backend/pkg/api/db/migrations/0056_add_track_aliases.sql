@@ -0,0 +1,13 @@
+-- +migrate Up
+
+create table track_alias (
+	application_id uuid not null references application (id) on delete cascade,
+	alias varchar(256) not null check (alias <> ''),
+	track varchar(256) not null check (track <> ''),
+	created_ts timestamptz default current_timestamp not null,
+	primary key (application_id, alias)
+);
+
+-- +migrate Down
+
+drop table if exists track_alias;
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+alter table groups add column policy_observe_only boolean default false not null;
+alter table instance_application add column observed_update_version varchar(255);
+alter table instance_application add column observed_update_ts timestamptz;
+
+-- +migrate Down
+
+alter table groups drop column policy_observe_only;
+alter table instance_application drop column observed_update_version;
+alter table instance_application drop column observed_update_ts;
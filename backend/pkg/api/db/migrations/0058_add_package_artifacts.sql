@@ -0,0 +1,17 @@
+-- +migrate Up
+
+create table package_artifact (
+	id uuid primary key default uuid_generate_v4(),
+	package_id uuid not null references package (id) on delete cascade,
+	name varchar(256) not null check (name <> ''),
+	size varchar(20),
+	hash varchar(64),
+	sha256 varchar(64) default '',
+	required boolean default true not null,
+	created_ts timestamptz default current_timestamp not null,
+	unique (package_id, name)
+);
+
+-- +migrate Down
+
+drop table if exists package_artifact;
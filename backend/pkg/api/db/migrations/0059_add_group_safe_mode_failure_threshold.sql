@@ -0,0 +1,9 @@
+-- +migrate Up
+
+alter table groups add column policy_safe_mode_failure_threshold integer default 0 not null check (policy_safe_mode_failure_threshold >= 0);
+alter table groups add column policy_safe_mode_failure_window varchar(20) check (policy_safe_mode_failure_window <> '');
+
+-- +migrate Down
+
+alter table groups drop column policy_safe_mode_failure_threshold;
+alter table groups drop column policy_safe_mode_failure_window;
@@ -0,0 +1,8 @@
+-- +migrate Up
+
+alter table groups add column policy_rollout_order varchar(20) not null default 'random'
+	check (policy_rollout_order in ('random', 'oldest_first', 'newest_first'));
+
+-- +migrate Down
+
+alter table groups drop column policy_rollout_order;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+alter table channel_package_history add column previous_package_id uuid references package (id) on delete set null;
+alter table channel_package_history add column actor varchar(255);
+
+update channel_package_history h set previous_package_id = p.previous_package_id
+from (
+	select id, lag(package_id) over (partition by channel_id order by created_ts, id) as previous_package_id
+	from channel_package_history
+) p
+where p.id = h.id;
+
+-- +migrate Down
+
+alter table channel_package_history drop column previous_package_id;
+alter table channel_package_history drop column actor;
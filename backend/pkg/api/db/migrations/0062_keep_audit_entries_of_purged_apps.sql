@@ -0,0 +1,21 @@
+-- +migrate Up
+
+alter table activity add column team_id uuid references team (id) on delete cascade;
+
+update activity a set team_id = app.team_id
+from application app
+where app.id = a.application_id and a.entity_type is not null;
+
+create index on activity (team_id);
+
+alter table activity alter column application_id drop not null;
+alter table activity drop constraint activity_application_id_fkey;
+alter table activity add constraint activity_application_id_fkey foreign key (application_id) references application (id) on delete set null;
+
+-- +migrate Down
+
+delete from activity where application_id is null;
+alter table activity drop constraint activity_application_id_fkey;
+alter table activity add constraint activity_application_id_fkey foreign key (application_id) references application (id) on delete cascade;
+alter table activity alter column application_id set not null;
+alter table activity drop column team_id;
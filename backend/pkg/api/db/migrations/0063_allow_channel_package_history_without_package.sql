@@ -0,0 +1,8 @@
+-- +migrate Up
+
+alter table channel_package_history alter column package_id drop not null;
+
+-- +migrate Down
+
+delete from channel_package_history where package_id is null;
+alter table channel_package_history alter column package_id set not null;
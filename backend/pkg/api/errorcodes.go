@@ -0,0 +1,105 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Flags update_engine combines with the error codes it reports, and the base
+// added to the HTTP status codes of the failed Omaha requests.
+const (
+	errorCodeDevModeFlag       = 1 << 31
+	errorCodeResumedFlag       = 1 << 30
+	errorCodeTestImageFlag     = 1 << 29
+	errorCodeTestOmahaURLFlag  = 1 << 28
+	errorCodeHTTPResponseBase  = 2000
+	errorCodeHTTPResponseLimit = 3000
+)
+
+// updateEngineErrorCodes maps the error codes reported by the Flatcar
+// update_engine to a short description of the error.
+var updateEngineErrorCodes = map[int]string{
+	0:  "success",
+	1:  "generic error",
+	2:  "omaha request error",
+	3:  "omaha response handler error",
+	4:  "filesystem copier error",
+	5:  "postinstall runner error",
+	7:  "install device open error",
+	8:  "kernel device open error",
+	9:  "download transfer error",
+	10: "payload hash mismatch",
+	11: "payload size mismatch",
+	12: "payload verification error",
+	13: "new partition info error",
+	14: "download write error",
+	15: "new rootfs verification error",
+	16: "new kernel verification error",
+	17: "signed delta payload expected",
+	18: "payload public key verification error",
+	19: "postinstall booted from firmware B",
+	20: "download state initialization error",
+	21: "invalid metadata magic string",
+	22: "signature missing in manifest",
+	23: "manifest parse error",
+	24: "metadata signature error",
+	25: "metadata signature verification error",
+	26: "metadata signature mismatch",
+	27: "operation hash verification error",
+	28: "operation execution error",
+	29: "operation hash mismatch",
+	30: "omaha empty response",
+	31: "omaha response XML parse error",
+	32: "invalid metadata size",
+	33: "invalid metadata signature",
+	34: "omaha response invalid",
+	35: "update ignored per policy",
+	36: "update deferred per policy",
+	37: "omaha HTTP response error",
+	38: "operation hash missing",
+	39: "metadata signature missing",
+	40: "update deferred for backoff",
+	41: "postinstall powerwash error",
+}
+
+// DescribeErrorCode returns a human readable description of an error code
+// reported by the Flatcar update_engine in its events, like "omaha HTTP
+// response 503 (test omaha url)" for 268437959. Unknown error codes are
+// returned as they are.
+func DescribeErrorCode(errorCode string) string {
+	code, err := strconv.ParseUint(errorCode, 10, 32)
+	if err != nil {
+		return errorCode
+	}
+
+	var flags []string
+	for _, flag := range []struct {
+		mask uint64
+		name string
+	}{
+		{errorCodeDevModeFlag, "dev mode"},
+		{errorCodeResumedFlag, "resumed"},
+		{errorCodeTestImageFlag, "test image"},
+		{errorCodeTestOmahaURLFlag, "test omaha url"},
+	} {
+		if code&flag.mask != 0 {
+			flags = append(flags, flag.name)
+			code &^= flag.mask
+		}
+	}
+
+	var description string
+	if desc, ok := updateEngineErrorCodes[int(code)]; ok {
+		description = desc
+	} else if code >= errorCodeHTTPResponseBase && code < errorCodeHTTPResponseLimit {
+		description = fmt.Sprintf("omaha HTTP response %d", code-errorCodeHTTPResponseBase)
+	} else {
+		return errorCode
+	}
+
+	if len(flags) > 0 {
+		description = fmt.Sprintf("%s (%s)", description, strings.Join(flags, ", "))
+	}
+	return description
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeErrorCode(t *testing.T) {
+	assert.Equal(t, "payload hash mismatch", DescribeErrorCode("10"))
+	assert.Equal(t, "omaha HTTP response 503 (test omaha url)", DescribeErrorCode("268437959"))
+	assert.Equal(t, "download transfer error (resumed)", DescribeErrorCode("1073741833"))
+	assert.Equal(t, "omaha HTTP response 404", DescribeErrorCode("2404"))
+	assert.Equal(t, "1234", DescribeErrorCode("1234"))
+	assert.Equal(t, "not-a-code", DescribeErrorCode("not-a-code"))
+	assert.Equal(t, "", DescribeErrorCode(""))
+}
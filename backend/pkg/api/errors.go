@@ -0,0 +1,61 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// uniqueViolation is the SQLSTATE postgres reports when an insert or update
+// breaks a unique constraint.
+const uniqueViolation = "23505"
+
+var (
+	// ErrNotFound error indicates that the entity requested doesn't exist, or
+	// it isn't visible to the api instance's team. It's sql.ErrNoRows, which
+	// callers compared against before the error kinds were introduced.
+	ErrNotFound = sql.ErrNoRows
+
+	// ErrValidation error indicates that the entity provided, or one of the
+	// parameters of the request, is not valid.
+	ErrValidation = errors.New("nebraska: validation failed")
+
+	// ErrConflict error indicates that the request conflicts with the current
+	// state of the entity, like a duplicate name or a stale revision.
+	ErrConflict = errors.New("nebraska: conflict")
+)
+
+// kindError is an error of one of the ErrNotFound, ErrValidation or
+// ErrConflict kinds. It keeps its own identity, so it can still be compared
+// directly, while errors.Is reports its kind.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+func newNotFoundError(msg string) error {
+	return &kindError{msg: msg, kind: ErrNotFound}
+}
+
+func newValidationError(msg string) error {
+	return &kindError{msg: msg, kind: ErrValidation}
+}
+
+func newConflictError(msg string) error {
+	return &kindError{msg: msg, kind: ErrConflict}
+}
+
+// wrapUniqueViolation returns an ErrConflict error described by the context
+// provided when err is caused by a unique constraint of the database,
+// otherwise it returns err as it is.
+func wrapUniqueViolation(err error, format string, args ...interface{}) error {
+	var sqlErr interface{ SQLState() string }
+	if errors.As(err, &sqlErr) && sqlErr.SQLState() == uniqueViolation {
+		return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrConflict)
+	}
+	return err
+}
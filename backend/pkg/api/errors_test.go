@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestErrorKinds(t *testing.T) {
+	assert.ErrorIs(t, ErrInvalidSemver, ErrValidation)
+	assert.ErrorIs(t, ErrInvalidPolicyInterval, ErrValidation)
+	assert.ErrorIs(t, ErrStaleRevision, ErrConflict)
+	assert.ErrorIs(t, ErrDuplicatePackageVersion, ErrConflict)
+	assert.ErrorIs(t, ErrNoRowsAffected, ErrNotFound)
+	assert.NotErrorIs(t, ErrInvalidSemver, ErrConflict)
+	assert.NotErrorIs(t, ErrUpdatesDisabled, ErrValidation)
+
+	// The specific errors keep their identity.
+	assert.ErrorIs(t, fmt.Errorf("adding package: %w", ErrInvalidSemver), ErrInvalidSemver)
+	assert.False(t, errors.Is(ErrInvalidSemver, ErrInvalidArch))
+
+	err := wrapUniqueViolation(fmt.Errorf("inserting: %w", sqlStateError(uniqueViolation)), "group %q", "default")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, `group "default": nebraska: conflict`, err.Error())
+	other := sqlStateError("23503")
+	assert.Equal(t, other, wrapUniqueViolation(other, "group %q", "default"))
+}
+
+func TestErrorKinds_CommonFailures(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, err := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	require.NoError(t, err)
+
+	_, err = a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	assert.ErrorIs(t, err, ErrConflict)
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+	tPkgOtherApp, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp2.ID})
+
+	_, err = a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+	tGroup2, err := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+	_, err = a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	assert.ErrorIs(t, err, ErrConflict)
+	tGroup2.Name = "group"
+	assert.ErrorIs(t, a.UpdateGroup(tGroup2), ErrConflict)
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyMinBakeTime: null.StringFrom("not an interval")})
+	assert.ErrorIs(t, err, ErrValidation)
+
+	_, err = a.AddChannel(&Channel{Name: "channel", Color: "blue", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	tChannel2, err := a.AddChannel(&Channel{Name: "channel2", Color: "red", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	tChannel2.Name = "channel"
+	assert.ErrorIs(t, a.UpdateChannel(tChannel2), ErrConflict)
+	tChannel2.Name = "channel2"
+	tChannel2.PackageID = null.StringFrom(tPkgOtherApp.ID)
+	assert.ErrorIs(t, a.UpdateChannel(tChannel2), ErrValidation)
+	tChannel2.PackageID = null.StringFrom(uuid.New().String())
+	assert.ErrorIs(t, a.UpdateChannel(tChannel2), ErrNotFound)
+
+	_, err = a.GetApp(uuid.New().String())
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = a.GetGroup(uuid.New().String())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, a.UpdateApp(&Application{ID: uuid.New().String(), Name: "missing"}), ErrNotFound)
+	assert.ErrorIs(t, a.UpdateChannel(&Channel{ID: uuid.New().String(), Name: "missing"}), ErrNotFound)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// eventDedupWindow is the time during which an event identical to the
+	// last one posted by an instance is considered a resend of it.
+	eventDedupWindow = 5 * time.Minute
+)
+
+var (
+	// ErrDuplicateEvent indicates that the event posted is a resend of the
+	// last event posted by the instance, so it was not registered again.
+	ErrDuplicateEvent = errors.New("nebraska: duplicate event")
+)
+
+// eventFingerprint returns the fingerprint identifying the event provided
+// among the events posted by the instance during its current update, which
+// is identified by the time it was granted.
+func eventFingerprint(instance *Instance, etype, eresult int, previousVersion, errorCode, sequence string) string {
+	updateGrantedTs := instance.Application.LastUpdateGrantedTs.Time.UTC().Format(time.RFC3339Nano)
+	fields := []string{updateGrantedTs, strconv.Itoa(etype), strconv.Itoa(eresult), previousVersion, errorCode, sequence}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// markEventSeen records the fingerprint provided as the one of the last event
+// posted by the instance for the given application, in the transaction
+// provided so it's only kept if the event is registered too. It returns false
+// if the instance already posted an event with the same fingerprint within
+// the dedup window, in which case nothing is recorded.
+func (api *API) markEventSeen(tx *sqlx.Tx, instanceID, appID, fingerprint string, now time.Time) (bool, error) {
+	query := `
+	INSERT INTO instance_event_fingerprint (instance_id, application_id, fingerprint, created_ts)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (instance_id, application_id) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, created_ts = EXCLUDED.created_ts
+	WHERE instance_event_fingerprint.fingerprint <> EXCLUDED.fingerprint OR instance_event_fingerprint.created_ts <= $5
+	`
+	result, err := tx.Exec(query, instanceID, appID, fingerprint, now, now.Add(-eventDedupWindow))
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
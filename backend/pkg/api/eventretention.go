@@ -0,0 +1,73 @@
+package api
+
+import (
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// DailyEventAggregate represents the number of events of a given type and
+// result reported in a day by the instances of a group, kept after the raw
+// events are deleted.
+type DailyEventAggregate struct {
+	GroupID       string    `db:"group_id" json:"group_id"`
+	ApplicationID string    `db:"application_id" json:"application_id"`
+	Day           time.Time `db:"day" json:"day"`
+	Type          int       `db:"type" json:"type"`
+	Result        int       `db:"result" json:"result"`
+	Count         int       `db:"count" json:"count"`
+}
+
+// RollupEvents deletes the events older than the age provided, rolling them
+// up first into per group daily aggregates, by type and result, so that their
+// historical stats survive. Days are in UTC. Events of instances not
+// belonging to any group are deleted without being aggregated. It returns the
+// number of events deleted.
+func (api *API) RollupEvents(olderThan time.Duration) (int, error) {
+	query := `
+	WITH rolled AS (
+		DELETE FROM event e
+		WHERE e.created_ts < $1
+		RETURNING e.instance_id, e.application_id, e.event_type_id, e.created_ts
+	), aggregated AS (
+		INSERT INTO event_daily_aggregate (group_id, application_id, day, type, result, count)
+		SELECT ia.group_id, r.application_id, (r.created_ts AT TIME ZONE 'utc')::date, et.type, et.result, count(*)
+		FROM rolled r
+		JOIN event_type et ON et.id = r.event_type_id
+		JOIN instance_application ia ON ia.instance_id = r.instance_id AND ia.application_id = r.application_id
+		WHERE ia.group_id IS NOT NULL
+		GROUP BY 1, 2, 3, 4, 5
+		ON CONFLICT (group_id, day, type, result) DO UPDATE SET count = event_daily_aggregate.count + EXCLUDED.count
+	)
+	SELECT count(*) FROM rolled`
+
+	var deleted int
+	if err := api.db.QueryRow(query, api.nowUTC().Add(-olderThan)).Scan(&deleted); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// GetDailyEventAggregates returns the daily aggregates of the events rolled up
+// for the group provided, for the days between from and to, both included.
+func (api *API) GetDailyEventAggregates(groupID string, from, to time.Time) ([]*DailyEventAggregate, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("event_daily_aggregate").
+		Where(
+			goqu.C("group_id").Eq(groupID),
+			goqu.C("day").Gte(from.UTC().Format("2006-01-02")),
+			goqu.C("day").Lte(to.UTC().Format("2006-01-02")),
+		).
+		Order(goqu.C("day").Asc(), goqu.C("type").Asc(), goqu.C("result").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	aggregates := []*DailyEventAggregate{}
+	if err := api.readDB().Select(&aggregates, query); err != nil {
+		return nil, err
+	}
+	return aggregates, nil
+}
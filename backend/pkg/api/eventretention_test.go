@@ -0,0 +1,73 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRollupEvents(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	var instanceIDs []string
+	for i := 0; i < 2; i++ {
+		tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+		_, err := a.GetUpdatePackage(tInstance.ID, "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		require.NoError(t, a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+		require.NoError(t, a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "", ""))
+		instanceIDs = append(instanceIDs, tInstance.ID)
+	}
+	// The download finished events of the second instance stay recent.
+	require.NoError(t, a.RegisterEvent(instanceIDs[1], tApp.ID, tGroup.ID, EventUpdateInstalled, ResultSuccess, "", ""))
+
+	day := time.Date(2021, time.March, 10, 0, 0, 0, 0, time.UTC)
+	_, err := a.db.Exec(`UPDATE event SET created_ts = $1 WHERE event_type_id IN (SELECT id FROM event_type WHERE type = $2)`, day.Add(10*time.Hour), EventUpdateDownloadStarted)
+	require.NoError(t, err)
+	_, err = a.db.Exec(`UPDATE event SET created_ts = $1 WHERE event_type_id IN (SELECT id FROM event_type WHERE type = $2) AND instance_id = $3`, day.Add(34*time.Hour), EventUpdateDownloadFinished, instanceIDs[0])
+	require.NoError(t, err)
+
+	deleted, err := a.RollupEvents(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	aggregates, err := a.GetDailyEventAggregates(tGroup.ID, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, aggregates, 2)
+	assert.Equal(t, day, aggregates[0].Day.UTC())
+	assert.Equal(t, EventUpdateDownloadStarted, aggregates[0].Type)
+	assert.Equal(t, ResultSuccess, aggregates[0].Result)
+	assert.Equal(t, 2, aggregates[0].Count)
+	assert.Equal(t, day.AddDate(0, 0, 1), aggregates[1].Day.UTC())
+	assert.Equal(t, EventUpdateDownloadFinished, aggregates[1].Type)
+	assert.Equal(t, 1, aggregates[1].Count)
+
+	aggregates, err = a.GetDailyEventAggregates(tGroup.ID, day, day)
+	require.NoError(t, err)
+	assert.Len(t, aggregates, 1)
+
+	// The raw events rolled up are gone, the recent ones are kept.
+	var remaining int
+	require.NoError(t, a.db.QueryRow(`SELECT count(*) FROM event WHERE application_id = $1`, tApp.ID).Scan(&remaining))
+	assert.Equal(t, 2, remaining)
+
+	// Rolling up again doesn't count the events twice.
+	deleted, err = a.RollupEvents(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	aggregates, err = a.GetDailyEventAggregates(tGroup.ID, day, day)
+	require.NoError(t, err)
+	require.Len(t, aggregates, 1)
+	assert.Equal(t, 2, aggregates[0].Count)
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
@@ -67,6 +69,10 @@ var (
 	// it was rejected.
 	ErrNoUpdateInProgress = errors.New("nebraska: no update in progress")
 
+	// ErrEventTypeNotAllowed indicates that the event type posted is not in
+	// the set of event types allowed for the application.
+	ErrEventTypeNotAllowed = errors.New("nebraska: event type not allowed")
+
 	// ErrFlatcarEventIgnored indicates that a Flatcar updater event was ignored.
 	// This is a temporary solution to handle Flatcar specific behaviour.
 	ErrFlatcarEventIgnored = errors.New("nebraska: flatcar event ignored")
@@ -74,22 +80,135 @@ var (
 
 // Event represents an event posted by an instance to Nebraska.
 type Event struct {
-	ID              int         `db:"id" json:"id"`
-	CreatedTs       time.Time   `db:"created_ts" json:"created_ts"`
-	PreviousVersion null.String `db:"previous_version" json:"previous_version"`
-	ErrorCode       null.String `db:"error_code" json:"error_code"`
-	InstanceID      string      `db:"instance_id" json:"instance_id"`
-	ApplicationID   string      `db:"application_id" json:"application_id"`
-	EventTypeID     string      `db:"event_type_id" json:"event_type_id"`
+	ID                int         `db:"id" json:"id"`
+	CreatedTs         time.Time   `db:"created_ts" json:"created_ts"`
+	PreviousVersion   null.String `db:"previous_version" json:"previous_version"`
+	ErrorCode         null.String `db:"error_code" json:"error_code"`
+	InstanceID        string      `db:"instance_id" json:"instance_id"`
+	ApplicationID     string      `db:"application_id" json:"application_id"`
+	EventTypeID       string      `db:"event_type_id" json:"event_type_id"`
+	Type              int         `db:"type" json:"type"`
+	Result            int         `db:"result" json:"result"`
+	Description       string      `db:"description" json:"description"`
+	TypeName          string      `db:"-" json:"type_name"`
+	ResultName        string      `db:"-" json:"result_name"`
+	Label             string      `db:"-" json:"label"`
+	ResultDescription string      `db:"-" json:"result_description,omitempty"`
+}
+
+// GetInstanceTimeline returns all the events the instance provided posted for
+// the given application, oldest first, with their type and result decoded.
+func (api *API) GetInstanceTimeline(instanceID, appID string) ([]*Event, error) {
+	if _, err := api.GetInstance(instanceID, appID); err != nil {
+		return nil, err
+	}
+
+	query, _, err := goqu.From(goqu.T("event").As("e")).
+		Join(goqu.T("event_type").As("et"), goqu.On(goqu.I("et.id").Eq(goqu.I("e.event_type_id")))).
+		Select(goqu.I("e.id"), goqu.I("e.created_ts"), goqu.I("e.previous_version"), goqu.I("e.error_code"),
+			goqu.I("e.instance_id"), goqu.I("e.application_id"), goqu.I("e.event_type_id"),
+			goqu.I("et.type"), goqu.I("et.result"), goqu.I("et.description")).
+		Where(goqu.I("e.instance_id").Eq(instanceID), goqu.I("e.application_id").Eq(appID)).
+		Order(goqu.I("e.created_ts").Asc(), goqu.I("e.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*Event{}
+	for rows.Next() {
+		event := &Event{}
+		if err := rows.StructScan(event); err != nil {
+			return nil, err
+		}
+		event.TypeName = eventTypeName(event.Type)
+		event.ResultName = eventResultName(event.Result)
+		event.Label = eventLabel(event.Type, event.Result)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// eventTypeName returns the name of the event type provided.
+func eventTypeName(etype int) string {
+	switch etype {
+	case EventUpdateComplete:
+		return "update_complete"
+	case EventUpdateDownloadStarted:
+		return "update_download_started"
+	case EventUpdateDownloadFinished:
+		return "update_download_finished"
+	case EventUpdateInstalled:
+		return "update_installed"
+	}
+	return "unknown"
+}
+
+// eventResultName returns the name of the event result provided.
+func eventResultName(result int) string {
+	switch result {
+	case ResultFailed:
+		return "failed"
+	case ResultSuccess:
+		return "success"
+	case ResultSuccessReboot:
+		return "success_reboot"
+	}
+	return "unknown"
+}
+
+// eventLabel returns a human readable description of what an event of the
+// given type and result means for the instance that posted it.
+func eventLabel(etype, result int) string {
+	if result == ResultFailed {
+		return "update failed"
+	}
+	switch etype {
+	case EventUpdateComplete:
+		if result == ResultSuccessReboot {
+			return "update completed"
+		}
+		return "update applied"
+	case EventUpdateDownloadStarted:
+		return "download started"
+	case EventUpdateDownloadFinished:
+		return "download finished"
+	case EventUpdateInstalled:
+		return "update installed"
+	}
+	return "unknown event"
 }
 
 // RegisterEvent registers an event posted by an instance in Nebraska. The
 // event will be bound to an application/group combination.
 func (api *API) RegisterEvent(instanceID, appID, groupID string, etype, eresult int, previousVersion, errorCode string) error {
+	return api.RegisterEventWithSequence(instanceID, appID, groupID, etype, eresult, previousVersion, errorCode, "")
+}
+
+// RegisterEventWithSequence works like RegisterEvent, but takes the sequence
+// number or nonce the instance attached to the event, if any. An event
+// identical to the last one the instance posted, sequence included, is
+// considered a resend if it's posted shortly after it, and is not registered
+// again, returning ErrDuplicateEvent.
+func (api *API) RegisterEventWithSequence(instanceID, appID, groupID string, etype, eresult int, previousVersion, errorCode, sequence string) error {
 	var err error
 	if appID, groupID, err = api.validateApplicationAndGroup(appID, groupID); err != nil {
 		return err
 	}
+	allowed, err := api.isEventTypeAllowed(appID, etype)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrEventTypeNotAllowed
+	}
 	instance, err := api.GetInstance(instanceID, appID)
 	if err != nil {
 		logger.Info().Err(err).Msg("RegisterEvent - could not get instance, maybe it is a first contact (propagates as ErrInvalidInstance)")
@@ -134,19 +253,58 @@ func (api *API) RegisterEvent(instanceID, appID, groupID string, etype, eresult
 		return ErrInvalidEventTypeOrResult
 	}
 
+	// The fingerprint is only kept along with the event, so the instance can
+	// post the event again if registering it fails.
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return ErrEventRegistrationFailed
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("RegisterEvent - could not roll back")
+		}
+	}()
+
+	now := api.nowUTC()
+	firstSeen, err := api.markEventSeen(tx, instanceID, appID, eventFingerprint(instance, etype, eresult, previousVersion, errorCode, sequence), now)
+	if err != nil {
+		return ErrEventRegistrationFailed
+	}
+	if !firstSeen {
+		return ErrDuplicateEvent
+	}
+
 	insertQuery, _, err := goqu.Insert("event").
-		Cols("event_type_id", "instance_id", "application_id", "previous_version", "error_code").
-		Vals(goqu.Vals{eventTypeID, instanceID, appID, previousVersion, errorCode}).
+		Cols("event_type_id", "instance_id", "application_id", "previous_version", "error_code", "created_ts").
+		Vals(goqu.Vals{eventTypeID, instanceID, appID, previousVersion, errorCode, now}).
 		ToSQL()
 	if err != nil {
 		return err
 	}
-	_, err = api.db.Exec(insertQuery)
-
-	if err != nil {
+	if _, err := tx.Exec(insertQuery); err != nil {
+		return ErrEventRegistrationFailed
+	}
+	if err := tx.Commit(); err != nil {
 		return ErrEventRegistrationFailed
 	}
 
+	api.eventBroker.publish(&StreamedEvent{
+		ApplicationID:   appID,
+		GroupID:         groupID,
+		InstanceID:      instanceID,
+		Type:            etype,
+		Result:          eresult,
+		PreviousVersion: previousVersion,
+		ErrorCode:       errorCode,
+		CreatedTs:       now,
+	})
+
+	if etype == EventUpdateComplete && (eresult == ResultSuccess || eresult == ResultSuccessReboot) {
+		if err := api.recordUpdateDuration(instance, groupID, now); err != nil {
+			logger.Error().Err(err).Msg("RegisterEvent - could not record update duration")
+		}
+	}
+
 	lastUpdateVersion := instance.Application.LastUpdateVersion.String
 	if err := api.triggerEventConsequences(instanceID, appID, groupID, lastUpdateVersion, etype, eresult); err != nil {
 		logger.Error().Err(err).Msgf("RegisterEvent - could not trigger event consequences")
@@ -164,12 +322,14 @@ func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateV
 		return err
 	}
 
-	// TODO: should we also consider ResultSuccess in the next check? Flatcar ~ generic conflicts?
-	if etype == EventUpdateComplete && result == ResultSuccessReboot {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusComplete); err != nil {
+	status := api.instanceStatusFromEvent(etype, result)
+	if status != 0 {
+		if err := api.updateInstanceStatus(instanceID, appID, status); err != nil {
 			logger.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
 		}
+	}
 
+	if status == InstanceStatusComplete {
 		updatesStats, err := api.getGroupUpdatesStats(group)
 		if err != nil {
 			return err
@@ -181,31 +341,16 @@ func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateV
 			if err := api.newGroupActivityEntry(activityRolloutFinished, activitySuccess, lastUpdateVersion, appID, groupID); err != nil {
 				logger.Error().Err(err).Msg("triggerEventConsequences - could not add group activity")
 			}
+			api.notifyRolloutTransition(group, RolloutStateCompleted, lastUpdateVersion)
+			if group.Channel != nil && group.Channel.PackageID.Valid {
+				if err := api.setGroupLastKnownGoodPackage(groupID, group.Channel.PackageID.String); err != nil {
+					logger.Error().Err(err).Msg("triggerEventConsequences - could not set last known good package")
+				}
+			}
 		}
 	}
 
-	if etype == EventUpdateDownloadStarted && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusDownloading); err != nil {
-			logger.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
-	}
-
-	if etype == EventUpdateDownloadFinished && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusDownloaded); err != nil {
-			logger.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
-	}
-
-	if etype == EventUpdateInstalled && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusInstalled); err != nil {
-			logger.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
-	}
-
-	if result == ResultFailed {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusError); err != nil {
-			logger.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
+	if status == InstanceStatusError {
 		if err := api.newInstanceActivityEntry(activityInstanceUpdateFailed, activityError, lastUpdateVersion, appID, groupID, instanceID); err != nil {
 			logger.Error().Err(err).Msg("triggerEventConsequences - could not add instance activity")
 		}
@@ -215,7 +360,11 @@ func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateV
 			if err != nil {
 				return err
 			}
-			if updatesStats.UpdatesToCurrentVersionAttempted == 1 {
+			halt, err := api.safeModeFailureThresholdReached(group, lastUpdateVersion, updatesStats)
+			if err != nil {
+				return err
+			}
+			if halt {
 				if err := api.disableUpdates(groupID); err != nil {
 					logger.Error().Err(err).Msg("triggerEventConsequences - could not disable updates")
 				}
@@ -225,6 +374,7 @@ func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateV
 				if err := api.newGroupActivityEntry(activityRolloutFailed, activityError, lastUpdateVersion, appID, groupID); err != nil {
 					logger.Error().Err(err).Msg("triggerEventConsequences - could not add group activity")
 				}
+				api.notifyRolloutTransition(group, RolloutStatePausedBySafeMode, lastUpdateVersion)
 			}
 		}
 	}
@@ -232,7 +382,124 @@ func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateV
 	return nil
 }
 
+// instanceStatusFromEvent returns the instance status that an event of the
+// given type and result leads to according to the instance status rules, or
+// 0 if the event doesn't change the instance status. Events leading to the
+// updating status set the status telling how far the update went.
+func (api *API) instanceStatusFromEvent(etype, result int) int {
+	status, ok := api.instanceStatusFromRules(etype, result)
+	if !ok {
+		return 0
+	}
+	switch status {
+	case InstanceUpdateStatusFailed:
+		return InstanceStatusError
+	case InstanceUpdateStatusComplete:
+		return InstanceStatusComplete
+	}
+	switch etype {
+	case EventUpdateDownloadStarted:
+		return InstanceStatusDownloading
+	case EventUpdateDownloadFinished:
+		return InstanceStatusDownloaded
+	case EventUpdateInstalled:
+		return InstanceStatusInstalled
+	}
+	return InstanceStatusUpdateGranted
+}
+
+// ReconcileInstanceStatuses recomputes the status of the active instances of
+// the given application from the events they posted since their last update
+// was granted, fixing the statuses that don't match. Instances on hold are
+// left untouched, as that status is set by the rollout policy and not by
+// events. It returns the number of instances whose status was fixed.
+func (api *API) ReconcileInstanceStatuses(appID string) (int, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return 0, err
+	}
+	return api.reconcileInstanceStatuses("application_id", appID)
+}
+
+// reconcileInstanceStatuses works like ReconcileInstanceStatuses for the
+// active instances whose instance_application column provided has the given
+// value.
+func (api *API) reconcileInstanceStatuses(column, value string) (int, error) {
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("event").As("e"), goqu.On(
+			goqu.I("e.instance_id").Eq(goqu.I("ia.instance_id")),
+			goqu.I("e.application_id").Eq(goqu.I("ia.application_id")),
+		)).
+		Join(goqu.T("event_type").As("et"), goqu.On(goqu.I("et.id").Eq(goqu.I("e.event_type_id")))).
+		Select("ia.instance_id", "ia.application_id", "ia.status", "et.type", "et.result").
+		Where(
+			goqu.I("ia."+column).Eq(value),
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.Or(
+				goqu.I("ia.last_update_granted_ts").IsNull(),
+				goqu.I("e.created_ts").Gte(goqu.I("ia.last_update_granted_ts")),
+			),
+		).
+		Order(goqu.I("ia.instance_id").Asc(), goqu.I("e.created_ts").Asc(), goqu.I("e.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var instanceIDs []string
+	appIDs := make(map[string]string)
+	currentStatuses := make(map[string]null.Int)
+	impliedStatuses := make(map[string]int)
+	for rows.Next() {
+		var (
+			instanceID    string
+			appID         string
+			status        null.Int
+			etype, result int
+		)
+		if err := rows.Scan(&instanceID, &appID, &status, &etype, &result); err != nil {
+			return 0, err
+		}
+		if _, ok := currentStatuses[instanceID]; !ok {
+			instanceIDs = append(instanceIDs, instanceID)
+			appIDs[instanceID] = appID
+			currentStatuses[instanceID] = status
+		}
+		if impliedStatus := api.instanceStatusFromEvent(etype, result); impliedStatus != 0 {
+			impliedStatuses[instanceID] = impliedStatus
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	fixed := 0
+	for _, instanceID := range instanceIDs {
+		impliedStatus, ok := impliedStatuses[instanceID]
+		if !ok {
+			continue
+		}
+		currentStatus := currentStatuses[instanceID]
+		if currentStatus.Valid && (currentStatus.Int64 == int64(impliedStatus) || currentStatus.Int64 == int64(InstanceStatusOnHold)) {
+			continue
+		}
+		if err := api.updateInstanceStatus(instanceID, appIDs[instanceID], impliedStatus); err != nil {
+			return fixed, err
+		}
+		fixed++
+	}
+
+	return fixed, nil
+}
+
 func (api *API) GetEvent(instanceID string, appID string, timestamp time.Time) (null.String, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return null.NewString("", true), err
+	}
 	query, _, err := goqu.From("event").
 		Select("error_code").
 		Where(goqu.C("instance_id").Eq(instanceID)).
@@ -251,3 +518,98 @@ func (api *API) GetEvent(instanceID string, appID string, timestamp time.Time) (
 	}
 	return errCode, nil
 }
+
+// GetEventErrorBreakdown returns the number of failed events posted since the
+// time provided by the instances of the given group, grouped by the error code
+// they reported. DescribeErrorCode can be used to make sense of the codes.
+func (api *API) GetEventErrorBreakdown(groupID string, since time.Time) (map[string]int, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
+	query := `
+	SELECT COALESCE(e.error_code, ''), count(*)
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	JOIN instance_application ia ON ia.instance_id = e.instance_id AND ia.application_id = e.application_id
+	WHERE ia.group_id = $1 AND et.result = $2 AND e.created_ts >= $3
+	GROUP BY 1
+	`
+	rows, err := api.readDB().Query(query, groupID, ResultFailed, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breakdown := make(map[string]int)
+	for rows.Next() {
+		var (
+			errorCode string
+			count     int
+		)
+		if err := rows.Scan(&errorCode, &count); err != nil {
+			return nil, err
+		}
+		breakdown[errorCode] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// GetFailingInstances returns the instances of the group provided whose last
+// event since the time provided, posted while updating to the version the
+// group is being updated to, reported a failure. Instances granted an update
+// again after failing aren't returned until they fail again. Most recently
+// seen instances are returned first.
+func (api *API) GetFailingInstances(groupID string, since time.Time) ([]*Instance, error) {
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := api.groupTargetVersion(group)
+	if err == ErrNoPackageFound {
+		return []*Instance{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT i.id, i.ip, i.created_ts, i.alias, i.arch, i.board, i.platform, i.region, i.labels, ia.application_id, ia.group_id, ia.version, ia.created_ts,
+		ia.status, ia.last_check_for_updates, ia.last_update_granted_ts, ia.last_update_version, ia.update_in_progress, ia.quarantined
+	FROM instance_application ia
+	JOIN instance i ON i.id = ia.instance_id
+	JOIN LATERAL (
+		SELECT et.result
+		FROM event e
+		JOIN event_type et ON et.id = e.event_type_id
+		WHERE e.instance_id = ia.instance_id AND e.application_id = ia.application_id
+			AND e.created_ts >= ia.last_update_granted_ts AND e.created_ts >= $3
+		ORDER BY e.created_ts DESC, e.id DESC
+		LIMIT 1
+	) last_event ON true
+	WHERE ia.group_id = $1 AND ia.last_update_version = $2 AND last_event.result = $4 AND %s
+	ORDER BY ia.last_check_for_updates DESC`, ignoreFakeInstanceCondition("ia.instance_id"))
+	rows, err := api.readDB().Query(query, groupID, version, since, ResultFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	instances := []*Instance{}
+	for rows.Next() {
+		var instance Instance
+		app := &instance.Application
+		err := rows.Scan(&instance.ID, &instance.IP, &instance.CreatedTs, &instance.Alias, &instance.Arch, &instance.Board, &instance.Platform, &instance.Region, &instance.Labels, &app.ApplicationID, &app.GroupID, &app.Version, &app.CreatedTs,
+			&app.Status, &app.LastCheckForUpdates, &app.LastUpdateGrantedTs, &app.LastUpdateVersion, &app.UpdateInProgress, &app.Quarantined)
+		if err != nil {
+			return nil, err
+		}
+		app.InstanceID = instance.ID
+		instances = append(instances, &instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
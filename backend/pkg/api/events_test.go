@@ -3,9 +3,11 @@ package api
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -108,3 +110,257 @@ func TestRegisterEvent_TriggerEventConsequences_FirstUpdateAttemptFailed(t *test
 	group, _ := a.GetGroup(tGroup.ID)
 	assert.Equal(t, false, group.PolicyUpdatesEnabled, "First update attempt failed.")
 }
+
+func TestRegisterEvent_TriggerEventConsequences_SafeModeFailureThreshold(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+	a, err := NewForTest(OptionInitDB, OptionDisableUpdatesOnFailedRollout, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+
+	_, err = a.AddGroup(&Group{Name: "invalid", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicySafeModeFailureThreshold: -1})
+	assert.Equal(t, ErrInvalidSafeModeFailureThreshold, err)
+	_, err = a.AddGroup(&Group{Name: "invalid", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicySafeModeFailureThreshold: 2, PolicySafeModeFailureWindow: null.StringFrom("-1 hour")})
+	assert.Equal(t, ErrInvalidPolicyInterval, err)
+
+	tGroup, err := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes",
+		PolicySafeModeFailureThreshold: 2, PolicySafeModeFailureWindow: null.StringFrom("1 hour")})
+	require.NoError(t, err)
+
+	fail := func() {
+		t.Helper()
+		instanceID := uuid.New().String()
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "", ""))
+	}
+
+	fail()
+	group, _ := a.GetGroup(tGroup.ID)
+	assert.True(t, group.PolicyUpdatesEnabled, "A single failure doesn't reach the threshold.")
+
+	// The first failure is out of the window by the time the second one is
+	// posted.
+	clock.Advance(2 * time.Hour)
+	fail()
+	group, _ = a.GetGroup(tGroup.ID)
+	assert.True(t, group.PolicyUpdatesEnabled, "Failures out of the window don't count.")
+
+	clock.Advance(10 * time.Minute)
+	fail()
+	group, _ = a.GetGroup(tGroup.ID)
+	assert.False(t, group.PolicyUpdatesEnabled, "The threshold was reached within the window.")
+}
+
+func TestReconcileInstanceStatuses(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+
+	_, err := a.GetUpdatePackage(tInstance.ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	_, err = a.GetUpdatePackage(tInstance2.ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	err = a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", "")
+	assert.NoError(t, err)
+	err = a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "", "")
+	assert.NoError(t, err)
+	err = a.RegisterEvent(tInstance2.ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", "")
+	assert.NoError(t, err)
+
+	fixed, err := a.ReconcileInstanceStatuses(tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fixed, "Statuses match the events.")
+
+	_, err = a.db.Exec("UPDATE instance_application SET status = $1 WHERE instance_id = $2", InstanceStatusInstalled, tInstance.ID)
+	assert.NoError(t, err)
+
+	fixed, err = a.ReconcileInstanceStatuses(tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fixed)
+	instance, _ := a.GetInstance(tInstance.ID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusDownloaded)), instance.Application.Status)
+	instance2, _ := a.GetInstance(tInstance2.ID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusDownloading)), instance2.Application.Status)
+}
+
+func TestGetInstanceTimeline(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	instanceID := uuid.New().String()
+
+	_, err := a.GetInstanceTimeline(instanceID, tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	events, err := a.GetInstanceTimeline(instanceID, tApp.ID)
+	assert.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	assert.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "12.0.0", ""))
+	assert.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", ""))
+	assert.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", ""))
+
+	events, err = a.GetInstanceTimeline(instanceID, tApp.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "download started", events[0].Label)
+		assert.Equal(t, "update_download_started", events[0].TypeName)
+		assert.Equal(t, "success", events[0].ResultName)
+		assert.Equal(t, "download finished", events[1].Label)
+		assert.Equal(t, "update completed", events[2].Label)
+		assert.Equal(t, EventUpdateComplete, events[2].Type)
+		assert.Equal(t, "success_reboot", events[2].ResultName)
+		assert.Equal(t, "12.0.0", events[2].PreviousVersion.String)
+	}
+}
+
+func TestEventLabel(t *testing.T) {
+	assert.Equal(t, "update failed", eventLabel(EventUpdateComplete, ResultFailed))
+	assert.Equal(t, "update applied", eventLabel(EventUpdateComplete, ResultSuccess))
+	assert.Equal(t, "update installed", eventLabel(EventUpdateInstalled, ResultSuccess))
+	assert.Equal(t, "unknown event", eventLabel(1000, ResultSuccess))
+}
+
+func TestGetEventErrorBreakdown(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	since := time.Now().Add(-time.Minute)
+	failUpdate := func(groupID, errorCode string) {
+		instanceID := uuid.New().String()
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, groupID)
+		require.NoError(t, err)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, groupID, EventUpdateDownloadStarted, ResultSuccess, "", "0"))
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, groupID, EventUpdateComplete, ResultFailed, "", errorCode))
+	}
+	failUpdate(tGroup1.ID, "268437959")
+	failUpdate(tGroup1.ID, "268437959")
+	failUpdate(tGroup1.ID, "9")
+	failUpdate(tGroup2.ID, "10")
+
+	breakdown, err := a.GetEventErrorBreakdown(tGroup1.ID, since)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"268437959": 2, "9": 1}, breakdown)
+
+	breakdown, err = a.GetEventErrorBreakdown(tGroup1.ID, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, breakdown)
+}
+
+func TestGetFailingInstances(t *testing.T) {
+	a, err := NewForTest(OptionInitDB)
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg1.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	since := time.Now().Add(-time.Minute)
+	update := func(instanceID string, result int) {
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, result, "", ""))
+	}
+	update("failed-instance", ResultFailed)
+	update("updated-instance", ResultSuccess)
+
+	// Instances still updating haven't failed.
+	_, err = a.GetUpdatePackage("updating-instance", "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	require.NoError(t, a.RegisterEvent("updating-instance", tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+
+	instances, err := a.GetFailingInstances(tGroup.ID, since)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "failed-instance", instances[0].ID)
+	assert.Equal(t, null.StringFrom("12.1.0"), instances[0].Application.LastUpdateVersion)
+
+	instances, err = a.GetFailingInstances(tGroup.ID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+
+	// Failures updating to an older version don't count once the group
+	// moves on to a new one.
+	tChannel.PackageID = null.StringFrom(tPkg2.ID)
+	require.NoError(t, a.UpdateChannel(tChannel))
+	instances, err = a.GetFailingInstances(tGroup.ID, since)
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+}
+
+func TestRegisterEvent_Duplicates(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	instanceID := uuid.New().String()
+
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "12.0.0", ""))
+	assert.Equal(t, ErrDuplicateEvent, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "12.0.0", ""))
+
+	// Events with different sequences are not resends of each other, but
+	// the same sequence is.
+	require.NoError(t, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "", "1"))
+	assert.Equal(t, ErrDuplicateEvent, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "", "1"))
+	require.NoError(t, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "", "2"))
+
+	// Identical events posted after the dedup window are registered.
+	clock.Advance(eventDedupWindow + time.Second)
+	require.NoError(t, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "", "2"))
+
+	// Events that couldn't be registered are not taken as seen, so they can
+	// be posted again.
+	_, err = a.db.Exec("ALTER TABLE event ADD CONSTRAINT event_test_check CHECK (error_code <> 'boom')")
+	require.NoError(t, err)
+	assert.Equal(t, ErrEventRegistrationFailed, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "boom", "3"))
+	_, err = a.db.Exec("ALTER TABLE event DROP CONSTRAINT event_test_check")
+	require.NoError(t, err)
+	require.NoError(t, a.RegisterEventWithSequence(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "12.0.0", "boom", "3"))
+
+	events, err := a.GetInstanceTimeline(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Len(t, events, 5)
+}
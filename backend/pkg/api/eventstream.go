@@ -0,0 +1,125 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// eventSubscriptionBufferSize is the number of events buffered for each
+// subscriber. Subscribers falling this far behind are dropped instead of
+// buffering their events unboundedly.
+const eventSubscriptionBufferSize = 64
+
+// StreamedEvent represents an instance event as published to the event
+// stream subscribers once it has been registered.
+type StreamedEvent struct {
+	ApplicationID   string    `json:"application_id"`
+	GroupID         string    `json:"group_id"`
+	InstanceID      string    `json:"instance_id"`
+	Type            int       `json:"type"`
+	Result          int       `json:"result"`
+	PreviousVersion string    `json:"previous_version"`
+	ErrorCode       string    `json:"error_code"`
+	CreatedTs       time.Time `json:"created_ts"`
+
+	ResultDescription string `json:"result_description,omitempty"`
+}
+
+// EventSubscription represents a subscription to the events registered for
+// an application, optionally restricted to one of its groups. The events are
+// delivered on C, which is closed when the subscription is cancelled or when
+// the subscriber doesn't keep up with the events published.
+type EventSubscription struct {
+	C <-chan *StreamedEvent
+
+	appID   string
+	groupID string
+	events  chan *StreamedEvent
+	broker  *eventBroker
+}
+
+// Unsubscribe cancels the subscription, closing its channel. It's safe to
+// call it more than once, or after the subscription was dropped.
+func (s *EventSubscription) Unsubscribe() {
+	s.broker.unsubscribe(s)
+}
+
+func (s *EventSubscription) matches(event *StreamedEvent) bool {
+	if s.appID != event.ApplicationID {
+		return false
+	}
+	return s.groupID == "" || s.groupID == event.GroupID
+}
+
+// eventBroker fans out the registered events to the subscriptions matching
+// them, safe for concurrent use.
+type eventBroker struct {
+	mu            sync.Mutex
+	subscriptions map[*EventSubscription]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscriptions: make(map[*EventSubscription]struct{}),
+	}
+}
+
+func (b *eventBroker) subscribe(appID, groupID string) *EventSubscription {
+	events := make(chan *StreamedEvent, eventSubscriptionBufferSize)
+	s := &EventSubscription{
+		C:       events,
+		appID:   appID,
+		groupID: groupID,
+		events:  events,
+		broker:  b,
+	}
+
+	b.mu.Lock()
+	b.subscriptions[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *eventBroker) unsubscribe(s *EventSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(s)
+}
+
+// remove closes the channel of the subscription provided if it's still
+// registered. The caller must hold the broker's lock.
+func (b *eventBroker) remove(s *EventSubscription) {
+	if _, ok := b.subscriptions[s]; !ok {
+		return
+	}
+	delete(b.subscriptions, s)
+	close(s.events)
+}
+
+// publish delivers the event provided to the matching subscriptions without
+// blocking, dropping those whose buffer is full.
+func (b *eventBroker) publish(event *StreamedEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions {
+		if !s.matches(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			logger.Warn().Str("appID", s.appID).Str("groupID", s.groupID).Msg("publish - dropping slow event stream subscriber")
+			b.remove(s)
+		}
+	}
+}
+
+// SubscribeEvents returns a subscription to the events registered from now
+// on for the application provided, restricted to the group provided unless
+// it's empty. The subscription must be cancelled once it's no longer needed.
+func (api *API) SubscribeEvents(appID, groupID string) *EventSubscription {
+	return api.eventBroker.subscribe(appID, groupID)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+
+	appSub := a.SubscribeEvents(tApp.ID, "")
+	defer appSub.Unsubscribe()
+	groupSub := a.SubscribeEvents(tApp.ID, tGroup.ID)
+	defer groupSub.Unsubscribe()
+	otherGroupSub := a.SubscribeEvents(tApp.ID, tGroup2.ID)
+	defer otherGroupSub.Unsubscribe()
+
+	_, err := a.GetUpdatePackage(tInstance.ID, "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	err = a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "1.0.0", "")
+	require.NoError(t, err)
+
+	for _, sub := range []*EventSubscription{appSub, groupSub} {
+		select {
+		case event := <-sub.C:
+			require.NotNil(t, event)
+			assert.Equal(t, tApp.ID, event.ApplicationID)
+			assert.Equal(t, tGroup.ID, event.GroupID)
+			assert.Equal(t, tInstance.ID, event.InstanceID)
+			assert.Equal(t, EventUpdateDownloadStarted, event.Type)
+			assert.Equal(t, ResultSuccess, event.Result)
+			assert.Equal(t, "1.0.0", event.PreviousVersion)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+
+	select {
+	case event := <-otherGroupSub.C:
+		t.Fatalf("unexpected event delivered: %v", event)
+	default:
+	}
+
+	// Events that fail to be registered aren't published.
+	err = a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, 1000, ResultSuccess, "", "")
+	assert.Equal(t, ErrInvalidEventTypeOrResult, err)
+	select {
+	case event := <-appSub.C:
+		t.Fatalf("unexpected event delivered: %v", event)
+	default:
+	}
+
+	appSub.Unsubscribe()
+	_, ok := <-appSub.C
+	assert.False(t, ok)
+}
+
+func TestSubscribeEvents_SlowSubscriber(t *testing.T) {
+	b := newEventBroker()
+	sub := b.subscribe("app", "")
+	defer sub.Unsubscribe()
+
+	for i := 0; i <= eventSubscriptionBufferSize; i++ {
+		b.publish(&StreamedEvent{ApplicationID: "app", GroupID: "group"})
+	}
+
+	// The buffered events are still delivered before the channel is closed.
+	received := 0
+	for range sub.C {
+		received++
+	}
+	assert.Equal(t, eventSubscriptionBufferSize, received)
+
+	// Publishing to a dropped subscriber is a no-op.
+	b.publish(&StreamedEvent{ApplicationID: "app", GroupID: "group"})
+}
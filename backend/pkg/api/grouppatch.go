@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+var (
+	// ErrInvalidGroupPatch error indicates that the changes provided to patch
+	// a group refer to a field that can't be patched or have a value of the
+	// wrong type.
+	ErrInvalidGroupPatch = newValidationError("nebraska: invalid group patch")
+
+	// ErrInvalidPolicyInterval error indicates that the period interval, the
+	// update timeout or the minimum bake time of a group's policy is not a
+	// valid positive interval.
+	ErrInvalidPolicyInterval = newValidationError("nebraska: invalid policy interval")
+)
+
+// patchableGroupFields are the json names of the group's fields that can be
+// patched. The other ones are either read-only or have dedicated methods, like
+// PauseGroup.
+var patchableGroupFields = map[string]bool{
+	"name":                               true,
+	"description":                        true,
+	"channel_id":                         true,
+	"policy_updates_enabled":             true,
+	"policy_safe_mode":                   true,
+	"policy_office_hours":                true,
+	"policy_timezone":                    true,
+	"policy_period_interval":             true,
+	"policy_max_updates_per_period":      true,
+	"policy_update_timeout":              true,
+	"policy_update_timeout_action":       true,
+	"policy_min_healthy_instances":       true,
+	"policy_max_version_spread":          true,
+	"policy_rollout_percentage":          true,
+	"policy_max_concurrent_downloads":    true,
+	"policy_max_concurrent_updates":      true,
+	"policy_rollout_cohorts":             true,
+	"policy_active_cohort":               true,
+	"policy_rollback_failure_percentage": true,
+	"policy_pinned_version":              true,
+	"policy_force_update_after":          true,
+	"policy_windows_bypass_severity":     true,
+	"policy_min_bake_time":               true,
+	"rollout_starts_at":                  true,
+	"rollout_ends_at":                    true,
+	"policy_observe_only":                true,
+	"policy_safe_mode_failure_threshold": true,
+	"policy_safe_mode_failure_window":    true,
+	"policy_rollout_order":               true,
+	"policy_update_windows":              true,
+	"channel_weights":                    true,
+	"track":                              true,
+}
+
+// PatchGroup updates only the fields of the group provided present in the
+// changes, keyed by their json name, leaving the other ones untouched. The
+// resulting group goes through the same validations as in UpdateGroup.
+func (api *API) PatchGroup(groupID string, changes map[string]interface{}) (*Group, error) {
+	for field := range changes {
+		if !patchableGroupFields[field] {
+			return nil, ErrInvalidGroupPatch
+		}
+	}
+
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the changes on top of the json representation of the current
+	// group, so that they are decoded exactly like in a full update.
+	current, err := json.Marshal(group)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(current, &fields); err != nil {
+		return nil, err
+	}
+	for field, value := range changes {
+		fields[field] = value
+	}
+	patched, err := json.Marshal(fields)
+	if err != nil {
+		return nil, ErrInvalidGroupPatch
+	}
+	group = &Group{}
+	if err := json.Unmarshal(patched, group); err != nil {
+		return nil, ErrInvalidGroupPatch
+	}
+
+	for _, field := range []string{"policy_period_interval", "policy_update_timeout"} {
+		if _, ok := changes[field]; !ok {
+			continue
+		}
+		if err := api.validatePolicyInterval(fields[field]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := api.UpdateGroup(group); err != nil {
+		return nil, err
+	}
+	return api.GetGroup(groupID)
+}
+
+// validatePolicyInterval checks that the value provided is a positive
+// interval postgres can parse, like "15 minutes".
+func (api *API) validatePolicyInterval(value interface{}) error {
+	interval, ok := value.(string)
+	if !ok || interval == "" {
+		return ErrInvalidPolicyInterval
+	}
+	var positive bool
+	if err := api.db.QueryRow("SELECT $1::interval > interval '0'", interval).Scan(&positive); err != nil || !positive {
+		return ErrInvalidPolicyInterval
+	}
+	return nil
+}
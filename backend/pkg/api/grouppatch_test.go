@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestPatchGroup(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID})
+	tChannel2, _ := a.AddChannel(&Channel{Name: "test_channel2", Color: "red", ApplicationID: tApp.ID})
+	tChannelApp2, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp2.ID})
+	tGroup, err := a.AddGroup(&Group{
+		Name:                      "test_group",
+		Description:               "description",
+		ApplicationID:             tApp.ID,
+		ChannelID:                 null.StringFrom(tChannel.ID),
+		PolicyUpdatesEnabled:      true,
+		PolicySafeMode:            true,
+		PolicyOfficeHours:         true,
+		PolicyTimezone:            null.StringFrom("Europe/Berlin"),
+		PolicyPeriodInterval:      "15 minutes",
+		PolicyMaxUpdatesPerPeriod: 5,
+		PolicyUpdateTimeout:       "60 minutes",
+		PolicyRolloutPercentage:   null.IntFrom(20),
+		PolicyUpdateWindows:       []UpdateWindow{{Weekday: 1, StartTime: "09:00", EndTime: "17:00"}},
+	})
+	require.NoError(t, err)
+	before, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+
+	group, err := a.PatchGroup(tGroup.ID, map[string]interface{}{"policy_max_updates_per_period": 10})
+	require.NoError(t, err)
+	assert.Equal(t, 10, group.PolicyMaxUpdatesPerPeriod)
+
+	// Everything else is preserved.
+	before.PolicyMaxUpdatesPerPeriod = 10
+	group.Channel, before.Channel = nil, nil
+	assert.Equal(t, before, group)
+
+	group, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"channel_id": tChannel2.ID, "policy_rollout_percentage": nil})
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom(tChannel2.ID), group.ChannelID)
+	assert.False(t, group.PolicyRolloutPercentage.Valid)
+	assert.Equal(t, "Europe/Berlin", group.PolicyTimezone.String)
+	assert.Len(t, group.PolicyUpdateWindows, 1)
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"channel_id": tChannelApp2.ID})
+	assert.Equal(t, ErrInvalidChannel, err, "The channel must belong to the group's application.")
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"channel_id": uuid.New().String()})
+	assert.Error(t, err, "The channel must exist.")
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"policy_period_interval": "every now and then"})
+	assert.Equal(t, ErrInvalidPolicyInterval, err)
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"policy_update_timeout": "-5 minutes"})
+	assert.Equal(t, ErrInvalidPolicyInterval, err)
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"policy_max_updates_per_period": "many"})
+	assert.Equal(t, ErrInvalidGroupPatch, err)
+
+	_, err = a.PatchGroup(tGroup.ID, map[string]interface{}{"application_id": tApp2.ID})
+	assert.Equal(t, ErrInvalidGroupPatch, err)
+
+	_, err = a.PatchGroup(uuid.New().String(), map[string]interface{}{"name": "new_name"})
+	assert.Error(t, err)
+
+	group, err = a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom(tChannel2.ID), group.ChannelID, "Failed patches leave the group untouched.")
+	assert.Equal(t, "15 minutes", group.PolicyPeriodInterval)
+}
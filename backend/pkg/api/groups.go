@@ -2,12 +2,14 @@ package api
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/doug-martin/goqu/v9"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -41,25 +43,49 @@ var durationParamToCode = map[durationParam]durationCode{
 	"30d": thirtyDays,
 }
 var (
+	// ErrInvalidRolloutPercentage error indicates that the rollout percentage
+	// provided is not between 0 and 100.
+	ErrInvalidRolloutPercentage = newValidationError("nebraska: invalid rollout percentage")
+
+	// ErrInvalidRollbackFailurePercentage error indicates that the failure
+	// percentage provided to trigger automatic rollbacks is not between 0 and
+	// 100.
+	ErrInvalidRollbackFailurePercentage = newValidationError("nebraska: invalid rollback failure percentage")
+
 	// ErrInvalidChannel error indicates that a channel doesn't belong to the
 	// application it was supposed to belong to.
-	ErrInvalidChannel = errors.New("nebraska: invalid channel")
+	ErrInvalidChannel = newValidationError("nebraska: invalid channel")
+
+	// ErrExpectingValidTimezone error indicates that the timezone provided
+	// isn't in the tz database, or that a timezone wasn't provided when
+	// enabling the flag PolicyOfficeHours or setting update windows.
+	ErrExpectingValidTimezone = newValidationError("nebraska: expecting valid timezone")
 
-	// ErrExpectingValidTimezone error indicates that a valid timezone wasn't
-	// provided when enabling the flag PolicyOfficeHours.
-	ErrExpectingValidTimezone = errors.New("nebraska: expecting valid timezone")
+	// ErrInvalidPackageSize error indicates that the size of the package
+	// provided is missing or cannot be parsed as a number of bytes.
+	ErrInvalidPackageSize = newValidationError("nebraska: invalid package size")
+
+	// ErrGroupNameExists error indicates that the application already has a
+	// group with the name provided.
+	ErrGroupNameExists = newConflictError("nebraska: group name already exists")
+
+	// ErrInvalidForceUpdateAfter error indicates that the deadline after which
+	// updates are forced on the group's instances is not in the future.
+	ErrInvalidForceUpdateAfter = newValidationError("nebraska: invalid force update after deadline")
 
 	// cachedGroups caches the mapping of group track names and
-	// architectures to groups. It must not be modified directly but
+	// architectures to groups, of groups to their applications and
+	// rollout state, and of track aliases to tracks. It must not be
+	// modified directly but
 	// replaced (atomically or via lock) by a new map to prevent data races.
 	// An update must be triggered through updateCachedGroups() each time
-	// a group entry changes (channel architectures are not modified after
+	// a group or track alias entry changes (channel architectures are not modified after
 	// creation, thus changes to the channel don't need to trigger an
 	// update). A RW lock was chosen to prevent data races over the pointer
 	// itself. An alternative is to use atomic loads instead of RLock()
 	// and using atomic stores inside Lock() of a normal Mutex to serialize
 	// the writes (or use channel handshakes instead of a mutex).
-	cachedGroups                    map[GroupDescriptor]string
+	cachedGroups                    *groupsCache
 	cachedGroupsLock                sync.RWMutex
 	cachedGroupVersionCount         = make(map[groupDurationCacheKey]groupVersionCountCache)
 	cachedGroupVersionCountLock     sync.RWMutex
@@ -81,24 +107,67 @@ type GroupDescriptor struct {
 	Arch  Arch
 }
 
+// groupsCache is the content of cachedGroups.
+type groupsCache struct {
+	// ids maps the track names and architectures to the groups' IDs.
+	ids map[GroupDescriptor]string
+	// appIDs maps the groups' IDs to their applications' IDs.
+	appIDs map[string]string
+	// rolloutsInProgress holds the IDs of the groups with a rollout in
+	// progress.
+	rolloutsInProgress map[string]bool
+	// trackAliases maps the track aliases of the applications to the
+	// tracks they resolve to.
+	trackAliases map[trackAliasKey]string
+}
+
+// trackAliasKey identifies a track alias of an application.
+type trackAliasKey struct {
+	appID string
+	alias string
+}
+
 // Group represents a Nebraska application's group.
 type Group struct {
-	ID                        string      `db:"id" json:"id"`
-	Name                      string      `db:"name" json:"name"`
-	Description               string      `db:"description" json:"description"`
-	CreatedTs                 time.Time   `db:"created_ts" json:"created_ts"`
-	RolloutInProgress         bool        `db:"rollout_in_progress" json:"rollout_in_progress"`
-	ApplicationID             string      `db:"application_id" json:"application_id"`
-	ChannelID                 null.String `db:"channel_id" json:"channel_id"`
-	PolicyUpdatesEnabled      bool        `db:"policy_updates_enabled" json:"policy_updates_enabled"`
-	PolicySafeMode            bool        `db:"policy_safe_mode" json:"policy_safe_mode"`
-	PolicyOfficeHours         bool        `db:"policy_office_hours" json:"policy_office_hours"`
-	PolicyTimezone            null.String `db:"policy_timezone" json:"policy_timezone"`
-	PolicyPeriodInterval      string      `db:"policy_period_interval" json:"policy_period_interval"`
-	PolicyMaxUpdatesPerPeriod int         `db:"policy_max_updates_per_period" json:"policy_max_updates_per_period"`
-	PolicyUpdateTimeout       string      `db:"policy_update_timeout" json:"policy_update_timeout"`
-	Channel                   *Channel    `db:"channel" json:"channel,omitempty"`
-	Track                     string      `db:"track" json:"track"`
+	ID                              string          `db:"id" json:"id"`
+	Name                            string          `db:"name" json:"name"`
+	Description                     string          `db:"description" json:"description"`
+	CreatedTs                       time.Time       `db:"created_ts" json:"created_ts"`
+	RolloutInProgress               bool            `db:"rollout_in_progress" json:"rollout_in_progress"`
+	ApplicationID                   string          `db:"application_id" json:"application_id"`
+	ChannelID                       null.String     `db:"channel_id" json:"channel_id"`
+	PolicyUpdatesEnabled            bool            `db:"policy_updates_enabled" json:"policy_updates_enabled"`
+	PolicySafeMode                  bool            `db:"policy_safe_mode" json:"policy_safe_mode"`
+	PolicyOfficeHours               bool            `db:"policy_office_hours" json:"policy_office_hours"`
+	PolicyTimezone                  null.String     `db:"policy_timezone" json:"policy_timezone"`
+	PolicyPeriodInterval            string          `db:"policy_period_interval" json:"policy_period_interval"`
+	PolicyMaxUpdatesPerPeriod       int             `db:"policy_max_updates_per_period" json:"policy_max_updates_per_period"`
+	PolicyUpdateTimeout             string          `db:"policy_update_timeout" json:"policy_update_timeout"`
+	PolicyUpdateTimeoutAction       string          `db:"policy_update_timeout_action" json:"policy_update_timeout_action"`
+	PolicyMinHealthyInstances       int             `db:"policy_min_healthy_instances" json:"policy_min_healthy_instances"`
+	PolicyMaxVersionSpread          int             `db:"policy_max_version_spread" json:"policy_max_version_spread"`
+	PolicyRolloutPercentage         null.Int        `db:"policy_rollout_percentage" json:"policy_rollout_percentage"`
+	PolicyMaxConcurrentDownloads    int             `db:"policy_max_concurrent_downloads" json:"policy_max_concurrent_downloads"`
+	PolicyMaxConcurrentUpdates      int             `db:"policy_max_concurrent_updates" json:"policy_max_concurrent_updates"`
+	PolicyRolloutCohorts            int             `db:"policy_rollout_cohorts" json:"policy_rollout_cohorts"`
+	PolicyActiveCohort              int             `db:"policy_active_cohort" json:"policy_active_cohort"`
+	PolicyPaused                    bool            `db:"policy_paused" json:"policy_paused"`
+	PolicyRollbackFailurePercentage null.Int        `db:"policy_rollback_failure_percentage" json:"policy_rollback_failure_percentage"`
+	LastKnownGoodPackageID          null.String     `db:"last_known_good_package_id" json:"last_known_good_package_id"`
+	PolicyPinnedVersion             null.String     `db:"policy_pinned_version" json:"policy_pinned_version"`
+	PolicyForceUpdateAfter          null.Time       `db:"policy_force_update_after" json:"policy_force_update_after"`
+	PolicyWindowsBypassSeverity     null.String     `db:"policy_windows_bypass_severity" json:"policy_windows_bypass_severity"`
+	PolicyMinBakeTime               null.String     `db:"policy_min_bake_time" json:"policy_min_bake_time"`
+	RolloutStartsAt                 null.Time       `db:"rollout_starts_at" json:"rollout_starts_at"`
+	RolloutEndsAt                   null.Time       `db:"rollout_ends_at" json:"rollout_ends_at"`
+	PolicyObserveOnly               bool            `db:"policy_observe_only" json:"policy_observe_only"`
+	PolicySafeModeFailureThreshold  int             `db:"policy_safe_mode_failure_threshold" json:"policy_safe_mode_failure_threshold"`
+	PolicySafeModeFailureWindow     null.String     `db:"policy_safe_mode_failure_window" json:"policy_safe_mode_failure_window"`
+	PolicyRolloutOrder              string          `db:"policy_rollout_order" json:"policy_rollout_order"`
+	PolicyUpdateWindows             []UpdateWindow  `db:"-" json:"policy_update_windows"`
+	ChannelWeights                  []ChannelWeight `db:"-" json:"channel_weights"`
+	Channel                         *Channel        `db:"channel" json:"channel,omitempty"`
+	Track                           string          `db:"track" json:"track"`
 }
 
 // VersionBreakdownEntry represents the distribution of the versions currently
@@ -138,6 +207,25 @@ type InstancesStatusStats struct {
 	OnHold        null.Int `db:"onhold" json:"onhold"`
 }
 
+// RolloutProgress represents how far the rollout of the version a group is
+// being updated to has gone among the group's active instances.
+type RolloutProgress struct {
+	Version string `db:"-" json:"version"`
+	// Total is the number of active instances in the group.
+	Total int `db:"total" json:"total"`
+	// Updated is the number of instances running the rollout's version.
+	Updated int `db:"updated" json:"updated"`
+	// Failed is the number of instances that reported an error since they
+	// were granted the update to the rollout's version.
+	Failed int `db:"failed" json:"failed"`
+	// Pending is the number of instances yet to be updated.
+	Pending int `db:"-" json:"pending"`
+
+	UpdatedPercentage float64 `db:"-" json:"updated_percentage"`
+	FailedPercentage  float64 `db:"-" json:"failed_percentage"`
+	PendingPercentage float64 `db:"-" json:"pending_percentage"`
+}
+
 // UpdatesStats represents a set of statistics about the status of the updates
 // that may be taking place in the instaces belonging to a given group.
 type UpdatesStats struct {
@@ -151,10 +239,62 @@ type UpdatesStats struct {
 	UpdatesTimedOut                  int `db:"updates_timed_out"`
 }
 
+// validateGroupTimezone checks that the group's timezone, when set, is a
+// valid IANA timezone name, and that it's set when the group's office hours
+// or update windows need it to be evaluated in local time.
+func validateGroupTimezone(group *Group) error {
+	if group.PolicyTimezone.String != "" && !isTimezoneValid(group.PolicyTimezone.String) {
+		return ErrExpectingValidTimezone
+	}
+	if (group.PolicyOfficeHours || len(group.PolicyUpdateWindows) > 0) && group.PolicyTimezone.String == "" {
+		return ErrExpectingValidTimezone
+	}
+	return nil
+}
+
 // AddGroup registers the provided group.
 func (api *API) AddGroup(group *Group) (*Group, error) {
-	if group.PolicyOfficeHours && !isTimezoneValid(group.PolicyTimezone.String) {
-		return nil, ErrExpectingValidTimezone
+	if err := api.checkAppTeam(group.ApplicationID); err != nil {
+		return nil, err
+	}
+	if err := validateGroupTimezone(group); err != nil {
+		return nil, err
+	}
+	if !isRolloutPercentageValid(group.PolicyRolloutPercentage) {
+		return nil, ErrInvalidRolloutPercentage
+	}
+	if !isRolloutPercentageValid(group.PolicyRollbackFailurePercentage) {
+		return nil, ErrInvalidRollbackFailurePercentage
+	}
+	if group.PolicyPinnedVersion.String != "" && !isValidSemver(group.PolicyPinnedVersion.String) {
+		return nil, ErrInvalidSemver
+	}
+	if err := validateUpdateWindows(group.PolicyUpdateWindows); err != nil {
+		return nil, err
+	}
+	if err := normalizeUpdateTimeoutAction(group); err != nil {
+		return nil, err
+	}
+	if err := validateWindowsBypassSeverity(group); err != nil {
+		return nil, err
+	}
+	if err := normalizeRolloutCohorts(group); err != nil {
+		return nil, err
+	}
+	if err := api.normalizeMinBakeTime(group); err != nil {
+		return nil, err
+	}
+	if err := api.normalizeSafeModeFailureThreshold(group); err != nil {
+		return nil, err
+	}
+	if err := normalizeRolloutOrder(group); err != nil {
+		return nil, err
+	}
+	if err := validateRolloutSchedule(group); err != nil {
+		return nil, err
+	}
+	if group.PolicyForceUpdateAfter.Valid && !group.PolicyForceUpdateAfter.Time.After(api.nowUTC()) {
+		return nil, ErrInvalidForceUpdateAfter
 	}
 
 	if group.ChannelID.String != "" {
@@ -162,6 +302,15 @@ func (api *API) AddGroup(group *Group) (*Group, error) {
 			return nil, err
 		}
 	}
+	if len(group.ChannelWeights) > 0 {
+		arch, err := api.channelWeightsArch(group)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.validateChannelWeights(group.ChannelWeights, group.ApplicationID, arch); err != nil {
+			return nil, err
+		}
+	}
 	// Instead of trying to solve this in the database, generate the ID beforehand to copy it to the track.
 	if group.ID == "" {
 		group.ID = uuid.New().String()
@@ -171,7 +320,8 @@ func (api *API) AddGroup(group *Group) (*Group, error) {
 	}
 	query, _, err := goqu.Insert("groups").
 		Cols("id", "name", "description", "application_id", "channel_id", "policy_updates_enabled", "policy_safe_mode", "policy_office_hours",
-			"policy_timezone", "policy_period_interval", "policy_max_updates_per_period", "policy_update_timeout", "track").
+			"policy_timezone", "policy_period_interval", "policy_max_updates_per_period", "policy_update_timeout", "policy_update_timeout_action", "policy_min_healthy_instances",
+			"policy_max_version_spread", "policy_rollout_percentage", "policy_max_concurrent_downloads", "policy_max_concurrent_updates", "policy_rollout_cohorts", "policy_active_cohort", "policy_rollback_failure_percentage", "policy_pinned_version", "policy_force_update_after", "policy_windows_bypass_severity", "policy_min_bake_time", "rollout_starts_at", "rollout_ends_at", "policy_observe_only", "policy_safe_mode_failure_threshold", "policy_safe_mode_failure_window", "policy_rollout_order", "track").
 		Vals(goqu.Vals{
 			group.ID,
 			group.Name,
@@ -185,6 +335,25 @@ func (api *API) AddGroup(group *Group) (*Group, error) {
 			group.PolicyPeriodInterval,
 			group.PolicyMaxUpdatesPerPeriod,
 			group.PolicyUpdateTimeout,
+			group.PolicyUpdateTimeoutAction,
+			group.PolicyMinHealthyInstances,
+			group.PolicyMaxVersionSpread,
+			group.PolicyRolloutPercentage,
+			group.PolicyMaxConcurrentDownloads,
+			group.PolicyMaxConcurrentUpdates,
+			group.PolicyRolloutCohorts,
+			group.PolicyActiveCohort,
+			group.PolicyRollbackFailurePercentage,
+			group.PolicyPinnedVersion,
+			group.PolicyForceUpdateAfter,
+			group.PolicyWindowsBypassSeverity,
+			group.PolicyMinBakeTime,
+			group.RolloutStartsAt,
+			group.RolloutEndsAt,
+			group.PolicyObserveOnly,
+			group.PolicySafeModeFailureThreshold,
+			group.PolicySafeModeFailureWindow,
+			group.PolicyRolloutOrder,
 			group.Track,
 		}).
 		Returning(goqu.T("groups").All()).
@@ -192,10 +361,39 @@ func (api *API) AddGroup(group *Group) (*Group, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = api.db.QueryRowx(query).StructScan(group)
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AddGroup - could not roll back")
+		}
+	}()
+	err = tx.QueryRowx(query).StructScan(group)
+	if err != nil {
+		return nil, wrapUniqueViolation(err, "group %q", group.Name)
+	}
+	if len(group.PolicyUpdateWindows) > 0 {
+		if err := api.setGroupUpdateWindows(tx, group.ID, group.PolicyUpdateWindows); err != nil {
+			return nil, err
+		}
+	}
+	if len(group.ChannelWeights) > 0 {
+		if err := api.setGroupChannelWeights(tx, group.ID, group.ChannelWeights); err != nil {
+			return nil, err
+		}
+	}
+	groupAfterAdd, err := api.getGroupAuditSnapshot(tx, group.ID)
 	if err != nil {
 		return nil, err
 	}
+	if err := api.recordAuditEntry(tx, activityEntityCreated, auditEntityGroup, group.ID, group.ApplicationID, nil, groupAfterAdd); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	api.updateCachedGroups()
 	return group, nil
 }
@@ -203,37 +401,106 @@ func (api *API) AddGroup(group *Group) (*Group, error) {
 // UpdateGroup updates an existing group using the context of the group
 // provided.
 func (api *API) UpdateGroup(group *Group) error {
-	if group.PolicyOfficeHours && !isTimezoneValid(group.PolicyTimezone.String) {
-		return ErrExpectingValidTimezone
+	if err := api.checkGroupTeam(group.ID); err != nil {
+		return err
+	}
+	if err := validateGroupTimezone(group); err != nil {
+		return err
+	}
+	if !isRolloutPercentageValid(group.PolicyRolloutPercentage) {
+		return ErrInvalidRolloutPercentage
+	}
+	if !isRolloutPercentageValid(group.PolicyRollbackFailurePercentage) {
+		return ErrInvalidRollbackFailurePercentage
+	}
+	if group.PolicyPinnedVersion.String != "" && !isValidSemver(group.PolicyPinnedVersion.String) {
+		return ErrInvalidSemver
+	}
+	if err := validateUpdateWindows(group.PolicyUpdateWindows); err != nil {
+		return err
+	}
+	if err := normalizeUpdateTimeoutAction(group); err != nil {
+		return err
+	}
+	if err := validateWindowsBypassSeverity(group); err != nil {
+		return err
+	}
+	if err := normalizeRolloutCohorts(group); err != nil {
+		return err
+	}
+	if err := api.normalizeMinBakeTime(group); err != nil {
+		return err
+	}
+	if err := api.normalizeSafeModeFailureThreshold(group); err != nil {
+		return err
+	}
+	if err := normalizeRolloutOrder(group); err != nil {
+		return err
+	}
+	if err := validateRolloutSchedule(group); err != nil {
+		return err
 	}
 
 	groupBeforeUpdate, err := api.GetGroup(group.ID)
 	if err != nil {
 		return err
 	}
+	// A deadline that already passed can be kept, but not set.
+	if group.PolicyForceUpdateAfter.Valid && !group.PolicyForceUpdateAfter.Time.After(api.nowUTC()) &&
+		!(groupBeforeUpdate.PolicyForceUpdateAfter.Valid && groupBeforeUpdate.PolicyForceUpdateAfter.Time.Equal(group.PolicyForceUpdateAfter.Time)) {
+		return ErrInvalidForceUpdateAfter
+	}
 
 	if group.ChannelID.String != "" {
 		if err := api.validateChannel(group.ChannelID.String, groupBeforeUpdate.ApplicationID); err != nil {
 			return err
 		}
 	}
+	if len(group.ChannelWeights) > 0 {
+		arch, err := api.channelWeightsArch(group)
+		if err != nil {
+			return err
+		}
+		if err := api.validateChannelWeights(group.ChannelWeights, groupBeforeUpdate.ApplicationID, arch); err != nil {
+			return err
+		}
+	}
 	if group.Track == "" {
 		group.Track = group.ID
 	}
 	query, _, err := goqu.Update("groups").
 		Set(
 			goqu.Record{
-				"name":                          group.Name,
-				"description":                   group.Description,
-				"channel_id":                    group.ChannelID,
-				"policy_updates_enabled":        group.PolicyUpdatesEnabled,
-				"policy_safe_mode":              group.PolicySafeMode,
-				"policy_office_hours":           group.PolicyOfficeHours,
-				"policy_timezone":               group.PolicyTimezone,
-				"policy_period_interval":        group.PolicyPeriodInterval,
-				"policy_max_updates_per_period": group.PolicyMaxUpdatesPerPeriod,
-				"policy_update_timeout":         group.PolicyUpdateTimeout,
-				"track":                         group.Track,
+				"name":                               group.Name,
+				"description":                        group.Description,
+				"channel_id":                         group.ChannelID,
+				"policy_updates_enabled":             group.PolicyUpdatesEnabled,
+				"policy_safe_mode":                   group.PolicySafeMode,
+				"policy_office_hours":                group.PolicyOfficeHours,
+				"policy_timezone":                    group.PolicyTimezone,
+				"policy_period_interval":             group.PolicyPeriodInterval,
+				"policy_max_updates_per_period":      group.PolicyMaxUpdatesPerPeriod,
+				"policy_update_timeout":              group.PolicyUpdateTimeout,
+				"policy_update_timeout_action":       group.PolicyUpdateTimeoutAction,
+				"policy_min_healthy_instances":       group.PolicyMinHealthyInstances,
+				"policy_max_version_spread":          group.PolicyMaxVersionSpread,
+				"policy_rollout_percentage":          group.PolicyRolloutPercentage,
+				"policy_max_concurrent_downloads":    group.PolicyMaxConcurrentDownloads,
+				"policy_max_concurrent_updates":      group.PolicyMaxConcurrentUpdates,
+				"policy_rollout_cohorts":             group.PolicyRolloutCohorts,
+				"policy_active_cohort":               group.PolicyActiveCohort,
+				"policy_rollback_failure_percentage": group.PolicyRollbackFailurePercentage,
+				"policy_pinned_version":              group.PolicyPinnedVersion,
+				"policy_force_update_after":          group.PolicyForceUpdateAfter,
+				"policy_windows_bypass_severity":     group.PolicyWindowsBypassSeverity,
+				"policy_min_bake_time":               group.PolicyMinBakeTime,
+				"rollout_starts_at":                  group.RolloutStartsAt,
+				"rollout_ends_at":                    group.RolloutEndsAt,
+				"policy_observe_only":                group.PolicyObserveOnly,
+				"policy_safe_mode_failure_threshold": group.PolicySafeModeFailureThreshold,
+				"policy_safe_mode_failure_window":    group.PolicySafeModeFailureWindow,
+				"policy_rollout_order":               group.PolicyRolloutOrder,
+				"track":                              group.Track,
 			},
 		).
 		Where(goqu.C("id").Eq(group.ID)).
@@ -241,10 +508,23 @@ func (api *API) UpdateGroup(group *Group) error {
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("UpdateGroup - could not roll back")
+		}
+	}()
+	auditBefore, err := api.getGroupAuditSnapshot(tx, group.ID)
 	if err != nil {
 		return err
 	}
+	result, err := tx.Exec(query)
+	if err != nil {
+		return wrapUniqueViolation(err, "group %q", group.Name)
+	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
@@ -253,27 +533,127 @@ func (api *API) UpdateGroup(group *Group) error {
 	if rowsAffected == 0 {
 		return ErrNoRowsAffected
 	}
+	if err := api.setGroupUpdateWindows(tx, group.ID, group.PolicyUpdateWindows); err != nil {
+		return err
+	}
+	if err := api.setGroupChannelWeights(tx, group.ID, group.ChannelWeights); err != nil {
+		return err
+	}
+	auditAfter, err := api.getGroupAuditSnapshot(tx, group.ID)
+	if err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityUpdated, auditEntityGroup, group.ID, groupBeforeUpdate.ApplicationID, auditBefore, auditAfter); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	api.updateCachedGroups()
 	return nil
 }
 
+// CloneGroup registers a new group in the application of the group provided,
+// with its description, channels and policy. The group's state, like it being
+// paused or its rollout progress, and its instances are not copied, and the
+// new group gets its own track.
+func (api *API) CloneGroup(sourceGroupID, newName string) (*Group, error) {
+	source, err := api.GetGroup(sourceGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, _, err := goqu.From("groups").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("application_id").Eq(source.ApplicationID), goqu.C("name").Eq(newName)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	if err := api.db.QueryRow(query).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrGroupNameExists
+	}
+
+	group := &Group{
+		Name:                            newName,
+		Description:                     source.Description,
+		ApplicationID:                   source.ApplicationID,
+		ChannelID:                       source.ChannelID,
+		PolicyUpdatesEnabled:            source.PolicyUpdatesEnabled,
+		PolicySafeMode:                  source.PolicySafeMode,
+		PolicyOfficeHours:               source.PolicyOfficeHours,
+		PolicyTimezone:                  source.PolicyTimezone,
+		PolicyPeriodInterval:            source.PolicyPeriodInterval,
+		PolicyMaxUpdatesPerPeriod:       source.PolicyMaxUpdatesPerPeriod,
+		PolicyUpdateTimeout:             source.PolicyUpdateTimeout,
+		PolicyUpdateTimeoutAction:       source.PolicyUpdateTimeoutAction,
+		PolicyMinHealthyInstances:       source.PolicyMinHealthyInstances,
+		PolicyMaxVersionSpread:          source.PolicyMaxVersionSpread,
+		PolicyRolloutPercentage:         source.PolicyRolloutPercentage,
+		PolicyMaxConcurrentDownloads:    source.PolicyMaxConcurrentDownloads,
+		PolicyMaxConcurrentUpdates:      source.PolicyMaxConcurrentUpdates,
+		PolicyRolloutCohorts:            source.PolicyRolloutCohorts,
+		PolicyRollbackFailurePercentage: source.PolicyRollbackFailurePercentage,
+		PolicyPinnedVersion:             source.PolicyPinnedVersion,
+		PolicyWindowsBypassSeverity:     source.PolicyWindowsBypassSeverity,
+		PolicyMinBakeTime:               source.PolicyMinBakeTime,
+		RolloutStartsAt:                 source.RolloutStartsAt,
+		RolloutEndsAt:                   source.RolloutEndsAt,
+		PolicyObserveOnly:               source.PolicyObserveOnly,
+		PolicySafeModeFailureThreshold:  source.PolicySafeModeFailureThreshold,
+		PolicySafeModeFailureWindow:     source.PolicySafeModeFailureWindow,
+		PolicyRolloutOrder:              source.PolicyRolloutOrder,
+		PolicyUpdateWindows:             source.PolicyUpdateWindows,
+		ChannelWeights:                  source.ChannelWeights,
+	}
+	// A deadline that already passed would force updates on the new group
+	// right away, so only pending ones are copied.
+	if source.PolicyForceUpdateAfter.Valid && source.PolicyForceUpdateAfter.Time.After(api.nowUTC()) {
+		group.PolicyForceUpdateAfter = source.PolicyForceUpdateAfter
+	}
+	if _, err := api.AddGroup(group); err != nil {
+		return nil, err
+	}
+
+	return api.GetGroup(group.ID)
+}
+
 // DeleteGroup removes the group identified by the id provided.
 func (api *API) DeleteGroup(groupID string) error {
-	query, _, err := goqu.Delete("groups").Where(goqu.C("id").Eq(groupID)).ToSQL()
-	if err != nil {
+	if err := api.checkGroupTeam(groupID); err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
+	tx, err := api.db.Beginx()
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("DeleteGroup - could not roll back")
+		}
+	}()
+	groupBeforeDelete, err := api.getGroupAuditSnapshot(tx, groupID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("groups").Where(goqu.C("id").Eq(groupID)).ToSQL()
 	if err != nil {
 		return err
 	}
-
-	if rowsAffected == 0 {
-		return ErrNoRowsAffected
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityDeleted, auditEntityGroup, groupID, groupBeforeDelete.ApplicationID, groupBeforeDelete, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 	api.updateCachedGroups()
 	return nil
@@ -281,6 +661,9 @@ func (api *API) DeleteGroup(groupID string) error {
 
 // GetGroup returns the group identified by the id provided.
 func (api *API) GetGroup(groupID string) (*Group, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
 	var group Group
 
 	query, _, err := goqu.From("groups").
@@ -306,68 +689,124 @@ func (api *API) GetGroup(groupID string) (*Group, error) {
 			return nil, err
 		}
 	}
+	if group.PolicyUpdateWindows, err = api.getGroupUpdateWindows(api.db, group.ID); err != nil {
+		return nil, err
+	}
+	if group.ChannelWeights, err = api.getGroupChannelWeights(api.db, group.ID); err != nil {
+		return nil, err
+	}
 	return &group, nil
 }
 
 // GetGroupID returns the ID of the first group identified by the track name and the channel architecture.
 // The track names should be unique in combination with the group's channel architecture but this is not
-// enforced on the DB level and the newest entry wins.
+// enforced on the DB level and the newest entry wins. If no group uses the given architecture, a group
+// with the track name whose channel serves all architectures is returned instead.
 func (api *API) GetGroupID(trackName string, arch Arch) (string, error) {
-	var cachedGroupsRef map[GroupDescriptor]string
+	cachedGroupsRef := api.getCachedGroups()
+	cachedGroupID, ok := cachedGroupsRef.ids[GroupDescriptor{Track: trackName, Arch: arch}]
+	if !ok {
+		cachedGroupID, ok = cachedGroupsRef.ids[GroupDescriptor{Track: trackName, Arch: ArchAll}]
+	}
+	if !ok {
+		return "", fmt.Errorf("no group found for track %v and architecture %v", trackName, arch)
+	}
+	return cachedGroupID, nil
+}
+
+// GetGroupApplicationID returns the ID of the application the group provided
+// belongs to. Like GetGroupID, it's served from the groups cache.
+func (api *API) GetGroupApplicationID(groupID string) (string, error) {
+	appID, ok := api.getCachedGroups().appIDs[groupID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return appID, nil
+}
+
+// IsGroupRolloutInProgress checks if the group provided has a rollout in
+// progress. Like GetGroupID, it's served from the groups cache.
+func (api *API) IsGroupRolloutInProgress(groupID string) (bool, error) {
+	cachedGroupsRef := api.getCachedGroups()
+	if _, ok := cachedGroupsRef.appIDs[groupID]; !ok {
+		return false, ErrNotFound
+	}
+	return cachedGroupsRef.rolloutsInProgress[groupID], nil
+}
+
+// getCachedGroups returns cachedGroups, generating it if it was invalidated.
+func (api *API) getCachedGroups() *groupsCache {
+	var cachedGroupsRef *groupsCache
 	cachedGroupsLock.RLock()
 	if cachedGroups != nil {
-		// Keep a reference to the map that we found.
+		// Keep a reference to the cache that we found.
 		cachedGroupsRef = cachedGroups
 	}
 	cachedGroupsLock.RUnlock()
-	// Generate map on startup or if invalidated.
-	if cachedGroupsRef == nil {
-		cachedGroupsLock.Lock()
-		cachedGroupsRef = cachedGroups
-		// If a concurrent execution generated it inbetween our RUnlock() and Lock(),
-		// we can use this because any invalidation inbetween must have happened
-		// before the generation because all writes are sequential.
-		if cachedGroupsRef == nil {
-			cachedGroups = make(map[GroupDescriptor]string)
-			query, _, err := goqu.From("groups").ToSQL()
-			var groups []*Group
-			if err == nil {
-				groups, err = api.getGroupsFromQuery(query)
-			}
-			// Checks boths errors above.
-			if err != nil {
-				logger.Error().Err(err).Msg("GetGroupID error")
-			} else {
-				for _, group := range groups {
-					if group.Channel != nil {
-						descriptor := GroupDescriptor{Track: group.Track, Arch: group.Channel.Arch}
-						// The groups are sorted descendingly by the creation time.
-						// The newest group with the track name and arch wins.
-						if otherID, ok := cachedGroups[descriptor]; ok {
-							// Log a warning for others.
-							logger.Warn().Str("group", group.ID).Str("group2", otherID).Str("track", group.Track).Msg("GetGroupID - another group already uses the same track name and architecture")
-						}
-						cachedGroups[descriptor] = group.ID
-					} else {
-						logger.Warn().Str("group", group.ID).Msg("GetGroupID - no channel found for")
-					}
-				}
+	if cachedGroupsRef != nil {
+		return cachedGroupsRef
+	}
+
+	// Generate the cache on startup or if invalidated.
+	cachedGroupsLock.Lock()
+	defer cachedGroupsLock.Unlock()
+	// If a concurrent execution generated it inbetween our RUnlock() and Lock(),
+	// we can use this because any invalidation inbetween must have happened
+	// before the generation because all writes are sequential.
+	if cachedGroups != nil {
+		return cachedGroups
+	}
+	cachedGroups = &groupsCache{
+		ids:                make(map[GroupDescriptor]string),
+		appIDs:             make(map[string]string),
+		rolloutsInProgress: make(map[string]bool),
+		trackAliases:       make(map[trackAliasKey]string),
+	}
+	query, _, err := goqu.From("groups").ToSQL()
+	var groups []*Group
+	if err == nil {
+		groups, err = api.getGroupsFromQuery(query)
+	}
+	// Checks boths errors above.
+	if err != nil {
+		logger.Error().Err(err).Msg("GetGroupID error")
+		return cachedGroups
+	}
+	for _, group := range groups {
+		cachedGroups.appIDs[group.ID] = group.ApplicationID
+		if group.RolloutInProgress {
+			cachedGroups.rolloutsInProgress[group.ID] = true
+		}
+		if group.Channel != nil {
+			descriptor := GroupDescriptor{Track: group.Track, Arch: group.Channel.Arch}
+			// The groups are sorted descendingly by the creation time.
+			// The newest group with the track name and arch wins.
+			if otherID, ok := cachedGroups.ids[descriptor]; ok {
+				// Log a warning for others.
+				logger.Warn().Str("group", group.ID).Str("group2", otherID).Str("track", group.Track).Msg("GetGroupID - another group already uses the same track name and architecture")
 			}
-			// Keep a reference to the map we created.
-			cachedGroupsRef = cachedGroups
+			cachedGroups.ids[descriptor] = group.ID
+		} else {
+			logger.Warn().Str("group", group.ID).Msg("GetGroupID - no channel found for")
 		}
-		cachedGroupsLock.Unlock()
 	}
-
-	cachedGroupID, ok := cachedGroupsRef[GroupDescriptor{Track: trackName, Arch: arch}]
-	if !ok {
-		return "", fmt.Errorf("no group found for track %v and architecture %v", trackName, arch)
+	query, _, err = goqu.From("track_alias").ToSQL()
+	var aliases []*TrackAlias
+	if err == nil {
+		err = api.db.Select(&aliases, query)
 	}
-	return cachedGroupID, nil
+	if err != nil {
+		logger.Error().Err(err).Msg("ResolveTrackAlias error")
+		return cachedGroups
+	}
+	for _, alias := range aliases {
+		cachedGroups.trackAliases[trackAliasKey{appID: alias.ApplicationID, alias: alias.Alias}] = alias.Track
+	}
+	return cachedGroups
 }
 
 // updateCachedGroups invalidates the cached track names in cachedGroups and
-// must be called whenever the group entries are modified.
+// must be called whenever the group or track alias entries are modified.
 func (api *API) updateCachedGroups() {
 	cachedGroupsLock.Lock()
 	cachedGroups = nil
@@ -378,6 +817,9 @@ func (api *API) updateCachedGroups() {
 
 // GetGroups returns all groups that belong to the application provided.
 func (api *API) GetGroups(appID string, page, perPage uint64) ([]*Group, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	page, perPage = validatePaginationParams(page, perPage)
 	limit, offset := sqlPaginate(page, perPage)
 	query, _, err := api.groupsQuery().Where(goqu.C("application_id").Eq(appID)).
@@ -423,6 +865,12 @@ func (api *API) getGroupsFromQuery(query string) ([]*Group, error) {
 				return nil, err
 			}
 		}
+		if group.PolicyUpdateWindows, err = api.getGroupUpdateWindows(api.db, group.ID); err != nil {
+			return nil, err
+		}
+		if group.ChannelWeights, err = api.getGroupChannelWeights(api.db, group.ID); err != nil {
+			return nil, err
+		}
 		groups = append(groups, &group)
 	}
 	if err := rows.Err(); err != nil {
@@ -443,6 +891,97 @@ func (api *API) validateChannel(channelID, appID string) error {
 	return nil
 }
 
+// getGroupHealthyInstances returns the number of active instances in the
+// group provided that are still running its known-good version and aren't
+// updating. The known-good version is the one of the last package rolled out
+// to all the group's instances, or any version other than the one the group
+// is being updated to when none was recorded yet.
+func (api *API) getGroupHealthyInstances(group *Group) (int, error) {
+	ds := goqu.From("instance_application").
+		Select(goqu.COUNT("*")).
+		Where(
+			goqu.C("group_id").Eq(group.ID),
+			goqu.C("update_in_progress").IsFalse(),
+			goqu.C("quarantined").IsFalse(),
+			goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("instance_id")),
+		)
+	switch {
+	case group.LastKnownGoodPackageID.Valid:
+		ds = ds.Where(goqu.C("version").Eq(
+			goqu.From("package").Select("version").Where(goqu.C("id").Eq(group.LastKnownGoodPackageID.String)),
+		))
+	case group.Channel != nil && group.Channel.Package != nil:
+		ds = ds.Where(goqu.C("version").Neq(group.Channel.Package.Version))
+	}
+	query, _, err := ds.ToSQL()
+	if err != nil {
+		return 0, err
+	}
+	var healthyInstances int
+	if err := api.db.QueryRow(query).Scan(&healthyInstances); err != nil {
+		return 0, err
+	}
+	return healthyInstances, nil
+}
+
+// getGroupDownloadsInProgress returns the number of instances in the group
+// provided that reported they started downloading the update package but
+// haven't reported yet the download finished. Only download started events
+// posted within the group's update timeout are taken into account.
+func (api *API) getGroupDownloadsInProgress(group *Group) (int, error) {
+	query := `
+	SELECT count(DISTINCT e.instance_id)
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	JOIN instance_application ia ON ia.instance_id = e.instance_id AND ia.application_id = e.application_id
+	WHERE ia.group_id = $1 AND et.type = $2 AND et.result = $3
+		AND e.created_ts > now() - $4::interval
+		AND NOT EXISTS (
+			SELECT 1
+			FROM event e2
+			JOIN event_type et2 ON et2.id = e2.event_type_id
+			WHERE e2.instance_id = e.instance_id AND e2.application_id = e.application_id
+				AND et2.type = $5 AND e2.created_ts >= e.created_ts
+		)
+	`
+	var downloadsInProgress int
+	err := api.db.QueryRow(query, group.ID, EventUpdateDownloadStarted, ResultSuccess, group.PolicyUpdateTimeout, EventUpdateDownloadFinished).Scan(&downloadsInProgress)
+	if err != nil {
+		return 0, err
+	}
+	return downloadsInProgress, nil
+}
+
+// getGroupUpdatesInFlight returns the number of instances in the group
+// provided that reported they started downloading the update package but
+// haven't reported yet the update completed, successfully or not. Only
+// download started events posted within the group's update timeout are taken
+// into account.
+func (api *API) getGroupUpdatesInFlight(group *Group) (int, error) {
+	query := `
+	SELECT count(DISTINCT e.instance_id)
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	JOIN instance_application ia ON ia.instance_id = e.instance_id AND ia.application_id = e.application_id
+	WHERE ia.group_id = $1 AND et.type = $2 AND et.result = $3
+		AND e.created_ts > $4::timestamptz - $5::interval
+		AND NOT EXISTS (
+			SELECT 1
+			FROM event e2
+			JOIN event_type et2 ON et2.id = e2.event_type_id
+			WHERE e2.instance_id = e.instance_id AND e2.application_id = e.application_id
+				AND et2.type = $6 AND e2.created_ts >= e.created_ts
+		)
+	`
+	var updatesInFlight int
+	err := api.db.QueryRow(query, group.ID, EventUpdateDownloadStarted, ResultSuccess, api.nowUTC(), group.PolicyUpdateTimeout, EventUpdateComplete).Scan(&updatesInFlight)
+	if err != nil {
+		return 0, err
+	}
+	return updatesInFlight, nil
+}
+
 // getGroupUpdatesStats returns a set of statistics about the distribution of
 // updates and their status in the group provided.
 func (api *API) getGroupUpdatesStats(group *Group) (*UpdatesStats, error) {
@@ -453,7 +992,7 @@ func (api *API) getGroupUpdatesStats(group *Group) (*UpdatesStats, error) {
 		packageVersion = group.Channel.Package.Version
 	}
 	query, _, err := goqu.From("instance_application").Select(
-		goqu.COUNT("*").As("total_instances"),
+		goqu.COALESCE(goqu.SUM(goqu.L("case when quarantined then 0 else 1 end")), 0).As("total_instances"),
 		goqu.COALESCE(goqu.SUM(goqu.L("case when last_update_version = ? then 1 else 0 end", packageVersion)), 0).As("updates_to_current_version_granted"),
 		goqu.COALESCE(goqu.SUM(goqu.L("case when update_in_progress = 'false' and last_update_version = ? then 1 else 0 end", packageVersion)), 0).As("updates_to_current_version_attempted"),
 		goqu.COALESCE(goqu.SUM(goqu.L("case when update_in_progress = 'false' and last_update_version = ? and last_update_version = version then 1 else 0 end", packageVersion)), 0).As("updates_to_current_version_succeeded"),
@@ -461,7 +1000,7 @@ func (api *API) getGroupUpdatesStats(group *Group) (*UpdatesStats, error) {
 		goqu.COALESCE(goqu.SUM(goqu.L("case when last_update_granted_ts > now() at time zone 'utc' - interval ? then 1 else 0 end", group.PolicyPeriodInterval)), 0).As("updates_granted_in_last_period"),
 		goqu.COALESCE(goqu.SUM(goqu.L("case when update_in_progress = 'true' and now() at time zone 'utc' - last_update_granted_ts <= interval ? then 1 else 0 end", group.PolicyUpdateTimeout)), 0).As("updates_in_progress"),
 		goqu.COALESCE(goqu.SUM(goqu.L("case when update_in_progress = 'true' and now() at time zone 'utc' - last_update_granted_ts > interval ? then 1 else 0 end", group.PolicyUpdateTimeout)), 0).As("updates_timed_out"),
-	).Where(goqu.C("group_id").Eq(group.ID), goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", validityInterval),
+	).Where(goqu.C("group_id").Eq(group.ID), goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
 		goqu.L(ignoreFakeInstanceCondition("instance_id")),
 	).ToSQL()
 	if err != nil {
@@ -490,11 +1029,80 @@ func (api *API) disableUpdates(groupID string) error {
 	return err
 }
 
+// PauseGroup pauses the rollout taking place in the group provided, so no new
+// instances are offered the update. Instances that were already granted the
+// update aren't affected.
+func (api *API) PauseGroup(groupID string) error {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return err
+	}
+	return api.setGroupPaused(groupID, true)
+}
+
+// ResumeGroup resumes the rollout in the group provided after it was paused.
+func (api *API) ResumeGroup(groupID string) error {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return err
+	}
+	return api.setGroupPaused(groupID, false)
+}
+
+// setGroupPaused updates the value of the policy_paused flag for a given group.
+func (api *API) setGroupPaused(groupID string, paused bool) error {
+	query, _, err := goqu.Update("groups").
+		Set(goqu.Record{"policy_paused": paused}).
+		Where(goqu.C("id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
+
 // setGroupRolloutInProgress updates the value of the rollout_in_progress flag
 // for a given group, indicating if a rollout is taking place now or not.
 func (api *API) setGroupRolloutInProgress(groupID string, inProgress bool) error {
 	query, _, err := goqu.Update("groups").
 		Set(goqu.Record{"rollout_in_progress": inProgress}).
+		Where(goqu.C("id").Eq(groupID), goqu.C("rollout_in_progress").Neq(inProgress)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	// The groups cache is only invalidated when the flag actually changes,
+	// as it's also set to the value it already has.
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		api.updateCachedGroups()
+	}
+
+	return nil
+}
+
+// setGroupLastKnownGoodPackage records the package provided as the last one
+// successfully rolled out to all the instances of the given group.
+func (api *API) setGroupLastKnownGoodPackage(groupID, packageID string) error {
+	query, _, err := goqu.Update("groups").
+		Set(goqu.Record{"last_known_good_package_id": packageID}).
 		Where(goqu.C("id").Eq(groupID)).
 		ToSQL()
 	if err != nil {
@@ -516,7 +1124,12 @@ func (api *API) groupsQuery() *goqu.SelectDataset {
 }
 
 // GetGroupVersionBreakdown returns a version breakdown of all instances running on a given group.
-func (api *API) GetGroupVersionBreakdown(groupID string) ([]*VersionBreakdownEntry, error) {
+// Only the instances that checked for updates within activeWithin are counted, or within the
+// API's default window when it's zero.
+func (api *API) GetGroupVersionBreakdown(groupID string, activeWithin time.Duration) ([]*VersionBreakdownEntry, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
 	var entryList []*VersionBreakdownEntry
 	query := fmt.Sprintf(`
 	SELECT version, count(*) as instances, (count(*) * 100.0 / total) as percentage
@@ -528,8 +1141,8 @@ func (api *API) GetGroupVersionBreakdown(groupID string) ([]*VersionBreakdownEnt
 	WHERE group_id=$1 AND last_check_for_updates > now() at time zone 'utc' - interval '%[1]s' AND %[2]s
 	GROUP BY version, total
 	ORDER BY regexp_matches(version, '(\d+)\.(\d+)\.(\d+)')::int[] DESC
-	`, validityInterval, ignoreFakeInstanceCondition("instance_id"))
-	rows, err := api.db.Queryx(query, groupID)
+	`, api.activeInterval(activeWithin), ignoreFakeInstanceCondition("instance_id"))
+	rows, err := api.readDB().Queryx(query, groupID)
 	if err != nil {
 		return nil, err
 	}
@@ -548,9 +1161,71 @@ func (api *API) GetGroupVersionBreakdown(groupID string) ([]*VersionBreakdownEnt
 	return entryList, nil
 }
 
+// GetVersionSpread returns the oldest and newest versions run by the active
+// instances of the group provided, as well as the spread, which is the number
+// of different versions they are running. Instances are active when they
+// checked for updates within activeWithin, or within the API's default window
+// when it's zero.
+func (api *API) GetVersionSpread(groupID string, activeWithin time.Duration) (oldest, newest string, spread int, err error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return "", "", 0, err
+	}
+	query, _, err := goqu.From("instance_application").
+		Select("version").
+		Distinct().
+		Where(goqu.C("group_id").Eq(groupID),
+			goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(activeWithin)),
+			goqu.L(ignoreFakeInstanceCondition("instance_id"))).
+		ToSQL()
+	if err != nil {
+		return "", "", 0, err
+	}
+	var versions []string
+	if err := api.db.Select(&versions, query); err != nil {
+		return "", "", 0, err
+	}
+
+	var oldestSemver, newestSemver semver.Version
+	for _, version := range versions {
+		v, err := semver.Make(version)
+		if err != nil {
+			continue
+		}
+		if spread == 0 || v.LT(oldestSemver) {
+			oldest, oldestSemver = version, v
+		}
+		if spread == 0 || v.GT(newestSemver) {
+			newest, newestSemver = version, v
+		}
+		spread++
+	}
+
+	return oldest, newest, spread, nil
+}
+
+// checkVersionSpread adds a warning activity entry if the spread of versions
+// in the group provided exceeds the one allowed by its policy. The spread is
+// not computed again while a recent warning exists.
+func (api *API) checkVersionSpread(group *Group) error {
+	if api.hasRecentActivity(activityVersionSpreadExceeded, ActivityQueryParams{AppID: group.ApplicationID, GroupID: group.ID}) {
+		return nil
+	}
+	_, newest, spread, err := api.GetVersionSpread(group.ID, 0)
+	if err != nil {
+		return err
+	}
+	if spread <= group.PolicyMaxVersionSpread {
+		return nil
+	}
+	return api.newGroupActivityEntry(activityVersionSpreadExceeded, activityWarning, newest, group.ApplicationID, group.ID)
+}
+
 // getGroupInstancesStats returns a summary of the status of the
 // instances that belong to a given group.
 func (api *API) GetGroupInstancesStats(groupID, duration string) (*InstancesStatusStats, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
 	var instancesStats InstancesStatusStats
 	durationString, _, err := durationParamToPostgresTimings(durationParam(duration))
 	if err != nil {
@@ -571,13 +1246,200 @@ func (api *API) GetGroupInstancesStats(groupID, duration string) (*InstancesStat
 	WHERE group_id=$1 AND last_check_for_updates > now() at time zone 'utc' - interval '%s' AND %s`,
 		InstanceStatusError, InstanceStatusUpdateGranted, InstanceStatusComplete, InstanceStatusInstalled,
 		InstanceStatusDownloaded, InstanceStatusDownloading, InstanceStatusOnHold, durationString, ignoreFakeInstanceCondition("instance_id"))
-	err = api.db.QueryRowx(query, groupID).StructScan(&instancesStats)
+	err = api.readDB().QueryRowx(query, groupID).StructScan(&instancesStats)
 	if err != nil {
 		return nil, err
 	}
 
 	return &instancesStats, nil
 }
+
+// EstimateRolloutBandwidth returns an estimation of the number of bytes that
+// the rollout of the group's current package will transfer, computed as the
+// package size multiplied by the number of active instances in the group that
+// are not running the package version yet. Instances are active when they
+// checked for updates within activeWithin, or within the API's default window
+// when it's zero.
+func (api *API) EstimateRolloutBandwidth(groupID string, activeWithin time.Duration) (int64, error) {
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return 0, err
+	}
+	if group.Channel == nil || group.Channel.Package == nil {
+		return 0, ErrNoPackageFound
+	}
+	pkg := group.Channel.Package
+	if !pkg.Size.Valid {
+		return 0, ErrInvalidPackageSize
+	}
+	size, err := strconv.ParseInt(pkg.Size.String, 10, 64)
+	if err != nil || size < 0 {
+		return 0, ErrInvalidPackageSize
+	}
+
+	query, _, err := goqu.From("instance_application").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("group_id").Eq(groupID), goqu.C("version").Neq(pkg.Version),
+			goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(activeWithin)),
+			goqu.L(ignoreFakeInstanceCondition("instance_id"))).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+	var pending int64
+	if err := api.readDB().QueryRow(query).Scan(&pending); err != nil {
+		return 0, err
+	}
+
+	return size * pending, nil
+}
+
+// groupTargetVersion returns the version the group provided is being updated
+// to: its channel's package version, or the newest package matching the
+// channel's version constraint, or the version the group is pinned at if that
+// is older.
+func (api *API) groupTargetVersion(group *Group) (string, error) {
+	if group.Channel == nil {
+		return "", ErrNoPackageFound
+	}
+	pkg := group.Channel.Package
+	if group.Channel.VersionConstraint.String != "" {
+		var err error
+		if pkg, err = api.resolveChannelPackage(group.Channel); err != nil {
+			return "", err
+		}
+	}
+	if pkg == nil {
+		return "", ErrNoPackageFound
+	}
+	version := pkg.Version
+	if pinnedSemver, pinned := groupPinnedSemver(group); pinned {
+		if packageSemver, err := semver.Make(version); err == nil && pinnedSemver.LT(packageSemver) {
+			version = group.PolicyPinnedVersion.String
+		}
+	}
+	return version, nil
+}
+
+// GetGroupRolloutProgress returns the progress of the rollout of the version
+// the group provided is being updated to, as returned by groupTargetVersion.
+// Only the instances
+// that checked for updates within activeWithin are counted, or within the
+// API's default window when it's zero.
+func (api *API) GetGroupRolloutProgress(groupID string, activeWithin time.Duration) (*RolloutProgress, error) {
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	version, err := api.groupTargetVersion(group)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := RolloutProgress{Version: version}
+	query := fmt.Sprintf(`
+	SELECT
+		count(*) total,
+		coalesce(sum(case when ia.version = $2 then 1 else 0 end), 0) updated,
+		coalesce(sum(case when ia.version <> $2 AND ia.last_update_version = $2 AND EXISTS (
+			SELECT 1
+			FROM event e
+			JOIN event_type et ON et.id = e.event_type_id
+			WHERE e.instance_id = ia.instance_id AND e.application_id = ia.application_id
+				AND et.result = %d AND e.created_ts >= ia.last_update_granted_ts
+		) then 1 else 0 end), 0) failed
+	FROM instance_application ia
+	WHERE ia.group_id = $1 AND ia.last_check_for_updates > now() at time zone 'utc' - interval '%s' AND %s`,
+		ResultFailed, api.activeInterval(activeWithin), ignoreFakeInstanceCondition("ia.instance_id"))
+	if err := api.readDB().QueryRowx(query, groupID, version).StructScan(&progress); err != nil {
+		return nil, err
+	}
+
+	progress.Pending = progress.Total - progress.Updated - progress.Failed
+	if progress.Total > 0 {
+		progress.UpdatedPercentage = float64(progress.Updated) * 100 / float64(progress.Total)
+		progress.FailedPercentage = float64(progress.Failed) * 100 / float64(progress.Total)
+		progress.PendingPercentage = float64(progress.Pending) * 100 / float64(progress.Total)
+	}
+
+	return &progress, nil
+}
+
+// overflowTimeToUpdateBucket is the key used in the time-to-update
+// distribution for the updates that took longer than the largest bucket.
+const overflowTimeToUpdateBucket = "+Inf"
+
+// GetTimeToUpdateDistribution returns a histogram of the time instances in the
+// given group took from being granted an update to the version provided until
+// completing it. The buckets provided are upper bounds: every update is
+// counted in the smallest bucket it fits in, keyed by the bucket's duration
+// string, and updates taking longer than all buckets are counted under "+Inf".
+func (api *API) GetTimeToUpdateDistribution(groupID, version string, buckets []time.Duration) (map[string]int, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
+	sortedBuckets := make([]time.Duration, len(buckets))
+	copy(sortedBuckets, buckets)
+	sort.Slice(sortedBuckets, func(i, j int) bool { return sortedBuckets[i] < sortedBuckets[j] })
+
+	distribution := make(map[string]int, len(sortedBuckets)+1)
+	for _, bucket := range sortedBuckets {
+		distribution[bucket.String()] = 0
+	}
+	distribution[overflowTimeToUpdateBucket] = 0
+
+	granted := goqu.From("instance_status_history").
+		Select("instance_id", goqu.MAX("created_ts").As("ts")).
+		Where(
+			goqu.C("group_id").Eq(groupID),
+			goqu.C("version").Eq(version),
+			goqu.C("status").Eq(InstanceStatusUpdateGranted),
+		).
+		GroupBy("instance_id")
+	completed := goqu.From("instance_status_history").
+		Select(goqu.MIN("created_ts").As("ts")).
+		Where(
+			goqu.C("group_id").Eq(groupID),
+			goqu.C("version").Eq(version),
+			goqu.C("status").Eq(InstanceStatusComplete),
+			goqu.C("instance_id").Eq(goqu.I("granted.instance_id")),
+			goqu.C("created_ts").Gte(goqu.I("granted.ts")),
+		)
+	query, _, err := goqu.From(granted.As("granted")).
+		Join(goqu.Lateral(completed).As("completed"), goqu.On(goqu.I("completed.ts").IsNotNull())).
+		Select(goqu.L("extract(epoch from (completed.ts - granted.ts))").As("seconds")).
+		Where(goqu.L(ignoreFakeInstanceCondition("granted.instance_id"))).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seconds float64
+		if err := rows.Scan(&seconds); err != nil {
+			return nil, err
+		}
+		timeToUpdate := time.Duration(seconds * float64(time.Second))
+		key := overflowTimeToUpdateBucket
+		for _, bucket := range sortedBuckets {
+			if timeToUpdate <= bucket {
+				key = bucket.String()
+				break
+			}
+		}
+		distribution[key]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return distribution, nil
+}
+
 func durationCodeToPostgresTimings(code durationCode) (postgresDuration, postgresInterval, error) {
 	switch code {
 	case thirtyDays:
@@ -602,13 +1464,16 @@ func durationParamToPostgresTimings(duration durationParam) (postgresDuration, p
 }
 
 func (api *API) GetGroupVersionCountTimeline(groupID string, duration string) (map[time.Time](VersionCountMap), bool, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, false, err
+	}
 	cacheKey := groupDurationCacheKey{GroupID: groupID, Duration: duration}
 
 	cachedGroupVersionCountLock.RLock()
 	val, ok := cachedGroupVersionCount[cacheKey]
 	cachedGroupVersionCountLock.RUnlock()
 	if ok {
-		if time.Since(val.storedAt) < cachedGroupVersionCountLifespan {
+		if api.clock.Now().Sub(val.storedAt) < cachedGroupVersionCountLifespan {
 			logger.Debug().Str("cacheStatus", "HIT").Str("groupID", groupID).Str("duration", duration).Msg("GetGroupVersionCountTimeline")
 			return val.data, true, nil
 		}
@@ -632,7 +1497,7 @@ func (api *API) GetGroupVersionCountTimeline(groupID string, duration string) (m
 		return nil, false, err
 	}
 	ids := []string{}
-	err = api.db.QueryRowx(instanceQuery).Scan(pq.Array(&ids))
+	err = api.readDB().QueryRowx(instanceQuery).Scan(pq.Array(&ids))
 	if err != nil {
 		return nil, false, err
 	}
@@ -667,7 +1532,7 @@ func (api *API) GetGroupVersionCountTimeline(groupID string, duration string) (m
 	`, durationString, interval, ignoreFakeInstanceCondition("instance_id"),
 		ignoreFakeInstanceCondition("instance_Id"), values, deadInstanceTimeSpan)
 
-	rows, err := api.db.Queryx(query, groupID)
+	rows, err := api.readDB().Queryx(query, groupID)
 	if err != nil {
 		return nil, false, err
 	}
@@ -724,9 +1589,9 @@ func (api *API) GetGroupVersionCountTimeline(groupID string, duration string) (m
 		cachedGroupVersionCountLock.Lock()
 		defer cachedGroupVersionCountLock.Unlock()
 		val, ok := cachedGroupVersionCount[cacheKey]
-		if !ok || time.Since(val.storedAt) >= cachedGroupVersionCountLifespan {
+		if !ok || api.clock.Now().Sub(val.storedAt) >= cachedGroupVersionCountLifespan {
 			logger.Debug().Str("cacheStatus", "SET").Str("groupID", groupID).Str("duration", duration).Msg("GetGroupVersionCountTimeline")
-			cachedGroupVersionCount[cacheKey] = groupVersionCountCache{timelineCount, time.Now()}
+			cachedGroupVersionCount[cacheKey] = groupVersionCountCache{timelineCount, api.clock.Now()}
 		}
 	}()
 
@@ -734,6 +1599,9 @@ func (api *API) GetGroupVersionCountTimeline(groupID string, duration string) (m
 }
 
 func (api *API) GetGroupStatusCountTimeline(groupID string, duration string) (map[time.Time](map[int](VersionCountMap)), error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
 	var timelineEntry []StatusVersionCountTimelineEntry
 	durationString, interval, err := durationParamToPostgresTimings(durationParam(duration))
 	if err != nil {
@@ -756,7 +1624,7 @@ func (api *API) GetGroupStatusCountTimeline(groupID string, duration string) (ma
 	GROUP BY 1,2,3
 	ORDER BY ts DESC;
 	`, durationString, interval, ignoreFakeInstanceCondition("instance_id"))
-	rows, err := api.db.Queryx(query, groupID)
+	rows, err := api.readDB().Queryx(query, groupID)
 	if err != nil {
 		return nil, err
 	}
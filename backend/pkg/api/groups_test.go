@@ -1,11 +1,13 @@
 package api
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -133,6 +135,12 @@ func TestGetGroup(t *testing.T) {
 
 	_, err = a.GetGroup(uuid.New().String())
 	assert.Error(t, err, "Trying to get non existent group.")
+
+	appID, err := a.GetGroupApplicationID(tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tApp.ID, appID)
+	_, err = a.GetGroupApplicationID(uuid.New().String())
+	assert.Equal(t, ErrNotFound, err)
 }
 
 func TestGetGroups(t *testing.T) {
@@ -179,7 +187,7 @@ func TestGetGroupsFiltered(t *testing.T) {
 		stats, err := a.GetGroupInstancesStats(g.ID, testDuration)
 		assert.NoError(t, err)
 		assert.Equal(t, 1, stats.Total)
-		versionBreakdown, vbErr := a.GetGroupVersionBreakdown(g.ID)
+		versionBreakdown, vbErr := a.GetGroupVersionBreakdown(g.ID, 0)
 		assert.NoError(t, vbErr)
 		if assert.Len(t, versionBreakdown, 1) {
 			vb := versionBreakdown[0]
@@ -319,3 +327,323 @@ func TestGetStatusCountTimeline(t *testing.T) {
 	// for 30d we generate timestamp after each 3days so total timeline should have 11 timestamps
 	assert.Equal(t, len(statusTimelineMap), 11)
 }
+
+func TestEstimateRolloutBandwidth(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", Size: null.StringFrom("1000"), ApplicationID: tApp.ID})
+	tPkgNoSize, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tChannelNoSize, _ := a.AddChannel(&Channel{Name: "test_channel2", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgNoSize.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroupNoSize, _ := a.AddGroup(&Group{Name: "test_group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannelNoSize.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroupNoChannel, _ := a.AddGroup(&Group{Name: "test_group3", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "11.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.4", "12.1.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance("{"+uuid.New().String()+"}", "", "10.0.0.5", "12.0.0", tApp.ID, tGroup.ID)
+
+	estimate, err := a.EstimateRolloutBandwidth(tGroup.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3000), estimate)
+
+	_, err = a.EstimateRolloutBandwidth(tGroupNoSize.ID, 0)
+	assert.Equal(t, ErrInvalidPackageSize, err)
+
+	_, err = a.EstimateRolloutBandwidth(tGroupNoChannel.ID, 0)
+	assert.Equal(t, ErrNoPackageFound, err)
+}
+
+func TestGetGroupRolloutProgress(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tGroupNoChannel, _ := a.AddGroup(&Group{Name: "test_group2", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "12.1.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	failedInstanceID := uuid.New().String()
+	_, err := a.GetUpdatePackage(failedInstanceID, "", "10.0.0.4", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, a.RegisterEvent(failedInstanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "", "1"))
+
+	progress, err := a.GetGroupRolloutProgress(tGroup.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "12.1.0", progress.Version)
+	assert.Equal(t, 4, progress.Total)
+	assert.Equal(t, 2, progress.Updated)
+	assert.Equal(t, 1, progress.Failed)
+	assert.Equal(t, 1, progress.Pending)
+	assert.Equal(t, 50.0, progress.UpdatedPercentage)
+	assert.Equal(t, 25.0, progress.FailedPercentage)
+	assert.Equal(t, 25.0, progress.PendingPercentage)
+
+	_, err = a.GetGroupRolloutProgress(tGroupNoChannel.ID, 0)
+	assert.Equal(t, ErrNoPackageFound, err)
+}
+
+func TestGetTimeToUpdateDistribution(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	grantedTs := a.nowUTC().Add(-3 * time.Hour)
+	completionDelays := []time.Duration{30 * time.Second, 45 * time.Second, 3 * time.Minute, 20 * time.Minute, 2 * time.Hour}
+	insertQuery := "INSERT INTO instance_status_history (status, version, created_ts, instance_id, application_id, group_id) VALUES ($1, $2, $3, $4, $5, $6)"
+	for i, delay := range completionDelays {
+		instance, _ := a.RegisterInstance(uuid.New().String(), "", fmt.Sprintf("10.0.0.%d", i+1), "12.0.0", tApp.ID, tGroup.ID)
+		_, err := a.db.Exec(insertQuery, InstanceStatusUpdateGranted, tPkg.Version, grantedTs, instance.ID, tApp.ID, tGroup.ID)
+		assert.NoError(t, err)
+		_, err = a.db.Exec(insertQuery, InstanceStatusComplete, tPkg.Version, grantedTs.Add(delay), instance.ID, tApp.ID, tGroup.ID)
+		assert.NoError(t, err)
+	}
+	// An update that is still in progress isn't part of the distribution.
+	instance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.10", "12.0.0", tApp.ID, tGroup.ID)
+	_, err := a.db.Exec(insertQuery, InstanceStatusUpdateGranted, tPkg.Version, grantedTs, instance.ID, tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	distribution, err := a.GetTimeToUpdateDistribution(tGroup.ID, tPkg.Version, []time.Duration{time.Hour, time.Minute, 5 * time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"1m0s":   2,
+		"5m0s":   1,
+		"1h0m0s": 1,
+		"+Inf":   1,
+	}, distribution)
+
+	distribution, err = a.GetTimeToUpdateDistribution(tGroup.ID, "1.0.0", []time.Duration{time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"1m0s": 0, "+Inf": 0}, distribution)
+}
+
+func TestGetVersionSpread(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMaxVersionSpread: 2})
+
+	_, _, spread, err := a.GetVersionSpread(tGroup.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, spread)
+
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "10.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "11.2.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "11.2.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(uuid.New().String(), "", "10.0.0.4", "9.5.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance("{"+uuid.New().String()+"}", "", "10.0.0.5", "1.0.0", tApp.ID, tGroup.ID)
+
+	oldest, newest, spread, err := a.GetVersionSpread(tGroup.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "9.5.0", oldest)
+	assert.Equal(t, "11.2.0", newest)
+	assert.Equal(t, 3, spread)
+
+	assert.False(t, a.hasRecentActivity(activityVersionSpreadExceeded, ActivityQueryParams{GroupID: tGroup.ID}))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.6", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.True(t, a.hasRecentActivity(activityVersionSpreadExceeded, ActivityQueryParams{GroupID: tGroup.ID}))
+}
+
+func TestPauseGroup(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	instance1ID := uuid.New().String()
+	instance2ID := uuid.New().String()
+
+	_, err := a.GetUpdatePackage(instance1ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	err = a.RegisterEvent(instance1ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", "")
+	assert.NoError(t, err)
+
+	err = a.PauseGroup(tGroup.ID)
+	assert.NoError(t, err)
+
+	group, err := a.GetGroup(tGroup.ID)
+	assert.NoError(t, err)
+	assert.True(t, group.PolicyPaused)
+
+	_, err = a.GetUpdatePackage(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrGroupPaused, err)
+
+	// The instance already downloading the update isn't affected.
+	instance1, err := a.GetInstance(instance1ID, tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, InstanceStatusDownloading, int(instance1.Application.Status.Int64))
+
+	err = a.ResumeGroup(tGroup.ID)
+	assert.NoError(t, err)
+
+	_, err = a.GetUpdatePackage(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	err = a.PauseGroup(uuid.New().String())
+	assert.Equal(t, ErrNoRowsAffected, err)
+}
+
+func TestCloneGroup(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, err := a.AddGroup(&Group{
+		Name:                            "production",
+		Description:                     "production servers",
+		ApplicationID:                   tApp.ID,
+		ChannelID:                       null.StringFrom(tChannel.ID),
+		PolicyUpdatesEnabled:            true,
+		PolicySafeMode:                  true,
+		PolicyOfficeHours:               true,
+		PolicyTimezone:                  null.StringFrom("Europe/Berlin"),
+		PolicyPeriodInterval:            "30 minutes",
+		PolicyMaxUpdatesPerPeriod:       5,
+		PolicyUpdateTimeout:             "90 minutes",
+		PolicyUpdateTimeoutAction:       UpdateTimeoutActionReoffer,
+		PolicyMinHealthyInstances:       3,
+		PolicyMaxVersionSpread:          2,
+		PolicyRolloutPercentage:         null.IntFrom(25),
+		PolicyMaxConcurrentDownloads:    4,
+		PolicyRollbackFailurePercentage: null.IntFrom(10),
+		PolicyPinnedVersion:             null.StringFrom("12.0.0"),
+		PolicyUpdateWindows:             []UpdateWindow{{Weekday: time.Saturday, StartTime: "02:00", EndTime: "06:00"}},
+		Track:                           "production",
+	})
+	require.NoError(t, err)
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	require.NoError(t, a.PauseGroup(tGroup.ID))
+
+	_, err = a.CloneGroup(tGroup.ID, "production")
+	assert.Equal(t, ErrGroupNameExists, err)
+
+	_, err = a.CloneGroup(uuid.New().String(), "canary")
+	assert.Error(t, err, "Trying to clone non existent group.")
+
+	clone, err := a.CloneGroup(tGroup.ID, "canary")
+	require.NoError(t, err)
+
+	source, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, source.ID, clone.ID)
+	assert.Equal(t, "canary", clone.Name)
+	assert.Equal(t, clone.ID, clone.Track)
+	assert.False(t, clone.PolicyPaused)
+	assert.Equal(t, source.Description, clone.Description)
+	assert.Equal(t, source.ApplicationID, clone.ApplicationID)
+	assert.Equal(t, source.ChannelID, clone.ChannelID)
+	assert.Equal(t, tPkg.Version, clone.Channel.Package.Version)
+	assert.Equal(t, source.PolicyUpdatesEnabled, clone.PolicyUpdatesEnabled)
+	assert.Equal(t, source.PolicySafeMode, clone.PolicySafeMode)
+	assert.Equal(t, source.PolicyOfficeHours, clone.PolicyOfficeHours)
+	assert.Equal(t, source.PolicyTimezone, clone.PolicyTimezone)
+	assert.Equal(t, source.PolicyPeriodInterval, clone.PolicyPeriodInterval)
+	assert.Equal(t, source.PolicyMaxUpdatesPerPeriod, clone.PolicyMaxUpdatesPerPeriod)
+	assert.Equal(t, source.PolicyUpdateTimeout, clone.PolicyUpdateTimeout)
+	assert.Equal(t, source.PolicyUpdateTimeoutAction, clone.PolicyUpdateTimeoutAction)
+	assert.Equal(t, source.PolicyMinHealthyInstances, clone.PolicyMinHealthyInstances)
+	assert.Equal(t, source.PolicyMaxVersionSpread, clone.PolicyMaxVersionSpread)
+	assert.Equal(t, source.PolicyRolloutPercentage, clone.PolicyRolloutPercentage)
+	assert.Equal(t, source.PolicyMaxConcurrentDownloads, clone.PolicyMaxConcurrentDownloads)
+	assert.Equal(t, source.PolicyRollbackFailurePercentage, clone.PolicyRollbackFailurePercentage)
+	assert.Equal(t, source.PolicyPinnedVersion, clone.PolicyPinnedVersion)
+	assert.Equal(t, source.PolicyUpdateWindows, clone.PolicyUpdateWindows)
+
+	instances, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: clone.ID, Page: 1, PerPage: 10}, testDuration)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), instances.TotalInstances)
+}
+
+func TestActiveWithin(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionActiveWithin(48*time.Hour))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Size: null.StringFrom("100")})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	backdate := func(instanceID string, age time.Duration) {
+		_, err := a.db.Exec("UPDATE instance_application SET last_check_for_updates = $1 WHERE instance_id = $2 AND application_id = $3", time.Now().UTC().Add(-age), instanceID, tApp.ID)
+		require.NoError(t, err)
+	}
+	day := 24 * time.Hour
+	ages := map[string]time.Duration{
+		"11.0.0": 30 * time.Minute,
+		"11.1.0": 12 * time.Hour,
+		"11.2.0": 36 * time.Hour,
+		"11.3.0": 5 * day,
+		"11.4.0": 20 * day,
+	}
+	for version, age := range ages {
+		instance, err := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", version, tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		backdate(instance.ID, age)
+	}
+
+	testCases := []struct {
+		activeWithin time.Duration
+		expected     int
+	}{
+		{time.Hour, 1},
+		{day, 2},
+		{0, 3},
+		{7 * day, 4},
+		{30 * day, 5},
+	}
+	for _, tc := range testCases {
+		versionBreakdown, err := a.GetGroupVersionBreakdown(tGroup.ID, tc.activeWithin)
+		require.NoError(t, err)
+		assert.Len(t, versionBreakdown, tc.expected, "active within %s", tc.activeWithin)
+
+		_, _, spread, err := a.GetVersionSpread(tGroup.ID, tc.activeWithin)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, spread, "active within %s", tc.activeWithin)
+
+		progress, err := a.GetGroupRolloutProgress(tGroup.ID, tc.activeWithin)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, progress.Total, "active within %s", tc.activeWithin)
+
+		estimate, err := a.EstimateRolloutBandwidth(tGroup.ID, tc.activeWithin)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100*tc.expected), estimate, "active within %s", tc.activeWithin)
+
+		stats, err := a.GetInstanceStatsByArch(tApp.ID, tc.activeWithin)
+		require.NoError(t, err)
+		require.Len(t, stats, 1)
+		assert.Equal(t, tc.expected, stats[0].Instances, "active within %s", tc.activeWithin)
+	}
+
+	_, err = NewForTest(OptionActiveWithin(0))
+	assert.Equal(t, ErrInvalidActiveWithin, err)
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"sort"
 	"time"
 
 	"github.com/blang/semver/v4"
+	"gopkg.in/guregu/null.v4"
 )
 
 const (
@@ -47,6 +49,65 @@ func isValidSemver(version string) bool {
 	return true
 }
 
+// MaxInstanceVersionLength is the length of the longest version reported by
+// an instance that can be stored.
+const MaxInstanceVersionLength = 255
+
+// unknownInstanceSemver is the version used in the update decisions of the
+// instances reporting a version which isn't valid semver, which makes them
+// older than any package. Their reported version is still the one stored.
+var unknownInstanceSemver = semver.Version{}
+
+// isValidInstanceVersion checks if the provided string can be stored as the
+// version reported by an instance. It doesn't need to be valid semver.
+func isValidInstanceVersion(version string) bool {
+	return version != "" && len(version) <= MaxInstanceVersionLength
+}
+
+// parseInstanceVersion returns the semver version the update decisions use
+// for the version reported by an instance provided, unknownInstanceSemver if
+// it isn't valid semver.
+func parseInstanceVersion(version string) semver.Version {
+	instanceSemver, err := semver.Make(version)
+	if err != nil {
+		return unknownInstanceSemver
+	}
+	return instanceSemver
+}
+
+// sameVersion checks if the versions provided are the same according to the
+// semver precedence rules, which ignore the build metadata, so 640.0.0 and
+// 640.0.0+build.1 are the same version. Versions that aren't valid semver
+// are only the same when they are equal strings.
+func sameVersion(a, b string) bool {
+	aSemver, aErr := semver.Make(a)
+	bSemver, bErr := semver.Make(b)
+	if aErr != nil || bErr != nil {
+		return a == b
+	}
+	return aSemver.EQ(bSemver)
+}
+
+// sortPackagesByVersion sorts the packages provided from the newest version
+// to the oldest one following the semver precedence rules, so pre-releases
+// come after the release they precede, like 640.0.0 before 640.0.0-rc1.
+// Packages whose version isn't valid semver are sorted last.
+func sortPackagesByVersion(pkgs []*Package) {
+	versions := make(map[*Package]semver.Version, len(pkgs))
+	for _, pkg := range pkgs {
+		versions[pkg], _ = semver.Make(pkg.Version)
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return versions[pkgs[i]].GT(versions[pkgs[j]])
+	})
+}
+
+// isRolloutPercentageValid checks if the provided rollout percentage is either
+// unset or between 0 and 100.
+func isRolloutPercentageValid(percentage null.Int) bool {
+	return !percentage.Valid || (percentage.Int64 >= 0 && percentage.Int64 <= 100)
+}
+
 func sqlPaginate(page, perPage uint64) (uint, uint) {
 	return uint(perPage), uint(page-1) * uint(perPage)
 }
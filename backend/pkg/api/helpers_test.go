@@ -0,0 +1,30 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameVersion(t *testing.T) {
+	assert.True(t, sameVersion("640.0.0", "640.0.0"))
+	assert.True(t, sameVersion("640.0.0", "640.0.0+build.1"), "Build metadata is ignored.")
+	assert.False(t, sameVersion("640.0.0", "640.0.0-rc1"))
+	assert.False(t, sameVersion("640.0.0-rc1", "640.0.0-rc2"))
+	assert.True(t, sameVersion("not-semver", "not-semver"))
+	assert.False(t, sameVersion("not-semver", "0.0.0"))
+}
+
+func TestSortPackagesByVersion(t *testing.T) {
+	var pkgs []*Package
+	for _, version := range []string{"640.0.0-rc1", "not-semver", "640.0.0+build.1", "640.0.0-rc2", "639.0.0", "640.0.0-beta", "640.0.1-alpha"} {
+		pkgs = append(pkgs, &Package{Version: version})
+	}
+	sortPackagesByVersion(pkgs)
+
+	var versions []string
+	for _, pkg := range pkgs {
+		versions = append(versions, pkg.Version)
+	}
+	assert.Equal(t, []string{"640.0.1-alpha", "640.0.0+build.1", "640.0.0-rc2", "640.0.0-rc1", "640.0.0-beta", "639.0.0", "not-semver"}, versions)
+}
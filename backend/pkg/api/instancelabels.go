@@ -0,0 +1,142 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	// maxInstanceLabels is the maximum number of labels an instance can have.
+	maxInstanceLabels = 32
+
+	// maxInstanceLabelKeyLength and maxInstanceLabelValueLength are the
+	// maximum lengths of the keys and values of the instance labels.
+	maxInstanceLabelKeyLength   = 64
+	maxInstanceLabelValueLength = 256
+)
+
+var (
+	// ErrInvalidInstanceLabels error indicates that the labels reported by an
+	// instance are too many, or have empty or too long keys or values.
+	ErrInvalidInstanceLabels = newValidationError("nebraska: invalid instance labels")
+)
+
+// InstanceLabels represents the key/value attributes an instance is tagged
+// with, like its region or role, used to slice the instances of an
+// application.
+type InstanceLabels map[string]string
+
+// Scan implements the sql.Scanner interface.
+func (l *InstanceLabels) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, l)
+	case string:
+		return json.Unmarshal([]byte(src), l)
+	case nil:
+		*l = nil
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T to InstanceLabels", src)
+}
+
+// Value implements the driver.Valuer interface.
+func (l InstanceLabels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// validateInstanceLabels checks that the labels provided are not too many and
+// that their keys and values are not empty or too long.
+func validateInstanceLabels(labels InstanceLabels) error {
+	if len(labels) > maxInstanceLabels {
+		return ErrInvalidInstanceLabels
+	}
+	for key, value := range labels {
+		if key == "" || len(key) > maxInstanceLabelKeyLength || value == "" || len(value) > maxInstanceLabelValueLength {
+			return ErrInvalidInstanceLabels
+		}
+	}
+	return nil
+}
+
+// UpdateInstanceLabels replaces the labels of the instance provided, if they
+// changed since they were last reported.
+func (api *API) UpdateInstanceLabels(instanceID string, labels InstanceLabels) error {
+	if err := validateInstanceLabels(labels); err != nil {
+		return err
+	}
+	if err := api.checkInstanceTeam(instanceID); err != nil {
+		return err
+	}
+	value, err := labels.Value()
+	if err != nil {
+		return err
+	}
+	query, _, err := goqu.Update("instance").
+		Set(goqu.Record{"labels": goqu.L("?::jsonb", value)}).
+		Where(goqu.C("id").Eq(instanceID), goqu.L("labels <> ?::jsonb", value)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// GetInstancesByLabel returns the instances of the application provided that
+// are tagged with the given label, most recently seen first.
+func (api *API) GetInstancesByLabel(appID, key, value string) ([]*Instance, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	label, err := json.Marshal(InstanceLabels{key: value})
+	if err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select("i.id", "i.ip", "i.created_ts", "i.alias", "i.arch", "i.board", "i.platform", "i.region", "i.labels", "i.note", "ia.application_id", "ia.group_id", "ia.version", "ia.created_ts",
+			"ia.status", "ia.last_check_for_updates", "ia.last_update_granted_ts", "ia.last_update_version", "ia.update_in_progress", "ia.quarantined").
+		Where(
+			goqu.I("ia.application_id").Eq(appID),
+			goqu.L("i.labels @> ?::jsonb", string(label)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		Order(goqu.I("ia.last_check_for_updates").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	instances := []*Instance{}
+	for rows.Next() {
+		var instance Instance
+		app := &instance.Application
+		err := rows.Scan(&instance.ID, &instance.IP, &instance.CreatedTs, &instance.Alias, &instance.Arch, &instance.Board, &instance.Platform, &instance.Region, &instance.Labels, &instance.Note, &app.ApplicationID, &app.GroupID, &app.Version, &app.CreatedTs,
+			&app.Status, &app.LastCheckForUpdates, &app.LastUpdateGrantedTs, &app.LastUpdateVersion, &app.UpdateInProgress, &app.Quarantined)
+		if err != nil {
+			return nil, err
+		}
+		app.InstanceID = instance.ID
+		instances = append(instances, &instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
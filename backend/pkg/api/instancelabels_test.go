@@ -0,0 +1,53 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateInstanceLabels(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance("instance1", "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	tInstance2, _ := a.RegisterInstance("instance2", "", "10.0.0.2", "1.0.0", tApp.ID, tGroup.ID)
+
+	assert.Equal(t, ErrInvalidInstanceLabels, a.UpdateInstanceLabels(tInstance.ID, InstanceLabels{"region": ""}))
+	assert.Equal(t, ErrInvalidInstanceLabels, a.UpdateInstanceLabels(tInstance.ID, InstanceLabels{strings.Repeat("k", maxInstanceLabelKeyLength+1): "value"}))
+
+	require.NoError(t, a.UpdateInstanceLabels(tInstance.ID, InstanceLabels{"region": "eu-west", "role": "worker"}))
+	require.NoError(t, a.UpdateInstanceLabels(tInstance2.ID, InstanceLabels{"region": "us-east", "role": "worker"}))
+
+	instance, err := a.GetInstance(tInstance.ID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InstanceLabels{"region": "eu-west", "role": "worker"}, instance.Labels)
+
+	instances, err := a.GetInstancesByLabel(tApp.ID, "region", "eu-west")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, tInstance.ID, instances[0].ID)
+	assert.Equal(t, tApp.ID, instances[0].Application.ApplicationID)
+
+	instances, err = a.GetInstancesByLabel(tApp.ID, "role", "worker")
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+
+	instances, err = a.GetInstancesByLabel(tApp.ID, "region", "ap-south")
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+
+	// Labels are replaced as a whole.
+	require.NoError(t, a.UpdateInstanceLabels(tInstance.ID, InstanceLabels{"region": "us-east"}))
+	instance, err = a.GetInstance(tInstance.ID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, InstanceLabels{"region": "us-east"}, instance.Labels)
+	instances, err = a.GetInstancesByLabel(tApp.ID, "region", "us-east")
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+}
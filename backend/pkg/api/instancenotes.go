@@ -0,0 +1,63 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+const maxInstanceNoteLength = 1024
+
+// ErrInvalidInstanceNote error indicates that the note provided for an
+// instance is too long.
+var ErrInvalidInstanceNote = newValidationError("nebraska: invalid instance note")
+
+// SetInstanceNote sets the note operators attached to the instance provided,
+// like "known flaky hardware". An empty note clears it. Notes are only
+// informational, they don't affect the updates the instance is offered.
+func (api *API) SetInstanceNote(instanceID, note string) error {
+	if len(note) > maxInstanceNoteLength {
+		return ErrInvalidInstanceNote
+	}
+	if err := api.checkInstanceTeam(instanceID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Update("instance").
+		Set(goqu.Record{"note": null.NewString(note, note != "")}).
+		Where(goqu.C("id").Eq(instanceID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
+
+// GetInstanceNote returns the note operators attached to the instance
+// provided, which is null when the instance has no note.
+func (api *API) GetInstanceNote(instanceID string) (null.String, error) {
+	if err := api.checkInstanceTeam(instanceID); err != nil {
+		return null.String{}, err
+	}
+	query, _, err := goqu.From("instance").
+		Select("note").
+		Where(goqu.C("id").Eq(instanceID)).
+		ToSQL()
+	if err != nil {
+		return null.String{}, err
+	}
+	var note null.String
+	if err := api.readDB().QueryRow(query).Scan(&note); err != nil {
+		return null.String{}, err
+	}
+	return note, nil
+}
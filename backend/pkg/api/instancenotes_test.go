@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestSetInstanceNote(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	instanceID := uuid.New().String()
+
+	_, err := a.RegisterInstance(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	instance, err := a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Note.Valid)
+
+	require.NoError(t, a.SetInstanceNote(instanceID, "known flaky hardware"))
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("known flaky hardware"), instance.Note)
+
+	// The note is kept when the instance checks in again.
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, a.SetInstanceNote(instanceID, "disk replaced"))
+	instances, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID}, testDuration)
+	require.NoError(t, err)
+	require.Len(t, instances.Instances, 1)
+	assert.Equal(t, null.StringFrom("disk replaced"), instances.Instances[0].Note)
+
+	searched, _, err := a.SearchInstances(tApp.ID, InstanceSearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, searched, 1)
+	assert.Equal(t, null.StringFrom("disk replaced"), searched[0].Note)
+
+	note, err := a.GetInstanceNote(instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("disk replaced"), note)
+
+	require.NoError(t, a.SetInstanceNote(instanceID, ""))
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Note.Valid)
+
+	note, err = a.GetInstanceNote(instanceID)
+	require.NoError(t, err)
+	assert.False(t, note.Valid)
+	_, err = a.GetInstanceNote(uuid.New().String())
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.Equal(t, ErrInvalidInstanceNote, a.SetInstanceNote(instanceID, strings.Repeat("a", maxInstanceNoteLength+1)))
+	assert.Equal(t, ErrNoRowsAffected, a.SetInstanceNote(uuid.New().String(), "note"))
+}
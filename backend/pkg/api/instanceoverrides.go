@@ -0,0 +1,71 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// SetInstancePackageOverride makes the instance provided be offered the
+// package provided on its next update checks for the package's application,
+// regardless of its group's channel and rollout policy. The override is
+// cleared once the instance reports the package's version.
+func (api *API) SetInstancePackageOverride(instanceID, packageID string) error {
+	pkg, err := api.GetPackage(packageID)
+	if err != nil {
+		return err
+	}
+	query, _, err := goqu.Insert("instance_package_override").
+		Cols("instance_id", "application_id", "package_id", "created_ts").
+		Vals(goqu.Vals{instanceID, pkg.ApplicationID, pkg.ID, api.nowUTC()}).
+		OnConflict(goqu.DoUpdate("instance_id, application_id", goqu.Record{
+			"package_id": pkg.ID,
+			"created_ts": api.nowUTC(),
+		})).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// ClearInstancePackageOverride removes the package override of the instance
+// provided for the given application, if any.
+func (api *API) ClearInstancePackageOverride(instanceID, appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("instance_package_override").
+		Where(goqu.C("instance_id").Eq(instanceID), goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// GetInstancePackageOverride returns the package the instance provided is
+// forced to for the given application, or nil if there is no override.
+func (api *API) GetInstancePackageOverride(instanceID, appID string) (*Package, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("instance_package_override").
+		Select("package_id").
+		Where(goqu.C("instance_id").Eq(instanceID), goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var packageID string
+	switch err := api.db.QueryRow(query).Scan(&packageID); err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	return api.GetPackage(packageID)
+}
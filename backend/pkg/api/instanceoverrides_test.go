@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetUpdatePackage_InstancePackageOverride(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgDebug, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg-debug", Version: "13.0.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	// Updates are disabled in the group, the override bypasses its policy.
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+
+	require.NoError(t, a.SetInstancePackageOverride(tInstance.ID, tPkgDebug.ID))
+
+	override, err := a.GetInstancePackageOverride(tInstance.ID, tApp.ID)
+	require.NoError(t, err)
+	require.NotNil(t, override)
+	assert.Equal(t, tPkgDebug.ID, override.ID)
+
+	pkg, reason, err := a.GetUpdatePackageWithReason(tInstance.ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateReasonPackageOverride, reason)
+	assert.Equal(t, tPkgDebug.ID, pkg.ID, "The override beats the group's channel.")
+
+	instance, err := a.GetInstance(tInstance.ID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("13.0.0"), instance.Application.LastUpdateVersion)
+
+	// Other instances still follow the group's policy.
+	_, reason, err = a.GetUpdatePackageWithReason(tInstance2.ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.Equal(t, ErrUpdatesDisabled, err)
+	assert.Equal(t, UpdateReasonUpdatesDisabled, reason)
+
+	// Once the instance reports the target version the override expires.
+	_, err = a.GetUpdatePackage(tInstance.ID, "", "10.0.0.1", "13.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	override, err = a.GetInstancePackageOverride(tInstance.ID, tApp.ID)
+	require.NoError(t, err)
+	assert.Nil(t, override)
+}
+
+func TestClearInstancePackageOverride(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgDebug, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg-debug", Version: "11.0.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+
+	require.NoError(t, a.SetInstancePackageOverride(tInstance.ID, tPkgDebug.ID))
+
+	// Dry runs don't grant the override.
+	pkg, reason, err := a.PreviewUpdatePackageWithReason(tInstance.ID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID, ArchAll)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateReasonPackageOverride, reason)
+	assert.Equal(t, tPkgDebug.ID, pkg.ID)
+
+	require.NoError(t, a.ClearInstancePackageOverride(tInstance.ID, tApp.ID))
+
+	_, reason, err = a.GetUpdatePackageWithReason(tInstance.ID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	assert.Equal(t, UpdateReasonUpToDate, reason)
+
+	assert.Error(t, a.SetInstancePackageOverride(tInstance.ID, uuid.New().String()), "The package must exist.")
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/doug-martin/goqu/v9"
+)
+
+// isVersionQuarantined checks if instances of the application provided
+// reporting the given version must be quarantined, which happens when the
+// version is newer than any of the application's packages. Versions of
+// applications without packages are never quarantined.
+func (api *API) isVersionQuarantined(appID, version string) (bool, error) {
+	instanceSemver, err := semver.Make(version)
+	if err != nil {
+		return false, nil
+	}
+	query, _, err := goqu.From("package").
+		Select("version").
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+	var versions []string
+	if err := api.db.Select(&versions, query); err != nil {
+		return false, err
+	}
+	known := false
+	for _, v := range versions {
+		packageSemver, err := semver.Make(v)
+		if err != nil {
+			continue
+		}
+		if !packageSemver.LT(instanceSemver) {
+			return false, nil
+		}
+		known = true
+	}
+	return known, nil
+}
+
+// ListQuarantinedInstances returns the instances of the application provided
+// that reported a version newer than any of its packages and haven't been
+// acknowledged by an operator yet, most recently seen first. Quarantined
+// instances don't count towards the groups' rollout percentages.
+func (api *API) ListQuarantinedInstances(appID string) ([]*Instance, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select("i.id", "i.ip", "i.created_ts", "i.alias", "i.arch", "i.board", "i.platform", "i.region", "i.labels", "ia.application_id", "ia.group_id", "ia.version", "ia.created_ts",
+			"ia.status", "ia.last_check_for_updates", "ia.last_update_granted_ts", "ia.last_update_version", "ia.update_in_progress", "ia.quarantined").
+		Where(
+			goqu.I("ia.application_id").Eq(appID),
+			goqu.I("ia.quarantined").IsTrue(),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		Order(goqu.I("ia.last_check_for_updates").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	instances := []*Instance{}
+	for rows.Next() {
+		var instance Instance
+		app := &instance.Application
+		err := rows.Scan(&instance.ID, &instance.IP, &instance.CreatedTs, &instance.Alias, &instance.Arch, &instance.Board, &instance.Platform, &instance.Region, &instance.Labels, &app.ApplicationID, &app.GroupID, &app.Version, &app.CreatedTs,
+			&app.Status, &app.LastCheckForUpdates, &app.LastUpdateGrantedTs, &app.LastUpdateVersion, &app.UpdateInProgress, &app.Quarantined)
+		if err != nil {
+			return nil, err
+		}
+		app.InstanceID = instance.ID
+		instances = append(instances, &instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// AcknowledgeQuarantinedInstance releases the instance provided from
+// quarantine. It won't be quarantined again unless it reports another version
+// newer than any of the application's packages.
+func (api *API) AcknowledgeQuarantinedInstance(instanceID, appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Update("instance_application").
+		Set(goqu.Record{"quarantined": false}).
+		Where(goqu.C("instance_id").Eq(instanceID), goqu.C("application_id").Eq(appID), goqu.C("quarantined").IsTrue()).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// instancesReportHeader is the header row of the instances report.
+var instancesReportHeader = []string{"machine_id", "ip", "version", "last_seen", "status"}
+
+// instanceStatusNames are the names the instances' statuses are reported with
+// in the instances report.
+var instanceStatusNames = map[int]string{
+	InstanceStatusUndefined:     "undefined",
+	InstanceStatusUpdateGranted: "update-granted",
+	InstanceStatusError:         "error",
+	InstanceStatusComplete:      "complete",
+	InstanceStatusInstalled:     "installed",
+	InstanceStatusDownloaded:    "downloaded",
+	InstanceStatusDownloading:   "downloading",
+	InstanceStatusOnHold:        "on-hold",
+}
+
+// instanceReportRow represents the status of an instance as reported in the
+// instances report.
+type instanceReportRow struct {
+	ID                  string    `db:"id"`
+	IP                  string    `db:"ip"`
+	Version             string    `db:"version"`
+	LastCheckForUpdates time.Time `db:"last_check_for_updates"`
+	Status              null.Int  `db:"status"`
+}
+
+// WriteInstancesReport writes to w, as CSV, the status of all the instances
+// matching the criteria provided, in the order requested and ignoring the
+// pagination parameters. Rows are written as they are read from the database,
+// so the instances of large groups are never held in memory at once.
+func (api *API) WriteInstancesReport(w io.Writer, p InstancesQueryParams, duration string) error {
+	if err := api.checkAppTeam(p.ApplicationID); err != nil {
+		return err
+	}
+	dbDuration, _, err := durationParamToPostgresTimings(durationParam(duration))
+	if err != nil {
+		return err
+	}
+	instancesQuery, err := api.instancesQuery(p, dbDuration)
+	if err != nil {
+		return err
+	}
+	query, _, err := instancesQuery.
+		Select(
+			goqu.I("i.id"),
+			goqu.I("i.ip"),
+			goqu.I("instance_application.version"),
+			goqu.I("instance_application.last_check_for_updates"),
+			goqu.I("instance_application.status"),
+		).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	rows, err := api.readDB().Queryx(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(instancesReportHeader); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var row instanceReportRow
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		record := []string{row.ID, row.IP, row.Version, row.LastCheckForUpdates.UTC().Format(time.RFC3339), instanceStatusName(row.Status)}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// instanceStatusName returns the name of the instance status provided, the
+// instances without status yet being reported as undefined.
+func instanceStatusName(status null.Int) string {
+	if !status.Valid {
+		return instanceStatusNames[InstanceStatusUndefined]
+	}
+	if name, ok := instanceStatusNames[int(status.Int64)]; ok {
+		return name
+	}
+	return strconv.FormatInt(status.Int64, 10)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteInstancesReport(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "test_group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	_, err := a.RegisterInstance("instance1", "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance("instance2", "", "10.0.0.2", "1.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance("instance3", "", "10.0.0.3", "1.1.0", tApp.ID, tGroup2.ID)
+	require.NoError(t, err)
+	require.NoError(t, a.updateInstanceStatus("instance2", tApp.ID, InstanceStatusComplete))
+
+	var report bytes.Buffer
+	err = a.WriteInstancesReport(&report, InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: InstancesSortByIP}, testDuration)
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(&report).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3, "The report must only have the header and the instances of the group.")
+	assert.Equal(t, []string{"machine_id", "ip", "version", "last_seen", "status"}, records[0])
+	assert.Equal(t, []string{"instance1", "10.0.0.1", "1.0.0"}, records[1][:3])
+	assert.Equal(t, "undefined", records[1][4])
+	assert.Equal(t, []string{"instance2", "10.0.0.2", "1.1.0"}, records[2][:3])
+	assert.Equal(t, "complete", records[2][4])
+
+	lastSeen, err := time.Parse(time.RFC3339, records[1][3])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), lastSeen, time.Minute)
+
+	err = a.WriteInstancesReport(&bytes.Buffer{}, InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID}, "1y")
+	assert.Error(t, err)
+}
@@ -3,8 +3,12 @@ package api
 import (
 	"database/sql"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/google/uuid"
@@ -46,7 +50,32 @@ const (
 )
 
 const (
-	validityInterval postgresDuration = "1 days"
+	// defaultActiveWithin is the default window in which instances must
+	// have checked for updates to be counted as active in the stats.
+	defaultActiveWithin = 24 * time.Hour
+)
+
+const (
+	// InstancesSortByLastSeen sorts instances by the time they last checked
+	// for updates, most recent first.
+	InstancesSortByLastSeen = "last-seen"
+
+	// InstancesSortByVersion sorts instances by the version they run, in
+	// lexical order.
+	InstancesSortByVersion = "version"
+
+	// InstancesSortByIP sorts instances by their ip.
+	InstancesSortByIP = "ip"
+)
+
+var (
+	// ErrInvalidIPRange indicates that the ip range provided to search for
+	// instances is not valid CIDR notation.
+	ErrInvalidIPRange = newValidationError("nebraska: invalid ip range")
+
+	// ErrInvalidInstancesSortBy indicates that the order requested for the
+	// instances listing is not supported.
+	ErrInvalidInstancesSortBy = newValidationError("nebraska: invalid instances sort by")
 )
 
 // Instance represents an instance running one or more applications for which
@@ -55,8 +84,15 @@ type Instance struct {
 	ID          string              `db:"id" json:"id"`
 	IP          string              `db:"ip" json:"ip"`
 	CreatedTs   time.Time           `db:"created_ts" json:"created_ts"`
+	FirstSeen   time.Time           `db:"first_seen" json:"first_seen"`
 	Application InstanceApplication `db:"application" json:"application,omitempty"`
 	Alias       string              `db:"alias" json:"alias,omitempty"`
+	Arch        Arch                `db:"arch" json:"arch"`
+	Board       string              `db:"board" json:"board"`
+	Platform    string              `db:"platform" json:"platform"`
+	Labels      InstanceLabels      `db:"labels" json:"labels"`
+	Region      null.String         `db:"region" json:"region"`
+	Note        null.String         `db:"note" json:"note"`
 }
 type InstancesWithTotal struct {
 	TotalInstances uint64      `json:"total"`
@@ -67,16 +103,28 @@ type InstancesWithTotal struct {
 // a given instance: current version of the app, last time the instance checked
 // for updates for this app, etc.
 type InstanceApplication struct {
-	InstanceID          string      `db:"instance_id" json:"instance_id,omitempty"`
-	ApplicationID       string      `db:"application_id" json:"application_id"`
-	GroupID             null.String `db:"group_id" json:"group_id"`
-	Version             string      `db:"version" json:"version"`
-	CreatedTs           time.Time   `db:"created_ts" json:"created_ts"`
-	Status              null.Int    `db:"status" json:"status"`
-	LastCheckForUpdates time.Time   `db:"last_check_for_updates" json:"last_check_for_updates"`
-	LastUpdateGrantedTs null.Time   `db:"last_update_granted_ts" json:"last_update_granted_ts"`
-	LastUpdateVersion   null.String `db:"last_update_version" json:"last_update_version"`
-	UpdateInProgress    bool        `db:"update_in_progress" json:"update_in_progress"`
+	InstanceID            string      `db:"instance_id" json:"instance_id,omitempty"`
+	ApplicationID         string      `db:"application_id" json:"application_id"`
+	GroupID               null.String `db:"group_id" json:"group_id"`
+	Version               string      `db:"version" json:"version"`
+	CreatedTs             time.Time   `db:"created_ts" json:"created_ts"`
+	VersionChangedAt      time.Time   `db:"version_changed_at" json:"version_changed_at"`
+	Status                null.Int    `db:"status" json:"status"`
+	LastCheckForUpdates   time.Time   `db:"last_check_for_updates" json:"last_check_for_updates"`
+	LastUpdateGrantedTs   null.Time   `db:"last_update_granted_ts" json:"last_update_granted_ts"`
+	LastUpdateVersion     null.String `db:"last_update_version" json:"last_update_version"`
+	UpdateInProgress      bool        `db:"update_in_progress" json:"update_in_progress"`
+	Quarantined           bool        `db:"quarantined" json:"quarantined"`
+	ObservedUpdateVersion null.String `db:"observed_update_version" json:"observed_update_version"`
+	ObservedUpdateTs      null.Time   `db:"observed_update_ts" json:"observed_update_ts"`
+}
+
+// InstanceArchStats represents the number of instances of an application
+// running on a given architecture and board.
+type InstanceArchStats struct {
+	Arch      Arch   `db:"arch" json:"arch"`
+	Board     string `db:"board" json:"board"`
+	Instances int    `db:"instances" json:"instances"`
 }
 
 // InstanceStatusHistoryEntry represents an entry in the instance status
@@ -101,17 +149,50 @@ type InstancesQueryParams struct {
 	Version       string `json:"version"`
 	Page          uint64 `json:"page"`
 	PerPage       uint64 `json:"perpage"`
+	// Limit and Offset select the instances to return instead of Page and
+	// PerPage when Limit is set.
+	Limit  uint64 `json:"limit"`
+	Offset uint64 `json:"offset"`
+	// SortBy is the order of the instances: InstancesSortByLastSeen (the
+	// default), InstancesSortByVersion or InstancesSortByIP. Instances are
+	// further sorted by id, so the order is stable across pages.
+	SortBy string `json:"sort_by"`
 }
 
-// RegisterInstance registers an instance into Nebraska.
+// InstanceSearchFilter represents the criteria used to search for the
+// instances of an application. Empty fields don't restrict the search.
+type InstanceSearchFilter struct {
+	// GroupID restricts the search to the instances of the given group.
+	GroupID string `json:"group_id"`
+	// Text is matched against the instance id, alias and ip.
+	Text string `json:"text"`
+	// IPRange is a range of ips in CIDR notation, like 10.0.0.0/8.
+	IPRange string `json:"ip_range"`
+	// MinVersion and MaxVersion are the inclusive bounds of the versions
+	// the instances run.
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+	// LastSeen restricts the search to the instances that checked for
+	// updates within the given duration.
+	LastSeen time.Duration `json:"last_seen"`
+	Page     uint64        `json:"page"`
+	PerPage  uint64        `json:"perpage"`
+}
+
+// RegisterInstance registers an instance into Nebraska. The version it
+// reports is stored as is, even if it isn't valid semver, in which case the
+// instance is considered to run a version older than any package.
 func (api *API) RegisterInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string) (*Instance, error) {
-	if !isValidSemver(instanceVersion) {
-		return nil, ErrInvalidSemver
+	if !isValidInstanceVersion(instanceVersion) {
+		return nil, ErrInvalidInstanceVersion
 	}
 	var err error
 	if appID, groupID, err = api.validateApplicationAndGroup(appID, groupID); err != nil {
 		return nil, err
 	}
+	if err := api.checkInstanceRegistration(appID, instanceIP); err != nil {
+		return nil, err
+	}
 
 	// We want to avoid having to create an unneeded DB transaction, so we check whether it
 	// is necessary (we need it when writing into the two tables, instance and
@@ -129,7 +210,7 @@ func (api *API) RegisterInstance(instanceID, instanceAlias, instanceIP, instance
 		// The instance exists, so we just update it if its IP or Alias changed
 		updateInstance = instance.IP != instanceIP || instance.Alias != instanceAlias
 
-		recent := nowUTC().Add(-5 * time.Minute)
+		recent := api.nowUTC().Add(-5 * time.Minute)
 
 		// And we only update the instance_application if the latest registry is outdated or
 		// older than what we establish as recent.
@@ -142,19 +223,42 @@ func (api *API) RegisterInstance(instanceID, instanceAlias, instanceIP, instance
 		}
 	}
 
+	instanceRecord := goqu.Record{"id": instanceID, "ip": instanceIP, "alias": instanceAlias}
+	if api.regionResolver != nil && (instance == nil || instance.IP != instanceIP) {
+		instanceRecord["region"] = api.resolveRegion(instanceIP)
+	}
+	// The first time an instance is seen is only recorded when inserting it.
+	insertRecord := goqu.Record{"first_seen": api.nowUTC()}
+	for k, v := range instanceRecord {
+		insertRecord[k] = v
+	}
 	upsertInstance, _, err := goqu.Insert("instance").
-		Cols("id", "ip", "alias").
-		Vals(goqu.Vals{instanceID, instanceIP, instanceAlias}).
-		OnConflict(goqu.DoUpdate("id", goqu.Record{"id": instanceID, "ip": instanceIP, "alias": instanceAlias})).
+		Rows(insertRecord).
+		OnConflict(goqu.DoUpdate("id", instanceRecord)).
 		ToSQL()
 	if err != nil {
 		return nil, err
 	}
 
+	// Instances reporting a version newer than any package are quarantined,
+	// until an operator acknowledges them or they report another version.
+	quarantined := false
+	if instance == nil || instance.Application.Version != instanceVersion {
+		if quarantined, err = api.isVersionQuarantined(appID, instanceVersion); err != nil {
+			return nil, err
+		}
+	}
+
 	upsertInstanceApplication, _, err := goqu.Insert("instance_application").
-		Cols("instance_id", "application_id", "group_id", "version", "last_check_for_updates").
-		Vals(goqu.Vals{instanceID, appID, groupID, instanceVersion, nowUTC()}).
-		OnConflict(goqu.DoUpdate("ON CONSTRAINT instance_application_pkey", goqu.Record{"group_id": groupID, "version": instanceVersion, "last_check_for_updates": nowUTC()})).
+		Cols("instance_id", "application_id", "group_id", "version", "last_check_for_updates", "version_changed_at", "quarantined").
+		Vals(goqu.Vals{instanceID, appID, groupID, instanceVersion, api.nowUTC(), api.nowUTC(), quarantined}).
+		OnConflict(goqu.DoUpdate("ON CONSTRAINT instance_application_pkey", goqu.Record{
+			"group_id":               groupID,
+			"version":                instanceVersion,
+			"last_check_for_updates": api.nowUTC(),
+			"version_changed_at":     goqu.L("CASE WHEN instance_application.version = EXCLUDED.version THEN instance_application.version_changed_at ELSE EXCLUDED.version_changed_at END"),
+			"quarantined":            goqu.L("CASE WHEN instance_application.version = EXCLUDED.version THEN instance_application.quarantined ELSE EXCLUDED.quarantined END"),
+		})).
 		ToSQL()
 	if err != nil {
 		return nil, err
@@ -215,8 +319,79 @@ func (api *API) RegisterInstance(instanceID, instanceAlias, instanceIP, instance
 	return api.GetInstance(instanceID, appID)
 }
 
+// RegisterPing registers an instance which pinged Nebraska, as
+// RegisterInstance does. When status inference from pings is enabled, an
+// instance reporting the version of its group's channel package is also
+// marked as complete, even if it never sent a completion event. Pings of
+// applications with ping persistence disabled are not stored, in which case
+// a nil instance is returned.
+func (api *API) RegisterPing(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string) (*Instance, error) {
+	disabled, err := api.isPingPersistenceDisabled(appID)
+	if err != nil || disabled {
+		return nil, err
+	}
+	instance, err := api.RegisterInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID)
+	if err != nil || !api.inferStatusFromPing {
+		return instance, err
+	}
+	if err := api.inferInstanceStatusFromPing(instanceID, instanceVersion, appID, groupID); err != nil {
+		logger.Error().Err(err).Msg("RegisterPing - could not infer instance status")
+	}
+	return instance, nil
+}
+
+// inferInstanceStatusFromPing marks the instance as complete if the version
+// it reports is the one of the package its group's channel points to.
+func (api *API) inferInstanceStatusFromPing(instanceID, instanceVersion, appID, groupID string) error {
+	appID, groupID, err := api.validateApplicationAndGroup(appID, groupID)
+	if err != nil {
+		return err
+	}
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if group.Channel == nil || group.Channel.Package == nil || !sameVersion(group.Channel.Package.Version, instanceVersion) {
+		return nil
+	}
+	instance, err := api.GetInstance(instanceID, appID)
+	if err != nil {
+		return err
+	}
+
+	instanceData := make(map[string]interface{})
+	instanceData["status"] = InstanceStatusComplete
+	instanceData["version"] = instanceVersion
+	instanceData["last_update_version"] = instanceVersion
+
+	return api.updateInstanceData(instance, instanceData)
+}
+
+// RegisterMalformedInstanceVersion records in the activity log that an
+// instance reported a version which is not valid semver. At most one entry per
+// instance is recorded in a 24 hours period.
+func (api *API) RegisterMalformedInstanceVersion(instanceID, appID, groupID, version string) error {
+	appID, groupID, err := api.validateApplicationAndGroup(appID, groupID)
+	if err != nil {
+		return err
+	}
+	if api.hasRecentActivity(activityInstanceVersionMalformed, ActivityQueryParams{AppID: appID, GroupID: groupID, InstanceID: instanceID}) {
+		return nil
+	}
+	// The activity version can't be empty and has a limited length, so the
+	// quoted version is stored instead.
+	version = strconv.Quote(version)
+	if len(version) > 255 {
+		version = version[:255]
+	}
+	return api.newInstanceActivityEntry(activityInstanceVersionMalformed, activityWarning, version, appID, groupID, instanceID)
+}
+
 // GetInstance returns the instance identified by the id provided.
 func (api *API) GetInstance(instanceID, appID string) (*Instance, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	var instance Instance
 	query, _, err := goqu.From("instance").
 		Where(goqu.C("id").Eq(instanceID)).
@@ -228,7 +403,7 @@ func (api *API) GetInstance(instanceID, appID string) (*Instance, error) {
 	if err != nil {
 		return nil, err
 	}
-	instanceApplication, err := api.getInstanceApp(appID, instance.ID, validityInterval)
+	instanceApplication, err := api.getInstanceApp(appID, instance.ID, api.activeInterval(0))
 	switch err {
 	case nil:
 		instance.Application = *instanceApplication
@@ -257,12 +432,15 @@ func (api *API) getInstanceApp(appID, instanceID string, duration postgresDurati
 // GetInstanceStatusHistory returns the status history of an instance in the
 // context of the application/group provided.
 func (api *API) GetInstanceStatusHistory(instanceID, appID, groupID string, limit uint64) ([]*InstanceStatusHistoryEntry, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	var instanceStatusHistory []*InstanceStatusHistoryEntry
 	query, _, err := api.instanceStatusHistoryQuery(instanceID, appID, groupID, limit).ToSQL()
 	if err != nil {
 		return nil, err
 	}
-	rows, err := api.db.Queryx(query)
+	rows, err := api.readDB().Queryx(query)
 	if err != nil {
 		return nil, err
 	}
@@ -289,6 +467,9 @@ func (api *API) GetInstanceStatusHistory(instanceID, appID, groupID string, limi
 
 // GetInstances returns all instances that match with the provided criteria.
 func (api *API) GetInstances(p InstancesQueryParams, duration string) (InstancesWithTotal, error) {
+	if err := api.checkAppTeam(p.ApplicationID); err != nil {
+		return InstancesWithTotal{}, err
+	}
 	var instances []*Instance
 	var err error
 	totalCount, err := api.GetInstancesCount(p, duration)
@@ -302,14 +483,21 @@ func (api *API) GetInstances(p InstancesQueryParams, duration string) (Instances
 		return InstancesWithTotal{}, err
 	}
 	limit, offset := sqlPaginate(p.Page, p.PerPage)
-	query, _, err := api.instancesQuery(p, dbDuration).
+	if p.Limit > 0 {
+		limit, offset = uint(p.Limit), uint(p.Offset)
+	}
+	instancesQuery, err := api.instancesQuery(p, dbDuration)
+	if err != nil {
+		return InstancesWithTotal{}, err
+	}
+	query, _, err := instancesQuery.
 		Limit(limit).
 		Offset(offset).
 		ToSQL()
 	if err != nil {
 		return InstancesWithTotal{}, err
 	}
-	rows, err := api.db.Queryx(query)
+	rows, err := api.readDB().Queryx(query)
 	if err != nil {
 		return InstancesWithTotal{}, err
 	}
@@ -342,6 +530,9 @@ func (api *API) GetInstances(p InstancesQueryParams, duration string) (Instances
 }
 
 func (api *API) GetInstancesCount(p InstancesQueryParams, duration string) (uint64, error) {
+	if err := api.checkAppTeam(p.ApplicationID); err != nil {
+		return 0, err
+	}
 	var totalCount uint64
 	var err error
 
@@ -354,14 +545,127 @@ func (api *API) GetInstancesCount(p InstancesQueryParams, duration string) (uint
 	if err != nil {
 		return 0, err
 	}
-	err = api.db.QueryRow(countQuery).Scan(&totalCount)
+	err = api.readDB().QueryRow(countQuery).Scan(&totalCount)
 	if err != nil {
 		return 0, err
 	}
 	return totalCount, nil
 }
 
+// SearchInstances returns the instances of the application provided that match
+// the search filter, along with the total number of matching instances.
+func (api *API) SearchInstances(appID string, filter InstanceSearchFilter) ([]*Instance, int, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, 0, err
+	}
+	searchQuery, err := api.instanceSearchQuery(appID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery, _, err := searchQuery.Select(goqu.COUNT("*")).ToSQL()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := api.readDB().QueryRow(countQuery).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	filter.Page, filter.PerPage = validatePaginationParams(filter.Page, filter.PerPage)
+	limit, offset := sqlPaginate(filter.Page, filter.PerPage)
+	query, _, err := searchQuery.
+		Select("i.id", "i.ip", "i.created_ts", "i.alias", "i.arch", "i.board", "i.platform", "i.region", "i.note", "ia.application_id", "ia.group_id", "ia.version", "ia.created_ts",
+			"ia.status", "ia.last_check_for_updates", "ia.last_update_granted_ts", "ia.last_update_version", "ia.update_in_progress", "ia.quarantined").
+		Order(goqu.I("ia.last_check_for_updates").Desc()).
+		Limit(limit).
+		Offset(offset).
+		ToSQL()
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	instances := []*Instance{}
+	for rows.Next() {
+		var instance Instance
+		app := &instance.Application
+		err := rows.Scan(&instance.ID, &instance.IP, &instance.CreatedTs, &instance.Alias, &instance.Arch, &instance.Board, &instance.Platform, &instance.Region, &instance.Note, &app.ApplicationID, &app.GroupID, &app.Version, &app.CreatedTs,
+			&app.Status, &app.LastCheckForUpdates, &app.LastUpdateGrantedTs, &app.LastUpdateVersion, &app.UpdateInProgress, &app.Quarantined)
+		if err != nil {
+			return nil, 0, err
+		}
+		app.InstanceID = instance.ID
+		instances = append(instances, &instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return instances, total, nil
+}
+
+// instanceSearchQuery returns a SelectDataset prepared to return the instances
+// of the given application that match the search filter provided.
+func (api *API) instanceSearchQuery(appID string, filter InstanceSearchFilter) (*goqu.SelectDataset, error) {
+	query := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Where(goqu.I("ia.application_id").Eq(appID), goqu.L(ignoreFakeInstanceCondition("ia.instance_id")))
+
+	if filter.GroupID != "" {
+		query = query.Where(goqu.I("ia.group_id").Eq(filter.GroupID))
+	}
+	if filter.LastSeen > 0 {
+		query = query.Where(goqu.I("ia.last_check_for_updates").Gt(api.nowUTC().Add(-filter.LastSeen)))
+	}
+	if filter.IPRange != "" {
+		_, ipNet, err := net.ParseCIDR(filter.IPRange)
+		if err != nil {
+			return nil, ErrInvalidIPRange
+		}
+		query = query.Where(goqu.L("i.ip <<= ?::inet", ipNet.String()))
+	}
+	if filter.MinVersion != "" {
+		minVersion, err := semver.Make(filter.MinVersion)
+		if err != nil {
+			return nil, ErrInvalidSemver
+		}
+		query = query.Where(goqu.L("(regexp_match(ia.version, '^(\\d+)\\.(\\d+)\\.(\\d+)'))::int[] >= ?::int[]", semverToPostgresArray(minVersion)))
+	}
+	if filter.MaxVersion != "" {
+		maxVersion, err := semver.Make(filter.MaxVersion)
+		if err != nil {
+			return nil, ErrInvalidSemver
+		}
+		query = query.Where(goqu.L("(regexp_match(ia.version, '^(\\d+)\\.(\\d+)\\.(\\d+)'))::int[] <= ?::int[]", semverToPostgresArray(maxVersion)))
+	}
+	if filter.Text != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Text) + "%"
+		query = query.Where(goqu.Or(
+			goqu.I("i.id").ILike(pattern),
+			goqu.I("i.alias").ILike(pattern),
+			goqu.L("host(i.ip) LIKE ?", pattern),
+		))
+	}
+
+	return query, nil
+}
+
+// likeEscaper escapes the characters with a special meaning in LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// semverToPostgresArray returns the major, minor and patch numbers of the
+// version provided as a postgres integer array literal.
+func semverToPostgresArray(v semver.Version) string {
+	return fmt.Sprintf("{%d,%d,%d}", v.Major, v.Minor, v.Patch)
+}
+
 func (api *API) UpdateInstance(instanceID string, alias string) (*Instance, error) {
+	if err := api.checkInstanceTeam(instanceID); err != nil {
+		return nil, err
+	}
 	instance := &Instance{}
 	query, _, err := goqu.Update("instance").
 		Set(
@@ -382,6 +686,194 @@ func (api *API) UpdateInstance(instanceID string, alias string) (*Instance, erro
 	return instance, nil
 }
 
+// UpdateInstanceSystemInfo stores the architecture, board and platform
+// reported by the instance provided, if they changed since they were last
+// reported.
+func (api *API) UpdateInstanceSystemInfo(instanceID string, arch Arch, board, platform string) error {
+	query, _, err := goqu.Update("instance").
+		Set(goqu.Record{"arch": arch, "board": board, "platform": platform}).
+		Where(
+			goqu.C("id").Eq(instanceID),
+			goqu.Or(
+				goqu.C("arch").Neq(arch),
+				goqu.C("board").Neq(board),
+				goqu.C("platform").Neq(platform),
+			),
+		).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// GetInstanceStatsByArch returns the number of instances of the application
+// provided grouped by the architecture and board they run on. Only the
+// instances that checked for updates within activeWithin are counted, or
+// within the API's default window when it's zero.
+func (api *API) GetInstanceStatsByArch(appID string, activeWithin time.Duration) ([]*InstanceArchStats, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select(goqu.I("i.arch"), goqu.I("i.board"), goqu.COUNT("*").As("instances")).
+		Where(
+			goqu.I("ia.application_id").Eq(appID),
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(activeWithin)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		GroupBy(goqu.I("i.arch"), goqu.I("i.board")).
+		Order(goqu.I("instances").Desc(), goqu.I("i.arch").Asc(), goqu.I("i.board").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []*InstanceArchStats{}
+	for rows.Next() {
+		archStats := &InstanceArchStats{}
+		if err := rows.StructScan(archStats); err != nil {
+			return nil, err
+		}
+		stats = append(stats, archStats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// DeleteStaleInstances deletes the instances that haven't checked for updates
+// of an application in longer than its retention period, along with their
+// events and status history in it. Applications without a retention period
+// of their own use the one provided, a non-positive value meaning their
+// instances are kept. Instances left without any application are deleted
+// too. It returns the number of stale instances removed from applications.
+func (api *API) DeleteStaleInstances(olderThan time.Duration) (int, error) {
+	var defaultRetention interface{}
+	if olderThan > 0 {
+		defaultRetention = fmt.Sprintf("%d seconds", int64(olderThan.Seconds()))
+	}
+
+	query := fmt.Sprintf(`
+	WITH stale AS (
+		DELETE FROM instance_application ia
+		USING application a
+		WHERE a.id = ia.application_id AND %s AND
+			ia.last_check_for_updates < $1::timestamptz - COALESCE(make_interval(days => a.instance_retention_days), $2::interval)
+		RETURNING ia.instance_id, ia.application_id
+	), deleted_events AS (
+		DELETE FROM event e USING stale s
+		WHERE e.instance_id = s.instance_id AND e.application_id = s.application_id
+	), deleted_history AS (
+		DELETE FROM instance_status_history h USING stale s
+		WHERE h.instance_id = s.instance_id AND h.application_id = s.application_id
+	), deleted_instances AS (
+		DELETE FROM instance i
+		WHERE i.id IN (SELECT instance_id FROM stale) AND NOT EXISTS (
+			SELECT 1 FROM instance_application ia
+			WHERE ia.instance_id = i.id AND NOT EXISTS (
+				SELECT 1 FROM stale s
+				WHERE s.instance_id = ia.instance_id AND s.application_id = ia.application_id
+			)
+		)
+	)
+	SELECT count(*) FROM stale`, ignoreFakeInstanceCondition("ia.instance_id"))
+
+	var deleted int
+	if err := api.db.QueryRow(query, api.nowUTC(), defaultRetention).Scan(&deleted); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// DeregisterInstance removes the instance provided from the application,
+// deleting its events and status history in it, and the instance itself when
+// it isn't registered in any other application. The rollout stats of the
+// group the instance belonged to are recalculated afterwards, as the instance
+// no longer counts towards them.
+func (api *API) DeregisterInstance(instanceID, appID string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+
+	query := `
+	WITH deregistered AS (
+		DELETE FROM instance_application
+		WHERE instance_id = $1 AND application_id = $2
+		RETURNING group_id
+	), deleted_events AS (
+		DELETE FROM event
+		WHERE instance_id = $1 AND application_id = $2
+	), deleted_history AS (
+		DELETE FROM instance_status_history
+		WHERE instance_id = $1 AND application_id = $2
+	), deleted_instance AS (
+		DELETE FROM instance i
+		WHERE i.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM instance_application ia
+			WHERE ia.instance_id = i.id AND ia.application_id <> $2
+		)
+	)
+	SELECT group_id FROM deregistered`
+
+	var groupID null.String
+	err := api.db.QueryRow(query, instanceID, appID).Scan(&groupID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return ErrNoRowsAffected
+	default:
+		return err
+	}
+
+	if !groupID.Valid {
+		return nil
+	}
+	return api.RecalculateRolloutStats(groupID.String)
+}
+
+// previewInstance returns the instance RegisterInstance would register with
+// the details provided, without writing anything to the database.
+func (api *API) previewInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string) (*Instance, error) {
+	if !isValidInstanceVersion(instanceVersion) {
+		return nil, ErrInvalidInstanceVersion
+	}
+	var err error
+	if appID, groupID, err = api.validateApplicationAndGroup(appID, groupID); err != nil {
+		return nil, err
+	}
+	if err := api.checkInstanceRegistration(appID, instanceIP); err != nil {
+		return nil, err
+	}
+
+	instance, err := api.GetInstance(instanceID, appID)
+	switch err {
+	case nil:
+		if instanceAlias == "" {
+			instanceAlias = instance.Alias
+		}
+	case sql.ErrNoRows:
+		instance = &Instance{ID: instanceID}
+	default:
+		return nil, err
+	}
+	instance.IP = instanceIP
+	instance.Application.InstanceID = instanceID
+	instance.Application.ApplicationID = appID
+	instance.Alias = instanceAlias
+	instance.Application.GroupID = null.StringFrom(groupID)
+	instance.Application.Version = instanceVersion
+
+	return instance, nil
+}
+
 // validateApplicationAndGroup validates if the group provided belongs to the
 // provided application, returning the normalized uuid version of the appID and
 // groupID provided if both are valid and the group belongs to the given
@@ -405,6 +897,14 @@ func (api *API) validateApplicationAndGroup(appID, groupID string) (string, stri
 		return "", "", ErrInvalidApplicationOrGroup
 	}
 
+	deleted, err := api.isAppDeleted(group.ApplicationID)
+	if err != nil {
+		return "", "", err
+	}
+	if deleted {
+		return "", "", ErrAppDeleted
+	}
+
 	return appUUID.String(), groupUUID.String(), nil
 }
 
@@ -429,7 +929,7 @@ func (api *API) updateInstanceData(instance *Instance, data map[string]interface
 		return nil
 	}
 
-	if newStatus == InstanceStatusComplete {
+	if _, ok := insertData["version"]; !ok && newStatus == InstanceStatusComplete {
 		insertData["version"] = goqu.L("CASE WHEN last_update_version IS NOT NULL THEN last_update_version ELSE version END")
 	}
 
@@ -464,11 +964,28 @@ func (api *API) updateInstanceObjStatus(instance *Instance, newStatus int) error
 	return api.updateInstanceData(instance, insertData)
 }
 
+// recordObservedUpdate records the version the instance provided would have
+// been granted an update to if its group didn't only observe the decisions.
+func (api *API) recordObservedUpdate(instance *Instance, version string) error {
+	query, _, err := goqu.Update("instance_application").
+		Set(goqu.Record{
+			"observed_update_version": version,
+			"observed_update_ts":      api.nowUTC(),
+		}).
+		Where(goqu.C("instance_id").Eq(instance.ID), goqu.C("application_id").Eq(instance.Application.ApplicationID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
 // instanceAppQuery returns a SelectDataset prepared to return the app status
 // of the app identified by the application id provided for a given instance.
 func (api *API) instanceAppQuery(appID, instanceID string, duration postgresDuration) *goqu.SelectDataset {
 	query := goqu.From("instance_application").
-		Select("version", "status", "last_check_for_updates", "last_update_version", "update_in_progress", "application_id", "group_id").
+		Select("version", "version_changed_at", "status", "last_check_for_updates", "last_update_version", "update_in_progress", "quarantined", "observed_update_version", "observed_update_ts", "application_id", "group_id").
 		Where(goqu.C("instance_id").Eq(instanceID), goqu.C("application_id").Eq(appID)).
 		Where(goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", duration))
 	return query
@@ -497,12 +1014,23 @@ func (api *API) getFilterInstancesQuery(selectPart exp.LiteralExpression, p Inst
 }
 
 // instancesQuery returns a SelectDataset prepared to return all instances
-// that match the criteria provided in InstancesQueryParams.
-func (api *API) instancesQuery(p InstancesQueryParams, duration postgresDuration) *goqu.SelectDataset {
-	instancesSubquery := api.getFilterInstancesQuery(goqu.L("instance_id"), p, duration)
+// that match the criteria provided in InstancesQueryParams, in the order
+// requested.
+func (api *API) instancesQuery(p InstancesQueryParams, duration postgresDuration) (*goqu.SelectDataset, error) {
+	query := api.getFilterInstancesQuery(goqu.L("i.*"), p, duration).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("instance_application.instance_id"))))
 
-	return goqu.From("instance").
-		Where(goqu.L("id IN ?", instancesSubquery))
+	switch p.SortBy {
+	case "", InstancesSortByLastSeen:
+		query = query.Order(goqu.I("instance_application.last_check_for_updates").Desc(), goqu.I("i.id").Asc())
+	case InstancesSortByVersion:
+		query = query.Order(goqu.I("instance_application.version").Asc(), goqu.I("i.id").Asc())
+	case InstancesSortByIP:
+		query = query.Order(goqu.I("i.ip").Asc(), goqu.I("i.id").Asc())
+	default:
+		return nil, ErrInvalidInstancesSortBy
+	}
+	return query, nil
 }
 
 // instanceStatusHistoryQuery returns a SelectDataset prepared to return the
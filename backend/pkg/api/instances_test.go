@@ -1,11 +1,16 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -36,11 +41,11 @@ func TestRegisterInstance(t *testing.T) {
 	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "1.0.0", tApp.ID, "invalidGroupID")
 	assert.Error(t, err, "Using an invalid group id.")
 
-	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "", tApp.ID, "invalidGroupID")
-	assert.Error(t, err, "Using an empty instance version.")
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrInvalidInstanceVersion, err, "Using an empty instance version.")
 
-	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "aaa1.0.0", tApp.ID, "invalidGroupID")
-	assert.Equal(t, ErrInvalidSemver, err, "Using an invalid instance version.")
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", strings.Repeat("1", 256), tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrInvalidInstanceVersion, err, "Using a too long instance version.")
 
 	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "1.0.0", tApp.ID, tGroup2.ID)
 	assert.Equal(t, ErrInvalidApplicationOrGroup, err, "The group provided doesn't belong to the application provided.")
@@ -65,6 +70,44 @@ func TestRegisterInstance(t *testing.T) {
 	assert.Equal(t, "10.0.0.3", instance.IP)
 	assert.Equal(t, "1.0.3", instance.Application.Version)
 	assert.Equal(t, null.StringFrom(tGroup3.ID), instance.Application.GroupID)
+
+	// Versions which aren't valid semver are stored as reported.
+	instance, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.4", "aaa1.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "aaa1.0.0", instance.Application.Version)
+}
+
+func TestRegisterPing_InferStatus(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionInferStatusFromPing)
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	instanceID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	_, err = a.RegisterPing(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	instance, _ := a.GetInstance(instanceID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusUpdateGranted)), instance.Application.Status, "Instance is not on the target version yet.")
+
+	_, err = a.RegisterPing(instanceID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	instance, _ = a.GetInstance(instanceID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusComplete)), instance.Application.Status)
+	assert.Equal(t, "12.1.0", instance.Application.Version)
+	assert.False(t, instance.Application.UpdateInProgress)
+
+	tGroup, _ = a.GetGroup(tGroup.ID)
+	updatesStats, err := a.getGroupUpdatesStats(tGroup)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updatesStats.UpdatesToCurrentVersionSucceeded)
 }
 
 func TestGetInstance(t *testing.T) {
@@ -149,6 +192,61 @@ func TestGetInstances(t *testing.T) {
 	assert.Error(t, err, "Application id and group id are required and must be valid uuids.")
 }
 
+func TestGetInstances_Sorting(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tInstance1, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "1.0.1", tApp.ID, tGroup.ID)
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.2", tApp.ID, tGroup.ID)
+	tInstance3, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp.ID, tGroup.ID)
+	tInstance4, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp.ID, tGroup.ID)
+
+	instanceIDs := func(instances []*Instance) []string {
+		ids := make([]string, 0, len(instances))
+		for _, instance := range instances {
+			ids = append(ids, instance.ID)
+		}
+		return ids
+	}
+	// Instances with the same version or ip are sorted by id.
+	tied := []string{tInstance3.ID, tInstance4.ID}
+	sort.Strings(tied)
+
+	result, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: InstancesSortByIP}, testDuration)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tInstance2.ID, tied[0], tied[1], tInstance1.ID}, instanceIDs(result.Instances))
+
+	result, err = a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: InstancesSortByVersion}, testDuration)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tied[0], tied[1], tInstance1.ID, tInstance2.ID}, instanceIDs(result.Instances))
+
+	// Pages are consistent with the whole listing and report the total.
+	for _, sortBy := range []string{"", InstancesSortByLastSeen, InstancesSortByVersion, InstancesSortByIP} {
+		all, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: sortBy}, testDuration)
+		require.NoError(t, err)
+		require.Len(t, all.Instances, 4)
+
+		var paged []*Instance
+		for offset := uint64(0); offset < 4; offset += 3 {
+			result, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: sortBy, Limit: 3, Offset: offset}, testDuration)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(4), result.TotalInstances)
+			paged = append(paged, result.Instances...)
+		}
+		assert.Equal(t, instanceIDs(all.Instances), instanceIDs(paged), sortBy)
+
+		result, err := a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: sortBy, Page: 2, PerPage: 3}, testDuration)
+		require.NoError(t, err)
+		assert.Equal(t, instanceIDs(all.Instances[3:]), instanceIDs(result.Instances), sortBy)
+	}
+
+	_, err = a.GetInstances(InstancesQueryParams{ApplicationID: tApp.ID, GroupID: tGroup.ID, SortBy: "alias"}, testDuration)
+	assert.Equal(t, ErrInvalidInstancesSortBy, err)
+}
+
 func TestGetInstancesFiltered(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -182,3 +280,314 @@ func TestGetInstancesFiltered(t *testing.T) {
 		assert.Empty(t, expectedIDs)
 	}
 }
+
+func TestSearchInstances(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	_, _ = a.RegisterInstance("machine-aaa-1", "web1", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance("machine-aaa-2", "web2", "10.0.1.1", "1.2.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance("machine-bbb-3", "db1", "192.168.0.1", "2.0.0", tApp.ID, tGroup2.ID)
+
+	instances, total, err := a.SearchInstances(tApp.ID, InstanceSearchFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, instances, 3)
+
+	instances, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{Text: "aaa"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, instances, 2)
+
+	instances, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{Text: "168.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "machine-bbb-3", instances[0].ID)
+		assert.Equal(t, "2.0.0", instances[0].Application.Version)
+	}
+
+	_, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{IPRange: "10.0.0.0/16"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	_, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{MinVersion: "1.1.0", MaxVersion: "2.0.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	_, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{GroupID: tGroup2.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	_, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{LastSeen: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	instances, total, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{Page: 2, PerPage: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, instances, 1)
+
+	_, _, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{IPRange: "10.0.0.0"})
+	assert.Equal(t, ErrInvalidIPRange, err)
+
+	_, _, err = a.SearchInstances(tApp.ID, InstanceSearchFilter{MinVersion: "aaa"})
+	assert.Equal(t, ErrInvalidSemver, err)
+}
+
+func TestGetInstanceStatsByArch(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tInstance1, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp.ID, tGroup.ID)
+	tInstance3, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "1.0.0", tApp.ID, tGroup.ID)
+
+	assert.NoError(t, a.UpdateInstanceSystemInfo(tInstance1.ID, ArchAMD64, "amd64-usr", "CoreOS"))
+	assert.NoError(t, a.UpdateInstanceSystemInfo(tInstance2.ID, ArchAMD64, "amd64-usr", "CoreOS"))
+	assert.NoError(t, a.UpdateInstanceSystemInfo(tInstance3.ID, ArchAMD64, "amd64-usr", "CoreOS"))
+	assert.NoError(t, a.UpdateInstanceSystemInfo(tInstance3.ID, ArchAArch64, "arm64-usr", "CoreOS"))
+
+	instance, err := a.GetInstance(tInstance3.ID, tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ArchAArch64, instance.Arch)
+	assert.Equal(t, "arm64-usr", instance.Board)
+	assert.Equal(t, "CoreOS", instance.Platform)
+
+	stats, err := a.GetInstanceStatsByArch(tApp.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*InstanceArchStats{
+		{Arch: ArchAMD64, Board: "amd64-usr", Instances: 2},
+		{Arch: ArchAArch64, Board: "arm64-usr", Instances: 1},
+	}, stats)
+}
+
+func TestDeleteStaleInstances(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp1, _ := a.AddApp(&Application{Name: "test_app1", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID, InstanceRetentionDays: null.IntFrom(30)})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp1.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp2.ID, PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err := a.AddApp(&Application{Name: "test_app3", TeamID: tTeam.ID, InstanceRetentionDays: null.IntFrom(0)})
+	assert.Equal(t, ErrInvalidInstanceRetention, err)
+
+	app, err := a.GetApp(tApp2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(30), app.InstanceRetentionDays)
+
+	backdate := func(instanceID, appID string, age time.Duration) {
+		_, err := a.db.Exec("UPDATE instance_application SET last_check_for_updates = $1 WHERE instance_id = $2 AND application_id = $3", time.Now().UTC().Add(-age), instanceID, appID)
+		require.NoError(t, err)
+	}
+	addEvent := func(instanceID, appID string) {
+		_, err := a.db.Exec("INSERT INTO event (instance_id, application_id, event_type_id) VALUES ($1, $2, (SELECT min(id) FROM event_type))", instanceID, appID)
+		require.NoError(t, err)
+	}
+	countRows := func(query string, args ...interface{}) int {
+		var count int
+		require.NoError(t, a.db.QueryRow(query, args...).Scan(&count))
+		return count
+	}
+	day := 24 * time.Hour
+
+	staleInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp1.ID, tGroup1.ID)
+	backdate(staleInstance.ID, tApp1.ID, 10*day)
+	addEvent(staleInstance.ID, tApp1.ID)
+
+	freshInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp1.ID, tGroup1.ID)
+	addEvent(freshInstance.ID, tApp1.ID)
+
+	retainedInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.3", "1.0.0", tApp2.ID, tGroup2.ID)
+	backdate(retainedInstance.ID, tApp2.ID, 10*day)
+
+	expiredInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.4", "1.0.0", tApp2.ID, tGroup2.ID)
+	backdate(expiredInstance.ID, tApp2.ID, 40*day)
+
+	sharedInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.5", "1.0.0", tApp1.ID, tGroup1.ID)
+	_, _ = a.RegisterInstance(sharedInstance.ID, "", "10.0.0.5", "1.0.0", tApp2.ID, tGroup2.ID)
+	backdate(sharedInstance.ID, tApp1.ID, 10*day)
+	addEvent(sharedInstance.ID, tApp1.ID)
+	addEvent(sharedInstance.ID, tApp2.ID)
+
+	deleted, err := a.DeleteStaleInstances(7 * day)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	instanceCount := func(instanceID string) int {
+		return countRows("SELECT count(*) FROM instance WHERE id = $1", instanceID)
+	}
+	instanceAppCount := func(instanceID, appID string) int {
+		return countRows("SELECT count(*) FROM instance_application WHERE instance_id = $1 AND application_id = $2", instanceID, appID)
+	}
+	eventCount := func(instanceID, appID string) int {
+		return countRows("SELECT count(*) FROM event WHERE instance_id = $1 AND application_id = $2", instanceID, appID)
+	}
+
+	assert.Equal(t, 0, instanceCount(staleInstance.ID))
+	assert.Equal(t, 0, eventCount(staleInstance.ID, tApp1.ID))
+	assert.Equal(t, 0, instanceCount(expiredInstance.ID))
+
+	assert.Equal(t, 1, instanceCount(freshInstance.ID))
+	assert.Equal(t, 1, eventCount(freshInstance.ID, tApp1.ID))
+	assert.Equal(t, 1, instanceAppCount(retainedInstance.ID, tApp2.ID))
+
+	assert.Equal(t, 1, instanceCount(sharedInstance.ID))
+	assert.Equal(t, 0, instanceAppCount(sharedInstance.ID, tApp1.ID))
+	assert.Equal(t, 0, eventCount(sharedInstance.ID, tApp1.ID))
+	assert.Equal(t, 1, instanceAppCount(sharedInstance.ID, tApp2.ID))
+	assert.Equal(t, 1, eventCount(sharedInstance.ID, tApp2.ID))
+
+	// Without a default retention only the applications' own periods apply.
+	backdate(freshInstance.ID, tApp1.ID, 100*day)
+	deleted, err = a.DeleteStaleInstances(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Equal(t, 1, instanceCount(freshInstance.ID))
+}
+
+func TestDeregisterInstance(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 4, PolicyUpdateTimeout: "60 minutes"})
+
+	instance1, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	instance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+
+	_, _ = a.GetUpdatePackage(instance1.ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.GetUpdatePackage(instance2.ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	_ = a.RegisterEvent(instance1.ID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "", "")
+	_ = a.RegisterEvent(instance2.ID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "", "")
+
+	group, _ := a.GetGroup(tGroup.ID)
+	assert.True(t, group.RolloutInProgress)
+	stats, _ := a.GetGroupInstancesStats(tGroup.ID, testDuration)
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, int64(1), stats.Complete.Int64)
+	assert.Equal(t, int64(1), stats.Error.Int64)
+
+	err := a.DeregisterInstance(instance2.ID, tApp.ID)
+	require.NoError(t, err)
+
+	_, err = a.GetInstance(instance2.ID, tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	var count int
+	require.NoError(t, a.db.QueryRow("SELECT count(*) FROM instance WHERE id = $1", instance2.ID).Scan(&count))
+	assert.Equal(t, 0, count)
+	require.NoError(t, a.db.QueryRow("SELECT count(*) FROM event WHERE instance_id = $1", instance2.ID).Scan(&count))
+	assert.Equal(t, 0, count)
+
+	// Only the instance that completed the update is left, so the rollout
+	// is finished.
+	group, _ = a.GetGroup(tGroup.ID)
+	assert.False(t, group.RolloutInProgress)
+	stats, _ = a.GetGroupInstancesStats(tGroup.ID, testDuration)
+	assert.Equal(t, 1, stats.Total)
+	assert.Equal(t, int64(1), stats.Complete.Int64)
+	assert.Equal(t, int64(0), stats.Error.Int64)
+
+	err = a.DeregisterInstance(instance2.ID, tApp.ID)
+	assert.Equal(t, ErrNoRowsAffected, err)
+}
+
+func TestQuarantinedInstances(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err := a.RegisterInstance("known-instance", "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	_, err = a.RegisterInstance("updated-instance", "", "10.0.0.2", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err := a.RegisterInstance("new-instance", "", "10.0.0.3", "13.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.True(t, instance.Application.Quarantined)
+
+	instances, err := a.ListQuarantinedInstances(tApp.ID)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "new-instance", instances[0].ID)
+	assert.Equal(t, "13.0.0", instances[0].Application.Version)
+
+	// Quarantined instances don't count towards the rollout percentages.
+	group, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	stats, err := a.getGroupUpdatesStats(group)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalInstances)
+
+	require.NoError(t, a.AcknowledgeQuarantinedInstance("new-instance", tApp.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.AcknowledgeQuarantinedInstance("new-instance", tApp.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.AcknowledgeQuarantinedInstance("known-instance", tApp.ID))
+	instances, err = a.ListQuarantinedInstances(tApp.ID)
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+
+	// Acknowledged instances stay out of quarantine until they report
+	// another unknown version, and are released when they report a known
+	// one.
+	_, err = a.RegisterInstance("new-instance", "", "10.0.0.4", "13.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance("new-instance", tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Application.Quarantined)
+
+	_, err = a.RegisterInstance("new-instance", "", "10.0.0.4", "14.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance("new-instance", tApp.ID)
+	require.NoError(t, err)
+	assert.True(t, instance.Application.Quarantined)
+
+	_, err = a.RegisterInstance("new-instance", "", "10.0.0.4", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance("new-instance", tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Application.Quarantined)
+}
+
+func TestRegisterPing_DisablePingPersistence(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID, DisablePingPersistence: true})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	app, err := a.GetApp(tApp.ID)
+	require.NoError(t, err)
+	assert.True(t, app.DisablePingPersistence)
+
+	instanceID := uuid.New().String()
+	instance, err := a.RegisterPing(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, instance)
+	_, err = a.GetInstance(instanceID, tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	app.DisablePingPersistence = false
+	require.NoError(t, a.UpdateApp(app))
+	instance, err = a.RegisterPing(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, instanceID, instance.ID)
+}
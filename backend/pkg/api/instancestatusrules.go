@@ -0,0 +1,203 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// InstanceUpdateStatus is the status of an instance regarding the updates of
+// its group, derived from the events it posted by GetInstanceStatus.
+//
+// The statuses form the following state machine, restarted each time the
+// instance is granted an update, as only the events posted since then are
+// considered:
+//
+//	idle -----(update granted)-----> updating
+//	updating --(progress event)----> updating
+//	updating --(failure event)-----> failed
+//	updating --(completion event)--> complete
+//	complete --(target changed)----> idle
+//	failed ----(update granted)----> updating
+//
+// The events leading to each status are given by the instance status rules,
+// see OptionInstanceStatusRules.
+type InstanceUpdateStatus string
+
+const (
+	// InstanceUpdateStatusIdle indicates that the instance isn't updating,
+	// and that it isn't running its group's target version.
+	InstanceUpdateStatusIdle InstanceUpdateStatus = "idle"
+
+	// InstanceUpdateStatusUpdating indicates that the instance was granted an
+	// update and it's in the process of applying it.
+	InstanceUpdateStatusUpdating InstanceUpdateStatus = "updating"
+
+	// InstanceUpdateStatusComplete indicates that the instance completed its
+	// last update, and it's running its group's target version.
+	InstanceUpdateStatusComplete InstanceUpdateStatus = "complete"
+
+	// InstanceUpdateStatusFailed indicates that the instance reported a
+	// failure while applying its last update.
+	InstanceUpdateStatusFailed InstanceUpdateStatus = "failed"
+)
+
+// ErrInvalidInstanceStatusRule error indicates that an instance status rule
+// leads to a status events can't lead to.
+var ErrInvalidInstanceStatusRule = newValidationError("nebraska: invalid instance status rule")
+
+// InstanceStatusRule maps the events of the given type and result to the
+// instance status they lead to. An EventType of 0 matches events of any type.
+type InstanceStatusRule struct {
+	EventType int
+	Result    int
+	Status    InstanceUpdateStatus
+}
+
+// matches checks if the event of the type and result provided matches the
+// rule.
+func (r InstanceStatusRule) matches(etype, result int) bool {
+	return (r.EventType == 0 || r.EventType == etype) && r.Result == result
+}
+
+// DefaultInstanceStatusRules are the instance status rules used unless the
+// api instance is given others.
+var DefaultInstanceStatusRules = []InstanceStatusRule{
+	{EventType: 0, Result: ResultFailed, Status: InstanceUpdateStatusFailed},
+	{EventType: EventUpdateDownloadStarted, Result: ResultSuccess, Status: InstanceUpdateStatusUpdating},
+	{EventType: EventUpdateDownloadFinished, Result: ResultSuccess, Status: InstanceUpdateStatusUpdating},
+	{EventType: EventUpdateInstalled, Result: ResultSuccess, Status: InstanceUpdateStatusUpdating},
+	{EventType: EventUpdateComplete, Result: ResultSuccessReboot, Status: InstanceUpdateStatusComplete},
+}
+
+// OptionInstanceStatusRules will modify API to derive the status of the
+// instances with the rules provided instead of DefaultInstanceStatusRules.
+// The rules decide both the status GetInstanceStatus returns and the one
+// stored when events are registered or the statuses reconciled, which in turn
+// drives the completion and safe mode consequences of the events. The first
+// rule matching an event applies, so more specific rules must come first.
+// Events can only lead to the updating, complete and failed statuses.
+func OptionInstanceStatusRules(rules []InstanceStatusRule) func(*API) error {
+	return func(api *API) error {
+		for _, rule := range rules {
+			switch rule.Status {
+			case InstanceUpdateStatusUpdating, InstanceUpdateStatusComplete, InstanceUpdateStatusFailed:
+			default:
+				return ErrInvalidInstanceStatusRule
+			}
+		}
+		api.instanceStatusRules = rules
+
+		return nil
+	}
+}
+
+// instanceStatusFromRules returns the status the first rule matching the event
+// of the type and result provided leads to, or false if none matches.
+func (api *API) instanceStatusFromRules(etype, result int) (InstanceUpdateStatus, bool) {
+	rules := api.instanceStatusRules
+	if rules == nil {
+		rules = DefaultInstanceStatusRules
+	}
+	for _, rule := range rules {
+		if rule.matches(etype, result) {
+			return rule.Status, true
+		}
+	}
+	return "", false
+}
+
+// GetInstanceStatus returns the status of the instance provided for the
+// application it checked for updates most recently. It's derived from the
+// latest event matching a status rule the instance posted since it was last
+// granted an update, and from the version of its group's channel package,
+// the group's current target:
+//
+//   - a failure event makes it failed, and a progress event updating;
+//   - an instance granted an update that didn't post events yet is updating;
+//   - otherwise, it's complete if it runs the target version, or if the
+//     group has no target and its last event was a completion one, and idle
+//     in any other case.
+func (api *API) GetInstanceStatus(instanceID string) (InstanceUpdateStatus, error) {
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		LeftJoin(goqu.T("groups").As("g"), goqu.On(goqu.I("g.id").Eq(goqu.I("ia.group_id")))).
+		LeftJoin(goqu.T("channel").As("c"), goqu.On(goqu.I("c.id").Eq(goqu.I("g.channel_id")))).
+		LeftJoin(goqu.T("package").As("p"), goqu.On(goqu.I("p.id").Eq(goqu.I("c.package_id")))).
+		Select("ia.application_id", "ia.version", "ia.last_update_granted_ts", "ia.update_in_progress", "p.version").
+		Where(goqu.I("ia.instance_id").Eq(instanceID)).
+		Order(goqu.I("ia.last_check_for_updates").Desc()).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return "", err
+	}
+	var (
+		appID               string
+		version             string
+		lastUpdateGrantedTs null.Time
+		updateInProgress    bool
+		targetVersion       null.String
+	)
+	err = api.db.QueryRow(query).Scan(&appID, &version, &lastUpdateGrantedTs, &updateInProgress, &targetVersion)
+	if err != nil {
+		return "", err
+	}
+	if err := api.checkAppTeam(appID); err != nil {
+		return "", err
+	}
+
+	eventsQuery := goqu.From(goqu.T("event").As("e")).
+		Join(goqu.T("event_type").As("et"), goqu.On(goqu.I("et.id").Eq(goqu.I("e.event_type_id")))).
+		Select("et.type", "et.result").
+		Where(
+			goqu.I("e.instance_id").Eq(instanceID),
+			goqu.I("e.application_id").Eq(appID),
+		).
+		Order(goqu.I("e.created_ts").Desc(), goqu.I("e.id").Desc())
+	if lastUpdateGrantedTs.Valid {
+		eventsQuery = eventsQuery.Where(goqu.I("e.created_ts").Gte(lastUpdateGrantedTs.Time))
+	}
+	query, _, err = eventsQuery.ToSQL()
+	if err != nil {
+		return "", err
+	}
+	eventStatus, err := api.latestInstanceStatusFromEvents(query)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case eventStatus == InstanceUpdateStatusFailed, eventStatus == InstanceUpdateStatusUpdating:
+		return eventStatus, nil
+	case eventStatus == "" && updateInProgress:
+		return InstanceUpdateStatusUpdating, nil
+	case targetVersion.Valid && version == targetVersion.String:
+		return InstanceUpdateStatusComplete, nil
+	case !targetVersion.Valid && eventStatus == InstanceUpdateStatusComplete:
+		return InstanceUpdateStatusComplete, nil
+	}
+	return InstanceUpdateStatusIdle, nil
+}
+
+// latestInstanceStatusFromEvents returns the status the first event returned
+// by the query provided, latest first, that matches a status rule leads to,
+// or an empty status if none matches.
+func (api *API) latestInstanceStatusFromEvents(query string) (InstanceUpdateStatus, error) {
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var etype, result int
+		if err := rows.Scan(&etype, &result); err != nil {
+			return "", err
+		}
+		if status, ok := api.instanceStatusFromRules(etype, result); ok {
+			return status, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", nil
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetInstanceStatus(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err = a.GetInstanceStatus(uuid.New().String())
+	assert.Equal(t, ErrNotFound, err)
+
+	instanceID := uuid.New().String()
+	checkStatus := func(expected InstanceUpdateStatus, msg string) {
+		t.Helper()
+		status, err := a.GetInstanceStatus(instanceID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, status, msg)
+	}
+	postEvent := func(etype, result int) {
+		t.Helper()
+		clock.Advance(time.Minute)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, etype, result, "12.0.0", ""))
+	}
+
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	checkStatus(InstanceUpdateStatusIdle, "An instance not running the target version is idle.")
+
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	checkStatus(InstanceUpdateStatusUpdating, "An instance granted an update is updating.")
+
+	postEvent(EventUpdateDownloadStarted, ResultSuccess)
+	checkStatus(InstanceUpdateStatusUpdating, "")
+	postEvent(EventUpdateDownloadFinished, ResultSuccess)
+	checkStatus(InstanceUpdateStatusUpdating, "")
+	postEvent(EventUpdateInstalled, ResultSuccess)
+	checkStatus(InstanceUpdateStatusUpdating, "")
+
+	postEvent(EventUpdateComplete, ResultSuccessReboot)
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	checkStatus(InstanceUpdateStatusComplete, "An instance that completed the update to the target version is complete.")
+
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tChannel.PackageID = null.StringFrom(tPkg2.ID)
+	require.NoError(t, a.UpdateChannel(tChannel))
+	checkStatus(InstanceUpdateStatusIdle, "A complete instance becomes idle when the target changes.")
+
+	clock.Advance(time.Minute)
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	checkStatus(InstanceUpdateStatusUpdating, "")
+	postEvent(EventUpdateDownloadStarted, ResultSuccess)
+	postEvent(EventUpdateDownloadFinished, ResultFailed)
+	checkStatus(InstanceUpdateStatusFailed, "An instance reporting a failure is failed.")
+
+	// Custom rules change how the events are interpreted.
+	require.NoError(t, OptionInstanceStatusRules([]InstanceStatusRule{
+		{EventType: EventUpdateDownloadFinished, Result: ResultFailed, Status: InstanceUpdateStatusUpdating},
+	})(a))
+	checkStatus(InstanceUpdateStatusUpdating, "")
+
+	assert.Equal(t, ErrInvalidInstanceStatusRule, OptionInstanceStatusRules([]InstanceStatusRule{
+		{EventType: EventUpdateComplete, Result: ResultSuccessReboot, Status: InstanceUpdateStatusIdle},
+	})(a))
+}
+
+func TestInstanceStatusRules_RegisterEvent(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionInstanceStatusRules([]InstanceStatusRule{
+		{EventType: EventUpdateComplete, Result: ResultSuccess, Status: InstanceUpdateStatusComplete},
+		{EventType: EventUpdateDownloadStarted, Result: ResultFailed, Status: InstanceUpdateStatusUpdating},
+	}))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tInstance, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+
+	_, err = a.GetUpdatePackage(tInstance.ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	// The stored status follows the same rules as GetInstanceStatus.
+	require.NoError(t, a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultFailed, "12.0.0", ""))
+	instance, _ := a.GetInstance(tInstance.ID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusDownloading)), instance.Application.Status)
+
+	require.NoError(t, a.RegisterEvent(tInstance.ID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccess, "12.0.0", ""))
+	instance, _ = a.GetInstance(tInstance.ID, tApp.ID)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusComplete)), instance.Application.Status)
+
+	fixed, err := a.ReconcileInstanceStatuses(tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, fixed, "Reconciling follows the rules too.")
+
+	group, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.False(t, group.RolloutInProgress, "The rollout completed.")
+}
@@ -8,7 +8,7 @@ var (
 	appInstancesPerChannelMetricSQL string = fmt.Sprintf(`
 SELECT a.name AS app_name, ia.version AS version, c.name AS channel_name, count(ia.version) AS instances_count
 FROM instance_application ia, application a, channel c, groups g
-WHERE a.id = ia.application_id AND ia.group_id = g.id AND g.channel_id = c.id AND %s
+WHERE a.id = ia.application_id AND a.deleted_at IS NULL AND ia.group_id = g.id AND g.channel_id = c.id AND %s
 GROUP BY app_name, version, channel_name
 ORDER BY app_name, version, channel_name
 `, ignoreFakeInstanceCondition("ia.instance_id"))
@@ -16,7 +16,7 @@ ORDER BY app_name, version, channel_name
 	failedUpdatesSQL string = fmt.Sprintf(`
 SELECT a.name AS app_name, count(*) as fail_count
 FROM application a, event e, event_type et
-WHERE a.id = e.application_id AND e.event_type_id = et.id AND et.result = 0 AND et.type = 3 AND %s
+WHERE a.id = e.application_id AND a.deleted_at IS NULL AND e.event_type_id = et.id AND et.result = 0 AND et.type = 3 AND %s
 GROUP BY app_name
 ORDER BY app_name
 `, ignoreFakeInstanceCondition("e.instance_id"))
@@ -31,7 +31,7 @@ type AppInstancesPerChannelMetric struct {
 
 func (api *API) GetAppInstancesPerChannelMetrics() ([]AppInstancesPerChannelMetric, error) {
 	var metrics []AppInstancesPerChannelMetric
-	rows, err := api.db.Queryx(appInstancesPerChannelMetricSQL)
+	rows, err := api.readDB().Queryx(appInstancesPerChannelMetricSQL)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +57,7 @@ type FailedUpdatesMetric struct {
 
 func (api *API) GetFailedUpdatesMetrics() ([]FailedUpdatesMetric, error) {
 	var metrics []FailedUpdatesMetric
-	rows, err := api.db.Queryx(failedUpdatesSQL)
+	rows, err := api.readDB().Queryx(failedUpdatesSQL)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"regexp"
+)
+
+var (
+	// ErrInvalidOCIImage error indicates that the registry, repository or
+	// digest of an OCI package are missing or not valid.
+	ErrInvalidOCIImage = newValidationError("nebraska: invalid oci image reference")
+
+	// ociRegistryRegexp matches a registry host name, optionally followed by
+	// a port.
+	ociRegistryRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]+)?$`)
+
+	// ociRepositoryRegexp matches a repository name made of slash separated
+	// path components, as defined by the OCI distribution spec.
+	ociRepositoryRegexp = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+	// ociDigestRegexp matches a sha256 or sha512 content digest.
+	ociDigestRegexp = regexp.MustCompile(`^(sha256:[a-f0-9]{64}|sha512:[a-f0-9]{128})$`)
+)
+
+// OCIImageReference returns the reference of the image of the OCI package, in
+// the registry/repository@digest form.
+func (pkg *Package) OCIImageReference() string {
+	return pkg.OCIRegistry.String + "/" + pkg.OCIRepository.String + "@" + pkg.OCIDigest.String
+}
+
+// validateOCIImage checks that OCI packages have a valid registry, repository
+// and digest, and sets their URL to the image reference when it's empty.
+func validateOCIImage(pkg *Package) error {
+	if pkg.Type != PkgTypeOCI {
+		return nil
+	}
+	if !ociRegistryRegexp.MatchString(pkg.OCIRegistry.String) ||
+		!ociRepositoryRegexp.MatchString(pkg.OCIRepository.String) ||
+		!ociDigestRegexp.MatchString(pkg.OCIDigest.String) {
+		return ErrInvalidOCIImage
+	}
+	if pkg.URL == "" {
+		pkg.URL = "oci://" + pkg.OCIImageReference()
+	}
+	return nil
+}
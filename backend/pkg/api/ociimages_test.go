@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestValidateOCIImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0f", 32)
+
+	pkg := &Package{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io"), OCIRepository: null.StringFrom("kinvolk/agent"), OCIDigest: null.StringFrom(digest)}
+	require.NoError(t, validateOCIImage(pkg))
+	assert.Equal(t, "oci://ghcr.io/kinvolk/agent@"+digest, pkg.URL)
+
+	pkg = &Package{Type: PkgTypeOCI, URL: "https://ghcr.io", OCIRegistry: null.StringFrom("localhost:5000"), OCIRepository: null.StringFrom("agent"), OCIDigest: null.StringFrom("sha512:" + strings.Repeat("0f", 64))}
+	require.NoError(t, validateOCIImage(pkg))
+	assert.Equal(t, "https://ghcr.io", pkg.URL)
+
+	invalid := []*Package{
+		{Type: PkgTypeOCI, OCIRepository: null.StringFrom("kinvolk/agent"), OCIDigest: null.StringFrom(digest)},
+		{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io/"), OCIRepository: null.StringFrom("kinvolk/agent"), OCIDigest: null.StringFrom(digest)},
+		{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io"), OCIRepository: null.StringFrom("Kinvolk/Agent"), OCIDigest: null.StringFrom(digest)},
+		{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io"), OCIRepository: null.StringFrom("kinvolk/agent:latest"), OCIDigest: null.StringFrom(digest)},
+		{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io"), OCIRepository: null.StringFrom("kinvolk/agent"), OCIDigest: null.StringFrom("sha256:abc")},
+		{Type: PkgTypeOCI, OCIRegistry: null.StringFrom("ghcr.io"), OCIRepository: null.StringFrom("kinvolk/agent")},
+	}
+	for _, pkg := range invalid {
+		assert.Equal(t, ErrInvalidOCIImage, validateOCIImage(pkg))
+	}
+
+	// Other packages don't need an image reference.
+	assert.NoError(t, validateOCIImage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg"}))
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	// ErrInvalidPackageArtifact error indicates that the name or the size of a
+	// package artifact are not valid.
+	ErrInvalidPackageArtifact = newValidationError("nebraska: invalid package artifact")
+
+	// cachedPackageArtifacts caches the artifacts of the packages, keyed by
+	// package id, as they are needed on every update offered. Entries are
+	// removed through invalidatePackageArtifacts() each time the artifacts of
+	// their package change. The lock is held while loading an entry, so an
+	// invalidation can't be overwritten by a stale load.
+	cachedPackageArtifacts     = make(map[string][]PackageArtifact)
+	cachedPackageArtifactsLock sync.RWMutex
+)
+
+// PackageArtifact represents an additional payload shipped with a package,
+// like an OCI artifact accompanying a Flatcar image, that has to be updated
+// along with it. Artifacts are offered in the same manifest as the package's
+// payload, and are downloaded from the same URLs, so their name is relative
+// to them.
+type PackageArtifact struct {
+	ID        string      `db:"id" json:"id"`
+	PackageID string      `db:"package_id" json:"package_id"`
+	Name      string      `db:"name" json:"name"`
+	Size      null.String `db:"size" json:"size"`
+	Hash      null.String `db:"hash" json:"hash"`
+	Sha256    string      `db:"sha256" json:"sha256"`
+	Required  bool        `db:"required" json:"required"`
+	CreatedTs time.Time   `db:"created_ts" json:"created_ts"`
+}
+
+// AddPackageArtifact registers the provided artifact for its package.
+func (api *API) AddPackageArtifact(artifact *PackageArtifact) (*PackageArtifact, error) {
+	if artifact.Name == "" {
+		return nil, ErrInvalidPackageArtifact
+	}
+	if artifact.Size.Valid {
+		if _, err := strconv.ParseUint(artifact.Size.String, 10, 64); err != nil {
+			return nil, ErrInvalidPackageArtifact
+		}
+	}
+	if err := api.checkPackageTeam(artifact.PackageID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.Insert("package_artifact").
+		Cols("package_id", "name", "size", "hash", "sha256", "required").
+		Vals(goqu.Vals{
+			artifact.PackageID,
+			artifact.Name,
+			artifact.Size,
+			artifact.Hash,
+			artifact.Sha256,
+			artifact.Required,
+		}).
+		Returning(goqu.T("package_artifact").All()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.db.QueryRowx(query).StructScan(artifact); err != nil {
+		return nil, wrapUniqueViolation(err, "package artifact %q", artifact.Name)
+	}
+	invalidatePackageArtifacts(artifact.PackageID)
+	return artifact, nil
+}
+
+// DeletePackageArtifact removes the artifact identified by the id provided
+// from the given package.
+func (api *API) DeletePackageArtifact(packageID, artifactID string) error {
+	if err := api.checkPackageTeam(packageID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("package_artifact").
+		Where(goqu.C("id").Eq(artifactID), goqu.C("package_id").Eq(packageID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	invalidatePackageArtifacts(packageID)
+	return nil
+}
+
+// GetPackageArtifacts returns the artifacts of the package provided, in the
+// order they were added. They are served from a cache, as they are part of
+// every update offered for the package.
+func (api *API) GetPackageArtifacts(packageID string) ([]*PackageArtifact, error) {
+	if err := api.checkPackageTeam(packageID); err != nil {
+		return nil, err
+	}
+	cachedPackageArtifactsLock.RLock()
+	cached, ok := cachedPackageArtifacts[packageID]
+	cachedPackageArtifactsLock.RUnlock()
+	if !ok {
+		var err error
+		if cached, err = api.loadPackageArtifacts(packageID); err != nil {
+			return nil, err
+		}
+	}
+	// Callers get their own copies, so they can't modify the cached ones.
+	artifacts := make([]*PackageArtifact, len(cached))
+	for i := range cached {
+		artifact := cached[i]
+		artifacts[i] = &artifact
+	}
+	return artifacts, nil
+}
+
+// loadPackageArtifacts reads the artifacts of the package provided from the
+// database into cachedPackageArtifacts.
+func (api *API) loadPackageArtifacts(packageID string) ([]PackageArtifact, error) {
+	cachedPackageArtifactsLock.Lock()
+	defer cachedPackageArtifactsLock.Unlock()
+	// A concurrent execution may have loaded them in the meantime.
+	if cached, ok := cachedPackageArtifacts[packageID]; ok {
+		return cached, nil
+	}
+	query, _, err := goqu.From("package_artifact").
+		Where(goqu.C("package_id").Eq(packageID)).
+		Order(goqu.C("created_ts").Asc(), goqu.C("name").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	artifacts := []PackageArtifact{}
+	if err := api.db.Select(&artifacts, query); err != nil {
+		return nil, err
+	}
+	cachedPackageArtifacts[packageID] = artifacts
+	return artifacts, nil
+}
+
+// invalidatePackageArtifacts removes the cached artifacts of the package
+// provided and must be called whenever they are modified.
+func invalidatePackageArtifacts(packageID string) {
+	cachedPackageArtifactsLock.Lock()
+	delete(cachedPackageArtifacts, packageID)
+	cachedPackageArtifactsLock.Unlock()
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestPackageArtifacts(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Version: "640.0.0", ApplicationID: tApp.ID})
+
+	_, err := a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg.ID})
+	assert.Equal(t, ErrInvalidPackageArtifact, err)
+	_, err = a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg.ID, Name: "oem.gz", Size: null.StringFrom("big")})
+	assert.Equal(t, ErrInvalidPackageArtifact, err)
+
+	tOEM, err := a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg.ID, Name: "oem.gz", Size: null.StringFrom("200"), Hash: null.StringFrom("oem-hash"), Sha256: "oem-sha256", Required: true})
+	require.NoError(t, err)
+	tSysext, err := a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg.ID, Name: "sysext.raw", Size: null.StringFrom("300")})
+	require.NoError(t, err)
+	_, err = a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg.ID, Name: "oem.gz"})
+	assert.True(t, errors.Is(err, ErrConflict), "Artifact names are unique in a package.")
+
+	artifacts, err := a.GetPackageArtifacts(tPkg.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 2)
+	assert.Equal(t, tOEM.ID, artifacts[0].ID)
+	assert.Equal(t, null.StringFrom("200"), artifacts[0].Size)
+	assert.Equal(t, "oem-sha256", artifacts[0].Sha256)
+	assert.True(t, artifacts[0].Required)
+	assert.Equal(t, tSysext.ID, artifacts[1].ID)
+	assert.False(t, artifacts[1].Required)
+
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Version: "650.0.0", ApplicationID: tApp.ID})
+	assert.Equal(t, ErrNoRowsAffected, a.DeletePackageArtifact(tPkg2.ID, tSysext.ID), "Artifacts are only deleted from their package.")
+	assert.NoError(t, a.DeletePackageArtifact(tPkg.ID, tSysext.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.DeletePackageArtifact(tPkg.ID, tSysext.ID))
+
+	// Artifacts go away with their package.
+	require.NoError(t, a.DeletePackage(tPkg.ID))
+	artifacts, err = a.GetPackageArtifacts(tPkg.ID)
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
+
+func TestPackageArtifacts_TeamScope(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam1, _ := a.AddTeam(&Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam2.ID})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Version: "640.0.0", ApplicationID: tApp2.ID})
+	tArtifact2, err := a.AddPackageArtifact(&PackageArtifact{PackageID: tPkg2.ID, Name: "oem.gz"})
+	require.NoError(t, err)
+	team1 := a.WithTeam(tTeam1.ID)
+
+	_, err = team1.AddPackageArtifact(&PackageArtifact{PackageID: tPkg2.ID, Name: "sysext.raw"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetPackageArtifacts(tPkg2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.DeletePackageArtifact(tPkg2.ID, tArtifact2.ID))
+
+	artifacts, err := a.GetPackageArtifacts(tPkg2.ID)
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 1)
+}
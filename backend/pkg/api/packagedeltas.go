@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// PackageDelta represents a delta payload that updates instances running a
+// given version to the version of the package it belongs to, which is offered
+// to them instead of the package's full payload.
+type PackageDelta struct {
+	ID                   string      `db:"id" json:"id"`
+	PackageID            string      `db:"package_id" json:"package_id"`
+	FromVersion          string      `db:"from_version" json:"from_version"`
+	URL                  string      `db:"url" json:"url"`
+	Filename             null.String `db:"filename" json:"filename"`
+	Size                 null.String `db:"size" json:"size"`
+	Hash                 null.String `db:"hash" json:"hash"`
+	Sha256               string      `db:"sha256" json:"sha256"`
+	MetadataSignatureRsa string      `db:"metadata_signature_rsa" json:"metadata_signature_rsa"`
+	MetadataSize         string      `db:"metadata_size" json:"metadata_size"`
+	CreatedTs            time.Time   `db:"created_ts" json:"created_ts"`
+}
+
+// AddPackageDelta registers the provided delta payload for its package.
+func (api *API) AddPackageDelta(delta *PackageDelta) (*PackageDelta, error) {
+	if err := api.checkPackageTeam(delta.PackageID); err != nil {
+		return nil, err
+	}
+	if !isValidSemver(delta.FromVersion) {
+		return nil, ErrInvalidSemver
+	}
+	query, _, err := goqu.Insert("package_delta").
+		Cols("package_id", "from_version", "url", "filename", "size", "hash", "sha256", "metadata_signature_rsa", "metadata_size").
+		Vals(goqu.Vals{
+			delta.PackageID,
+			delta.FromVersion,
+			delta.URL,
+			delta.Filename,
+			delta.Size,
+			delta.Hash,
+			delta.Sha256,
+			delta.MetadataSignatureRsa,
+			delta.MetadataSize,
+		}).
+		Returning(goqu.T("package_delta").All()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.db.QueryRowx(query).StructScan(delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// DeletePackageDelta removes the delta payload identified by the id provided.
+func (api *API) DeletePackageDelta(deltaID string) error {
+	if err := api.checkPackageDeltaTeam(deltaID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("package_delta").
+		Where(goqu.C("id").Eq(deltaID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
+
+// GetPackageDeltas returns the delta payloads of the package provided.
+func (api *API) GetPackageDeltas(packageID string) ([]*PackageDelta, error) {
+	if err := api.checkPackageTeam(packageID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("package_delta").
+		Where(goqu.C("package_id").Eq(packageID)).
+		Order(goqu.C("from_version").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	deltas := []*PackageDelta{}
+	if err := api.db.Select(&deltas, query); err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+// getPackageDelta returns the delta payload updating instances running the
+// version provided to the given package.
+func (api *API) getPackageDelta(packageID, fromVersion string) (*PackageDelta, error) {
+	query, _, err := goqu.From("package_delta").
+		Where(goqu.C("package_id").Eq(packageID), goqu.C("from_version").Eq(fromVersion)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	delta := &PackageDelta{}
+	if err := api.db.QueryRowx(query).StructScan(delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// preferPackageDelta returns the package provided with its payload replaced
+// by the delta payload for instances running the given version, if there is
+// one, or the package untouched otherwise.
+func (api *API) preferPackageDelta(pkg *Package, instanceVersion string) (*Package, error) {
+	delta, err := api.getPackageDelta(pkg.ID, instanceVersion)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return pkg, nil
+	default:
+		return nil, err
+	}
+
+	deltaPkg := *pkg
+	deltaPkg.URL = delta.URL
+	deltaPkg.Filename = delta.Filename
+	deltaPkg.Size = delta.Size
+	deltaPkg.Hash = delta.Hash
+	// Mirrors host the full payload only.
+	deltaPkg.Mirrors = nil
+	if pkg.FlatcarAction != nil {
+		action := *pkg.FlatcarAction
+		action.Sha256 = delta.Sha256
+		action.MetadataSignatureRsa = delta.MetadataSignatureRsa
+		action.MetadataSize = delta.MetadataSize
+		action.IsDelta = true
+		deltaPkg.FlatcarAction = &action
+	}
+	return &deltaPkg, nil
+}
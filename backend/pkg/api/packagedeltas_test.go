@@ -0,0 +1,65 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestPackageDeltas(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Size: null.StringFrom("1000"), Hash: null.StringFrom("full-hash"), Version: "640.0.0", ApplicationID: tApp.ID, Mirrors: StringArray{"http://mirror.url/pkg"}})
+	_, _ = a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "full-sha256", PackageID: tPkg.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err := a.AddPackageDelta(&PackageDelta{PackageID: tPkg.ID, FromVersion: "610", URL: "http://sample.url/delta"})
+	assert.Equal(t, ErrInvalidSemver, err)
+
+	tDelta, err := a.AddPackageDelta(&PackageDelta{PackageID: tPkg.ID, FromVersion: "610.0.0", URL: "http://sample.url/delta", Filename: null.StringFrom("delta.gz"), Size: null.StringFrom("100"), Hash: null.StringFrom("delta-hash"), Sha256: "delta-sha256"})
+	require.NoError(t, err)
+	_, err = a.AddPackageDelta(&PackageDelta{PackageID: tPkg.ID, FromVersion: "610.0.0", URL: "http://sample.url/delta2"})
+	assert.Error(t, err, "There can only be one delta per source version.")
+
+	deltas, err := a.GetPackageDeltas(tPkg.ID)
+	require.NoError(t, err)
+	require.Len(t, deltas, 1)
+	assert.Equal(t, tDelta.ID, deltas[0].ID)
+
+	// An instance running the delta's source version gets the delta.
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "610.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+	assert.Equal(t, tPkg.Version, pkg.Version)
+	assert.Equal(t, "http://sample.url/delta", pkg.URL)
+	assert.Equal(t, null.StringFrom("delta.gz"), pkg.Filename)
+	assert.Equal(t, null.StringFrom("100"), pkg.Size)
+	assert.Equal(t, null.StringFrom("delta-hash"), pkg.Hash)
+	assert.Empty(t, pkg.Mirrors)
+	require.NotNil(t, pkg.FlatcarAction)
+	assert.True(t, pkg.FlatcarAction.IsDelta)
+	assert.Equal(t, "delta-sha256", pkg.FlatcarAction.Sha256)
+
+	// Other instances get the full payload.
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "500.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.URL, pkg.URL)
+	assert.Equal(t, null.StringFrom("full-hash"), pkg.Hash)
+	assert.Equal(t, StringArray{"http://mirror.url/pkg"}, pkg.Mirrors)
+	require.NotNil(t, pkg.FlatcarAction)
+	assert.False(t, pkg.FlatcarAction.IsDelta)
+	assert.Equal(t, "full-sha256", pkg.FlatcarAction.Sha256)
+
+	assert.NoError(t, a.DeletePackageDelta(tDelta.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.DeletePackageDelta(tDelta.ID))
+	deltas, err = a.GetPackageDeltas(tPkg.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deltas)
+}
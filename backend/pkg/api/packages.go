@@ -1,12 +1,20 @@
 package api
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
 	"database/sql"
-	"errors"
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v4"
@@ -24,14 +32,60 @@ const (
 
 	// PkgTypeOther is the generic package type.
 	PkgTypeOther
+
+	// PkgTypeOCI indicates that the package is an OCI container image,
+	// identified by its registry, repository and digest
+	PkgTypeOCI
 )
 
 var (
 	// ErrBlacklistingChannel error indicates that the channel the package is
 	// trying to blacklist is already pointing to the package.
-	ErrBlacklistingChannel = errors.New("nebraska: channel trying to blacklist is already pointing to the package")
+	ErrBlacklistingChannel = newConflictError("nebraska: channel trying to blacklist is already pointing to the package")
+
+	// ErrInvalidPackageRetention error indicates that the number of packages
+	// to keep when pruning old packages is negative.
+	ErrInvalidPackageRetention = newValidationError("nebraska: invalid package retention")
+
+	// ErrDuplicatePackageVersion error indicates that a package with the
+	// same version and architecture already exists in the application.
+	ErrDuplicatePackageVersion = newConflictError("nebraska: duplicate package version")
+
+	// ErrInvalidPackageChecksum error indicates that the hash or the sha256
+	// of a package is not a base64 encoded digest of the expected size.
+	ErrInvalidPackageChecksum = newValidationError("nebraska: invalid package checksum")
+
+	// ErrUnreachablePackageURL error indicates that the url of a package
+	// could not be reached.
+	ErrUnreachablePackageURL = newValidationError("nebraska: unreachable package url")
+
+	// ErrPackageInUse error indicates that the package to delete is served
+	// by the channel of some group.
+	ErrPackageInUse = newConflictError("nebraska: package in use by some groups")
+
+	packageURLClient = &http.Client{Timeout: packageURLCheckTimeout}
 )
 
+const packageURLCheckTimeout = 10 * time.Second
+
+// PackagesBatchError is returned by AddPackages when some of the packages
+// provided could not be added. Errors holds the error of each package, in
+// the same order they were provided, being nil for the valid ones.
+type PackagesBatchError struct {
+	Errors []error
+}
+
+// Error implements the error interface.
+func (e *PackagesBatchError) Error() string {
+	failed := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("nebraska: %d of %d packages could not be added", failed, len(e.Errors))
+}
+
 // Package represents a Nebraska application's package.
 type Package struct {
 	ID                string         `db:"id" json:"id"`
@@ -47,40 +101,251 @@ type Package struct {
 	ApplicationID     string         `db:"application_id" json:"application_id"`
 	FlatcarAction     *FlatcarAction `db:"flatcar_action" json:"flatcar_action"`
 	Arch              Arch           `db:"arch" json:"arch"`
+	Mirrors           StringArray    `db:"mirrors" json:"mirrors"`
+	// MinPreviousVersion is the oldest version instances can update to
+	// this package from, older ones have to go through an intermediate
+	// package first.
+	MinPreviousVersion null.String `db:"min_previous_version" json:"min_previous_version"`
+	// Revision is increased on every update of the package, it's used to
+	// detect concurrent modifications.
+	Revision int `db:"revision" json:"revision"`
+	// OCIRegistry, OCIRepository and OCIDigest identify the image of OCI
+	// packages.
+	OCIRegistry   null.String `db:"oci_registry" json:"oci_registry"`
+	OCIRepository null.String `db:"oci_repository" json:"oci_repository"`
+	OCIDigest     null.String `db:"oci_digest" json:"oci_digest"`
+	// Severity is one of PackageSeverityRoutine (the default),
+	// PackageSeverityImportant or PackageSeverityCritical. Groups can let
+	// severe enough packages bypass their update windows.
+	Severity string `db:"severity" json:"severity"`
 }
 
-// AddPackage registers the provided package.
+// AddPackage registers the provided package. When package URLs validation is
+// enabled, its URL and mirrors must be reachable.
 func (api *API) AddPackage(pkg *Package) (*Package, error) {
+	if err := api.validateNewPackage(pkg); err != nil {
+		return nil, err
+	}
+	if api.validatePackageURLs {
+		if err := checkPackageURLs(pkg); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AddPackage - could not roll back")
+		}
+	}()
+
+	if err := api.insertPackage(tx, pkg); err != nil {
+		return nil, err
+	}
+	if err := api.recordPackageCreated(tx, pkg); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// AddPackages registers the provided packages in a single transaction, so
+// either all of them are added or none is. Besides the checks done by
+// AddPackage, the packages' checksums must be valid, their versions must not
+// be in use yet in their application and architecture and, when package URLs
+// validation is enabled, their URLs must be reachable. When any package is
+// not valid, a *PackagesBatchError with the error of each package is
+// returned.
+func (api *API) AddPackages(pkgs []*Package) ([]*Package, error) {
+	errs := make([]error, len(pkgs))
+	failed := false
+	versions := make(map[string]struct{}, len(pkgs))
+	for i, pkg := range pkgs {
+		if err := api.validateBatchPackage(pkg); err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+		key := pkg.ApplicationID + "/" + pkg.Version + "/" + pkg.Arch.String()
+		if _, ok := versions[key]; ok {
+			errs[i] = ErrDuplicatePackageVersion
+			failed = true
+			continue
+		}
+		versions[key] = struct{}{}
+	}
+	if failed {
+		return nil, &PackagesBatchError{Errors: errs}
+	}
+
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("AddPackages - could not roll back")
+		}
+	}()
+
+	for i, pkg := range pkgs {
+		if err := api.insertPackage(tx, pkg); err != nil {
+			errs[i] = err
+			return nil, &PackagesBatchError{Errors: errs}
+		}
+		if err := api.recordPackageCreated(tx, pkg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+// recordPackageCreated records in the audit log the creation of the package
+// provided, within the transaction that inserted it.
+func (api *API) recordPackageCreated(tx *sqlx.Tx, pkg *Package) error {
+	pkgAfterAdd, err := api.getPackageAuditSnapshot(tx, pkg.ID)
+	if err != nil {
+		return err
+	}
+	return api.recordAuditEntry(tx, activityEntityCreated, auditEntityPackage, pkg.ID, pkg.ApplicationID, nil, pkgAfterAdd)
+}
+
+// validateNewPackage checks that the package provided can be registered.
+func (api *API) validateNewPackage(pkg *Package) error {
+	if err := api.checkAppTeam(pkg.ApplicationID); err != nil {
+		return err
+	}
 	if !isValidSemver(pkg.Version) {
-		return nil, ErrInvalidSemver
+		return ErrInvalidSemver
+	}
+	if pkg.MinPreviousVersion.String != "" && !isValidSemver(pkg.MinPreviousVersion.String) {
+		return ErrInvalidSemver
 	}
 	if !pkg.Arch.IsValid() {
-		return nil, ErrInvalidArch
+		return ErrInvalidArch
+	}
+	if err := validateOCIImage(pkg); err != nil {
+		return err
+	}
+	if err := normalizePackageSeverity(pkg); err != nil {
+		return err
 	}
 	if len(pkg.ChannelsBlacklist) > 0 {
 		blacklistedChannels, err := api.getSpecificChannels(pkg.ChannelsBlacklist...)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		for _, channel := range blacklistedChannels {
 			if pkg.Arch != channel.Arch {
-				return nil, ErrArchMismatch
+				return ErrArchMismatch
 			}
 		}
 	}
+	return nil
+}
 
-	tx, err := api.db.Beginx()
+// validateBatchPackage checks that the package provided can be registered as
+// part of a batch of packages.
+func (api *API) validateBatchPackage(pkg *Package) error {
+	if err := api.validateNewPackage(pkg); err != nil {
+		return err
+	}
+	if err := validatePackageChecksums(pkg); err != nil {
+		return err
+	}
+	_, err := api.GetPackageByVersionAndArch(pkg.ApplicationID, pkg.Version, pkg.Arch)
+	switch err {
+	case nil:
+		return ErrDuplicatePackageVersion
+	case sql.ErrNoRows:
+	default:
+		return err
+	}
+	if api.validatePackageURLs {
+		if err := checkPackageURLs(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePackageChecksums checks that the package's hash, if any, is a base64
+// encoded SHA-1 digest and that its Flatcar action's sha256, if any, is a
+// base64 encoded SHA-256 digest.
+func validatePackageChecksums(pkg *Package) error {
+	if pkg.Hash.String != "" && !isValidChecksum(pkg.Hash.String, sha1.Size) {
+		return ErrInvalidPackageChecksum
+	}
+	if pkg.FlatcarAction != nil && pkg.FlatcarAction.Sha256 != "" && !isValidChecksum(pkg.FlatcarAction.Sha256, sha256.Size) {
+		return ErrInvalidPackageChecksum
+	}
+	return nil
+}
+
+// isValidChecksum reports whether the checksum provided is a base64 encoded
+// digest of the given size.
+func isValidChecksum(checksum string, size int) bool {
+	digest, err := base64.StdEncoding.DecodeString(checksum)
+	return err == nil && len(digest) == size
+}
+
+// checkPackageURLs checks that the package provided can be downloaded from its
+// URL and from each of its mirrors. When the package's size is not set, it's
+// taken from the content length reported for its URL, if any.
+func checkPackageURLs(pkg *Package) error {
+	size, err := checkPackageURL(packageDownloadURL(pkg.URL, pkg.Filename.String))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer func() {
-		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-			logger.Error().Err(err).Msg("AddPackage - could not roll back")
+	for _, mirror := range pkg.Mirrors {
+		if _, err := checkPackageURL(packageDownloadURL(mirror, pkg.Filename.String)); err != nil {
+			return err
 		}
-	}()
+	}
+	if pkg.Size.String == "" && size > 0 {
+		pkg.Size = null.StringFrom(strconv.FormatInt(size, 10))
+	}
+	return nil
+}
 
+// packageDownloadURL returns the URL instances download the package file
+// provided from, as they append the file name to the package's base URL.
+func packageDownloadURL(baseURL, filename string) string {
+	if filename == "" {
+		return baseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + filename
+}
+
+// checkPackageURL checks that the package URL provided is reachable,
+// returning the content length it reports, or -1 if it's unknown.
+func checkPackageURL(url string) (int64, error) {
+	resp, err := packageURLClient.Head(url)
+	if err != nil {
+		return 0, ErrUnreachablePackageURL
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, ErrUnreachablePackageURL
+	}
+	return resp.ContentLength, nil
+}
+
+// insertPackage stores the package provided, with its channels blacklist,
+// mirrors and Flatcar action, as part of the transaction provided.
+func (api *API) insertPackage(tx *sqlx.Tx, pkg *Package) error {
 	query, _, err := goqu.Insert("package").
-		Cols("type", "filename", "description", "size", "hash", "url", "version", "application_id", "arch").
+		Cols("type", "filename", "description", "size", "hash", "url", "version", "application_id", "arch", "min_previous_version", "oci_registry", "oci_repository", "oci_digest", "severity").
 		Vals(goqu.Vals{
 			pkg.Type,
 			pkg.Filename,
@@ -91,15 +356,20 @@ func (api *API) AddPackage(pkg *Package) (*Package, error) {
 			pkg.Version,
 			pkg.ApplicationID,
 			pkg.Arch,
+			pkg.MinPreviousVersion,
+			pkg.OCIRegistry,
+			pkg.OCIRepository,
+			pkg.OCIDigest,
+			pkg.Severity,
 		}).
 		Returning(goqu.T("package").All()).
 		ToSQL()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	err = tx.QueryRowx(query).StructScan(pkg)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if len(pkg.ChannelsBlacklist) > 0 {
 		for _, channelID := range pkg.ChannelsBlacklist {
@@ -108,24 +378,28 @@ func (api *API) AddPackage(pkg *Package) (*Package, error) {
 				Vals(goqu.Vals{pkg.ID, channelID}).
 				ToSQL()
 			if err != nil {
-				return nil, err
+				return err
 			}
 			_, err = tx.Exec(query)
 
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 
+	if err := api.insertPackageMirrors(tx, pkg); err != nil {
+		return err
+	}
+
 	if pkg.Type == PkgTypeFlatcar && pkg.FlatcarAction != nil {
 		query, _, err := goqu.Insert("flatcar_action").
-			Cols("package_id", "sha256").
-			Vals(goqu.Vals{pkg.ID, pkg.FlatcarAction.Sha256}).
+			Cols("package_id", "sha256", "payload_signature", "signing_key_id").
+			Vals(goqu.Vals{pkg.ID, pkg.FlatcarAction.Sha256, pkg.FlatcarAction.PayloadSignature, pkg.FlatcarAction.SigningKeyID}).
 			Returning(goqu.T("flatcar_action").All()).
 			ToSQL()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		flatcarAction := &FlatcarAction{}
 		err = tx.QueryRowx(query).StructScan(flatcarAction)
@@ -135,22 +409,32 @@ func (api *API) AddPackage(pkg *Package) (*Package, error) {
 		case sql.ErrNoRows:
 			pkg.FlatcarAction = nil
 		default:
-			return nil, err
+			return err
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
-	}
-	return pkg, nil
+	return nil
 }
 
 // UpdatePackage updates an existing package using the content of the package
-// provided.
+// provided. If the package's revision is set, the update fails with
+// ErrStaleRevision when the package was modified since that revision was read.
 func (api *API) UpdatePackage(pkg *Package) error {
+	if err := api.checkPackageTeam(pkg.ID); err != nil {
+		return err
+	}
 	if !isValidSemver(pkg.Version) {
 		return ErrInvalidSemver
 	}
+	if pkg.MinPreviousVersion.String != "" && !isValidSemver(pkg.MinPreviousVersion.String) {
+		return ErrInvalidSemver
+	}
+	if err := validateOCIImage(pkg); err != nil {
+		return err
+	}
+	if err := normalizePackageSeverity(pkg); err != nil {
+		return err
+	}
 	tx, err := api.db.Beginx()
 	if err != nil {
 		return err
@@ -160,17 +444,33 @@ func (api *API) UpdatePackage(pkg *Package) error {
 			logger.Error().Err(err).Msg("UpdatePackage - could not roll back")
 		}
 	}()
+	pkgBeforeUpdate, err := api.getPackageAuditSnapshot(tx, pkg.ID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
+	where := []exp.Expression{goqu.C("id").Eq(pkg.ID)}
+	if pkg.Revision > 0 {
+		where = append(where, goqu.C("revision").Eq(pkg.Revision))
+	}
 	query, _, err := goqu.Update("package").
 		Set(goqu.Record{
-			"type":        pkg.Type,
-			"filename":    pkg.Filename,
-			"description": pkg.Description,
-			"size":        pkg.Size,
-			"hash":        pkg.Hash,
-			"url":         pkg.URL,
-			"version":     pkg.Version,
+			"type":                 pkg.Type,
+			"filename":             pkg.Filename,
+			"description":          pkg.Description,
+			"size":                 pkg.Size,
+			"hash":                 pkg.Hash,
+			"url":                  pkg.URL,
+			"version":              pkg.Version,
+			"min_previous_version": pkg.MinPreviousVersion,
+			"oci_registry":         pkg.OCIRegistry,
+			"oci_repository":       pkg.OCIRepository,
+			"oci_digest":           pkg.OCIDigest,
+			"severity":             pkg.Severity,
+			"revision":             goqu.L("revision + 1"),
 		}).
-		Where(goqu.C("id").Eq(pkg.ID)).
+		Where(where...).
 		ToSQL()
 	if err != nil {
 		return err
@@ -183,6 +483,11 @@ func (api *API) UpdatePackage(pkg *Package) error {
 	if err != nil {
 		return err
 	} else if rowsAffected == 0 {
+		if pkg.Revision > 0 {
+			if _, err := api.GetPackage(pkg.ID); err == nil {
+				return ErrStaleRevision
+			}
+		}
 		return ErrNoRowsAffected
 	}
 
@@ -190,14 +495,23 @@ func (api *API) UpdatePackage(pkg *Package) error {
 		return err
 	}
 
+	if err := api.updatePackageMirrors(tx, pkg); err != nil {
+		return err
+	}
+
 	if pkg.Type == PkgTypeFlatcar && pkg.FlatcarAction != nil {
 		if pkg.FlatcarAction.ID == "" {
 			pkg.FlatcarAction.ID = uuid.New().String()
 		}
 		query, _, err = goqu.Insert("flatcar_action").
-			Cols("id", "package_id", "sha256").
-			Vals(goqu.Vals{pkg.FlatcarAction.ID, pkg.ID, pkg.FlatcarAction.Sha256}).
-			OnConflict(goqu.DoUpdate("id", goqu.Record{"sha256": pkg.FlatcarAction.Sha256, "package_id": pkg.ID})).
+			Cols("id", "package_id", "sha256", "payload_signature", "signing_key_id").
+			Vals(goqu.Vals{pkg.FlatcarAction.ID, pkg.ID, pkg.FlatcarAction.Sha256, pkg.FlatcarAction.PayloadSignature, pkg.FlatcarAction.SigningKeyID}).
+			OnConflict(goqu.DoUpdate("id", goqu.Record{
+				"sha256":            pkg.FlatcarAction.Sha256,
+				"payload_signature": pkg.FlatcarAction.PayloadSignature,
+				"signing_key_id":    pkg.FlatcarAction.SigningKeyID,
+				"package_id":        pkg.ID,
+			})).
 			Returning(goqu.T("flatcar_action").All()).
 			ToSQL()
 		if err != nil {
@@ -209,44 +523,218 @@ func (api *API) UpdatePackage(pkg *Package) error {
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	pkgAfterUpdate, err := api.getPackageAuditSnapshot(tx, pkg.ID)
+	if err != nil {
+		return err
+	}
+	if err := api.recordAuditEntry(tx, activityEntityUpdated, auditEntityPackage, pkg.ID, pkgBeforeUpdate.ApplicationID, pkgBeforeUpdate, pkgAfterUpdate); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-// DeletePackage removes the package identified by the id provided.
+// DeletePackage removes the package identified by the id provided. It fails
+// with ErrPackageInUse when some group is serving the package, see
+// GetGroupsUsingPackage.
 func (api *API) DeletePackage(pkgID string) error {
+	return api.deletePackage(pkgID, false)
+}
+
+// ForceDeletePackage removes the package identified by the id provided, even
+// if some group is serving it. The channels pointing to it are left without
+// package.
+func (api *API) ForceDeletePackage(pkgID string) error {
+	return api.deletePackage(pkgID, true)
+}
+
+func (api *API) deletePackage(pkgID string, force bool) error {
+	if err := api.checkPackageTeam(pkgID); err != nil {
+		return err
+	}
+	if !force {
+		groups, err := api.GetGroupsUsingPackage(pkgID)
+		if err != nil {
+			return err
+		}
+		if len(groups) > 0 {
+			return ErrPackageInUse
+		}
+	}
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("deletePackage - could not roll back")
+		}
+	}()
+	pkgBeforeDelete, err := api.getPackageAuditSnapshot(tx, pkgID)
+	if err == sql.ErrNoRows {
+		return ErrNoRowsAffected
+	} else if err != nil {
+		return err
+	}
 	query, _, err := goqu.Delete("package").
 		Where(goqu.C("id").Eq(pkgID)).
 		ToSQL()
 	if err != nil {
 		return err
 	}
-	result, err := api.db.Exec(query)
-	if err != nil {
+	if _, err := tx.Exec(query); err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	if err := api.recordAuditEntry(tx, activityEntityDeleted, auditEntityPackage, pkgID, pkgBeforeDelete.ApplicationID, pkgBeforeDelete, nil); err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNoRowsAffected
+	if err := tx.Commit(); err != nil {
+		return err
 	}
+	invalidatePackageArtifacts(pkgID)
 
 	return nil
 }
 
+// GetGroupsUsingPackage returns the groups currently serving the package
+// provided, those whose channel, or one of their weighted channels, points
+// to it.
+func (api *API) GetGroupsUsingPackage(pkgID string) ([]*Group, error) {
+	if err := api.checkPackageTeam(pkgID); err != nil {
+		return nil, err
+	}
+	channels := goqu.From("channel").
+		Select("id").
+		Where(goqu.C("package_id").Eq(pkgID))
+	weightedGroups := goqu.From("group_channel_weight").
+		Select("group_id").
+		Where(goqu.C("channel_id").In(channels))
+	query, _, err := api.groupsQuery().
+		Where(goqu.Or(
+			goqu.I("groups.channel_id").In(channels),
+			goqu.I("groups.id").In(weightedGroups),
+		)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := api.getGroupsFromQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if groups == nil {
+		groups = []*Group{}
+	}
+	return groups, nil
+}
+
+// PruneOldPackages removes the old packages of the application provided,
+// keeping for each architecture the last keep packages by version. Packages
+// referenced by a channel, newer than the packages of all the channels of
+// their architecture (so they are still waiting to be promoted) or recorded
+// as the last known good package of a group are never removed. It returns
+// the packages removed.
+func (api *API) PruneOldPackages(appID string, keep int) ([]*Package, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		return nil, ErrInvalidPackageRetention
+	}
+
+	query, _, err := api.packagesQuery().
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := api.getPackagesFromQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := make(map[string]bool)
+	newestChannelVersions := make(map[Arch]semver.Version)
+	channels, err := api.getChannels(appID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if channel.Package == nil {
+			continue
+		}
+		protected[channel.Package.ID] = true
+		v, err := semver.Make(channel.Package.Version)
+		if err != nil {
+			continue
+		}
+		if newest, ok := newestChannelVersions[channel.Arch]; !ok || v.GT(newest) {
+			newestChannelVersions[channel.Arch] = v
+		}
+	}
+
+	query, _, err = goqu.From("groups").
+		Select("last_known_good_package_id").
+		Where(goqu.C("application_id").Eq(appID), goqu.C("last_known_good_package_id").IsNotNull()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	var lastKnownGoodPackageIDs []string
+	if err := api.db.Select(&lastKnownGoodPackageIDs, query); err != nil {
+		return nil, err
+	}
+	for _, packageID := range lastKnownGoodPackageIDs {
+		protected[packageID] = true
+	}
+
+	type versionedPackage struct {
+		pkg     *Package
+		version semver.Version
+	}
+	pkgsByArch := make(map[Arch][]versionedPackage)
+	for _, pkg := range pkgs {
+		v, err := semver.Make(pkg.Version)
+		if err != nil {
+			continue
+		}
+		pkgsByArch[pkg.Arch] = append(pkgsByArch[pkg.Arch], versionedPackage{pkg: pkg, version: v})
+	}
+
+	removed := []*Package{}
+	for arch, archPkgs := range pkgsByArch {
+		sort.Slice(archPkgs, func(i, j int) bool {
+			return archPkgs[i].version.GT(archPkgs[j].version)
+		})
+		newestChannelVersion, hasChannelVersion := newestChannelVersions[arch]
+		for i, p := range archPkgs {
+			if i < keep || protected[p.pkg.ID] || (hasChannelVersion && p.version.GT(newestChannelVersion)) {
+				continue
+			}
+			if err := api.DeletePackage(p.pkg.ID); err != nil {
+				return removed, err
+			}
+			removed = append(removed, p.pkg)
+		}
+	}
+
+	return removed, nil
+}
+
 // GetPackage returns the package identified by the id provided.
 func (api *API) GetPackage(pkgID string) (*Package, error) {
+	if err := api.checkPackageTeam(pkgID); err != nil {
+		return nil, err
+	}
 	return api.getPackage(null.StringFrom(pkgID))
 }
 
 // GetPackageByVersionAndArch returns the package identified by the
 // application ID, version and arch provided.
 func (api *API) GetPackageByVersionAndArch(appID, version string, arch Arch) (*Package, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	var pkg Package
 	if !isValidSemver(version) {
 		return nil, fmt.Errorf("Error GetPackageByVersionAndArch version %s is not valid", version)
@@ -261,7 +749,7 @@ func (api *API) GetPackageByVersionAndArch(appID, version string, arch Arch) (*P
 	if err != nil {
 		return nil, err
 	}
-	flatcarAction, err := api.getFlatcarAction(pkg.ID)
+	flatcarAction, err := api.getFlatcarAction(api.db, pkg.ID)
 	switch err {
 	case nil:
 		pkg.FlatcarAction = flatcarAction
@@ -275,6 +763,9 @@ func (api *API) GetPackageByVersionAndArch(appID, version string, arch Arch) (*P
 
 // GetPackages returns all packages associated to the application provided.
 func (api *API) GetPackages(appID string, page, perPage uint64) ([]*Package, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
 	page, perPage = validatePaginationParams(page, perPage)
 	limit, offset := sqlPaginate(page, perPage)
 	query, _, err := api.packagesQuery().
@@ -304,7 +795,7 @@ func (api *API) getPackagesFromQuery(query string) ([]*Package, error) {
 		if err != nil {
 			return nil, err
 		}
-		flatcarAction, err = api.getFlatcarAction(pkg.ID)
+		flatcarAction, err = api.getFlatcarAction(api.db, pkg.ID)
 		switch err {
 		case nil:
 			pkg.FlatcarAction = flatcarAction
@@ -329,7 +820,8 @@ func (api *API) getPackagesFromQuery(query string) ([]*Package, error) {
 func (api *API) packagesQuery() *goqu.SelectDataset {
 	query := goqu.From(goqu.L("package LEFT JOIN package_channel_blacklist pcb ON package.id = pcb.package_id")).
 		Select(goqu.L(`package.*,
-	    array_agg(pcb.channel_id) FILTER (WHERE pcb.channel_id IS NOT NULL) as channels_blacklist
+	    array_agg(pcb.channel_id) FILTER (WHERE pcb.channel_id IS NOT NULL) as channels_blacklist,
+	    (SELECT array_agg(pm.url ORDER BY pm.priority) FROM package_mirrors pm WHERE pm.package_id = package.id) as mirrors
 	    `)).
 		GroupBy("package.id").Order(goqu.L("regexp_matches(version, '(\\d+)\\.(\\d+)\\.(\\d+)')::int[]").Desc())
 	return query
@@ -338,13 +830,13 @@ func (api *API) getFlatcarActionQuery(packageID string) *goqu.SelectDataset {
 	query := goqu.From("flatcar_action").Where(goqu.C("package_id").Eq(packageID))
 	return query
 }
-func (api *API) getFlatcarAction(packageID string) (*FlatcarAction, error) {
+func (api *API) getFlatcarAction(q sqlx.Queryer, packageID string) (*FlatcarAction, error) {
 	query, _, err := api.getFlatcarActionQuery(packageID).ToSQL()
 	if err != nil {
 		return nil, err
 	}
 	flatcarAction := FlatcarAction{}
-	err = api.db.QueryRowx(query).StructScan(&flatcarAction)
+	err = q.QueryRowx(query).StructScan(&flatcarAction)
 	if err != nil {
 		return nil, err
 	}
@@ -361,7 +853,7 @@ func (api *API) getPackage(packageID null.String) (*Package, error) {
 	if err != nil {
 		return nil, err
 	}
-	flatcarAction, err := api.getFlatcarAction(packageEntity.ID)
+	flatcarAction, err := api.getFlatcarAction(api.db, packageEntity.ID)
 	switch err {
 	case nil:
 		packageEntity.FlatcarAction = flatcarAction
@@ -441,3 +933,40 @@ func (api *API) updatePackageBlacklistedChannels(tx *sqlx.Tx, pkg *Package) erro
 
 	return nil
 }
+
+// insertPackageMirrors stores the mirror URLs of the package provided, keeping
+// the order in which they were given as their priority.
+func (api *API) insertPackageMirrors(tx *sqlx.Tx, pkg *Package) error {
+	for priority, url := range pkg.Mirrors {
+		query, _, err := goqu.Insert("package_mirrors").
+			Cols("package_id", "priority", "url").
+			Vals(goqu.Vals{pkg.ID, priority, url}).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updatePackageMirrors replaces the mirror URLs of the package provided with
+// the ones in the updated package entry.
+//
+// This method is part of the transaction that updates a package.
+func (api *API) updatePackageMirrors(tx *sqlx.Tx, pkg *Package) error {
+	query, _, err := goqu.Delete("package_mirrors").
+		Where(goqu.C("package_id").Eq(pkg.ID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	return api.insertPackageMirrors(tx, pkg)
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"gopkg.in/guregu/null.v4"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAddPackage(t *testing.T) {
@@ -46,6 +50,9 @@ func TestAddPackage(t *testing.T) {
 	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "aaa12.1.0"})
 	assert.Equal(t, ErrInvalidSemver, err, "Package version must be a valid semver.")
 
+	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, MinPreviousVersion: null.StringFrom("aaa12.0.0")})
+	assert.Equal(t, ErrInvalidSemver, err, "Package minimum previous version must be a valid semver.")
+
 	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0"})
 	assert.Error(t, err, "App id is required and must be a valid uuid.")
 
@@ -90,6 +97,118 @@ func TestAddPackageFlatcar(t *testing.T) {
 	assert.Equal(t, "sha256:blablablabla", pkg.FlatcarAction.Sha256)
 }
 
+func TestAddPackages(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	_, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.0.0", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	require.NoError(t, err)
+
+	sha1Hash := "w1b2u7mOHBlAHz+0dZ0yYgRA2Fs="
+	sha256Hash := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	_, err = a.AddPackages([]*Package{
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64, Hash: null.StringFrom(sha1Hash)},
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "aaa12.2.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.0.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.3.0", ApplicationID: tApp.ID, Arch: ArchAMD64, Hash: null.StringFrom("sha1:blablablabla")},
+		{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "12.4.0", ApplicationID: tApp.ID, Arch: ArchAMD64, FlatcarAction: &FlatcarAction{Sha256: sha1Hash}},
+	})
+	require.Error(t, err)
+	batchErr, ok := err.(*PackagesBatchError)
+	require.True(t, ok)
+	assert.Equal(t, []error{nil, ErrInvalidSemver, ErrDuplicatePackageVersion, ErrDuplicatePackageVersion, ErrInvalidPackageChecksum, ErrInvalidPackageChecksum}, batchErr.Errors)
+
+	// None of the packages is added when any of them is not valid.
+	pkgs, err := a.GetPackages(tApp.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 1)
+
+	pkgs, err = a.AddPackages([]*Package{
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64, Hash: null.StringFrom(sha1Hash)},
+		{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAArch64},
+		{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "12.4.0", ApplicationID: tApp.ID, Arch: ArchAMD64, FlatcarAction: &FlatcarAction{Sha256: sha256Hash}},
+	})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+	pkg, err := a.GetPackage(pkgs[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "12.4.0", pkg.Version)
+	require.NotNil(t, pkg.FlatcarAction)
+	assert.Equal(t, sha256Hash, pkg.FlatcarAction.Sha256)
+
+	pkgs, err = a.GetPackages(tApp.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 4)
+}
+
+func TestAddPackages_ValidateURLs(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionValidatePackageURLs)
+	require.NoError(t, err)
+	defer a.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pkg" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	_, err = a.AddPackages([]*Package{
+		{Type: PkgTypeOther, URL: server.URL + "/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+		{Type: PkgTypeOther, URL: server.URL + "/missing", Version: "12.2.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+	})
+	require.Error(t, err)
+	batchErr, ok := err.(*PackagesBatchError)
+	require.True(t, ok)
+	assert.Equal(t, []error{nil, ErrUnreachablePackageURL}, batchErr.Errors)
+
+	pkgs, err := a.AddPackages([]*Package{
+		{Type: PkgTypeOther, URL: server.URL + "/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64},
+	})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 1)
+}
+
+func TestAddPackage_ValidateURLs(t *testing.T) {
+	a, err := NewForTest(OptionInitDB, OptionValidatePackageURLs)
+	require.NoError(t, err)
+	defer a.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pkg/update.gz", "/mirror/update.gz":
+			w.Header().Set("Content-Length", "1234")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: server.URL + "/missing/", Filename: null.StringFrom("update.gz"), Version: "12.1.0", ApplicationID: tApp.ID})
+	assert.Equal(t, ErrUnreachablePackageURL, err)
+
+	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: server.URL + "/pkg/", Filename: null.StringFrom("update.gz"), Mirrors: StringArray{server.URL + "/missing/"}, Version: "12.1.0", ApplicationID: tApp.ID})
+	assert.Equal(t, ErrUnreachablePackageURL, err, "Mirrors must be reachable too.")
+
+	pkg, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: server.URL + "/pkg/", Filename: null.StringFrom("update.gz"), Mirrors: StringArray{server.URL + "/mirror"}, Version: "12.1.0", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("1234"), pkg.Size, "The size is taken from the content length.")
+
+	pkg, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: server.URL + "/pkg/", Filename: null.StringFrom("update.gz"), Size: null.StringFrom("1000"), Version: "12.2.0", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("1000"), pkg.Size, "An explicit size is kept.")
+}
+
 func TestUpdatePackage(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -126,6 +245,67 @@ func TestUpdatePackage(t *testing.T) {
 	assert.Equal(t, ArchAll, pkg.Arch)
 }
 
+func TestPackageMirrors(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	tPkg, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Mirrors: []string{"http://mirror1.url/pkg", "http://mirror2.url/pkg"}})
+	assert.NoError(t, err)
+
+	pkg, err := a.GetPackage(tPkg.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StringArray{"http://mirror1.url/pkg", "http://mirror2.url/pkg"}, pkg.Mirrors)
+
+	err = a.UpdatePackage(&Package{ID: tPkg.ID, Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", Mirrors: []string{"http://mirror3.url/pkg", "http://mirror1.url/pkg"}})
+	assert.NoError(t, err)
+	pkg, err = a.GetPackage(tPkg.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StringArray{"http://mirror3.url/pkg", "http://mirror1.url/pkg"}, pkg.Mirrors)
+
+	err = a.UpdatePackage(&Package{ID: tPkg.ID, Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0"})
+	assert.NoError(t, err)
+	pkg, err = a.GetPackage(tPkg.ID)
+	assert.NoError(t, err)
+	assert.Len(t, pkg.Mirrors, 0)
+}
+
+func TestUpdatePackage_StaleRevision(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, tPkg.Revision)
+
+	// Two operators read the same revision of the package.
+	pkg1, err := a.GetPackage(tPkg.ID)
+	require.NoError(t, err)
+	pkg2, err := a.GetPackage(tPkg.ID)
+	require.NoError(t, err)
+
+	pkg1.URL = "http://sample.url/pkg1"
+	assert.NoError(t, a.UpdatePackage(pkg1))
+
+	pkg2.URL = "http://sample.url/pkg2"
+	assert.Equal(t, ErrStaleRevision, a.UpdatePackage(pkg2))
+
+	pkg, err := a.GetPackage(tPkg.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pkg.Revision)
+	assert.Equal(t, "http://sample.url/pkg1", pkg.URL, "The stale write didn't clobber the package.")
+
+	pkg.URL = "http://sample.url/pkg2"
+	assert.NoError(t, a.UpdatePackage(pkg))
+
+	err = a.UpdatePackage(&Package{ID: uuid.New().String(), Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", Revision: 1})
+	assert.Equal(t, ErrNoRowsAffected, err)
+}
+
 func TestUpdatePackageFlatcar(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -204,6 +384,53 @@ func TestDeletePackage(t *testing.T) {
 	assert.Error(t, err, "Package id must be a valid uuid.")
 }
 
+func TestGetGroupsUsingPackage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgWeighted, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tPkgUnused, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.3.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tChannelWeighted, _ := a.AddChannel(&Channel{Name: "test_channel2", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgWeighted.ID)})
+	tGroup1, err := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+	tGroup2, err := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes",
+		ChannelWeights: []ChannelWeight{{ChannelID: tChannel.ID, Weight: 90}, {ChannelID: tChannelWeighted.ID, Weight: 10}}})
+	require.NoError(t, err)
+	_, err = a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+
+	groups, err := a.GetGroupsUsingPackage(tPkg.ID)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.ElementsMatch(t, []string{tGroup1.ID, tGroup2.ID}, []string{groups[0].ID, groups[1].ID})
+
+	groups, err = a.GetGroupsUsingPackage(tPkgWeighted.ID)
+	require.NoError(t, err)
+	require.Len(t, groups, 1, "Groups serving the package through a weighted channel use it too.")
+	assert.Equal(t, tGroup2.ID, groups[0].ID)
+
+	groups, err = a.GetGroupsUsingPackage(tPkgUnused.ID)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	// Only the packages no group uses can be deleted, unless forced.
+	assert.NoError(t, a.DeletePackage(tPkgUnused.ID))
+	err = a.DeletePackage(tPkg.ID)
+	assert.Equal(t, ErrPackageInUse, err)
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = a.GetPackage(tPkg.ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.ForceDeletePackage(tPkg.ID))
+	channel, err := a.GetChannel(tChannel.ID)
+	require.NoError(t, err)
+	assert.False(t, channel.PackageID.Valid)
+}
+
 func TestGetPackage(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -296,3 +523,74 @@ func TestGetPackages(t *testing.T) {
 	_, err = a.GetPackages(uuid.New().String(), 0, 0)
 	assert.NoError(t, err, "should be no error for non existing appID")
 }
+
+func TestPruneOldPackages(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	addPackages := func(arch Arch, versions ...string) map[string]*Package {
+		pkgs := make(map[string]*Package)
+		for _, version := range versions {
+			pkg, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: version, ApplicationID: tApp.ID, Arch: arch})
+			require.NoError(t, err)
+			pkgs[version] = pkg
+		}
+		return pkgs
+	}
+	amd64Pkgs := addPackages(ArchAMD64, "1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0", "1.5.0", "1.6.0", "1.7.0", "1.8.0", "1.9.0")
+	arm64Pkgs := addPackages(ArchAArch64, "1.0.0", "1.1.0", "1.2.0", "1.3.0")
+
+	_, err := a.AddChannel(&Channel{Name: "stable", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(amd64Pkgs["1.2.0"].ID), Arch: ArchAMD64})
+	require.NoError(t, err)
+	_, err = a.AddChannel(&Channel{Name: "beta", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(amd64Pkgs["1.5.0"].ID), Arch: ArchAMD64})
+	require.NoError(t, err)
+	_, err = a.AddChannel(&Channel{Name: "stable_arm64", Color: "green", ApplicationID: tApp.ID, PackageID: null.StringFrom(arm64Pkgs["1.3.0"].ID), Arch: ArchAArch64})
+	require.NoError(t, err)
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	require.NoError(t, err)
+	require.NoError(t, a.setGroupLastKnownGoodPackage(tGroup.ID, amd64Pkgs["1.1.0"].ID))
+
+	_, err = a.PruneOldPackages(tApp.ID, -1)
+	assert.Equal(t, ErrInvalidPackageRetention, err)
+
+	removed, err := a.PruneOldPackages(tApp.ID, 2)
+	require.NoError(t, err)
+	removedIDs := make([]string, 0, len(removed))
+	for _, pkg := range removed {
+		removedIDs = append(removedIDs, pkg.ID)
+	}
+	expectedRemovedIDs := []string{
+		amd64Pkgs["1.0.0"].ID,
+		amd64Pkgs["1.3.0"].ID,
+		amd64Pkgs["1.4.0"].ID,
+		arm64Pkgs["1.0.0"].ID,
+		arm64Pkgs["1.1.0"].ID,
+	}
+	assert.ElementsMatch(t, expectedRemovedIDs, removedIDs)
+
+	pkgs, err := a.GetPackages(tApp.ID, 0, 0)
+	require.NoError(t, err)
+	survivingIDs := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		survivingIDs = append(survivingIDs, pkg.ID)
+	}
+	expectedSurvivingIDs := []string{
+		amd64Pkgs["1.1.0"].ID, // last known good package of a group
+		amd64Pkgs["1.2.0"].ID, // referenced by a channel
+		amd64Pkgs["1.5.0"].ID, // referenced by a channel
+		amd64Pkgs["1.6.0"].ID, // newer than the packages of all channels
+		amd64Pkgs["1.7.0"].ID, // newer than the packages of all channels
+		amd64Pkgs["1.8.0"].ID,
+		amd64Pkgs["1.9.0"].ID,
+		arm64Pkgs["1.2.0"].ID,
+		arm64Pkgs["1.3.0"].ID,
+	}
+	assert.ElementsMatch(t, expectedSurvivingIDs, survivingIDs)
+
+	removed, err = a.PruneOldPackages(tApp.ID, 2)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"github.com/blang/semver/v4"
+	"github.com/doug-martin/goqu/v9"
+)
+
+// PromotionImpact represents the instances that would be offered a package if
+// it was promoted to a channel.
+type PromotionImpact struct {
+	ChannelID              string                  `json:"channel_id"`
+	PackageID              string                  `json:"package_id"`
+	Version                string                  `json:"version"`
+	Groups                 []*PromotionGroupImpact `json:"groups"`
+	TotalInstances         int                     `json:"total_instances"`
+	TotalEligibleInstances int                     `json:"total_eligible_instances"`
+}
+
+// PromotionGroupImpact represents the instances of a group following the
+// channel a package would be promoted to, and how many of them run a version
+// older than the package's one and so would be offered the update.
+type PromotionGroupImpact struct {
+	GroupID           string `json:"group_id"`
+	GroupName         string `json:"group_name"`
+	Instances         int    `json:"instances"`
+	EligibleInstances int    `json:"eligible_instances"`
+}
+
+// promotionPreviewInstance represents an active instance of a group whose
+// current version is checked against the one of the package to promote.
+type promotionPreviewInstance struct {
+	InstanceID string `db:"instance_id"`
+	Version    string `db:"version"`
+}
+
+// PreviewPromotion returns, for each group following the channel provided,
+// either directly or through its channel weights, how many of its active
+// instances would become eligible for an update if the package provided was
+// promoted to the channel, based on the versions they currently run. Nothing
+// is modified. The group policies that may hold back the update, like
+// rollout percentages or update windows, are not taken into account.
+func (api *API) PreviewPromotion(channelID, packageID string) (*PromotionImpact, error) {
+	if err := api.checkChannelTeam(channelID); err != nil {
+		return nil, err
+	}
+	channel, err := api.GetChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := api.validatePackage(packageID, channel.ID, channel.ApplicationID, channel.Arch)
+	if err != nil {
+		return nil, err
+	}
+	pkgVersion, err := semver.Make(pkg.Version)
+	if err != nil {
+		return nil, ErrInvalidSemver
+	}
+
+	groups, err := api.getGroups(channel.ApplicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &PromotionImpact{
+		ChannelID: channel.ID,
+		PackageID: pkg.ID,
+		Version:   pkg.Version,
+		Groups:    []*PromotionGroupImpact{},
+	}
+	for _, group := range groups {
+		if !groupFollowsChannel(group, channel.ID) {
+			continue
+		}
+		instances, err := api.getPromotionPreviewInstances(group.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		groupImpact := &PromotionGroupImpact{GroupID: group.ID, GroupName: group.Name}
+		for _, instance := range instances {
+			// Instances of groups split between several channels only
+			// follow the one they were assigned to.
+			if len(group.ChannelWeights) > 0 && pickWeightedChannel(group.ID, instance.InstanceID, group.ChannelWeights) != channel.ID {
+				continue
+			}
+			groupImpact.Instances++
+			version, err := semver.Make(instance.Version)
+			if err != nil {
+				continue
+			}
+			if version.LT(pkgVersion) {
+				groupImpact.EligibleInstances++
+			}
+		}
+		impact.Groups = append(impact.Groups, groupImpact)
+		impact.TotalInstances += groupImpact.Instances
+		impact.TotalEligibleInstances += groupImpact.EligibleInstances
+	}
+
+	return impact, nil
+}
+
+// groupFollowsChannel checks if some instances of the group provided get the
+// packages of the channel provided.
+func groupFollowsChannel(group *Group, channelID string) bool {
+	if len(group.ChannelWeights) == 0 {
+		return group.ChannelID.String == channelID
+	}
+	for _, weight := range group.ChannelWeights {
+		if weight.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// getPromotionPreviewInstances returns the id and version of the active
+// instances of the group provided.
+func (api *API) getPromotionPreviewInstances(groupID string) ([]promotionPreviewInstance, error) {
+	query, _, err := goqu.From("instance_application").
+		Select("instance_id", "version").
+		Where(goqu.C("group_id").Eq(groupID),
+			goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("instance_id"))).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	instances := []promotionPreviewInstance{}
+	if err := api.readDB().Select(&instances, query); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestPreviewPromotion(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam.ID})
+	tPkgOld, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.0.0", ApplicationID: tApp.ID})
+	tPkgNew, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgOtherApp, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp2.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "stable", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgOld.ID)})
+	tChannel2, _ := a.AddChannel(&Channel{Name: "beta", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgNew.ID)})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup3, _ := a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel2.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	for _, version := range []string{"11.0.0", "12.0.0", "12.1.0"} {
+		_, err := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", version, tApp.ID, tGroup1.ID)
+		require.NoError(t, err)
+	}
+	for _, version := range []string{"12.0.0", "12.0.0"} {
+		_, err := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", version, tApp.ID, tGroup2.ID)
+		require.NoError(t, err)
+	}
+	_, err := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "11.0.0", tApp.ID, tGroup3.ID)
+	require.NoError(t, err)
+
+	impact, err := a.PreviewPromotion(tChannel.ID, tPkgNew.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "12.1.0", impact.Version)
+	require.Len(t, impact.Groups, 2, "Only the groups following the channel are affected.")
+	byGroup := make(map[string]*PromotionGroupImpact)
+	for _, groupImpact := range impact.Groups {
+		byGroup[groupImpact.GroupID] = groupImpact
+	}
+	assert.Equal(t, &PromotionGroupImpact{GroupID: tGroup1.ID, GroupName: "group1", Instances: 3, EligibleInstances: 2}, byGroup[tGroup1.ID])
+	assert.Equal(t, &PromotionGroupImpact{GroupID: tGroup2.ID, GroupName: "group2", Instances: 2, EligibleInstances: 2}, byGroup[tGroup2.ID])
+	assert.Equal(t, 5, impact.TotalInstances)
+	assert.Equal(t, 4, impact.TotalEligibleInstances)
+
+	// The preview doesn't modify the channel.
+	channel, _ := a.GetChannel(tChannel.ID)
+	assert.Equal(t, tPkgOld.ID, channel.PackageID.String)
+
+	_, err = a.PreviewPromotion(tChannel.ID, tPkgOtherApp.ID)
+	assert.Equal(t, ErrInvalidPackage, err)
+	_, err = a.PreviewPromotion(uuid.New().String(), tPkgNew.ID)
+	assert.Error(t, err)
+}
+
+func TestPreviewPromotion_ChannelWeights(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannelA, _ := a.AddChannel(&Channel{Name: "a", Color: "blue", ApplicationID: tApp.ID})
+	tChannelB, _ := a.AddChannel(&Channel{Name: "b", Color: "red", ApplicationID: tApp.ID})
+	weights := []ChannelWeight{{ChannelID: tChannelA.ID, Weight: 1}, {ChannelID: tChannelB.ID, Weight: 1}}
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannelA.ID), PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", ChannelWeights: weights})
+	require.NoError(t, err)
+
+	expected := 0
+	for i := 0; i < 10; i++ {
+		instanceID := uuid.New().String()
+		if pickWeightedChannel(tGroup.ID, instanceID, weights) == tChannelB.ID {
+			expected++
+		}
+		_, err := a.RegisterInstance(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+	}
+
+	impact, err := a.PreviewPromotion(tChannelB.ID, tPkg.ID)
+	require.NoError(t, err)
+	require.Len(t, impact.Groups, 1)
+	assert.Equal(t, expected, impact.Groups[0].Instances, "Only the instances assigned to the channel are counted.")
+	assert.Equal(t, expected, impact.TotalEligibleInstances)
+}
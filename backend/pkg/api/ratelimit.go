@@ -0,0 +1,98 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultUpdateCheckCacheSize is the maximum number of update check decisions
+// kept in memory to rate limit the instances' update checks.
+const defaultUpdateCheckCacheSize = 100000
+
+// updateCheckKey identifies the update checks of an instance for a given
+// application.
+type updateCheckKey struct {
+	instanceID string
+	appID      string
+}
+
+// updateCheckDecision represents the outcome of the last accepted update
+// check of an instance.
+type updateCheckDecision struct {
+	key             updateCheckKey
+	instanceVersion string
+	groupID         string
+	arch            Arch
+	pkg             *Package
+	reason          UpdateDecisionReason
+	err             error
+	checkedAt       time.Time
+}
+
+// updateCheckLimiter enforces a minimum interval between the accepted update
+// checks of each instance, remembering the decision of the last accepted one
+// so that it can be returned to the instances polling too frequently. The
+// decisions are kept in a LRU cache of bounded size, safe for concurrent use.
+type updateCheckLimiter struct {
+	minInterval time.Duration
+	size        int
+
+	mu        sync.Mutex
+	decisions map[updateCheckKey]*list.Element
+	lru       *list.List
+}
+
+func newUpdateCheckLimiter(minInterval time.Duration, size int) *updateCheckLimiter {
+	return &updateCheckLimiter{
+		minInterval: minInterval,
+		size:        size,
+		decisions:   make(map[updateCheckKey]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// get returns the decision of the last update check accepted for the
+// instance provided if it happened less than the minimum interval ago, and
+// the instance is still reporting the same version, group and architecture.
+func (l *updateCheckLimiter) get(instanceID, instanceVersion, appID, groupID string, arch Arch, now time.Time) (*updateCheckDecision, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.decisions[updateCheckKey{instanceID: instanceID, appID: appID}]
+	if !ok {
+		return nil, false
+	}
+	decision := elem.Value.(*updateCheckDecision)
+	if now.Sub(decision.checkedAt) >= l.minInterval || decision.instanceVersion != instanceVersion || decision.groupID != groupID || decision.arch != arch {
+		return nil, false
+	}
+	l.lru.MoveToFront(elem)
+	return decision, true
+}
+
+// add records the decision of an accepted update check, evicting the least
+// recently used decision when the cache is full.
+func (l *updateCheckLimiter) add(decision *updateCheckDecision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.decisions[decision.key]; ok {
+		elem.Value = decision
+		l.lru.MoveToFront(elem)
+		return
+	}
+	l.decisions[decision.key] = l.lru.PushFront(decision)
+	if l.lru.Len() > l.size {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.decisions, oldest.Value.(*updateCheckDecision).key)
+	}
+}
+
+// len returns the number of decisions currently cached.
+func (l *updateCheckLimiter) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestUpdateCheckLimiter(t *testing.T) {
+	now := time.Now()
+	l := newUpdateCheckLimiter(time.Minute, 2)
+
+	l.add(&updateCheckDecision{key: updateCheckKey{"instance1", "app"}, instanceVersion: "1.0.0", groupID: "group", checkedAt: now})
+	l.add(&updateCheckDecision{key: updateCheckKey{"instance2", "app"}, instanceVersion: "1.0.0", groupID: "group", checkedAt: now})
+
+	_, ok := l.get("instance1", "1.0.0", "app", "group", ArchAll, now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = l.get("instance1", "1.0.0", "app", "group", ArchAll, now.Add(time.Minute))
+	assert.False(t, ok, "Decision must expire after the minimum interval.")
+
+	_, ok = l.get("instance1", "1.1.0", "app", "group", ArchAll, now)
+	assert.False(t, ok, "Decision must not be reused for another version.")
+
+	_, ok = l.get("instance1", "1.0.0", "app", "group2", ArchAll, now)
+	assert.False(t, ok, "Decision must not be reused for another group.")
+
+	// instance1 was used more recently, so instance2 gets evicted.
+	l.add(&updateCheckDecision{key: updateCheckKey{"instance3", "app"}, instanceVersion: "1.0.0", groupID: "group", checkedAt: now})
+	assert.Equal(t, 2, l.len())
+
+	_, ok = l.get("instance2", "1.0.0", "app", "group", ArchAll, now)
+	assert.False(t, ok)
+	_, ok = l.get("instance1", "1.0.0", "app", "group", ArchAll, now)
+	assert.True(t, ok)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				instanceID := uuid.New().String()
+				l.add(&updateCheckDecision{key: updateCheckKey{instanceID, "app"}, checkedAt: now})
+				l.get(instanceID, "", "app", "", ArchAll, now)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, l.len())
+}
+
+func TestGetUpdatePackage_UpdateCheckMinInterval(t *testing.T) {
+	start := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+	a, err := NewForTest(OptionInitDB, OptionClock(clock), OptionUpdateCheckMinInterval(time.Minute))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: false, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	instanceID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrUpdatesDisabled, err)
+
+	tGroup.PolicyUpdatesEnabled = true
+	assert.NoError(t, a.UpdateGroup(tGroup))
+
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrUpdatesDisabled, err, "The last decision is returned when polling too frequently.")
+
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err, "Other instances are not affected.")
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	clock.Advance(time.Minute)
+
+	pkg, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// ErrRegionNotFound error indicates that the ip provided couldn't be resolved
+// to a region.
+var ErrRegionNotFound = newNotFoundError("nebraska: region not found")
+
+// nonPublicIPRanges are the ip ranges that can't be resolved to a region, like
+// the private, loopback or link-local ones.
+var nonPublicIPRanges = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+// RegionResolver resolves the ip of the instances to the region they are in,
+// like a country or a country subdivision code.
+type RegionResolver interface {
+	// Resolve returns the region of the ip provided, or ErrRegionNotFound
+	// if it's unknown.
+	Resolve(ip net.IP) (string, error)
+}
+
+// InstanceRegionStats represents the number of instances of an application in
+// a given region. Instances whose region is unknown are counted in an empty
+// region.
+type InstanceRegionStats struct {
+	Region    string `db:"region" json:"region"`
+	Instances int    `db:"instances" json:"instances"`
+}
+
+// OptionRegionResolver will modify API to tag the instances with the region
+// their ip resolves to, using the resolver provided, when they register or
+// their ip changes.
+func OptionRegionResolver(resolver RegionResolver) func(*API) error {
+	return func(api *API) error {
+		api.regionResolver = resolver
+
+		return nil
+	}
+}
+
+// resolveRegion returns the region the ip provided resolves to, or null when
+// no resolver is configured or the ip is not public or unknown.
+func (api *API) resolveRegion(instanceIP string) null.String {
+	if api.regionResolver == nil {
+		return null.String{}
+	}
+	ip := net.ParseIP(instanceIP)
+	if ip == nil || !isPublicIP(ip) {
+		return null.String{}
+	}
+	region, err := api.regionResolver.Resolve(ip)
+	if err != nil {
+		if err != ErrRegionNotFound {
+			logger.Warn().Err(err).Str("ip", instanceIP).Msg("resolveRegion - could not resolve region")
+		}
+		return null.String{}
+	}
+	return null.NewString(region, region != "")
+}
+
+func isPublicIP(ip net.IP) bool {
+	for _, ipRange := range nonPublicIPRanges {
+		if ipRange.Contains(ip) {
+			return false
+		}
+	}
+	return !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	ipNets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets
+}
+
+// GetInstanceStatsByRegion returns the number of active instances of the
+// application provided grouped by the region they are in.
+func (api *API) GetInstanceStatsByRegion(appID string) ([]*InstanceRegionStats, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select(goqu.COALESCE(goqu.I("i.region"), "").As("region"), goqu.COUNT("*").As("instances")).
+		Where(
+			goqu.I("ia.application_id").Eq(appID),
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		GroupBy(goqu.I("i.region")).
+		Order(goqu.I("instances").Desc(), goqu.I("region").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	stats := []*InstanceRegionStats{}
+	if err := api.readDB().Select(&stats, query); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// CIDRRegionResolver resolves ips to regions from a list of ip ranges, the
+// most specific range containing an ip winning.
+type CIDRRegionResolver struct {
+	ranges []cidrRegion
+}
+
+type cidrRegion struct {
+	ipNet  *net.IPNet
+	region string
+}
+
+// NewCIDRRegionResolver returns a resolver loading its ranges from the file
+// provided, which has one "cidr,region" entry per line, like
+// "81.0.0.0/12,DE". Empty lines and lines starting with # are ignored.
+func NewCIDRRegionResolver(path string) (*CIDRRegionResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resolver := &CIDRRegionResolver{}
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected cidr,region", path, lineNumber)
+		}
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		resolver.ranges = append(resolver.ranges, cidrRegion{ipNet: ipNet, region: strings.TrimSpace(fields[1])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return resolver, nil
+}
+
+// Resolve returns the region of the most specific range containing the ip
+// provided.
+func (r *CIDRRegionResolver) Resolve(ip net.IP) (string, error) {
+	region := ""
+	bestPrefix := -1
+	for _, cidr := range r.ranges {
+		if !cidr.ipNet.Contains(ip) {
+			continue
+		}
+		if prefix, _ := cidr.ipNet.Mask.Size(); prefix > bestPrefix {
+			region, bestPrefix = cidr.region, prefix
+		}
+	}
+	if bestPrefix < 0 {
+		return "", ErrRegionNotFound
+	}
+	return region, nil
+}
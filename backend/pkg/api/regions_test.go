@@ -0,0 +1,123 @@
+package api
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRegionResolver map[string]string
+
+func (r stubRegionResolver) Resolve(ip net.IP) (string, error) {
+	region, ok := r[ip.String()]
+	if !ok {
+		return "", ErrRegionNotFound
+	}
+	return region, nil
+}
+
+func TestRegisterInstance_Region(t *testing.T) {
+	resolver := stubRegionResolver{
+		"81.2.69.142":   "GB",
+		"89.160.20.112": "SE",
+		"10.0.0.1":      "private",
+	}
+	a, err := NewForTest(OptionInitDB, OptionRegionResolver(resolver))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	instanceID := uuid.New().String()
+	_, err = a.RegisterInstance(instanceID, "", "81.2.69.142", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err := a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "GB", instance.Region.String)
+
+	// The region follows the ip of the instance.
+	_, err = a.RegisterInstance(instanceID, "", "89.160.20.112", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "SE", instance.Region.String)
+
+	// Private and unknown ips aren't resolved.
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.1", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Region.Valid)
+
+	unknownID := uuid.New().String()
+	_, err = a.RegisterInstance(unknownID, "", "1.1.1.1", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance(unknownID, tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Region.Valid)
+
+	for i := 0; i < 2; i++ {
+		_, err = a.RegisterInstance(uuid.New().String(), "", "81.2.69.142", "1.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+	}
+
+	stats, err := a.GetInstanceStatsByRegion(tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []*InstanceRegionStats{
+		{Region: "", Instances: 2},
+		{Region: "GB", Instances: 2},
+	}, stats)
+}
+
+func TestRegisterInstance_NoRegionResolver(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	tInstance, err := a.RegisterInstance(uuid.New().String(), "", "81.2.69.142", "1.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.False(t, tInstance.Region.Valid)
+}
+
+func TestCIDRRegionResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebraska-regions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "regions.csv")
+	content := "# ip ranges\n81.0.0.0/8,EU\n81.2.0.0/16,GB\n\n2001:db8::/32,DE\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	resolver, err := NewCIDRRegionResolver(path)
+	require.NoError(t, err)
+
+	region, err := resolver.Resolve(net.ParseIP("81.2.69.142"))
+	assert.NoError(t, err)
+	assert.Equal(t, "GB", region, "The most specific range wins.")
+
+	region, err = resolver.Resolve(net.ParseIP("81.3.0.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "EU", region)
+
+	region, err = resolver.Resolve(net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "DE", region)
+
+	_, err = resolver.Resolve(net.ParseIP("8.8.8.8"))
+	assert.Equal(t, ErrRegionNotFound, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("81.0.0.0/8\n"), 0600))
+	_, err = NewCIDRRegionResolver(path)
+	assert.Error(t, err)
+}
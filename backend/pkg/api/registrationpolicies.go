@@ -0,0 +1,163 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	// RegistrationRuleAllow indicates that the instances whose IP matches the
+	// rule are allowed to register.
+	RegistrationRuleAllow = "allow"
+
+	// RegistrationRuleDeny indicates that the instances whose IP matches the
+	// rule are not allowed to register.
+	RegistrationRuleDeny = "deny"
+)
+
+var (
+	// ErrInvalidRegistrationRule error indicates that a rule of an instance
+	// registration policy has an unknown action or an invalid CIDR.
+	ErrInvalidRegistrationRule = newValidationError("nebraska: invalid instance registration rule")
+
+	// ErrInstanceRegistrationBlocked error indicates that the instance
+	// registration policy of the application doesn't allow the instance's IP
+	// to register.
+	ErrInstanceRegistrationBlocked = errors.New("nebraska: instance registration blocked by policy")
+)
+
+// InstanceRegistrationRule represents a rule of an instance registration
+// policy, allowing or denying the registration of the instances whose IP is
+// in the given CIDR.
+type InstanceRegistrationRule struct {
+	Action string `db:"action" json:"action"`
+	CIDR   string `db:"cidr" json:"cidr"`
+}
+
+// InstanceRegistrationPolicy represents the policy restricting which IP ranges
+// the instances of an application may register from. The rules are evaluated
+// in order and the first one matching the instance's IP decides. When no rule
+// matches, the instance is allowed to register unless the policy has allow
+// rules, in which case only the IPs in their ranges are allowed.
+type InstanceRegistrationPolicy struct {
+	Rules []InstanceRegistrationRule `json:"rules"`
+}
+
+// Allows checks if the instance registration policy allows an instance with
+// the IP provided to register.
+func (p *InstanceRegistrationPolicy) Allows(ip string) bool {
+	if len(p.Rules) == 0 {
+		return true
+	}
+	instanceIP := net.ParseIP(ip)
+	if instanceIP == nil {
+		return false
+	}
+
+	hasAllowRules := false
+	for _, rule := range p.Rules {
+		_, ipNet, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(instanceIP) {
+			return rule.Action == RegistrationRuleAllow
+		}
+		if rule.Action == RegistrationRuleAllow {
+			hasAllowRules = true
+		}
+	}
+	return !hasAllowRules
+}
+
+// GetInstanceRegistrationPolicy returns the instance registration policy of
+// the application provided. An application without rules allows all the
+// instances to register.
+func (api *API) GetInstanceRegistrationPolicy(appID string) (*InstanceRegistrationPolicy, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("instance_registration_rule").
+		Select("action", goqu.L("cidr::text").As("cidr")).
+		Where(goqu.C("application_id").Eq(appID)).
+		Order(goqu.C("position").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	policy := &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{}}
+	if err := api.db.Select(&policy.Rules, query); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetInstanceRegistrationPolicy replaces the instance registration policy of
+// the application provided.
+func (api *API) SetInstanceRegistrationPolicy(appID string, policy *InstanceRegistrationPolicy) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	rules := make([]InstanceRegistrationRule, 0, len(policy.Rules))
+	for _, rule := range policy.Rules {
+		if rule.Action != RegistrationRuleAllow && rule.Action != RegistrationRuleDeny {
+			return ErrInvalidRegistrationRule
+		}
+		_, ipNet, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return ErrInvalidRegistrationRule
+		}
+		rules = append(rules, InstanceRegistrationRule{Action: rule.Action, CIDR: ipNet.String()})
+	}
+
+	tx, err := api.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			logger.Error().Err(err).Msg("SetInstanceRegistrationPolicy - could not roll back")
+		}
+	}()
+
+	query, _, err := goqu.Delete("instance_registration_rule").
+		Where(goqu.C("application_id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	for i, rule := range rules {
+		query, _, err := goqu.Insert("instance_registration_rule").
+			Cols("application_id", "position", "action", "cidr").
+			Vals(goqu.Vals{appID, i, rule.Action, rule.CIDR}).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkInstanceRegistration checks if the instance registration policy of the
+// application provided allows an instance with the given IP to register.
+func (api *API) checkInstanceRegistration(appID, instanceIP string) error {
+	policy, err := api.GetInstanceRegistrationPolicy(appID)
+	if err != nil {
+		return err
+	}
+	if !policy.Allows(instanceIP) {
+		return ErrInstanceRegistrationBlocked
+	}
+	return nil
+}
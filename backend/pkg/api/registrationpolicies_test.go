@@ -0,0 +1,111 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceRegistrationPolicyAllows(t *testing.T) {
+	// Without rules every instance is allowed.
+	policy := &InstanceRegistrationPolicy{}
+	assert.True(t, policy.Allows("10.0.0.1"))
+	assert.True(t, policy.Allows("invalid-ip"))
+
+	// With only deny rules, the IPs not denied are allowed.
+	policy = &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{
+		{Action: RegistrationRuleDeny, CIDR: "10.0.0.0/8"},
+	}}
+	assert.False(t, policy.Allows("10.1.2.3"))
+	assert.True(t, policy.Allows("192.168.1.1"))
+	assert.True(t, policy.Allows("2001:db8::1"))
+	assert.False(t, policy.Allows("invalid-ip"))
+
+	// With allow rules, only the IPs allowed are, and the first rule matching
+	// decides.
+	policy = &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{
+		{Action: RegistrationRuleDeny, CIDR: "10.0.1.0/24"},
+		{Action: RegistrationRuleAllow, CIDR: "10.0.0.0/16"},
+		{Action: RegistrationRuleAllow, CIDR: "2001:db8::/32"},
+	}}
+	assert.False(t, policy.Allows("10.0.1.1"))
+	assert.True(t, policy.Allows("10.0.2.1"))
+	assert.True(t, policy.Allows("2001:db8::1"))
+	assert.False(t, policy.Allows("10.1.0.1"))
+	assert.False(t, policy.Allows("192.168.1.1"))
+}
+
+func TestSetInstanceRegistrationPolicy(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	policy, err := a.GetInstanceRegistrationPolicy(tApp.ID)
+	require.NoError(t, err)
+	assert.Empty(t, policy.Rules)
+
+	err = a.SetInstanceRegistrationPolicy(tApp.ID, &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{{Action: "block", CIDR: "10.0.0.0/8"}}})
+	assert.Equal(t, ErrInvalidRegistrationRule, err)
+	err = a.SetInstanceRegistrationPolicy(tApp.ID, &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{{Action: RegistrationRuleDeny, CIDR: "10.0.0.1"}}})
+	assert.Equal(t, ErrInvalidRegistrationRule, err)
+
+	err = a.SetInstanceRegistrationPolicy(tApp.ID, &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{
+		{Action: RegistrationRuleDeny, CIDR: "10.0.1.7/24"},
+		{Action: RegistrationRuleAllow, CIDR: "10.0.0.0/16"},
+	}})
+	require.NoError(t, err)
+
+	policy, err = a.GetInstanceRegistrationPolicy(tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []InstanceRegistrationRule{
+		{Action: RegistrationRuleDeny, CIDR: "10.0.1.0/24"},
+		{Action: RegistrationRuleAllow, CIDR: "10.0.0.0/16"},
+	}, policy.Rules)
+
+	require.NoError(t, a.SetInstanceRegistrationPolicy(tApp.ID, &InstanceRegistrationPolicy{}))
+	policy, err = a.GetInstanceRegistrationPolicy(tApp.ID)
+	require.NoError(t, err)
+	assert.Empty(t, policy.Rules)
+}
+
+func TestRegisterInstance_RegistrationPolicy(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	// Default allow.
+	_, err := a.RegisterInstance(uuid.New().String(), "", "192.168.1.1", "1.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	err = a.SetInstanceRegistrationPolicy(tApp.ID, &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{
+		{Action: RegistrationRuleDeny, CIDR: "10.0.1.0/24"},
+		{Action: RegistrationRuleAllow, CIDR: "10.0.0.0/16"},
+	}})
+	require.NoError(t, err)
+
+	// Allow.
+	instanceID := uuid.New().String()
+	_, err = a.RegisterInstance(instanceID, "", "10.0.2.1", "1.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	_, err = a.GetInstance(instanceID, tApp.ID)
+	assert.NoError(t, err)
+
+	// Deny.
+	for _, ip := range []string{"10.0.1.1", "192.168.1.1"} {
+		instanceID := uuid.New().String()
+		_, err = a.RegisterInstance(instanceID, "", ip, "1.0.0", tApp.ID, tGroup.ID)
+		assert.Equal(t, ErrInstanceRegistrationBlocked, err)
+		_, err = a.GetInstance(instanceID, tApp.ID)
+		assert.Error(t, err)
+	}
+
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.1.1", "1.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrRegisterInstanceFailed, err)
+}
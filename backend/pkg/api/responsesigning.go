@@ -0,0 +1,52 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// responseSignaturePrefix identifies the algorithm of the response
+// signatures, which are HMAC-SHA256 digests in hex.
+const responseSignaturePrefix = "sha256="
+
+// OptionResponseSigningKey will modify API to sign the Omaha responses with
+// the key provided, so clients sharing it can verify that the responses come
+// from this Nebraska before acting on them. An empty key disables it.
+func OptionResponseSigningKey(key string) func(*API) error {
+	return func(api *API) error {
+		if key == "" {
+			api.responseSigningKey = nil
+		} else {
+			api.responseSigningKey = []byte(key)
+		}
+
+		return nil
+	}
+}
+
+// SignsResponses checks if the Omaha responses are signed.
+func (api *API) SignsResponses() bool {
+	return api.responseSigningKey != nil
+}
+
+// SignResponse returns the signature of the serialized Omaha response
+// provided, or false when responses are not signed.
+func (api *API) SignResponse(body []byte) (string, bool) {
+	if !api.SignsResponses() {
+		return "", false
+	}
+	return signResponse(api.responseSigningKey, body), true
+}
+
+// VerifyResponseSignature checks that the signature provided was made over
+// the given serialized Omaha response with the key provided.
+func VerifyResponseSignature(key string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signResponse([]byte(key), body)), []byte(signature))
+}
+
+func signResponse(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(body)
+	return responseSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
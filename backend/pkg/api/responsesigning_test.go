@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignResponse(t *testing.T) {
+	a := &API{}
+	body := []byte("The quick brown fox jumps over the lazy dog")
+
+	_, ok := a.SignResponse(body)
+	assert.False(t, ok, "Responses are not signed without a key.")
+
+	require.NoError(t, OptionResponseSigningKey("key")(a))
+	signature, ok := a.SignResponse(body)
+	require.True(t, ok)
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", signature)
+
+	assert.True(t, VerifyResponseSignature("key", body, signature))
+	assert.False(t, VerifyResponseSignature("other-key", body, signature))
+	assert.False(t, VerifyResponseSignature("key", []byte("The quick brown fox jumps over the lazy cat"), signature))
+
+	require.NoError(t, OptionResponseSigningKey("")(a))
+	_, ok = a.SignResponse(body)
+	assert.False(t, ok)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// EvaluateRollbacks checks the groups that have automatic rollbacks enabled
+// and points the channel of those in which too many updates to the current
+// package failed back to the previous good package.
+func (api *API) EvaluateRollbacks() error {
+	query, _, err := api.groupsQuery().
+		Where(goqu.C("policy_rollback_failure_percentage").IsNotNull(), goqu.C("channel_id").IsNotNull(),
+			goqu.L("application_id IN (SELECT id FROM application WHERE deleted_at IS NULL)")).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	groups, err := api.getGroupsFromQuery(query)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if err := api.evaluateGroupRollback(group); err != nil {
+			logger.Error().Err(err).Str("groupID", group.ID).Msg("EvaluateRollbacks - could not evaluate group rollback")
+		}
+	}
+
+	return nil
+}
+
+// evaluateGroupRollback rolls back the channel of the group provided if the
+// percentage of failed updates to its current package within the group's
+// period interval exceeds the one allowed by its policy.
+func (api *API) evaluateGroupRollback(group *Group) error {
+	if group.Channel == nil || group.Channel.Package == nil {
+		return nil
+	}
+
+	failed, succeeded, err := api.getGroupUpdateResults(group)
+	if err != nil {
+		return err
+	}
+	total := failed + succeeded
+	if total == 0 || failed*100 <= total*int(group.PolicyRollbackFailurePercentage.Int64) {
+		return nil
+	}
+
+	packageID := group.LastKnownGoodPackageID.String
+	if packageID == "" || packageID == group.Channel.PackageID.String {
+		packageID, err = api.getPreviousChannelPackageID(group.Channel.ID, group.Channel.PackageID.String)
+		switch err {
+		case nil:
+		case sql.ErrNoRows:
+			// There is no package to roll back to.
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return api.rollbackChannel(group, packageID)
+}
+
+// getGroupUpdateResults returns the number of failed and successful complete
+// events posted within the group's period interval by the instances of the
+// group provided that were updating to its current package.
+func (api *API) getGroupUpdateResults(group *Group) (failed, succeeded int, err error) {
+	query := `
+	SELECT count(*) FILTER (WHERE et.result = $3), count(*) FILTER (WHERE et.result <> $3)
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	JOIN instance_application ia ON ia.instance_id = e.instance_id AND ia.application_id = e.application_id
+	WHERE ia.group_id = $1 AND ia.last_update_version = $2 AND et.type = $4
+		AND e.created_ts > now() - $5::interval
+	`
+	err = api.db.QueryRow(query, group.ID, group.Channel.Package.Version, ResultFailed, EventUpdateComplete, group.PolicyPeriodInterval).Scan(&failed, &succeeded)
+	if err != nil {
+		return 0, 0, err
+	}
+	return failed, succeeded, nil
+}
+
+// rollbackChannel points the channel of the group provided to the given
+// package, stopping the rollout taking place in the group.
+func (api *API) rollbackChannel(group *Group, packageID string) error {
+	pkg, err := api.validatePackage(packageID, group.Channel.ID, group.ApplicationID, group.Channel.Arch)
+	if err != nil {
+		return err
+	}
+
+	query, _, err := goqu.Update("channel").
+		Set(goqu.Record{"package_id": pkg.ID, "revision": goqu.L("revision + 1")}).
+		Where(goqu.C("id").Eq(group.Channel.ID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := api.db.Exec(query); err != nil {
+		return err
+	}
+
+	if err := api.recordChannelPackage(group.Channel.ID, group.Channel.PackageID, null.StringFrom(pkg.ID)); err != nil {
+		logger.Error().Err(err).Msg("rollbackChannel - could not record channel package history")
+	}
+	if err := api.setGroupRolloutInProgress(group.ID, false); err != nil {
+		logger.Error().Err(err).Msg("rollbackChannel - could not set rollout progress")
+	}
+	if err := api.newGroupActivityEntry(activityRolloutRolledBack, activityError, pkg.Version, group.ApplicationID, group.ID); err != nil {
+		logger.Error().Err(err).Msg("rollbackChannel - could not add group activity")
+	}
+	api.notifyRolloutTransition(group, RolloutStateRolledBack, pkg.Version)
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestEvaluateRollbacks(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.0.0", ApplicationID: tApp.ID})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg1.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyRollbackFailurePercentage: null.IntFrom(50)})
+
+	tChannel.PackageID = null.StringFrom(tPkg2.ID)
+	assert.NoError(t, a.UpdateChannel(tChannel))
+
+	var instanceIDs []string
+	for i := 0; i < 4; i++ {
+		instanceID := uuid.New().String()
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		assert.NoError(t, err)
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+
+	err := a.RegisterEvent(instanceIDs[0], tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "12.0.0", "")
+	assert.NoError(t, err)
+	err = a.RegisterEvent(instanceIDs[1], tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", "")
+	assert.NoError(t, err)
+
+	// Half of the updates failed, which doesn't exceed the policy.
+	assert.NoError(t, a.EvaluateRollbacks())
+	channel, err := a.GetChannel(tChannel.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg2.ID, channel.PackageID.String)
+
+	err = a.RegisterEvent(instanceIDs[2], tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "12.0.0", "")
+	assert.NoError(t, err)
+	err = a.RegisterEvent(instanceIDs[3], tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "12.0.0", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.EvaluateRollbacks())
+	channel, err = a.GetChannel(tChannel.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg1.ID, channel.PackageID.String)
+	assert.True(t, a.hasRecentActivity(activityRolloutRolledBack, ActivityQueryParams{GroupID: tGroup.ID}))
+
+	// Once rolled back, the failures don't affect the channel anymore.
+	assert.NoError(t, a.EvaluateRollbacks())
+	channel, err = a.GetChannel(tChannel.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg1.ID, channel.PackageID.String)
+}
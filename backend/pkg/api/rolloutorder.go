@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	// RolloutOrderRandom indicates that the instances in the rollout
+	// percentage of a group are picked from a hash of their id, so they are
+	// spread across the group regardless of their age.
+	RolloutOrderRandom = "random"
+
+	// RolloutOrderOldestFirst indicates that the rollout percentage of a group
+	// is made of its oldest instances, the ones first seen the earliest.
+	RolloutOrderOldestFirst = "oldest_first"
+
+	// RolloutOrderNewestFirst indicates that the rollout percentage of a group
+	// is made of its newest instances, the ones first seen the latest.
+	RolloutOrderNewestFirst = "newest_first"
+)
+
+// ErrInvalidRolloutOrder error indicates that the order the instances of a
+// group are picked for its rollout percentage is not one of the supported
+// ones.
+var ErrInvalidRolloutOrder = newValidationError("nebraska: invalid rollout order")
+
+// normalizeRolloutOrder checks the rollout order of the group provided,
+// defaulting it to RolloutOrderRandom when unset.
+func normalizeRolloutOrder(group *Group) error {
+	switch group.PolicyRolloutOrder {
+	case "":
+		group.PolicyRolloutOrder = RolloutOrderRandom
+	case RolloutOrderRandom, RolloutOrderOldestFirst, RolloutOrderNewestFirst:
+	default:
+		return ErrInvalidRolloutOrder
+	}
+	return nil
+}
+
+// inRolloutPercentage checks if the instance provided is part of the rollout
+// percentage of its group. With a random order the instance's bucket decides
+// it, while with an age based order the instance is part of it when fewer
+// than the percentage of the active instances of the group come before it,
+// ties in the time they were first seen broken by their ids.
+func (api *API) inRolloutPercentage(instance *Instance, group *Group) (bool, error) {
+	percentage := int(group.PolicyRolloutPercentage.Int64)
+
+	var op string
+	switch group.PolicyRolloutOrder {
+	case RolloutOrderOldestFirst:
+		op = "<"
+	case RolloutOrderNewestFirst:
+		op = ">"
+	default:
+		return rolloutBucket(instance.ID) < percentage, nil
+	}
+
+	// Instances not registered yet, as in dry runs, are the newest ones.
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select(
+			goqu.COUNT("*"),
+			goqu.L(fmt.Sprintf("count(*) filter (where (i.first_seen, i.id) %s (coalesce((select first_seen from instance where id = ?), ?), ?))", op),
+				instance.ID, api.nowUTC(), instance.ID),
+		).
+		Where(
+			goqu.I("ia.group_id").Eq(group.ID),
+			goqu.I("ia.quarantined").IsFalse(),
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+	var total, ahead int
+	if err := api.db.QueryRow(query).Scan(&total, &ahead); err != nil {
+		return false, err
+	}
+	return ahead*100 < total*percentage, nil
+}
@@ -0,0 +1,28 @@
+package api
+
+// ErrInvalidRolloutSchedule error indicates that the rollout schedule of a
+// group ends before it starts.
+var ErrInvalidRolloutSchedule = newValidationError("nebraska: invalid rollout schedule")
+
+// validateRolloutSchedule checks that the rollout schedule of the group
+// provided, when it has both a start and an end, starts before it ends.
+func validateRolloutSchedule(group *Group) error {
+	if group.RolloutStartsAt.Valid && group.RolloutEndsAt.Valid && !group.RolloutStartsAt.Time.Before(group.RolloutEndsAt.Time) {
+		return ErrInvalidRolloutSchedule
+	}
+	return nil
+}
+
+// isWithinRolloutSchedule checks if the group provided can roll out updates
+// now according to its rollout schedule. Groups without a start or an end
+// roll out since or until any time.
+func (api *API) isWithinRolloutSchedule(group *Group) bool {
+	now := api.nowUTC()
+	if group.RolloutStartsAt.Valid && now.Before(group.RolloutStartsAt.Time) {
+		return false
+	}
+	if group.RolloutEndsAt.Valid && !now.Before(group.RolloutEndsAt.Time) {
+		return false
+	}
+	return true
+}
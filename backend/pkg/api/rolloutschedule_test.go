@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetUpdatePackage_RolloutSchedule(t *testing.T) {
+	start := time.Now().UTC()
+	clock := NewMockClock(start)
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes",
+		RolloutStartsAt: null.TimeFrom(start.Add(time.Hour)), RolloutEndsAt: null.TimeFrom(start.Add(2 * time.Hour))})
+	require.NoError(t, err)
+
+	_, reason, err := a.PreviewUpdatePackageWithReason(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAMD64)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err, "The rollout hasn't started yet.")
+	assert.Equal(t, UpdateReasonRolloutSchedule, reason)
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+
+	clock.Advance(90 * time.Minute)
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	clock.Advance(time.Hour)
+	_, reason, err = a.PreviewUpdatePackageWithReason(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAMD64)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err, "The rollout already ended.")
+	assert.Equal(t, UpdateReasonRolloutSchedule, reason)
+
+	// Groups with only an end roll out until then.
+	tGroup.RolloutStartsAt = null.Time{}
+	tGroup.RolloutEndsAt = null.TimeFrom(clock.Now().Add(time.Hour))
+	require.NoError(t, a.UpdateGroup(tGroup))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+}
+
+func TestRolloutScheduleValidation(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	now := time.Now().UTC()
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	_, err := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes",
+		RolloutStartsAt: null.TimeFrom(now.Add(time.Hour)), RolloutEndsAt: null.TimeFrom(now)})
+	assert.Equal(t, ErrInvalidRolloutSchedule, err)
+
+	_, err = a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes",
+		RolloutStartsAt: null.TimeFrom(now), RolloutEndsAt: null.TimeFrom(now)})
+	assert.Equal(t, ErrInvalidRolloutSchedule, err)
+
+	tGroup, err := a.AddGroup(&Group{Name: "group3", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes",
+		RolloutStartsAt: null.TimeFrom(now)})
+	require.NoError(t, err)
+
+	tGroup.RolloutEndsAt = null.TimeFrom(now.Add(-time.Hour))
+	assert.Equal(t, ErrInvalidRolloutSchedule, a.UpdateGroup(tGroup))
+
+	tGroup.RolloutEndsAt = null.TimeFrom(now.Add(time.Hour))
+	require.NoError(t, a.UpdateGroup(tGroup))
+	group, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, now, group.RolloutStartsAt.Time, time.Millisecond)
+	assert.WithinDuration(t, now.Add(time.Hour), group.RolloutEndsAt.Time, time.Millisecond)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+)
+
+// RecalculateRolloutStats repairs the rollout state of the group provided
+// from the event log. The update limits of the rollout policy, like
+// PolicyMaxUpdatesPerPeriod, are enforced counting the instances granted an
+// update and still updating, whose state is updated after their events are
+// stored. When the process stops in between, instances are left updating
+// even though they reported their update completed or failed, and the group
+// may look over its limits forever. Their statuses are recomputed from the
+// events they posted since their last update was granted, and the group's
+// rollout is marked as finished if all its instances completed the update.
+func (api *API) RecalculateRolloutStats(groupID string) error {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return err
+	}
+	if _, err := api.reconcileInstanceStatuses("group_id", groupID); err != nil {
+		return err
+	}
+
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if !group.RolloutInProgress {
+		return nil
+	}
+	updatesStats, err := api.getGroupUpdatesStats(group)
+	if err != nil {
+		return err
+	}
+	if updatesStats.TotalInstances > 0 && updatesStats.UpdatesToCurrentVersionSucceeded == updatesStats.TotalInstances {
+		return api.setGroupRolloutInProgress(groupID, false)
+	}
+	return nil
+}
+
+// RecalculateAllRolloutStats runs RecalculateRolloutStats on the groups with
+// a rollout in progress or instances updating. It's meant to be run on
+// startup, in case the process stopped in the middle of a rollout.
+func (api *API) RecalculateAllRolloutStats() error {
+	query, _, err := goqu.From("groups").
+		Select("id").
+		Where(goqu.Or(
+			goqu.C("rollout_in_progress").IsTrue(),
+			goqu.L("EXISTS (SELECT 1 FROM instance_application ia WHERE ia.group_id = groups.id AND ia.update_in_progress)"),
+		)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	var groupIDs []string
+	if err := api.db.Select(&groupIDs, query); err != nil {
+		return err
+	}
+	for _, groupID := range groupIDs {
+		if err := api.RecalculateRolloutStats(groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
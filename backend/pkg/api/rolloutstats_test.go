@@ -0,0 +1,73 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRecalculateRolloutStats(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	instanceID := uuid.New().String()
+	instanceID2 := uuid.New().String()
+
+	for _, id := range []string{instanceID, instanceID2} {
+		_, err := a.GetUpdatePackage(id, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+	}
+	require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", ""))
+
+	updatesInProgress := func() int {
+		group, err := a.GetGroup(tGroup.ID)
+		require.NoError(t, err)
+		stats, err := a.getGroupUpdatesStats(group)
+		require.NoError(t, err)
+		return stats.UpdatesInProgress
+	}
+	assert.Equal(t, 1, updatesInProgress())
+
+	// The process stopped after storing the event but before updating the
+	// instance's state.
+	_, err := a.db.Exec("UPDATE instance_application SET update_in_progress = true, status = $1, version = '12.0.0' WHERE instance_id = $2", InstanceStatusUpdateGranted, instanceID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updatesInProgress())
+
+	require.NoError(t, a.RecalculateRolloutStats(tGroup.ID))
+	assert.Equal(t, 1, updatesInProgress(), "Instances that didn't post their update result are still updating.")
+	instance, err := a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusComplete)), instance.Application.Status)
+	assert.Equal(t, "12.1.0", instance.Application.Version)
+	group, err := a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.True(t, group.RolloutInProgress)
+	inProgress, err := a.IsGroupRolloutInProgress(tGroup.ID)
+	require.NoError(t, err)
+	assert.True(t, inProgress)
+
+	require.NoError(t, a.RegisterEvent(instanceID2, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", ""))
+	group, err = a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.False(t, group.RolloutInProgress)
+	inProgress, err = a.IsGroupRolloutInProgress(tGroup.ID)
+	require.NoError(t, err)
+	assert.False(t, inProgress, "The cached rollout state follows the group's flag.")
+
+	_, err = a.db.Exec("UPDATE groups SET rollout_in_progress = true WHERE id = $1", tGroup.ID)
+	require.NoError(t, err)
+	require.NoError(t, a.RecalculateAllRolloutStats())
+	assert.Equal(t, 0, updatesInProgress())
+	group, err = a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	assert.False(t, group.RolloutInProgress, "The rollout finished once all the instances updated.")
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"time"
+)
+
+// ErrInvalidSafeModeFailureThreshold error indicates that the number of
+// failed updates that halt the rollout of a group in safe mode is negative.
+var ErrInvalidSafeModeFailureThreshold = newValidationError("nebraska: invalid safe mode failure threshold")
+
+// normalizeSafeModeFailureThreshold checks that the safe mode failure
+// threshold of the group provided is not negative and that its window, if
+// any, is a positive interval, clearing the window when it's empty.
+func (api *API) normalizeSafeModeFailureThreshold(group *Group) error {
+	if group.PolicySafeModeFailureThreshold < 0 {
+		return ErrInvalidSafeModeFailureThreshold
+	}
+	if !group.PolicySafeModeFailureWindow.Valid {
+		return nil
+	}
+	if group.PolicySafeModeFailureWindow.String == "" {
+		group.PolicySafeModeFailureWindow.Valid = false
+		return nil
+	}
+	return api.validatePolicyInterval(group.PolicySafeModeFailureWindow.String)
+}
+
+// safeModeFailureThresholdReached checks if the failed updates to the version
+// provided of the group's instances are enough to halt its rollout. With a
+// threshold of 0 or 1 the rollout is halted when the first update attempted
+// fails. Otherwise, it's halted once the failures posted within the group's
+// failure window, or since the rollout started when it has none, reach the
+// threshold, so a single transient error doesn't stop it.
+func (api *API) safeModeFailureThresholdReached(group *Group, version string, updatesStats *UpdatesStats) (bool, error) {
+	if group.PolicySafeModeFailureThreshold <= 1 {
+		return updatesStats.UpdatesToCurrentVersionAttempted == 1, nil
+	}
+
+	var since time.Time
+	if group.PolicySafeModeFailureWindow.Valid {
+		window, err := api.intervalDuration(group.PolicySafeModeFailureWindow.String)
+		if err != nil {
+			return false, err
+		}
+		since = api.nowUTC().Add(-window)
+	}
+
+	var failures int
+	err := api.db.QueryRow(`
+	SELECT count(*)
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	JOIN instance_application ia ON ia.instance_id = e.instance_id AND ia.application_id = e.application_id
+	WHERE ia.group_id = $1 AND ia.last_update_version = $2 AND et.result = $3
+		AND e.created_ts >= ia.last_update_granted_ts AND e.created_ts >= $4
+	`, group.ID, version, ResultFailed, since).Scan(&failures)
+	if err != nil {
+		return false, err
+	}
+	return failures >= group.PolicySafeModeFailureThreshold, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"time"
+)
+
+const (
+	// PackageSeverityRoutine is the severity of regular updates, the
+	// default one.
+	PackageSeverityRoutine = "routine"
+
+	// PackageSeverityImportant is the severity of updates that should be
+	// rolled out sooner than routine ones.
+	PackageSeverityImportant = "important"
+
+	// PackageSeverityCritical is the severity of updates that must be rolled
+	// out as soon as possible, like security fixes.
+	PackageSeverityCritical = "critical"
+)
+
+var (
+	// ErrInvalidPackageSeverity error indicates that the severity of a
+	// package, or the one of a group's policy, is not one of the supported
+	// ones.
+	ErrInvalidPackageSeverity = newValidationError("nebraska: invalid package severity")
+
+	packageSeverityRanks = map[string]int{
+		PackageSeverityRoutine:   0,
+		PackageSeverityImportant: 1,
+		PackageSeverityCritical:  2,
+	}
+
+	// packageSeverityDeadlines are the time clients are given to apply the
+	// packages of each severity, counted from when the package was created so
+	// every instance gets the same deadline. Routine packages keep the
+	// deadline of their Flatcar action.
+	packageSeverityDeadlines = map[string]time.Duration{
+		PackageSeverityImportant: 7 * 24 * time.Hour,
+		PackageSeverityCritical:  24 * time.Hour,
+	}
+)
+
+// normalizePackageSeverity checks the severity of the package provided,
+// defaulting it to PackageSeverityRoutine when unset.
+func normalizePackageSeverity(pkg *Package) error {
+	if pkg.Severity == "" {
+		pkg.Severity = PackageSeverityRoutine
+	}
+	if _, ok := packageSeverityRanks[pkg.Severity]; !ok {
+		return ErrInvalidPackageSeverity
+	}
+	return nil
+}
+
+// validateWindowsBypassSeverity checks the severity from which packages
+// bypass the update windows and office hours of the group provided, if set.
+func validateWindowsBypassSeverity(group *Group) error {
+	if !group.PolicyWindowsBypassSeverity.Valid {
+		return nil
+	}
+	if _, ok := packageSeverityRanks[group.PolicyWindowsBypassSeverity.String]; !ok {
+		return ErrInvalidPackageSeverity
+	}
+	return nil
+}
+
+// bypassesUpdateWindows checks if the package provided is severe enough to
+// be granted outside of the update windows and office hours of the group
+// given.
+func bypassesUpdateWindows(group *Group, pkg *Package) bool {
+	if !group.PolicyWindowsBypassSeverity.Valid {
+		return false
+	}
+	return packageSeverityRanks[pkg.Severity] >= packageSeverityRanks[group.PolicyWindowsBypassSeverity.String]
+}
+
+// withSeverityDeadline returns a copy of the package provided whose Flatcar
+// action deadline is computed from the package's severity, so clients apply
+// urgent fixes sooner. The deadline stored in the action is kept when it is
+// earlier than the computed one.
+func withSeverityDeadline(pkg *Package) *Package {
+	window, ok := packageSeverityDeadlines[pkg.Severity]
+	if pkg.FlatcarAction == nil || !ok {
+		return pkg
+	}
+	deadline := pkg.CreatedTs.UTC().Add(window)
+	switch stored := pkg.FlatcarAction.Deadline; stored {
+	case "":
+	case forcedUpdateDeadline:
+		return pkg
+	default:
+		if storedDeadline, err := time.Parse(time.RFC3339, stored); err != nil || storedDeadline.Before(deadline) {
+			return pkg
+		}
+	}
+	severePkg := *pkg
+	action := *pkg.FlatcarAction
+	action.Deadline = deadline.Format(time.RFC3339)
+	severePkg.FlatcarAction = &action
+	return &severePkg
+}
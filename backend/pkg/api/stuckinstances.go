@@ -0,0 +1,62 @@
+package api
+
+import (
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// ErrInvalidStuckThreshold indicates that the time instances must have been
+// running the same version to be considered stuck is not positive.
+var ErrInvalidStuckThreshold = newValidationError("nebraska: invalid stuck instances threshold")
+
+// GetStuckInstances returns the active instances of the application provided
+// that have been running the same version for longer than the threshold even
+// though they were granted an update while running it, longest running the
+// same version first.
+func (api *API) GetStuckInstances(appID string, threshold time.Duration) ([]*Instance, error) {
+	if threshold <= 0 {
+		return nil, ErrInvalidStuckThreshold
+	}
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+
+	query, _, err := goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("instance").As("i"), goqu.On(goqu.I("i.id").Eq(goqu.I("ia.instance_id")))).
+		Select("i.id", "i.ip", "i.created_ts", "i.first_seen", "i.alias", "i.arch", "i.board", "i.platform", "i.region", "i.labels", "i.note", "ia.application_id", "ia.group_id", "ia.version", "ia.created_ts",
+			"ia.version_changed_at", "ia.status", "ia.last_check_for_updates", "ia.last_update_granted_ts", "ia.last_update_version", "ia.update_in_progress", "ia.quarantined").
+		Where(
+			goqu.I("ia.application_id").Eq(appID),
+			goqu.I("ia.version_changed_at").Lt(api.nowUTC().Add(-threshold)),
+			goqu.I("ia.last_update_granted_ts").Gte(goqu.I("ia.version_changed_at")),
+			goqu.L("ia.last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("ia.instance_id")),
+		).
+		Order(goqu.I("ia.version_changed_at").Asc(), goqu.I("i.id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	instances := []*Instance{}
+	for rows.Next() {
+		var instance Instance
+		app := &instance.Application
+		err := rows.Scan(&instance.ID, &instance.IP, &instance.CreatedTs, &instance.FirstSeen, &instance.Alias, &instance.Arch, &instance.Board, &instance.Platform, &instance.Region, &instance.Labels, &instance.Note, &app.ApplicationID, &app.GroupID, &app.Version, &app.CreatedTs,
+			&app.VersionChangedAt, &app.Status, &app.LastCheckForUpdates, &app.LastUpdateGrantedTs, &app.LastUpdateVersion, &app.UpdateInProgress, &app.Quarantined)
+		if err != nil {
+			return nil, err
+		}
+		app.InstanceID = instance.ID
+		instances = append(instances, &instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRegisterInstance_VersionChangedAt(t *testing.T) {
+	start := time.Now().UTC()
+	clock := NewMockClock(start)
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	instanceID := uuid.New().String()
+
+	instance, err := a.RegisterInstance(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, start, instance.FirstSeen, time.Millisecond)
+	assert.WithinDuration(t, start, instance.Application.VersionChangedAt, time.Millisecond)
+
+	// Checking in again with the same version keeps the version age.
+	clock.Advance(time.Hour)
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, start, instance.FirstSeen, time.Millisecond)
+	assert.WithinDuration(t, start, instance.Application.VersionChangedAt, time.Millisecond)
+
+	clock.Advance(time.Hour)
+	_, err = a.RegisterInstance(instanceID, "", "10.0.0.2", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	instance, err = a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, start, instance.FirstSeen, time.Millisecond, "The first time the instance was seen doesn't change.")
+	assert.WithinDuration(t, start.Add(2*time.Hour), instance.Application.VersionChangedAt, time.Millisecond)
+}
+
+func TestGetStuckInstances(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	stuckID := uuid.New().String()
+	updatedID := uuid.New().String()
+	notOfferedID := uuid.New().String()
+
+	for _, instanceID := range []string{stuckID, updatedID} {
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+	}
+	_, err = a.RegisterInstance(notOfferedID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	clock.Advance(2 * time.Hour)
+	_, err = a.RegisterInstance(updatedID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+
+	clock.Advance(46 * time.Hour)
+	for instanceID, version := range map[string]string{stuckID: "12.0.0", updatedID: "12.1.0", notOfferedID: "12.0.0"} {
+		_, err := a.RegisterInstance(instanceID, "", "10.0.0.1", version, tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+	}
+
+	instances, err := a.GetStuckInstances(tApp.ID, 24*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, instances, 1, "Only instances granted an update that didn't apply it are stuck.")
+	assert.Equal(t, stuckID, instances[0].ID)
+	assert.Equal(t, "12.0.0", instances[0].Application.Version)
+
+	instances, err = a.GetStuckInstances(tApp.ID, 72*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+
+	_, err = a.GetStuckInstances(tApp.ID, 0)
+	assert.Equal(t, ErrInvalidStuckThreshold, err)
+}
@@ -67,7 +67,7 @@ func (api *API) UpdateTeam(team *Team) error {
 	}
 	result, err := api.db.Exec(query)
 	if err != nil {
-		return err
+		return wrapUniqueViolation(err, "team %q", team.Name)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -101,7 +101,7 @@ func (api *API) AddTeam(team *Team) (*Team, error) {
 	err = api.db.QueryRowx(insertQuery).StructScan(team)
 
 	if err != nil {
-		return nil, err
+		return nil, wrapUniqueViolation(err, "team %q", team.Name)
 	}
 	return team, err
 }
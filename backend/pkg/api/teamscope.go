@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// WithTeam returns a copy of the api instance scoped to the team provided:
+// the applications of other teams, and their groups, channels, packages,
+// instances and webhooks, are reported as not found (sql.ErrNoRows) by the
+// methods accessing them. An empty team id disables the scoping, which is
+// what the Omaha handler and the internal jobs use.
+func (api *API) WithTeam(teamID string) *API {
+	apiCopy := *api
+	apiCopy.teamID = teamID
+	return &apiCopy
+}
+
+// checkTeam returns sql.ErrNoRows when the api instance is scoped to a team
+// other than the one provided.
+func (api *API) checkTeam(teamID string) error {
+	if api.teamID != "" && api.teamID != teamID {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkAppTeam returns sql.ErrNoRows when the api instance is scoped to a
+// team and the application provided doesn't belong to it.
+func (api *API) checkAppTeam(appID string) error {
+	if api.teamID == "" {
+		return nil
+	}
+	return api.checkEntityTeam(goqu.From("application").
+		Select(goqu.COUNT("*")).
+		Where(goqu.C("id").Eq(appID), goqu.C("team_id").Eq(api.teamID)))
+}
+
+// checkGroupTeam returns sql.ErrNoRows when the api instance is scoped to a
+// team and the application of the group provided doesn't belong to it.
+func (api *API) checkGroupTeam(groupID string) error {
+	return api.checkAppEntityTeam("groups", groupID)
+}
+
+// checkChannelTeam returns sql.ErrNoRows when the api instance is scoped to a
+// team and the application of the channel provided doesn't belong to it.
+func (api *API) checkChannelTeam(channelID string) error {
+	return api.checkAppEntityTeam("channel", channelID)
+}
+
+// checkPackageTeam returns sql.ErrNoRows when the api instance is scoped to a
+// team and the application of the package provided doesn't belong to it.
+func (api *API) checkPackageTeam(packageID string) error {
+	return api.checkAppEntityTeam("package", packageID)
+}
+
+// checkInstanceTeam returns sql.ErrNoRows when the api instance is scoped to
+// a team and the instance provided isn't registered in any of its
+// applications.
+func (api *API) checkInstanceTeam(instanceID string) error {
+	if api.teamID == "" {
+		return nil
+	}
+	return api.checkEntityTeam(goqu.From(goqu.T("instance_application").As("ia")).
+		Join(goqu.T("application").As("a"), goqu.On(goqu.I("a.id").Eq(goqu.I("ia.application_id")))).
+		Select(goqu.COUNT("*")).
+		Where(goqu.I("ia.instance_id").Eq(instanceID), goqu.I("a.team_id").Eq(api.teamID)))
+}
+
+// checkWebhookTeam returns sql.ErrNoRows when the api instance is scoped to a
+// team and the application of the webhook provided doesn't belong to it.
+func (api *API) checkWebhookTeam(webhookID string) error {
+	return api.checkAppEntityTeam("webhook", webhookID)
+}
+
+// checkPackageDeltaTeam returns sql.ErrNoRows when the api instance is scoped
+// to a team and the application of the package the delta provided belongs to
+// doesn't belong to it.
+func (api *API) checkPackageDeltaTeam(deltaID string) error {
+	return api.checkPackageEntityTeam("package_delta", deltaID)
+}
+
+func (api *API) checkPackageEntityTeam(table, id string) error {
+	if api.teamID == "" {
+		return nil
+	}
+	return api.checkEntityTeam(goqu.From(goqu.T(table).As("e")).
+		Join(goqu.T("package").As("p"), goqu.On(goqu.I("p.id").Eq(goqu.I("e.package_id")))).
+		Join(goqu.T("application").As("a"), goqu.On(goqu.I("a.id").Eq(goqu.I("p.application_id")))).
+		Select(goqu.COUNT("*")).
+		Where(goqu.I("e.id").Eq(id), goqu.I("a.team_id").Eq(api.teamID)))
+}
+
+func (api *API) checkAppEntityTeam(table, id string) error {
+	if api.teamID == "" {
+		return nil
+	}
+	return api.checkEntityTeam(goqu.From(goqu.T(table).As("e")).
+		Join(goqu.T("application").As("a"), goqu.On(goqu.I("a.id").Eq(goqu.I("e.application_id")))).
+		Select(goqu.COUNT("*")).
+		Where(goqu.I("e.id").Eq(id), goqu.I("a.team_id").Eq(api.teamID)))
+}
+
+func (api *API) checkEntityTeam(dataset *goqu.SelectDataset) error {
+	query, _, err := dataset.ToSQL()
+	if err != nil {
+		return err
+	}
+	var count int
+	if err := api.db.QueryRow(query).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
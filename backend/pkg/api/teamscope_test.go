@@ -0,0 +1,262 @@
+package api
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestWithTeam_Apps(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam1, _ := a.AddTeam(&Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp1, _ := a.AddApp(&Application{Name: "test_app1", TeamID: tTeam1.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam2.ID})
+	team1 := a.WithTeam(tTeam1.ID)
+
+	app, err := team1.GetApp(tApp1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tApp1.ID, app.ID)
+
+	_, err = team1.AddApp(&Application{Name: "test_app3", TeamID: tTeam2.ID})
+	assert.Equal(t, sql.ErrNoRows, err, "Apps can't be added to another team.")
+	_, err = team1.AddAppCloning(&Application{Name: "test_app3", TeamID: tTeam1.ID}, tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err, "Apps of another team can't be cloned.")
+
+	_, err = team1.GetApp(tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetApps(tTeam2.ID, 0, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, _, err = team1.GetAppsByTeam(tTeam2.ID, 0, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.UpdateApp(&Application{ID: tApp2.ID, Name: "hijacked"}))
+	assert.Equal(t, sql.ErrNoRows, team1.DeleteApp(tApp2.ID))
+	assert.Equal(t, sql.ErrNoRows, team1.RestoreApp(tApp2.ID))
+	assert.Equal(t, sql.ErrNoRows, team1.PurgeApp(tApp2.ID))
+
+	app, err = a.GetApp(tApp2.ID)
+	require.NoError(t, err, "The api instance not scoped to a team can access all apps.")
+	assert.Equal(t, "test_app2", app.Name)
+}
+
+func TestWithTeam_GroupsChannelsPackages(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam1, _ := a.AddTeam(&Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp1, _ := a.AddApp(&Application{Name: "test_app1", TeamID: tTeam1.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam2.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp1.ID, Arch: ArchAMD64})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp2.ID, Arch: ArchAMD64})
+	tChannel1, _ := a.AddChannel(&Channel{Name: "channel1", ApplicationID: tApp1.ID, PackageID: null.StringFrom(tPkg1.ID), Arch: ArchAMD64})
+	tChannel2, _ := a.AddChannel(&Channel{Name: "channel2", ApplicationID: tApp2.ID, PackageID: null.StringFrom(tPkg2.ID), Arch: ArchAMD64})
+	tChannel3, _ := a.AddChannel(&Channel{Name: "channel3", ApplicationID: tApp2.ID, Arch: ArchAMD64})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp1.ID, ChannelID: null.StringFrom(tChannel1.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp2.ID, ChannelID: null.StringFrom(tChannel2.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	team1 := a.WithTeam(tTeam1.ID)
+
+	// Groups
+	group, err := team1.GetGroup(tGroup1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tGroup1.ID, group.ID)
+
+	_, err = team1.AddGroup(&Group{Name: "group3", ApplicationID: tApp2.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetGroup(tGroup2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetGroups(tApp2.ID, 0, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	tGroup2.Name = "hijacked"
+	assert.Equal(t, sql.ErrNoRows, team1.UpdateGroup(tGroup2))
+	_, err = team1.PatchGroup(tGroup2.ID, map[string]interface{}{"name": "hijacked"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.CloneGroup(tGroup2.ID, "group3")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.PauseGroup(tGroup2.ID))
+	assert.Equal(t, sql.ErrNoRows, team1.ResumeGroup(tGroup2.ID))
+	assert.Equal(t, sql.ErrNoRows, team1.DeleteGroup(tGroup2.ID))
+
+	// Channels
+	channel, err := team1.GetChannel(tChannel1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tChannel1.ID, channel.ID)
+
+	_, err = team1.AddChannel(&Channel{Name: "channel4", ApplicationID: tApp2.ID, Arch: ArchAMD64})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetChannel(tChannel2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetChannels(tApp2.ID, 0, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	tChannel2.Name = "hijacked"
+	assert.Equal(t, sql.ErrNoRows, team1.UpdateChannel(tChannel2))
+	_, err = team1.PromotePackage(tChannel2.ID, tChannel3.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.DeleteChannel(tChannel2.ID))
+
+	// Packages
+	pkg, err := team1.GetPackage(tPkg1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg1.ID, pkg.ID)
+
+	_, err = team1.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp2.ID, Arch: ArchAMD64})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.AddPackages([]*Package{{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp2.ID, Arch: ArchAMD64}})
+	assert.Error(t, err)
+	_, err = team1.GetPackage(tPkg2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetPackages(tApp2.ID, 0, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetPackageByVersionAndArch(tApp2.ID, "12.1.0", ArchAMD64)
+	assert.Equal(t, sql.ErrNoRows, err)
+	tPkg2.Version = "12.3.0"
+	assert.Equal(t, sql.ErrNoRows, team1.UpdatePackage(tPkg2))
+	_, err = team1.PruneOldPackages(tApp2.ID, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.DeletePackage(tPkg2.ID))
+
+	// Nothing of the other team's app was changed.
+	group, err = a.GetGroup(tGroup2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "group2", group.Name)
+	channel, err = a.GetChannel(tChannel2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "channel2", channel.Name)
+	pkg, err = a.GetPackage(tPkg2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "12.1.0", pkg.Version)
+}
+
+func TestWithTeam_InstancesWebhooks(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam1, _ := a.AddTeam(&Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp1, _ := a.AddApp(&Application{Name: "test_app1", TeamID: tTeam1.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam2.ID})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp1.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp2.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tInstance1, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "1.0.0", tApp1.ID, tGroup1.ID)
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp2.ID, tGroup2.ID)
+	tWebhook1, _ := a.AddWebhook(&Webhook{ApplicationID: tApp1.ID, URL: "http://example.com/hook1"})
+	tWebhook2, _ := a.AddWebhook(&Webhook{ApplicationID: tApp2.ID, URL: "http://example.com/hook2"})
+	team1 := a.WithTeam(tTeam1.ID)
+
+	// Instances
+	instance, err := team1.GetInstance(tInstance1.ID, tApp1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tInstance1.ID, instance.ID)
+	require.NoError(t, team1.SetInstanceNote(tInstance1.ID, "flaky"))
+
+	_, err = team1.GetInstance(tInstance2.ID, tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstances(InstancesQueryParams{ApplicationID: tApp2.ID, GroupID: tGroup2.ID}, testDuration)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstancesCount(InstancesQueryParams{ApplicationID: tApp2.ID, GroupID: tGroup2.ID}, testDuration)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, _, err = team1.SearchInstances(tApp2.ID, InstanceSearchFilter{})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstanceStatusHistory(tInstance2.ID, tApp2.ID, tGroup2.ID, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstanceTimeline(tInstance2.ID, tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstanceStatsByArch(tApp2.ID, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetInstancesByLabel(tApp2.ID, "key", "value")
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetEventErrorBreakdown(tGroup2.ID, time.Now())
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.ReconcileInstanceStatuses(tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.UpdateInstance(tInstance2.ID, "hijacked")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.SetInstanceNote(tInstance2.ID, "hijacked"))
+	assert.Equal(t, sql.ErrNoRows, team1.UpdateInstanceLabels(tInstance2.ID, InstanceLabels{"key": "hijacked"}))
+	assert.Equal(t, sql.ErrNoRows, team1.DeregisterInstance(tInstance2.ID, tApp2.ID))
+
+	// Webhooks
+	webhook, err := team1.GetWebhook(tWebhook1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tWebhook1.ID, webhook.ID)
+
+	_, err = team1.AddWebhook(&Webhook{ApplicationID: tApp2.ID, URL: "http://example.com/hook3"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetWebhook(tWebhook2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetWebhooks(tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.DeleteWebhook(tWebhook2.ID))
+
+	// Nothing of the other team's app was changed.
+	instance, err = a.GetInstance(tInstance2.ID, tApp2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "", instance.Alias)
+	assert.False(t, instance.Note.Valid)
+	webhooks, err := a.GetWebhooks(tApp2.ID)
+	require.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+}
+
+func TestWithTeam_PoliciesDeltasQuarantine(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam1, _ := a.AddTeam(&Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&Team{Name: "test_team2"})
+	tApp1, _ := a.AddApp(&Application{Name: "test_app1", TeamID: tTeam1.ID})
+	tApp2, _ := a.AddApp(&Application{Name: "test_app2", TeamID: tTeam2.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp1.ID, Arch: ArchAMD64})
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp2.ID, Arch: ArchAMD64})
+	tGroup1, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp1.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp2.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tInstance2, _ := a.RegisterInstance(uuid.New().String(), "", "10.0.0.2", "1.0.0", tApp2.ID, tGroup2.ID)
+	tDelta2, _ := a.AddPackageDelta(&PackageDelta{PackageID: tPkg2.ID, FromVersion: "12.0.0", URL: "http://sample.url/delta", Sha256: "sha256", MetadataSignatureRsa: "signature", MetadataSize: "100"})
+	policy2 := &InstanceRegistrationPolicy{Rules: []InstanceRegistrationRule{{Action: RegistrationRuleDeny, CIDR: "10.0.0.0/8"}}}
+	require.NoError(t, a.SetInstanceRegistrationPolicy(tApp2.ID, policy2))
+	team1 := a.WithTeam(tTeam1.ID)
+
+	// Registration policies
+	_, err := team1.GetInstanceRegistrationPolicy(tApp1.ID)
+	require.NoError(t, err)
+	_, err = team1.GetInstanceRegistrationPolicy(tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.SetInstanceRegistrationPolicy(tApp2.ID, &InstanceRegistrationPolicy{}))
+
+	// Update durations
+	_, err = team1.GetUpdateDurationStats(tGroup1.ID)
+	require.NoError(t, err)
+	_, err = team1.GetUpdateDurationStats(tGroup2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// Package deltas
+	_, err = team1.GetPackageDeltas(tPkg1.ID)
+	require.NoError(t, err)
+	_, err = team1.AddPackageDelta(&PackageDelta{PackageID: tPkg2.ID, FromVersion: "11.0.0", URL: "http://sample.url/delta", Sha256: "sha256", MetadataSignatureRsa: "signature", MetadataSize: "100"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = team1.GetPackageDeltas(tPkg2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.DeletePackageDelta(tDelta2.ID))
+
+	// Quarantined instances
+	_, err = team1.ListQuarantinedInstances(tApp1.ID)
+	require.NoError(t, err)
+	_, err = team1.ListQuarantinedInstances(tApp2.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, team1.AcknowledgeQuarantinedInstance(tInstance2.ID, tApp2.ID))
+
+	// Nothing of the other team's app was changed.
+	policy, err := a.GetInstanceRegistrationPolicy(tApp2.ID)
+	require.NoError(t, err)
+	assert.Len(t, policy.Rules, 1)
+	deltas, err := a.GetPackageDeltas(tPkg2.ID)
+	require.NoError(t, err)
+	assert.Len(t, deltas, 1)
+}
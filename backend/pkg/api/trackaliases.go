@@ -0,0 +1,113 @@
+package api
+
+import (
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/google/uuid"
+)
+
+const maxTrackNameLength = 256
+
+// ErrInvalidTrackAlias error indicates that a track alias or the track it
+// resolves to is empty, too long, or that the alias resolves to itself.
+var ErrInvalidTrackAlias = newValidationError("nebraska: invalid track alias")
+
+// TrackAlias represents an alternative track name clients of an application
+// can request, resolving to the track of one of the application's groups.
+type TrackAlias struct {
+	ApplicationID string    `db:"application_id" json:"application_id"`
+	Alias         string    `db:"alias" json:"alias"`
+	Track         string    `db:"track" json:"track"`
+	CreatedTs     time.Time `db:"created_ts" json:"created_ts"`
+}
+
+// GetTrackAliases returns the track aliases of the application provided,
+// sorted by alias.
+func (api *API) GetTrackAliases(appID string) ([]*TrackAlias, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("track_alias").
+		Where(goqu.C("application_id").Eq(appID)).
+		Order(goqu.C("alias").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	aliases := []*TrackAlias{}
+	if err := api.db.Select(&aliases, query); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SetTrackAlias makes the clients of the application provided requesting the
+// alias track be served by the group of the given track, replacing the track
+// the alias resolved to before, if any.
+func (api *API) SetTrackAlias(appID, alias, track string) error {
+	if alias == "" || track == "" || alias == track || len(alias) > maxTrackNameLength || len(track) > maxTrackNameLength {
+		return ErrInvalidTrackAlias
+	}
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Insert("track_alias").
+		Cols("application_id", "alias", "track", "created_ts").
+		Vals(goqu.Vals{appID, alias, track, api.nowUTC()}).
+		OnConflict(goqu.DoUpdate("application_id, alias", goqu.Record{
+			"track":      track,
+			"created_ts": api.nowUTC(),
+		})).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := api.db.Exec(query); err != nil {
+		return err
+	}
+	api.updateCachedGroups()
+	return nil
+}
+
+// DeleteTrackAlias removes the alias provided from the track aliases of the
+// given application.
+func (api *API) DeleteTrackAlias(appID, alias string) error {
+	if err := api.checkAppTeam(appID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("track_alias").
+		Where(goqu.C("application_id").Eq(appID), goqu.C("alias").Eq(alias)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	api.updateCachedGroups()
+	return nil
+}
+
+// ResolveTrackAlias returns the track the alias provided resolves to for the
+// given application, or the track provided as it is when it isn't an alias.
+// The application id may be in any of the formats clients send it in. Like
+// GetGroupID, it's served from the groups cache.
+func (api *API) ResolveTrackAlias(appID, track string) (string, error) {
+	appUUID, err := uuid.Parse(appID)
+	if err != nil {
+		return track, nil
+	}
+	if resolved, ok := api.getCachedGroups().trackAliases[trackAliasKey{appID: appUUID.String(), alias: track}]; ok {
+		return resolved, nil
+	}
+	return track, nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackAliases(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	track, err := a.ResolveTrackAlias(tApp.ID, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", track, "Tracks that aren't aliases are kept.")
+
+	require.NoError(t, a.SetTrackAlias(tApp.ID, "prod", "stable"))
+	track, err = a.ResolveTrackAlias("{"+tApp.ID+"}", "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "stable", track)
+
+	require.NoError(t, a.SetTrackAlias(tApp.ID, "prod", "beta"))
+	require.NoError(t, a.SetTrackAlias(tApp.ID, "canary", "alpha"))
+	aliases, err := a.GetTrackAliases(tApp.ID)
+	require.NoError(t, err)
+	if assert.Len(t, aliases, 2) {
+		assert.Equal(t, "canary", aliases[0].Alias)
+		assert.Equal(t, "alpha", aliases[0].Track)
+		assert.Equal(t, "prod", aliases[1].Alias)
+		assert.Equal(t, "beta", aliases[1].Track)
+	}
+
+	require.NoError(t, a.DeleteTrackAlias(tApp.ID, "prod"))
+	assert.Equal(t, ErrNoRowsAffected, a.DeleteTrackAlias(tApp.ID, "prod"))
+	track, err = a.ResolveTrackAlias(tApp.ID, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", track)
+
+	assert.Equal(t, ErrInvalidTrackAlias, a.SetTrackAlias(tApp.ID, "", "stable"))
+	assert.Equal(t, ErrInvalidTrackAlias, a.SetTrackAlias(tApp.ID, "stable", "stable"))
+}
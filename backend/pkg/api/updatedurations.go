@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// UpdateDurationStats represents the distribution of the time the instances
+// of a group took to update, from the moment they started downloading the
+// update until they reported its completion. Durations are in seconds.
+type UpdateDurationStats struct {
+	// Updates is the number of completed updates the stats were computed
+	// from.
+	Updates int     `db:"updates" json:"updates"`
+	Min     float64 `db:"min" json:"min"`
+	P50     float64 `db:"p50" json:"p50"`
+	P90     float64 `db:"p90" json:"p90"`
+	P95     float64 `db:"p95" json:"p95"`
+	P99     float64 `db:"p99" json:"p99"`
+	Max     float64 `db:"max" json:"max"`
+}
+
+// GetUpdateDurationStats returns the percentiles of the durations of the
+// updates completed by the instances of the group provided.
+func (api *API) GetUpdateDurationStats(groupID string) (*UpdateDurationStats, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
+	query := `
+	SELECT count(*) AS updates,
+		COALESCE(min(d), 0) AS min,
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY d), 0) AS p50,
+		COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY d), 0) AS p90,
+		COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY d), 0) AS p95,
+		COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY d), 0) AS p99,
+		COALESCE(max(d), 0) AS max
+	FROM (
+		SELECT extract(epoch FROM completed_ts - started_ts)::float8 AS d
+		FROM instance_update_duration
+		WHERE group_id = $1
+	) durations
+	`
+	var stats UpdateDurationStats
+	if err := api.readDB().QueryRowx(query, groupID).StructScan(&stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// recordUpdateDuration records how long the update the instance provided just
+// completed took, measured from the first download event it posted since it
+// was granted the update. Updates for which no download event was posted are
+// not recorded.
+func (api *API) recordUpdateDuration(instance *Instance, groupID string, completedTs time.Time) error {
+	query := fmt.Sprintf(`
+	INSERT INTO instance_update_duration (instance_id, application_id, group_id, version, started_ts, completed_ts)
+	SELECT $1::varchar, $2::uuid, $3::uuid, $4::varchar, min(e.created_ts), $5::timestamptz
+	FROM event e
+	JOIN event_type et ON et.id = e.event_type_id
+	WHERE e.instance_id = $1 AND e.application_id = $2 AND et.type IN (%d, %d) AND et.result = %d
+		AND ($6::timestamptz IS NULL OR e.created_ts >= $6)
+	HAVING min(e.created_ts) IS NOT NULL
+	`, EventUpdateDownloadStarted, EventUpdateDownloadFinished, ResultSuccess)
+	app := instance.Application
+	_, err := api.db.Exec(query, instance.ID, app.ApplicationID, null.NewString(groupID, groupID != ""), app.LastUpdateVersion, completedTs, app.LastUpdateGrantedTs)
+	return err
+}
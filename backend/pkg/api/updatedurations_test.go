@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestGetUpdateDurationStats(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	stats, err := a.GetUpdateDurationStats(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &UpdateDurationStats{}, stats)
+
+	// update simulates an instance updating, taking the time provided from
+	// the download start to the update completion.
+	update := func(downloadTime, installTime time.Duration) {
+		instanceID := uuid.New().String()
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		clock.Advance(time.Minute)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+		clock.Advance(downloadTime)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "", ""))
+		clock.Advance(installTime)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", ""))
+	}
+	update(2*time.Minute, 3*time.Minute)
+	update(4*time.Minute, 6*time.Minute)
+	update(10*time.Minute, 10*time.Minute)
+
+	// Updates completed without download events are not recorded.
+	instanceID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", ""))
+
+	stats, err = a.GetUpdateDurationStats(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Updates)
+	assert.InDelta(t, 300, stats.Min, 0.001)
+	assert.InDelta(t, 600, stats.P50, 0.001)
+	assert.InDelta(t, 1080, stats.P90, 0.001)
+	assert.InDelta(t, 1200, stats.Max, 0.001)
+}
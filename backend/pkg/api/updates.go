@@ -2,13 +2,19 @@ package api
 
 import (
 	"errors"
+	"hash/fnv"
 	"time"
 
 	"github.com/blang/semver/v4"
+	"gopkg.in/guregu/null.v4"
 )
 
 const (
 	maxParallelUpdates = 900000
+
+	// forcedUpdateDeadline is the Omaha action deadline sent to instances of
+	// groups whose update deadline passed, so they don't postpone the update.
+	forcedUpdateDeadline = "now"
 )
 
 var (
@@ -32,6 +38,9 @@ var (
 	// ErrUpdatesDisabled indicates that updates are not enabled in the group.
 	ErrUpdatesDisabled = errors.New("nebraska: updates disabled")
 
+	// ErrGroupPaused indicates that the rollout in the group has been paused.
+	ErrGroupPaused = errors.New("nebraska: group rollout paused")
+
 	// ErrGetUpdatesStatsFailed indicates that there was a problem getting the
 	// updates stats of the group which are needed to enforce the rollout
 	// policy.
@@ -49,74 +58,345 @@ var (
 	// timed out while updating has been reached.
 	ErrMaxTimedOutUpdatesLimitReached = errors.New("nebraska: max timed out updates limit reached")
 
+	// ErrMinHealthyInstancesLimitReached indicates that granting another
+	// update would leave fewer instances than the group's minimum on its
+	// known-good version.
+	ErrMinHealthyInstancesLimitReached = errors.New("nebraska: min healthy instances limit reached")
+
+	// ErrRolloutPercentageLimitReached indicates that the instance is not part
+	// of the percentage of the group the update is being rolled out to, or
+	// that such percentage has already been updated.
+	ErrRolloutPercentageLimitReached = errors.New("nebraska: rollout percentage limit reached")
+
+	// ErrMaxConcurrentDownloadsLimitReached indicates that the maximum number
+	// of instances downloading the update package at the same time has been
+	// reached.
+	ErrMaxConcurrentDownloadsLimitReached = errors.New("nebraska: max concurrent downloads limit reached")
+
 	// ErrGrantingUpdate indicates that something went wrong while granting an
 	// update.
 	ErrGrantingUpdate = errors.New("nebraska: error granting update")
 )
 
+// UpdateDecisionReason explains the update decision made for an instance,
+// naming the step of the decision or the rollout policy gate that led to it.
+type UpdateDecisionReason string
+
+const (
+	// UpdateReasonGranted is the reason of updates granted to instances.
+	UpdateReasonGranted UpdateDecisionReason = "granted"
+	// UpdateReasonAlreadyGranted is the reason of updates offered again to
+	// instances that were already granted them.
+	UpdateReasonAlreadyGranted UpdateDecisionReason = "already-granted"
+	// UpdateReasonError is the reason of decisions that couldn't be made
+	// because of an unexpected error.
+	UpdateReasonError UpdateDecisionReason = "error"
+	// UpdateReasonUpdateInProgress indicates that an update is in progress on
+	// the instance.
+	UpdateReasonUpdateInProgress UpdateDecisionReason = "update-in-progress"
+	// UpdateReasonNoPackage indicates that the group has no channel or its
+	// channel has no package.
+	UpdateReasonNoPackage UpdateDecisionReason = "no-package"
+	// UpdateReasonArchMismatch indicates that the package of the channel was
+	// built for another architecture than the instance's.
+	UpdateReasonArchMismatch UpdateDecisionReason = "arch-mismatch"
+	// UpdateReasonChannelBlacklisted indicates that the package of the
+	// channel blacklists it.
+	UpdateReasonChannelBlacklisted UpdateDecisionReason = "channel-blacklisted"
+	// UpdateReasonUpToDate indicates that the instance already runs the
+	// version of the channel's package, or a newer one.
+	UpdateReasonUpToDate UpdateDecisionReason = "up-to-date"
+	// UpdateReasonPinnedVersion indicates that the instance already runs the
+	// version the group is pinned at, or a newer one.
+	UpdateReasonPinnedVersion UpdateDecisionReason = "pinned-version"
+	// UpdateReasonNoUpgradePath indicates that no package the instance can
+	// update to is available, because of the packages' minimum previous
+	// versions.
+	UpdateReasonNoUpgradePath UpdateDecisionReason = "no-upgrade-path"
+	// UpdateReasonBakeTime indicates that the package the instance would
+	// update to was added more recently than the group's minimum bake time.
+	UpdateReasonBakeTime UpdateDecisionReason = "bake-time"
+	// UpdateReasonGroupPaused indicates that the group's rollout is paused.
+	UpdateReasonGroupPaused UpdateDecisionReason = "group-paused"
+	// UpdateReasonObserveOnly indicates that the update would have been
+	// granted, but the group only observes the decisions without serving
+	// the updates.
+	UpdateReasonObserveOnly UpdateDecisionReason = "observe-only"
+	// UpdateReasonRolloutSchedule indicates that the group's rollout hasn't
+	// started yet or already ended according to its rollout schedule.
+	UpdateReasonRolloutSchedule UpdateDecisionReason = "rollout-schedule"
+	// UpdateReasonUpdatesDisabled indicates that the group's updates are
+	// disabled.
+	UpdateReasonUpdatesDisabled UpdateDecisionReason = "updates-disabled"
+	// UpdateReasonOutsideOfficeHours indicates that the group only updates in
+	// office hours and it's outside of them.
+	UpdateReasonOutsideOfficeHours UpdateDecisionReason = "outside-office-hours"
+	// UpdateReasonOutsideUpdateWindows indicates that it's outside of the
+	// group's update windows.
+	UpdateReasonOutsideUpdateWindows UpdateDecisionReason = "outside-update-windows"
+	// UpdateReasonRolloutPercentage indicates that the instance isn't part of
+	// the group's rollout percentage, or that it has been reached.
+	UpdateReasonRolloutPercentage UpdateDecisionReason = "rollout-percentage"
+	// UpdateReasonRolloutCohort indicates that the instance's rollout cohort
+	// hasn't been reached yet in the group.
+	UpdateReasonRolloutCohort UpdateDecisionReason = "rollout-cohort"
+	// UpdateReasonMaxConcurrentDownloads indicates that the group's limit of
+	// concurrent downloads has been reached.
+	UpdateReasonMaxConcurrentDownloads UpdateDecisionReason = "max-concurrent-downloads"
+	// UpdateReasonMaxConcurrentUpdates indicates that the group's limit of
+	// concurrent updates has been reached.
+	UpdateReasonMaxConcurrentUpdates UpdateDecisionReason = "max-concurrent-updates"
+	// UpdateReasonMaxUpdatesPerPeriod indicates that the group's limit of
+	// updates per period has been reached.
+	UpdateReasonMaxUpdatesPerPeriod UpdateDecisionReason = "max-updates-per-period"
+	// UpdateReasonSafeMode indicates that the group is in safe mode and
+	// another instance is already trying the update.
+	UpdateReasonSafeMode UpdateDecisionReason = "safe-mode"
+	// UpdateReasonMaxTimedOutUpdates indicates that too many updates timed
+	// out in the group, which is in safe mode.
+	UpdateReasonMaxTimedOutUpdates UpdateDecisionReason = "max-timed-out-updates"
+	// UpdateReasonMinHealthyInstances indicates that granting the update
+	// would leave fewer healthy instances than the group's minimum.
+	UpdateReasonMinHealthyInstances UpdateDecisionReason = "min-healthy-instances"
+	// UpdateReasonPackageOverride is the reason of updates to the package the
+	// instance was forced to, regardless of its group's channel.
+	UpdateReasonPackageOverride UpdateDecisionReason = "package-override"
+)
+
 // GetUpdatePackage returns an update package for the instance/application
 // provided. The instance details and the application it's running will be
 // registered in Nebraska (or updated if it's already registered).
 func (api *API) GetUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string) (*Package, error) {
-	instance, err := api.RegisterInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID)
+	return api.GetUpdatePackageForArch(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, ArchAll)
+}
+
+// GetUpdatePackageForArch works like GetUpdatePackage, but only offers the
+// group's package if it was built for the architecture of the instance
+// provided. Packages for all architectures are offered to any instance, and
+// instances of unknown architecture (ArchAll) are offered any package.
+func (api *API) GetUpdatePackageForArch(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string, arch Arch) (*Package, error) {
+	pkg, _, err := api.GetUpdatePackageWithReason(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, arch)
+	return pkg, err
+}
+
+// GetUpdatePackageWithReason works like GetUpdatePackageForArch, but also
+// returns the reason of the decision, e.g. the policy gate that blocked the
+// update.
+func (api *API) GetUpdatePackageWithReason(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string, arch Arch) (*Package, UpdateDecisionReason, error) {
+	if api.updateCheckLimiter == nil {
+		return api.getUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, arch, false)
+	}
+
+	now := api.nowUTC()
+	if decision, ok := api.updateCheckLimiter.get(instanceID, instanceVersion, appID, groupID, arch, now); ok {
+		return decision.pkg, decision.reason, decision.err
+	}
+	pkg, reason, err := api.getUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, arch, false)
+	api.updateCheckLimiter.add(&updateCheckDecision{
+		key:             updateCheckKey{instanceID: instanceID, appID: appID},
+		instanceVersion: instanceVersion,
+		groupID:         groupID,
+		arch:            arch,
+		pkg:             pkg,
+		reason:          reason,
+		err:             err,
+		checkedAt:       now,
+	})
+	return pkg, reason, err
+}
+
+// PreviewUpdatePackage returns the same update package GetUpdatePackageForArch
+// would, or the same error, but without registering the instance, granting
+// the update or changing anything else, so it doesn't count against the
+// group's rollout policy limits.
+func (api *API) PreviewUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string, arch Arch) (*Package, error) {
+	pkg, _, err := api.PreviewUpdatePackageWithReason(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, arch)
+	return pkg, err
+}
+
+// PreviewUpdatePackageWithReason works like PreviewUpdatePackage, but also
+// returns the reason of the decision.
+func (api *API) PreviewUpdatePackageWithReason(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string, arch Arch) (*Package, UpdateDecisionReason, error) {
+	return api.getUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID, arch, true)
+}
+
+// getUpdatePackage decides which update package to offer to the instance
+// provided, and why. When dryRun is set, nothing is written to the database.
+func (api *API) getUpdatePackage(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID string, arch Arch, dryRun bool) (*Package, UpdateDecisionReason, error) {
+	var instance *Instance
+	var err error
+	if dryRun {
+		instance, err = api.previewInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID)
+	} else {
+		instance, err = api.RegisterInstance(instanceID, instanceAlias, instanceIP, instanceVersion, appID, groupID)
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("GetUpdatePackage - could not register instance (propagates as ErrRegisterInstanceFailed)")
-		return nil, ErrRegisterInstanceFailed
+		return nil, UpdateReasonError, ErrRegisterInstanceFailed
 	}
 	updateAlreadyGranted := false
 
 	if instance.Application.Status.Valid {
 		switch int(instance.Application.Status.Int64) {
 		case InstanceStatusDownloading, InstanceStatusDownloaded, InstanceStatusInstalled:
-			return nil, ErrUpdateInProgressOnInstance
+			return nil, UpdateReasonUpdateInProgress, ErrUpdateInProgressOnInstance
 		case InstanceStatusUpdateGranted:
 			updateAlreadyGranted = true
 		}
 	}
 
+	override, err := api.GetInstancePackageOverride(instanceID, appID)
+	if err != nil {
+		return nil, UpdateReasonError, err
+	}
+	if override != nil {
+		if !sameVersion(override.Version, instanceVersion) {
+			return api.offerPackageOverride(instance, override, arch, updateAlreadyGranted, dryRun)
+		}
+		// The instance reached the version it was forced to, so it goes
+		// back to following its group's channel.
+		if !dryRun {
+			if err := api.ClearInstancePackageOverride(instanceID, appID); err != nil {
+				logger.Error().Err(err).Msg("GetUpdatePackage - could not clear instance package override")
+			}
+		}
+	}
+
 	group, err := api.GetGroup(groupID)
 	if err != nil {
-		return nil, err
+		return nil, UpdateReasonError, err
+	}
+
+	// Instances of groups split between several channels are only offered
+	// the package of the channel they were assigned to.
+	if channelID := pickWeightedChannel(group.ID, instanceID, group.ChannelWeights); channelID != "" {
+		channel, err := api.GetChannel(channelID)
+		if err != nil {
+			return nil, UpdateReasonError, err
+		}
+		group.ChannelID = null.StringFrom(channel.ID)
+		group.Channel = channel
+	}
+
+	// Channels with a version constraint offer the newest package matching
+	// it, so new packages are served without editing the channel.
+	if group.Channel != nil && group.Channel.VersionConstraint.String != "" {
+		if group.Channel.Package, err = api.resolveChannelPackage(group.Channel); err != nil {
+			return nil, UpdateReasonError, err
+		}
 	}
 
 	if group.Channel == nil || group.Channel.Package == nil {
+		if dryRun {
+			return nil, UpdateReasonNoPackage, ErrNoPackageFound
+		}
 		if err := api.newGroupActivityEntry(activityPackageNotFound, activityWarning, "0.0.0", appID, groupID); err != nil {
 			logger.Error().Err(err).Msg("GetUpdatePackage - could not add new group activity entry")
 		}
-		return nil, ErrNoPackageFound
+		return nil, UpdateReasonNoPackage, ErrNoPackageFound
+	}
+
+	if !archMatches(group.Channel.Package.Arch, arch) {
+		return nil, UpdateReasonArchMismatch, ErrNoUpdatePackageAvailable
 	}
 
 	for _, blacklistedChannelID := range group.Channel.Package.ChannelsBlacklist {
 		if blacklistedChannelID == group.Channel.ID {
-			if updateAlreadyGranted {
+			if updateAlreadyGranted && !dryRun {
 				if err := api.updateInstanceObjStatus(instance, InstanceStatusComplete); err != nil {
 					logger.Error().Err(err).Msg("GetUpdatePackage - could not update instance status")
 				}
 			}
-			return nil, ErrNoUpdatePackageAvailable
+			return nil, UpdateReasonChannelBlacklisted, ErrNoUpdatePackageAvailable
 		}
 	}
 
-	instanceSemver, _ := semver.Make(instanceVersion)
+	instanceSemver := parseInstanceVersion(instanceVersion)
 	packageSemver, _ := semver.Make(group.Channel.Package.Version)
+	upToDateReason := UpdateReasonUpToDate
+	if pinnedSemver, ok := groupPinnedSemver(group); ok && pinnedSemver.LT(packageSemver) {
+		packageSemver = pinnedSemver
+		upToDateReason = UpdateReasonPinnedVersion
+	}
 	if !instanceSemver.LT(packageSemver) {
-		if updateAlreadyGranted {
+		if updateAlreadyGranted && !dryRun {
 			if err := api.updateInstanceObjStatus(instance, InstanceStatusComplete); err != nil {
 				logger.Error().Err(err).Msg("GetUpdatePackage - could not update instance status")
 			}
 		}
-		return nil, ErrNoUpdatePackageAvailable
+		return nil, upToDateReason, ErrNoUpdatePackageAvailable
+	}
+
+	pkg, err := api.getUpgradePackage(group, instanceSemver)
+	if err != nil {
+		return nil, UpdateReasonError, err
+	}
+	if pkg == nil {
+		return nil, UpdateReasonNoUpgradePath, ErrNoUpdatePackageAvailable
+	}
+	// Instances for which there is a delta payload get it instead of the
+	// full one.
+	if pkg, err = api.preferPackageDelta(pkg, instanceVersion); err != nil {
+		return nil, UpdateReasonError, err
+	}
+
+	forced := api.forceUpdateDeadlinePassed(group)
+	if forced {
+		pkg = withForcedDeadline(pkg)
+	} else {
+		pkg = withSeverityDeadline(pkg)
 	}
 
 	if updateAlreadyGranted {
-		return group.Channel.Package, nil
+		return pkg, UpdateReasonAlreadyGranted, nil
 	}
 
-	if err := api.enforceRolloutPolicy(instance, group); err != nil {
-		return nil, err
+	// Packages are held back until they've been around for the group's
+	// minimum bake time, to catch broken builds before they reach instances.
+	baking, err := api.isPackageBaking(group, pkg)
+	if err != nil {
+		return nil, UpdateReasonError, err
+	}
+	if baking {
+		return nil, UpdateReasonBakeTime, ErrNoUpdatePackageAvailable
+	}
+
+	if group.PolicyPaused && !forced {
+		return nil, UpdateReasonGroupPaused, ErrGroupPaused
 	}
 
-	version := group.Channel.Package.Version
+	if !forced && !api.isWithinRolloutSchedule(group) {
+		return nil, UpdateReasonRolloutSchedule, ErrNoUpdatePackageAvailable
+	}
+
+	if dryRun {
+		if reason, err := api.checkRolloutPolicy(instance, group, pkg); err != nil {
+			return nil, reason, err
+		}
+		if group.PolicyObserveOnly {
+			return nil, UpdateReasonObserveOnly, ErrNoUpdatePackageAvailable
+		}
+		return pkg, UpdateReasonGranted, nil
+	}
+
+	// Observe only groups record the update their instances would have been
+	// granted, but never serve it.
+	if group.PolicyObserveOnly {
+		if reason, err := api.checkRolloutPolicy(instance, group, pkg); err != nil {
+			return nil, reason, err
+		}
+		logger.Info().Str("instance", instanceID).Str("group", groupID).Str("version", pkg.Version).Msg("GetUpdatePackage - observe only group, not serving update")
+		if err := api.recordObservedUpdate(instance, pkg.Version); err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - could not record observed update")
+		}
+		return nil, UpdateReasonObserveOnly, ErrNoUpdatePackageAvailable
+	}
+
+	if reason, err := api.enforceRolloutPolicy(instance, group, pkg); err != nil {
+		return nil, reason, err
+	}
+
+	version := pkg.Version
 
 	if err := api.grantUpdate(instance, version); err != nil {
 		logger.Error().Err(err).Msg("GetUpdatePackage - grantUpdate error (propagates as ErrGrantingUpdate):")
@@ -132,85 +412,303 @@ func (api *API) GetUpdatePackage(instanceID, instanceAlias, instanceIP, instance
 		if err := api.setGroupRolloutInProgress(groupID, true); err != nil {
 			logger.Error().Err(err).Msg("GetUpdatePackage - could not set rollout progress")
 		}
+		api.notifyRolloutTransition(group, RolloutStateStarted, version)
+	}
+
+	if group.PolicyMaxVersionSpread > 0 {
+		if err := api.checkVersionSpread(group); err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - could not check version spread")
+		}
+	}
+
+	return pkg, UpdateReasonGranted, nil
+}
+
+// getUpgradePackage returns the package an instance of the group provided
+// running the given version should update to. That's the package of the
+// group's channel, unless it's newer than the version the group is pinned at
+// or the instance is older than the minimum version the package can be
+// updated from. In those cases it's the newest package from the channel's
+// history the instance can update to without going past the pinned version,
+// so that the instance goes through it first. If there is no such package,
+// nil is returned.
+func (api *API) getUpgradePackage(group *Group, instanceSemver semver.Version) (*Package, error) {
+	channel := group.Channel
+	targetSemver, _ := semver.Make(channel.Package.Version)
+	pinnedSemver, pinned := groupPinnedSemver(group)
+	pinned = pinned && pinnedSemver.LT(targetSemver)
+	if !pinned && canUpdateFrom(channel.Package, instanceSemver) {
+		return channel.Package, nil
+	}
+
+	pkgs, err := api.getChannelHistoryPackages(channel.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		pkgSemver, err := semver.Make(pkg.Version)
+		if err != nil || !pkgSemver.LT(targetSemver) || (pinned && pinnedSemver.LT(pkgSemver)) {
+			continue
+		}
+		if !instanceSemver.LT(pkgSemver) {
+			// Packages are sorted by version, so the rest are older.
+			break
+		}
+		if isChannelBlacklisted(pkg, channel.ID) {
+			continue
+		}
+		if canUpdateFrom(pkg, instanceSemver) {
+			return pkg, nil
+		}
 	}
+	return nil, nil
+}
 
-	return group.Channel.Package, nil
+// groupPinnedSemver returns the version the group provided is pinned at, if
+// any.
+func groupPinnedSemver(group *Group) (semver.Version, bool) {
+	if group.PolicyPinnedVersion.String == "" {
+		return semver.Version{}, false
+	}
+	pinnedSemver, err := semver.Make(group.PolicyPinnedVersion.String)
+	if err != nil {
+		return semver.Version{}, false
+	}
+	return pinnedSemver, true
+}
+
+// canUpdateFrom checks if instances running the version provided can update
+// directly to the package given.
+func canUpdateFrom(pkg *Package, instanceSemver semver.Version) bool {
+	if pkg.MinPreviousVersion.String == "" {
+		return true
+	}
+	minSemver, err := semver.Make(pkg.MinPreviousVersion.String)
+	if err != nil {
+		return true
+	}
+	return !instanceSemver.LT(minSemver)
+}
+
+// isChannelBlacklisted checks if the channel provided is in the package's
+// channels blacklist.
+func isChannelBlacklisted(pkg *Package, channelID string) bool {
+	for _, blacklistedChannelID := range pkg.ChannelsBlacklist {
+		if blacklistedChannelID == channelID {
+			return true
+		}
+	}
+	return false
 }
 
 // enforceRolloutPolicy validates if an update should be provided to the
 // requesting instance based on the group rollout policy and the current status
-// of the updates taking place in the group.
-func (api *API) enforceRolloutPolicy(instance *Instance, group *Group) error {
-	appID := instance.Application.ApplicationID
+// of the updates taking place in the group. Instances that can't be updated
+// because of the policy limits are put on hold.
+func (api *API) enforceRolloutPolicy(instance *Instance, group *Group, pkg *Package) (UpdateDecisionReason, error) {
+	reason, err := api.checkRolloutPolicy(instance, group, pkg)
+	switch err {
+	case ErrRolloutPercentageLimitReached, ErrRolloutCohortNotActive, ErrMaxConcurrentDownloadsLimitReached, ErrMaxUpdatesPerPeriodLimitReached,
+		ErrMaxConcurrentUpdatesLimitReached, ErrMinHealthyInstancesLimitReached:
+	case ErrMaxTimedOutUpdatesLimitReached:
+		if group.PolicyUpdatesEnabled {
+			if err := api.disableUpdates(group.ID); err != nil {
+				logger.Error().Err(err).Msg("enforceRolloutPolicy - could not disable updates")
+			}
+			if err := api.setGroupRolloutInProgress(group.ID, false); err != nil {
+				logger.Error().Err(err).Msg("enforceRolloutPolicy - could not set rollout progress")
+			}
+			api.notifyRolloutTransition(group, RolloutStatePausedBySafeMode, pkg.Version)
+		}
+	default:
+		return reason, err
+	}
+	if err := api.updateInstanceStatus(instance.ID, instance.Application.ApplicationID, InstanceStatusOnHold); err != nil {
+		logger.Error().Err(err).Msg("enforceRolloutPolicy - could not update instance status")
+	}
+	return reason, err
+}
+
+// checkRolloutPolicy returns the error enforceRolloutPolicy would for the
+// instance and package provided, and the gate of the policy that returned
+// it, without changing its status or the group's.
+func (api *API) checkRolloutPolicy(instance *Instance, group *Group, pkg *Package) (UpdateDecisionReason, error) {
+	// Once the group's update deadline passes, every instance gets the
+	// update regardless of the rollout limits, update windows and safe mode
+	// having disabled the updates.
+	if api.forceUpdateDeadlinePassed(group) {
+		return UpdateReasonGranted, nil
+	}
 
 	if !group.PolicyUpdatesEnabled {
-		return ErrUpdatesDisabled
+		return UpdateReasonUpdatesDisabled, ErrUpdatesDisabled
+	}
+
+	// Severe enough packages are granted at any time, but still respect
+	// the rollout limits.
+	if !bypassesUpdateWindows(group, pkg) {
+		if group.PolicyOfficeHours && !inOfficeHours(api.clock.Now(), group.PolicyTimezone.String) {
+			return UpdateReasonOutsideOfficeHours, ErrUpdatesDisabled
+		}
+
+		if len(group.PolicyUpdateWindows) > 0 && !inUpdateWindows(api.clock.Now(), group.PolicyTimezone.String, group.PolicyUpdateWindows) {
+			return UpdateReasonOutsideUpdateWindows, ErrOutsideUpdateWindows
+		}
+	}
+
+	if group.PolicyRolloutPercentage.Valid {
+		inPercentage, err := api.inRolloutPercentage(instance, group)
+		if err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - inRolloutPercentage error (propagates as ErrGetUpdatesStatsFailed):")
+			return UpdateReasonError, ErrGetUpdatesStatsFailed
+		}
+		if !inPercentage {
+			return UpdateReasonRolloutPercentage, ErrRolloutPercentageLimitReached
+		}
 	}
 
-	if group.PolicyOfficeHours && !inOfficeHoursNow(group.PolicyTimezone.String) {
-		return ErrUpdatesDisabled
+	if !inActiveCohort(instance.ID, group) {
+		return UpdateReasonRolloutCohort, ErrRolloutCohortNotActive
+	}
+
+	if group.PolicyMaxConcurrentDownloads > 0 {
+		downloadsInProgress, err := api.getGroupDownloadsInProgress(group)
+		if err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - getGroupDownloadsInProgress error (propagates as ErrGetUpdatesStatsFailed):")
+			return UpdateReasonError, ErrGetUpdatesStatsFailed
+		}
+		if downloadsInProgress >= group.PolicyMaxConcurrentDownloads {
+			return UpdateReasonMaxConcurrentDownloads, ErrMaxConcurrentDownloadsLimitReached
+		}
+	}
+
+	if group.PolicyMaxConcurrentUpdates > 0 {
+		updatesInFlight, err := api.getGroupUpdatesInFlight(group)
+		if err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - getGroupUpdatesInFlight error (propagates as ErrGetUpdatesStatsFailed):")
+			return UpdateReasonError, ErrGetUpdatesStatsFailed
+		}
+		if updatesInFlight >= group.PolicyMaxConcurrentUpdates {
+			return UpdateReasonMaxConcurrentUpdates, ErrMaxConcurrentUpdatesLimitReached
+		}
 	}
 
 	effectiveMaxUpdates := group.PolicyMaxUpdatesPerPeriod
 
 	// If no policy enforcement is needed, then we skip getting the update stats below.
-	if effectiveMaxUpdates >= maxParallelUpdates && !group.PolicySafeMode {
-		return nil
+	if effectiveMaxUpdates >= maxParallelUpdates && !group.PolicySafeMode && group.PolicyMinHealthyInstances == 0 && !group.PolicyRolloutPercentage.Valid {
+		return UpdateReasonGranted, nil
 	}
 
 	updatesStats, err := api.getGroupUpdatesStats(group)
 	if err != nil {
 		logger.Error().Err(err).Msg("GetUpdatePackage - getGroupUpdatesStats error (propagates as ErrGetUpdatesStatsFailed):")
-		return ErrGetUpdatesStatsFailed
+		return UpdateReasonError, ErrGetUpdatesStatsFailed
 	}
 
+	// Until the first update to the current version is attempted, safe mode
+	// lets a single instance update at a time.
+	perPeriodReason, inProgressReason := UpdateReasonMaxUpdatesPerPeriod, UpdateReasonMaxConcurrentUpdates
 	if group.PolicySafeMode && updatesStats.UpdatesToCurrentVersionAttempted == 0 {
 		effectiveMaxUpdates = 1
+		perPeriodReason, inProgressReason = UpdateReasonSafeMode, UpdateReasonSafeMode
+	}
+
+	// Granting this update must not take the fraction of updated instances
+	// beyond the rollout percentage.
+	if group.PolicyRolloutPercentage.Valid && (updatesStats.UpdatesToCurrentVersionGranted+1)*100 > updatesStats.TotalInstances*int(group.PolicyRolloutPercentage.Int64) {
+		return UpdateReasonRolloutPercentage, ErrRolloutPercentageLimitReached
 	}
 
 	if updatesStats.UpdatesGrantedInLastPeriod >= effectiveMaxUpdates {
-		if err := api.updateInstanceStatus(instance.ID, appID, InstanceStatusOnHold); err != nil {
-			logger.Error().Err(err).Msg("enforceRolloutPolicy - could not update instance status")
-		}
-		return ErrMaxUpdatesPerPeriodLimitReached
+		return perPeriodReason, ErrMaxUpdatesPerPeriodLimitReached
 	}
 
 	if updatesStats.UpdatesInProgress >= effectiveMaxUpdates {
-		if err := api.updateInstanceStatus(instance.ID, appID, InstanceStatusOnHold); err != nil {
-			logger.Error().Err(err).Msg("enforceRolloutPolicy - could not update instance status")
-		}
-		return ErrMaxConcurrentUpdatesLimitReached
+		return inProgressReason, ErrMaxConcurrentUpdatesLimitReached
 	}
 
 	if group.PolicySafeMode && updatesStats.UpdatesTimedOut >= effectiveMaxUpdates {
-		if group.PolicyUpdatesEnabled {
-			if err := api.disableUpdates(group.ID); err != nil {
-				logger.Error().Err(err).Msg("enforceRolloutPolicy - could not disable updates")
-			}
+		return UpdateReasonMaxTimedOutUpdates, ErrMaxTimedOutUpdatesLimitReached
+	}
+
+	// The requesting instance is still on the known-good version, so
+	// granting it an update takes one instance away from the healthy ones.
+	if group.PolicyMinHealthyInstances > 0 {
+		healthyInstances, err := api.getGroupHealthyInstances(group)
+		if err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - getGroupHealthyInstances error (propagates as ErrGetUpdatesStatsFailed):")
+			return UpdateReasonError, ErrGetUpdatesStatsFailed
 		}
-		if err := api.updateInstanceStatus(instance.ID, appID, InstanceStatusOnHold); err != nil {
-			logger.Error().Err(err).Msg("enforceRolloutPolicy - could not update instance status")
+		if healthyInstances-1 < group.PolicyMinHealthyInstances {
+			return UpdateReasonMinHealthyInstances, ErrMinHealthyInstancesLimitReached
 		}
-		return ErrMaxTimedOutUpdatesLimitReached
 	}
 
-	return nil
+	return UpdateReasonGranted, nil
+}
+
+// forceUpdateDeadlinePassed checks if the deadline after which the updates
+// of the group provided are forced has passed.
+func (api *API) forceUpdateDeadlinePassed(group *Group) bool {
+	return group.PolicyForceUpdateAfter.Valid && !api.nowUTC().Before(group.PolicyForceUpdateAfter.Time)
+}
+
+// withForcedDeadline returns a copy of the package provided whose Flatcar
+// action asks clients to install the update and reboot right away.
+func withForcedDeadline(pkg *Package) *Package {
+	if pkg.FlatcarAction == nil {
+		return pkg
+	}
+	forcedPkg := *pkg
+	action := *pkg.FlatcarAction
+	action.Deadline = forcedUpdateDeadline
+	forcedPkg.FlatcarAction = &action
+	return &forcedPkg
+}
+
+// rolloutBucket returns the bucket, between 0 and 99, the instance provided
+// falls in for percentage based rollouts. It is derived from the instance id so
+// instances consistently are in or out of a given rollout percentage.
+func rolloutBucket(instanceID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(instanceID))
+	return int(h.Sum32() % 100)
+}
+
+// offerPackageOverride offers the package the instance provided was forced to,
+// bypassing the group's rollout policy.
+func (api *API) offerPackageOverride(instance *Instance, pkg *Package, arch Arch, updateAlreadyGranted, dryRun bool) (*Package, UpdateDecisionReason, error) {
+	if !archMatches(pkg.Arch, arch) {
+		return nil, UpdateReasonArchMismatch, ErrNoUpdatePackageAvailable
+	}
+	if updateAlreadyGranted {
+		return pkg, UpdateReasonAlreadyGranted, nil
+	}
+	if !dryRun {
+		if err := api.grantUpdate(instance, pkg.Version); err != nil {
+			logger.Error().Err(err).Msg("GetUpdatePackage - grantUpdate error for package override")
+		}
+	}
+	return pkg, UpdateReasonPackageOverride, nil
 }
 
 // grantUpdate grants an update for the provided instance in the context of the
 // given application.
 func (api *API) grantUpdate(instance *Instance, version string) error {
 	instanceData := make(map[string]interface{})
-	instanceData["last_update_granted_ts"] = nowUTC()
+	instanceData["last_update_granted_ts"] = api.nowUTC()
 	instanceData["last_update_version"] = version
 	instanceData["status"] = InstanceStatusUpdateGranted
 	instanceData["update_in_progress"] = true
+	instanceData["update_timed_out"] = false
 
 	return api.updateInstanceData(instance, instanceData)
 }
 
-// inOfficeHoursNow checks if the provided timezone is now in office hours.
-func inOfficeHoursNow(tz string) bool {
+// inOfficeHours checks if the time provided is in office hours in the
+// provided timezone.
+func inOfficeHours(now time.Time, tz string) bool {
 	if tz == "" {
 		return false
 	}
@@ -220,7 +718,7 @@ func inOfficeHoursNow(tz string) bool {
 		return false
 	}
 
-	now := time.Now().In(location)
+	now = now.In(location)
 	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
 		return false
 	}
@@ -1,12 +1,14 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -50,6 +52,173 @@ func TestGetUpdatePackage(t *testing.T) {
 	assert.Equal(t, ErrNoUpdatePackageAvailable, err, "Instance version is up to date.")
 }
 
+func TestGetUpdatePackageForArch(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: ArchAMD64})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err := a.GetUpdatePackageForArch(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAArch64)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+
+	pkg, err := a.GetUpdatePackageForArch(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID, ArchAMD64)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	// Instances of unknown architecture are offered any package.
+	pkg, err = a.GetUpdatePackageForArch(uuid.New().String(), "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+}
+
+func TestPreviewUpdatePackage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: "60 minutes"})
+
+	instanceID := uuid.New().String()
+	pkg, err := a.PreviewUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	_, err = a.GetInstance(instanceID, tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err, "Previewing an update must not register the instance.")
+
+	group, _ := a.GetGroup(tGroup.ID)
+	assert.False(t, group.RolloutInProgress)
+
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err, "Previewing an update must not count against the updates per period limit.")
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	_, err = a.PreviewUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAll)
+	assert.Equal(t, ErrMaxUpdatesPerPeriodLimitReached, err)
+
+	group, _ = a.GetGroup(tGroup.ID)
+	assert.True(t, group.PolicyUpdatesEnabled)
+}
+
+func TestGetUpdatePackageWithReason(t *testing.T) {
+	// Saturday at noon.
+	clock := NewMockClock(time.Date(2021, time.June, 12, 12, 0, 0, 0, time.UTC))
+	a, err := NewForTest(OptionInitDB, OptionDisableUpdatesOnFailedRollout, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID, Arch: ArchAMD64})
+	tPkgMinVersion, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "13.0.0", ApplicationID: tApp.ID, MinPreviousVersion: null.StringFrom("12.1.0")})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: ArchAMD64})
+	tChannelMinVersion, _ := a.AddChannel(&Channel{Name: "test_channel2", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgMinVersion.ID)})
+	tChannelNoPkg, _ := a.AddChannel(&Channel{Name: "test_channel3", Color: "green", ApplicationID: tApp.ID})
+
+	addGroup := func(group *Group) *Group {
+		group.Name = uuid.New().String()
+		group.ApplicationID = tApp.ID
+		if !group.ChannelID.Valid {
+			group.ChannelID = null.StringFrom(tChannel.ID)
+		}
+		group.PolicyUpdatesEnabled = true
+		group.PolicyPeriodInterval = "15 minutes"
+		group.PolicyUpdateTimeout = "60 minutes"
+		if group.PolicyMaxUpdatesPerPeriod == 0 {
+			group.PolicyMaxUpdatesPerPeriod = 10
+		}
+		tGroup, err := a.AddGroup(group)
+		require.NoError(t, err)
+		return tGroup
+	}
+
+	tGroup := addGroup(&Group{})
+	tGroupNoPkg := addGroup(&Group{ChannelID: null.StringFrom(tChannelNoPkg.ID)})
+	tGroupMinVersion := addGroup(&Group{ChannelID: null.StringFrom(tChannelMinVersion.ID)})
+	tGroupPinned := addGroup(&Group{PolicyPinnedVersion: null.StringFrom("12.0.0")})
+	tGroupDisabled := addGroup(&Group{})
+	tGroupPaused := addGroup(&Group{})
+	tGroupSafeMode := addGroup(&Group{PolicySafeMode: true})
+	tGroupMaxUpdates := addGroup(&Group{PolicyMaxUpdatesPerPeriod: 1})
+	tGroupRollout := addGroup(&Group{PolicyRolloutPercentage: null.IntFrom(0)})
+	tGroupWindows := addGroup(&Group{PolicyTimezone: null.StringFrom("UTC"), PolicyUpdateWindows: []UpdateWindow{{Weekday: time.Tuesday, StartTime: "09:00", EndTime: "10:00"}}})
+	tGroupOfficeHours := addGroup(&Group{PolicyTimezone: null.StringFrom("UTC"), PolicyOfficeHours: true})
+
+	tGroupDisabled.PolicyUpdatesEnabled = false
+	require.NoError(t, a.UpdateGroup(tGroupDisabled))
+	require.NoError(t, a.PauseGroup(tGroupPaused.ID))
+
+	getUpdate := func(instanceID, version string, group *Group, arch Arch) (*Package, UpdateDecisionReason, error) {
+		return a.GetUpdatePackageWithReason(instanceID, "", "10.0.0.1", version, tApp.ID, group.ID, arch)
+	}
+
+	instanceID := uuid.New().String()
+	pkg, reason, err := getUpdate(instanceID, "12.0.0", tGroup, ArchAMD64)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+	assert.Equal(t, UpdateReasonGranted, reason)
+
+	_, reason, err = getUpdate(instanceID, "12.0.0", tGroup, ArchAMD64)
+	assert.NoError(t, err)
+	assert.Equal(t, UpdateReasonAlreadyGranted, reason)
+
+	require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+	_, reason, err = getUpdate(instanceID, "12.0.0", tGroup, ArchAMD64)
+	assert.Equal(t, ErrUpdateInProgressOnInstance, err)
+	assert.Equal(t, UpdateReasonUpdateInProgress, reason)
+
+	tests := []struct {
+		name    string
+		version string
+		group   *Group
+		arch    Arch
+		err     error
+		reason  UpdateDecisionReason
+	}{
+		{"up to date", "12.1.0", tGroup, ArchAMD64, ErrNoUpdatePackageAvailable, UpdateReasonUpToDate},
+		{"arch mismatch", "12.0.0", tGroup, ArchAArch64, ErrNoUpdatePackageAvailable, UpdateReasonArchMismatch},
+		{"no package", "12.0.0", tGroupNoPkg, ArchAMD64, ErrNoPackageFound, UpdateReasonNoPackage},
+		{"no upgrade path", "11.0.0", tGroupMinVersion, ArchAMD64, ErrNoUpdatePackageAvailable, UpdateReasonNoUpgradePath},
+		{"pinned version", "12.0.0", tGroupPinned, ArchAMD64, ErrNoUpdatePackageAvailable, UpdateReasonPinnedVersion},
+		{"updates disabled", "12.0.0", tGroupDisabled, ArchAMD64, ErrUpdatesDisabled, UpdateReasonUpdatesDisabled},
+		{"group paused", "12.0.0", tGroupPaused, ArchAMD64, ErrGroupPaused, UpdateReasonGroupPaused},
+		{"rollout percentage", "12.0.0", tGroupRollout, ArchAMD64, ErrRolloutPercentageLimitReached, UpdateReasonRolloutPercentage},
+		{"update windows", "12.0.0", tGroupWindows, ArchAMD64, ErrOutsideUpdateWindows, UpdateReasonOutsideUpdateWindows},
+		{"office hours", "12.0.0", tGroupOfficeHours, ArchAMD64, ErrUpdatesDisabled, UpdateReasonOutsideOfficeHours},
+	}
+	for _, tc := range tests {
+		_, reason, err := getUpdate(uuid.New().String(), tc.version, tc.group, tc.arch)
+		assert.Equal(t, tc.err, err, tc.name)
+		assert.Equal(t, tc.reason, reason, tc.name)
+	}
+
+	// Safe mode and the updates per period limit let a single update
+	// through, but are told apart.
+	_, _, err = getUpdate(uuid.New().String(), "12.0.0", tGroupSafeMode, ArchAMD64)
+	require.NoError(t, err)
+	_, reason, err = getUpdate(uuid.New().String(), "12.0.0", tGroupSafeMode, ArchAMD64)
+	assert.Equal(t, ErrMaxUpdatesPerPeriodLimitReached, err)
+	assert.Equal(t, UpdateReasonSafeMode, reason)
+
+	_, _, err = getUpdate(uuid.New().String(), "12.0.0", tGroupMaxUpdates, ArchAMD64)
+	require.NoError(t, err)
+	_, reason, err = getUpdate(uuid.New().String(), "12.0.0", tGroupMaxUpdates, ArchAMD64)
+	assert.Equal(t, ErrMaxUpdatesPerPeriodLimitReached, err)
+	assert.Equal(t, UpdateReasonMaxUpdatesPerPeriod, reason)
+
+	// Previewing an update explains it the same way.
+	_, reason, err = a.PreviewUpdatePackageWithReason(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroupPaused.ID, ArchAMD64)
+	assert.Equal(t, ErrGroupPaused, err)
+	assert.Equal(t, UpdateReasonGroupPaused, reason)
+}
+
 func TestGetUpdatePackage_GroupNoChannel(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -189,6 +358,219 @@ func TestGetUpdatePackage_MaxTimedOutUpdatesLimitReached_SafeMode(t *testing.T)
 	assert.Equal(t, ErrUpdatesDisabled, err)
 }
 
+func TestGetUpdatePackage_MinHealthyInstancesLimitReached(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMinHealthyInstances: 2})
+
+	instance1ID := uuid.New().String()
+	instance2ID := uuid.New().String()
+	instance3ID := uuid.New().String()
+	instance4ID := uuid.New().String()
+	_, _ = a.RegisterInstance(instance1ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	_, _ = a.RegisterInstance(instance4ID, "", "10.0.0.4", "12.0.0", tApp.ID, tGroup.ID)
+
+	_, err := a.GetUpdatePackage(instance1ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err, "Three instances remain on the known-good version.")
+
+	_, err = a.GetUpdatePackage(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err, "Two instances remain on the known-good version.")
+
+	_, err = a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMinHealthyInstancesLimitReached, err, "Only one instance would remain on the known-good version.")
+
+	// An instance that completed the update runs the new version, so it
+	// doesn't count as healthy.
+	_ = a.updateInstanceStatus(instance1ID, tApp.ID, InstanceStatusComplete)
+
+	_, err = a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMinHealthyInstancesLimitReached, err)
+
+	// An instance whose update failed is back on the known-good version.
+	_ = a.updateInstanceStatus(instance2ID, tApp.ID, InstanceStatusError)
+
+	_, err = a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	_, err = a.GetUpdatePackage(instance4ID, "", "10.0.0.4", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMinHealthyInstancesLimitReached, err)
+}
+
+func TestGetUpdatePackage_RolloutPercentage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1000, PolicyUpdateTimeout: "60 minutes", PolicyRolloutPercentage: null.IntFrom(30)})
+
+	const numInstances = 200
+	instanceIDs := make([]string, numInstances)
+	for i := range instanceIDs {
+		instanceIDs[i] = uuid.New().String()
+		_, _ = a.RegisterInstance(instanceIDs[i], "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	}
+
+	served := 0
+	for _, instanceID := range instanceIDs {
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		if err == nil {
+			served++
+			assert.Less(t, rolloutBucket(instanceID), 30)
+			continue
+		}
+		assert.Equal(t, ErrRolloutPercentageLimitReached, err)
+	}
+	assert.Greater(t, served, 0)
+	assert.LessOrEqual(t, served*100, numInstances*30)
+
+	// Instances outside of the rollout percentage are consistently left out.
+	for _, instanceID := range instanceIDs {
+		if rolloutBucket(instanceID) >= 30 {
+			_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+			assert.Equal(t, ErrRolloutPercentageLimitReached, err)
+			break
+		}
+	}
+
+	tGroup.PolicyRolloutPercentage = null.IntFrom(101)
+	assert.Equal(t, ErrInvalidRolloutPercentage, a.UpdateGroup(tGroup))
+}
+
+func TestGetUpdatePackage_RolloutOrder(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	addGroup := func(name, order string) *Group {
+		group, err := a.AddGroup(&Group{Name: name, ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1000, PolicyUpdateTimeout: "60 minutes", PolicyRolloutPercentage: null.IntFrom(30), PolicyRolloutOrder: order})
+		require.NoError(t, err)
+		return group
+	}
+	tGroupOldest := addGroup("oldest", RolloutOrderOldestFirst)
+	tGroupNewest := addGroup("newest", RolloutOrderNewestFirst)
+
+	tGroupRandom := addGroup("random", "")
+	assert.Equal(t, RolloutOrderRandom, tGroupRandom.PolicyRolloutOrder)
+	tGroupRandom.PolicyRolloutOrder = "youngest_first"
+	assert.Equal(t, ErrInvalidRolloutOrder, a.UpdateGroup(tGroupRandom))
+
+	// Registers ten instances in the group provided, a minute apart, and
+	// has them check for updates newest first. It returns the ids of the
+	// instances, oldest first, and the ones served the update.
+	checkForUpdates := func(group *Group) ([]string, []string) {
+		instanceIDs := make([]string, 10)
+		for i := range instanceIDs {
+			instanceIDs[i] = uuid.New().String()
+			_, err := a.RegisterInstance(instanceIDs[i], "", "10.0.0.1", "12.0.0", tApp.ID, group.ID)
+			require.NoError(t, err)
+			clock.Advance(time.Minute)
+		}
+		served := []string{}
+		for i := len(instanceIDs) - 1; i >= 0; i-- {
+			_, err := a.GetUpdatePackage(instanceIDs[i], "", "10.0.0.1", "12.0.0", tApp.ID, group.ID)
+			if err == nil {
+				served = append(served, instanceIDs[i])
+				continue
+			}
+			assert.Equal(t, ErrRolloutPercentageLimitReached, err)
+		}
+		return instanceIDs, served
+	}
+
+	instanceIDs, served := checkForUpdates(tGroupOldest)
+	assert.ElementsMatch(t, instanceIDs[:3], served, "The oldest instances are served first.")
+
+	instanceIDs, served = checkForUpdates(tGroupNewest)
+	assert.ElementsMatch(t, instanceIDs[7:], served, "The newest instances are served first.")
+}
+
+func TestGetUpdatePackage_MaxConcurrentDownloadsLimitReached(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMaxConcurrentDownloads: 1})
+
+	instance1ID := uuid.New().String()
+	instance2ID := uuid.New().String()
+
+	_, err := a.GetUpdatePackage(instance1ID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	err = a.RegisterEvent(instance1ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", "")
+	assert.NoError(t, err)
+
+	_, err = a.GetUpdatePackage(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxConcurrentDownloadsLimitReached, err)
+
+	err = a.RegisterEvent(instance1ID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "", "")
+	assert.NoError(t, err)
+
+	_, err = a.GetUpdatePackage(instance2ID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+}
+
+func TestGetUpdatePackage_MaxConcurrentUpdatesLimitReached(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMaxConcurrentUpdates: 2})
+
+	instance1ID := uuid.New().String()
+	instance2ID := uuid.New().String()
+	instance3ID := uuid.New().String()
+
+	for _, instanceID := range []string{instance1ID, instance2ID} {
+		_, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		require.NoError(t, a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+	}
+
+	_, err := a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxConcurrentUpdatesLimitReached, err)
+
+	// Instances that finished downloading are still updating.
+	require.NoError(t, a.RegisterEvent(instance1ID, tApp.ID, tGroup.ID, EventUpdateDownloadFinished, ResultSuccess, "", ""))
+	_, err = a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxConcurrentUpdatesLimitReached, err)
+
+	require.NoError(t, a.RegisterEvent(instance1ID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccess, "", ""))
+	_, err = a.GetUpdatePackage(instance3ID, "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	require.NoError(t, a.RegisterEvent(instance3ID, tApp.ID, tGroup.ID, EventUpdateDownloadStarted, ResultSuccess, "", ""))
+
+	// Failed updates free their slot too.
+	instance4ID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instance4ID, "", "10.0.0.4", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxConcurrentUpdatesLimitReached, err)
+	require.NoError(t, a.RegisterEvent(instance2ID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "", ""))
+	_, err = a.GetUpdatePackage(instance4ID, "", "10.0.0.4", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+}
+
 func TestGetUpdatePackage_ResumeUpdates(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -314,6 +696,73 @@ func TestGetUpdatePackage_UpdateInProgressOnInstance(t *testing.T) {
 	assert.Equal(t, ErrUpdateInProgressOnInstance, err)
 }
 
+func TestGetUpdatePackage_MinPreviousVersion(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkgIntermediate, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "600.0.0", ApplicationID: tApp.ID})
+	tPkgTarget, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "700.0.0", ApplicationID: tApp.ID, MinPreviousVersion: null.StringFrom("600.0.0")})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgIntermediate.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	tChannel.PackageID = null.StringFrom(tPkgTarget.ID)
+	assert.NoError(t, a.UpdateChannel(tChannel))
+
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "500.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkgIntermediate.ID, pkg.ID)
+
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "600.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkgTarget.ID, pkg.ID)
+
+	// Without an intermediate package in the channel's history, instances
+	// too old for the target package don't get any update.
+	tChannel2, _ := a.AddChannel(&Channel{Name: "test_channel2", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgTarget.ID)})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel2.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "500.0.0", tApp.ID, tGroup2.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+}
+
+func TestGetUpdatePackage_PinnedVersion(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkgPinned, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tPkgNewer, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgPinned.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyPinnedVersion: null.StringFrom("12.1.0")})
+
+	tChannel.PackageID = null.StringFrom(tPkgNewer.ID)
+	assert.NoError(t, a.UpdateChannel(tChannel))
+
+	// Instances below the pin are updated up to it.
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkgPinned.ID, pkg.ID)
+
+	// Instances at or above the pin don't get any update.
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.1.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "12.1.5", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+
+	// Clearing the pin resumes the updates to the channel's package.
+	tGroup.PolicyPinnedVersion = null.String{}
+	assert.NoError(t, a.UpdateGroup(tGroup))
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.4", "12.1.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkgNewer.ID, pkg.ID)
+
+	tGroup.PolicyPinnedVersion = null.StringFrom("aaa12.1.0")
+	assert.Equal(t, ErrInvalidSemver, a.UpdateGroup(tGroup))
+}
+
 func TestGetUpdatePackage_CheckVersionForGrantedUpdate(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -370,3 +819,272 @@ func TestGetUpdatePackage_InstanceStatusHistory(t *testing.T) {
 	assert.Equal(t, InstanceStatusUpdateGranted, instanceStatusHistory[2].Status)
 	assert.Equal(t, tPkg.Version, instanceStatusHistory[2].Version)
 }
+
+func TestEnforceRolloutPolicy_OfficeHours(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Monday, one minute before the office hours finish.
+	clock := NewMockClock(time.Date(2021, time.June, 7, 16, 59, 0, 0, location))
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tInstance := &Instance{ID: uuid.New().String()}
+	tGroup := &Group{PolicyUpdatesEnabled: true, PolicyOfficeHours: true, PolicyTimezone: null.StringFrom("Europe/Berlin"), PolicyMaxUpdatesPerPeriod: maxParallelUpdates}
+	tPkg := &Package{Severity: PackageSeverityRoutine}
+
+	_, err = a.enforceRolloutPolicy(tInstance, tGroup, tPkg)
+	assert.NoError(t, err)
+
+	clock.Advance(time.Minute)
+	reason, err := a.enforceRolloutPolicy(tInstance, tGroup, tPkg)
+	assert.Equal(t, ErrUpdatesDisabled, err, "Office hours are over.")
+	assert.Equal(t, UpdateReasonOutsideOfficeHours, reason)
+
+	// Next day, right when the office hours start.
+	clock.Set(time.Date(2021, time.June, 8, 9, 0, 0, 0, location))
+	_, err = a.enforceRolloutPolicy(tInstance, tGroup, tPkg)
+	assert.NoError(t, err)
+
+	// Saturday
+	clock.Set(time.Date(2021, time.June, 12, 12, 0, 0, 0, location))
+	_, err = a.enforceRolloutPolicy(tInstance, tGroup, tPkg)
+	assert.Equal(t, ErrUpdatesDisabled, err)
+}
+
+func TestGetUpdatePackage_SeverityBypassesUpdateWindows(t *testing.T) {
+	// Saturday, outside of the group's update windows.
+	clock := NewMockClock(time.Date(2021, time.June, 12, 12, 0, 0, 0, time.UTC))
+	a, err := NewForTest(OptionInitDB, OptionDisableUpdatesOnFailedRollout, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	_, err = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.0.0", ApplicationID: tApp.ID, Severity: "urgent"})
+	assert.Equal(t, ErrInvalidPackageSeverity, err)
+
+	tPkgRoutine, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	require.NoError(t, err)
+	assert.Equal(t, PackageSeverityRoutine, tPkgRoutine.Severity)
+	tPkgCritical, err := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.2.0", ApplicationID: tApp.ID, Severity: PackageSeverityCritical})
+	require.NoError(t, err)
+
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgRoutine.ID)})
+	tGroup := &Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes",
+		PolicyTimezone: null.StringFrom("UTC"), PolicyUpdateWindows: []UpdateWindow{{Weekday: time.Tuesday, StartTime: "09:00", EndTime: "10:00"}}, PolicyWindowsBypassSeverity: null.StringFrom("urgent")}
+	_, err = a.AddGroup(tGroup)
+	assert.Equal(t, ErrInvalidPackageSeverity, err)
+
+	tGroup.PolicyWindowsBypassSeverity = null.StringFrom(PackageSeverityCritical)
+	tGroup, err = a.AddGroup(tGroup)
+	require.NoError(t, err)
+
+	// Routine packages respect the update windows.
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrOutsideUpdateWindows, err)
+
+	// Critical ones don't.
+	tChannel.PackageID = null.StringFrom(tPkgCritical.ID)
+	require.NoError(t, a.UpdateChannel(tChannel))
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkgCritical.ID, pkg.ID)
+
+	// Without a bypass severity in the group, every package respects them.
+	tGroup.PolicyWindowsBypassSeverity = null.String{}
+	require.NoError(t, a.UpdateGroup(tGroup))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrOutsideUpdateWindows, err)
+}
+
+func TestGetUpdatePackage_ForceUpdateAfter(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionDisableUpdatesOnFailedRollout, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	deadline := clock.Now().Add(time.Hour).Truncate(time.Second)
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	_, _ = a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PackageID: tPkg.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+
+	_, err = a.AddGroup(&Group{Name: "past_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: "60 minutes", PolicyForceUpdateAfter: null.TimeFrom(clock.Now().Add(-time.Minute))})
+	assert.Equal(t, ErrInvalidForceUpdateAfter, err)
+
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: "60 minutes", PolicyForceUpdateAfter: null.TimeFrom(deadline)})
+	require.NoError(t, err)
+
+	// Before the deadline the rollout policy is enforced as usual.
+	firstInstanceID := uuid.New().String()
+	pkg, err := a.GetUpdatePackage(firstInstanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	require.NotNil(t, pkg.FlatcarAction)
+	assert.Empty(t, pkg.FlatcarAction.Deadline)
+
+	instanceID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxUpdatesPerPeriodLimitReached, err)
+
+	// The first update failing makes safe mode disable the updates.
+	require.NoError(t, a.RegisterEvent(firstInstanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultFailed, "", ""))
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrUpdatesDisabled, err)
+
+	require.NoError(t, a.PauseGroup(tGroup.ID))
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrGroupPaused, err)
+
+	// A deadline that already passed can be kept when updating the group.
+	clock.Set(deadline)
+	tGroup, err = a.GetGroup(tGroup.ID)
+	require.NoError(t, err)
+	tGroup.Description = "forced"
+	assert.NoError(t, a.UpdateGroup(tGroup))
+	tGroup.PolicyForceUpdateAfter = null.TimeFrom(deadline.Add(-time.Minute))
+	assert.Equal(t, ErrInvalidForceUpdateAfter, a.UpdateGroup(tGroup))
+
+	// After it, every instance gets the update right away, even though the
+	// group is still paused and safe mode disabled its updates.
+	assert.False(t, tGroup.PolicyUpdatesEnabled)
+	for _, id := range []string{instanceID, uuid.New().String()} {
+		pkg, err = a.GetUpdatePackage(id, "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tPkg.ID, pkg.ID)
+		require.NotNil(t, pkg.FlatcarAction)
+		assert.Equal(t, forcedUpdateDeadline, pkg.FlatcarAction.Deadline)
+	}
+}
+
+func TestGetUpdatePackage_PreReleaseVersions(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkgRC, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0-rc1", ApplicationID: tApp.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgRC.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tChannel.PackageID = null.StringFrom(tPkg.ID)
+	require.NoError(t, a.UpdateChannel(tChannel))
+
+	// Releases are newer than their pre-releases.
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "640.0.0-rc1", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	// Build metadata doesn't make a version newer or older.
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "640.0.0+build.5", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "640.0.1-rc1", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+
+	// Instances going through the channel's history get the release, not
+	// its pre-release.
+	tPkgTarget, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "700.0.0", ApplicationID: tApp.ID, MinPreviousVersion: null.StringFrom("640.0.0")})
+	tChannel.PackageID = null.StringFrom(tPkgTarget.ID)
+	require.NoError(t, a.UpdateChannel(tChannel))
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.4", "600.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+}
+
+func TestGetUpdatePackage_SeverityDeadline(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	deadlines := make(map[string]string)
+	for i, severity := range []string{PackageSeverityRoutine, PackageSeverityImportant, PackageSeverityCritical} {
+		tPkg, err := a.AddPackage(&Package{Type: PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: fmt.Sprintf("12.%d.0", i+1), ApplicationID: tApp.ID, Severity: severity})
+		require.NoError(t, err)
+		_, err = a.AddFlatcarAction(&FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PackageID: tPkg.ID})
+		require.NoError(t, err)
+		tChannel, _ := a.AddChannel(&Channel{Name: severity, Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+		tGroup, _ := a.AddGroup(&Group{Name: severity, ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+		pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+		require.NoError(t, err)
+		require.NotNil(t, pkg.FlatcarAction)
+		deadlines[severity] = pkg.FlatcarAction.Deadline
+
+		if window, ok := packageSeverityDeadlines[severity]; ok {
+			assert.Equal(t, pkg.CreatedTs.UTC().Add(window).Format(time.RFC3339), pkg.FlatcarAction.Deadline)
+		}
+	}
+
+	assert.Empty(t, deadlines[PackageSeverityRoutine], "Routine packages keep the action's deadline.")
+	assert.NotEmpty(t, deadlines[PackageSeverityCritical])
+	assert.NotEqual(t, deadlines[PackageSeverityImportant], deadlines[PackageSeverityCritical])
+}
+
+func TestWithSeverityDeadline(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	expected := "2021-06-02T12:00:00Z"
+
+	for stored, want := range map[string]string{
+		"":                     expected,
+		"2030-01-01T00:00:00Z": expected,
+		"2021-06-01T18:00:00Z": "2021-06-01T18:00:00Z",
+		forcedUpdateDeadline:   forcedUpdateDeadline,
+		"tomorrow":             "tomorrow",
+	} {
+		pkg := &Package{Severity: PackageSeverityCritical, CreatedTs: created, FlatcarAction: &FlatcarAction{Deadline: stored}}
+		assert.Equal(t, want, withSeverityDeadline(pkg).FlatcarAction.Deadline, "stored deadline %q", stored)
+		assert.Equal(t, stored, pkg.FlatcarAction.Deadline, "The package provided isn't modified.")
+	}
+
+	pkg := &Package{Severity: PackageSeverityRoutine, CreatedTs: created, FlatcarAction: &FlatcarAction{Deadline: "2030-01-01T00:00:00Z"}}
+	assert.Equal(t, "2030-01-01T00:00:00Z", withSeverityDeadline(pkg).FlatcarAction.Deadline)
+}
+
+func TestGetUpdatePackage_ObserveOnly(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyObserveOnly: true})
+	require.NoError(t, err)
+	instanceID := uuid.New().String()
+
+	_, reason, err := a.PreviewUpdatePackageWithReason(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID, ArchAMD64)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	assert.Equal(t, UpdateReasonObserveOnly, reason)
+
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err, "Observe only groups never serve updates.")
+
+	instance, err := a.GetInstance(instanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("12.1.0"), instance.Application.ObservedUpdateVersion, "The update that would have been served is recorded.")
+	assert.True(t, instance.Application.ObservedUpdateTs.Valid)
+	assert.False(t, instance.Application.UpdateInProgress)
+	assert.False(t, instance.Application.LastUpdateVersion.Valid)
+
+	// Decisions that wouldn't serve the update aren't recorded.
+	upToDateID := uuid.New().String()
+	_, err = a.GetUpdatePackage(upToDateID, "", "10.0.0.1", "12.1.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoUpdatePackageAvailable, err)
+	instance, err = a.GetInstance(upToDateID, tApp.ID)
+	require.NoError(t, err)
+	assert.False(t, instance.Application.ObservedUpdateVersion.Valid)
+
+	// Once the group serves updates, instances get them.
+	tGroup.PolicyObserveOnly = false
+	require.NoError(t, a.UpdateGroup(tGroup))
+	pkg, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	// UpdateTimeoutActionNone indicates that nothing is done when an update
+	// times out, besides counting it for the safe mode.
+	UpdateTimeoutActionNone = "none"
+
+	// UpdateTimeoutActionFail indicates that the instances whose update timed
+	// out are marked as failed.
+	UpdateTimeoutActionFail = "fail"
+
+	// UpdateTimeoutActionReoffer indicates that the instances whose update
+	// timed out are offered the update again on their next update check.
+	UpdateTimeoutActionReoffer = "reoffer"
+
+	// UpdateTimeoutActionAlert indicates that an activity entry is added for
+	// the instances whose update timed out, leaving them untouched.
+	UpdateTimeoutActionAlert = "alert"
+)
+
+var (
+	// ErrInvalidUpdateTimeoutAction error indicates that the action to take
+	// when an update times out is not one of the supported ones.
+	ErrInvalidUpdateTimeoutAction = newValidationError("nebraska: invalid update timeout action")
+)
+
+// normalizeUpdateTimeoutAction checks the update timeout action of the group
+// provided, defaulting it to UpdateTimeoutActionNone when unset.
+func normalizeUpdateTimeoutAction(group *Group) error {
+	switch group.PolicyUpdateTimeoutAction {
+	case "":
+		group.PolicyUpdateTimeoutAction = UpdateTimeoutActionNone
+	case UpdateTimeoutActionNone, UpdateTimeoutActionFail, UpdateTimeoutActionReoffer, UpdateTimeoutActionAlert:
+	default:
+		return ErrInvalidUpdateTimeoutAction
+	}
+	return nil
+}
+
+// timedOutUpdate identifies an instance whose update timed out.
+type timedOutUpdate struct {
+	InstanceID        string `db:"instance_id"`
+	ApplicationID     string `db:"application_id"`
+	LastUpdateVersion string `db:"last_update_version"`
+}
+
+// SweepUpdateTimeouts looks for the instances that have been updating for
+// longer than their group's update timeout and applies the action configured
+// in the group to each of them, once per update granted.
+func (api *API) SweepUpdateTimeouts() error {
+	query, _, err := api.groupsQuery().
+		Where(goqu.C("policy_update_timeout_action").Neq(UpdateTimeoutActionNone),
+			goqu.L("application_id IN (SELECT id FROM application WHERE deleted_at IS NULL)")).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	groups, err := api.getGroupsFromQuery(query)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if err := api.sweepGroupUpdateTimeouts(group); err != nil {
+			logger.Error().Err(err).Str("groupID", group.ID).Msg("SweepUpdateTimeouts - could not sweep group update timeouts")
+		}
+	}
+
+	return nil
+}
+
+// sweepGroupUpdateTimeouts applies the update timeout action of the group
+// provided to its instances whose update timed out.
+func (api *API) sweepGroupUpdateTimeouts(group *Group) error {
+	query := `
+	SELECT instance_id, application_id, last_update_version
+	FROM instance_application
+	WHERE group_id = $1 AND update_in_progress = true AND update_timed_out = false
+		AND last_update_granted_ts < $2::timestamptz - $3::interval
+	`
+	var timedOut []timedOutUpdate
+	if err := api.db.Select(&timedOut, query, group.ID, api.nowUTC(), group.PolicyUpdateTimeout); err != nil {
+		return err
+	}
+
+	for _, update := range timedOut {
+		if err := api.applyUpdateTimeoutAction(group, update); err != nil {
+			logger.Error().Err(err).Str("instanceID", update.InstanceID).Msg("sweepGroupUpdateTimeouts - could not apply update timeout action")
+		}
+	}
+
+	return nil
+}
+
+// applyUpdateTimeoutAction marks the update of the instance provided as timed
+// out and applies the group's update timeout action to it.
+func (api *API) applyUpdateTimeoutAction(group *Group, update timedOutUpdate) error {
+	query, _, err := goqu.Update("instance_application").
+		Set(goqu.Record{"update_timed_out": true}).
+		Where(goqu.C("instance_id").Eq(update.InstanceID), goqu.C("application_id").Eq(update.ApplicationID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := api.db.Exec(query); err != nil {
+		return err
+	}
+
+	severity := activityWarning
+	switch group.PolicyUpdateTimeoutAction {
+	case UpdateTimeoutActionFail:
+		if err := api.updateInstanceStatus(update.InstanceID, update.ApplicationID, InstanceStatusError); err != nil {
+			return err
+		}
+		severity = activityError
+	case UpdateTimeoutActionReoffer:
+		if err := api.updateInstanceStatus(update.InstanceID, update.ApplicationID, InstanceStatusUndefined); err != nil {
+			return err
+		}
+	}
+
+	if err := api.newInstanceActivityEntry(activityInstanceUpdateTimedOut, severity, update.LastUpdateVersion, update.ApplicationID, group.ID, update.InstanceID); err != nil {
+		logger.Error().Err(err).Msg("applyUpdateTimeoutAction - could not add instance activity")
+	}
+
+	return nil
+}
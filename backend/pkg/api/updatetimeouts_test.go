@@ -0,0 +1,107 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestSweepUpdateTimeouts(t *testing.T) {
+	clock := NewMockClock(time.Now().UTC())
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+
+	_, err = a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes", PolicyUpdateTimeoutAction: "escalate"})
+	assert.Equal(t, ErrInvalidUpdateTimeoutAction, err)
+
+	addGroup := func(name, action string) *Group {
+		group, err := a.AddGroup(&Group{Name: name, ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyUpdateTimeoutAction: action})
+		require.NoError(t, err)
+		return group
+	}
+	grantUpdate := func(group *Group) string {
+		instanceID := uuid.New().String()
+		pkg, err := a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, group.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tPkg.ID, pkg.ID)
+		return instanceID
+	}
+	timeoutActivities := func(instanceID string) int {
+		var count int
+		require.NoError(t, a.db.QueryRow("SELECT count(*) FROM activity WHERE class = $1 AND instance_id = $2", activityInstanceUpdateTimedOut, instanceID).Scan(&count))
+		return count
+	}
+
+	tGroupNone := addGroup("group_none", "")
+	assert.Equal(t, UpdateTimeoutActionNone, tGroupNone.PolicyUpdateTimeoutAction)
+	tGroupFail := addGroup("group_fail", UpdateTimeoutActionFail)
+	tGroupReoffer := addGroup("group_reoffer", UpdateTimeoutActionReoffer)
+	tGroupAlert := addGroup("group_alert", UpdateTimeoutActionAlert)
+
+	noneInstanceID := grantUpdate(tGroupNone)
+	failInstanceID := grantUpdate(tGroupFail)
+	reofferInstanceID := grantUpdate(tGroupReoffer)
+	alertInstanceID := grantUpdate(tGroupAlert)
+
+	// The updates haven't timed out yet.
+	clock.Advance(30 * time.Minute)
+	assert.NoError(t, a.SweepUpdateTimeouts())
+	for _, instanceID := range []string{failInstanceID, reofferInstanceID, alertInstanceID} {
+		instance, err := a.GetInstance(instanceID, tApp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, null.IntFrom(int64(InstanceStatusUpdateGranted)), instance.Application.Status)
+		assert.True(t, instance.Application.UpdateInProgress)
+		assert.Equal(t, 0, timeoutActivities(instanceID))
+	}
+
+	clock.Advance(time.Hour)
+	assert.NoError(t, a.SweepUpdateTimeouts())
+
+	instance, err := a.GetInstance(noneInstanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusUpdateGranted)), instance.Application.Status)
+	assert.True(t, instance.Application.UpdateInProgress)
+	assert.Equal(t, 0, timeoutActivities(noneInstanceID))
+
+	instance, err = a.GetInstance(failInstanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusError)), instance.Application.Status)
+	assert.False(t, instance.Application.UpdateInProgress)
+	assert.Equal(t, 1, timeoutActivities(failInstanceID))
+
+	instance, err = a.GetInstance(reofferInstanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusUndefined)), instance.Application.Status)
+	assert.False(t, instance.Application.UpdateInProgress)
+	assert.Equal(t, 1, timeoutActivities(reofferInstanceID))
+
+	instance, err = a.GetInstance(alertInstanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(InstanceStatusUpdateGranted)), instance.Application.Status)
+	assert.True(t, instance.Application.UpdateInProgress)
+	assert.Equal(t, 1, timeoutActivities(alertInstanceID))
+
+	// Each timed out update is only handled once.
+	clock.Advance(time.Hour)
+	assert.NoError(t, a.SweepUpdateTimeouts())
+	assert.Equal(t, 1, timeoutActivities(alertInstanceID))
+
+	// The instance whose update timed out is offered it again.
+	pkg, err := a.GetUpdatePackage(reofferInstanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroupReoffer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+	instance, err = a.GetInstance(reofferInstanceID, tApp.ID)
+	require.NoError(t, err)
+	assert.True(t, instance.Application.UpdateInProgress)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+)
+
+// updateWindowTimeLayout is the layout of the start and end times of the
+// update windows. An end time of 24:00 stands for the end of the day.
+const (
+	updateWindowTimeLayout = "15:04"
+	updateWindowEndOfDay   = "24:00"
+)
+
+var (
+	// ErrInvalidUpdateWindow error indicates that an update window has an
+	// invalid weekday or time range.
+	ErrInvalidUpdateWindow = newValidationError("nebraska: invalid update window")
+
+	// ErrOverlappingUpdateWindows error indicates that two update windows of
+	// a group overlap.
+	ErrOverlappingUpdateWindows = newValidationError("nebraska: overlapping update windows")
+
+	// ErrOutsideUpdateWindows error indicates that an update can't be granted
+	// because the current time is outside of the group's update windows.
+	ErrOutsideUpdateWindows = errors.New("nebraska: outside of update windows")
+)
+
+// UpdateWindow represents a time range of a weekday in which the instances of
+// a group are allowed to be updated, as in 22:00-24:00 on Saturdays. Times
+// are evaluated in the group's timezone.
+type UpdateWindow struct {
+	Weekday   time.Weekday `db:"weekday" json:"weekday"`
+	StartTime string       `db:"start_time" json:"start_time"`
+	EndTime   string       `db:"end_time" json:"end_time"`
+}
+
+// minutes returns the start and end of the window as minutes since midnight.
+func (w UpdateWindow) minutes() (int, int, error) {
+	start, err := parseUpdateWindowTime(w.StartTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseUpdateWindowTime(w.EndTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseUpdateWindowTime(value string) (int, error) {
+	if value == updateWindowEndOfDay {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse(updateWindowTimeLayout, value)
+	if err != nil {
+		return 0, ErrInvalidUpdateWindow
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateUpdateWindows checks that the update windows provided have valid
+// weekdays and non-empty time ranges that don't overlap each other. Windows
+// can't span midnight, two windows on consecutive days must be used instead.
+func validateUpdateWindows(windows []UpdateWindow) error {
+	type timeRange struct{ start, end int }
+	ranges := make(map[time.Weekday][]timeRange)
+	for _, window := range windows {
+		if window.Weekday < time.Sunday || window.Weekday > time.Saturday {
+			return ErrInvalidUpdateWindow
+		}
+		start, end, err := window.minutes()
+		if err != nil {
+			return err
+		}
+		if start >= end {
+			return ErrInvalidUpdateWindow
+		}
+		ranges[window.Weekday] = append(ranges[window.Weekday], timeRange{start, end})
+	}
+	for _, dayRanges := range ranges {
+		sort.Slice(dayRanges, func(i, j int) bool { return dayRanges[i].start < dayRanges[j].start })
+		for i := 1; i < len(dayRanges); i++ {
+			if dayRanges[i].start < dayRanges[i-1].end {
+				return ErrOverlappingUpdateWindows
+			}
+		}
+	}
+	return nil
+}
+
+// inUpdateWindows checks if the time provided falls in any of the update
+// windows provided, evaluated in the given timezone.
+func inUpdateWindows(now time.Time, tz string, windows []UpdateWindow) bool {
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return false
+	}
+
+	now = now.In(location)
+	minute := now.Hour()*60 + now.Minute()
+	for _, window := range windows {
+		if window.Weekday != now.Weekday() {
+			continue
+		}
+		start, end, err := window.minutes()
+		if err != nil {
+			continue
+		}
+		if minute >= start && minute < end {
+			return true
+		}
+	}
+	return false
+}
+
+// getGroupUpdateWindows returns the update windows of the group provided.
+func (api *API) getGroupUpdateWindows(q sqlx.Queryer, groupID string) ([]UpdateWindow, error) {
+	query, _, err := goqu.From("group_update_window").
+		Select("weekday", "start_time", "end_time").
+		Where(goqu.C("group_id").Eq(groupID)).
+		Order(goqu.C("weekday").Asc(), goqu.C("start_time").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	windows := []UpdateWindow{}
+	for rows.Next() {
+		var window UpdateWindow
+		if err := rows.StructScan(&window); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// setGroupUpdateWindows replaces the update windows of the group provided,
+// within the transaction given.
+func (api *API) setGroupUpdateWindows(tx *sqlx.Tx, groupID string, windows []UpdateWindow) error {
+	query, _, err := goqu.Delete("group_update_window").
+		Where(goqu.C("group_id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	for _, window := range windows {
+		query, _, err := goqu.Insert("group_update_window").
+			Cols("group_id", "weekday", "start_time", "end_time").
+			Vals(goqu.Vals{groupID, int(window.Weekday), window.StartTime, window.EndTime}).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
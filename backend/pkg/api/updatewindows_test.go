@@ -0,0 +1,110 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestValidateUpdateWindows(t *testing.T) {
+	assert.NoError(t, validateUpdateWindows(nil))
+	assert.NoError(t, validateUpdateWindows([]UpdateWindow{
+		{Weekday: time.Saturday, StartTime: "00:00", EndTime: "06:00"},
+		{Weekday: time.Saturday, StartTime: "22:00", EndTime: "24:00"},
+		{Weekday: time.Sunday, StartTime: "00:00", EndTime: "24:00"},
+	}))
+	assert.NoError(t, validateUpdateWindows([]UpdateWindow{
+		{Weekday: time.Monday, StartTime: "02:00", EndTime: "04:00"},
+		{Weekday: time.Monday, StartTime: "01:00", EndTime: "02:00"},
+	}), "Adjacent windows don't overlap.")
+
+	assert.Equal(t, ErrInvalidUpdateWindow, validateUpdateWindows([]UpdateWindow{{Weekday: time.Weekday(7), StartTime: "01:00", EndTime: "02:00"}}))
+	assert.Equal(t, ErrInvalidUpdateWindow, validateUpdateWindows([]UpdateWindow{{Weekday: time.Monday, StartTime: "25:00", EndTime: "26:00"}}))
+	assert.Equal(t, ErrInvalidUpdateWindow, validateUpdateWindows([]UpdateWindow{{Weekday: time.Monday, StartTime: "night", EndTime: "02:00"}}))
+	assert.Equal(t, ErrInvalidUpdateWindow, validateUpdateWindows([]UpdateWindow{{Weekday: time.Monday, StartTime: "22:00", EndTime: "06:00"}}), "Windows can't span midnight.")
+	assert.Equal(t, ErrInvalidUpdateWindow, validateUpdateWindows([]UpdateWindow{{Weekday: time.Monday, StartTime: "02:00", EndTime: "02:00"}}))
+	assert.Equal(t, ErrOverlappingUpdateWindows, validateUpdateWindows([]UpdateWindow{
+		{Weekday: time.Monday, StartTime: "01:00", EndTime: "03:00"},
+		{Weekday: time.Monday, StartTime: "02:00", EndTime: "04:00"},
+	}))
+}
+
+func TestGetUpdatePackage_UpdateWindows(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Saturday, one minute before the window finishes.
+	clock := NewMockClock(time.Date(2021, time.June, 12, 5, 59, 0, 0, location))
+
+	a, err := NewForTest(OptionInitDB, OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	windows := []UpdateWindow{{Weekday: time.Saturday, StartTime: "00:00", EndTime: "06:00"}}
+
+	_, err = a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyUpdateWindows: windows})
+	assert.Equal(t, ErrExpectingValidTimezone, err, "Update windows need a timezone.")
+
+	_, err = a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyTimezone: null.StringFrom("Mars/Olympus_Mons"), PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	assert.Equal(t, ErrExpectingValidTimezone, err, "Timezones must be in the tz database.")
+
+	tGroup, err := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyTimezone: null.StringFrom("Europe/Berlin"), PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyUpdateWindows: windows})
+	require.NoError(t, err)
+
+	group, err := a.GetGroup(tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, windows, group.PolicyUpdateWindows)
+
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tPkg.ID, pkg.ID)
+
+	clock.Advance(time.Minute)
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrOutsideUpdateWindows, err, "The update window is over.")
+
+	// Same time on a Monday.
+	clock.Set(time.Date(2021, time.June, 14, 5, 0, 0, 0, location))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrOutsideUpdateWindows, err)
+
+	group.PolicyUpdateWindows = append(group.PolicyUpdateWindows, UpdateWindow{Weekday: time.Saturday, StartTime: "05:00", EndTime: "07:00"})
+	assert.Equal(t, ErrOverlappingUpdateWindows, a.UpdateGroup(group))
+
+	group.PolicyUpdateWindows = nil
+	assert.NoError(t, a.UpdateGroup(group))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err, "Updates are allowed at any time without update windows.")
+}
+
+func TestInUpdateWindows_DST(t *testing.T) {
+	tz := "Europe/Berlin"
+
+	// Clocks go forward from 02:00 to 03:00 on Sunday, March 28 2021.
+	windows := []UpdateWindow{{Weekday: time.Sunday, StartTime: "01:00", EndTime: "03:30"}}
+	assert.False(t, inUpdateWindows(time.Date(2021, time.March, 27, 23, 59, 0, 0, time.UTC), tz, windows))
+	assert.True(t, inUpdateWindows(time.Date(2021, time.March, 28, 0, 30, 0, 0, time.UTC), tz, windows), "01:30 CET")
+	assert.True(t, inUpdateWindows(time.Date(2021, time.March, 28, 1, 0, 0, 0, time.UTC), tz, windows), "03:00 CEST")
+	assert.False(t, inUpdateWindows(time.Date(2021, time.March, 28, 1, 30, 0, 0, time.UTC), tz, windows), "03:30 CEST")
+
+	// Clocks go back from 03:00 to 02:00 on Sunday, October 31 2021, so the
+	// window is open for two hours.
+	windows = []UpdateWindow{{Weekday: time.Sunday, StartTime: "02:00", EndTime: "03:00"}}
+	assert.False(t, inUpdateWindows(time.Date(2021, time.October, 30, 23, 59, 0, 0, time.UTC), tz, windows), "01:59 CEST")
+	assert.True(t, inUpdateWindows(time.Date(2021, time.October, 31, 0, 30, 0, 0, time.UTC), tz, windows), "02:30 CEST")
+	assert.True(t, inUpdateWindows(time.Date(2021, time.October, 31, 1, 30, 0, 0, time.UTC), tz, windows), "02:30 CET")
+	assert.False(t, inUpdateWindows(time.Date(2021, time.October, 31, 2, 0, 0, 0, time.UTC), tz, windows), "03:00 CET")
+
+	// The same local window starts an hour later in UTC in winter.
+	windows = []UpdateWindow{{Weekday: time.Saturday, StartTime: "22:00", EndTime: "24:00"}}
+	assert.True(t, inUpdateWindows(time.Date(2021, time.October, 30, 20, 30, 0, 0, time.UTC), tz, windows))
+	assert.False(t, inUpdateWindows(time.Date(2021, time.November, 6, 20, 30, 0, 0, time.UTC), tz, windows))
+	assert.True(t, inUpdateWindows(time.Date(2021, time.November, 6, 21, 30, 0, 0, time.UTC), tz, windows))
+}
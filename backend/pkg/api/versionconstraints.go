@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/doug-martin/goqu/v9"
+)
+
+var (
+	// ErrInvalidVersionConstraint error indicates that the version constraint
+	// of a channel can't be parsed.
+	ErrInvalidVersionConstraint = newValidationError("nebraska: invalid version constraint")
+)
+
+// parseVersionConstraint parses the version constraint provided. Besides the
+// ranges supported by semver.ParseRange, like ">=1.0.0 <2.0.0", it supports
+// caret (^1.2.3, same major version) and tilde (~1.2.3, same minor version)
+// constraints.
+func parseVersionConstraint(constraint string) (semver.Range, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" {
+		return nil, ErrInvalidVersionConstraint
+	}
+
+	var lower, upper semver.Version
+	switch constraint[0] {
+	case '^':
+		v, err := semver.Parse(constraint[1:])
+		if err != nil {
+			return nil, ErrInvalidVersionConstraint
+		}
+		lower = v
+		switch {
+		case v.Major > 0:
+			upper = semver.Version{Major: v.Major + 1}
+		case v.Minor > 0:
+			upper = semver.Version{Minor: v.Minor + 1}
+		default:
+			upper = semver.Version{Patch: v.Patch + 1}
+		}
+	case '~':
+		v, err := semver.Parse(constraint[1:])
+		if err != nil {
+			return nil, ErrInvalidVersionConstraint
+		}
+		lower = v
+		upper = semver.Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		r, err := semver.ParseRange(constraint)
+		if err != nil {
+			return nil, ErrInvalidVersionConstraint
+		}
+		return r, nil
+	}
+
+	r, err := semver.ParseRange(fmt.Sprintf(">=%s <%s", lower, upper))
+	if err != nil {
+		return nil, ErrInvalidVersionConstraint
+	}
+	return r, nil
+}
+
+// validateVersionConstraint checks that the channel's version constraint, if
+// any, can be parsed.
+func validateVersionConstraint(channel *Channel) error {
+	if channel.VersionConstraint.String == "" {
+		return nil
+	}
+	_, err := parseVersionConstraint(channel.VersionConstraint.String)
+	return err
+}
+
+// resolveChannelPackage returns the newest package of the channel's
+// application and architecture matching the channel's version constraint,
+// skipping the packages that blacklisted the channel. If no package matches,
+// nil is returned.
+func (api *API) resolveChannelPackage(channel *Channel) (*Package, error) {
+	constraint, err := parseVersionConstraint(channel.VersionConstraint.String)
+	if err != nil {
+		return nil, err
+	}
+
+	query, _, err := goqu.From("package").
+		Select("id", "version").
+		Where(
+			goqu.C("application_id").Eq(channel.ApplicationID),
+			goqu.C("arch").Eq(channel.Arch),
+			goqu.L("NOT EXISTS (SELECT 1 FROM package_channel_blacklist pcb WHERE pcb.package_id = package.id AND pcb.channel_id = ?)", channel.ID),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		bestID     string
+		bestSemver semver.Version
+	)
+	for rows.Next() {
+		var id, version string
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, err
+		}
+		v, err := semver.Make(version)
+		if err != nil || !constraint(v) {
+			continue
+		}
+		if bestID == "" || bestSemver.LT(v) {
+			bestID, bestSemver = id, v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if bestID == "" {
+		return nil, nil
+	}
+	return api.GetPackage(bestID)
+}
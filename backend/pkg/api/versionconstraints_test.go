@@ -0,0 +1,74 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestParseVersionConstraint(t *testing.T) {
+	matches := func(constraint, version string) bool {
+		r, err := parseVersionConstraint(constraint)
+		require.NoError(t, err, constraint)
+		return r(semver.MustParse(version))
+	}
+
+	assert.True(t, matches("^3.0.0", "3.0.0"))
+	assert.True(t, matches("^3.0.0", "3.9.1"))
+	assert.False(t, matches("^3.0.0", "4.0.0"))
+	assert.False(t, matches("^3.1.0", "3.0.9"))
+	assert.True(t, matches("^0.2.3", "0.2.9"))
+	assert.False(t, matches("^0.2.3", "0.3.0"))
+	assert.False(t, matches("^0.0.3", "0.0.4"))
+	assert.True(t, matches("~1.2.3", "1.2.9"))
+	assert.False(t, matches("~1.2.3", "1.3.0"))
+	assert.True(t, matches(">=1.0.0 <2.0.0", "1.5.0"))
+	assert.False(t, matches(">=1.0.0 <2.0.0", "2.0.0"))
+
+	for _, constraint := range []string{"", "^3", "~latest", "latest", "^3.0.0 <4.0.0"} {
+		_, err := parseVersionConstraint(constraint)
+		assert.Equal(t, ErrInvalidVersionConstraint, err, constraint)
+	}
+}
+
+func TestGetUpdatePackage_VersionConstraint(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg1, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "3.0.0", ApplicationID: tApp.ID})
+	_, _ = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "4.0.0", ApplicationID: tApp.ID})
+
+	_, err := a.AddChannel(&Channel{Name: "invalid_channel", Color: "blue", ApplicationID: tApp.ID, VersionConstraint: null.StringFrom("^three")})
+	assert.Equal(t, ErrInvalidVersionConstraint, err)
+
+	tChannel, err := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, VersionConstraint: null.StringFrom("^3.0.0")})
+	require.NoError(t, err)
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	pkg, err := a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "2.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg1.ID, pkg.ID)
+
+	// A new package matching the constraint is served right away.
+	tPkg2, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "3.1.0", ApplicationID: tApp.ID})
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "2.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg2.ID, pkg.ID)
+
+	// Packages that blacklisted the channel are skipped.
+	_, _ = a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "3.2.0", ApplicationID: tApp.ID, ChannelsBlacklist: []string{tChannel.ID}})
+	pkg, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.3", "2.0.0", tApp.ID, tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tPkg2.ID, pkg.ID)
+
+	tChannel.VersionConstraint = null.StringFrom("^5.0.0")
+	require.NoError(t, a.UpdateChannel(tChannel))
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.4", "2.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrNoPackageFound, err)
+}
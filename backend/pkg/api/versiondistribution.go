@@ -0,0 +1,71 @@
+package api
+
+import (
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/doug-martin/goqu/v9"
+)
+
+// VersionDistribution represents the number of instances running each of the
+// versions reported in a group.
+type VersionDistribution map[string]int
+
+// Versions returns the versions of the distribution ordered by semver, oldest
+// first. Versions that are not valid semver go last, sorted alphabetically.
+func (d VersionDistribution) Versions() []string {
+	versions := make([]string, 0, len(d))
+	for version := range d {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		vi, errI := semver.Make(versions[i])
+		vj, errJ := semver.Make(versions[j])
+		switch {
+		case errI == nil && errJ == nil && !vi.EQ(vj):
+			return vi.LT(vj)
+		case errI == nil && errJ != nil:
+			return true
+		case errI != nil && errJ == nil:
+			return false
+		}
+		return versions[i] < versions[j]
+	})
+	return versions
+}
+
+// GetVersionDistribution returns the number of active instances of the group
+// provided running each version. Use Versions to walk it ordered by semver.
+func (api *API) GetVersionDistribution(groupID string) (VersionDistribution, error) {
+	if err := api.checkGroupTeam(groupID); err != nil {
+		return nil, err
+	}
+	query, _, err := goqu.From("instance_application").
+		Select("version", goqu.COUNT("*")).
+		Where(goqu.C("group_id").Eq(groupID),
+			goqu.L("last_check_for_updates > now() at time zone 'utc' - interval ?", api.activeInterval(0)),
+			goqu.L(ignoreFakeInstanceCondition("instance_id"))).
+		GroupBy("version").
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.readDB().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	distribution := VersionDistribution{}
+	for rows.Next() {
+		var version string
+		var count int
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, err
+		}
+		distribution[version] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return distribution, nil
+}
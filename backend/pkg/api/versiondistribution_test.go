@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVersionDistribution(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tGroup, _ := a.AddGroup(&Group{Name: "group", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&Group{Name: "group2", ApplicationID: tApp.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	distribution, err := a.GetVersionDistribution(tGroup.ID)
+	require.NoError(t, err)
+	assert.Empty(t, distribution)
+
+	for version, count := range map[string]int{"9.0.0": 1, "10.0.0": 3, "9.10.0": 2, "9.2.0": 1} {
+		for i := 0; i < count; i++ {
+			_, err := a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", version, tApp.ID, tGroup.ID)
+			require.NoError(t, err)
+		}
+	}
+	_, err = a.RegisterInstance(uuid.New().String(), "", "10.0.0.1", "11.0.0", tApp.ID, tGroup2.ID)
+	require.NoError(t, err)
+
+	distribution, err = a.GetVersionDistribution(tGroup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, VersionDistribution{"9.0.0": 1, "9.2.0": 1, "9.10.0": 2, "10.0.0": 3}, distribution)
+	assert.Equal(t, []string{"9.0.0", "9.2.0", "9.10.0", "10.0.0"}, distribution.Versions())
+}
+
+func TestVersionDistributionVersions(t *testing.T) {
+	distribution := VersionDistribution{"2.0.0": 1, "garbage": 1, "1.10.0": 1, "1.9.0": 1, "another": 1}
+	assert.Equal(t, []string{"1.9.0", "1.10.0", "2.0.0", "another", "garbage"}, distribution.Versions())
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+const (
+	// RolloutStateIdle indicates that there is no rollout in progress in
+	// the group.
+	RolloutStateIdle = "idle"
+
+	// RolloutStateStarted indicates that the group started rolling out a
+	// new version.
+	RolloutStateStarted = "started"
+
+	// RolloutStatePausedBySafeMode indicates that the rollout was stopped
+	// and the group's updates disabled as the first update attempt failed.
+	RolloutStatePausedBySafeMode = "paused-by-safemode"
+
+	// RolloutStateCompleted indicates that all instances in the group were
+	// updated successfully.
+	RolloutStateCompleted = "completed"
+
+	// RolloutStateRolledBack indicates that the group's channel was rolled
+	// back to a previous package as too many updates failed.
+	RolloutStateRolledBack = "rolled-back"
+)
+
+const (
+	// WebhookSignatureHeader is the header holding the HMAC-SHA256 signature
+	// of the body of the requests posted to webhooks.
+	WebhookSignatureHeader = "X-Nebraska-Signature"
+
+	webhookMaxAttempts          = 5
+	defaultWebhookRetryBackoff  = time.Second
+	webhookRequestTimeout       = 10 * time.Second
+	webhookGeneratedSecretBytes = 32
+)
+
+var (
+	// ErrInvalidWebhookURL error indicates that the url provided for a
+	// webhook is not a valid http(s) url.
+	ErrInvalidWebhookURL = newValidationError("nebraska: invalid webhook url")
+
+	webhookClient = &http.Client{Timeout: webhookRequestTimeout}
+)
+
+// Webhook represents an url of an application that will be notified when
+// the rollouts of its groups transition between states. The secret the
+// notifications are signed with is left out of its JSON representation.
+type Webhook struct {
+	ID            string    `db:"id" json:"id"`
+	ApplicationID string    `db:"application_id" json:"application_id"`
+	URL           string    `db:"url" json:"url"`
+	Secret        string    `db:"secret" json:"-"`
+	CreatedTs     time.Time `db:"created_ts" json:"created_ts"`
+}
+
+// RolloutTransition represents the payload posted to the webhooks of an
+// application when the rollout of one of its groups changes its state.
+type RolloutTransition struct {
+	ApplicationID string    `json:"app_id"`
+	GroupID       string    `json:"group_id"`
+	OldState      string    `json:"old_state"`
+	NewState      string    `json:"new_state"`
+	Version       string    `json:"version"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// AddWebhook registers the provided webhook. A random secret is generated
+// when none is provided.
+func (api *API) AddWebhook(webhook *Webhook) (*Webhook, error) {
+	if err := api.checkAppTeam(webhook.ApplicationID); err != nil {
+		return nil, err
+	}
+	if !isValidWebhookURL(webhook.URL) {
+		return nil, ErrInvalidWebhookURL
+	}
+	if webhook.Secret == "" {
+		secret := make([]byte, webhookGeneratedSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	query, _, err := goqu.Insert("webhook").
+		Cols("application_id", "url", "secret").
+		Vals(goqu.Vals{webhook.ApplicationID, webhook.URL, webhook.Secret}).
+		Returning(goqu.T("webhook").All()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	err = api.db.QueryRowx(query).StructScan(webhook)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes the webhook identified by the id provided.
+func (api *API) DeleteWebhook(webhookID string) error {
+	if err := api.checkWebhookTeam(webhookID); err != nil {
+		return err
+	}
+	query, _, err := goqu.Delete("webhook").
+		Where(goqu.C("id").Eq(webhookID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	result, err := api.db.Exec(query)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
+
+// GetWebhook returns the webhook identified by the id provided.
+func (api *API) GetWebhook(webhookID string) (*Webhook, error) {
+	if err := api.checkWebhookTeam(webhookID); err != nil {
+		return nil, err
+	}
+	var webhook Webhook
+
+	query, _, err := goqu.From("webhook").
+		Where(goqu.C("id").Eq(webhookID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	err = api.db.QueryRowx(query).StructScan(&webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhooks returns all webhooks registered for the application provided.
+func (api *API) GetWebhooks(appID string) ([]*Webhook, error) {
+	if err := api.checkAppTeam(appID); err != nil {
+		return nil, err
+	}
+	var webhooks []*Webhook
+
+	query, _, err := goqu.From("webhook").
+		Where(goqu.C("application_id").Eq(appID)).
+		Order(goqu.C("created_ts").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.db.Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		webhook := &Webhook{}
+		if err := rows.StructScan(webhook); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// SignWebhookPayload returns the value of the signature header sent along
+// with the body provided to a webhook using the given secret, so receivers
+// can verify the requests come from Nebraska.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// rolloutState returns the state of the rollout of the group provided as
+// stored in the database.
+func rolloutState(group *Group) string {
+	if group.RolloutInProgress {
+		return RolloutStateStarted
+	}
+	return RolloutStateIdle
+}
+
+// notifyRolloutTransition posts the transition of the group's rollout to the
+// provided state to the webhooks of its application. The delivery happens
+// in the background, so it never blocks the caller.
+func (api *API) notifyRolloutTransition(group *Group, newState, version string) {
+	transition := &RolloutTransition{
+		ApplicationID: group.ApplicationID,
+		GroupID:       group.ID,
+		OldState:      rolloutState(group),
+		NewState:      newState,
+		Version:       version,
+		Timestamp:     api.nowUTC(),
+	}
+	go api.deliverRolloutTransition(transition)
+}
+
+// deliverRolloutTransition posts the transition provided to all the webhooks
+// registered for its application.
+func (api *API) deliverRolloutTransition(transition *RolloutTransition) {
+	webhooks, err := api.GetWebhooks(transition.ApplicationID)
+	if err != nil {
+		logger.Error().Err(err).Str("appID", transition.ApplicationID).Msg("deliverRolloutTransition - could not get webhooks")
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(transition)
+	if err != nil {
+		logger.Error().Err(err).Msg("deliverRolloutTransition - could not encode transition")
+		return
+	}
+	for _, webhook := range webhooks {
+		go api.deliverWebhook(webhook, body)
+	}
+}
+
+// deliverWebhook posts the body provided to the webhook, retrying with an
+// exponential backoff when the delivery fails.
+func (api *API) deliverWebhook(webhook *Webhook, body []byte) {
+	backoff := api.webhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(webhook, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			logger.Error().Err(err).Str("webhookID", webhook.ID).Msg("deliverWebhook - giving up delivering webhook")
+			return
+		}
+		logger.Warn().Err(err).Str("webhookID", webhook.ID).Int("attempt", attempt).Msg("deliverWebhook - delivery failed, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postWebhook posts the signed body provided to the webhook's url.
+func postWebhook(webhook *Webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isValidWebhookURL checks if the url provided is an absolute http(s) url.
+func isValidWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestWebhooks(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+
+	_, err := a.AddWebhook(&Webhook{ApplicationID: tApp.ID, URL: "ftp://example.com/hook"})
+	assert.Equal(t, ErrInvalidWebhookURL, err)
+
+	webhook, err := a.AddWebhook(&Webhook{ApplicationID: tApp.ID, URL: "http://example.com/hook"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, webhook.Secret)
+	webhookJSON, err := json.Marshal(webhook)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(webhookJSON), webhook.Secret)
+
+	webhookX, err := a.GetWebhook(webhook.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, webhook.URL, webhookX.URL)
+
+	webhooks, err := a.GetWebhooks(tApp.ID)
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+
+	assert.NoError(t, a.DeleteWebhook(webhook.ID))
+	assert.Equal(t, ErrNoRowsAffected, a.DeleteWebhook(webhook.ID))
+}
+
+func TestWebhookRolloutTransitions(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	a.webhookRetryBackoff = time.Millisecond
+
+	secret := "test_secret"
+	transitions := make(chan RolloutTransition, 10)
+	failedOnce := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first delivery to exercise the retries.
+		if !failedOnce {
+			failedOnce = true
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, SignWebhookPayload(secret, body), r.Header.Get(WebhookSignatureHeader))
+
+		var transition RolloutTransition
+		require.NoError(t, json.Unmarshal(body, &transition))
+		transitions <- transition
+	}))
+	defer server.Close()
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	_, err := a.AddWebhook(&Webhook{ApplicationID: tApp.ID, URL: server.URL, Secret: secret})
+	require.NoError(t, err)
+
+	instanceID := uuid.New().String()
+	_, err = a.GetUpdatePackage(instanceID, "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+
+	transition := waitForTransition(t, transitions)
+	assert.Equal(t, tApp.ID, transition.ApplicationID)
+	assert.Equal(t, tGroup.ID, transition.GroupID)
+	assert.Equal(t, RolloutStateIdle, transition.OldState)
+	assert.Equal(t, RolloutStateStarted, transition.NewState)
+	assert.Equal(t, "12.1.0", transition.Version)
+	assert.False(t, transition.Timestamp.IsZero())
+
+	err = a.RegisterEvent(instanceID, tApp.ID, tGroup.ID, EventUpdateComplete, ResultSuccessReboot, "12.0.0", "")
+	assert.NoError(t, err)
+
+	transition = waitForTransition(t, transitions)
+	assert.Equal(t, RolloutStateStarted, transition.OldState)
+	assert.Equal(t, RolloutStateCompleted, transition.NewState)
+}
+
+func waitForTransition(t *testing.T, transitions chan RolloutTransition) RolloutTransition {
+	select {
+	case transition := <-transitions:
+		return transition
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for webhook delivery")
+	}
+	return RolloutTransition{}
+}
+
+func TestWebhookRolloutTransitions_TimedOutUpdates(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+
+	transitions := make(chan RolloutTransition, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var transition RolloutTransition
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&transition))
+		transitions <- transition
+	}))
+	defer server.Close()
+
+	updateTimeout := 100 * time.Millisecond
+	updateTimeoutSetting := fmt.Sprintf("%d milliseconds", updateTimeout.Milliseconds())
+
+	tTeam, _ := a.AddTeam(&Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&Application{Name: "test_app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&Package{Type: PkgTypeOther, URL: "http://sample.url/pkg", Version: "12.1.0", ApplicationID: tApp.ID})
+	tChannel, _ := a.AddChannel(&Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID)})
+	tGroup, _ := a.AddGroup(&Group{Name: "group1", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "10 milliseconds", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: updateTimeoutSetting})
+	_, err := a.AddWebhook(&Webhook{ApplicationID: tApp.ID, URL: server.URL})
+	require.NoError(t, err)
+
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.1", "12.0.0", tApp.ID, tGroup.ID)
+	assert.NoError(t, err)
+	transition := waitForTransition(t, transitions)
+	assert.Equal(t, RolloutStateStarted, transition.NewState)
+
+	time.Sleep(updateTimeout + 10*time.Millisecond) // ensure that update timeout is over
+
+	_, err = a.GetUpdatePackage(uuid.New().String(), "", "10.0.0.2", "12.0.0", tApp.ID, tGroup.ID)
+	assert.Equal(t, ErrMaxTimedOutUpdatesLimitReached, err)
+
+	transition = waitForTransition(t, transitions)
+	assert.Equal(t, tGroup.ID, transition.GroupID)
+	assert.Equal(t, RolloutStateStarted, transition.OldState)
+	assert.Equal(t, RolloutStatePausedBySafeMode, transition.NewState)
+	assert.Equal(t, "12.1.0", transition.Version)
+}
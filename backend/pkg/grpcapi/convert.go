@@ -0,0 +1,340 @@
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func nullTimeToProto(t null.Time) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+func nullTimeFromProto(t *timestamppb.Timestamp) null.Time {
+	if t == nil {
+		return null.Time{}
+	}
+	return null.TimeFrom(t.AsTime())
+}
+
+func nullStringToProto(s null.String) *wrapperspb.StringValue {
+	if !s.Valid {
+		return nil
+	}
+	return wrapperspb.String(s.String)
+}
+
+func nullStringFromProto(s *wrapperspb.StringValue) null.String {
+	if s == nil {
+		return null.String{}
+	}
+	return null.StringFrom(s.Value)
+}
+
+func nullIntToProto(i null.Int) *wrapperspb.Int64Value {
+	if !i.Valid {
+		return nil
+	}
+	return wrapperspb.Int64(i.Int64)
+}
+
+func nullIntFromProto(i *wrapperspb.Int64Value) null.Int {
+	if i == nil {
+		return null.Int{}
+	}
+	return null.IntFrom(i.Value)
+}
+
+func appToProto(app *api.Application) *Application {
+	m := &Application{
+		Id:                     app.ID,
+		Name:                   app.Name,
+		Description:            app.Description,
+		CreatedTs:              timeToProto(app.CreatedTs),
+		TeamId:                 app.TeamID,
+		InstanceRetentionDays:  nullIntToProto(app.InstanceRetentionDays),
+		InstanceCount:          int64(app.Instances.Count),
+		DisablePingPersistence: app.DisablePingPersistence,
+	}
+	for _, group := range app.Groups {
+		m.Groups = append(m.Groups, groupToProto(group))
+	}
+	for _, channel := range app.Channels {
+		m.Channels = append(m.Channels, channelToProto(channel))
+	}
+	return m
+}
+
+func appFromProto(m *Application) *api.Application {
+	return &api.Application{
+		ID:                     m.GetId(),
+		Name:                   m.GetName(),
+		Description:            m.GetDescription(),
+		TeamID:                 m.GetTeamId(),
+		InstanceRetentionDays:  nullIntFromProto(m.GetInstanceRetentionDays()),
+		DisablePingPersistence: m.GetDisablePingPersistence(),
+	}
+}
+
+func groupToProto(group *api.Group) *Group {
+	m := &Group{
+		Id:                              group.ID,
+		Name:                            group.Name,
+		Description:                     group.Description,
+		CreatedTs:                       timeToProto(group.CreatedTs),
+		RolloutInProgress:               group.RolloutInProgress,
+		ApplicationId:                   group.ApplicationID,
+		ChannelId:                       nullStringToProto(group.ChannelID),
+		PolicyUpdatesEnabled:            group.PolicyUpdatesEnabled,
+		PolicySafeMode:                  group.PolicySafeMode,
+		PolicyOfficeHours:               group.PolicyOfficeHours,
+		PolicyTimezone:                  nullStringToProto(group.PolicyTimezone),
+		PolicyPeriodInterval:            group.PolicyPeriodInterval,
+		PolicyMaxUpdatesPerPeriod:       int64(group.PolicyMaxUpdatesPerPeriod),
+		PolicyUpdateTimeout:             group.PolicyUpdateTimeout,
+		PolicyUpdateTimeoutAction:       group.PolicyUpdateTimeoutAction,
+		PolicyMinHealthyInstances:       int64(group.PolicyMinHealthyInstances),
+		PolicyMaxVersionSpread:          int64(group.PolicyMaxVersionSpread),
+		PolicyRolloutPercentage:         nullIntToProto(group.PolicyRolloutPercentage),
+		PolicyMaxConcurrentDownloads:    int64(group.PolicyMaxConcurrentDownloads),
+		PolicyMaxConcurrentUpdates:      int64(group.PolicyMaxConcurrentUpdates),
+		PolicyRolloutCohorts:            int64(group.PolicyRolloutCohorts),
+		PolicyActiveCohort:              int64(group.PolicyActiveCohort),
+		PolicyPaused:                    group.PolicyPaused,
+		PolicyRollbackFailurePercentage: nullIntToProto(group.PolicyRollbackFailurePercentage),
+		LastKnownGoodPackageId:          nullStringToProto(group.LastKnownGoodPackageID),
+		PolicyPinnedVersion:             nullStringToProto(group.PolicyPinnedVersion),
+		PolicyForceUpdateAfter:          nullTimeToProto(group.PolicyForceUpdateAfter),
+		Track:                           group.Track,
+		PolicyWindowsBypassSeverity:     nullStringToProto(group.PolicyWindowsBypassSeverity),
+		PolicyMinBakeTime:               nullStringToProto(group.PolicyMinBakeTime),
+		RolloutStartsAt:                 nullTimeToProto(group.RolloutStartsAt),
+		RolloutEndsAt:                   nullTimeToProto(group.RolloutEndsAt),
+		PolicyObserveOnly:               group.PolicyObserveOnly,
+		PolicySafeModeFailureThreshold:  int64(group.PolicySafeModeFailureThreshold),
+		PolicySafeModeFailureWindow:     nullStringToProto(group.PolicySafeModeFailureWindow),
+		PolicyRolloutOrder:              group.PolicyRolloutOrder,
+	}
+	for _, window := range group.PolicyUpdateWindows {
+		m.PolicyUpdateWindows = append(m.PolicyUpdateWindows, &UpdateWindow{
+			Weekday:   int32(window.Weekday),
+			StartTime: window.StartTime,
+			EndTime:   window.EndTime,
+		})
+	}
+	for _, weight := range group.ChannelWeights {
+		m.ChannelWeights = append(m.ChannelWeights, &ChannelWeight{ChannelId: weight.ChannelID, Weight: int64(weight.Weight)})
+	}
+	if group.Channel != nil {
+		m.Channel = channelToProto(group.Channel)
+	}
+	return m
+}
+
+func groupFromProto(m *Group) *api.Group {
+	group := &api.Group{
+		ID:                              m.GetId(),
+		Name:                            m.GetName(),
+		Description:                     m.GetDescription(),
+		ApplicationID:                   m.GetApplicationId(),
+		ChannelID:                       nullStringFromProto(m.GetChannelId()),
+		PolicyUpdatesEnabled:            m.GetPolicyUpdatesEnabled(),
+		PolicySafeMode:                  m.GetPolicySafeMode(),
+		PolicyOfficeHours:               m.GetPolicyOfficeHours(),
+		PolicyTimezone:                  nullStringFromProto(m.GetPolicyTimezone()),
+		PolicyPeriodInterval:            m.GetPolicyPeriodInterval(),
+		PolicyMaxUpdatesPerPeriod:       int(m.GetPolicyMaxUpdatesPerPeriod()),
+		PolicyUpdateTimeout:             m.GetPolicyUpdateTimeout(),
+		PolicyUpdateTimeoutAction:       m.GetPolicyUpdateTimeoutAction(),
+		PolicyMinHealthyInstances:       int(m.GetPolicyMinHealthyInstances()),
+		PolicyMaxVersionSpread:          int(m.GetPolicyMaxVersionSpread()),
+		PolicyRolloutPercentage:         nullIntFromProto(m.GetPolicyRolloutPercentage()),
+		PolicyMaxConcurrentDownloads:    int(m.GetPolicyMaxConcurrentDownloads()),
+		PolicyMaxConcurrentUpdates:      int(m.GetPolicyMaxConcurrentUpdates()),
+		PolicyRolloutCohorts:            int(m.GetPolicyRolloutCohorts()),
+		PolicyActiveCohort:              int(m.GetPolicyActiveCohort()),
+		PolicyPaused:                    m.GetPolicyPaused(),
+		PolicyRollbackFailurePercentage: nullIntFromProto(m.GetPolicyRollbackFailurePercentage()),
+		PolicyPinnedVersion:             nullStringFromProto(m.GetPolicyPinnedVersion()),
+		PolicyForceUpdateAfter:          nullTimeFromProto(m.GetPolicyForceUpdateAfter()),
+		Track:                           m.GetTrack(),
+		PolicyWindowsBypassSeverity:     nullStringFromProto(m.GetPolicyWindowsBypassSeverity()),
+		PolicyMinBakeTime:               nullStringFromProto(m.GetPolicyMinBakeTime()),
+		RolloutStartsAt:                 nullTimeFromProto(m.GetRolloutStartsAt()),
+		RolloutEndsAt:                   nullTimeFromProto(m.GetRolloutEndsAt()),
+		PolicyObserveOnly:               m.GetPolicyObserveOnly(),
+		PolicySafeModeFailureThreshold:  int(m.GetPolicySafeModeFailureThreshold()),
+		PolicySafeModeFailureWindow:     nullStringFromProto(m.GetPolicySafeModeFailureWindow()),
+		PolicyRolloutOrder:              m.GetPolicyRolloutOrder(),
+	}
+	for _, window := range m.GetPolicyUpdateWindows() {
+		group.PolicyUpdateWindows = append(group.PolicyUpdateWindows, api.UpdateWindow{
+			Weekday:   time.Weekday(window.GetWeekday()),
+			StartTime: window.GetStartTime(),
+			EndTime:   window.GetEndTime(),
+		})
+	}
+	for _, weight := range m.GetChannelWeights() {
+		group.ChannelWeights = append(group.ChannelWeights, api.ChannelWeight{ChannelID: weight.GetChannelId(), Weight: int(weight.GetWeight())})
+	}
+	return group
+}
+
+func channelToProto(channel *api.Channel) *Channel {
+	m := &Channel{
+		Id:                channel.ID,
+		Name:              channel.Name,
+		Color:             channel.Color,
+		CreatedTs:         timeToProto(channel.CreatedTs),
+		ApplicationId:     channel.ApplicationID,
+		PackageId:         nullStringToProto(channel.PackageID),
+		Arch:              uint32(channel.Arch),
+		Revision:          int64(channel.Revision),
+		VersionConstraint: nullStringToProto(channel.VersionConstraint),
+	}
+	if channel.Package != nil {
+		m.Package = packageToProto(channel.Package)
+	}
+	return m
+}
+
+func channelFromProto(m *Channel) *api.Channel {
+	return &api.Channel{
+		ID:                m.GetId(),
+		Name:              m.GetName(),
+		Color:             m.GetColor(),
+		ApplicationID:     m.GetApplicationId(),
+		PackageID:         nullStringFromProto(m.GetPackageId()),
+		Arch:              api.Arch(m.GetArch()),
+		Revision:          int(m.GetRevision()),
+		VersionConstraint: nullStringFromProto(m.GetVersionConstraint()),
+	}
+}
+
+func packageToProto(pkg *api.Package) *Package {
+	m := &Package{
+		Id:                 pkg.ID,
+		Type:               int64(pkg.Type),
+		Version:            pkg.Version,
+		Url:                pkg.URL,
+		Filename:           nullStringToProto(pkg.Filename),
+		Description:        nullStringToProto(pkg.Description),
+		Size:               nullStringToProto(pkg.Size),
+		Hash:               nullStringToProto(pkg.Hash),
+		CreatedTs:          timeToProto(pkg.CreatedTs),
+		ChannelsBlacklist:  pkg.ChannelsBlacklist,
+		ApplicationId:      pkg.ApplicationID,
+		Arch:               uint32(pkg.Arch),
+		Mirrors:            pkg.Mirrors,
+		MinPreviousVersion: nullStringToProto(pkg.MinPreviousVersion),
+		Revision:           int64(pkg.Revision),
+		OciRegistry:        nullStringToProto(pkg.OCIRegistry),
+		OciRepository:      nullStringToProto(pkg.OCIRepository),
+		OciDigest:          nullStringToProto(pkg.OCIDigest),
+		Severity:           pkg.Severity,
+	}
+	if action := pkg.FlatcarAction; action != nil {
+		m.FlatcarAction = &FlatcarAction{
+			Id:                    action.ID,
+			Event:                 action.Event,
+			ChromeosVersion:       action.ChromeOSVersion,
+			Sha256:                action.Sha256,
+			NeedsAdmin:            action.NeedsAdmin,
+			IsDelta:               action.IsDelta,
+			DisablePayloadBackoff: action.DisablePayloadBackoff,
+			MetadataSignatureRsa:  action.MetadataSignatureRsa,
+			MetadataSize:          action.MetadataSize,
+			Deadline:              action.Deadline,
+			PayloadSignature:      action.PayloadSignature,
+			SigningKeyId:          action.SigningKeyID,
+			CreatedTs:             timeToProto(action.CreatedTs),
+		}
+	}
+	return m
+}
+
+func packageFromProto(m *Package) *api.Package {
+	pkg := &api.Package{
+		ID:                 m.GetId(),
+		Type:               int(m.GetType()),
+		Version:            m.GetVersion(),
+		URL:                m.GetUrl(),
+		Filename:           nullStringFromProto(m.GetFilename()),
+		Description:        nullStringFromProto(m.GetDescription()),
+		Size:               nullStringFromProto(m.GetSize()),
+		Hash:               nullStringFromProto(m.GetHash()),
+		ChannelsBlacklist:  m.GetChannelsBlacklist(),
+		ApplicationID:      m.GetApplicationId(),
+		Arch:               api.Arch(m.GetArch()),
+		Mirrors:            m.GetMirrors(),
+		MinPreviousVersion: nullStringFromProto(m.GetMinPreviousVersion()),
+		Revision:           int(m.GetRevision()),
+		OCIRegistry:        nullStringFromProto(m.GetOciRegistry()),
+		OCIRepository:      nullStringFromProto(m.GetOciRepository()),
+		OCIDigest:          nullStringFromProto(m.GetOciDigest()),
+		Severity:           m.GetSeverity(),
+	}
+	if action := m.GetFlatcarAction(); action != nil {
+		pkg.FlatcarAction = &api.FlatcarAction{
+			ID:                    action.GetId(),
+			Event:                 action.GetEvent(),
+			ChromeOSVersion:       action.GetChromeosVersion(),
+			Sha256:                action.GetSha256(),
+			NeedsAdmin:            action.GetNeedsAdmin(),
+			IsDelta:               action.GetIsDelta(),
+			DisablePayloadBackoff: action.GetDisablePayloadBackoff(),
+			MetadataSignatureRsa:  action.GetMetadataSignatureRsa(),
+			MetadataSize:          action.GetMetadataSize(),
+			Deadline:              action.GetDeadline(),
+			PayloadSignature:      action.GetPayloadSignature(),
+			SigningKeyID:          action.GetSigningKeyId(),
+		}
+	}
+	return pkg
+}
+
+func instanceToProto(instance *api.Instance) *Instance {
+	app := instance.Application
+	return &Instance{
+		Id:        instance.ID,
+		Ip:        instance.IP,
+		CreatedTs: timeToProto(instance.CreatedTs),
+		Application: &InstanceApplication{
+			ApplicationId:         app.ApplicationID,
+			GroupId:               nullStringToProto(app.GroupID),
+			Version:               app.Version,
+			CreatedTs:             timeToProto(app.CreatedTs),
+			Status:                nullIntToProto(app.Status),
+			LastCheckForUpdates:   timeToProto(app.LastCheckForUpdates),
+			LastUpdateGrantedTs:   nullTimeToProto(app.LastUpdateGrantedTs),
+			LastUpdateVersion:     nullStringToProto(app.LastUpdateVersion),
+			UpdateInProgress:      app.UpdateInProgress,
+			Quarantined:           app.Quarantined,
+			VersionChangedAt:      timeToProto(app.VersionChangedAt),
+			ObservedUpdateVersion: nullStringToProto(app.ObservedUpdateVersion),
+			ObservedUpdateTs:      nullTimeToProto(app.ObservedUpdateTs),
+		},
+		Alias:     instance.Alias,
+		Arch:      uint32(instance.Arch),
+		Board:     instance.Board,
+		Platform:  instance.Platform,
+		Labels:    instance.Labels,
+		Region:    nullStringToProto(instance.Region),
+		FirstSeen: timeToProto(instance.FirstSeen),
+		Note:      nullStringToProto(instance.Note),
+	}
+}
@@ -0,0 +1,393 @@
+package grpcapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+	"github.com/kinvolk/nebraska/backend/pkg/util"
+)
+
+// actor is the name changes made through the gRPC API are attributed to in
+// the audit log.
+const actor = "grpc"
+
+// authorizationMetadataKey is the request metadata key holding the bearer
+// token clients authenticate with.
+const authorizationMetadataKey = "authorization"
+
+var (
+	logger = util.NewLogger("grpcapi")
+
+	// ErrUnauthenticated indicates that the request doesn't carry a valid
+	// token.
+	ErrUnauthenticated = errors.New("grpcapi: missing or invalid token")
+)
+
+// Authenticator authenticates the client of the request of the context
+// provided, returning the id of the team the request is scoped to.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (teamID string, err error)
+}
+
+// TokenAuthenticator authenticates the clients by the bearer token in the
+// authorization metadata of their requests, each token giving access to the
+// applications of one team.
+type TokenAuthenticator struct {
+	teamIDsByToken map[string]string
+}
+
+// NewTokenAuthenticator creates a TokenAuthenticator accepting the tokens
+// provided, mapped to the ids of the teams they give access to.
+func NewTokenAuthenticator(teamIDsByToken map[string]string) *TokenAuthenticator {
+	return &TokenAuthenticator{teamIDsByToken: teamIDsByToken}
+}
+
+// NewTokenAuthenticatorFromFile creates a TokenAuthenticator accepting the
+// tokens in the file provided, one "team_id token" entry per line. Empty lines
+// and lines starting with # are ignored.
+func NewTokenAuthenticatorFromFile(path string) (*TokenAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	teamIDsByToken := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected team_id token", path, lineNumber)
+		}
+		teamIDsByToken[fields[1]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(teamIDsByToken) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return NewTokenAuthenticator(teamIDsByToken), nil
+}
+
+// Authenticate returns the id of the team of the token in the request
+// metadata, or ErrUnauthenticated if there is no known token.
+func (a *TokenAuthenticator) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return "", ErrUnauthenticated
+	}
+	token := []byte(strings.TrimPrefix(values[0], "Bearer "))
+	for knownToken, teamID := range a.teamIDsByToken {
+		if subtle.ConstantTimeCompare(token, []byte(knownToken)) == 1 {
+			return teamID, nil
+		}
+	}
+	return "", ErrUnauthenticated
+}
+
+// teamIDContextKey is the context key the id of the team a request is scoped
+// to is stored with once authenticated.
+type teamIDContextKey struct{}
+
+type server struct {
+	UnimplementedNebraskaServer
+
+	api *api.API
+}
+
+// NewServer returns a gRPC server exposing the Nebraska service backed by the
+// provided api instance. The requests are authenticated by the authenticator
+// provided and scoped to the team it returns; the requests it rejects, or
+// doesn't return a team for, fail with an Unauthenticated status.
+func NewServer(a *api.API, authenticator Authenticator, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(logErrors, authenticate(authenticator)))
+	s := grpc.NewServer(opts...)
+	RegisterNebraskaServer(s, &server{api: a.WithActor(actor)})
+
+	return s
+}
+
+// authenticate returns an interceptor authenticating the requests with the
+// authenticator provided, and storing the team they are scoped to in their
+// context.
+func authenticate(authenticator Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		teamID, err := authenticator.Authenticate(ctx)
+		if err == nil && teamID == "" {
+			err = ErrUnauthenticated
+		}
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(context.WithValue(ctx, teamIDContextKey{}, teamID), req)
+	}
+}
+
+// apiForContext returns the api instance to handle the request of the context
+// provided with, scoped to the team it was authenticated for.
+func (s *server) apiForContext(ctx context.Context) *api.API {
+	teamID, _ := ctx.Value(teamIDContextKey{}).(string)
+	return s.api.WithTeam(teamID)
+}
+
+func logErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		logger.Error().Err(err).Str("method", info.FullMethod).Msg("grpc request failed")
+	}
+	return resp, err
+}
+
+// grpcError maps the errors returned by the api package to a gRPC status,
+// like the REST API does with the http status codes.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, api.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, api.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+//
+// Applications
+//
+
+func (s *server) ListApps(ctx context.Context, req *ListAppsRequest) (*ListAppsResponse, error) {
+	apps, err := s.apiForContext(ctx).GetApps(req.GetTeamId(), req.GetPage(), req.GetPerPage())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, grpcError(err)
+	}
+
+	resp := &ListAppsResponse{}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, appToProto(app))
+	}
+	return resp, nil
+}
+
+func (s *server) GetApp(ctx context.Context, req *GetAppRequest) (*Application, error) {
+	app, err := s.apiForContext(ctx).GetApp(req.GetAppId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return appToProto(app), nil
+}
+
+func (s *server) AddApp(ctx context.Context, req *AddAppRequest) (*Application, error) {
+	app, err := s.apiForContext(ctx).AddAppCloning(appFromProto(req.GetApp()), req.GetCloneFrom())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetApp(ctx, &GetAppRequest{AppId: app.ID})
+}
+
+func (s *server) UpdateApp(ctx context.Context, req *UpdateAppRequest) (*Application, error) {
+	if err := s.apiForContext(ctx).UpdateApp(appFromProto(req.GetApp())); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetApp(ctx, &GetAppRequest{AppId: req.GetApp().GetId()})
+}
+
+func (s *server) DeleteApp(ctx context.Context, req *DeleteAppRequest) (*DeleteResponse, error) {
+	if err := s.apiForContext(ctx).DeleteApp(req.GetAppId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+//
+// Groups
+//
+
+func (s *server) ListGroups(ctx context.Context, req *ListGroupsRequest) (*ListGroupsResponse, error) {
+	groups, err := s.apiForContext(ctx).GetGroups(req.GetAppId(), req.GetPage(), req.GetPerPage())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, grpcError(err)
+	}
+
+	resp := &ListGroupsResponse{}
+	for _, group := range groups {
+		resp.Groups = append(resp.Groups, groupToProto(group))
+	}
+	return resp, nil
+}
+
+func (s *server) GetGroup(ctx context.Context, req *GetGroupRequest) (*Group, error) {
+	group, err := s.apiForContext(ctx).GetGroup(req.GetGroupId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return groupToProto(group), nil
+}
+
+func (s *server) AddGroup(ctx context.Context, req *AddGroupRequest) (*Group, error) {
+	group, err := s.apiForContext(ctx).AddGroup(groupFromProto(req.GetGroup()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetGroup(ctx, &GetGroupRequest{GroupId: group.ID})
+}
+
+func (s *server) UpdateGroup(ctx context.Context, req *UpdateGroupRequest) (*Group, error) {
+	if err := s.apiForContext(ctx).UpdateGroup(groupFromProto(req.GetGroup())); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetGroup(ctx, &GetGroupRequest{GroupId: req.GetGroup().GetId()})
+}
+
+func (s *server) DeleteGroup(ctx context.Context, req *DeleteGroupRequest) (*DeleteResponse, error) {
+	if err := s.apiForContext(ctx).DeleteGroup(req.GetGroupId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+//
+// Channels
+//
+
+func (s *server) ListChannels(ctx context.Context, req *ListChannelsRequest) (*ListChannelsResponse, error) {
+	channels, err := s.apiForContext(ctx).GetChannels(req.GetAppId(), req.GetPage(), req.GetPerPage())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, grpcError(err)
+	}
+
+	resp := &ListChannelsResponse{}
+	for _, channel := range channels {
+		resp.Channels = append(resp.Channels, channelToProto(channel))
+	}
+	return resp, nil
+}
+
+func (s *server) GetChannel(ctx context.Context, req *GetChannelRequest) (*Channel, error) {
+	channel, err := s.apiForContext(ctx).GetChannel(req.GetChannelId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return channelToProto(channel), nil
+}
+
+func (s *server) AddChannel(ctx context.Context, req *AddChannelRequest) (*Channel, error) {
+	channel, err := s.apiForContext(ctx).AddChannel(channelFromProto(req.GetChannel()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetChannel(ctx, &GetChannelRequest{ChannelId: channel.ID})
+}
+
+func (s *server) UpdateChannel(ctx context.Context, req *UpdateChannelRequest) (*Channel, error) {
+	if err := s.apiForContext(ctx).UpdateChannel(channelFromProto(req.GetChannel())); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetChannel(ctx, &GetChannelRequest{ChannelId: req.GetChannel().GetId()})
+}
+
+func (s *server) DeleteChannel(ctx context.Context, req *DeleteChannelRequest) (*DeleteResponse, error) {
+	if err := s.apiForContext(ctx).DeleteChannel(req.GetChannelId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+//
+// Packages
+//
+
+func (s *server) ListPackages(ctx context.Context, req *ListPackagesRequest) (*ListPackagesResponse, error) {
+	pkgs, err := s.apiForContext(ctx).GetPackages(req.GetAppId(), req.GetPage(), req.GetPerPage())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, grpcError(err)
+	}
+
+	resp := &ListPackagesResponse{}
+	for _, pkg := range pkgs {
+		resp.Packages = append(resp.Packages, packageToProto(pkg))
+	}
+	return resp, nil
+}
+
+func (s *server) GetPackage(ctx context.Context, req *GetPackageRequest) (*Package, error) {
+	pkg, err := s.apiForContext(ctx).GetPackage(req.GetPackageId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return packageToProto(pkg), nil
+}
+
+func (s *server) AddPackage(ctx context.Context, req *AddPackageRequest) (*Package, error) {
+	pkg, err := s.apiForContext(ctx).AddPackage(packageFromProto(req.GetPackage()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetPackage(ctx, &GetPackageRequest{PackageId: pkg.ID})
+}
+
+func (s *server) UpdatePackage(ctx context.Context, req *UpdatePackageRequest) (*Package, error) {
+	if err := s.apiForContext(ctx).UpdatePackage(packageFromProto(req.GetPackage())); err != nil {
+		return nil, grpcError(err)
+	}
+	return s.GetPackage(ctx, &GetPackageRequest{PackageId: req.GetPackage().GetId()})
+}
+
+func (s *server) DeletePackage(ctx context.Context, req *DeletePackageRequest) (*DeleteResponse, error) {
+	deletePackage := s.apiForContext(ctx).DeletePackage
+	if req.GetForce() {
+		deletePackage = s.apiForContext(ctx).ForceDeletePackage
+	}
+	if err := deletePackage(req.GetPackageId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &DeleteResponse{}, nil
+}
+
+//
+// Instances
+//
+
+func (s *server) ListInstances(ctx context.Context, req *ListInstancesRequest) (*ListInstancesResponse, error) {
+	p := api.InstancesQueryParams{
+		ApplicationID: req.GetAppId(),
+		GroupID:       req.GetGroupId(),
+		Status:        int(req.GetStatus()),
+		Version:       req.GetVersion(),
+		Page:          req.GetPage(),
+		PerPage:       req.GetPerPage(),
+		Limit:         req.GetLimit(),
+		Offset:        req.GetOffset(),
+		SortBy:        req.GetSortBy(),
+	}
+	result, err := s.apiForContext(ctx).GetInstances(p, req.GetDuration())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &ListInstancesResponse{Total: result.TotalInstances}
+	for _, instance := range result.Instances {
+		resp.Instances = append(resp.Instances, instanceToProto(instance))
+	}
+	return resp, nil
+}
@@ -0,0 +1,181 @@
+package omaha
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedCompression error indicates that the request body is
+	// compressed with a content coding that is not supported.
+	ErrUnsupportedCompression = errors.New("omaha: unsupported content encoding")
+
+	// ErrMalformedCompressedRequest error indicates that the compressed body
+	// of the request can't be decompressed.
+	ErrMalformedCompressedRequest = errors.New("omaha: compressed request is malformed")
+)
+
+// Compression represents the content coding of the bodies of the Omaha
+// requests and responses, so large fleets can save bandwidth. Bodies are not
+// compressed by default.
+type Compression int
+
+const (
+	// CompressionNone means the body is not compressed.
+	CompressionNone Compression = iota
+
+	// CompressionGzip is the gzip content coding.
+	CompressionGzip
+
+	// CompressionDeflate is the deflate content coding, which in HTTP is the
+	// zlib format.
+	CompressionDeflate
+)
+
+// CompressionFromContentEncoding returns the compression matching the
+// Content-Encoding header of a request.
+func CompressionFromContentEncoding(contentEncoding string) (Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return CompressionNone, nil
+	case "gzip", "x-gzip":
+		return CompressionGzip, nil
+	case "deflate":
+		return CompressionDeflate, nil
+	}
+	return CompressionNone, ErrUnsupportedCompression
+}
+
+// CompressionFromAcceptEncoding returns the compression the response to a
+// request should use according to its Accept-Encoding header, preferring
+// gzip over deflate when both are equally acceptable. Responses are not
+// compressed when none of them is acceptable.
+func CompressionFromAcceptEncoding(acceptEncoding string) Compression {
+	var gzipQ, deflateQ, anyQ float64 = -1, -1, -1
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		switch name {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "deflate":
+			deflateQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+	if deflateQ < 0 {
+		deflateQ = anyQ
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return CompressionGzip
+	case deflateQ > 0:
+		return CompressionDeflate
+	}
+	return CompressionNone
+}
+
+// ContentEncoding returns the value of the Content-Encoding header of the
+// bodies compressed this way, empty for uncompressed ones.
+func (c Compression) ContentEncoding() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionDeflate:
+		return "deflate"
+	}
+	return ""
+}
+
+// DecompressRequest returns a reader of the decompressed body of a request
+// compressed as provided. Reading a body that can't be decompressed fails
+// with ErrMalformedCompressedRequest, while the errors of the raw body are
+// returned as they are.
+func DecompressRequest(rawReq io.Reader, c Compression) (io.ReadCloser, error) {
+	src := &errRecordingReader{r: rawReq}
+	var r io.ReadCloser
+	var err error
+	switch c {
+	case CompressionGzip:
+		r, err = gzip.NewReader(src)
+	case CompressionDeflate:
+		r, err = zlib.NewReader(src)
+	default:
+		return ioutil.NopCloser(rawReq), nil
+	}
+	if err != nil {
+		if src.err != nil && src.err != io.EOF && err == src.err {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCompressedRequest, err)
+	}
+	return &decompressingReader{ReadCloser: r, src: src}, nil
+}
+
+// CompressResponse returns a writer compressing the response written to it as
+// provided. It must be closed once the response is written.
+func CompressResponse(respWriter io.Writer, c Compression) io.WriteCloser {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(respWriter)
+	case CompressionDeflate:
+		return zlib.NewWriter(respWriter)
+	}
+	return nopWriteCloser{respWriter}
+}
+
+// errRecordingReader records the last error returned by the reader it wraps,
+// so the errors of the raw body can be told apart from the decompression
+// ones.
+type errRecordingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errRecordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.err = err
+	return n, err
+}
+
+// decompressingReader wraps the decompression errors in
+// ErrMalformedCompressedRequest.
+type decompressingReader struct {
+	io.ReadCloser
+	src *errRecordingReader
+}
+
+func (r *decompressingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && err != r.src.err {
+		err = fmt.Errorf("%w: %v", ErrMalformedCompressedRequest, err)
+	}
+	return n, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,127 @@
+package omaha
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+func TestCompressionFromEncodingHeaders(t *testing.T) {
+	for header, expected := range map[string]Compression{
+		"":                    CompressionNone,
+		"identity":            CompressionNone,
+		"GZIP":                CompressionGzip,
+		"x-gzip":              CompressionGzip,
+		"deflate":             CompressionDeflate,
+		"deflate, gzip":       CompressionGzip,
+		"deflate, gzip;q=0.5": CompressionDeflate,
+		"gzip;q=0, deflate":   CompressionDeflate,
+		"gzip;q=0":            CompressionNone,
+		"*":                   CompressionGzip,
+		"*;q=0, deflate":      CompressionDeflate,
+		"br":                  CompressionNone,
+	} {
+		assert.Equal(t, expected, CompressionFromAcceptEncoding(header), "Accept-Encoding: %q", header)
+	}
+
+	compression, err := CompressionFromContentEncoding(" gzip ")
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionGzip, compression)
+	_, err = CompressionFromContentEncoding("br")
+	assert.Equal(t, ErrUnsupportedCompression, err)
+}
+
+func TestHandle_Compression(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.tgz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	omahaReq := omahaSpec.NewRequest()
+	omahaReq.OS.Platform = reqPlatform
+	omahaReq.OS.Arch = reqArch
+	appReq := omahaReq.AddApp(tApp.ID, "610.0.0")
+	appReq.MachineID = "compressed-machine"
+	appReq.Track = tGroup.ID
+	appReq.AddUpdateCheck()
+	rawOmahaReq, err := xml.Marshal(omahaReq)
+	require.NoError(t, err)
+
+	for compression, newReader := range map[Compression]func(io.Reader) (io.ReadCloser, error){
+		CompressionGzip:    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		CompressionDeflate: zlib.NewReader,
+	} {
+		var compressedReq bytes.Buffer
+		w := CompressResponse(&compressedReq, compression)
+		_, err := w.Write(rawOmahaReq)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		body, err := DecompressRequest(&compressedReq, compression)
+		require.NoError(t, err)
+		var compressedResp bytes.Buffer
+		respWriter := CompressResponse(&compressedResp, compression)
+		require.NoError(t, h.Handle(body, respWriter, "127.0.0.1"))
+		require.NoError(t, respWriter.Close())
+
+		r, err := newReader(&compressedResp)
+		require.NoError(t, err)
+		rawOmahaResp, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		var omahaResp *omahaSpec.Response
+		require.NoError(t, xml.Unmarshal(rawOmahaResp, &omahaResp))
+		checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+		checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "update.tgz", tPkg.URL, omahaSpec.UpdateOK)
+	}
+
+	// Uncompressed requests and responses are left as they are.
+	body, err := DecompressRequest(bytes.NewReader(rawOmahaReq), CompressionNone)
+	require.NoError(t, err)
+	var resp bytes.Buffer
+	respWriter := CompressResponse(&resp, CompressionNone)
+	require.NoError(t, h.Handle(body, respWriter, "127.0.0.1"))
+	require.NoError(t, respWriter.Close())
+	var omahaResp *omahaSpec.Response
+	require.NoError(t, xml.Unmarshal(resp.Bytes(), &omahaResp))
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+}
+
+func TestHandle_MalformedCompressedRequest(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	_, err := DecompressRequest(bytes.NewReader([]byte("not gzip")), CompressionGzip)
+	assert.ErrorIs(t, err, ErrMalformedCompressedRequest)
+	_, err = DecompressRequest(bytes.NewReader(nil), CompressionDeflate)
+	assert.ErrorIs(t, err, ErrMalformedCompressedRequest)
+
+	// A valid gzip header followed by corrupted data.
+	var compressedReq bytes.Buffer
+	w := gzip.NewWriter(&compressedReq)
+	_, _ = w.Write([]byte(`<request protocol="3.0"></request>`))
+	_ = w.Close()
+	corrupted := compressedReq.Bytes()
+	corrupted[len(corrupted)-5] ^= 0xff
+
+	body, err := DecompressRequest(bytes.NewReader(corrupted), CompressionGzip)
+	require.NoError(t, err)
+	err = h.Handle(body, new(bytes.Buffer), "127.0.0.1")
+	assert.ErrorIs(t, err, ErrMalformedCompressedRequest)
+}
@@ -0,0 +1,279 @@
+package omaha
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+	"time"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+// Encoding represents the format used to (de)serialize the Omaha requests
+// and responses. The decision logic doesn't depend on it.
+type Encoding int
+
+const (
+	// EncodingXML is the classic Omaha XML format, used by default.
+	EncodingXML Encoding = iota
+
+	// EncodingJSON is the JSON format spoken by newer update clients, where
+	// requests and responses are the JSON encoding of the Omaha types.
+	EncodingJSON
+)
+
+// EncodingFromContentType returns the encoding matching the content type of
+// a request, falling back to XML when it isn't a JSON one.
+func EncodingFromContentType(contentType string) Encoding {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return EncodingXML
+	}
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return EncodingJSON
+	}
+	return EncodingXML
+}
+
+// ContentType returns the content type of the responses in this encoding.
+func (e Encoding) ContentType() string {
+	if e == EncodingJSON {
+		return "application/json"
+	}
+	return "text/xml"
+}
+
+const (
+	// labelAttrPrefix is the prefix of the attributes of the app elements of
+	// the requests that carry instance labels, like label_region="eu-west".
+	labelAttrPrefix = "label_"
+
+	// eventSequenceAttr is the attribute of the event elements of the
+	// requests that carries the sequence number or nonce of the event, used
+	// to detect resent events.
+	eventSequenceAttr = "sequence"
+)
+
+// appExtensions holds what the instances report in the apps of the requests
+// beyond the Omaha protocol.
+type appExtensions struct {
+	// labels are the labels the instance reported.
+	labels api.InstanceLabels
+	// eventSequences holds the sequence of each of the app's events, empty
+	// for the events without one.
+	eventSequences []string
+}
+
+// eventSequence returns the sequence of the i-th event of the app, if any.
+func (ext appExtensions) eventSequence(i int) string {
+	if i < len(ext.eventSequences) {
+		return ext.eventSequences[i]
+	}
+	return ""
+}
+
+// decodeRequest decodes the Omaha request provided, together with the
+// extensions the instance reported for each of the apps in the request.
+func (e Encoding) decodeRequest(rawReq io.Reader) (*omahaSpec.Request, []appExtensions, error) {
+	data, err := ioutil.ReadAll(rawReq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var omahaReq *omahaSpec.Request
+	if e == EncodingJSON {
+		err = json.Unmarshal(data, &omahaReq)
+	} else {
+		err = xml.Unmarshal(data, &omahaReq)
+	}
+	if err == nil && omahaReq == nil {
+		// A JSON null decodes successfully into a nil request.
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extensions, err := e.decodeExtensions(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return omahaReq, extensions, nil
+}
+
+// decodeExtensions returns the labels carried by the attributes with the
+// labelAttrPrefix prefix of each of the apps in the raw request provided, and
+// the sequences carried by the eventSequenceAttr attribute of their events.
+func (e Encoding) decodeExtensions(data []byte) ([]appExtensions, error) {
+	var extensions []appExtensions
+	addLabel := func(appLabels api.InstanceLabels, name, value string) {
+		if strings.HasPrefix(name, labelAttrPrefix) && len(name) > len(labelAttrPrefix) {
+			appLabels[strings.TrimPrefix(name, labelAttrPrefix)] = value
+		}
+	}
+
+	if e == EncodingJSON {
+		var req struct {
+			Apps []map[string]interface{}
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			return nil, err
+		}
+		for _, app := range req.Apps {
+			ext := appExtensions{labels: api.InstanceLabels{}}
+			for name, value := range app {
+				switch value := value.(type) {
+				case string:
+					addLabel(ext.labels, name, value)
+				case []interface{}:
+					if !strings.EqualFold(name, "events") {
+						continue
+					}
+					for _, event := range value {
+						sequence := ""
+						if event, ok := event.(map[string]interface{}); ok {
+							switch value := event[eventSequenceAttr].(type) {
+							case string:
+								sequence = value
+							case json.Number:
+								sequence = value.String()
+							}
+						}
+						ext.eventSequences = append(ext.eventSequences, sequence)
+					}
+				}
+			}
+			extensions = append(extensions, ext)
+		}
+		return extensions, nil
+	}
+
+	var req struct {
+		Apps []struct {
+			Attrs  []xml.Attr `xml:",any,attr"`
+			Events []struct {
+				Sequence string `xml:"sequence,attr"`
+			} `xml:"event"`
+		} `xml:"app"`
+	}
+	if err := xml.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	for _, app := range req.Apps {
+		ext := appExtensions{labels: api.InstanceLabels{}}
+		for _, attr := range app.Attrs {
+			addLabel(ext.labels, attr.Name.Local, attr.Value)
+		}
+		for _, event := range app.Events {
+			ext.eventSequences = append(ext.eventSequences, event.Sequence)
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions, nil
+}
+
+// appResponseExtensions holds what is told to the instances in the apps of
+// the responses beyond the Omaha protocol.
+type appResponseExtensions struct {
+	// pollInterval is how often the instance should check for updates, 0
+	// when it's not hinted.
+	pollInterval time.Duration
+	// payloadSignature and signingKeyID are the detached signature of the
+	// payload described by the Flatcar action of the manifest, if any, and
+	// the identifier of the key that made it.
+	payloadSignature string
+	signingKeyID     string
+}
+
+// extendedResponse is an Omaha response whose apps carry their extensions,
+// it's encoded like omahaSpec.Response.
+type extendedResponse struct {
+	XMLName  xml.Name              `xml:"response" json:"-"`
+	DayStart omahaSpec.DayStart    `xml:"daystart"`
+	Apps     []extendedAppResponse `xml:"app"`
+	Protocol string                `xml:"protocol,attr"`
+	Server   string                `xml:"server,attr"`
+}
+
+// extendedAppResponse is an app of an Omaha response with its extensions.
+type extendedAppResponse struct {
+	*omahaSpec.AppResponse
+	UpdateCheck  *extendedUpdateResponse `xml:"updatecheck"`
+	PollInterval int64                   `xml:"poll_interval,attr,omitempty" json:",omitempty"`
+}
+
+// extendedUpdateResponse is the update check of an app of an Omaha response
+// whose manifest's actions carry the app's extensions. The only action is the
+// one describing the Flatcar payload.
+type extendedUpdateResponse struct {
+	*omahaSpec.UpdateResponse
+	Manifest *extendedManifest `xml:"manifest"`
+}
+
+// extendedManifest is the manifest of an extendedUpdateResponse.
+type extendedManifest struct {
+	*omahaSpec.Manifest
+	Actions []*extendedAction `xml:"actions>action"`
+}
+
+// extendedAction is an action of an extendedManifest.
+type extendedAction struct {
+	*omahaSpec.Action
+	PayloadSignature string `xml:"PayloadSignature,attr,omitempty" json:",omitempty"`
+	SigningKeyID     string `xml:"SigningKeyId,attr,omitempty" json:",omitempty"`
+}
+
+// newExtendedUpdateResponse returns the update check provided extended with
+// the app's extensions given, nil if there is no update check.
+func newExtendedUpdateResponse(updateCheck *omahaSpec.UpdateResponse, ext appResponseExtensions) *extendedUpdateResponse {
+	if updateCheck == nil {
+		return nil
+	}
+	extUpdateCheck := &extendedUpdateResponse{UpdateResponse: updateCheck}
+	if updateCheck.Manifest == nil {
+		return extUpdateCheck
+	}
+	extUpdateCheck.Manifest = &extendedManifest{Manifest: updateCheck.Manifest}
+	for _, action := range updateCheck.Manifest.Actions {
+		extUpdateCheck.Manifest.Actions = append(extUpdateCheck.Manifest.Actions, &extendedAction{
+			Action:           action,
+			PayloadSignature: ext.payloadSignature,
+			SigningKeyID:     ext.signingKeyID,
+		})
+	}
+	return extUpdateCheck
+}
+
+// encodeResponse encodes the Omaha response provided, together with the
+// extensions of each of its apps.
+func (e Encoding) encodeResponse(respWriter io.Writer, omahaResp *omahaSpec.Response, extensions []appResponseExtensions) error {
+	resp := extendedResponse{
+		DayStart: omahaResp.DayStart,
+		Protocol: omahaResp.Protocol,
+		Server:   omahaResp.Server,
+	}
+	for i, app := range omahaResp.Apps {
+		var ext appResponseExtensions
+		if i < len(extensions) {
+			ext = extensions[i]
+		}
+		extApp := extendedAppResponse{
+			AppResponse:  app,
+			UpdateCheck:  newExtendedUpdateResponse(app.UpdateCheck, ext),
+			PollInterval: int64(ext.pollInterval / time.Second),
+		}
+		resp.Apps = append(resp.Apps, extApp)
+	}
+	if e == EncodingJSON {
+		return json.NewEncoder(respWriter).Encode(resp)
+	}
+	return xml.NewEncoder(respWriter).Encode(resp)
+}
@@ -0,0 +1,51 @@
+package omaha
+
+import (
+	"fmt"
+	"strconv"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+// DescribeEventResult returns a human readable description of an event posted
+// by an instance, given its type, result and error code as found in the Omaha
+// request, like "update complete: error - omaha HTTP response 503 (test omaha
+// url)" for the type 3, the result 0 and the error code 268437959. The error
+// code is only described for the results reporting an error, those unknown to
+// the update_engine error codes table being reported as they are.
+func DescribeEventResult(eventType, result, errorCode string) string {
+	etype, err := strconv.Atoi(eventType)
+	if err != nil {
+		return "unknown event"
+	}
+	eresult, err := strconv.Atoi(result)
+	if err != nil {
+		return fmt.Sprintf("%s: unknown result", omahaSpec.EventType(etype))
+	}
+
+	description := fmt.Sprintf("%s: %s", omahaSpec.EventType(etype), omahaSpec.EventResult(eresult))
+	if !isErrorEventResult(omahaSpec.EventResult(eresult)) || errorCode == "" {
+		return description
+	}
+	errorDescription := api.DescribeErrorCode(errorCode)
+	if errorDescription == errorCode {
+		errorDescription = "error code " + errorCode
+	}
+	return description + " - " + errorDescription
+}
+
+// isErrorEventResult reports whether the event result provided means that
+// the action of the event failed.
+func isErrorEventResult(result omahaSpec.EventResult) bool {
+	switch result {
+	case omahaSpec.EventResultError,
+		omahaSpec.EventResultErrorInstallerMSI,
+		omahaSpec.EventResultErrorInstallerOther,
+		omahaSpec.EventResultInstallerSystem,
+		omahaSpec.EventResultHandoffError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package omaha
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeEventResult(t *testing.T) {
+	assert.Equal(t, "update complete: error - omaha HTTP response 503 (test omaha url)", DescribeEventResult("3", "0", "268437959"))
+	assert.Equal(t, "update complete: error - payload hash mismatch", DescribeEventResult("3", "0", "10"))
+	assert.Equal(t, "update download started: error - download transfer error (resumed)", DescribeEventResult("13", "0", "1073741833"))
+	assert.Equal(t, "update complete: success reboot", DescribeEventResult("3", "2", "0"), "Error codes are only described for errors.")
+	assert.Equal(t, "update download finished: success", DescribeEventResult("14", "1", ""))
+	assert.Equal(t, "update complete: error", DescribeEventResult("3", "0", ""))
+
+	// Fallbacks
+	assert.Equal(t, "update complete: error - error code 1234", DescribeEventResult("3", "0", "1234"))
+	assert.Equal(t, "update complete: error - error code not-a-code", DescribeEventResult("3", "0", "not-a-code"))
+	assert.Equal(t, "event 999: success", DescribeEventResult("999", "1", ""))
+	assert.Equal(t, "update complete: unknown result", DescribeEventResult("3", "", ""))
+	assert.Equal(t, "unknown event", DescribeEventResult("", "0", "10"))
+}
@@ -0,0 +1,109 @@
+package omaha
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// unknownLabel is used instead of the application and group ids of the
+	// requests that couldn't be matched to an existing group, so that the
+	// cardinality of the labels can't be inflated by clients.
+	unknownLabel = "unknown"
+
+	// malformedRequestStatus is the status recorded for the requests that
+	// couldn't be decoded.
+	malformedRequestStatus = "error-malformedRequest"
+)
+
+var (
+	requestsCounterMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nebraska",
+			Subsystem: "omaha",
+			Name:      "requests_total",
+			Help:      "Number of application update requests received through the Omaha protocol",
+		},
+		[]string{
+			"application",
+			"group",
+		},
+	)
+
+	responsesCounterMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "nebraska",
+			Subsystem: "omaha",
+			Name:      "responses_total",
+			Help:      "Number of application update responses sent through the Omaha protocol by status",
+		},
+		[]string{
+			"application",
+			"group",
+			"status",
+		},
+	)
+
+	requestDurationHistogramMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "nebraska",
+			Subsystem: "omaha",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent processing application update requests received through the Omaha protocol",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{
+			"application",
+			"group",
+		},
+	)
+)
+
+// RegisterMetrics registers the Omaha handler metrics with the registerer
+// provided.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{requestsCounterMetric, responsesCounterMetric, requestDurationHistogramMetric} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAppResponse records the outcome of processing the application
+// request provided, which started at the given time. The labels are unknown
+// unless the application is groupAppID, the application of the group, so
+// client provided values don't leak into them.
+func recordAppResponse(appID, groupID, groupAppID string, respApp *omahaSpec.AppResponse, start time.Time) {
+	id, err := uuid.Parse(appID)
+	if err != nil || groupID == "" || id.String() != groupAppID || respApp.Status == omahaSpec.AppStatus("error-unknownApplicationOrGroup") {
+		appID, groupID = unknownLabel, unknownLabel
+	} else {
+		appID = id.String()
+	}
+
+	requestsCounterMetric.WithLabelValues(appID, groupID).Inc()
+	responsesCounterMetric.WithLabelValues(appID, groupID, appResponseStatus(respApp)).Inc()
+	requestDurationHistogramMetric.WithLabelValues(appID, groupID).Observe(time.Since(start).Seconds())
+}
+
+// recordMalformedRequest records a request that couldn't be decoded.
+func recordMalformedRequest() {
+	requestsCounterMetric.WithLabelValues(unknownLabel, unknownLabel).Inc()
+	responsesCounterMetric.WithLabelValues(unknownLabel, unknownLabel, malformedRequestStatus).Inc()
+}
+
+// appResponseStatus returns the status summarizing the application response
+// provided: its error status if any, or the status of its update check.
+func appResponseStatus(respApp *omahaSpec.AppResponse) string {
+	if respApp.Status != "" && respApp.Status != omahaSpec.AppOK {
+		return string(respApp.Status)
+	}
+	if respApp.UpdateCheck != nil {
+		return string(respApp.UpdateCheck.Status)
+	}
+	return string(omahaSpec.AppOK)
+}
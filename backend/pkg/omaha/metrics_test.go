@@ -0,0 +1,59 @@
+package omaha
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+func TestMetrics(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	requests := requestsCounterMetric.WithLabelValues(tApp.ID, tGroup.ID)
+	updates := responsesCounterMetric.WithLabelValues(tApp.ID, tGroup.ID, string(omahaSpec.UpdateOK))
+	noUpdates := responsesCounterMetric.WithLabelValues(tApp.ID, tGroup.ID, string(omahaSpec.NoUpdate))
+	unknownRequests := requestsCounterMetric.WithLabelValues(unknownLabel, unknownLabel)
+	malformedResponses := responsesCounterMetric.WithLabelValues(unknownLabel, unknownLabel, malformedRequestStatus)
+
+	requestsBefore := testutil.ToFloat64(requests)
+	updatesBefore := testutil.ToFloat64(updates)
+	noUpdatesBefore := testutil.ToFloat64(noUpdates)
+	unknownRequestsBefore := testutil.ToFloat64(unknownRequests)
+	malformedResponsesBefore := testutil.ToFloat64(malformedResponses)
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "metrics-machine-1", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	omahaResp = doOmahaRequest(t, h, tApp.ID, tPkg.Version, "metrics-machine-2", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+
+	assert.Equal(t, requestsBefore+2, testutil.ToFloat64(requests))
+	assert.Equal(t, updatesBefore+1, testutil.ToFloat64(updates))
+	assert.Equal(t, noUpdatesBefore+1, testutil.ToFloat64(noUpdates))
+
+	// Unknown groups, groups of other applications and malformed requests
+	// don't leak client provided values into the labels.
+	_ = doOmahaRequest(t, h, tApp.ID, "610.0.0", "metrics-machine-3", "invalid-track", "127.0.0.1", false, true, nil)
+	tApp2, _ := a.AddApp(&api.Application{Name: "test_app2", Description: "Test app 2", TeamID: tTeam.ID})
+	_ = doOmahaRequest(t, h, tApp2.ID, "610.0.0", "metrics-machine-4", tGroup.ID, "127.0.0.1", false, true, nil)
+	err := h.Handle(strings.NewReader("not xml"), new(bytes.Buffer), "127.0.0.1")
+	assert.Error(t, err)
+
+	assert.Equal(t, unknownRequestsBefore+3, testutil.ToFloat64(unknownRequests))
+	assert.Zero(t, testutil.ToFloat64(requestsCounterMetric.WithLabelValues(tApp2.ID, tGroup.ID)))
+	assert.Equal(t, malformedResponsesBefore+1, testutil.ToFloat64(malformedResponses))
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 
+	"github.com/blang/semver/v4"
 	omahaSpec "github.com/kinvolk/go-omaha/omaha"
 	"github.com/rs/zerolog"
 
@@ -14,6 +16,13 @@ import (
 	"github.com/kinvolk/nebraska/backend/pkg/util"
 )
 
+const (
+	// unknownVersion is stored instead of the versions reported by instances
+	// which can't be stored, as they are empty or too long. Like any other
+	// version which isn't valid semver, it's considered outdated.
+	unknownVersion = "unknown"
+)
+
 var (
 	logger = util.NewLogger("omaha")
 
@@ -42,7 +51,12 @@ var (
 // Handler represents a component capable of processing Omaha requests. It uses
 // the Nebraska API to get packages updates, process events, etc.
 type Handler struct {
-	crAPI *api.API
+	crAPI     *api.API
+	recorder  *RequestRecorder
+	urlSigner URLSigner
+
+	rolloutPollInterval time.Duration
+	idlePollInterval    time.Duration
 }
 
 // NewHandler creates a new Handler instance.
@@ -52,27 +66,103 @@ func NewHandler(crAPI *api.API) *Handler {
 	}
 }
 
-// Handle is in charge of processing an Omaha request.
+// Handle is in charge of processing an XML encoded Omaha request. Each app
+// of the request is processed independently, as gateways aggregating the
+// reports of several machines may send apps with different machine ids in
+// the same request, so an app failing doesn't prevent the others from being
+// handled.
 func (h *Handler) Handle(rawReq io.Reader, respWriter io.Writer, ip string) error {
-	var omahaReq *omahaSpec.Request
+	return h.HandleEncoding(rawReq, respWriter, ip, EncodingXML)
+}
+
+// HandleEncoding works like Handle for an Omaha request in the encoding
+// provided, writing the response in the same encoding.
+func (h *Handler) HandleEncoding(rawReq io.Reader, respWriter io.Writer, ip string, encoding Encoding) error {
+	return h.handle(rawReq, respWriter, ip, encoding, false, nil)
+}
+
+// UpdateDecisionKey identifies the application of a machine an update
+// decision was made for, as a single request can hold several machines
+// checking for updates to the same application.
+type UpdateDecisionKey struct {
+	MachineID string
+	AppID     string
+}
+
+// HandleWithReasons works like HandleEncoding, but also returns the reason of the
+// update decision made for each application in the request that checked
+// for updates, keyed by machine and application id. The reasons are logged
+// too, so unexpected noupdate responses can be diagnosed.
+func (h *Handler) HandleWithReasons(rawReq io.Reader, respWriter io.Writer, ip string, encoding Encoding) (map[UpdateDecisionKey]api.UpdateDecisionReason, error) {
+	reasons := make(map[UpdateDecisionKey]api.UpdateDecisionReason)
+	err := h.handle(rawReq, respWriter, ip, encoding, false, reasons)
+	return reasons, err
+}
 
-	if err := xml.NewDecoder(rawReq).Decode(&omahaReq); err != nil {
+// HandleDryRun responds to an Omaha request with the update decision Handle
+// would make, but without registering the instances, their pings or events,
+// nor granting them updates, so the request doesn't count against the
+// rollout policy limits of their groups. Events are acknowledged without
+// being checked.
+func (h *Handler) HandleDryRun(rawReq io.Reader, respWriter io.Writer, ip string) error {
+	return h.HandleDryRunEncoding(rawReq, respWriter, ip, EncodingXML)
+}
+
+// HandleDryRunEncoding works like HandleDryRun for an Omaha request in the
+// encoding provided, writing the response in the same encoding.
+func (h *Handler) HandleDryRunEncoding(rawReq io.Reader, respWriter io.Writer, ip string, encoding Encoding) error {
+	return h.handle(rawReq, respWriter, ip, encoding, true, nil)
+}
+
+// HandleDryRunWithReasons works like HandleDryRunEncoding, but also returns the
+// reasons of the update decisions like HandleWithReasons.
+func (h *Handler) HandleDryRunWithReasons(rawReq io.Reader, respWriter io.Writer, ip string, encoding Encoding) (map[UpdateDecisionKey]api.UpdateDecisionReason, error) {
+	reasons := make(map[UpdateDecisionKey]api.UpdateDecisionReason)
+	err := h.handle(rawReq, respWriter, ip, encoding, true, reasons)
+	return reasons, err
+}
+
+// handle processes the Omaha request provided. When reasons is not nil, the
+// reasons of the update decisions are stored in it.
+func (h *Handler) handle(rawReq io.Reader, respWriter io.Writer, ip string, encoding Encoding, dryRun bool, reasons map[UpdateDecisionKey]api.UpdateDecisionReason) error {
+	if !dryRun {
+		var err error
+		if rawReq, err = h.recordRequest(rawReq, ip, encoding); err != nil {
+			return fmt.Errorf("%s: %w", ErrMalformedRequest, err)
+		}
+	}
+	omahaReq, extensions, err := encoding.decodeRequest(rawReq)
+	if err != nil {
 		logger.Warn().Msgf("Handle - malformed omaha request error %s", err.Error())
+		if !dryRun {
+			recordMalformedRequest()
+		}
 		return fmt.Errorf("%s: %w", ErrMalformedRequest, err)
 	}
 	trace(omahaReq)
 
-	omahaResp, err := h.buildOmahaResponse(omahaReq, ip)
+	omahaResp, respExtensions, err := h.buildOmahaResponse(omahaReq, extensions, ip, dryRun, reasons)
 	if err != nil {
 		logger.Warn().Msgf("Handle - error building omaha response error %s", err.Error())
 		return ErrMalformedResponse
 	}
 	trace(omahaResp)
 
-	return xml.NewEncoder(respWriter).Encode(omahaResp)
+	return encoding.encodeResponse(respWriter, omahaResp, respExtensions)
 }
 
 func getArch(os *omahaSpec.OS, appReq *omahaSpec.AppRequest) api.Arch {
+	arch := getInstanceArch(os, appReq)
+	if arch == api.ArchAll {
+		logger.Debug().Msg("getArch - unknown arch, assuming amd64 arch")
+		return api.ArchAMD64
+	}
+	return arch
+}
+
+// getInstanceArch returns the architecture reported by the instance, or
+// api.ArchAll if it couldn't be determined.
+func getInstanceArch(os *omahaSpec.OS, appReq *omahaSpec.AppRequest) api.Arch {
 	arch, err := api.ArchFromCoreosString(appReq.Board)
 	if err == nil {
 		return arch
@@ -83,15 +173,16 @@ func getArch(os *omahaSpec.OS, appReq *omahaSpec.AppRequest) api.Arch {
 			return arch
 		}
 	}
-	logger.Debug().Msg("getArch - unknown arch, assuming amd64 arch")
-	return api.ArchAMD64
+	return api.ArchAll
 }
 
-func (h *Handler) buildOmahaResponse(omahaReq *omahaSpec.Request, ip string) (*omahaSpec.Response, error) {
+func (h *Handler) buildOmahaResponse(omahaReq *omahaSpec.Request, extensions []appExtensions, ip string, dryRun bool, reasons map[UpdateDecisionKey]api.UpdateDecisionReason) (*omahaSpec.Response, []appResponseExtensions, error) {
 	omahaResp := omahaSpec.NewResponse()
 	omahaResp.Server = "nebraska"
+	respExtensions := make([]appResponseExtensions, len(omahaReq.Apps))
 
-	for _, reqApp := range omahaReq.Apps {
+	for i, reqApp := range omahaReq.Apps {
+		start := time.Now()
 		respApp := omahaResp.AddApp(reqApp.ID, omahaSpec.AppOK)
 
 		// Use the Omaha track field to find the group. It preferably contains the group's track name
@@ -101,6 +192,11 @@ func (h *Handler) buildOmahaResponse(omahaReq *omahaSpec.Request, ip string) (*o
 			logger.Info().Str("machineId", reqApp.MachineID).Str("uuid", group).Msgf("buildOmahaResponse - found client using a hard-coded group UUID")
 			group = trackName
 		}
+		if track, err := h.crAPI.ResolveTrackAlias(reqApp.ID, group); err == nil {
+			group = track
+		} else {
+			logger.Warn().Str("machineId", reqApp.MachineID).Str("track", group).Err(err).Msg("buildOmahaResponse - could not resolve track alias")
+		}
 		groupID, err := h.crAPI.GetGroupID(group, getArch(omahaReq.OS, reqApp))
 		if err == nil {
 			group = groupID
@@ -108,41 +204,125 @@ func (h *Handler) buildOmahaResponse(omahaReq *omahaSpec.Request, ip string) (*o
 			logger.Info().Str("machineId", reqApp.MachineID).Str("track", group).Msgf("buildOmahaResponse - no group found for track and arch error %s", err.Error())
 			respApp.Status = h.getStatusMessage(err)
 			respApp.AddUpdateCheck(omahaSpec.UpdateInternalError)
-			return omahaResp, nil
+			if !dryRun {
+				recordAppResponse(reqApp.ID, "", "", respApp, start)
+			}
+			// The request may carry the apps of other machines, forwarded
+			// by a gateway, which must still be processed.
+			continue
+		}
+
+		// Malformed versions are still stored and shown as reported, the
+		// update decisions consider them outdated.
+		version := reqApp.Version
+		malformedVersion := false
+		if _, err := semver.Make(version); err != nil {
+			logger.Warn().Str("machineId", reqApp.MachineID).Str("version", version).Msg("buildOmahaResponse - malformed version")
+			malformedVersion = true
+			if version == "" || len(version) > api.MaxInstanceVersionLength {
+				version = unknownVersion
+			}
+		}
+
+		var ext appExtensions
+		if i < len(extensions) {
+			ext = extensions[i]
 		}
 
-		for _, event := range reqApp.Events {
-			if err := h.processEvent(reqApp.MachineID, reqApp.ID, group, event); err != nil {
+		for j, event := range reqApp.Events {
+			respEvent := respApp.AddEvent()
+			if dryRun {
+				continue
+			}
+			// Resent events are acknowledged like the original ones.
+			if err := h.processEvent(reqApp.MachineID, reqApp.ID, group, event, ext.eventSequence(j)); err != nil {
 				logger.Debug().Str("machineId", reqApp.MachineID).Msgf("processEvent error %s", err.Error())
+				if err == api.ErrEventTypeNotAllowed {
+					respEvent.Status = h.getStatusMessageStr(err)
+				}
 			}
-			respApp.AddEvent()
 		}
 
 		if reqApp.Ping != nil {
-			if _, err := h.crAPI.RegisterInstance(reqApp.MachineID, reqApp.MachineAlias, ip, reqApp.Version, reqApp.ID, group); err != nil {
-				logger.Debug().Str("machineId", reqApp.MachineID).Msgf("processPing error %s", err.Error())
+			if !dryRun {
+				if _, err := h.crAPI.RegisterPing(reqApp.MachineID, reqApp.MachineAlias, ip, version, reqApp.ID, group); err != nil {
+					logger.Debug().Str("machineId", reqApp.MachineID).Msgf("processPing error %s", err.Error())
+				}
 			}
 			respApp.AddPing()
 		}
 
 		if reqApp.UpdateCheck != nil {
-			pkg, err := h.crAPI.GetUpdatePackage(reqApp.MachineID, reqApp.MachineAlias, ip, reqApp.Version, reqApp.ID, group)
-			if err != nil && err != api.ErrNoUpdatePackageAvailable {
+			getUpdatePackage := h.crAPI.GetUpdatePackageWithReason
+			if dryRun {
+				getUpdatePackage = h.crAPI.PreviewUpdatePackageWithReason
+			}
+			pkg, reason, err := getUpdatePackage(reqApp.MachineID, reqApp.MachineAlias, ip, version, reqApp.ID, group, getInstanceArch(omahaReq.OS, reqApp))
+			if reasons != nil {
+				logger.Info().Str("machineId", reqApp.MachineID).Str("appID", reqApp.ID).Str("group", group).Str("reason", string(reason)).Msg("buildOmahaResponse - update decision")
+				reasons[UpdateDecisionKey{MachineID: reqApp.MachineID, AppID: reqApp.ID}] = reason
+			}
+			if err != nil && !isNoUpdateError(err) {
 				respApp.Status = h.getStatusMessage(err)
 				respApp.AddUpdateCheck(omahaSpec.UpdateInternalError)
 			} else {
-				h.prepareUpdateCheck(respApp, pkg)
+				h.prepareUpdateCheck(respApp, &respExtensions[i], pkg, reqApp.MachineID)
+			}
+		}
+
+		// The hint is computed after the update decision, as granting an
+		// update may have started the group's rollout.
+		respExtensions[i].pollInterval = h.pollInterval(group)
+
+		if !dryRun {
+			platform := ""
+			if omahaReq.OS != nil {
+				platform = omahaReq.OS.Platform
+			}
+			if err := h.crAPI.UpdateInstanceSystemInfo(reqApp.MachineID, getInstanceArch(omahaReq.OS, reqApp), reqApp.Board, platform); err != nil {
+				logger.Debug().Str("machineId", reqApp.MachineID).Msgf("UpdateInstanceSystemInfo error %s", err.Error())
+			}
+			// Instances not reporting labels keep the ones they had.
+			if len(ext.labels) > 0 {
+				if err := h.crAPI.UpdateInstanceLabels(reqApp.MachineID, ext.labels); err != nil {
+					logger.Debug().Str("machineId", reqApp.MachineID).Msgf("UpdateInstanceLabels error %s", err.Error())
+				}
 			}
 		}
+
+		if malformedVersion && !dryRun {
+			if err := h.crAPI.RegisterMalformedInstanceVersion(reqApp.MachineID, reqApp.ID, group, reqApp.Version); err != nil {
+				logger.Debug().Str("machineId", reqApp.MachineID).Msgf("RegisterMalformedInstanceVersion error %s", err.Error())
+			}
+		}
+
+		if !dryRun {
+			groupAppID, _ := h.crAPI.GetGroupApplicationID(group)
+			recordAppResponse(reqApp.ID, group, groupAppID, respApp, start)
+		}
 	}
 
-	return omahaResp, nil
+	return omahaResp, respExtensions, nil
 }
 
-func (h *Handler) processEvent(machineID string, appID string, group string, event *omahaSpec.EventRequest) error {
-	logger.Info().Str("machineId", machineID).Str("appID", appID).Str("group", group).Str("event", event.Type.String()+"."+event.Result.String()).Str("previousVersion", event.PreviousVersion).Msgf("processEvent eventError %d", event.ErrorCode)
+func (h *Handler) processEvent(machineID string, appID string, group string, event *omahaSpec.EventRequest, sequence string) error {
+	logger.Info().Str("machineId", machineID).Str("appID", appID).Str("group", group).Str("event", event.Type.String()+"."+event.Result.String()).Str("previousVersion", event.PreviousVersion).Str("sequence", sequence).Msgf("processEvent eventError %d", event.ErrorCode)
 
-	return h.crAPI.RegisterEvent(machineID, appID, group, int(event.Type), int(event.Result), event.PreviousVersion, strconv.Itoa(event.ErrorCode))
+	return h.crAPI.RegisterEventWithSequence(machineID, appID, group, int(event.Type), int(event.Result), event.PreviousVersion, strconv.Itoa(event.ErrorCode), sequence)
+}
+
+// isNoUpdateError checks if the error provided, returned when looking for an
+// update for an instance, just means that there is no update for it yet, like
+// outside of the group's update windows, while the group is paused or while
+// too many instances of the group are downloading the update. Those are
+// answered with a noupdate instead of an error status, so the instance just
+// checks again later.
+func isNoUpdateError(err error) bool {
+	switch err {
+	case api.ErrNoUpdatePackageAvailable, api.ErrOutsideUpdateWindows, api.ErrGroupPaused, api.ErrMaxConcurrentDownloadsLimitReached:
+		return true
+	}
+	return false
 }
 
 func (h *Handler) getStatusMessage(crErr error) omahaSpec.AppStatus {
@@ -166,12 +346,20 @@ func (h *Handler) getStatusMessageStr(crErr error) string {
 		return "error-maxConcurrentUpdatesLimitReached"
 	case api.ErrMaxTimedOutUpdatesLimitReached:
 		return "error-maxTimedOutUpdatesLimitReached"
+	case api.ErrMinHealthyInstancesLimitReached:
+		return "error-minHealthyInstancesLimitReached"
+	case api.ErrRolloutPercentageLimitReached:
+		return "error-rolloutPercentageLimitReached"
+	case api.ErrRolloutCohortNotActive:
+		return "error-rolloutCohortNotActive"
 	case api.ErrUpdatesDisabled:
 		return "error-updatesDisabled"
 	case api.ErrGetUpdatesStatsFailed:
 		return "error-couldNotCheckUpdatesStats"
 	case api.ErrUpdateInProgressOnInstance:
 		return "error-updateInProgressOnInstance"
+	case api.ErrEventTypeNotAllowed:
+		return "error-eventTypeNotAllowed"
 	}
 
 	logger.Warn().Msgf("getStatusMessage error %s", crErr.Error())
@@ -179,12 +367,17 @@ func (h *Handler) getStatusMessageStr(crErr error) string {
 	return "error-failedToRetrieveUpdatePackageInfo"
 }
 
-func (h *Handler) prepareUpdateCheck(appResp *omahaSpec.AppResponse, pkg *api.Package) {
+func (h *Handler) prepareUpdateCheck(appResp *omahaSpec.AppResponse, ext *appResponseExtensions, pkg *api.Package, machineID string) {
 	if pkg == nil {
 		appResp.AddUpdateCheck(omahaSpec.NoUpdate)
 		return
 	}
 
+	if pkg.Type == api.PkgTypeOCI {
+		prepareOCIUpdateCheck(appResp, pkg)
+		return
+	}
+
 	// Create a manifest, but do not add it to UpdateCheck until it's successful
 	manifest := &omahaSpec.Manifest{Version: pkg.Version}
 	mpkg := manifest.AddPackage()
@@ -200,27 +393,105 @@ func (h *Handler) prepareUpdateCheck(appResp *omahaSpec.AppResponse, pkg *api.Pa
 	}
 	mpkg.Required = true
 
-	switch pkg.Type {
-	case api.PkgTypeFlatcar:
-		cra, err := h.crAPI.GetFlatcarAction(pkg.ID)
-		if err != nil {
+	if err := h.addPackageArtifacts(manifest, pkg); err != nil {
+		logger.Error().Err(err).Str("packageID", pkg.ID).Msg("prepareUpdateCheck - getting package artifacts")
+		appResp.AddUpdateCheck(omahaSpec.UpdateInternalError)
+		return
+	}
+
+	// Only Flatcar packages need an action in the manifest, the manifest of
+	// other packages just carries their version, hash, size and URLs. The
+	// action describes the package's payload, the first in the manifest.
+	if pkg.Type == api.PkgTypeFlatcar {
+		if err := h.addFlatcarAction(manifest, ext, pkg); err != nil {
+			logger.Error().Err(err).Str("packageID", pkg.ID).Msg("prepareUpdateCheck - getting flatcar action")
 			appResp.AddUpdateCheck(omahaSpec.UpdateInternalError)
 			return
 		}
-		a := manifest.AddAction(cra.Event)
-		a.DisplayVersion = cra.ChromeOSVersion
-		a.SHA256 = cra.Sha256
-		a.NeedsAdmin = cra.NeedsAdmin
-		a.IsDeltaPayload = cra.IsDelta
-		a.DisablePayloadBackoff = cra.DisablePayloadBackoff
-		a.MetadataSignatureRsa = cra.MetadataSignatureRsa
-		a.MetadataSize = cra.MetadataSize
-		a.Deadline = cra.Deadline
 	}
 
+	urls, err := h.packageURLs(pkg, machineID)
+	if err != nil {
+		logger.Error().Err(err).Str("packageID", pkg.ID).Str("machineId", machineID).Msg("prepareUpdateCheck - signing package urls")
+		appResp.AddUpdateCheck(omahaSpec.UpdateInternalError)
+		return
+	}
+
+	updateCheck := appResp.AddUpdateCheck(omahaSpec.UpdateOK)
+	updateCheck.Manifest = manifest
+	for _, url := range urls {
+		updateCheck.AddURL(url)
+	}
+}
+
+// prepareOCIUpdateCheck adds to the response provided the update check of the
+// given OCI package, which carries the reference of its image instead of a
+// payload URL: the codebase points to the image's registry and the package
+// name is its repository and digest, so agents can pull the image from
+// codebase + name as they'd download any other payload.
+func prepareOCIUpdateCheck(appResp *omahaSpec.AppResponse, pkg *api.Package) {
+	manifest := &omahaSpec.Manifest{Version: pkg.Version}
+	mpkg := manifest.AddPackage()
+	mpkg.Name = pkg.OCIRepository.String + "@" + pkg.OCIDigest.String
+	mpkg.Required = true
+
 	updateCheck := appResp.AddUpdateCheck(omahaSpec.UpdateOK)
 	updateCheck.Manifest = manifest
-	updateCheck.AddURL(pkg.URL)
+	updateCheck.AddURL("oci://" + pkg.OCIRegistry.String + "/")
+}
+
+// addPackageArtifacts adds to the manifest provided the artifacts shipped
+// with the given package, after the package's payload. They are downloaded
+// from the same URLs as the payload.
+func (h *Handler) addPackageArtifacts(manifest *omahaSpec.Manifest, pkg *api.Package) error {
+	artifacts, err := h.crAPI.GetPackageArtifacts(pkg.ID)
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		mpkg := manifest.AddPackage()
+		mpkg.Name = artifact.Name
+		mpkg.SHA1 = artifact.Hash.String
+		mpkg.SHA256 = artifact.Sha256
+		if artifact.Size.Valid {
+			size, err := strconv.ParseUint(artifact.Size.String, 10, 64)
+			if err != nil {
+				logger.Warn().Msgf("addPackageArtifacts bad artifact size %s", err.Error())
+			} else {
+				mpkg.Size = size
+			}
+		}
+		mpkg.Required = artifact.Required
+	}
+	return nil
+}
+
+// addFlatcarAction adds to the manifest provided the postinstall action of the
+// given Flatcar package, used by the update engine to verify the payload. The
+// action loaded with the package is used when present, as it describes the
+// delta payload when the package offered is a delta one. The action's payload
+// signature and signing key, which the Omaha protocol has no attributes for,
+// are set in the app's extensions.
+func (h *Handler) addFlatcarAction(manifest *omahaSpec.Manifest, ext *appResponseExtensions, pkg *api.Package) error {
+	cra := pkg.FlatcarAction
+	if cra == nil {
+		var err error
+		if cra, err = h.crAPI.GetFlatcarAction(pkg.ID); err != nil {
+			return err
+		}
+	}
+	a := manifest.AddAction(cra.Event)
+	a.DisplayVersion = cra.ChromeOSVersion
+	a.SHA256 = cra.Sha256
+	a.NeedsAdmin = cra.NeedsAdmin
+	a.IsDeltaPayload = cra.IsDelta
+	a.DisablePayloadBackoff = cra.DisablePayloadBackoff
+	a.MetadataSignatureRsa = cra.MetadataSignatureRsa
+	a.MetadataSize = cra.MetadataSize
+	a.Deadline = cra.Deadline
+	ext.payloadSignature = cra.PayloadSignature
+	ext.signingKeyID = cra.SigningKeyID
+	return nil
 }
 
 func trace(v interface{}) {
@@ -2,13 +2,20 @@ package omaha
 
 import (
 	"bytes"
+	"database/sql"
+	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kinvolk/nebraska/backend/pkg/api"
 
+	"github.com/blang/semver/v4"
 	omahaSpec "github.com/kinvolk/go-omaha/omaha"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -83,10 +90,78 @@ func TestInvalidRequests(t *testing.T) {
 	omahaResp = doOmahaRequest(t, h, "invalid-app-uuid", validUnverifiedAppVersion, validUnregisteredMachineID, tGroup.ID, validUnregisteredIP, addPing, updateCheck, noEventInfo)
 	checkOmahaResponse(t, omahaResp, "invalid-app-uuid", omahaSpec.AppStatus("error-instanceRegistrationFailed"))
 
+	// Malformed versions are treated as outdated ones
 	omahaResp = doOmahaRequest(t, h, tApp.ID, "", validUnregisteredMachineID, tGroup.ID, validUnregisteredIP, addPing, updateCheck, noEventInfo)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+}
+
+func TestRegistrationPolicy(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	err := a.SetInstanceRegistrationPolicy(tApp.ID, &api.InstanceRegistrationPolicy{Rules: []api.InstanceRegistrationRule{
+		{Action: api.RegistrationRuleDeny, CIDR: "10.0.1.0/24"},
+		{Action: api.RegistrationRuleAllow, CIDR: "10.0.0.0/16"},
+	}})
+	require.NoError(t, err)
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "1.0.0", "allowed-machine", tGroup.ID, "10.0.2.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "1.0.0", "denied-machine", tGroup.ID, "10.0.1.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppStatus("error-instanceRegistrationFailed"))
+
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "1.0.0", "unlisted-machine", tGroup.ID, "192.168.1.1", false, true, nil)
 	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppStatus("error-instanceRegistrationFailed"))
 }
 
+func TestMalformedVersion(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	machineIP := "127.0.0.1"
+	machineID := "65e1266d-6f54-4b87-9080-23b99ca9c12f"
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "garbage-version", machineID, tGroup.ID, machineIP, true, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaPingResponse(t, omahaResp, tApp.ID, true)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+
+	instance, err := a.GetInstance(machineID, tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "garbage-version", instance.Application.Version)
+
+	activityEntries, err := a.GetActivity(tTeam.ID, api.ActivityQueryParams{AppID: tApp.ID, InstanceID: machineID, Page: 1, PerPage: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, activityEntries, 1) {
+		assert.Equal(t, `"garbage-version"`, activityEntries[0].Version)
+	}
+
+	// Versions which can't be stored are replaced.
+	emptyMachineID := "3b0d5ac2-f1a7-4a43-8ab4-6ab1d0f5c0a4"
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "", emptyMachineID, tGroup.ID, machineIP, true, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	instance, err = a.GetInstance(emptyMachineID, tApp.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, unknownVersion, instance.Application.Version)
+}
+
 func TestAppNoUpdateForAppWithChannelAndPackageName(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -222,6 +297,699 @@ func TestAppUpdateForAppWithChannelAndPackageName(t *testing.T) {
 	checkOmahaUpdateResponse(t, omahaResp, tPkgFlatcar640.Version, "", "", omahaSpec.NoUpdate)
 }
 
+func TestAppAllowedEventTypes(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tAppFlatcar, _ := a.GetApp(flatcarAppID)
+	tPkgFlatcar640, _ := a.AddPackage(&api.Package{Type: api.PkgTypeFlatcar, URL: "http://sample.url/pkg", Version: "99640.0.0", ApplicationID: tAppFlatcar.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "mychannel", Color: "white", ApplicationID: tAppFlatcar.ID, PackageID: null.StringFrom(tPkgFlatcar640.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "Production", ApplicationID: tAppFlatcar.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	err := a.SetAppAllowedEventTypes(tAppFlatcar.ID, []int{api.EventUpdateDownloadStarted, api.EventUpdateComplete})
+	assert.NoError(t, err)
+
+	machineIP := "127.0.0.1"
+	machineID := "65e1266d-6f54-4b87-9080-23b99ca9c12f"
+	oldAppVersion := "610.0.0"
+
+	omahaResp := doOmahaRequest(t, h, tAppFlatcar.ID, oldAppVersion, machineID, tGroup.ID, machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, tAppFlatcar.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkgFlatcar640.Version, "", tPkgFlatcar640.URL, omahaSpec.UpdateOK)
+
+	// Allowed event type
+	omahaResp = doOmahaRequest(t, h, tAppFlatcar.ID, oldAppVersion, machineID, tGroup.ID, machineIP, false, false, ei(omahaSpec.EventTypeUpdateDownloadStarted, omahaSpec.EventResultSuccess, ""))
+	checkOmahaResponse(t, omahaResp, tAppFlatcar.ID, omahaSpec.AppOK)
+	checkOmahaEventResponse(t, omahaResp, tAppFlatcar.ID, 1)
+
+	// Disallowed event type
+	omahaResp = doOmahaRequest(t, h, tAppFlatcar.ID, oldAppVersion, machineID, tGroup.ID, machineIP, false, false, ei(omahaSpec.EventTypeUpdateDownloadFinished, omahaSpec.EventResultSuccess, ""))
+	checkOmahaResponse(t, omahaResp, tAppFlatcar.ID, omahaSpec.AppOK)
+	if assert.Len(t, omahaResp.Apps[0].Events, 1) {
+		assert.Equal(t, "error-eventTypeNotAllowed", omahaResp.Apps[0].Events[0].Status)
+	}
+
+	instance, err := a.GetInstance(machineID, tAppFlatcar.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, null.IntFrom(int64(api.InstanceStatusDownloading)), instance.Application.Status)
+}
+
+func TestPackageMirrorURLs(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64, Mirrors: []string{"http://mirror1.url/pkg", "http://mirror2.url/pkg"}})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "65e1266d-6f54-4b87-9080-23b99ca9c12f", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+
+	updateCheck := omahaResp.Apps[0].UpdateCheck
+	if assert.NotNil(t, updateCheck) && assert.Len(t, updateCheck.URLs, 3) {
+		assert.Equal(t, tPkg.URL, updateCheck.URLs[0].CodeBase)
+		assert.Equal(t, "http://mirror1.url/pkg", updateCheck.URLs[1].CodeBase)
+		assert.Equal(t, "http://mirror2.url/pkg", updateCheck.URLs[2].CodeBase)
+	}
+}
+
+func TestArchPackageFiltering(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkgAll, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAll})
+	tChannelAll, _ := a.AddChannel(&api.Channel{Name: "all_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgAll.ID), Arch: api.ArchAll})
+	_, _ = a.AddGroup(&api.Group{Name: "all_group", Track: "multiarch", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannelAll.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	tPkgAMD64, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg-amd64", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannelAMD64, _ := a.AddChannel(&api.Channel{Name: "amd64_channel", Color: "red", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkgAMD64.ID), Arch: api.ArchAMD64})
+	_, _ = a.AddGroup(&api.Group{Name: "amd64_group", Track: "amd64only", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannelAMD64.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	// A group whose channel serves all architectures updates both amd64 and
+	// arm64 instances.
+	omahaResp := doOmahaRequestWithArch(t, h, tApp.ID, "610.0.0", "arch-machine-1", "multiarch", "127.0.0.1", "x64", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkgAll.Version, "", tPkgAll.URL, omahaSpec.UpdateOK)
+
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "610.0.0", "arch-machine-2", "multiarch", "127.0.0.1", "arm", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkgAll.Version, "", tPkgAll.URL, omahaSpec.UpdateOK)
+
+	// A group whose channel serves amd64 updates amd64 instances.
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "610.0.0", "arch-machine-3", "amd64only", "127.0.0.1", "x64", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkgAMD64.Version, "", tPkgAMD64.URL, omahaSpec.UpdateOK)
+
+	// A package override is only offered to instances of its architecture.
+	tPkgAMD64Next, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg-amd64-next", Version: "650.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "640.0.0", "arch-machine-4", "multiarch", "127.0.0.1", "x64", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+	assert.NoError(t, a.SetInstancePackageOverride("arch-machine-4", tPkgAMD64Next.ID))
+
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "640.0.0", "arch-machine-4", "multiarch", "127.0.0.1", "x64", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkgAMD64Next.Version, "", tPkgAMD64Next.URL, omahaSpec.UpdateOK)
+
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "640.0.0", "arch-machine-5", "multiarch", "127.0.0.1", "arm", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+	assert.NoError(t, a.SetInstancePackageOverride("arch-machine-5", tPkgAMD64Next.ID))
+
+	omahaResp = doOmahaRequestWithArch(t, h, tApp.ID, "640.0.0", "arch-machine-5", "multiarch", "127.0.0.1", "arm", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+}
+
+func TestDeletedApp(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	assert.NoError(t, a.DeleteApp(tApp.ID))
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "deleted-app-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppStatus("error-instanceRegistrationFailed"))
+
+	assert.NoError(t, a.RestoreApp(tApp.ID))
+
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "deleted-app-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+}
+
+func TestDisablePingPersistence(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID, DisablePingPersistence: true})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	// Pings are acknowledged without being stored.
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "ping-machine", tGroup.ID, "127.0.0.1", true, false, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaPingResponse(t, omahaResp, tApp.ID, true)
+	_, err := a.GetInstance("ping-machine", tApp.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// Update checks and events are still processed.
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "update-machine", tGroup.ID, "127.0.0.1", true, true, nil)
+	checkOmahaPingResponse(t, omahaResp, tApp.ID, true)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "update-machine", tGroup.ID, "127.0.0.1", true, false, ei(omahaSpec.EventTypeUpdateDownloadStarted, omahaSpec.EventResultSuccess, ""))
+	checkOmahaPingResponse(t, omahaResp, tApp.ID, true)
+	checkOmahaEventResponse(t, omahaResp, tApp.ID, 1)
+	instance, err := a.GetInstance("update-machine", tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int(api.InstanceStatusDownloading), int(instance.Application.Status.Int64))
+}
+
+func TestDryRun(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: "60 minutes"})
+
+	machineID := "dry-run-machine"
+	machineIP := "127.0.0.1"
+
+	dryRunResp := doOmahaDryRunRequest(t, h, tApp.ID, "610.0.0", machineID, tGroup.ID, machineIP, true, true, nil)
+	checkOmahaResponse(t, dryRunResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaPingResponse(t, dryRunResp, tApp.ID, true)
+	checkOmahaUpdateResponse(t, dryRunResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+
+	_, err := a.GetInstance(machineID, tApp.ID)
+	assert.Error(t, err, "Dry-run requests must not register instances.")
+
+	// The dry-run didn't use the only update allowed in the period.
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", machineID, tGroup.ID, machineIP, true, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	assert.Equal(t, omahaResp.Apps[0].UpdateCheck, dryRunResp.Apps[0].UpdateCheck)
+
+	// A dry-run for another instance reports the limit was reached, without
+	// disabling anything.
+	dryRunResp = doOmahaDryRunRequest(t, h, tApp.ID, "610.0.0", "dry-run-machine2", tGroup.ID, machineIP, false, true, nil)
+	checkOmahaResponse(t, dryRunResp, tApp.ID, omahaSpec.AppStatus("error-maxUpdatesPerPeriodLimitReached"))
+
+	dryRunResp = doOmahaDryRunRequest(t, h, tApp.ID, "610.0.0", machineID, tGroup.ID, machineIP, false, false, ei(omahaSpec.EventTypeUpdateComplete, omahaSpec.EventResultError, ""))
+	checkOmahaEventResponse(t, dryRunResp, tApp.ID, 1)
+
+	group, err := a.GetGroup(tGroup.ID)
+	assert.NoError(t, err)
+	assert.True(t, group.PolicyUpdatesEnabled, "Dry-run events must not trigger the safe mode.")
+}
+
+func TestSeverityDeadline(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tAppFlatcar, _ := a.GetApp(flatcarAppID)
+	deadlines := make(map[string]string)
+	for i, severity := range []string{api.PackageSeverityRoutine, api.PackageSeverityCritical} {
+		tPkg, err := a.AddPackage(&api.Package{Type: api.PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("flatcarupdate.tgz"), Version: fmt.Sprintf("9964%d.0.0", i), ApplicationID: tAppFlatcar.ID, Arch: api.ArchAMD64, Severity: severity})
+		require.NoError(t, err)
+		_, err = a.AddFlatcarAction(&api.FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PackageID: tPkg.ID})
+		require.NoError(t, err)
+		tChannel, _ := a.AddChannel(&api.Channel{Name: severity, Color: "white", ApplicationID: tAppFlatcar.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+		tGroup, _ := a.AddGroup(&api.Group{Name: severity, ApplicationID: tAppFlatcar.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+
+		omahaResp := doOmahaRequest(t, h, tAppFlatcar.ID, "610.0.0", fmt.Sprintf("severity-machine-%d", i), tGroup.ID, "127.0.0.1", false, true, nil)
+		checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "flatcarupdate.tgz", tPkg.URL, omahaSpec.UpdateOK)
+		deadlines[severity] = omahaResp.Apps[0].UpdateCheck.Manifest.Actions[0].Deadline
+	}
+
+	assert.Empty(t, deadlines[api.PackageSeverityRoutine])
+	deadline, err := time.Parse(time.RFC3339, deadlines[api.PackageSeverityCritical])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), deadline, time.Minute, "Critical packages must be applied within a day.")
+}
+
+func TestAppUpdateForOtherPackage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Filename: null.StringFrom("agent.tgz"), Hash: null.StringFrom("w1b2u7mOHBlAHz+0dZ0yYgRA2Fs="), Size: null.StringFrom("123456"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "other-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "agent.tgz", tPkg.URL, omahaSpec.UpdateOK)
+
+	manifest := omahaResp.Apps[0].UpdateCheck.Manifest
+	require.NotNil(t, manifest)
+	assert.Equal(t, tPkg.Version, manifest.Version)
+	assert.Empty(t, manifest.Actions)
+	require.Len(t, manifest.Packages, 1)
+	assert.Equal(t, uint64(123456), manifest.Packages[0].Size)
+	assert.Equal(t, tPkg.Hash.String, manifest.Packages[0].SHA1)
+	assert.True(t, manifest.Packages[0].Required)
+}
+
+func TestMaxConcurrentDownloads(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", PolicyMaxConcurrentDownloads: 1})
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "downloading-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "downloading-machine", tGroup.ID, "127.0.0.1", false, false, ei(omahaSpec.EventTypeUpdateDownloadStarted, omahaSpec.EventResultSuccess, ""))
+	checkOmahaEventResponse(t, omahaResp, tApp.ID, 1)
+
+	// Instances exceeding the cap are just told there is no update yet.
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "waiting-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "downloading-machine", tGroup.ID, "127.0.0.1", false, false, ei(omahaSpec.EventTypeUpdateDownloadFinished, omahaSpec.EventResultSuccess, ""))
+	checkOmahaEventResponse(t, omahaResp, tApp.ID, 1)
+
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "waiting-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+}
+
+func TestAppUpdateForOCIPackage(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, err := a.AddPackage(&api.Package{Type: api.PkgTypeOCI, OCIRegistry: null.StringFrom("registry.example.com:5000"), OCIRepository: null.StringFrom("team/agent"), OCIDigest: null.StringFrom(digest), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	require.NoError(t, err)
+	assert.Equal(t, "oci://registry.example.com:5000/team/agent@"+digest, tPkg.URL)
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "oci-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "team/agent@"+digest, "oci://registry.example.com:5000/", omahaSpec.UpdateOK)
+
+	manifest := omahaResp.Apps[0].UpdateCheck.Manifest
+	require.NotNil(t, manifest)
+	assert.Empty(t, manifest.Actions)
+	require.Len(t, manifest.Packages, 1)
+	assert.Empty(t, manifest.Packages[0].SHA1)
+	assert.True(t, manifest.Packages[0].Required)
+	require.Len(t, omahaResp.Apps[0].UpdateCheck.URLs, 1)
+}
+
+func TestAppUpdateDelta(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tFlatcarAction, _ := a.AddFlatcarAction(&api.FlatcarAction{Event: "postinstall", Sha256: "full-sha256", PackageID: tPkg.ID})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	_, err := a.AddPackageDelta(&api.PackageDelta{PackageID: tPkg.ID, FromVersion: "610.0.0", URL: "http://sample.url/delta", Filename: null.StringFrom("delta.gz"), Sha256: "delta-sha256"})
+	require.NoError(t, err)
+
+	// The instance at 610 gets the 610->640 delta.
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "delta-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "delta.gz", "http://sample.url/delta", omahaSpec.UpdateOK)
+	manifest := omahaResp.Apps[0].UpdateCheck.Manifest
+	require.NotNil(t, manifest)
+	require.Len(t, manifest.Actions, 1)
+	assert.True(t, manifest.Actions[0].IsDeltaPayload)
+	assert.Equal(t, "delta-sha256", manifest.Actions[0].SHA256)
+
+	// The fresh instance at 500 gets the full payload.
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "500.0.0", "full-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "update.gz", tPkg.URL, omahaSpec.UpdateOK)
+	manifest = omahaResp.Apps[0].UpdateCheck.Manifest
+	require.NotNil(t, manifest)
+	require.Len(t, manifest.Actions, 1)
+	checkOmahaFlatcarAction(t, tFlatcarAction, manifest.Actions[0])
+}
+
+func TestAppUpdateArtifacts(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.gz"), Size: null.StringFrom("1000"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tFlatcarAction, _ := a.AddFlatcarAction(&api.FlatcarAction{Event: "postinstall", Sha256: "full-sha256", PackageID: tPkg.ID})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+	_, err := a.AddPackageArtifact(&api.PackageArtifact{PackageID: tPkg.ID, Name: "oem.gz", Size: null.StringFrom("200"), Hash: null.StringFrom("oem-hash"), Sha256: "oem-sha256", Required: true})
+	require.NoError(t, err)
+	_, err = a.AddPackageArtifact(&api.PackageArtifact{PackageID: tPkg.ID, Name: "sysext.raw", Size: null.StringFrom("300")})
+	require.NoError(t, err)
+
+	for encoding, machineID := range map[Encoding]string{EncodingXML: "xml-machine", EncodingJSON: "json-machine"} {
+		omahaResp := doOmahaRequestWithEncoding(t, h, encoding, tApp.ID, "610.0.0", machineID, tGroup.ID, "127.0.0.1", false, true, nil)
+		checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+		checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "update.gz", tPkg.URL, omahaSpec.UpdateOK)
+		manifest := omahaResp.Apps[0].UpdateCheck.Manifest
+		require.NotNil(t, manifest)
+		require.Len(t, manifest.Packages, 3)
+		assert.Equal(t, uint64(1000), manifest.Packages[0].Size)
+		assert.Equal(t, "oem.gz", manifest.Packages[1].Name)
+		assert.Equal(t, uint64(200), manifest.Packages[1].Size)
+		assert.Equal(t, "oem-hash", manifest.Packages[1].SHA1)
+		assert.Equal(t, "oem-sha256", manifest.Packages[1].SHA256)
+		assert.True(t, manifest.Packages[1].Required)
+		assert.Equal(t, "sysext.raw", manifest.Packages[2].Name)
+		assert.Equal(t, uint64(300), manifest.Packages[2].Size)
+		assert.False(t, manifest.Packages[2].Required)
+		require.Len(t, manifest.Actions, 1)
+		checkOmahaFlatcarAction(t, tFlatcarAction, manifest.Actions[0])
+	}
+}
+
+func TestMultipleMachinesRequest(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.tgz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	// A gateway forwards the reports of two machines, the first one using a
+	// track that doesn't exist.
+	omahaReq := omahaSpec.NewRequest()
+	omahaReq.OS.Version = reqVersion
+	omahaReq.OS.Platform = reqPlatform
+	omahaReq.OS.ServicePack = reqSp
+	omahaReq.OS.Arch = reqArch
+	for machineID, track := range map[string]string{"failing-machine": "unknown_track", "ok-machine": tGroup.ID} {
+		appReq := omahaReq.AddApp(tApp.ID, "610.0.0")
+		appReq.MachineID = machineID
+		appReq.Track = track
+		appReq.AddUpdateCheck()
+		appReq.AddPing()
+	}
+	rawOmahaReq, err := xml.Marshal(omahaReq)
+	require.NoError(t, err)
+
+	rawOmahaResp := new(bytes.Buffer)
+	require.NoError(t, h.Handle(bytes.NewReader(rawOmahaReq), rawOmahaResp, "127.0.0.1"))
+	var omahaResp *omahaSpec.Response
+	require.NoError(t, xml.Unmarshal(rawOmahaResp.Bytes(), &omahaResp))
+	require.Len(t, omahaResp.Apps, 2)
+
+	for i, reqApp := range omahaReq.Apps {
+		respApp := omahaResp.Apps[i]
+		assert.Equal(t, tApp.ID, respApp.ID)
+		require.NotNil(t, respApp.UpdateCheck)
+		switch reqApp.MachineID {
+		case "failing-machine":
+			assert.NotEqual(t, omahaSpec.AppOK, respApp.Status)
+			assert.Equal(t, omahaSpec.UpdateInternalError, respApp.UpdateCheck.Status)
+			_, err := a.GetInstance(reqApp.MachineID, tApp.ID)
+			assert.Error(t, err, "The machine whose group wasn't found isn't registered.")
+		case "ok-machine":
+			assert.Equal(t, omahaSpec.AppOK, respApp.Status)
+			assert.Equal(t, omahaSpec.UpdateOK, respApp.UpdateCheck.Status)
+			require.NotNil(t, respApp.UpdateCheck.Manifest)
+			assert.Equal(t, "update.tgz", respApp.UpdateCheck.Manifest.Packages[0].Name)
+			assert.NotNil(t, respApp.Ping)
+			instance, err := a.GetInstance(reqApp.MachineID, tApp.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tGroup.ID, instance.Application.GroupID.String)
+			assert.True(t, instance.Application.UpdateInProgress)
+		}
+	}
+}
+
+func TestPollIntervals(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+	h.SetPollIntervals(time.Minute, time.Hour)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.tgz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	pollInterval := func(version, machineID string) (omahaSpec.UpdateStatus, string) {
+		t.Helper()
+		omahaReq := omahaSpec.NewRequest()
+		omahaReq.OS.Arch = reqArch
+		appReq := omahaReq.AddApp(tApp.ID, version)
+		appReq.MachineID = machineID
+		appReq.Track = tGroup.ID
+		appReq.AddUpdateCheck()
+		rawOmahaReq, err := xml.Marshal(omahaReq)
+		require.NoError(t, err)
+
+		rawOmahaResp := new(bytes.Buffer)
+		require.NoError(t, h.Handle(bytes.NewReader(rawOmahaReq), rawOmahaResp, "127.0.0.1"))
+		var resp struct {
+			Apps []struct {
+				PollInterval string `xml:"poll_interval,attr"`
+				UpdateCheck  struct {
+					Status omahaSpec.UpdateStatus `xml:"status,attr"`
+				} `xml:"updatecheck"`
+			} `xml:"app"`
+		}
+		require.NoError(t, xml.Unmarshal(rawOmahaResp.Bytes(), &resp))
+		require.Len(t, resp.Apps, 1)
+		return resp.Apps[0].UpdateCheck.Status, resp.Apps[0].PollInterval
+	}
+
+	status, interval := pollInterval("640.0.0", "idle-machine")
+	assert.Equal(t, omahaSpec.NoUpdate, status)
+	assert.Equal(t, "3600", interval, "Instances of idle groups poll slowly.")
+
+	status, interval = pollInterval("610.0.0", "updating-machine")
+	assert.Equal(t, omahaSpec.UpdateOK, status)
+	assert.Equal(t, "60", interval, "Granting the update starts the rollout.")
+
+	status, interval = pollInterval("640.0.0", "idle-machine")
+	assert.Equal(t, omahaSpec.NoUpdate, status)
+	assert.Equal(t, "60", interval, "Instances of groups with a rollout in progress poll frequently.")
+
+	h.SetPollIntervals(0, 0)
+	_, interval = pollInterval("640.0.0", "idle-machine")
+	assert.Empty(t, interval)
+}
+
+func TestFlatcarActionPayloadSignature(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tAppFlatcar, _ := a.GetApp(flatcarAppID)
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeFlatcar, URL: "http://sample.url/pkg", Filename: null.StringFrom("flatcarupdate.tgz"), Version: "99640.0.0", ApplicationID: tAppFlatcar.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "mychannel", Color: "white", ApplicationID: tAppFlatcar.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "Production", ApplicationID: tAppFlatcar.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 2, PolicyUpdateTimeout: "60 minutes"})
+	tFlatcarAction, err := a.AddFlatcarAction(&api.FlatcarAction{Event: "postinstall", Sha256: "fsdkjjfghsdakjfgaksdjfasd", PayloadSignature: "c2lnbmF0dXJl", SigningKeyID: "release-key-2026", PackageID: tPkg.ID})
+	require.NoError(t, err)
+
+	omahaReq := omahaSpec.NewRequest()
+	omahaReq.OS.Arch = reqArch
+	appReq := omahaReq.AddApp(tAppFlatcar.ID, "610.0.0")
+	appReq.MachineID = "65e1266d-6f54-4b87-9080-23b99ca9c12f"
+	appReq.Track = tGroup.ID
+	appReq.AddUpdateCheck()
+	rawOmahaReq, err := xml.Marshal(omahaReq)
+	require.NoError(t, err)
+
+	rawOmahaResp := new(bytes.Buffer)
+	require.NoError(t, h.Handle(bytes.NewReader(rawOmahaReq), rawOmahaResp, "127.0.0.1"))
+	var resp struct {
+		Apps []struct {
+			Actions []struct {
+				SHA256           string `xml:"sha256,attr"`
+				PayloadSignature string `xml:"PayloadSignature,attr"`
+				SigningKeyID     string `xml:"SigningKeyId,attr"`
+			} `xml:"updatecheck>manifest>actions>action"`
+		} `xml:"app"`
+	}
+	require.NoError(t, xml.Unmarshal(rawOmahaResp.Bytes(), &resp))
+	require.Len(t, resp.Apps, 1)
+	require.Len(t, resp.Apps[0].Actions, 1)
+	assert.Equal(t, tFlatcarAction.Sha256, resp.Apps[0].Actions[0].SHA256)
+	assert.Equal(t, tFlatcarAction.PayloadSignature, resp.Apps[0].Actions[0].PayloadSignature)
+	assert.Equal(t, tFlatcarAction.SigningKeyID, resp.Apps[0].Actions[0].SigningKeyID)
+}
+
+func TestEncodings(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Filename: null.StringFrom("update.tgz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	for encoding, machineID := range map[Encoding]string{EncodingXML: "xml-machine", EncodingJSON: "json-machine"} {
+		omahaResp := doOmahaRequestWithEncoding(t, h, encoding, tApp.ID, "610.0.0", machineID, tGroup.ID, "127.0.0.1", true, true, nil)
+		checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+		checkOmahaPingResponse(t, omahaResp, tApp.ID, true)
+		checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "update.tgz", tPkg.URL, omahaSpec.UpdateOK)
+
+		omahaResp = doOmahaRequestWithEncoding(t, h, encoding, tApp.ID, "610.0.0", machineID, tGroup.ID, "127.0.0.1", false, false, ei(omahaSpec.EventTypeUpdateDownloadStarted, omahaSpec.EventResultSuccess, ""))
+		checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+		checkOmahaEventResponse(t, omahaResp, tApp.ID, 1)
+
+		instance, err := a.GetInstance(machineID, tApp.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, int(api.InstanceStatusDownloading), int(instance.Application.Status.Int64))
+	}
+
+	err := h.HandleEncoding(bytes.NewReader([]byte("null")), new(bytes.Buffer), "127.0.0.1", EncodingJSON)
+	assert.Error(t, err)
+
+	assert.Equal(t, EncodingJSON, EncodingFromContentType("application/json; charset=utf-8"))
+	assert.Equal(t, EncodingXML, EncodingFromContentType("text/xml"))
+	assert.Equal(t, EncodingXML, EncodingFromContentType(""))
+}
+
+func TestInstanceLabels(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", Track: "labels-track"})
+
+	xmlReq := `<request protocol="3.0"><os platform="CoreOS" arch="x64"></os>` +
+		`<app appid="` + tApp.ID + `" version="610.0.0" track="labels-track" machineid="xml-labels-machine" label_region="eu-west" label_role="worker"><ping></ping></app></request>`
+	err := h.Handle(bytes.NewReader([]byte(xmlReq)), new(bytes.Buffer), "127.0.0.1")
+	require.NoError(t, err)
+
+	jsonReq := `{"OS": {"Platform": "CoreOS", "Arch": "x64"}, "Apps": [{"ID": "` + tApp.ID + `", "Version": "610.0.0", "Track": "labels-track", "MachineID": "json-labels-machine", "Ping": {}, "label_region": "us-east", "label_role": "worker"}]}`
+	err = h.HandleEncoding(bytes.NewReader([]byte(jsonReq)), new(bytes.Buffer), "127.0.0.1", EncodingJSON)
+	require.NoError(t, err)
+
+	instance, err := a.GetInstance("xml-labels-machine", tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, api.InstanceLabels{"region": "eu-west", "role": "worker"}, instance.Labels)
+	assert.Equal(t, tGroup.ID, instance.Application.GroupID.String)
+
+	instances, err := a.GetInstancesByLabel(tApp.ID, "region", "us-east")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, "json-labels-machine", instances[0].ID)
+
+	instances, err = a.GetInstancesByLabel(tApp.ID, "role", "worker")
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+
+	// Requests without labels keep the ones reported before.
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "xml-labels-machine", "labels-track", "127.0.0.1", true, false, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	instance, err = a.GetInstance("xml-labels-machine", tApp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, api.InstanceLabels{"region": "eu-west", "role": "worker"}, instance.Labels)
+}
+
+func TestDuplicateEvents(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	_, _ = a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", Track: "dedup-track"})
+
+	machineID := "dedup-machine"
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", machineID, "dedup-track", "127.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+
+	// Resent events are acknowledged but only registered once.
+	xmlReq := `<request protocol="3.0"><os platform="CoreOS" arch="x64"></os>` +
+		`<app appid="` + tApp.ID + `" version="610.0.0" track="dedup-track" machineid="` + machineID + `">` +
+		`<event eventtype="13" eventresult="1" sequence="1"></event></app></request>`
+	for i := 0; i < 2; i++ {
+		buf := new(bytes.Buffer)
+		require.NoError(t, h.Handle(strings.NewReader(xmlReq), buf, "127.0.0.1"))
+		var omahaResp omahaSpec.Response
+		require.NoError(t, xml.Unmarshal(buf.Bytes(), &omahaResp))
+		checkOmahaEventResponse(t, &omahaResp, tApp.ID, 1)
+	}
+
+	events, err := a.GetInstanceTimeline(machineID, tApp.ID)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestUpdateDecisionReasons(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes", Track: "reasons-track"})
+
+	handle := func(handle func(io.Reader, io.Writer, string, Encoding) (map[UpdateDecisionKey]api.UpdateDecisionReason, error), version string) (*omahaSpec.Response, api.UpdateDecisionReason) {
+		xmlReq := `<request protocol="3.0"><os platform="CoreOS" arch="x64"></os>` +
+			`<app appid="` + tApp.ID + `" version="` + version + `" track="reasons-track" machineid="reasons-machine">` +
+			`<updatecheck></updatecheck></app></request>`
+		buf := new(bytes.Buffer)
+		reasons, err := handle(strings.NewReader(xmlReq), buf, "127.0.0.1", EncodingXML)
+		require.NoError(t, err)
+		var omahaResp omahaSpec.Response
+		require.NoError(t, xml.Unmarshal(buf.Bytes(), &omahaResp))
+		return &omahaResp, reasons[UpdateDecisionKey{MachineID: "reasons-machine", AppID: tApp.ID}]
+	}
+
+	omahaResp, reason := handle(h.HandleWithReasons, "640.0.0")
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+	assert.Equal(t, api.UpdateReasonUpToDate, reason)
+
+	require.NoError(t, a.PauseGroup(tGroup.ID))
+	omahaResp, reason = handle(h.HandleDryRunWithReasons, "610.0.0")
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	checkOmahaUpdateResponse(t, omahaResp, "", "", "", omahaSpec.NoUpdate)
+	assert.Equal(t, api.UpdateReasonGroupPaused, reason)
+
+	require.NoError(t, a.ResumeGroup(tGroup.ID))
+	omahaResp, reason = handle(h.HandleWithReasons, "610.0.0")
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	assert.Equal(t, api.UpdateReasonGranted, reason)
+
+	// The reasons of several machines checking for updates to the same
+	// application in a single request are kept apart.
+	xmlReq := `<request protocol="3.0"><os platform="CoreOS" arch="x64"></os>` +
+		`<app appid="` + tApp.ID + `" version="640.0.0" track="reasons-track" machineid="reasons-machine-1"><updatecheck></updatecheck></app>` +
+		`<app appid="` + tApp.ID + `" version="610.0.0" track="reasons-track" machineid="reasons-machine-2"><updatecheck></updatecheck></app>` +
+		`</request>`
+	reasons, err := h.HandleDryRunWithReasons(strings.NewReader(xmlReq), new(bytes.Buffer), "127.0.0.1", EncodingXML)
+	require.NoError(t, err)
+	assert.Equal(t, map[UpdateDecisionKey]api.UpdateDecisionReason{
+		{MachineID: "reasons-machine-1", AppID: tApp.ID}: api.UpdateReasonUpToDate,
+		{MachineID: "reasons-machine-2", AppID: tApp.ID}: api.UpdateReasonGranted,
+	}, reasons)
+}
+
 func TestFlatcarGroupNamesConversionToIds(t *testing.T) {
 	a := newForTest(t)
 	defer a.Close()
@@ -241,6 +1009,36 @@ func TestFlatcarGroupNamesConversionToIds(t *testing.T) {
 	checkOmahaResponse(t, omahaResp, flatcarAppIDWithCurlyBraces, omahaSpec.AppOK)
 }
 
+func TestTrackAliases(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	flatcarAppIDWithCurlyBraces := "{" + flatcarAppID + "}"
+	machineID := "65e1266d-6f54-4b87-9080-23b99ca9c12f"
+	machineIP := "10.0.0.1"
+
+	omahaResp := doOmahaRequest(t, h, flatcarAppID, "2000.0.0", machineID, "prod", machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, flatcarAppID, omahaSpec.AppStatus("error-failedToRetrieveUpdatePackageInfo"))
+
+	require.NoError(t, a.SetTrackAlias(flatcarAppID, "prod", "stable"))
+
+	omahaResp = doOmahaRequest(t, h, flatcarAppID, "2000.0.0", machineID, "prod", machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, flatcarAppID, omahaSpec.AppOK)
+
+	omahaResp = doOmahaRequest(t, h, flatcarAppIDWithCurlyBraces, "2000.0.0", machineID, "prod", machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, flatcarAppIDWithCurlyBraces, omahaSpec.AppOK)
+
+	// Unknown tracks still fail to register.
+	omahaResp = doOmahaRequest(t, h, flatcarAppID, "2000.0.0", machineID, "staging", machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, flatcarAppID, omahaSpec.AppStatus("error-failedToRetrieveUpdatePackageInfo"))
+
+	// Aliases resolving to unknown tracks too.
+	require.NoError(t, a.SetTrackAlias(flatcarAppID, "staging", "missing"))
+	omahaResp = doOmahaRequest(t, h, flatcarAppID, "2000.0.0", machineID, "staging", machineIP, false, true, nil)
+	checkOmahaResponse(t, omahaResp, flatcarAppID, omahaSpec.AppStatus("error-failedToRetrieveUpdatePackageInfo"))
+}
+
 type eventInfo struct {
 	Type            omahaSpec.EventType
 	Result          omahaSpec.EventResult
@@ -256,11 +1054,27 @@ func ei(t omahaSpec.EventType, r omahaSpec.EventResult, pv string) *eventInfo {
 }
 
 func doOmahaRequest(t *testing.T, h *Handler, appID, appVersion, appMachineID, appTrack, ip string, addPing, updateCheck bool, eventInfo *eventInfo) *omahaSpec.Response {
+	return doOmahaRequestWithArch(t, h, appID, appVersion, appMachineID, appTrack, ip, reqArch, addPing, updateCheck, eventInfo)
+}
+
+func doOmahaRequestWithArch(t *testing.T, h *Handler, appID, appVersion, appMachineID, appTrack, ip, arch string, addPing, updateCheck bool, eventInfo *eventInfo) *omahaSpec.Response {
+	return handleOmahaRequest(t, h.HandleEncoding, EncodingXML, appID, appVersion, appMachineID, appTrack, ip, arch, addPing, updateCheck, eventInfo)
+}
+
+func doOmahaRequestWithEncoding(t *testing.T, h *Handler, encoding Encoding, appID, appVersion, appMachineID, appTrack, ip string, addPing, updateCheck bool, eventInfo *eventInfo) *omahaSpec.Response {
+	return handleOmahaRequest(t, h.HandleEncoding, encoding, appID, appVersion, appMachineID, appTrack, ip, reqArch, addPing, updateCheck, eventInfo)
+}
+
+func doOmahaDryRunRequest(t *testing.T, h *Handler, appID, appVersion, appMachineID, appTrack, ip string, addPing, updateCheck bool, eventInfo *eventInfo) *omahaSpec.Response {
+	return handleOmahaRequest(t, h.HandleDryRunEncoding, EncodingXML, appID, appVersion, appMachineID, appTrack, ip, reqArch, addPing, updateCheck, eventInfo)
+}
+
+func handleOmahaRequest(t *testing.T, handle func(io.Reader, io.Writer, string, Encoding) error, encoding Encoding, appID, appVersion, appMachineID, appTrack, ip, arch string, addPing, updateCheck bool, eventInfo *eventInfo) *omahaSpec.Response {
 	omahaReq := omahaSpec.NewRequest()
 	omahaReq.OS.Version = reqVersion
 	omahaReq.OS.Platform = reqPlatform
 	omahaReq.OS.ServicePack = reqSp
-	omahaReq.OS.Arch = reqArch
+	omahaReq.OS.Arch = arch
 	appReq := omahaReq.AddApp(appID, appVersion)
 	appReq.MachineID = appMachineID
 	appReq.Track = appTrack
@@ -277,15 +1091,20 @@ func doOmahaRequest(t *testing.T, h *Handler, appID, appVersion, appMachineID, a
 		appReq.AddPing()
 	}
 
-	omahaReqXML, err := xml.Marshal(omahaReq)
+	marshal, unmarshal := xml.Marshal, xml.Unmarshal
+	if encoding == EncodingJSON {
+		marshal, unmarshal = json.Marshal, json.Unmarshal
+	}
+
+	rawOmahaReq, err := marshal(omahaReq)
 	assert.NoError(t, err)
 
-	omahaRespXML := new(bytes.Buffer)
-	err = h.Handle(bytes.NewReader(omahaReqXML), omahaRespXML, ip)
+	rawOmahaResp := new(bytes.Buffer)
+	err = handle(bytes.NewReader(rawOmahaReq), rawOmahaResp, ip, encoding)
 	assert.NoError(t, err)
 
 	var omahaResp *omahaSpec.Response
-	err = xml.NewDecoder(omahaRespXML).Decode(&omahaResp)
+	err = unmarshal(rawOmahaResp.Bytes(), &omahaResp)
 	assert.NoError(t, err)
 
 	return omahaResp
@@ -311,7 +1130,9 @@ func checkOmahaUpdateResponse(t *testing.T, omahaResp *omahaSpec.Response, expec
 	assert.Equal(t, expectedError, appResp.UpdateCheck.Status)
 
 	if appResp.UpdateCheck.Manifest != nil {
-		assert.True(t, appResp.UpdateCheck.Manifest.Version >= expectedVersion)
+		manifestSemver, err := semver.Make(appResp.UpdateCheck.Manifest.Version)
+		require.NoError(t, err)
+		assert.True(t, manifestSemver.GTE(semver.MustParse(expectedVersion)))
 		assert.Equal(t, expectedPackageName, appResp.UpdateCheck.Manifest.Packages[0].Name)
 	}
 
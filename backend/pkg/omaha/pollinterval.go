@@ -0,0 +1,32 @@
+package omaha
+
+import (
+	"time"
+)
+
+// SetPollIntervals makes the handler hint the instances how often they should
+// check for updates, in the poll_interval attribute of the apps of the
+// responses, in seconds. Instances of groups with a rollout in progress are
+// told to check every rollout interval, so they pick the update up quickly,
+// and the rest every idle interval. Zero intervals are not hinted.
+func (h *Handler) SetPollIntervals(rollout, idle time.Duration) {
+	h.rolloutPollInterval = rollout
+	h.idlePollInterval = idle
+}
+
+// pollInterval returns the interval the instances of the group provided
+// should check for updates with, or 0 if none must be hinted.
+func (h *Handler) pollInterval(groupID string) time.Duration {
+	if h.rolloutPollInterval == 0 && h.idlePollInterval == 0 {
+		return 0
+	}
+	inProgress, err := h.crAPI.IsGroupRolloutInProgress(groupID)
+	if err != nil {
+		logger.Debug().Str("groupID", groupID).Msgf("pollInterval - could not get group %s", err.Error())
+		return 0
+	}
+	if inProgress {
+		return h.rolloutPollInterval
+	}
+	return h.idlePollInterval
+}
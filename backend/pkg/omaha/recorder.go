@@ -0,0 +1,169 @@
+package omaha
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+// ErrRecordedRequestNotFound error indicates that the recorded request to
+// replay doesn't exist, or it was already evicted from the recorder.
+var ErrRecordedRequestNotFound = errors.New("omaha: recorded request not found")
+
+// RecordedRequest is a raw Omaha request captured by a RequestRecorder.
+type RecordedRequest struct {
+	ID         uint64    `json:"id"`
+	ReceivedAt time.Time `json:"received_at"`
+	IP         string    `json:"ip"`
+	Encoding   Encoding  `json:"encoding"`
+	Body       string    `json:"body"`
+}
+
+// RequestRecorder keeps the last raw Omaha requests handled, so they can be
+// replayed later to reproduce the behavior of the clients. It's a ring
+// buffer, once full the oldest requests are evicted.
+type RequestRecorder struct {
+	mu       sync.Mutex
+	requests []RecordedRequest
+	next     int
+	lastID   uint64
+}
+
+// NewRequestRecorder creates a recorder keeping the given number of
+// requests.
+func NewRequestRecorder(size int) *RequestRecorder {
+	return &RequestRecorder{
+		requests: make([]RecordedRequest, 0, size),
+	}
+}
+
+// record stores the raw request provided, received at the given time,
+// evicting the oldest one when the recorder is full.
+func (r *RequestRecorder) record(body []byte, ip string, encoding Encoding, receivedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cap(r.requests) == 0 {
+		return
+	}
+	r.lastID++
+	req := RecordedRequest{
+		ID:         r.lastID,
+		ReceivedAt: receivedAt,
+		IP:         ip,
+		Encoding:   encoding,
+		Body:       string(body),
+	}
+	if len(r.requests) < cap(r.requests) {
+		r.requests = append(r.requests, req)
+		return
+	}
+	r.requests[r.next] = req
+	r.next = (r.next + 1) % len(r.requests)
+}
+
+// Requests returns the recorded requests, oldest first.
+func (r *RequestRecorder) Requests() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requests := make([]RecordedRequest, 0, len(r.requests))
+	requests = append(requests, r.requests[r.next:]...)
+	return append(requests, r.requests[:r.next]...)
+}
+
+// RequestsFor returns the recorded requests whose applications can all be
+// accessed through the api instance provided, oldest first. Scoping the api
+// instance to a team restricts them to the requests of its applications.
+func (r *RequestRecorder) RequestsFor(a *api.API) []RecordedRequest {
+	requests := []RecordedRequest{}
+	for _, req := range r.Requests() {
+		if req.accessibleBy(a) {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// GetFor works like Get, but reports the requests whose applications can't
+// all be accessed through the api instance provided as not found.
+func (r *RequestRecorder) GetFor(a *api.API, id uint64) (RecordedRequest, error) {
+	req, err := r.Get(id)
+	if err != nil {
+		return RecordedRequest{}, err
+	}
+	if !req.accessibleBy(a) {
+		return RecordedRequest{}, ErrRecordedRequestNotFound
+	}
+	return req, nil
+}
+
+// accessibleBy checks if all the applications of the request can be accessed
+// through the api instance provided. Malformed requests, whose applications
+// are unknown, can't be accessed.
+func (req *RecordedRequest) accessibleBy(a *api.API) bool {
+	omahaReq, _, err := req.Encoding.decodeRequest(strings.NewReader(req.Body))
+	if err != nil || len(omahaReq.Apps) == 0 {
+		return false
+	}
+	for _, reqApp := range omahaReq.Apps {
+		if _, err := a.GetApp(reqApp.ID); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the recorded request identified by the id provided.
+func (r *RequestRecorder) Get(id uint64) (RecordedRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, req := range r.requests {
+		if req.ID == id {
+			return req, nil
+		}
+	}
+	return RecordedRequest{}, ErrRecordedRequestNotFound
+}
+
+// RecordRequests makes the handler record the raw requests it handles in the
+// recorder provided. Dry-run requests are not recorded.
+func (h *Handler) RecordRequests(recorder *RequestRecorder) {
+	h.recorder = recorder
+}
+
+// recordRequest records the raw request provided when the handler has a
+// recorder, returning a reader of the same request to handle it.
+func (h *Handler) recordRequest(rawReq io.Reader, ip string, encoding Encoding) (io.Reader, error) {
+	if h.recorder == nil {
+		return rawReq, nil
+	}
+	body, err := ioutil.ReadAll(rawReq)
+	if err != nil {
+		return nil, err
+	}
+	h.recorder.record(body, ip, encoding, h.crAPI.Now())
+	return bytes.NewReader(body), nil
+}
+
+// ReplayRequest feeds the recorded request identified by the id provided
+// through the handler in dry-run mode, as if it was sent again by the same
+// ip, so nothing is written to the database. It returns the reasons of the
+// update decisions like HandleDryRunWithReasons.
+func (h *Handler) ReplayRequest(id uint64, respWriter io.Writer) (map[UpdateDecisionKey]api.UpdateDecisionReason, error) {
+	if h.recorder == nil {
+		return nil, ErrRecordedRequestNotFound
+	}
+	req, err := h.recorder.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return h.HandleDryRunWithReasons(bytes.NewReader([]byte(req.Body)), respWriter, req.IP, req.Encoding)
+}
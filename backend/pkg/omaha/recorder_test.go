@@ -0,0 +1,114 @@
+package omaha
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+func TestRequestRecorder(t *testing.T) {
+	r := NewRequestRecorder(2)
+	assert.Empty(t, r.Requests())
+
+	for i := 1; i <= 3; i++ {
+		r.record([]byte(fmt.Sprintf("<request%d/>", i)), "10.0.0.1", EncodingXML, time.Now())
+	}
+
+	requests := r.Requests()
+	require.Len(t, requests, 2, "The oldest requests are evicted.")
+	assert.Equal(t, uint64(2), requests[0].ID)
+	assert.Equal(t, "<request2/>", requests[0].Body)
+	assert.Equal(t, uint64(3), requests[1].ID)
+	assert.Equal(t, "10.0.0.1", requests[1].IP)
+
+	_, err := r.Get(1)
+	assert.Equal(t, ErrRecordedRequestNotFound, err)
+	req, err := r.Get(3)
+	require.NoError(t, err)
+	assert.Equal(t, "<request3/>", req.Body)
+}
+
+func TestReplayRequest(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	_, err := h.ReplayRequest(1, new(bytes.Buffer))
+	assert.Equal(t, ErrRecordedRequestNotFound, err, "Nothing is recorded by default.")
+
+	recorder := NewRequestRecorder(10)
+	h.RecordRequests(recorder)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg", Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 1, PolicyUpdateTimeout: "60 minutes"})
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "recorded-machine", tGroup.ID, "10.0.0.1", false, true, nil)
+	checkOmahaUpdateResponse(t, omahaResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	_ = doOmahaDryRunRequest(t, h, tApp.ID, "610.0.0", "dry-run-machine", tGroup.ID, "10.0.0.2", false, true, nil)
+
+	requests := recorder.Requests()
+	require.Len(t, requests, 1, "Dry-run requests are not recorded.")
+	assert.Equal(t, "10.0.0.1", requests[0].IP)
+	assert.Contains(t, requests[0].Body, "recorded-machine")
+
+	var resp bytes.Buffer
+	reasons, err := h.ReplayRequest(requests[0].ID, &resp)
+	require.NoError(t, err)
+	assert.Equal(t, api.UpdateReasonAlreadyGranted, reasons[UpdateDecisionKey{MachineID: "recorded-machine", AppID: tApp.ID}])
+	var replayResp *omahaSpec.Response
+	require.NoError(t, xml.Unmarshal(resp.Bytes(), &replayResp))
+	checkOmahaUpdateResponse(t, replayResp, tPkg.Version, "", tPkg.URL, omahaSpec.UpdateOK)
+	assert.Len(t, recorder.Requests(), 1, "Replayed requests are not recorded again.")
+
+	_, err = h.ReplayRequest(100, new(bytes.Buffer))
+	assert.Equal(t, ErrRecordedRequestNotFound, err)
+}
+
+func TestRecordedRequests_TeamScope(t *testing.T) {
+	clock := api.NewMockClock(time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC))
+	a, err := api.NewForTest(api.OptionInitDB, api.OptionClock(clock))
+	require.NoError(t, err)
+	defer a.Close()
+	h := NewHandler(a)
+	recorder := NewRequestRecorder(10)
+	h.RecordRequests(recorder)
+
+	tTeam1, _ := a.AddTeam(&api.Team{Name: "test_team1"})
+	tTeam2, _ := a.AddTeam(&api.Team{Name: "test_team2"})
+	tApp1, _ := a.AddApp(&api.Application{Name: "test_app1", TeamID: tTeam1.ID})
+	tApp2, _ := a.AddApp(&api.Application{Name: "test_app2", TeamID: tTeam2.ID})
+	tGroup1, _ := a.AddGroup(&api.Group{Name: "group1", ApplicationID: tApp1.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+	tGroup2, _ := a.AddGroup(&api.Group{Name: "group2", ApplicationID: tApp2.ID, PolicyPeriodInterval: "15 minutes", PolicyUpdateTimeout: "60 minutes"})
+
+	_ = doOmahaRequest(t, h, tApp1.ID, "610.0.0", "team1-machine", tGroup1.ID, "10.0.0.1", true, false, nil)
+	_ = doOmahaRequest(t, h, tApp2.ID, "610.0.0", "team2-machine", tGroup2.ID, "10.0.0.2", true, false, nil)
+	assert.Error(t, h.Handle(bytes.NewReader([]byte("not xml")), new(bytes.Buffer), "10.0.0.3"))
+
+	all := recorder.Requests()
+	require.Len(t, all, 3)
+	assert.Equal(t, clock.Now(), all[0].ReceivedAt, "Requests are stamped with the API clock.")
+
+	team1 := a.WithTeam(tTeam1.ID)
+	requests := recorder.RequestsFor(team1)
+	require.Len(t, requests, 1, "Only the requests of the team's apps are returned.")
+	assert.Contains(t, requests[0].Body, "team1-machine")
+
+	_, err = recorder.GetFor(team1, all[0].ID)
+	assert.NoError(t, err)
+	_, err = recorder.GetFor(team1, all[1].ID)
+	assert.Equal(t, ErrRecordedRequestNotFound, err)
+	_, err = recorder.GetFor(team1, all[2].ID)
+	assert.Equal(t, ErrRecordedRequestNotFound, err, "Malformed requests belong to no team.")
+}
@@ -0,0 +1,48 @@
+package omaha
+
+import (
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+// URLSigner transforms the URLs the packages are downloaded from before they
+// are handed to the instances, so payloads can be served by CDNs requiring
+// an auth token in the URL, like signed URLs that expire shortly. The URLs
+// are the codebases the files of the package's manifest are downloaded from,
+// its URL and its mirrors, so the signatures must cover their paths.
+type URLSigner interface {
+	// SignURL returns the URL the instance identified by the machine id
+	// provided must download the given package from instead of url.
+	SignURL(pkg *api.Package, url, machineID string) (string, error)
+}
+
+// URLSignerFunc is an adapter to use a function as URLSigner.
+type URLSignerFunc func(pkg *api.Package, url, machineID string) (string, error)
+
+// SignURL calls f(pkg, url, machineID).
+func (f URLSignerFunc) SignURL(pkg *api.Package, url, machineID string) (string, error) {
+	return f(pkg, url, machineID)
+}
+
+// SignURLs makes the handler transform the URLs of the packages offered with
+// the signer provided each time they are served.
+func (h *Handler) SignURLs(signer URLSigner) {
+	h.urlSigner = signer
+}
+
+// packageURLs returns the URLs the instance identified by the machine id
+// provided must download the given package from, its URL followed by its
+// mirrors, signed when the handler has a signer.
+func (h *Handler) packageURLs(pkg *api.Package, machineID string) ([]string, error) {
+	urls := append([]string{pkg.URL}, pkg.Mirrors...)
+	if h.urlSigner == nil {
+		return urls, nil
+	}
+	for i, url := range urls {
+		signed, err := h.urlSigner.SignURL(pkg, url, machineID)
+		if err != nil {
+			return nil, err
+		}
+		urls[i] = signed
+	}
+	return urls, nil
+}
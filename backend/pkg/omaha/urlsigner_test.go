@@ -0,0 +1,48 @@
+package omaha
+
+import (
+	"errors"
+	"testing"
+
+	omahaSpec "github.com/kinvolk/go-omaha/omaha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/kinvolk/nebraska/backend/pkg/api"
+)
+
+func TestSignURLs(t *testing.T) {
+	a := newForTest(t)
+	defer a.Close()
+	h := NewHandler(a)
+
+	tTeam, _ := a.AddTeam(&api.Team{Name: "test_team"})
+	tApp, _ := a.AddApp(&api.Application{Name: "test_app", Description: "Test app", TeamID: tTeam.ID})
+	tPkg, _ := a.AddPackage(&api.Package{Type: api.PkgTypeOther, URL: "http://sample.url/pkg/", Filename: null.StringFrom("update.tgz"), Version: "640.0.0", ApplicationID: tApp.ID, Arch: api.ArchAMD64, Mirrors: api.StringArray{"http://mirror.url/pkg/"}})
+	tChannel, _ := a.AddChannel(&api.Channel{Name: "test_channel", Color: "blue", ApplicationID: tApp.ID, PackageID: null.StringFrom(tPkg.ID), Arch: api.ArchAMD64})
+	tGroup, _ := a.AddGroup(&api.Group{Name: "test_group", ApplicationID: tApp.ID, ChannelID: null.StringFrom(tChannel.ID), PolicyUpdatesEnabled: true, PolicySafeMode: false, PolicyPeriodInterval: "15 minutes", PolicyMaxUpdatesPerPeriod: 10, PolicyUpdateTimeout: "60 minutes"})
+
+	h.SignURLs(URLSignerFunc(func(pkg *api.Package, url, machineID string) (string, error) {
+		if machineID == "unsignable-machine" {
+			return "", errors.New("signer unavailable")
+		}
+		assert.Equal(t, tPkg.ID, pkg.ID)
+		return url + "token-for-" + machineID + "/", nil
+	}))
+
+	omahaResp := doOmahaRequest(t, h, tApp.ID, "610.0.0", "signed-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	checkOmahaResponse(t, omahaResp, tApp.ID, omahaSpec.AppOK)
+	require.NotNil(t, omahaResp.Apps[0].UpdateCheck)
+	assert.Equal(t, omahaSpec.UpdateOK, omahaResp.Apps[0].UpdateCheck.Status)
+	urls := omahaResp.Apps[0].UpdateCheck.URLs
+	require.Len(t, urls, 2)
+	assert.Equal(t, "http://sample.url/pkg/token-for-signed-machine/", urls[0].CodeBase)
+	assert.Equal(t, "http://mirror.url/pkg/token-for-signed-machine/", urls[1].CodeBase)
+
+	// The package isn't offered when its URLs can't be signed.
+	omahaResp = doOmahaRequest(t, h, tApp.ID, "610.0.0", "unsignable-machine", tGroup.ID, "127.0.0.1", false, true, nil)
+	require.NotNil(t, omahaResp.Apps[0].UpdateCheck)
+	assert.Equal(t, omahaSpec.UpdateInternalError, omahaResp.Apps[0].UpdateCheck.Status)
+	assert.Nil(t, omahaResp.Apps[0].UpdateCheck.Manifest)
+}
@@ -17,6 +17,8 @@ export interface Group {
   policy_period_interval: string;
   policy_max_updates_per_period: number;
   policy_update_timeout: string;
+  policy_update_timeout_action?: string;
+  policy_windows_bypass_severity?: null | string;
   channel: Channel;
   track: string;
 }
@@ -30,6 +32,7 @@ export interface Channel {
   package_id: null | string;
   package: Package;
   arch: Arch;
+  revision?: number;
 }
 
 export interface Package {
@@ -46,6 +49,10 @@ export interface Package {
   application_id: string;
   flatcar_action?: FlatcarAction;
   arch: Arch;
+  mirrors?: null | string[];
+  min_previous_version?: null | string;
+  revision?: number;
+  severity?: string;
 }
 
 export interface FlatcarAction {
@@ -59,6 +66,8 @@ export interface FlatcarAction {
   metadata_signature_rsa?: string;
   metadata_size?: string;
   deadline?: string;
+  payload_signature?: string;
+  signing_key_id?: string;
   created_ts?: string;
   package_id?: string;
 }
@@ -87,6 +87,8 @@ function EditDialog(props: { data: any; show: boolean; create?: boolean; onHide:
       packageFunctionCall = applicationsStore.createPackage(data);
     } else {
       data['id'] = props.data.channel.id;
+      data.mirrors = props.data.channel.mirrors;
+      data.min_previous_version = props.data.channel.min_previous_version;
       packageFunctionCall = applicationsStore.updatePackage(data);
     }
 
@@ -126,6 +126,62 @@ class ActivityStore extends Store {
         description:
           'Channel ' + entry.channel_name + ' is now pointing to version ' + entry.version,
       },
+      7: {
+        type: 'activityInstanceVersionMalformed',
+        appName: entry.application_name,
+        groupName: entry.group_name,
+        channelName: entry.channel_name,
+        description: (
+          <React.Fragment>
+            Instance{' '}
+            <Link component={RouterLink} to={instancePath}>
+              {entry.instance_id}
+            </Link>{' '}
+            reported the malformed version {entry.version}, it is being treated as outdated
+          </React.Fragment>
+        ),
+      },
+      8: {
+        type: 'activityVersionSpreadExceeded',
+        appName: entry.application_name,
+        groupName: entry.group_name,
+        channelName: entry.channel_name,
+        description:
+          'Instances are running more different versions than allowed, the newest being ' +
+          entry.version,
+      },
+      9: {
+        type: 'activityRolloutRolledBack',
+        appName: entry.application_name,
+        groupName: entry.group_name,
+        channelName: entry.channel_name,
+        description:
+          "Too many updates failed, the group's channel has been rolled back to version " +
+          entry.version,
+      },
+      10: {
+        type: 'activityChannelPackagePromoted',
+        appName: entry.application_name,
+        groupName: entry.group_name,
+        channelName: entry.channel_name,
+        description:
+          'Version ' + entry.version + ' has been promoted to channel ' + entry.channel_name,
+      },
+      11: {
+        type: 'activityInstanceUpdateTimedOut',
+        appName: entry.application_name,
+        groupName: entry.group_name,
+        channelName: entry.channel_name,
+        description: (
+          <React.Fragment>
+            Instance{' '}
+            <Link component={RouterLink} to={instancePath}>
+              {entry.instance_id}
+            </Link>{' '}
+            timed out while updating to version {entry.version}
+          </React.Fragment>
+        ),
+      },
     };
 
     const classDetails = classID ? classType[classID] : classType[1];